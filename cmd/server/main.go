@@ -4,6 +4,7 @@ import (
 	"context"
 	"crypto/tls"
 	"errors"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -13,12 +14,22 @@ import (
 	"time"
 
 	"github.com/getsentry/sentry-go"
+	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
 	"golang.org/x/crypto/acme/autocert"
 
+	"gopublic/internal/alerts"
+	"gopublic/internal/capture"
 	"gopublic/internal/config"
 	"gopublic/internal/dashboard"
+	"gopublic/internal/filter"
+	"gopublic/internal/geoip"
 	"gopublic/internal/ingress"
+	"gopublic/internal/jobs"
+	"gopublic/internal/metrics"
+	"gopublic/internal/notify"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/scan"
 	"gopublic/internal/server"
 	"gopublic/internal/storage"
 	"gopublic/internal/telegram"
@@ -26,6 +37,25 @@ import (
 
 const shutdownTimeout = 30 * time.Second
 
+// filterReloadInterval is how often traffic filter modules are checked for changes.
+const filterReloadInterval = 5 * time.Second
+
+// Background maintenance job intervals.
+const (
+	sessionReapInterval       = time.Minute
+	usageAggregationInterval  = time.Minute
+	domainRecycleInterval     = time.Hour
+	bandwidthRolloverInterval = 6 * time.Hour
+	idempotencyPruneInterval  = time.Hour
+	certRenewalInterval       = 12 * time.Hour
+	tunnelHeartbeatInterval   = 30 * time.Second
+	offlineAlertInterval      = time.Minute
+)
+
+// domainRecycleGrace is how long domains of a deleted account are kept
+// before they become available to other users.
+const domainRecycleGrace = 30 * 24 * time.Hour
+
 func main() {
 	// Load .env file if it exists
 	_ = godotenv.Load()
@@ -57,7 +87,13 @@ func main() {
 	}
 
 	// 2. Initialize Database
-	if err := storage.InitDB(cfg.DBPath); err != nil {
+	pool := storage.PoolConfig{
+		MaxOpenConns:    cfg.DBMaxOpenConns,
+		MaxIdleConns:    cfg.DBMaxIdleConns,
+		ConnMaxLifetime: cfg.DBConnMaxLifetime,
+		ReplicaPath:     cfg.DBReplicaPath,
+	}
+	if err := storage.InitDBWithConfig(cfg.DBPath, pool); err != nil {
 		log.Fatalf("Failed to initialize database: %v", err)
 	}
 
@@ -75,11 +111,10 @@ func main() {
 		log.Fatalf("Failed to initialize dashboard: %v", err)
 	}
 
-	// 5. Start Telegram Admin Bot
-	var adminBot *telegram.Bot
-	if cfg.HasAdminNotifications() {
-		adminBot = telegram.NewBot(cfg.TelegramBotToken, cfg.AdminTelegramID)
-		adminBot.Start()
+	// 5. Create Telegram Bot (started once the control plane exists)
+	var bot *telegram.Bot
+	if cfg.HasAdminNotifications() || (cfg.TelegramBotCommands && cfg.TelegramBotToken != "") {
+		bot = telegram.NewBot(cfg.TelegramBotToken, cfg.AdminTelegramID)
 	}
 
 	// 6. Configure TLS & Autocert (if applicable)
@@ -108,11 +143,43 @@ func main() {
 		tlsConfig = autocertManager.TLSConfig()
 	}
 
+	// Identifies this instance in job locks and cross-instance events
+	hostname, _ := os.Hostname()
+	instanceID := fmt.Sprintf("%s-%d", hostname, os.Getpid())
+
+	// Event bus: Redis when running multiple instances, in-process otherwise
+	var bus pubsub.Bus = pubsub.NewLocalBus()
+	if cfg.RedisURL != "" {
+		redisBus, err := pubsub.NewRedisBus(cfg.RedisURL, cfg.RedisChannel, instanceID)
+		if err != nil {
+			log.Fatalf("Failed to configure Redis event bus: %v", err)
+		}
+		bus = redisBus
+		log.Printf("Cross-instance events enabled on Redis channel %s", cfg.RedisChannel)
+	}
+	defer bus.Close()
+
 	// 7. Start Control Plane
 	controlPlane := server.NewServerWithConfig(cfg, registry, tlsConfig)
+	controlPlane.SetEvents(bus)
 
 	// Connect dashboard to user sessions for connection status display
 	dashHandler.SetUserSessions(controlPlane.UserSessions)
+	dashHandler.SetFleet(controlPlane.UserSessions)
+	dashHandler.SetEvents(bus)
+
+	if bot != nil {
+		if cfg.TelegramBotCommands {
+			bot.EnableUserCommands(telegram.UserCommands{
+				Sessions:       controlPlane.UserSessions,
+				Revoker:        controlPlane,
+				RootDomain:     cfg.Domain,
+				BandwidthLimit: cfg.DailyBandwidthLimit,
+				PlanBandwidth:  cfg.PlanBandwidthLimits,
+			})
+		}
+		bot.Start()
+	}
 
 	serverErrors := make(chan error, 4)
 
@@ -122,17 +189,151 @@ func main() {
 		}
 	}()
 
+	// Shared metrics registry for the ingress, jobs and /metrics endpoint
+	appMetrics := metrics.New()
+	latency := metrics.NewDomainLatency(appMetrics, metrics.DefaultMaxLatencyDomains)
+	dashHandler.SetLatency(latency)
+	captures := capture.NewLog(0, 0)
+	dashHandler.SetCaptures(captures)
+
 	// 8. Start Public Ingress
 	ing := ingress.NewIngressWithConfig(cfg, registry, dashHandler)
+	ing.SetEvents(bus)
+	ing.Latency = latency
+	ing.Captures = captures
+	if err := ing.LoadSuspendedDomains(); err != nil {
+		log.Printf("Failed to load suspended domains: %v", err)
+	}
+	if err := ing.LoadDomainSchedules(); err != nil {
+		log.Printf("Failed to load domain schedules: %v", err)
+	}
+	if err := ing.LoadHubDomains(); err != nil {
+		log.Printf("Failed to load hub domains: %v", err)
+	}
+	if err := ing.LoadCaptureDomains(); err != nil {
+		log.Printf("Failed to load capture domains: %v", err)
+	}
+
+	// Load traffic filters (if configured)
+	filterCtx, filterCancel := context.WithCancel(context.Background())
+	defer filterCancel()
+	if len(cfg.TrafficFilters) > 0 {
+		chain, err := filter.LoadChain(cfg.TrafficFilters)
+		if err != nil {
+			log.Fatalf("Failed to load traffic filters: %v", err)
+		}
+		defer chain.Close()
+		filter.WatchChain(filterCtx, chain, filterReloadInterval)
+		ing.Filters = chain
+		log.Printf("Loaded %d traffic filter(s)", len(chain))
+	}
+
+	// GeoIP enrichment (if configured)
+	if cfg.GeoIPDBPath != "" || cfg.GeoIPASNDBPath != "" {
+		resolver, err := geoip.NewResolver(cfg.GeoIPDBPath, cfg.GeoIPASNDBPath)
+		if err != nil {
+			log.Fatalf("Failed to open GeoIP database: %v", err)
+		}
+		ing.GeoIP = resolver
+	}
+
+	// Content scanning hook (if configured)
+	if cfg.ScanURL != "" {
+		scanner, err := scan.New(cfg.ScanURL)
+		if err != nil {
+			log.Fatalf("Failed to configure content scanner: %v", err)
+		}
+		ing.Scan = &scan.Hook{
+			Scanner:   scanner,
+			Threshold: cfg.ScanThreshold,
+			MaxBody:   cfg.ScanMaxBody,
+			Timeout:   cfg.ScanTimeout,
+			FailOpen:  cfg.ScanFailOpen,
+		}
+		log.Printf("Scanning request bodies over %d bytes", cfg.ScanThreshold)
+	}
+
+	// User notifications over Telegram and email
+	var smtpConfig *notify.SMTPConfig
+	if cfg.SMTPAddr != "" {
+		smtpConfig = &notify.SMTPConfig{
+			Addr:     cfg.SMTPAddr,
+			Username: cfg.SMTPUsername,
+			Password: cfg.SMTPPassword,
+			From:     cfg.SMTPFrom,
+		}
+	}
+	notifier := notify.New(cfg.TelegramBotToken, smtpConfig)
+
+	// Quota usage alerts (if enabled)
+	if cfg.QuotaAlerts {
+		ing.Notifier = notifier
+		if notifier == nil {
+			log.Println("QUOTA_ALERTS is set but neither TELEGRAM_BOT_TOKEN nor SMTP_ADDR is configured")
+		}
+	}
+
+	// Sign-in links by email (if enabled)
+	if cfg.EmailLogin {
+		if smtpConfig != nil {
+			dashHandler.EmailLogin = notifier
+		} else {
+			log.Println("EMAIL_LOGIN is set but SMTP_ADDR is not configured")
+		}
+	}
+
+	// Offline tunnel alerts (if enabled). Without a notifier only webhooks are offered.
+	var alertSender *alerts.Sender
+	if cfg.OfflineAlerts {
+		alertSender = alerts.NewSender(notifier)
+		dashHandler.Alerts = alertSender
+	}
+
+	// Start background maintenance jobs. Locks live in the database so that
+	// instances sharing it never run the same job concurrently.
+	runner := jobs.NewRunner(jobs.NewStoreLocker(instanceID), appMetrics)
+	runner.Add(jobs.SessionReaping(sessionReapInterval, registry, controlPlane.UserSessions))
+	runner.Add(jobs.UsageAggregation(usageAggregationInterval, runner.Metrics(), controlPlane.UserSessions))
+	runner.Add(jobs.DomainRecycling(domainRecycleInterval, domainRecycleGrace))
+	runner.Add(jobs.BandwidthRollover(bandwidthRolloverInterval, time.Duration(cfg.BandwidthRetentionDays)*24*time.Hour))
+	runner.Add(jobs.IdempotencyKeyPruning(idempotencyPruneInterval, dashboard.IdempotencyKeyTTL))
+	if alertSender != nil || cfg.StatusPages {
+		runner.Add(jobs.TunnelHeartbeat(tunnelHeartbeatInterval, instanceID, registry))
+	}
+	if alertSender != nil {
+		runner.Add(jobs.OfflineAlerts(offlineAlertInterval, alertSender))
+	}
+	if autocertManager != nil {
+		runner.Add(jobs.CertRenewal(certRenewalInterval, autocertManager, cfg.Domain, "app."+cfg.Domain))
+	}
+	runner.Start(context.Background())
 
 	var httpServers []*http.Server
 
+	if cfg.MetricsAddr != "" {
+		metricsRouter := gin.New()
+		metricsRouter.GET("/metrics", appMetrics.Handler())
+		metricsServer := &http.Server{
+			Addr:    cfg.MetricsAddr,
+			Handler: metricsRouter,
+		}
+		httpServers = append(httpServers, metricsServer)
+
+		go func() {
+			log.Printf("Metrics endpoint listening on %s", cfg.MetricsAddr)
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				serverErrors <- err
+			}
+		}()
+	}
+
 	if cfg.IsSecure() {
 		// HTTPS Mode (Production)
 		httpsServer := &http.Server{
-			Addr:      ":443",
-			Handler:   ing.Handler(),
-			TLSConfig: tlsConfig,
+			Addr:           ":443",
+			Handler:        ing.Handler(),
+			TLSConfig:      tlsConfig,
+			MaxHeaderBytes: ingress.MaxHeaderBytes,
 		}
 		httpServers = append(httpServers, httpsServer)
 
@@ -167,8 +368,9 @@ func main() {
 		}
 
 		httpServer := &http.Server{
-			Addr:    ingressPort,
-			Handler: ing.Handler(),
+			Addr:           ingressPort,
+			Handler:        ing.Handler(),
+			MaxHeaderBytes: ingress.MaxHeaderBytes,
 		}
 		httpServers = append(httpServers, httpServer)
 
@@ -204,9 +406,11 @@ func main() {
 		log.Printf("Control plane shutdown error: %v", err)
 	}
 
+	runner.Stop()
+
 	// Stop Telegram bot
-	if adminBot != nil {
-		adminBot.Stop()
+	if bot != nil {
+		bot.Stop()
 	}
 
 	log.Println("Server shutdown complete")
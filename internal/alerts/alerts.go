@@ -0,0 +1,182 @@
+// Package alerts turns gopublic into a lightweight uptime monitor.
+//
+// Users register rules that fire once one of their tunnels has been
+// offline for longer than a threshold. Every server instance records the
+// tunnels it serves (jobs.TunnelHeartbeat); a single instance evaluates
+// the rules (jobs.OfflineAlerts) and delivers alerts and recovery notices
+// over Telegram, email or a webhook.
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+
+	"gopublic/internal/models"
+	"gopublic/internal/notify"
+)
+
+// Limits for user-defined rules.
+const (
+	MaxRulesPerUser   = 10
+	MinOfflineMinutes = 1
+	MaxOfflineMinutes = 24 * 60
+)
+
+// Webhook event types.
+const (
+	EventOffline = "tunnel.offline"
+	EventOnline  = "tunnel.online"
+)
+
+// webhookTimeout bounds a single webhook delivery.
+const webhookTimeout = 10 * time.Second
+
+// Errors returned when validating rules.
+var (
+	ErrInvalidWebhook = errors.New("webhook URL must be a public http(s) URL")
+	ErrPrivateAddress = errors.New("webhook address is not public")
+)
+
+// Payload is the JSON body posted to webhooks.
+type Payload struct {
+	Event        string    `json:"event"` // EventOffline or EventOnline
+	Domain       string    `json:"domain"`
+	RuleID       uint      `json:"rule_id"`
+	LastOnlineAt time.Time `json:"last_online_at"`
+	Timestamp    time.Time `json:"timestamp"`
+}
+
+// Sender delivers alerts. A nil Notifier leaves only webhooks available.
+type Sender struct {
+	Notifier *notify.Notifier
+	Client   *http.Client // Defaults to a client that refuses private addresses
+}
+
+// NewSender creates a sender that delivers Telegram and email alerts
+// through notifier.
+func NewSender(notifier *notify.Notifier) *Sender {
+	return &Sender{Notifier: notifier}
+}
+
+// Channels returns the channels the user can pick for a rule.
+func (s *Sender) Channels(user *models.User) []string {
+	var channels []string
+	for _, channel := range []string{models.AlertChannelTelegram, models.AlertChannelEmail} {
+		if s.Notifier.CanReach(user, channel) {
+			channels = append(channels, channel)
+		}
+	}
+	return append(channels, models.AlertChannelWebhook)
+}
+
+// Deliver sends an offline alert, or a recovery notice if online is true.
+// The rule's User must be loaded.
+func (s *Sender) Deliver(ctx context.Context, rule *models.AlertRule, online bool) error {
+	if rule.Channel == models.AlertChannelWebhook {
+		return s.postWebhook(ctx, rule, online)
+	}
+	return s.Notifier.SendVia(ctx, &rule.User, rule.Channel, message(rule, online))
+}
+
+// message formats a Telegram or email alert.
+func message(rule *models.AlertRule, online bool) notify.Message {
+	if online {
+		return notify.Message{
+			Subject: fmt.Sprintf("Туннель %s снова доступен", rule.Domain),
+			Text:    fmt.Sprintf("✅ Туннель %s снова доступен.", rule.Domain),
+		}
+	}
+	return notify.Message{
+		Subject: fmt.Sprintf("Туннель %s недоступен", rule.Domain),
+		Text: fmt.Sprintf("🔴 Туннель %s недоступен более %d мин.\n\nПроверьте, что клиент gopublic запущен.",
+			rule.Domain, rule.OfflineMinutes),
+	}
+}
+
+func (s *Sender) postWebhook(ctx context.Context, rule *models.AlertRule, online bool) error {
+	payload := Payload{Event: EventOffline, Domain: rule.Domain, RuleID: rule.ID, Timestamp: time.Now().UTC()}
+	if online {
+		payload.Event = EventOnline
+	}
+	if rule.LastOnlineAt != nil {
+		payload.LastOnlineAt = rule.LastOnlineAt.UTC()
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, webhookTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, rule.WebhookURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "gopublic-alerts")
+
+	client := s.Client
+	if client == nil {
+		client = publicClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http(s) URL
+// with a host. Addresses are checked again when connecting.
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" || u.User != nil {
+		return ErrInvalidWebhook
+	}
+	if ip := net.ParseIP(u.Hostname()); ip != nil && !isPublic(ip) {
+		return ErrPrivateAddress
+	}
+	return nil
+}
+
+// publicClient refuses to connect to loopback, private and link-local
+// addresses so that webhooks cannot reach the server's internal network.
+var publicClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		Proxy: nil,
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip == nil || !isPublic(ip) {
+					return ErrPrivateAddress
+				}
+				return nil
+			},
+		}).DialContext,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified() || ip.IsMulticast())
+}
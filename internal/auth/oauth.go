@@ -0,0 +1,192 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// OAuthProvider signs users in with a GitHub or Google account through the
+// OAuth 2.0 authorization code flow. Unlike OIDCProvider it has fixed
+// endpoints and reads the profile from the provider's user API.
+type OAuthProvider struct {
+	Name          string // models.Provider*
+	Label         string // Shown to users, e.g. "GitHub"
+	ClientID      string
+	ClientSecret  string
+	AuthEndpoint  string
+	TokenEndpoint string
+	UserEndpoint  string
+	Scope         string
+
+	Client *http.Client // nil = http.DefaultClient
+
+	identity func(ctx context.Context, p *OAuthProvider, accessToken string) (*OAuthIdentity, error)
+}
+
+// OAuthIdentity is a user the provider vouched for. Email is set only if
+// the provider verified it.
+type OAuthIdentity struct {
+	ID         string // Stable user ID at the provider
+	Email      string
+	GivenName  string
+	FamilyName string
+	Username   string
+	Picture    string
+}
+
+// NewGitHubProvider creates a provider for "Sign in with GitHub".
+func NewGitHubProvider(clientID, clientSecret string) *OAuthProvider {
+	return &OAuthProvider{
+		Name:          "github",
+		Label:         "GitHub",
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		AuthEndpoint:  "https://github.com/login/oauth/authorize",
+		TokenEndpoint: "https://github.com/login/oauth/access_token",
+		UserEndpoint:  "https://api.github.com/user",
+		Scope:         "read:user user:email",
+		identity:      githubIdentity,
+	}
+}
+
+// NewGoogleProvider creates a provider for "Sign in with Google".
+func NewGoogleProvider(clientID, clientSecret string) *OAuthProvider {
+	return &OAuthProvider{
+		Name:          "google",
+		Label:         "Google",
+		ClientID:      clientID,
+		ClientSecret:  clientSecret,
+		AuthEndpoint:  "https://accounts.google.com/o/oauth2/v2/auth",
+		TokenEndpoint: "https://oauth2.googleapis.com/token",
+		UserEndpoint:  "https://openidconnect.googleapis.com/v1/userinfo",
+		Scope:         "openid email profile",
+		identity:      googleIdentity,
+	}
+}
+
+// AuthURL returns the provider's login page URL. state must be random and
+// checked again when the provider redirects back.
+func (p *OAuthProvider) AuthURL(redirectURI, state string) string {
+	params := url.Values{}
+	params.Set("response_type", "code")
+	params.Set("client_id", p.ClientID)
+	params.Set("redirect_uri", redirectURI)
+	params.Set("scope", p.Scope)
+	params.Set("state", state)
+	return p.AuthEndpoint + "?" + params.Encode()
+}
+
+// Exchange redeems the authorization code and returns the user's identity.
+func (p *OAuthProvider) Exchange(ctx context.Context, code, redirectURI string) (*OAuthIdentity, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURI)
+	form.Set("client_id", p.ClientID)
+	form.Set("client_secret", p.ClientSecret)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json") // GitHub answers form-encoded otherwise
+	var tokens struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"` // GitHub reports errors with status 200
+	}
+	if err := doJSON(p.Client, req, &tokens); err != nil {
+		return nil, fmt.Errorf("%s: token exchange: %w", p.Name, err)
+	}
+	if tokens.AccessToken == "" {
+		return nil, fmt.Errorf("%s: token exchange: no access token (%s)", p.Name, tokens.Error)
+	}
+
+	identity, err := p.identity(ctx, p, tokens.AccessToken)
+	if err != nil {
+		return nil, fmt.Errorf("%s: user info: %w", p.Name, err)
+	}
+	if identity.ID == "" {
+		return nil, fmt.Errorf("%s: user info: no user ID", p.Name)
+	}
+	return identity, nil
+}
+
+// getJSON fetches a user API resource with the access token.
+func (p *OAuthProvider) getJSON(ctx context.Context, endpoint, accessToken string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Accept", "application/json")
+	return doJSON(p.Client, req, v)
+}
+
+// githubIdentity reads the GitHub profile and the primary email from the
+// email list, which tells whether it is verified.
+func githubIdentity(ctx context.Context, p *OAuthProvider, accessToken string) (*OAuthIdentity, error) {
+	var user struct {
+		ID        int64  `json:"id"`
+		Login     string `json:"login"`
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}
+	if err := p.getJSON(ctx, p.UserEndpoint, accessToken, &user); err != nil {
+		return nil, err
+	}
+	if user.ID == 0 {
+		return nil, errors.New("no user ID")
+	}
+
+	// The public email of the profile may be unverified
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := p.getJSON(ctx, p.UserEndpoint+"/emails", accessToken, &emails); err != nil {
+		return nil, err
+	}
+	identity := &OAuthIdentity{
+		ID:       strconv.FormatInt(user.ID, 10),
+		Username: user.Login,
+		Picture:  user.AvatarURL,
+	}
+	identity.GivenName, identity.FamilyName, _ = strings.Cut(user.Name, " ")
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			identity.Email = e.Email
+		}
+	}
+	return identity, nil
+}
+
+// googleIdentity reads the OpenID Connect userinfo of a Google account.
+func googleIdentity(ctx context.Context, p *OAuthProvider, accessToken string) (*OAuthIdentity, error) {
+	var info struct {
+		Sub           string `json:"sub"`
+		Email         string `json:"email"`
+		EmailVerified bool   `json:"email_verified"`
+		GivenName     string `json:"given_name"`
+		FamilyName    string `json:"family_name"`
+		Picture       string `json:"picture"`
+	}
+	if err := p.getJSON(ctx, p.UserEndpoint, accessToken, &info); err != nil {
+		return nil, err
+	}
+	identity := &OAuthIdentity{
+		ID:         info.Sub,
+		GivenName:  info.GivenName,
+		FamilyName: info.FamilyName,
+		Picture:    info.Picture,
+	}
+	if info.EmailVerified {
+		identity.Email = info.Email
+	}
+	return identity, nil
+}
@@ -0,0 +1,117 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+// fakeOAuth serves a token endpoint and the user API answers of paths.
+func fakeOAuth(t *testing.T, p *OAuthProvider, answers map[string]any) {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if r.FormValue("client_id") != "client" || r.FormValue("client_secret") != "secret" || r.FormValue("code") != "code" {
+			// Like GitHub: errors with status 200
+			json.NewEncoder(w).Encode(map[string]string{"error": "bad_verification_code"})
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "token_type": "bearer"})
+	})
+	for path, answer := range answers {
+		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "Bearer at" {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			json.NewEncoder(w).Encode(answer)
+		})
+	}
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	p.ClientID, p.ClientSecret = "client", "secret"
+	p.AuthEndpoint = srv.URL + "/authorize"
+	p.TokenEndpoint = srv.URL + "/token"
+	p.UserEndpoint = srv.URL + "/user"
+}
+
+func TestOAuthProvider_AuthURL(t *testing.T) {
+	p := NewGitHubProvider("client", "secret")
+	u, err := url.Parse(p.AuthURL("https://app.example.com/auth/github/callback", "s-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if u.Host != "github.com" || q.Get("client_id") != "client" || q.Get("state") != "s-1" || q.Get("redirect_uri") != "https://app.example.com/auth/github/callback" {
+		t.Errorf("AuthURL() = %s", u)
+	}
+}
+
+func TestOAuthProvider_GitHub(t *testing.T) {
+	p := NewGitHubProvider("", "")
+	fakeOAuth(t, p, map[string]any{
+		"/user": map[string]any{"id": 583231, "login": "octocat", "name": "Mona Lisa Octocat", "avatar_url": "https://avatars.example/u/583231", "email": "public@example.com"},
+		"/user/emails": []map[string]any{
+			{"email": "old@example.com", "primary": false, "verified": true},
+			{"email": "octocat@example.com", "primary": true, "verified": true},
+		},
+	})
+
+	id, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/github/callback")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	want := OAuthIdentity{ID: "583231", Email: "octocat@example.com", GivenName: "Mona", FamilyName: "Lisa Octocat", Username: "octocat", Picture: "https://avatars.example/u/583231"}
+	if *id != want {
+		t.Errorf("identity = %+v, want %+v", *id, want)
+	}
+
+	if _, err := p.Exchange(context.Background(), "wrong", "https://app.example.com/auth/github/callback"); err == nil {
+		t.Error("Exchange() with a bad code succeeded")
+	}
+}
+
+func TestOAuthProvider_GitHubUnverifiedEmail(t *testing.T) {
+	p := NewGitHubProvider("", "")
+	fakeOAuth(t, p, map[string]any{
+		"/user":        map[string]any{"id": 1, "login": "octocat"},
+		"/user/emails": []map[string]any{{"email": "octocat@example.com", "primary": true, "verified": false}},
+	})
+
+	id, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/github/callback")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if id.Email != "" {
+		t.Errorf("Email = %q, want none for an unverified address", id.Email)
+	}
+}
+
+func TestOAuthProvider_Google(t *testing.T) {
+	p := NewGoogleProvider("", "")
+	fakeOAuth(t, p, map[string]any{
+		"/user": map[string]any{"sub": "1098", "email": "ivan@example.com", "email_verified": true, "given_name": "Ivan", "family_name": "Petrov", "picture": "https://lh3.example/photo"},
+	})
+
+	id, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/google/callback")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	want := OAuthIdentity{ID: "1098", Email: "ivan@example.com", GivenName: "Ivan", FamilyName: "Petrov", Picture: "https://lh3.example/photo"}
+	if *id != want {
+		t.Errorf("identity = %+v, want %+v", *id, want)
+	}
+}
+
+func TestOAuthProvider_NoUserID(t *testing.T) {
+	p := NewGoogleProvider("", "")
+	fakeOAuth(t, p, map[string]any{"/user": map[string]any{"email": "ivan@example.com"}})
+
+	if _, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/google/callback"); err == nil {
+		t.Error("Exchange() without a user ID succeeded")
+	}
+}
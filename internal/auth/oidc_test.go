@@ -0,0 +1,128 @@
+package auth
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeIdP serves discovery, token and userinfo endpoints. The token
+// endpoint returns an ID token with claims.
+func fakeIdP(t *testing.T, claims map[string]any, userinfo map[string]any) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 srv.URL,
+			"authorization_endpoint": srv.URL + "/authorize",
+			"token_endpoint":         srv.URL + "/token",
+			"userinfo_endpoint":      srv.URL + "/userinfo",
+		})
+	})
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		if id, secret, _ := r.BasicAuth(); id != "client" || secret != "secret" || r.FormValue("code") != "code" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		payload, _ := json.Marshal(claims)
+		idToken := "eyJhbGciOiJSUzI1NiJ9." + base64.RawURLEncoding.EncodeToString(payload) + ".sig"
+		json.NewEncoder(w).Encode(map[string]string{"access_token": "at", "id_token": idToken})
+	})
+	mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer at" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(userinfo)
+	})
+	srv = httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestOIDCProvider_Exchange(t *testing.T) {
+	claims := map[string]any{
+		"sub":            "u-1",
+		"aud":            []string{"client", "other"},
+		"exp":            time.Now().Add(time.Hour).Unix(),
+		"nonce":          "n-1",
+		"email":          "ivan@corp.example",
+		"email_verified": true,
+	}
+	userinfo := map[string]any{"sub": "u-1", "email": "other@corp.example", "groups": []string{"eng", "gopublic-admins"}}
+	srv := fakeIdP(t, claims, userinfo)
+	claims["iss"] = srv.URL
+
+	p := NewOIDCProvider(srv.URL+"/", "client", "secret", "")
+	authURL, err := p.AuthURL(context.Background(), "https://app.example.com/auth/sso/callback", "s-1", "n-1")
+	if err != nil {
+		t.Fatalf("AuthURL() error = %v", err)
+	}
+	u, _ := url.Parse(authURL)
+	if u.Path != "/authorize" || u.Query().Get("nonce") != "n-1" || !strings.Contains(u.Query().Get("scope"), "openid") {
+		t.Errorf("AuthURL() = %s", authURL)
+	}
+
+	id, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/sso/callback", "n-1")
+	if err != nil {
+		t.Fatalf("Exchange() error = %v", err)
+	}
+	if id.Subject != "u-1" || id.Email != "ivan@corp.example" {
+		t.Errorf("identity = %+v, want the ID token to win over userinfo", id)
+	}
+	if len(id.Groups) != 2 || id.Groups[1] != "gopublic-admins" {
+		t.Errorf("groups = %v, want them from userinfo", id.Groups)
+	}
+
+	if _, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/sso/callback", "n-2"); !errors.Is(err, ErrOIDCRejected) {
+		t.Errorf("Exchange() with another nonce error = %v, want ErrOIDCRejected", err)
+	}
+
+	// Unverified emails are dropped, also when userinfo verifies another one
+	delete(claims, "email_verified")
+	userinfo["email_verified"] = true
+	if id, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/sso/callback", "n-1"); err != nil || id.Email != "" {
+		t.Errorf("Exchange() with an unverified email = %+v, %v; want no email", id, err)
+	}
+	userinfo["email"] = "ivan@corp.example"
+	if id, err := p.Exchange(context.Background(), "code", "https://app.example.com/auth/sso/callback", "n-1"); err != nil || id.Email != "ivan@corp.example" {
+		t.Errorf("Exchange() with the email verified by userinfo = %+v, %v", id, err)
+	}
+}
+
+func TestOIDCProvider_CheckClaims(t *testing.T) {
+	p := NewOIDCProvider("https://idp.example", "client", "secret", "groups")
+	valid := func() map[string]any {
+		return map[string]any{"iss": "https://idp.example", "sub": "u-1", "aud": "client", "exp": float64(time.Now().Add(time.Hour).Unix()), "nonce": "n"}
+	}
+	if err := p.checkClaims(valid(), "https://idp.example", "n"); err != nil {
+		t.Fatalf("checkClaims() error = %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		claim string
+		value any
+	}{
+		{"issuer", "iss", "https://evil.example"},
+		{"audience", "aud", "someone-else"},
+		{"expired", "exp", float64(time.Now().Add(-time.Minute).Unix())},
+		{"nonce", "nonce", "replayed"},
+		{"subject", "sub", ""},
+	}
+	for _, tt := range tests {
+		claims := valid()
+		claims[tt.claim] = tt.value
+		if err := p.checkClaims(claims, "https://idp.example", "n"); !errors.Is(err, ErrOIDCRejected) {
+			t.Errorf("%s: checkClaims() error = %v, want ErrOIDCRejected", tt.name, err)
+		}
+	}
+}
@@ -23,3 +23,23 @@ func HashToken(token string) string {
 	hash := sha256.Sum256([]byte(token))
 	return hex.EncodeToString(hash[:])
 }
+
+// inviteAlphabet omits characters that are easy to confuse when typed (0/O, 1/I/L).
+const inviteAlphabet = "ABCDEFGHJKMNPQRSTUVWXYZ23456789"
+
+// GenerateInviteCode creates a short human-friendly invite code,
+// e.g. "K7QX-M2PA".
+func GenerateInviteCode() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	code := make([]byte, 0, 9)
+	for i, v := range b {
+		if i == 4 {
+			code = append(code, '-')
+		}
+		code = append(code, inviteAlphabet[int(v)%len(inviteAlphabet)])
+	}
+	return string(code), nil
+}
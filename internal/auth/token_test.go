@@ -3,6 +3,7 @@ package auth
 import (
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestGenerateSecureToken(t *testing.T) {
@@ -60,3 +61,85 @@ func TestHashToken(t *testing.T) {
 		t.Error("Different tokens produced same hash")
 	}
 }
+
+func TestGenerateInviteCode(t *testing.T) {
+	code, err := GenerateInviteCode()
+	if err != nil {
+		t.Fatalf("GenerateInviteCode() error = %v", err)
+	}
+	if len(code) != 9 || code[4] != '-' {
+		t.Errorf("unexpected invite code format %q", code)
+	}
+	if strings.ContainsAny(code, "0O1IL") {
+		t.Errorf("invite code %q contains ambiguous characters", code)
+	}
+}
+
+func TestTOTPCode_RFC6238(t *testing.T) {
+	// Test vectors from RFC 6238 Appendix B (SHA-1), truncated to 6 digits
+	secret := totpEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		got, err := TOTPCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatalf("TOTPCode() error = %v", err)
+		}
+		if got != tt.want {
+			t.Errorf("TOTPCode(%d) = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	now := time.Now()
+	code, _ := TOTPCode(secret, now)
+	if !ValidateTOTP(secret, code, now) {
+		t.Error("current code rejected")
+	}
+	if !ValidateTOTP(secret, code, now.Add(30*time.Second)) {
+		t.Error("code from the previous step rejected")
+	}
+	if ValidateTOTP(secret, code, now.Add(5*time.Minute)) {
+		t.Error("stale code accepted")
+	}
+	if ValidateTOTP(secret, "12345", now) || ValidateTOTP("not base32!", code, now) {
+		t.Error("malformed input accepted")
+	}
+}
+
+func TestMatchTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret() error = %v", err)
+	}
+	now := time.Unix(1700000010, 0)
+	code, _ := TOTPCode(secret, now)
+	if step, ok := MatchTOTP(secret, code, now); !ok || step != 1700000010/30 {
+		t.Errorf("MatchTOTP() = %d, %v; want %d, true", step, ok, 1700000010/30)
+	}
+	if step, ok := MatchTOTP(secret, code, now.Add(30*time.Second)); !ok || step != 1700000010/30 {
+		t.Errorf("MatchTOTP() a step later = %d, %v; want the code's own step", step, ok)
+	}
+}
+
+func TestGenerateConfirmCode(t *testing.T) {
+	code, err := GenerateConfirmCode()
+	if err != nil {
+		t.Fatalf("GenerateConfirmCode() error = %v", err)
+	}
+	if len(code) != 6 || strings.Trim(code, "0123456789") != "" {
+		t.Errorf("GenerateConfirmCode() = %q, want 6 digits", code)
+	}
+}
@@ -0,0 +1,81 @@
+// Package billing connects paid subscriptions to service plans.
+//
+// Subscriptions are sold through Stripe Checkout. Stripe webhooks report
+// subscription changes, which are mapped to models.User.Plan; the plan in
+// turn selects the quotas enforced by the ingress.
+package billing
+
+import (
+	"sort"
+
+	"gopublic/internal/models"
+)
+
+// PlanLimits maps a plan to its daily bandwidth limit in bytes (0 = unlimited).
+// Plans without an entry use the server default.
+type PlanLimits map[string]int64
+
+// Bandwidth returns the daily bandwidth limit for the plan.
+func (l PlanLimits) Bandwidth(plan string, fallback int64) int64 {
+	if limit, ok := l[plan]; ok {
+		return limit
+	}
+	return fallback
+}
+
+// PlanDomains maps a plan to how many subdomains its users may reserve
+// (0 = none). Plans without an entry use the server default.
+type PlanDomains map[string]int
+
+// Reserved returns the reserved domain limit for the plan.
+func (l PlanDomains) Reserved(plan string, fallback int) int {
+	if limit, ok := l[plan]; ok {
+		return limit
+	}
+	return fallback
+}
+
+// Subscription states that keep the paid plan. Any other state, e.g.
+// "canceled" or "unpaid", downgrades the user to the free plan.
+var activeStatuses = map[string]bool{
+	"active":   true,
+	"trialing": true,
+	"past_due": true, // Stripe is still retrying the payment
+}
+
+// Plans maps a plan name to the Stripe price ID that sells it.
+type Plans map[string]string
+
+// Names returns the purchasable plans in alphabetical order.
+func (p Plans) Names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ForPrice returns the plan sold by a Stripe price, or "" if none is.
+func (p Plans) ForPrice(priceID string) string {
+	for name, price := range p {
+		if price == priceID {
+			return name
+		}
+	}
+	return ""
+}
+
+// SubscriptionPlan returns the plan a subscription entitles its customer
+// to. Inactive subscriptions and unknown prices map to the free plan.
+func (p Plans) SubscriptionPlan(sub *Subscription) string {
+	if !activeStatuses[sub.Status] {
+		return models.PlanFree
+	}
+	for _, item := range sub.Items.Data {
+		if plan := p.ForPrice(item.Price.ID); plan != "" {
+			return plan
+		}
+	}
+	return models.PlanFree
+}
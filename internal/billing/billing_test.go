@@ -0,0 +1,158 @@
+package billing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+func TestPlanLimits_Bandwidth(t *testing.T) {
+	limits := PlanLimits{"pro": 10 << 30, "team": 0}
+	tests := []struct {
+		plan string
+		want int64
+	}{
+		{"pro", 10 << 30},
+		{"team", 0},
+		{"free", 100 << 20},
+	}
+	for _, tt := range tests {
+		if got := limits.Bandwidth(tt.plan, 100<<20); got != tt.want {
+			t.Errorf("Bandwidth(%q) = %d, want %d", tt.plan, got, tt.want)
+		}
+	}
+	if got := PlanLimits(nil).Bandwidth("pro", 5); got != 5 {
+		t.Errorf("nil limits Bandwidth() = %d, want fallback", got)
+	}
+}
+
+func TestPlans_SubscriptionPlan(t *testing.T) {
+	plans := Plans{"pro": "price_pro", "team": "price_team"}
+	sub := func(status, price string) *Subscription {
+		s := &Subscription{Status: status}
+		s.Items.Data = make([]struct {
+			Price struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		}, 1)
+		s.Items.Data[0].Price.ID = price
+		return s
+	}
+
+	tests := []struct {
+		name string
+		sub  *Subscription
+		want string
+	}{
+		{"active", sub("active", "price_team"), "team"},
+		{"trialing", sub("trialing", "price_pro"), "pro"},
+		{"past due keeps plan", sub("past_due", "price_pro"), "pro"},
+		{"canceled downgrades", sub("canceled", "price_pro"), models.PlanFree},
+		{"unpaid downgrades", sub("unpaid", "price_pro"), models.PlanFree},
+		{"unknown price", sub("active", "price_other"), models.PlanFree},
+	}
+	for _, tt := range tests {
+		if got := plans.SubscriptionPlan(tt.sub); got != tt.want {
+			t.Errorf("%s: SubscriptionPlan() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func sign(payload []byte, secret string, ts time.Time) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.%s", ts.Unix(), payload)
+	return fmt.Sprintf("t=%d,v1=%s", ts.Unix(), hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"customer.subscription.deleted"}`)
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		header string
+		secret string
+		ok     bool
+	}{
+		{"valid", sign(payload, "whsec_test", now), "whsec_test", true},
+		{"wrong secret", sign(payload, "whsec_other", now), "whsec_test", false},
+		{"expired", sign(payload, "whsec_test", now.Add(-time.Hour)), "whsec_test", false},
+		{"no secret configured", sign(payload, "", now), "", false},
+		{"malformed", "garbage", "whsec_test", false},
+	}
+	for _, tt := range tests {
+		err := verifySignature(payload, tt.header, tt.secret, now)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: verifySignature() error = %v, want ok=%v", tt.name, err, tt.ok)
+		}
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	s := NewStripe("sk_test", "whsec_test", Plans{"pro": "price_pro"})
+	payload := []byte(`{"id":"evt_1","type":"checkout.session.completed","data":{"object":{"id":"cs_1"}}}`)
+
+	event, err := s.ParseEvent(payload, sign(payload, "whsec_test", time.Now()))
+	if err != nil {
+		t.Fatalf("ParseEvent() error = %v", err)
+	}
+	if event.Type != EventCheckoutCompleted || len(event.Data.Object) == 0 {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	if _, err := s.ParseEvent(payload, "t=1,v1=00"); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("ParseEvent() with bad signature error = %v, want ErrInvalidSignature", err)
+	}
+}
+
+func TestCreateCheckoutSession(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, _, _ := r.BasicAuth(); user != "sk_test" {
+			t.Errorf("API key = %q, want sk_test", user)
+		}
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		if got := r.PostForm.Get("line_items[0][price]"); got != "price_pro" {
+			t.Errorf("price = %q, want price_pro", got)
+		}
+		if got := r.PostForm.Get("client_reference_id"); got != "42" {
+			t.Errorf("client_reference_id = %q, want 42", got)
+		}
+		fmt.Fprint(w, `{"id":"cs_1","url":"https://checkout.stripe.com/c/cs_1"}`)
+	}))
+	defer srv.Close()
+
+	s := NewStripe("sk_test", "whsec_test", Plans{"pro": "price_pro"})
+	s.BaseURL = srv.URL
+
+	got, err := s.CreateCheckoutSession(context.Background(), CheckoutRequest{Plan: "pro", UserID: 42})
+	if err != nil {
+		t.Fatalf("CreateCheckoutSession() error = %v", err)
+	}
+	if got != "https://checkout.stripe.com/c/cs_1" {
+		t.Errorf("CreateCheckoutSession() = %q", got)
+	}
+
+	if _, err := s.CreateCheckoutSession(context.Background(), CheckoutRequest{Plan: "team"}); !errors.Is(err, ErrUnknownPlan) {
+		t.Errorf("unknown plan error = %v, want ErrUnknownPlan", err)
+	}
+}
+
+func TestNewStripe_Disabled(t *testing.T) {
+	if NewStripe("", "whsec", Plans{"pro": "price_pro"}) != nil {
+		t.Error("expected nil without a secret key")
+	}
+	if NewStripe("sk_test", "whsec", nil) != nil {
+		t.Error("expected nil without plans")
+	}
+}
@@ -0,0 +1,218 @@
+package billing
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Stripe webhook event types handled by the server.
+const (
+	EventCheckoutCompleted   = "checkout.session.completed"
+	EventSubscriptionUpdated = "customer.subscription.updated"
+	EventSubscriptionDeleted = "customer.subscription.deleted"
+)
+
+// Errors returned by the Stripe client.
+var (
+	ErrUnknownPlan      = errors.New("plan is not for sale")
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+)
+
+// signatureTolerance is the maximum age of a webhook, to limit replays.
+const signatureTolerance = 5 * time.Minute
+
+// Stripe talks to the Stripe API without the official SDK.
+// A nil Stripe means billing is disabled.
+type Stripe struct {
+	SecretKey     string
+	WebhookSecret string
+	Plans         Plans
+	BaseURL       string       // Defaults to https://api.stripe.com
+	Client        *http.Client // Defaults to http.DefaultClient
+}
+
+// NewStripe creates a Stripe client. It returns nil if secretKey is empty
+// or no plan is for sale.
+func NewStripe(secretKey, webhookSecret string, plans Plans) *Stripe {
+	if secretKey == "" || len(plans) == 0 {
+		return nil
+	}
+	return &Stripe{SecretKey: secretKey, WebhookSecret: webhookSecret, Plans: plans}
+}
+
+// CheckoutRequest describes a subscription purchase.
+type CheckoutRequest struct {
+	Plan       string
+	UserID     uint
+	CustomerID string // Existing Stripe customer, if any
+	Email      string // Prefills checkout for new customers
+	SuccessURL string
+	CancelURL  string
+}
+
+// CheckoutSession is the subset of a Stripe Checkout Session used here.
+type CheckoutSession struct {
+	ID                string            `json:"id"`
+	URL               string            `json:"url"`
+	ClientReferenceID string            `json:"client_reference_id"`
+	Customer          string            `json:"customer"`
+	Subscription      string            `json:"subscription"`
+	Metadata          map[string]string `json:"metadata"`
+}
+
+// Subscription is the subset of a Stripe Subscription used here.
+type Subscription struct {
+	ID       string `json:"id"`
+	Customer string `json:"customer"`
+	Status   string `json:"status"`
+	Items    struct {
+		Data []struct {
+			Price struct {
+				ID string `json:"id"`
+			} `json:"price"`
+		} `json:"data"`
+	} `json:"items"`
+}
+
+// Event is a Stripe webhook event. Data.Object holds the affected object.
+type Event struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	Data struct {
+		Object json.RawMessage `json:"object"`
+	} `json:"data"`
+}
+
+// CreateCheckoutSession starts a subscription checkout and returns the
+// Stripe-hosted payment page URL.
+func (s *Stripe) CreateCheckoutSession(ctx context.Context, req CheckoutRequest) (string, error) {
+	price, ok := s.Plans[req.Plan]
+	if !ok {
+		return "", ErrUnknownPlan
+	}
+
+	form := url.Values{}
+	form.Set("mode", "subscription")
+	form.Set("line_items[0][price]", price)
+	form.Set("line_items[0][quantity]", "1")
+	form.Set("client_reference_id", strconv.FormatUint(uint64(req.UserID), 10))
+	form.Set("metadata[plan]", req.Plan)
+	form.Set("success_url", req.SuccessURL)
+	form.Set("cancel_url", req.CancelURL)
+	if req.CustomerID != "" {
+		form.Set("customer", req.CustomerID)
+	} else if req.Email != "" {
+		form.Set("customer_email", req.Email)
+	}
+
+	var session CheckoutSession
+	if err := s.post(ctx, "/v1/checkout/sessions", form, &session); err != nil {
+		return "", err
+	}
+	return session.URL, nil
+}
+
+// post sends a form-encoded API request and decodes the JSON reply.
+func (s *Stripe) post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	baseURL := s.BaseURL
+	if baseURL == "" {
+		baseURL = "https://api.stripe.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, baseURL+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.SecretKey, "")
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("stripe request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return fmt.Errorf("stripe response read failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		var apiErr struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		_ = json.Unmarshal(body, &apiErr)
+		return fmt.Errorf("stripe returned %d: %s", resp.StatusCode, apiErr.Error.Message)
+	}
+	return json.Unmarshal(body, out)
+}
+
+// ParseEvent verifies the Stripe-Signature header of a webhook and decodes
+// the event.
+func (s *Stripe) ParseEvent(payload []byte, signature string) (*Event, error) {
+	if err := verifySignature(payload, signature, s.WebhookSecret, time.Now()); err != nil {
+		return nil, err
+	}
+	var event Event
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return nil, fmt.Errorf("invalid webhook payload: %w", err)
+	}
+	return &event, nil
+}
+
+// verifySignature checks a "t=<unix>,v1=<hex>" header, where v1 is the
+// HMAC-SHA256 of "<t>.<payload>" keyed with the endpoint secret.
+func verifySignature(payload []byte, header, secret string, now time.Time) error {
+	if secret == "" {
+		return ErrInvalidSignature
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if age := now.Sub(time.Unix(ts, 0)); age > signatureTolerance || age < -signatureTolerance {
+		return ErrInvalidSignature
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+
+	for _, sig := range signatures {
+		got, err := hex.DecodeString(sig)
+		if err == nil && hmac.Equal(got, expected) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
@@ -0,0 +1,52 @@
+// Package bufpool shares the buffers of the proxy copy paths, so that
+// every tunneled connection does not allocate a fresh 32KB buffer per
+// direction.
+package bufpool
+
+import (
+	"io"
+	"net"
+	"sync"
+)
+
+// Size is the size of pooled buffers, the same as io.Copy allocates.
+const Size = 32 * 1024
+
+var pool = sync.Pool{
+	New: func() any {
+		b := make([]byte, Size)
+		return &b
+	},
+}
+
+// Get takes a buffer of Size bytes from the pool.
+func Get() *[]byte {
+	return pool.Get().(*[]byte)
+}
+
+// Put returns a buffer taken with Get.
+func Put(b *[]byte) {
+	pool.Put(b)
+}
+
+// Copy copies from src to dst until EOF like io.Copy. Between two TCP
+// connections it leaves the copy to the kernel (splice on Linux);
+// otherwise it copies through a pooled buffer. A multiplexed tunnel
+// stream always needs the buffered copy.
+func Copy(dst io.Writer, src io.Reader) (int64, error) {
+	if _, ok := src.(*net.TCPConn); ok {
+		if _, ok := dst.(*net.TCPConn); ok {
+			return io.Copy(dst, src)
+		}
+	}
+
+	buf := Get()
+	defer Put(buf)
+	// Hide ReadFrom and WriteTo: their generic fallbacks allocate a
+	// buffer of their own
+	return io.CopyBuffer(writerOnly{dst}, readerOnly{src}, *buf)
+}
+
+type writerOnly struct{ io.Writer }
+
+type readerOnly struct{ io.Reader }
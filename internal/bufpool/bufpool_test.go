@@ -0,0 +1,93 @@
+package bufpool
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestCopy(t *testing.T) {
+	src := strings.Repeat("gopublic", 10000) // Several buffers worth
+	var dst bytes.Buffer
+	n, err := Copy(&dst, strings.NewReader(src))
+	if err != nil || n != int64(len(src)) {
+		t.Fatalf("Copy() = %d, %v, want %d", n, err, len(src))
+	}
+	if dst.String() != src {
+		t.Error("Copy() corrupted the data")
+	}
+}
+
+func TestCopy_TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// An echo server that copies socket to socket
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		Copy(conn, conn)
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	io.WriteString(conn, "ping")
+	conn.(*net.TCPConn).CloseWrite()
+	got, err := io.ReadAll(conn)
+	if err != nil || string(got) != "ping" {
+		t.Errorf("echo = %q, %v", got, err)
+	}
+}
+
+// chunkReader yields size bytes in reads of up to 16KB, like a socket.
+type chunkReader struct {
+	left int
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if r.left == 0 {
+		return 0, io.EOF
+	}
+	n := min(len(p), r.left, 16*1024)
+	r.left -= n
+	return n, nil
+}
+
+// sink discards writes and, like *net.TCPConn, implements io.ReaderFrom
+// with a generic fallback that allocates a buffer per call.
+type sink struct{}
+
+func (sink) Write(p []byte) (int, error) { return len(p), nil }
+
+func (s sink) ReadFrom(r io.Reader) (int64, error) {
+	return io.Copy(struct{ io.Writer }{s}, r)
+}
+
+const benchSize = 1 << 20
+
+func BenchmarkCopy(b *testing.B) {
+	b.SetBytes(benchSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Copy(sink{}, &chunkReader{left: benchSize})
+	}
+}
+
+func BenchmarkCopy_IOCopy(b *testing.B) {
+	b.SetBytes(benchSize)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		io.Copy(sink{}, &chunkReader{left: benchSize})
+	}
+}
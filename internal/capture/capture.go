@@ -0,0 +1,204 @@
+// Package capture records recent exchanges of domains whose owners run
+// their client without the local inspector, e.g. with --inspect=false or
+// raw mode on a remote box. The ingress records them on opt-in and the
+// dashboard shows them. Records are redacted, capped and kept in memory
+// on the instance that served them.
+package capture
+
+import (
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of NewLog.
+const (
+	DefaultPerDomain = 50       // Exchanges kept per domain
+	DefaultMaxBody   = 4 * 1024 // Bytes kept of each body
+)
+
+// Redacted replaces the values of sensitive headers, query parameters
+// and body fields.
+const Redacted = "[redacted]"
+
+// sensitiveName matches header, parameter and field names whose values
+// are credentials.
+var sensitiveName = regexp.MustCompile(`(?i)(auth|cookie|token|secret|passw|api[-_]?key|signature|session)`)
+
+// Sensitive fields in JSON and form bodies. Only string values are
+// redacted; the capture keeps the rest of the body readable.
+var (
+	jsonField = regexp.MustCompile(`(?i)("[^"]*(?:auth|token|secret|passw|api[-_]?key|signature|session)[^"]*"\s*:\s*)"(?:[^"\\]|\\.)*"`)
+	formField = regexp.MustCompile(`(?i)((?:^|&)[^=&]*(?:auth|token|secret|passw|api[-_]?key|signature|session)[^=&]*=)[^&]*`)
+)
+
+// Exchange is one recorded request and its response.
+type Exchange struct {
+	Time            time.Time           `json:"time"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"` // Path and query
+	Status          int                 `json:"status"`
+	DurationMS      int64               `json:"duration_ms"`
+	RequestHeaders  map[string][]string `json:"request_headers"`
+	RequestBody     string              `json:"request_body,omitempty"`
+	RequestSize     int64               `json:"request_size"`
+	ResponseHeaders map[string][]string `json:"response_headers"`
+	ResponseBody    string              `json:"response_body,omitempty"`
+	ResponseSize    int64               `json:"response_size"`
+}
+
+// Log keeps the last exchanges of the domains capture is enabled for.
+type Log struct {
+	perDomain int
+	maxBody   int
+
+	mu      sync.RWMutex
+	enabled map[string]bool
+	records map[string][]Exchange // Oldest first
+}
+
+// NewLog creates a log keeping perDomain exchanges per domain with bodies
+// of at most maxBody bytes. Zero values use the defaults.
+func NewLog(perDomain, maxBody int) *Log {
+	if perDomain <= 0 {
+		perDomain = DefaultPerDomain
+	}
+	if maxBody <= 0 {
+		maxBody = DefaultMaxBody
+	}
+	return &Log{
+		perDomain: perDomain,
+		maxBody:   maxBody,
+		enabled:   make(map[string]bool),
+		records:   make(map[string][]Exchange),
+	}
+}
+
+// MaxBody returns the number of bytes kept of each body.
+func (l *Log) MaxBody() int {
+	return l.maxBody
+}
+
+// SetDomains enables capture for exactly the given hosts. Records of the
+// other hosts are dropped.
+func (l *Log) SetDomains(hosts []string) {
+	enabled := make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		enabled[host] = true
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.enabled = enabled
+	for host := range l.records {
+		if !enabled[host] {
+			delete(l.records, host)
+		}
+	}
+}
+
+// Disable stops capture for host and drops its records.
+func (l *Log) Disable(host string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.enabled, host)
+	delete(l.records, host)
+}
+
+// Enabled reports whether exchanges of host are recorded.
+func (l *Log) Enabled(host string) bool {
+	if l == nil {
+		return false
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	return l.enabled[host]
+}
+
+// Record redacts an exchange and adds it to the records of host, dropping
+// the oldest beyond the limit. Bodies are truncated to MaxBody.
+func (l *Log) Record(host string, ex Exchange) {
+	ex.URL = redactURL(ex.URL)
+	ex.RequestHeaders = redactHeaders(ex.RequestHeaders)
+	ex.ResponseHeaders = redactHeaders(ex.ResponseHeaders)
+	ex.RequestBody = redactBody(truncate(ex.RequestBody, l.maxBody), ex.RequestHeaders)
+	ex.ResponseBody = redactBody(truncate(ex.ResponseBody, l.maxBody), ex.ResponseHeaders)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !l.enabled[host] {
+		return // Disabled while the request was in flight
+	}
+	records := append(l.records[host], ex)
+	if len(records) > l.perDomain {
+		records = append([]Exchange(nil), records[len(records)-l.perDomain:]...)
+	}
+	l.records[host] = records
+}
+
+// Recent returns the records of host, newest first.
+func (l *Log) Recent(host string) []Exchange {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+	records := l.records[host]
+	recent := make([]Exchange, len(records))
+	for i, ex := range records {
+		recent[len(records)-1-i] = ex
+	}
+	return recent
+}
+
+func truncate(body string, max int) string {
+	if len(body) <= max {
+		return body
+	}
+	return body[:max] + "\n... (truncated)"
+}
+
+// redactHeaders returns a copy of headers with credential values replaced.
+func redactHeaders(headers map[string][]string) map[string][]string {
+	redacted := make(map[string][]string, len(headers))
+	for name, values := range headers {
+		if sensitiveName.MatchString(name) {
+			values = []string{Redacted}
+		} else {
+			values = append([]string(nil), values...)
+		}
+		redacted[http.CanonicalHeaderKey(name)] = values
+	}
+	return redacted
+}
+
+// redactURL replaces the values of sensitive query parameters.
+func redactURL(uri string) string {
+	u, err := url.ParseRequestURI(uri)
+	if err != nil || u.RawQuery == "" {
+		return uri
+	}
+	query := u.Query()
+	changed := false
+	for name := range query {
+		if sensitiveName.MatchString(name) {
+			query[name] = []string{Redacted}
+			changed = true
+		}
+	}
+	if changed {
+		u.RawQuery = query.Encode()
+	}
+	return u.RequestURI()
+}
+
+// redactBody replaces sensitive string fields of JSON bodies and values
+// of form bodies.
+func redactBody(body string, headers map[string][]string) string {
+	if body == "" {
+		return body
+	}
+	if strings.HasPrefix(http.Header(headers).Get("Content-Type"), "application/x-www-form-urlencoded") {
+		return formField.ReplaceAllString(body, "${1}"+url.QueryEscape(Redacted))
+	}
+	return jsonField.ReplaceAllString(body, `$1"`+Redacted+`"`)
+}
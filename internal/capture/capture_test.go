@@ -0,0 +1,81 @@
+package capture
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestLog_Record(t *testing.T) {
+	l := NewLog(3, 8)
+	l.Record("a.example.com", Exchange{URL: "/ignored"})
+	if got := l.Recent("a.example.com"); len(got) != 0 {
+		t.Fatalf("recorded %d exchanges of a disabled domain", len(got))
+	}
+
+	l.SetDomains([]string{"a.example.com", "b.example.com"})
+	for i := 0; i < 5; i++ {
+		l.Record("a.example.com", Exchange{URL: fmt.Sprintf("/%d", i), ResponseBody: "0123456789"})
+	}
+	got := l.Recent("a.example.com")
+	if len(got) != 3 || got[0].URL != "/4" || got[2].URL != "/2" {
+		t.Fatalf("Recent() = %v, want the last 3 newest first", got)
+	}
+	if !strings.HasPrefix(got[0].ResponseBody, "01234567\n") {
+		t.Errorf("body = %q, want truncated to 8 bytes", got[0].ResponseBody)
+	}
+
+	l.Record("b.example.com", Exchange{URL: "/b"})
+	l.SetDomains([]string{"b.example.com"})
+	if got := l.Recent("a.example.com"); len(got) != 0 || l.Enabled("a.example.com") {
+		t.Errorf("records of a domain turned off = %v", got)
+	}
+	l.Disable("b.example.com")
+	if got := l.Recent("b.example.com"); len(got) != 0 || l.Enabled("b.example.com") {
+		t.Errorf("records of a disabled domain = %v", got)
+	}
+
+	var nilLog *Log
+	if nilLog.Enabled("a.example.com") {
+		t.Error("nil log is enabled")
+	}
+}
+
+func TestRedact(t *testing.T) {
+	headers := redactHeaders(map[string][]string{
+		"Authorization": {"Bearer abc"},
+		"Set-Cookie":    {"sid=1"},
+		"X-Api-Key":     {"k"},
+		"Accept":        {"text/html"},
+	})
+	for _, name := range []string{"Authorization", "Set-Cookie", "X-Api-Key"} {
+		if headers[name][0] != Redacted {
+			t.Errorf("%s = %q, want redacted", name, headers[name])
+		}
+	}
+	if headers["Accept"][0] != "text/html" {
+		t.Errorf("Accept = %q, want kept", headers["Accept"])
+	}
+
+	if got := redactURL("/cb?code=1&access_token=xyz"); got != "/cb?access_token=%5Bredacted%5D&code=1" {
+		t.Errorf("redactURL() = %q", got)
+	}
+	if got := redactURL("/plain?page=2"); got != "/plain?page=2" {
+		t.Errorf("redactURL() = %q, want unchanged", got)
+	}
+
+	json := map[string][]string{"Content-Type": {"application/json"}}
+	body := `{"user":"bob","password":"p\"w","refreshToken":"r","count":3}`
+	want := `{"user":"bob","password":"[redacted]","refreshToken":"[redacted]","count":3}`
+	if got := redactBody(body, json); got != want {
+		t.Errorf("redactBody(json) = %q, want %q", got, want)
+	}
+	// Only form bodies are read as key=value pairs
+	if got := redactBody(`{"note":"session=1"}`, json); got != `{"note":"session=1"}` {
+		t.Errorf("redactBody(json with =) = %q, want unchanged", got)
+	}
+	form := map[string][]string{"Content-Type": {"application/x-www-form-urlencoded"}}
+	if got := redactBody("user=bob&client_secret=s&x=1", form); got != "user=bob&client_secret=%5Bredacted%5D&x=1" {
+		t.Errorf("redactBody(form) = %q", got)
+	}
+}
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/inspector"
+
+	"github.com/spf13/cobra"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench <exchange-id>",
+	Short: "Replay a captured request repeatedly and summarize the latencies",
+	Long: `Replay a request captured by the running client's inspector N times at
+a fixed rate against the local app, then print the status codes and a
+latency summary. Exchange IDs are shown in the inspector UI.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runBenchCmd,
+}
+
+func init() {
+	benchCmd.Flags().IntP("requests", "n", 100, "Number of requests to send (at most 10000)")
+	benchCmd.Flags().Float64("rate", 10, "Requests per second; 0 sends them as fast as possible")
+	benchCmd.Flags().String("target", "", "Base URL to send the requests to instead of the tunnel's local port")
+	benchCmd.Flags().String("inspector", "http://localhost:4040", "Inspector of the running client")
+}
+
+func runBenchCmd(cmd *cobra.Command, args []string) {
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil || id < 0 {
+		fmt.Fprintln(os.Stderr, i18n.T("bench.invalid_id", args[0]))
+		os.Exit(1)
+	}
+	count, _ := cmd.Flags().GetInt("requests")
+	rate, _ := cmd.Flags().GetFloat64("rate")
+	target, _ := cmd.Flags().GetString("target")
+	inspectorURL, _ := cmd.Flags().GetString("inspector")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	fmt.Println(i18n.T("bench.title", id, count, rate))
+	result, err := runBench(ctx, inspectorURL, id, inspector.BenchOptions{
+		ReplayOverrides: inspector.ReplayOverrides{Target: target},
+		Count:           count,
+		Rate:            rate,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("bench.error", err))
+		os.Exit(1)
+	}
+	printBenchResult(result)
+	if result.Errors > 0 {
+		os.Exit(1)
+	}
+}
+
+// runBench asks the inspector at inspectorURL to bench exchange id.
+func runBench(ctx context.Context, inspectorURL string, id int64, opts inspector.BenchOptions) (*inspector.BenchResult, error) {
+	var result inspector.BenchResult
+	if err := callInspector(ctx, inspectorURL, http.MethodPost, fmt.Sprintf("/api/bench/%d", id), opts, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// callInspector sends in as JSON, if not nil, to the API of the running
+// client's inspector and decodes a successful answer into out.
+func callInspector(ctx context.Context, inspectorURL, method, path string, in, out any) error {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(inspectorURL, "/")+path, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return err
+		}
+		return fmt.Errorf("%s: %w (is the client running?)", inspectorURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("%s", strings.TrimSpace(string(msg)))
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// printBenchResult prints the summary of a bench.
+func printBenchResult(result *inspector.BenchResult) {
+	codes := make([]int, 0, len(result.Statuses))
+	for code := range result.Statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	statuses := make([]string, 0, len(codes)+1)
+	for _, code := range codes {
+		statuses = append(statuses, fmt.Sprintf("%d x%d", code, result.Statuses[code]))
+	}
+	if result.Errors > 0 {
+		statuses = append(statuses, i18n.T("bench.errors", result.Errors))
+	}
+
+	l := result.Latency
+	fmt.Println()
+	fmt.Println(i18n.T("bench.target", result.Target))
+	fmt.Println(i18n.T("bench.requests", result.Requests, result.DurationMS/1000, result.Rate))
+	fmt.Println(i18n.T("bench.statuses", strings.Join(statuses, ", ")))
+	fmt.Println(i18n.T("bench.latency", l.Min, l.Mean, l.Max))
+	fmt.Println(i18n.T("bench.percentiles", l.P50, l.P90, l.P99))
+	if result.LastError != "" {
+		fmt.Println(i18n.T("bench.last_error", result.LastError))
+	}
+}
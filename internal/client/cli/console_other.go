@@ -0,0 +1,6 @@
+//go:build !windows
+
+package cli
+
+// enableConsoleColors is a no-op: Unix terminals handle ANSI escapes natively.
+func enableConsoleColors() {}
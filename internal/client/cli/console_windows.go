@@ -0,0 +1,23 @@
+//go:build windows
+
+package cli
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// enableConsoleColors turns on ANSI escape processing for stdout and stderr
+// so that the TUI renders colors and cursor movement in cmd.exe and
+// PowerShell. Consoles that do not support it are left unchanged.
+func enableConsoleColors() {
+	for _, f := range []*os.File{os.Stdout, os.Stderr} {
+		h := windows.Handle(f.Fd())
+		var mode uint32
+		if err := windows.GetConsoleMode(h, &mode); err != nil {
+			continue // Not a console (redirected output)
+		}
+		windows.SetConsoleMode(h, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	}
+}
@@ -0,0 +1,251 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/control"
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/stats"
+	"gopublic/internal/client/tunnel"
+	"gopublic/internal/version"
+	"gopublic/pkg/protocol"
+
+	"github.com/spf13/cobra"
+)
+
+var addCmd = &cobra.Command{
+	Use:     "add <name> <port>",
+	Short:   "Add a tunnel to the running client, until it stops",
+	Example: "  gopublic add api 8080 --subdomain misty-river",
+	Args:    cobra.ExactArgs(2),
+	Run: func(cmd *cobra.Command, args []string) {
+		subdomain, _ := cmd.Flags().GetString("subdomain")
+		basicAuth, _ := cmd.Flags().GetString("basic-auth")
+		spec := control.TunnelSpec{Name: args[0], Addr: args[1], Subdomain: subdomain, BasicAuth: basicAuth}
+		runControl(func(c *control.Client) error { return c.AddTunnel(context.Background(), spec) }, i18n.T("control.added", spec.Name))
+	},
+}
+
+var removeCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Stop a tunnel of the running client",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runControl(func(c *control.Client) error { return c.RemoveTunnel(context.Background(), args[0]) }, i18n.T("control.removed", args[0]))
+	},
+}
+
+var reconnectCmd = &cobra.Command{
+	Use:   "reconnect",
+	Short: "Make the running client reconnect to the server",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		runControl(func(c *control.Client) error { return c.Reconnect(context.Background()) }, i18n.T("control.reconnecting"))
+	},
+}
+
+func init() {
+	addCmd.Flags().String("subdomain", "", "Domain of your account to bind, e.g. misty-river")
+	addCmd.Flags().String("basic-auth", "", "Require visitors to log in with user:pass before reaching the service")
+	addCmd.MarkFlagRequired("subdomain")
+}
+
+// controlClient returns a client of the running client's control API.
+func controlClient() *control.Client {
+	path, err := config.ControlSocketPath()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.error", err))
+		os.Exit(1)
+	}
+	return control.NewClient(path)
+}
+
+// runControl sends a change to the running client and prints done.
+func runControl(change func(*control.Client) error, done string) {
+	err := change(controlClient())
+	if errors.Is(err, control.ErrNotRunning) {
+		fmt.Fprintln(os.Stderr, i18n.T("status.not_running"))
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.error", err))
+		os.Exit(1)
+	}
+	fmt.Println(done)
+}
+
+// startControl serves the control API until ctx is done. The tunnels run
+// without it if the socket cannot be created.
+func startControl(ctx context.Context, bus *events.Bus, st *stats.Stats) *tunnelControl {
+	tc := &tunnelControl{bus: bus}
+	ctrl := control.NewServer(ServerAddr, version.Version, st)
+	ctrl.SetController(tc)
+	ctrl.Track(bus)
+	path, err := config.ControlSocketPath()
+	if err == nil {
+		err = ctrl.Start(ctx, path)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.control_failed", err))
+	}
+	return tc
+}
+
+// reconnecter is the running tunnel or tunnel manager.
+type reconnecter interface {
+	Reconnect() error
+}
+
+// tunnelControl changes the tunnels of "gopublic start" for the control
+// API. Tunnels added or removed are kept on top of gopublic.yaml across
+// reloads, until the client stops; they are not written to the file.
+type tunnelControl struct {
+	bus *events.Bus
+
+	mu       sync.Mutex
+	running  reconnecter
+	editable bool                      // Tunnels come from gopublic.yaml
+	current  map[string]*config.Tunnel // Tunnels of the last load, with the changes
+	added    map[string]*config.Tunnel
+	removed  map[string]bool
+}
+
+// setRunning sets the tunnel or manager Reconnect is sent to.
+func (c *tunnelControl) setRunning(r reconnecter) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.running = r
+}
+
+// edit lets AddTunnel and RemoveTunnel change the tunnels of projectCfg,
+// and returns load with the changes applied to what it returns.
+func (c *tunnelControl) edit(projectCfg *config.ProjectConfig, load func() (*config.ProjectConfig, error)) func() (*config.ProjectConfig, error) {
+	c.mu.Lock()
+	c.editable = true
+	c.current = projectCfg.Tunnels
+	c.mu.Unlock()
+
+	return func() (*config.ProjectConfig, error) {
+		projectCfg, err := load()
+		if err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		defer c.mu.Unlock()
+		tunnels := make(map[string]*config.Tunnel, len(projectCfg.Tunnels)+len(c.added))
+		for name, t := range projectCfg.Tunnels {
+			if !c.removed[name] {
+				tunnels[name] = t
+			}
+		}
+		for name, t := range c.added {
+			tunnels[name] = t
+		}
+		projectCfg.Tunnels = tunnels
+		c.current = tunnels
+		return projectCfg, nil
+	}
+}
+
+// AddTunnel implements control.Controller.
+func (c *tunnelControl) AddTunnel(spec control.TunnelSpec) error {
+	if spec.Name == "" || strings.ContainsAny(spec.Name, "/ \t") {
+		return fmt.Errorf("invalid tunnel name %q", spec.Name)
+	}
+	if err := validateAddr(spec.Addr); err != nil {
+		return err
+	}
+	if err := protocol.ValidateSubdomain(spec.Subdomain); err != nil {
+		return err
+	}
+	if spec.BasicAuth != "" {
+		if err := protocol.ValidateBasicAuth(spec.BasicAuth); err != nil {
+			return err
+		}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.editable {
+		return control.ErrFixedTunnels
+	}
+	if _, ok := c.current[spec.Name]; ok {
+		return control.ErrTunnelExists
+	}
+	for name, t := range c.current {
+		if t.Subdomain == spec.Subdomain && t.MountPath == "" {
+			return fmt.Errorf("%w: %s is served by '%s'", control.ErrTunnelExists, spec.Subdomain, name)
+		}
+	}
+	if c.added == nil {
+		c.added = make(map[string]*config.Tunnel)
+	}
+	c.added[spec.Name] = &config.Tunnel{Proto: protocol.ProtoHTTP, Addr: spec.Addr, Subdomain: spec.Subdomain, BasicAuth: spec.BasicAuth}
+	delete(c.removed, spec.Name)
+	c.reload()
+	return nil
+}
+
+// RemoveTunnel implements control.Controller.
+func (c *tunnelControl) RemoveTunnel(name string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !c.editable {
+		return control.ErrFixedTunnels
+	}
+	if _, ok := c.current[name]; !ok {
+		return control.ErrTunnelNotFound
+	}
+	if len(c.current) == 1 {
+		return errors.New("cannot remove the last tunnel, stop the client instead")
+	}
+	if c.removed == nil {
+		c.removed = make(map[string]bool)
+	}
+	delete(c.added, name)
+	c.removed[name] = true
+	c.reload()
+	return nil
+}
+
+// Reconnect implements control.Controller.
+func (c *tunnelControl) Reconnect() error {
+	c.mu.Lock()
+	running := c.running
+	c.mu.Unlock()
+	if running == nil {
+		return control.ErrNotConnected
+	}
+	if err := running.Reconnect(); errors.Is(err, tunnel.ErrNotConnected) {
+		return control.ErrNotConnected
+	} else if err != nil {
+		return err
+	}
+	return nil
+}
+
+// reload restarts the tunnels with the changes, the same way as a reload
+// sent from the dashboard, see runReloading.
+func (c *tunnelControl) reload() {
+	c.bus.Publish(events.Event{Type: events.EventRemoteCommand, Data: events.RemoteCommandData{Command: protocol.CommandReload}})
+}
+
+// validateAddr checks the local port or host:port of a tunnel.
+func validateAddr(addr string) error {
+	port := addr
+	if _, p, err := net.SplitHostPort(addr); err == nil {
+		port = p
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return fmt.Errorf("invalid address %q, use a port or host:port", addr)
+	}
+	return nil
+}
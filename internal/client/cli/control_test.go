@@ -0,0 +1,77 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/control"
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/tunnel"
+)
+
+type fakeReconnecter struct{ err error }
+
+func (f fakeReconnecter) Reconnect() error { return f.err }
+
+func TestTunnelControl_Edit(t *testing.T) {
+	bus := events.NewBus()
+	commands := bus.Subscribe()
+	tc := &tunnelControl{bus: bus}
+
+	fromFile := func() (*config.ProjectConfig, error) {
+		return &config.ProjectConfig{Tunnels: map[string]*config.Tunnel{
+			"web":   {Addr: "3000", Subdomain: "misty-river"},
+			"admin": {Addr: "3001", Subdomain: "quiet-lake"},
+		}}, nil
+	}
+	projectCfg, _ := fromFile()
+	load := tc.edit(projectCfg, fromFile)
+
+	if err := tc.AddTunnel(control.TunnelSpec{Name: "api", Addr: "8080", Subdomain: "misty-river"}); !errors.Is(err, control.ErrTunnelExists) {
+		t.Errorf("AddTunnel() on a served subdomain error = %v, want ErrTunnelExists", err)
+	}
+	if err := tc.AddTunnel(control.TunnelSpec{Name: "api", Addr: "http", Subdomain: "red-fox"}); err == nil {
+		t.Error("AddTunnel() with an invalid address succeeded")
+	}
+	if err := tc.AddTunnel(control.TunnelSpec{Name: "api", Addr: "8080", Subdomain: "red-fox"}); err != nil {
+		t.Fatalf("AddTunnel() error = %v", err)
+	}
+	if event := <-commands; !isReload(event) {
+		t.Errorf("event = %+v, want a reload", event)
+	}
+	if err := tc.RemoveTunnel("admin"); err != nil {
+		t.Fatalf("RemoveTunnel() error = %v", err)
+	}
+	if err := tc.RemoveTunnel("nope"); !errors.Is(err, control.ErrTunnelNotFound) {
+		t.Errorf("RemoveTunnel() of an unknown tunnel error = %v", err)
+	}
+
+	// A reload re-reads gopublic.yaml and keeps the changes
+	reloaded, err := load()
+	if err != nil {
+		t.Fatalf("load() error = %v", err)
+	}
+	if len(reloaded.Tunnels) != 2 || reloaded.Tunnels["web"] == nil || reloaded.Tunnels["api"].Addr != "8080" {
+		t.Errorf("tunnels after reload = %+v, want web and api", reloaded.Tunnels)
+	}
+}
+
+func TestTunnelControl_Fixed(t *testing.T) {
+	tc := &tunnelControl{bus: events.NewBus()}
+	if err := tc.AddTunnel(control.TunnelSpec{Name: "api", Addr: "8080", Subdomain: "red-fox"}); !errors.Is(err, control.ErrFixedTunnels) {
+		t.Errorf("AddTunnel() without gopublic.yaml error = %v, want ErrFixedTunnels", err)
+	}
+
+	if err := tc.Reconnect(); !errors.Is(err, control.ErrNotConnected) {
+		t.Errorf("Reconnect() before starting error = %v, want ErrNotConnected", err)
+	}
+	tc.setRunning(fakeReconnecter{err: tunnel.ErrNotConnected})
+	if err := tc.Reconnect(); !errors.Is(err, control.ErrNotConnected) {
+		t.Errorf("Reconnect() between sessions error = %v, want ErrNotConnected", err)
+	}
+	tc.setRunning(fakeReconnecter{})
+	if err := tc.Reconnect(); err != nil {
+		t.Errorf("Reconnect() error = %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// flagSource says where the value of a flag came from, in increasing
+// precedence.
+type flagSource int
+
+const (
+	sourceDefault       flagSource = iota // Built-in default of the flag
+	sourceUserConfig                      // defaults section of the user config
+	sourceProjectConfig                   // gopublic.yaml
+	sourceEnv                             // GOPUBLIC_<FLAG> environment variable
+	sourceCommandLine
+)
+
+// flagSources records the flags set by resolveFlags. Changed flags missing
+// from it were given on the command line.
+var flagSources = make(map[*pflag.Flag]flagSource)
+
+// flagEnv returns the environment variable that sets a flag, e.g.
+// GOPUBLIC_RECONNECT_MAX_DELAY for --reconnect-max-delay.
+func flagEnv(name string) string {
+	return "GOPUBLIC_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// resolveFlags sets the flags of cmd that were not given on the command
+// line from their environment variable, or else from defaults (the
+// defaults section of the user config). gopublic.yaml sits between the
+// two and is applied by the commands that read it, see flagFrom.
+func resolveFlags(cmd *cobra.Command, defaults map[string]string) error {
+	var err error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if err != nil || f.Changed || f.Name == "help" {
+			return
+		}
+		if value, ok := os.LookupEnv(flagEnv(f.Name)); ok {
+			err = setFlag(f, value, sourceEnv, flagEnv(f.Name))
+		} else if value, ok := defaults[f.Name]; ok {
+			err = setFlag(f, value, sourceUserConfig, "config.yaml")
+		}
+	})
+	return err
+}
+
+// setFlag sets f from a layer below the command line and records it.
+func setFlag(f *pflag.Flag, value string, source flagSource, origin string) error {
+	if err := f.Value.Set(value); err != nil {
+		return fmt.Errorf("invalid value %q for --%s from %s: %w", value, f.Name, origin, err)
+	}
+	f.Changed = true
+	flagSources[f] = source
+	return nil
+}
+
+// flagFrom reports whether flag name was set from a source between min
+// and max, inclusive. Commands reading gopublic.yaml apply the flags set
+// below sourceProjectConfig before it and the others after it.
+func flagFrom(flags *pflag.FlagSet, name string, min, max flagSource) bool {
+	f := flags.Lookup(name)
+	if f == nil || !f.Changed {
+		return false
+	}
+	from, ok := flagSources[f]
+	if !ok {
+		from = sourceCommandLine
+	}
+	return from >= min && from <= max
+}
+
+// checkDefaults rejects defaults that name no flag of any command of root,
+// which are most likely typos.
+func checkDefaults(root *cobra.Command, defaults map[string]string) error {
+	known := make(map[string]bool)
+	var collect func(cmd *cobra.Command)
+	collect = func(cmd *cobra.Command) {
+		cmd.Flags().VisitAll(func(f *pflag.Flag) { known[f.Name] = true })
+		for _, sub := range cmd.Commands() {
+			collect(sub)
+		}
+	}
+	collect(root)
+	for name := range defaults {
+		if !known[name] {
+			return fmt.Errorf("unknown flag %q in the defaults of config.yaml", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package cli
+
+import (
+	"testing"
+	"time"
+
+	"gopublic/internal/client/config"
+
+	"github.com/spf13/cobra"
+)
+
+func TestResolveFlags_Precedence(t *testing.T) {
+	cmd := &cobra.Command{}
+	addReconnectFlags(cmd)
+	addKeepaliveFlags(cmd)
+	if err := cmd.Flags().Parse([]string{"--reconnect-max-attempts=7"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	t.Setenv("GOPUBLIC_RECONNECT_JITTER", "0.3")
+	defaults := map[string]string{
+		"reconnect-max-attempts":  "1",     // Command line wins
+		"reconnect-jitter":        "0.9",   // Environment wins
+		"reconnect-initial-delay": "3s",    // gopublic.yaml wins
+		"reconnect-max-delay":     "30s",   // Only set here
+		"keepalive-interval":      "500ms", // Only set here
+	}
+	if err := resolveFlags(cmd, defaults); err != nil {
+		t.Fatalf("resolveFlags() error = %v", err)
+	}
+
+	rc, err := reconnectConfig(cmd, &config.Reconnect{InitialDelay: 2 * time.Second, Jitter: 0.5})
+	if err != nil {
+		t.Fatalf("reconnectConfig() error = %v", err)
+	}
+	if rc.MaxAttempts != 7 || rc.Jitter != 0.3 || rc.InitialDelay != 2*time.Second || rc.MaxDelay != 30*time.Second {
+		t.Errorf("reconnectConfig() = %+v", rc)
+	}
+	kc, err := keepaliveConfig(cmd, nil)
+	if err != nil {
+		t.Fatalf("keepaliveConfig() error = %v", err)
+	}
+	if kc.Interval != 500*time.Millisecond || kc.Timeout != 10*time.Second {
+		t.Errorf("keepaliveConfig() = %+v", kc)
+	}
+}
+
+func TestResolveFlags_InvalidValue(t *testing.T) {
+	cmd := &cobra.Command{}
+	addKeepaliveFlags(cmd)
+	if err := resolveFlags(cmd, map[string]string{"keepalive-timeout": "soon"}); err == nil {
+		t.Error("resolveFlags() accepted an invalid duration")
+	}
+}
+
+func TestCheckDefaults(t *testing.T) {
+	root := &cobra.Command{Use: "gopublic"}
+	sub := &cobra.Command{Use: "start"}
+	addKeepaliveFlags(sub)
+	root.AddCommand(sub)
+
+	if err := checkDefaults(root, map[string]string{"keepalive-timeout": "5s"}); err != nil {
+		t.Errorf("checkDefaults() error = %v", err)
+	}
+	if err := checkDefaults(root, map[string]string{"keepalive-timout": "5s"}); err == nil {
+		t.Error("checkDefaults() accepted an unknown flag")
+	}
+}
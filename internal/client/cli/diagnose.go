@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/diagnose"
+	"gopublic/internal/client/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var diagnoseCmd = &cobra.Command{
+	Use:   "diagnose [port]",
+	Short: "Check connectivity to the server and the local service",
+	Args:  cobra.MaximumNArgs(1),
+	Run:   runDiagnose,
+}
+
+func init() {
+	diagnoseCmd.Flags().Duration("timeout", diagnose.DefaultTimeout, "Timeout for each check")
+	addTLSFlags(diagnoseCmd)
+}
+
+func runDiagnose(cmd *cobra.Command, args []string) {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	opts := diagnose.Options{ServerAddr: ServerAddr, Timeout: timeout}
+	cfg, err := config.LoadConfig()
+	if err == nil {
+		opts.Token = cfg.Token
+	}
+	tlsCfg, err := tlsConfig(cmd, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_ca_cert", err))
+		os.Exit(1)
+	}
+	opts.TLS, _ = tlsCfg.ClientConfig()
+	if len(args) == 1 {
+		opts.LocalPort = args[0]
+	}
+
+	fmt.Println(i18n.T("diagnose.title", ServerAddr))
+	fmt.Println()
+
+	results := diagnose.Run(context.Background(), opts)
+	printDiagnostics(results)
+
+	fmt.Println()
+	if diagnose.Failed(results) {
+		fmt.Println(i18n.T("diagnose.failed"))
+		os.Exit(1)
+	}
+	fmt.Println(i18n.T("diagnose.passed"))
+}
+
+// printDiagnostics renders the check results as an aligned table.
+func printDiagnostics(results []diagnose.Result) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, r := range results {
+		duration := "-"
+		if d := r.Duration.Round(100 * time.Microsecond); d > 0 {
+			duration = d.String()
+		}
+		fmt.Fprintf(w, "[%s]\t%s\t%s\t%s\n",
+			i18n.T("diagnose.status."+string(r.Status)),
+			i18n.T("diagnose.check."+r.Check),
+			duration,
+			r.Detail)
+	}
+	w.Flush()
+}
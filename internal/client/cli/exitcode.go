@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"gopublic/internal/client/tunnel"
+	"gopublic/pkg/protocol"
+)
+
+// Exit codes of "gopublic start", so that scripts and CI jobs can tell why
+// the tunnel stopped. Setup errors (missing token, bad flags) exit with 1.
+const (
+	exitError            = 1
+	exitAuthFailed       = 3 // Token invalid, expired or device revoked
+	exitDomainTaken      = 4 // No requested domain could be bound
+	exitQuotaExceeded    = 5 // Daily bandwidth used up
+	exitNetwork          = 6 // Server unreachable
+	exitAlreadyConnected = 7 // Another session is active, see --force, or too many agents
+	exitTLSUntrusted     = 8 // Server certificate failed verification or pinning
+	exitCanceled         = 130
+)
+
+// exitStatus maps the error a tunnel stopped with to an exit code and a
+// stable reason, printed as "exit_reason=<reason>".
+func exitStatus(err error) (int, string) {
+	if errors.Is(err, context.Canceled) {
+		return exitCanceled, "canceled"
+	}
+	if errors.Is(err, tunnel.ErrRemoteDisconnect) {
+		// A deliberate stop: supervisors restarting on failure leave it down
+		return 0, "remote_disconnect"
+	}
+	if tunnel.IsAlreadyConnectedError(err) {
+		return exitAlreadyConnected, "already_connected"
+	}
+	switch tunnel.ServerErrorCode(err) {
+	case protocol.ErrorCodeInvalidToken:
+		return exitAuthFailed, "auth_failed"
+	case protocol.ErrorCodeTokenExpired:
+		return exitAuthFailed, "token_expired"
+	case protocol.ErrorCodeNoDomains:
+		return exitDomainTaken, "domain_taken"
+	case protocol.ErrorCodeQuotaExceeded:
+		return exitQuotaExceeded, "quota_exceeded"
+	case protocol.ErrorCodeTooManyAgents:
+		return exitAlreadyConnected, "too_many_agents"
+	}
+	if tunnel.IsUntrustedCert(err) {
+		return exitTLSUntrusted, "tls_untrusted"
+	}
+	var opErr *net.OpError
+	var dnsErr *net.DNSError
+	if errors.As(err, &opErr) || errors.As(err, &dnsErr) {
+		return exitNetwork, "network_unreachable"
+	}
+	return exitError, "error"
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/inspector"
+)
+
+// Eviction of the persistent inspector store (--persist-inspector).
+const (
+	persistentInspectorSize   = 1000
+	persistentInspectorMaxAge = 7 * 24 * time.Hour
+)
+
+// inspectorOptions keeps the inspector UI preferences and scenarios in
+// the data directory; without one they only last until the client exits.
+func inspectorOptions() []inspector.Option {
+	var opts []inspector.Option
+	if path, err := config.InspectorPrefsPath(); err == nil {
+		opts = append(opts, inspector.WithPreferencesPath(path))
+	}
+	if path, err := config.InspectorScenariosPath(); err == nil {
+		opts = append(opts, inspector.WithScenariosPath(path))
+	}
+	return opts
+}
+
+// newInspectorStore returns the store of the inspector: the database in
+// the state directory when persist is set, memory otherwise. Exits if the
+// database cannot be opened, e.g. in a build without cgo, rather than
+// silently dropping the history the user asked to keep. close releases
+// the store.
+func newInspectorStore(persist, lowMemory bool) (store inspector.Store, close func()) {
+	if !persist {
+		return inspector.NewInMemoryStore(inspectorSize(lowMemory)), func() {}
+	}
+	path, err := config.InspectorDBPath()
+	if err == nil {
+		var db *inspector.SQLiteStore
+		if db, err = inspector.OpenSQLiteStore(path, persistentInspectorSize, persistentInspectorMaxAge); err == nil {
+			return db, func() { db.Close() }
+		}
+	}
+	fmt.Fprintln(os.Stderr, i18n.T("cli.inspector_persist_failed", err))
+	os.Exit(1)
+	return nil, nil
+}
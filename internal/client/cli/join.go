@@ -0,0 +1,75 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/tunnel"
+	"gopublic/pkg/protocol"
+)
+
+var joinCmd = &cobra.Command{
+	Use:     "join <join-token>",
+	Short:   "Register this machine as an agent with a one-time token from the dashboard",
+	Long:    "Exchanges a join token created on the Devices page of the dashboard for a token of this machine's own and saves it with the agent name, so that 'gopublic start' connects as that agent.",
+	Example: "  gopublic join 3f9a... --agent ci-runner-1",
+	Args:    cobra.ExactArgs(1),
+	Run:     runJoin,
+}
+
+func init() {
+	joinCmd.Flags().String("agent", "", "Agent name of this machine, e.g. ci-runner-1 (required)")
+	joinCmd.Flags().Duration("timeout", 30*time.Second, "Give up if the server does not answer within this time")
+	addTLSFlags(joinCmd)
+}
+
+func runJoin(cmd *cobra.Command, args []string) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_load_error", err))
+		os.Exit(1)
+	}
+	agent, _ := cmd.Flags().GetString("agent")
+	if agent == "" {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.join_agent_required"))
+		os.Exit(1)
+	}
+	if err := protocol.ValidateAgent(agent); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_agent", err))
+		os.Exit(1)
+	}
+	tlsCfg, err := tlsConfig(cmd, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_ca_cert", err))
+		os.Exit(1)
+	}
+
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	token, err := tunnel.Join(ctx, ServerAddr, tlsCfg, args[0], agent)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.join_failed", err))
+		os.Exit(1)
+	}
+
+	cfg.Token = token
+	// The agent token never expires; drop a refresh token of the old one
+	cfg.RefreshToken = ""
+	if cfg.Defaults == nil {
+		cfg.Defaults = make(map[string]string)
+	}
+	cfg.Defaults["agent"] = agent
+	if err := config.SaveConfig(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_save_error", err))
+		os.Exit(1)
+	}
+	path, _ := config.GetConfigPath()
+	fmt.Println(i18n.T("cli.joined", agent, path))
+}
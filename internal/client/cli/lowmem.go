@@ -0,0 +1,36 @@
+package cli
+
+import (
+	"os"
+	"runtime/debug"
+)
+
+// Low-memory profile (--low-memory), for small always-on devices such as
+// a Raspberry Pi exposing home-lab services.
+const (
+	// lowMemoryInspectorSize is the number of exchanges kept by the
+	// inspector, instead of defaultInspectorSize.
+	lowMemoryInspectorSize = 20
+	defaultInspectorSize   = 100
+	// lowMemorySoftLimit is the Go runtime soft memory limit, unless
+	// GOMEMLIMIT is set explicitly.
+	lowMemorySoftLimit = 64 << 20
+)
+
+// applyLowMemoryProfile limits the Go runtime's memory. The inspector is
+// sized by inspectorSize; per-tunnel settings (body streaming) are applied
+// via SetLowMemory.
+func applyLowMemoryProfile() {
+	if os.Getenv("GOMEMLIMIT") == "" {
+		debug.SetMemoryLimit(lowMemorySoftLimit)
+	}
+}
+
+// inspectorSize returns the number of exchanges the inspector keeps in
+// memory.
+func inspectorSize(lowMemory bool) int {
+	if lowMemory {
+		return lowMemoryInspectorSize
+	}
+	return defaultInspectorSize
+}
@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/logger"
+	"gopublic/internal/client/tunnel"
+	"gopublic/pkg/protocol"
+)
+
+// managedPollInterval is how often a client started with --managed asks
+// for its tunnel definitions while none are defined for it yet.
+const managedPollInterval = 30 * time.Second
+
+// errNoDefinitions tells that no tunnel defined in the dashboard matches
+// the client's labels.
+var errNoDefinitions = errors.New("no tunnels are defined in the dashboard for these labels")
+
+// managedLoader returns a loader of the tunnels defined in the dashboard
+// whose selector matches labels, in the form of a gopublic.yaml.
+func managedLoader(ctx context.Context, cfg *config.Config, tlsCfg *tunnel.TLSConfig, labels map[string]string) func() (*config.ProjectConfig, error) {
+	return func() (*config.ProjectConfig, error) {
+		defs, err := tunnel.FetchDefinitions(ctx, ServerAddr, tlsCfg, cfg.Token)
+		if err != nil {
+			return nil, err
+		}
+		return projectFromDefinitions(defs, labels)
+	}
+}
+
+// projectFromDefinitions builds the project config of the definitions
+// that match labels.
+func projectFromDefinitions(defs []protocol.TunnelDefinition, labels map[string]string) (*config.ProjectConfig, error) {
+	projectCfg := &config.ProjectConfig{Version: "1", Tunnels: make(map[string]*config.Tunnel)}
+	for _, d := range defs {
+		if !protocol.MatchSelector(d.Selector, labels) {
+			continue
+		}
+		projectCfg.Tunnels[d.Name] = &config.Tunnel{Addr: strconv.Itoa(d.Port), Subdomain: d.Subdomain}
+	}
+	if len(projectCfg.Tunnels) == 0 {
+		return nil, errNoDefinitions
+	}
+	return projectCfg, nil
+}
+
+// waitDefinitions returns the first config load returns, asking again
+// every managedPollInterval while no tunnels are defined for the client,
+// so that agents can be started before their tunnels are defined.
+func waitDefinitions(ctx context.Context, load func() (*config.ProjectConfig, error)) (*config.ProjectConfig, error) {
+	waiting := false
+	for {
+		projectCfg, err := load()
+		if !errors.Is(err, errNoDefinitions) {
+			return projectCfg, err
+		}
+		if !waiting {
+			logger.Print(i18n.T("cli.managed_waiting", managedPollInterval))
+			waiting = true
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(managedPollInterval):
+		}
+	}
+}
@@ -0,0 +1,31 @@
+package cli
+
+import (
+	"errors"
+	"testing"
+
+	"gopublic/pkg/protocol"
+)
+
+func TestProjectFromDefinitions(t *testing.T) {
+	defs := []protocol.TunnelDefinition{
+		{Name: "web", Subdomain: "happy-cat", Port: 3000, Selector: "env=preview"},
+		{Name: "api", Subdomain: "sad-dog", Port: 8080, Selector: "env=staging"},
+		{Name: "docs", Subdomain: "misty-river", Port: 4000},
+	}
+
+	projectCfg, err := projectFromDefinitions(defs, map[string]string{"env": "preview"})
+	if err != nil {
+		t.Fatalf("projectFromDefinitions() = %v", err)
+	}
+	if len(projectCfg.Tunnels) != 2 {
+		t.Fatalf("tunnels = %v, want web and docs", projectCfg.Tunnels)
+	}
+	if web := projectCfg.Tunnels["web"]; web == nil || web.Addr != "3000" || web.Subdomain != "happy-cat" {
+		t.Errorf("web = %+v", web)
+	}
+
+	if _, err := projectFromDefinitions(defs[:2], nil); !errors.Is(err, errNoDefinitions) {
+		t.Errorf("projectFromDefinitions(no match) = %v, want errNoDefinitions", err)
+	}
+}
@@ -0,0 +1,104 @@
+package cli
+
+import (
+	"context"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/logger"
+	"gopublic/internal/client/tunnel"
+	"gopublic/pkg/protocol"
+)
+
+// runReloading runs the manager's tunnels until ctx ends or they stop. A
+// reload sent from the dashboard calls load again (which re-reads
+// gopublic.yaml, or fetches the tunnels defined in the dashboard), builds
+// a new manager from the result and restarts the tunnels with that; if
+// load fails, the running tunnels are kept. Reconnect and keepalive
+// settings are not reloaded. With printReady, the public URLs are printed
+// after every start, see printReadyTunnels.
+func runReloading(ctx context.Context, manager *tunnel.TunnelManager, load func() (*config.ProjectConfig, error), build func(*config.ProjectConfig) (*tunnel.TunnelManager, error), eventBus *events.Bus, printReady bool) error {
+	commands := eventBus.Subscribe()
+	defer eventBus.Unsubscribe(commands)
+
+	for {
+		runCtx, stop := context.WithCancel(ctx)
+		done := make(chan error, 1)
+		go func() { done <- manager.StartAll(runCtx) }()
+		if printReady {
+			go printReadyTunnels(runCtx, manager)
+		}
+
+		next, err := waitReload(commands, done, load, build)
+		if next == nil {
+			stop()
+			return err
+		}
+
+		logger.Info("%s", i18n.T("cli.config_reloaded"))
+		stop()
+		manager.StopAll()
+		<-done
+		// The server may not have noticed yet that the old session closed
+		next.SetForce(true)
+		manager = next
+	}
+}
+
+// waitReload waits for a reload command and returns the manager built
+// from the config load returns then, or nil and the error of done if the
+// tunnels stop first.
+func waitReload(commands <-chan events.Event, done <-chan error, load func() (*config.ProjectConfig, error), build func(*config.ProjectConfig) (*tunnel.TunnelManager, error)) (*tunnel.TunnelManager, error) {
+	for {
+		select {
+		case err := <-done:
+			return nil, err
+		case event, ok := <-commands:
+			if !ok {
+				commands = nil // Bus closed; wait for the tunnels
+				continue
+			}
+			if !isReload(event) {
+				continue
+			}
+			projectCfg, err := load()
+			if err != nil {
+				logger.Warn("%s", i18n.T("cli.reload_failed", err))
+				continue
+			}
+			next, err := build(projectCfg)
+			if err != nil {
+				logger.Warn("%s", i18n.T("cli.reload_failed", err))
+				continue
+			}
+			return next, nil
+		}
+	}
+}
+
+// ignoreReloads reports reload commands as unsupported until ctx ends:
+// a single tunnel started from flags has no gopublic.yaml to re-read.
+func ignoreReloads(ctx context.Context, eventBus *events.Bus) {
+	commands := eventBus.Subscribe()
+	defer eventBus.Unsubscribe(commands)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-commands:
+			if !ok {
+				return
+			}
+			if isReload(event) {
+				logger.Warn("%s", i18n.T("cli.reload_single"))
+			}
+		}
+	}
+}
+
+// isReload reports whether event is a reload command from the dashboard.
+func isReload(event events.Event) bool {
+	data, ok := event.Data.(events.RemoteCommandData)
+	return event.Type == events.EventRemoteCommand && ok && data.Command == protocol.CommandReload
+}
@@ -0,0 +1,69 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/tunnel"
+	"gopublic/pkg/protocol"
+)
+
+func TestIsReload(t *testing.T) {
+	tests := []struct {
+		name  string
+		event events.Event
+		want  bool
+	}{
+		{"reload", events.Event{Type: events.EventRemoteCommand, Data: events.RemoteCommandData{Command: protocol.CommandReload}}, true},
+		{"other command", events.Event{Type: events.EventRemoteCommand, Data: events.RemoteCommandData{Command: "upgrade"}}, false},
+		{"other event", events.Event{Type: events.EventLog, Data: events.LogData{Message: protocol.CommandReload}}, false},
+	}
+	for _, tt := range tests {
+		if got := isReload(tt.event); got != tt.want {
+			t.Errorf("%s: isReload() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestWaitReload_TunnelsStop(t *testing.T) {
+	commands := make(chan events.Event, 1)
+	done := make(chan error, 1)
+	stopped := errors.New("tunnel closed")
+	commands <- events.Event{Type: events.EventLog}
+	done <- stopped
+
+	build := func(*config.ProjectConfig) (*tunnel.TunnelManager, error) {
+		t.Error("build called without a reload")
+		return nil, nil
+	}
+	next, err := waitReload(commands, done, loadProjectConfig, build)
+	if next != nil || !errors.Is(err, stopped) {
+		t.Errorf("waitReload() = %v, %v, want nil and the tunnel error", next, err)
+	}
+}
+
+func TestWaitReload_RebuildsFromProjectConfig(t *testing.T) {
+	t.Chdir(t.TempDir())
+	content := "version: \"1\"\ntunnels:\n  web:\n    addr: \"3000\"\n    subdomain: web\n"
+	if err := os.WriteFile("gopublic.yaml", []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	commands := make(chan events.Event, 1)
+	commands <- events.Event{Type: events.EventRemoteCommand, Data: events.RemoteCommandData{Command: protocol.CommandReload}}
+	var got *config.ProjectConfig
+	build := func(cfg *config.ProjectConfig) (*tunnel.TunnelManager, error) {
+		got = cfg
+		return tunnel.NewTunnelManager("localhost:4443", "token"), nil
+	}
+	next, err := waitReload(commands, make(chan error), loadProjectConfig, build)
+	if next == nil || err != nil {
+		t.Fatalf("waitReload() = %v, %v, want a new manager", next, err)
+	}
+	if got == nil || got.Tunnels["web"].Subdomain != "web" {
+		t.Errorf("build got %+v, want the re-read gopublic.yaml", got)
+	}
+}
@@ -4,29 +4,61 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io/fs"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"gopublic/internal/client/config"
+	"gopublic/internal/client/crash"
 	"gopublic/internal/client/events"
+	"gopublic/internal/client/i18n"
 	"gopublic/internal/client/inspector"
 	"gopublic/internal/client/logger"
 	"gopublic/internal/client/stats"
+	"gopublic/internal/client/telemetry"
 	"gopublic/internal/client/tui"
 	"gopublic/internal/client/tunnel"
+	"gopublic/internal/filter"
 	"gopublic/internal/version"
+	"gopublic/pkg/protocol"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"golang.org/x/term"
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "gopublic",
 	Short: "A secure request tunneling tool",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			cfg = &config.Config{} // Commands that need it report the error
+		}
+		setupLanguage(cfg)
+		if cfg.Server != "" {
+			ServerAddr = cfg.Server
+		}
+		if err := checkDefaults(cmd.Root(), cfg.Defaults); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_defaults", err))
+			os.Exit(1)
+		}
+		if err := resolveFlags(cmd, cfg.Defaults); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_defaults", err))
+			os.Exit(1)
+		}
+	},
 }
 
+// filterReloadInterval is how often filter modules are checked for changes.
+const filterReloadInterval = 2 * time.Second
+
 // ServerAddr should be injected via ldflags. Default for dev.
 var ServerAddr = "localhost:4443"
 
@@ -38,8 +70,30 @@ func Init(serverAddr string) {
 	// Set version for TUI
 	tui.Version = version.Version
 
+	enableConsoleColors()
+
 	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(joinCmd)
 	rootCmd.AddCommand(startCmd)
+	rootCmd.AddCommand(httpCmd)
+	rootCmd.AddCommand(tcpCmd)
+	rootCmd.AddCommand(diagnoseCmd)
+	rootCmd.AddCommand(speedtestCmd)
+	rootCmd.AddCommand(benchCmd)
+	rootCmd.AddCommand(scenarioCmd)
+	rootCmd.AddCommand(telemetryCmd)
+	rootCmd.AddCommand(trustCmd)
+	rootCmd.AddCommand(schemaCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(addCmd)
+	rootCmd.AddCommand(removeCmd)
+	rootCmd.AddCommand(reconnectCmd)
+}
+
+// setupLanguage selects the message language from the user config,
+// falling back to the LANG environment.
+func setupLanguage(cfg *config.Config) {
+	i18n.SetLanguage(i18n.Detect(cfg.Language))
 }
 
 func Execute() {
@@ -57,16 +111,18 @@ var authCmd = &cobra.Command{
 		token := args[0]
 		cfg, err := config.LoadConfig()
 		if err != nil {
-			fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+			fmt.Fprintln(os.Stderr, i18n.T("cli.config_load_error", err))
 			os.Exit(1)
 		}
 		cfg.Token = token
+		// A token without expiry has no refresh token; drop a stale one
+		cfg.RefreshToken, _ = cmd.Flags().GetString("refresh")
 		if err := config.SaveConfig(cfg); err != nil {
-			fmt.Fprintf(os.Stderr, "Error saving config: %v\n", err)
+			fmt.Fprintln(os.Stderr, i18n.T("cli.config_save_error", err))
 			os.Exit(1)
 		}
 		path, _ := config.GetConfigPath()
-		fmt.Printf("Token saved to %s\n", path)
+		fmt.Println(i18n.T("cli.token_saved", path))
 	},
 }
 
@@ -74,55 +130,299 @@ var startCmd = &cobra.Command{
 	Use:   "start [port]",
 	Short: "Start a public tunnel to a local port",
 	Args:  cobra.MaximumNArgs(1),
-	Run:   runStart,
+	Run: func(cmd *cobra.Command, args []string) {
+		proto, _ := cmd.Flags().GetString("proto")
+		runStart(cmd, args, proto)
+	},
+}
+
+var httpCmd = &cobra.Command{
+	Use:     "http <port>",
+	Short:   "Start a public HTTP tunnel to a local port",
+	Example: "  gopublic http 3000\n  gopublic http 3000 --subdomain foo",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runStart(cmd, args, protocol.ProtoHTTP)
+	},
+}
+
+var tcpCmd = &cobra.Command{
+	Use:     "tcp <port>",
+	Short:   "Start a public TCP tunnel to a local port, on a server-assigned port",
+	Example: "  gopublic tcp 5432",
+	Args:    cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		runStart(cmd, args, protocol.ProtoTCP)
+	},
 }
 
 func init() {
+	authCmd.Flags().String("refresh", "", "Refresh token that renews the token when it expires, shown next to expiring tokens in the dashboard")
+
 	startCmd.Flags().BoolP("all", "a", false, "Start all tunnels from gopublic.yaml")
-	startCmd.Flags().Bool("tui", true, "Enable terminal UI (default: true for interactive terminals)")
-	startCmd.Flags().Bool("no-tui", false, "Disable terminal UI")
-	startCmd.Flags().BoolP("force", "f", false, "Force connect, replacing any existing session")
-	startCmd.Flags().Bool("no-cache", false, "Add Cache-Control: no-store header to all responses (useful for development)")
+	startCmd.Flags().Bool("managed", false, "Run the tunnels defined in the dashboard for this client's --label values instead of gopublic.yaml; they are reloaded when changed")
+	startCmd.Flags().String("proto", protocol.ProtoHTTP, "Tunnel protocol: http, or tcp for raw TCP services (Postgres, Redis, SSH) on a server-assigned port")
+	for _, cmd := range []*cobra.Command{startCmd, httpCmd} {
+		cmd.Flags().String("subdomain", "", "Bind only this domain of your account instead of all of them, e.g. misty-river")
+		cmd.Flags().String("domain", "", "Like --subdomain, with the full hostname, e.g. misty-river.tunnel.example.com")
+		cmd.MarkFlagsMutuallyExclusive("subdomain", "domain")
+		cmd.Flags().String("basic-auth", "", "Require visitors to log in with user:pass before reaching the service")
+		cmd.Flags().Float64("rate-limit", 0, "Requests per second the server forwards to the tunnel; more get 429 (default: the server limit)")
+		cmd.Flags().Int("rate-burst", 0, "Requests allowed at once on top of --rate-limit (default: the rate)")
+		cmd.Flags().Bool("no-cache", false, "Add Cache-Control: no-store header to all responses (useful for development)")
+		cmd.Flags().StringSlice("filter", nil, "WASM traffic filter module to apply (repeatable, reloaded on change)")
+	}
+	for _, cmd := range []*cobra.Command{startCmd, httpCmd, tcpCmd} {
+		addTunnelFlags(cmd)
+	}
+}
+
+// addTunnelFlags registers the flags shared by the commands starting
+// tunnels: start, http and tcp.
+func addTunnelFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("tui", true, "Enable terminal UI (default: true for interactive terminals)")
+	cmd.Flags().Bool("no-tui", false, "Disable terminal UI")
+	cmd.Flags().String("log-format", logger.FormatText, "Log format without the TUI: text, or json for log collectors (implies --no-tui)")
+	cmd.Flags().BoolP("force", "f", false, "Force connect, replacing any existing session")
+	cmd.Flags().StringToString("label", nil, "Label to attach to the tunnel, e.g. --label env=staging (repeatable)")
+	cmd.Flags().String("agent", "", "Name this client in the dashboard fleet view, e.g. ci-runner-1; agents with different names stay connected side by side")
+	cmd.Flags().Bool("low-memory", false, "Low-memory profile for small devices: no TUI, no body capture, small inspector buffer")
+	cmd.Flags().Bool("persist-inspector", false, "Keep captured requests across restarts in ~/.local/state/gopublic/inspector.db (last 1000, up to 7 days)")
+	addTLSFlags(cmd)
+	addReconnectFlags(cmd)
+	addKeepaliveFlags(cmd)
+}
+
+// addReconnectFlags registers the --reconnect-* flags overriding the
+// reconnect policy from gopublic.yaml.
+func addReconnectFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("reconnect-initial-delay", 0, "Delay before the first reconnect attempt (default 1s)")
+	cmd.Flags().Duration("reconnect-max-delay", 0, "Maximum delay between reconnect attempts (default 60s)")
+	cmd.Flags().Float64("reconnect-multiplier", 0, "Backoff factor between reconnect attempts (default 2)")
+	cmd.Flags().Int("reconnect-max-attempts", 0, "Give up after this many failed reconnect attempts (default 0, never)")
+	cmd.Flags().Float64("reconnect-jitter", 0, "Randomize each reconnect delay by up to this fraction, 0-1 (default 0)")
+	cmd.Flags().Bool("offline-start", false, "Start even if the server is unreachable and keep retrying until it is; --reconnect-max-attempts applies once connected")
+}
+
+// reconnectConfig builds the reconnect policy from the defaults, the
+// --reconnect-* flags set in the user config, the reconnect section of
+// gopublic.yaml and the flags set from the environment or the command
+// line, in increasing precedence.
+func reconnectConfig(cmd *cobra.Command, fromConfig *config.Reconnect) (*tunnel.ReconnectConfig, error) {
+	rc := tunnel.DefaultReconnectConfig()
+	applyReconnectFlags(rc, cmd.Flags(), sourceUserConfig, sourceUserConfig)
+	if fromConfig != nil {
+		if fromConfig.InitialDelay != 0 {
+			rc.InitialDelay = fromConfig.InitialDelay
+		}
+		if fromConfig.MaxDelay != 0 {
+			rc.MaxDelay = fromConfig.MaxDelay
+		}
+		if fromConfig.Multiplier != 0 {
+			rc.Multiplier = fromConfig.Multiplier
+		}
+		if fromConfig.MaxAttempts != 0 {
+			rc.MaxAttempts = fromConfig.MaxAttempts
+		}
+		if fromConfig.Jitter != 0 {
+			rc.Jitter = fromConfig.Jitter
+		}
+		if fromConfig.OfflineStart {
+			rc.OfflineStart = true
+		}
+	}
+	applyReconnectFlags(rc, cmd.Flags(), sourceEnv, sourceCommandLine)
+	return rc, rc.Validate()
+}
+
+// applyReconnectFlags copies the --reconnect-* flags set from a source
+// between min and max to rc.
+func applyReconnectFlags(rc *tunnel.ReconnectConfig, flags *pflag.FlagSet, min, max flagSource) {
+	if flagFrom(flags, "reconnect-initial-delay", min, max) {
+		rc.InitialDelay, _ = flags.GetDuration("reconnect-initial-delay")
+	}
+	if flagFrom(flags, "reconnect-max-delay", min, max) {
+		rc.MaxDelay, _ = flags.GetDuration("reconnect-max-delay")
+	}
+	if flagFrom(flags, "reconnect-multiplier", min, max) {
+		rc.Multiplier, _ = flags.GetFloat64("reconnect-multiplier")
+	}
+	if flagFrom(flags, "reconnect-max-attempts", min, max) {
+		rc.MaxAttempts, _ = flags.GetInt("reconnect-max-attempts")
+	}
+	if flagFrom(flags, "reconnect-jitter", min, max) {
+		rc.Jitter, _ = flags.GetFloat64("reconnect-jitter")
+	}
+	if flagFrom(flags, "offline-start", min, max) {
+		rc.OfflineStart, _ = flags.GetBool("offline-start")
+	}
+}
+
+// addKeepaliveFlags registers the --keepalive-* flags overriding the
+// keepalive section of gopublic.yaml.
+func addKeepaliveFlags(cmd *cobra.Command) {
+	cmd.Flags().Duration("keepalive-interval", 0, "Ping the server this often to detect dead connections (default 5s)")
+	cmd.Flags().Duration("keepalive-timeout", 0, "Reconnect if a ping is not answered within this time (default 10s)")
+	cmd.Flags().Duration("handshake-timeout", 0, "Reconnect if a handshake or control message is not answered within this time (default 10s)")
 }
 
-func runStart(cmd *cobra.Command, args []string) {
+// keepaliveConfig builds the keepalive settings from the defaults, the
+// --keepalive-* flags set in the user config, the keepalive section of
+// gopublic.yaml and the flags set from the environment or the command
+// line, in increasing precedence.
+func keepaliveConfig(cmd *cobra.Command, fromConfig *config.Keepalive) (*tunnel.KeepaliveConfig, error) {
+	kc := tunnel.DefaultKeepaliveConfig()
+	applyKeepaliveFlags(kc, cmd.Flags(), sourceUserConfig, sourceUserConfig)
+	if fromConfig != nil {
+		if fromConfig.Interval != 0 {
+			kc.Interval = fromConfig.Interval
+		}
+		if fromConfig.Timeout != 0 {
+			kc.Timeout = fromConfig.Timeout
+		}
+		if fromConfig.HandshakeTimeout != 0 {
+			kc.HandshakeTimeout = fromConfig.HandshakeTimeout
+		}
+	}
+	applyKeepaliveFlags(kc, cmd.Flags(), sourceEnv, sourceCommandLine)
+	return kc, kc.Validate()
+}
+
+// applyKeepaliveFlags copies the --keepalive-* flags set from a source
+// between min and max to kc.
+func applyKeepaliveFlags(kc *tunnel.KeepaliveConfig, flags *pflag.FlagSet, min, max flagSource) {
+	if flagFrom(flags, "keepalive-interval", min, max) {
+		kc.Interval, _ = flags.GetDuration("keepalive-interval")
+	}
+	if flagFrom(flags, "keepalive-timeout", min, max) {
+		kc.Timeout, _ = flags.GetDuration("keepalive-timeout")
+	}
+	if flagFrom(flags, "handshake-timeout", min, max) {
+		kc.HandshakeTimeout, _ = flags.GetDuration("handshake-timeout")
+	}
+}
+
+// runStart starts the tunnels of start, http or tcp. proto applies to the
+// single tunnel started for a port argument. Flags a command lacks read as
+// their zero value.
+func runStart(cmd *cobra.Command, args []string, proto string) {
+	defer crash.Recover()
+
 	cfg, err := config.LoadConfig()
 	if err != nil {
-		fmt.Fprintf(os.Stderr, "Error loading config: %v\n", err)
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_load_error", err))
 		os.Exit(1)
 	}
 
 	if cfg.Token == "" {
-		fmt.Fprintln(os.Stderr, "No token found. Run 'gopublic auth <token>' first.")
-		os.Exit(1)
+		if !canRunWizard() {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.no_token"))
+			os.Exit(1)
+		}
+		if err := runWizard(cfg, args); err != nil {
+			if errors.Is(err, tui.ErrWizardCanceled) {
+				fmt.Fprintln(os.Stderr, i18n.T("cli.wizard_canceled"))
+			} else {
+				fmt.Fprintln(os.Stderr, i18n.T("cli.error", err))
+			}
+			os.Exit(1)
+		}
 	}
 
 	// Get flags
 	forceFlag, _ := cmd.Flags().GetBool("force")
 	noCacheFlag, _ := cmd.Flags().GetBool("no-cache")
+	filterFlag, _ := cmd.Flags().GetStringSlice("filter")
+	labelFlag, _ := cmd.Flags().GetStringToString("label")
+	agentFlag, _ := cmd.Flags().GetString("agent")
+	lowMemoryFlag, _ := cmd.Flags().GetBool("low-memory")
+	protoFlag := proto
+	basicAuthFlag, _ := cmd.Flags().GetString("basic-auth")
+	if protoFlag != protocol.ProtoHTTP && protoFlag != protocol.ProtoTCP {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_proto", protoFlag))
+		os.Exit(1)
+	}
+	domainFlag, err := requestedDomain(cmd)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_domain", err))
+		os.Exit(1)
+	}
+	if domainFlag != "" && protoFlag == protocol.ProtoTCP {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.subdomain_http_only"))
+		os.Exit(1)
+	}
+	if basicAuthFlag != "" {
+		if err := protocol.ValidateBasicAuth(basicAuthFlag); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_basic_auth", err))
+			os.Exit(1)
+		}
+		if protoFlag == protocol.ProtoTCP {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.basic_auth_http_only"))
+			os.Exit(1)
+		}
+	}
+	rateLimitFlag, _ := cmd.Flags().GetFloat64("rate-limit")
+	rateBurstFlag, _ := cmd.Flags().GetInt("rate-burst")
+	rateLimit := protocol.RateLimit{RPS: rateLimitFlag, Burst: rateBurstFlag}
+	if cmd.Flags().Changed("rate-limit") || cmd.Flags().Changed("rate-burst") {
+		if err := protocol.ValidateRateLimit(rateLimit); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_rate_limit", err))
+			os.Exit(1)
+		}
+		if protoFlag == protocol.ProtoTCP {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.rate_limit_http_only"))
+			os.Exit(1)
+		}
+	}
+	if len(filterFlag) > 0 && protoFlag == protocol.ProtoTCP {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.filter_http_only"))
+		os.Exit(1)
+	}
+	if err := protocol.ValidateLabels(labelFlag); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_label", err))
+		os.Exit(1)
+	}
+	if err := protocol.ValidateAgent(agentFlag); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_agent", err))
+		os.Exit(1)
+	}
+	logFormat, _ := cmd.Flags().GetString("log-format")
+	if err := logger.SetFormat(logFormat); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_log_format", err))
+		os.Exit(1)
+	}
+	tlsCfg, err := tlsConfig(cmd, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_ca_cert", err))
+		os.Exit(1)
+	}
 
 	// Check local lock file
 	if err := config.AcquireLock(); err != nil {
 		if errors.Is(err, config.ErrAlreadyRunning) {
 			if forceFlag {
-				fmt.Println("Force mode: removing stale lock file...")
+				fmt.Println(i18n.T("cli.force_remove_lock"))
 				config.ForceReleaseLock()
 				if err := config.AcquireLock(); err != nil {
-					fmt.Fprintf(os.Stderr, "Failed to acquire lock: %v\n", err)
+					fmt.Fprintln(os.Stderr, i18n.T("cli.lock_failed", err))
 					os.Exit(1)
 				}
 			} else {
-				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-				fmt.Fprintln(os.Stderr, "Use --force to override.")
+				fmt.Fprintln(os.Stderr, i18n.T("cli.error", err))
+				fmt.Fprintln(os.Stderr, i18n.T("cli.use_force"))
 				os.Exit(1)
 			}
 		} else {
-			fmt.Fprintf(os.Stderr, "Failed to acquire lock: %v\n", err)
+			fmt.Fprintln(os.Stderr, i18n.T("cli.lock_failed", err))
 			os.Exit(1)
 		}
 	}
 	defer config.ReleaseLock()
 
+	if lowMemoryFlag {
+		applyLowMemoryProfile()
+	}
+
 	// Determine if we should use TUI
 	useTUI := shouldUseTUI(cmd)
 
@@ -133,6 +433,12 @@ func runStart(cmd *cobra.Command, args []string) {
 	// Create shared components
 	eventBus := events.NewBus()
 	statsTracker := stats.New()
+	crash.Record(eventBus)
+	usage := telemetry.NewCollector()
+	usage.Track(eventBus, func(err error) string {
+		_, reason := exitStatus(err)
+		return reason
+	})
 
 	// Handle shutdown signals
 	sigChan := make(chan os.Signal, 1)
@@ -140,32 +446,143 @@ func runStart(cmd *cobra.Command, args []string) {
 	go func() {
 		<-sigChan
 		if !useTUI {
-			fmt.Println("\nShutdown signal received, closing tunnel...")
+			logger.Print(i18n.T("cli.shutdown"))
 		}
 		cancel()
 	}()
 
-	// Start Inspector in background
-	inspector.Start("4040")
+	// Start Inspector in background; the tunnels record to it
+	persistInspector, _ := cmd.Flags().GetBool("persist-inspector")
+	inspectorStore, closeInspectorStore := newInspectorStore(persistInspector, lowMemoryFlag)
+	insp := inspector.NewServer(inspectorStore, inspectorOptions()...)
+	defer func() {
+		insp.Flush()
+		closeInspectorStore()
+	}()
+	insp.TrackTunnels(eventBus)
+	insp.StartAsync(ctx)
+	tc := startControl(ctx, eventBus, statsTracker)
 
 	// Check for project config (gopublic.yaml)
 	allFlag, _ := cmd.Flags().GetBool("all")
+	managedFlag, _ := cmd.Flags().GetBool("managed")
+	if managedFlag && (allFlag || len(args) > 0 || protoFlag == protocol.ProtoTCP) {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.managed_exclusive"))
+		os.Exit(1)
+	}
 	projectCfg, projectErr := config.LoadProjectConfig("")
+	if projectErr != nil && !errors.Is(projectErr, fs.ErrNotExist) && (allFlag || len(args) == 0) && !managedFlag {
+		// A broken gopublic.yaml would otherwise be taken for a missing one
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_project_config", projectErr))
+		os.Exit(1)
+	}
+	multiTunnel := projectErr == nil && (allFlag || len(args) == 0) && !managedFlag
+
+	var reconnectFromConfig *config.Reconnect
+	var keepaliveFromConfig *config.Keepalive
+	if multiTunnel {
+		reconnectFromConfig = projectCfg.Reconnect
+		keepaliveFromConfig = projectCfg.Keepalive
+	}
+	reconnect, err := reconnectConfig(cmd, reconnectFromConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_reconnect", err))
+		os.Exit(1)
+	}
+	keepalive, err := keepaliveConfig(cmd, keepaliveFromConfig)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_keepalive", err))
+		os.Exit(1)
+	}
 
-	if projectErr == nil && (allFlag || len(args) == 0) {
+	trackUsage(usage, cmd, useTUI, multiTunnel || managedFlag)
+	if multiTunnel && (protoFlag == protocol.ProtoTCP || projectCfg.HasTCPTunnels()) {
+		// All tunnels share one session, which is either HTTP or TCP
+		fmt.Fprintln(os.Stderr, i18n.T("cli.tcp_single_only"))
+		os.Exit(1)
+	}
+
+	var tunnelErr error
+	if managedFlag {
+		// Multi-tunnel mode from the dashboard
+		load := managedLoader(ctx, cfg, tlsCfg, labelFlag)
+		projectCfg, tunnelErr = waitDefinitions(ctx, load)
+		if tunnelErr == nil {
+			tunnelErr = runMultiTunnel(ctx, tc, cfg, projectCfg, load, true, labelFlag, agentFlag, reconnect, keepalive, tlsCfg, eventBus, statsTracker, insp, useTUI, forceFlag, noCacheFlag, lowMemoryFlag)
+		}
+	} else if multiTunnel {
 		// Multi-tunnel mode from gopublic.yaml
-		runMultiTunnel(ctx, cfg, projectCfg, eventBus, statsTracker, useTUI, forceFlag, noCacheFlag)
+		tunnelErr = runMultiTunnel(ctx, tc, cfg, projectCfg, loadProjectConfig, false, labelFlag, agentFlag, reconnect, keepalive, tlsCfg, eventBus, statsTracker, insp, useTUI, forceFlag, noCacheFlag, lowMemoryFlag)
 	} else if len(args) == 1 {
 		// Single tunnel mode
 		port := args[0]
-		runSingleTunnel(ctx, cfg, port, eventBus, statsTracker, useTUI, forceFlag, noCacheFlag)
+		tunnelErr = runSingleTunnel(ctx, tc, cfg, port, protoFlag, domainFlag, basicAuthFlag, rateLimit, filterFlag, labelFlag, agentFlag, reconnect, keepalive, tlsCfg, eventBus, statsTracker, insp, useTUI, forceFlag, noCacheFlag, lowMemoryFlag)
 	} else {
-		fmt.Fprintln(os.Stderr, "Either provide a port or create gopublic.yaml config file")
+		fmt.Fprintln(os.Stderr, i18n.T("cli.port_or_config"))
 		os.Exit(1)
 	}
 
-	if !useTUI {
-		fmt.Println("Tunnel closed")
+	code, reason := 0, ""
+	if tunnelErr != nil {
+		code, reason = exitStatus(tunnelErr)
+	}
+	if code != 0 && code != exitCanceled {
+		usage.Error(reason)
+	}
+	sendUsage(cfg, usage)
+	if code != 0 && code != exitCanceled {
+		if logFormat == logger.FormatJSON {
+			logger.Error("%s", i18n.T("cli.tunnel_error", tunnelErr))
+		} else {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.tunnel_error", tunnelErr))
+		}
+		if reason == "token_expired" {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.token_expired"))
+		}
+		if reason == "domain_taken" && domainFlag != "" && !multiTunnel {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.domain_not_bound", domainFlag))
+		}
+	} else if !useTUI {
+		logger.Print(i18n.T("cli.tunnel_closed"))
+	}
+	if code != 0 {
+		// Not translated: scripts match on it
+		fmt.Fprintf(os.Stderr, "exit_reason=%s\n", reason)
+		config.ReleaseLock()
+		os.Exit(code)
+	}
+}
+
+// requestedDomain returns the domain set with --subdomain or --domain, or
+// "" to bind all domains of the account.
+func requestedDomain(cmd *cobra.Command) (string, error) {
+	if subdomain, _ := cmd.Flags().GetString("subdomain"); subdomain != "" {
+		return subdomain, protocol.ValidateSubdomain(subdomain)
+	}
+	if domain, _ := cmd.Flags().GetString("domain"); domain != "" {
+		return domain, protocol.ValidateDomain(domain)
+	}
+	return "", nil
+}
+
+// tokenRefresher returns a refresher that renews cfg.Token with the stored
+// refresh token and saves both new credentials, or nil if there is none.
+func tokenRefresher(cfg *config.Config, tlsCfg *tunnel.TLSConfig) tunnel.TokenRefresher {
+	if cfg.RefreshToken == "" {
+		return nil
+	}
+	return func(ctx context.Context) (string, error) {
+		refreshed, err := tunnel.RefreshToken(ctx, ServerAddr, tlsCfg, cfg.RefreshToken)
+		if err != nil {
+			return "", err
+		}
+		cfg.Token = refreshed.Token
+		cfg.RefreshToken = refreshed.RefreshToken
+		if err := config.SaveConfig(cfg); err != nil {
+			// The old credentials no longer work: keep going with the new ones
+			fmt.Fprintln(os.Stderr, i18n.T("cli.config_save_error", err))
+		}
+		return refreshed.Token, nil
 	}
 }
 
@@ -176,6 +593,18 @@ func shouldUseTUI(cmd *cobra.Command) bool {
 		return false
 	}
 
+	// JSON logs are for machines
+	logFormat, _ := cmd.Flags().GetString("log-format")
+	if logFormat == logger.FormatJSON {
+		return false
+	}
+
+	// The TUI keeps request and log history; skip it in low-memory mode
+	lowMemory, _ := cmd.Flags().GetBool("low-memory")
+	if lowMemory {
+		return false
+	}
+
 	tuiFlag, _ := cmd.Flags().GetBool("tui")
 	if !tuiFlag {
 		return false
@@ -189,73 +618,238 @@ func shouldUseTUI(cmd *cobra.Command) bool {
 	return true
 }
 
-func runSingleTunnel(ctx context.Context, cfg *config.Config, port string, eventBus *events.Bus, statsTracker *stats.Stats, useTUI bool, force bool, noCache bool) {
+func runSingleTunnel(ctx context.Context, tc *tunnelControl, cfg *config.Config, port, proto, subdomain, basicAuth string, rateLimit protocol.RateLimit, filterPaths []string, labels map[string]string, agent string, reconnect *tunnel.ReconnectConfig, keepalive *tunnel.KeepaliveConfig, tlsCfg *tunnel.TLSConfig, eventBus *events.Bus, statsTracker *stats.Stats, insp *inspector.Server, useTUI bool, force bool, noCache bool, lowMemory bool) error {
 	// Configure replay with local port
-	inspector.SetLocalPort(port)
+	insp.SetLocalPort(port)
 
 	// Create tunnel with dependencies
 	t := tunnel.NewTunnel(ServerAddr, cfg.Token, port)
 	t.SetEventBus(eventBus)
 	t.SetStats(statsTracker)
+	t.SetInspector(insp)
 	t.SetForce(force)
 	t.SetNoCache(noCache)
+	t.SetLowMemory(lowMemory)
+	t.SetFilters(loadFilters(ctx, filterPaths))
+	t.SetLabels(labels)
+	t.SetAgent(agent)
+	t.SetProto(proto)
+	t.SetSubdomain(subdomain)
+	t.SetBasicAuth(basicAuth)
+	t.SetRateLimit(rateLimit)
+	t.SetTLSConfig(tlsCfg)
+	t.SetKeepalive(keepalive)
+	t.SetTokenRefresher(tokenRefresher(cfg, tlsCfg))
+	tc.setRunning(t)
+	go ignoreReloads(ctx, eventBus)
 
 	if useTUI {
 		// Run with TUI
-		runWithTUI(ctx, eventBus, statsTracker, func(ctx context.Context) error {
-			return t.StartWithReconnect(ctx, nil)
+		return runWithTUI(ctx, eventBus, statsTracker, insp, func(ctx context.Context) error {
+			return t.StartWithReconnect(ctx, reconnect)
 		})
-	} else {
-		// Legacy mode
-		fmt.Printf("Starting tunnel to localhost:%s on server %s\n", port, ServerAddr)
-		fmt.Println("Inspector UI: http://localhost:4040")
-
-		if err := t.StartWithReconnect(ctx, nil); err != nil {
-			if err != context.Canceled {
-				fmt.Fprintf(os.Stderr, "Tunnel error: %v\n", err)
-				os.Exit(1)
-			}
-		}
 	}
+
+	// Legacy mode
+	logger.Print(i18n.T("cli.starting_tunnel", port, ServerAddr))
+	logger.Print(i18n.T("cli.inspector_url"))
+	return t.StartWithReconnect(ctx, reconnect)
 }
 
-func runMultiTunnel(ctx context.Context, cfg *config.Config, projectCfg *config.ProjectConfig, eventBus *events.Bus, statsTracker *stats.Stats, useTUI bool, force bool, noCache bool) {
-	manager := tunnel.NewTunnelManager(ServerAddr, cfg.Token)
-	manager.SetForce(force)
-	manager.SetEventBus(eventBus)
-	manager.SetStats(statsTracker)
-	manager.SetNoCache(noCache)
+// loadProjectConfig reads gopublic.yaml from the working directory.
+func loadProjectConfig() (*config.ProjectConfig, error) {
+	return config.LoadProjectConfig("")
+}
 
-	// Set first tunnel port for replay
-	for _, t := range projectCfg.Tunnels {
-		inspector.SetLocalPort(t.Addr)
-		break
+// runMultiTunnel runs the tunnels of projectCfg on a shared session. A
+// reload sent from the dashboard replaces them with those load returns;
+// managed marks tunnels fetched from the dashboard, see managedLoader.
+// Other tunnels can be changed through tc by the control API.
+func runMultiTunnel(ctx context.Context, tc *tunnelControl, cfg *config.Config, projectCfg *config.ProjectConfig, load func() (*config.ProjectConfig, error), managed bool, labels map[string]string, agent string, reconnect *tunnel.ReconnectConfig, keepalive *tunnel.KeepaliveConfig, tlsCfg *tunnel.TLSConfig, eventBus *events.Bus, statsTracker *stats.Stats, insp *inspector.Server, useTUI bool, force bool, noCache bool, lowMemory bool) error {
+	filters := &filterModules{ctx: ctx, modules: make(map[string]*filter.Module)}
+
+	// build creates the manager of the tunnels in projectCfg; it runs again
+	// for every reload sent from the dashboard
+	build := func(projectCfg *config.ProjectConfig) (*tunnel.TunnelManager, error) {
+		manager := tunnel.NewTunnelManager(ServerAddr, cfg.Token)
+		manager.SetForce(force)
+		manager.SetEventBus(eventBus)
+		manager.SetStats(statsTracker)
+		manager.SetInspector(insp)
+		manager.SetNoCache(noCache)
+		manager.SetLowMemory(lowMemory)
+		manager.SetReconnectConfig(reconnect)
+		manager.SetTLSConfig(tlsCfg)
+		manager.SetKeepalive(keepalive)
+		manager.SetTokenRefresher(tokenRefresher(cfg, tlsCfg))
+		manager.SetAgent(agent)
+		manager.SetManaged(managed)
+		mergedLabels := mergeLabels(projectCfg.Labels, labels)
+		if err := protocol.ValidateLabels(mergedLabels); err != nil {
+			return nil, errors.New(i18n.T("cli.invalid_config_labels", err))
+		}
+		manager.SetLabels(mergedLabels)
+
+		for name, t := range projectCfg.Tunnels {
+			manager.AddTunnel(name, t.Addr, t.Subdomain)
+			if len(t.Filters) > 0 {
+				chain, err := filters.chain(t.Filters)
+				if err != nil {
+					return nil, errors.New(i18n.T("cli.filters_failed", err))
+				}
+				manager.SetTunnelFilters(name, chain)
+			}
+			if t.StartTimeout > 0 {
+				manager.SetTunnelStartTimeout(name, t.StartTimeout)
+			}
+			if t.MountPath != "" {
+				manager.SetTunnelMount(name, t.MountPath, t.KeepMountPath)
+			}
+			if t.RequestHeaders != nil || t.ResponseHeaders != nil {
+				manager.SetTunnelHeaders(name, headerRewrite(t))
+			}
+			if t.BasicAuth != "" {
+				if err := protocol.ValidateBasicAuth(t.BasicAuth); err != nil {
+					return nil, errors.New(i18n.T("cli.invalid_config_basic_auth", name, err))
+				}
+				manager.SetTunnelBasicAuth(name, t.BasicAuth)
+			}
+			if t.RateLimit > 0 || t.RateBurst > 0 {
+				limit := protocol.RateLimit{RPS: t.RateLimit, Burst: t.RateBurst}
+				if err := protocol.ValidateRateLimit(limit); err != nil {
+					return nil, errors.New(i18n.T("cli.invalid_config_rate_limit", name, err))
+				}
+				manager.SetTunnelRateLimit(name, limit)
+			}
+		}
+
+		// Set first tunnel port for replay
+		for _, t := range projectCfg.Tunnels {
+			insp.SetLocalPort(t.Addr)
+			break
+		}
+		tc.setRunning(manager)
+		return manager, nil
+	}
+	if !managed {
+		// Tunnels added or removed with "gopublic add" and "remove"
+		load = tc.edit(projectCfg, load)
 	}
 
-	for name, t := range projectCfg.Tunnels {
-		manager.AddTunnel(name, t.Addr, t.Subdomain)
+	manager, err := build(projectCfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
 	}
 
 	if useTUI {
 		// Run with TUI
-		runWithTUI(ctx, eventBus, statsTracker, func(ctx context.Context) error {
-			return manager.StartAll(ctx)
+		return runWithTUI(ctx, eventBus, statsTracker, insp, func(ctx context.Context) error {
+			return runReloading(ctx, manager, load, build, eventBus, false)
 		})
-	} else {
-		// Legacy mode
-		fmt.Println("Loading tunnels from gopublic.yaml...")
-		fmt.Println("Inspector UI: http://localhost:4040")
+	}
 
-		if err := manager.StartAll(ctx); err != nil {
-			if err != context.Canceled {
-				fmt.Fprintf(os.Stderr, "Tunnel error: %v\n", err)
-				os.Exit(1)
+	// Legacy mode
+	logger.Print(i18n.T("cli.loading_tunnels"))
+	logger.Print(i18n.T("cli.inspector_url"))
+	return runReloading(ctx, manager, load, build, eventBus, true)
+}
+
+// printReadyTunnels prints the public URLs once all tunnels are bound or
+// failed, one "name url..." line per bound tunnel, so that scripts can wait
+// for them. Failed tunnels are reported on stderr and retried meanwhile.
+func printReadyTunnels(ctx context.Context, manager *tunnel.TunnelManager) {
+	err := manager.WaitReady(ctx)
+	if ctx.Err() != nil {
+		return
+	}
+	urls := manager.PublicURLs()
+	names := make([]string, 0, len(urls))
+	for name := range urls {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		logger.Print(i18n.T("cli.tunnel_ready", name, strings.Join(urls[name], " ")))
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.tunnels_not_ready", err))
+	}
+}
+
+// headerRewrite converts the header rules of a gopublic.yaml tunnel.
+func headerRewrite(t *config.Tunnel) *tunnel.HeaderRewrite {
+	rules := func(r *config.HeaderRules) tunnel.HeaderRules {
+		if r == nil {
+			return tunnel.HeaderRules{}
+		}
+		return tunnel.HeaderRules{Add: r.Add, Remove: r.Remove}
+	}
+	return &tunnel.HeaderRewrite{Request: rules(t.RequestHeaders), Response: rules(t.ResponseHeaders)}
+}
+
+// mergeLabels combines labels from gopublic.yaml with --label flags.
+// Flags take precedence over the config file.
+func mergeLabels(fromConfig, fromFlags map[string]string) map[string]string {
+	if len(fromConfig) == 0 && len(fromFlags) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(fromConfig)+len(fromFlags))
+	for k, v := range fromConfig {
+		merged[k] = v
+	}
+	for k, v := range fromFlags {
+		merged[k] = v
+	}
+	return merged
+}
+
+// loadFilters loads WASM filter modules and watches them for changes.
+// Exits if a module cannot be loaded.
+func loadFilters(ctx context.Context, paths []string) filter.Chain {
+	if len(paths) == 0 {
+		return nil
+	}
+	chain, err := filter.LoadChain(paths)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.filters_failed", err))
+		os.Exit(1)
+	}
+	filter.WatchChain(ctx, chain, filterReloadInterval)
+	return chain
+}
+
+// filterModules loads the filter modules of gopublic.yaml tunnels and
+// watches them until ctx ends. Each path is loaded once, so the tunnels
+// built on a reload share the modules already running.
+type filterModules struct {
+	ctx     context.Context
+	mu      sync.Mutex
+	modules map[string]*filter.Module
+}
+
+func (fm *filterModules) chain(paths []string) (filter.Chain, error) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	var chain filter.Chain
+	for _, path := range paths {
+		m, ok := fm.modules[path]
+		if !ok {
+			var err error
+			if m, err = filter.LoadModule(path); err != nil {
+				return nil, err
 			}
+			go m.Watch(fm.ctx, filterReloadInterval)
+			fm.modules[path] = m
 		}
+		chain = append(chain, m)
 	}
+	return chain, nil
 }
 
-func runWithTUI(ctx context.Context, eventBus *events.Bus, statsTracker *stats.Stats, tunnelFunc func(context.Context) error) {
+// runWithTUI runs tunnelFunc behind the TUI and returns its error. Quitting
+// the TUI is a normal exit rather than a cancellation.
+func runWithTUI(ctx context.Context, eventBus *events.Bus, statsTracker *stats.Stats, insp *inspector.Server, tunnelFunc func(context.Context) error) error {
 	// Create context that will be cancelled when TUI exits
 	tuiCtx, tuiCancel := context.WithCancel(ctx)
 	defer tuiCancel()
@@ -267,22 +861,29 @@ func runWithTUI(ctx context.Context, eventBus *events.Bus, statsTracker *stats.S
 
 	// Start tunnel in background
 	tunnelDone := make(chan error, 1)
-	go func() {
+	crash.Go(func() {
 		tunnelDone <- tunnelFunc(tuiCtx)
-	}()
+	})
 
 	// Create and run TUI
 	model := tui.NewModel(eventBus, statsTracker)
+	model.SetInspector(insp)
 	p := tea.NewProgram(model, tea.WithAltScreen())
+	// Leave the alternate screen so the crash report path is visible
+	defer crash.BeforeExit(func() { p.ReleaseTerminal() })()
 
 	// Run TUI (blocks until quit)
 	if _, err := p.Run(); err != nil {
-		fmt.Fprintf(os.Stderr, "TUI error: %v\n", err)
+		fmt.Fprintln(os.Stderr, i18n.T("cli.tui_error", err))
 	}
 
 	// Cancel tunnel context when TUI exits
 	tuiCancel()
 
 	// Wait for tunnel to finish
-	<-tunnelDone
+	err := <-tunnelDone
+	if errors.Is(err, context.Canceled) && ctx.Err() == nil {
+		return nil
+	}
+	return err
 }
@@ -2,11 +2,20 @@ package cli
 
 import (
 	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/tui"
+	"gopublic/internal/client/tunnel"
 	"gopublic/internal/version"
+	"gopublic/pkg/protocol"
 
 	"github.com/spf13/cobra"
 )
@@ -143,6 +152,17 @@ func TestShouldUseTUI_NoTuiFlag(t *testing.T) {
 	}
 }
 
+func TestShouldUseTUI_JSONLogFormat(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("no-tui", false, "")
+	cmd.Flags().Bool("tui", true, "")
+	cmd.Flags().String("log-format", "json", "")
+
+	if shouldUseTUI(cmd) {
+		t.Error("expected false with --log-format json")
+	}
+}
+
 func TestShouldUseTUI_TuiFlagFalse(t *testing.T) {
 	cmd := &cobra.Command{}
 	cmd.Flags().Bool("no-tui", false, "")
@@ -252,3 +272,155 @@ func TestRootCmd_Structure(t *testing.T) {
 		t.Error("Short description should not be empty")
 	}
 }
+
+func TestShouldUseTUI_LowMemory(t *testing.T) {
+	cmd := &cobra.Command{}
+	cmd.Flags().Bool("no-tui", false, "")
+	cmd.Flags().Bool("tui", true, "")
+	cmd.Flags().Bool("low-memory", true, "")
+
+	if shouldUseTUI(cmd) {
+		t.Error("expected false when --low-memory is set")
+	}
+}
+
+func TestDiagnoseCmd_Structure(t *testing.T) {
+	if diagnoseCmd.Use != "diagnose [port]" {
+		t.Errorf("unexpected Use: %s", diagnoseCmd.Use)
+	}
+	if diagnoseCmd.Flags().Lookup("timeout") == nil {
+		t.Error("expected 'timeout' flag to be registered")
+	}
+}
+
+func TestSpeedtestCmd_Structure(t *testing.T) {
+	if speedtestCmd.Use != "speedtest" {
+		t.Errorf("unexpected Use: %s", speedtestCmd.Use)
+	}
+	for _, name := range []string{"size", "pings"} {
+		if speedtestCmd.Flags().Lookup(name) == nil {
+			t.Errorf("expected '%s' flag to be registered", name)
+		}
+	}
+}
+
+func TestReconnectConfig(t *testing.T) {
+	cmd := &cobra.Command{}
+	addReconnectFlags(cmd)
+
+	// gopublic.yaml overrides the defaults, flags override gopublic.yaml
+	fromConfig := &config.Reconnect{InitialDelay: 2 * time.Second, MaxAttempts: 5}
+	if err := cmd.Flags().Parse([]string{"--reconnect-max-attempts=0", "--reconnect-jitter=0.1", "--offline-start"}); err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	rc, err := reconnectConfig(cmd, fromConfig)
+	if err != nil {
+		t.Fatalf("reconnectConfig() error = %v", err)
+	}
+	if rc.InitialDelay != 2*time.Second || rc.MaxDelay != 60*time.Second || rc.Multiplier != 2 || rc.MaxAttempts != 0 || rc.Jitter != 0.1 || !rc.OfflineStart {
+		t.Errorf("reconnectConfig() = %+v", rc)
+	}
+
+	cmd = &cobra.Command{}
+	addReconnectFlags(cmd)
+	cmd.Flags().Parse([]string{"--reconnect-max-delay=100ms"})
+	if _, err := reconnectConfig(cmd, nil); err == nil {
+		t.Error("reconnectConfig() with max delay below initial delay: expected error")
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	dialErr := &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	tests := []struct {
+		name   string
+		err    error
+		code   int
+		reason string
+	}{
+		{"canceled", context.Canceled, exitCanceled, "canceled"},
+		{"invalid token", &tunnel.ServerError{Code: protocol.ErrorCodeInvalidToken}, exitAuthFailed, "auth_failed"},
+		{"expired token", &tunnel.ServerError{Code: protocol.ErrorCodeTokenExpired}, exitAuthFailed, "token_expired"},
+		{"no domains", &tunnel.ServerError{Code: protocol.ErrorCodeNoDomains}, exitDomainTaken, "domain_taken"},
+		{"quota", &tunnel.ServerError{Code: protocol.ErrorCodeQuotaExceeded}, exitQuotaExceeded, "quota_exceeded"},
+		{"already connected", &tunnel.AlreadyConnectedError{}, exitAlreadyConnected, "already_connected"},
+		{"too many agents", &tunnel.ServerError{Code: protocol.ErrorCodeTooManyAgents}, exitAlreadyConnected, "too_many_agents"},
+		{"remote disconnect", tunnel.ErrRemoteDisconnect, 0, "remote_disconnect"},
+		{"unreachable", fmt.Errorf("max reconnection attempts (3) exceeded: %w", fmt.Errorf("failed to connect: %w", dialErr)), exitNetwork, "network_unreachable"},
+		{"untrusted", fmt.Errorf("failed to connect: %w", tunnel.ErrPinMismatch), exitTLSUntrusted, "tls_untrusted"},
+		{"other", errors.New("session ended"), exitError, "error"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, reason := exitStatus(tt.err)
+			if code != tt.code || reason != tt.reason {
+				t.Errorf("exitStatus() = %d, %q, want %d, %q", code, reason, tt.code, tt.reason)
+			}
+		})
+	}
+}
+
+func TestProtoCmds_Flags(t *testing.T) {
+	if httpCmd.Flags().Lookup("subdomain") == nil || httpCmd.Flags().Lookup("basic-auth") == nil {
+		t.Error("http should accept --subdomain and --basic-auth")
+	}
+	for _, name := range []string{"subdomain", "basic-auth", "proto", "all"} {
+		if tcpCmd.Flags().Lookup(name) != nil {
+			t.Errorf("tcp should not accept --%s", name)
+		}
+	}
+	for _, cmd := range []*cobra.Command{startCmd, httpCmd, tcpCmd} {
+		if cmd.Flags().Lookup("force") == nil || cmd.Flags().Lookup("reconnect-max-delay") == nil {
+			t.Errorf("%s lacks the shared tunnel flags", cmd.Name())
+		}
+	}
+	if err := httpCmd.Args(httpCmd, nil); err == nil {
+		t.Error("http accepted no port")
+	}
+}
+
+func TestRequestedDomain(t *testing.T) {
+	tests := []struct {
+		args    []string
+		want    string
+		wantErr bool
+	}{
+		{nil, "", false},
+		{[]string{"--subdomain=misty-river"}, "misty-river", false},
+		{[]string{"--domain=misty-river.tunnel.example.com"}, "misty-river.tunnel.example.com", false},
+		{[]string{"--subdomain=Misty_River"}, "Misty_River", true},
+		{[]string{"--domain=misty-river"}, "misty-river", true},
+	}
+	for _, tt := range tests {
+		cmd := &cobra.Command{}
+		cmd.Flags().String("subdomain", "", "")
+		cmd.Flags().String("domain", "", "")
+		if err := cmd.Flags().Parse(tt.args); err != nil {
+			t.Fatalf("Parse(%v): %v", tt.args, err)
+		}
+		got, err := requestedDomain(cmd)
+		if got != tt.want || (err != nil) != tt.wantErr {
+			t.Errorf("requestedDomain(%v) = %q, %v", tt.args, got, err)
+		}
+	}
+
+	// tcp has neither flag
+	if got, err := requestedDomain(tcpCmd); got != "" || err != nil {
+		t.Errorf("requestedDomain(tcp) = %q, %v", got, err)
+	}
+}
+
+func TestWizardProjectConfig(t *testing.T) {
+	path := t.TempDir() + "/gopublic.yaml"
+	data := wizardProjectConfig(&tui.WizardResult{Port: "3000", Subdomain: "misty-river"})
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.LoadProjectConfig(path)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() error = %v\n%s", err, data)
+	}
+	web := cfg.Tunnels["web"]
+	if web == nil || web.Addr != "3000" || web.Subdomain != "misty-river" || web.Proto != protocol.ProtoHTTP {
+		t.Errorf("tunnels = %+v", cfg.Tunnels)
+	}
+}
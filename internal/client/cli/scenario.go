@@ -0,0 +1,143 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/inspector"
+
+	"github.com/spf13/cobra"
+)
+
+var scenarioCmd = &cobra.Command{
+	Use:   "scenario",
+	Short: "List and run the inspector's multi-step scenarios",
+	Long: `Scenarios are ordered requests copied from captured exchanges, with
+variables extracted from one step's response and used as {{name}} in the
+next. Build them with POST /api/scenarios on the running client's
+inspector; they are kept in ~/.config/gopublic/inspector-scenarios.json.`,
+}
+
+var scenarioListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the scenarios",
+	Args:  cobra.NoArgs,
+	Run:   runScenarioList,
+}
+
+var scenarioRunCmd = &cobra.Command{
+	Use:   "run <name>",
+	Short: "Run a scenario against the local app and report each step",
+	Args:  cobra.ExactArgs(1),
+	Run:   runScenarioRun,
+}
+
+func init() {
+	scenarioCmd.PersistentFlags().String("inspector", "http://localhost:4040", "Inspector of the running client")
+	scenarioRunCmd.Flags().String("target", "", "Base URL to send every step to instead of its local port")
+	scenarioRunCmd.Flags().StringArray("var", nil, "Set a variable before the first step, as name=value (repeatable)")
+	scenarioCmd.AddCommand(scenarioListCmd, scenarioRunCmd)
+}
+
+func runScenarioList(cmd *cobra.Command, args []string) {
+	inspectorURL, _ := cmd.Flags().GetString("inspector")
+	var list struct {
+		Scenarios []inspector.Scenario `json:"scenarios"`
+	}
+	if err := callInspector(context.Background(), inspectorURL, http.MethodGet, "/api/scenarios", nil, &list); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("scenario.error", err))
+		os.Exit(1)
+	}
+	if len(list.Scenarios) == 0 {
+		fmt.Println(i18n.T("scenario.none"))
+		return
+	}
+	for _, sc := range list.Scenarios {
+		fmt.Println(i18n.T("scenario.list_item", sc.Name, len(sc.Steps)))
+	}
+}
+
+func runScenarioRun(cmd *cobra.Command, args []string) {
+	inspectorURL, _ := cmd.Flags().GetString("inspector")
+	target, _ := cmd.Flags().GetString("target")
+	vars, _ := cmd.Flags().GetStringArray("var")
+
+	run := inspector.ScenarioRun{Target: target, Vars: make(map[string]string)}
+	for _, v := range vars {
+		name, value, ok := strings.Cut(v, "=")
+		if !ok || name == "" {
+			fmt.Fprintln(os.Stderr, i18n.T("scenario.invalid_var", v))
+			os.Exit(1)
+		}
+		run.Vars[name] = value
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	var result inspector.ScenarioResult
+	path := "/api/scenarios/" + url.PathEscape(args[0]) + "/run"
+	if err := callInspector(ctx, inspectorURL, http.MethodPost, path, run, &result); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("scenario.error", err))
+		os.Exit(1)
+	}
+	printScenarioResult(&result)
+	if !result.Passed {
+		os.Exit(1)
+	}
+}
+
+// printScenarioResult prints one line per step, with its failed
+// assertions and extracted variables.
+func printScenarioResult(result *inspector.ScenarioResult) {
+	for i, step := range result.Steps {
+		mark := "✓"
+		if !step.Passed {
+			mark = "✗"
+		}
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		fmt.Println(i18n.T("scenario.step", mark, name, step.Method, step.URL, step.Status, step.DurationMS))
+		for _, a := range step.Assertions {
+			if !a.Pass {
+				fmt.Println(i18n.T("scenario.assertion_failed", describeAssertion(a.Assertion), a.Actual+a.Error))
+			}
+		}
+		names := make([]string, 0, len(step.Extracted))
+		for name := range step.Extracted {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Println(i18n.T("scenario.extracted", name, step.Extracted[name]))
+		}
+		if step.Error != "" {
+			fmt.Println(i18n.T("scenario.step_error", step.Error))
+		}
+	}
+	if result.Passed {
+		fmt.Println(i18n.T("scenario.passed", result.Scenario))
+	} else {
+		fmt.Println(i18n.T("scenario.failed", result.Scenario))
+	}
+}
+
+// describeAssertion renders an assertion as in the inspector UI.
+func describeAssertion(a inspector.Assertion) string {
+	switch {
+	case a.Status != 0:
+		return fmt.Sprintf("status == %d", a.Status)
+	case a.BodyContains != "":
+		return "body contains " + a.BodyContains
+	}
+	return a.JSONPath + " == " + string(a.Equals)
+}
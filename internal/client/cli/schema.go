@@ -0,0 +1,45 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var schemaCmd = &cobra.Command{
+	Use:   "schema",
+	Short: "Print the JSON Schema of gopublic.yaml for editor completion and validation",
+	Long: "Prints the JSON Schema of gopublic.yaml. Save it and add\n" +
+		"  # yaml-language-server: $schema=./gopublic.schema.json\n" +
+		"at the top of gopublic.yaml to get completion and validation in editors using the YAML language server.",
+	Args: cobra.NoArgs,
+	Run:  runSchema,
+}
+
+func init() {
+	schemaCmd.Flags().StringP("output", "o", "", "Write the schema to this file instead of stdout")
+}
+
+func runSchema(cmd *cobra.Command, args []string) {
+	schema, err := config.ProjectSchema()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.error", err))
+		os.Exit(1)
+	}
+	schema = append(schema, '\n')
+
+	output, _ := cmd.Flags().GetString("output")
+	if output == "" {
+		os.Stdout.Write(schema)
+		return
+	}
+	if err := os.WriteFile(output, schema, 0644); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.error", err))
+		os.Exit(1)
+	}
+	fmt.Println(i18n.T("cli.schema_saved", output))
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/diagnose"
+	"gopublic/internal/client/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var speedtestCmd = &cobra.Command{
+	Use:   "speedtest",
+	Short: "Measure latency and throughput between this machine and the server",
+	Args:  cobra.NoArgs,
+	Run:   runSpeedtest,
+}
+
+func init() {
+	speedtestCmd.Flags().Int("size", diagnose.DefaultSpeedtestBytes>>20, "Payload per direction in MiB")
+	speedtestCmd.Flags().Int("pings", diagnose.DefaultSpeedtestPings, "Number of latency samples")
+	addTLSFlags(speedtestCmd)
+}
+
+func runSpeedtest(cmd *cobra.Command, args []string) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_load_error", err))
+		os.Exit(1)
+	}
+	if cfg.Token == "" {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.no_token"))
+		os.Exit(1)
+	}
+
+	tlsCfg, err := tlsConfig(cmd, cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.invalid_ca_cert", err))
+		os.Exit(1)
+	}
+	tlsClientCfg, _ := tlsCfg.ClientConfig()
+
+	size, _ := cmd.Flags().GetInt("size")
+	pings, _ := cmd.Flags().GetInt("pings")
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	fmt.Println(i18n.T("speedtest.title", ServerAddr))
+	result, err := diagnose.Speedtest(ctx, diagnose.SpeedtestOptions{
+		ServerAddr: ServerAddr,
+		Token:      cfg.Token,
+		Bytes:      int64(size) << 20,
+		Pings:      pings,
+		TLS:        tlsClientCfg,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("speedtest.error", err))
+		os.Exit(1)
+	}
+
+	fmt.Println()
+	fmt.Println(i18n.T("speedtest.latency",
+		result.LatencyMin.Round(100*time.Microsecond),
+		result.LatencyAvg.Round(100*time.Microsecond),
+		result.LatencyMax.Round(100*time.Microsecond)))
+	fmt.Println(i18n.T("speedtest.download", result.Download.Mbps(), result.Download.Bytes>>20, result.Download.Duration.Round(time.Millisecond)))
+	fmt.Println(i18n.T("speedtest.upload", result.Upload.Mbps(), result.Upload.Bytes>>20, result.Upload.Duration.Round(time.Millisecond)))
+	fmt.Println()
+	fmt.Println(i18n.T("speedtest.hint"))
+}
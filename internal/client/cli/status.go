@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"gopublic/internal/client/control"
+	"gopublic/internal/client/i18n"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the tunnels, connection and traffic of the running client",
+	Args:  cobra.NoArgs,
+	Run:   runStatus,
+}
+
+func init() {
+	statusCmd.Flags().Bool("json", false, "Print the status as JSON")
+}
+
+func runStatus(cmd *cobra.Command, args []string) {
+	status, err := controlClient().Status(context.Background())
+	if errors.Is(err, control.ErrNotRunning) {
+		fmt.Fprintln(os.Stderr, i18n.T("status.not_running"))
+		os.Exit(1)
+	} else if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.error", err))
+		os.Exit(1)
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		enc.Encode(status)
+		return
+	}
+	printStatus(status)
+}
+
+// stateText returns the translated connection state.
+func stateText(status *control.Status) string {
+	switch status.State {
+	case control.StateConnected:
+		return i18n.T("status.online")
+	case control.StateReconnecting:
+		return i18n.T("status.reconnecting") + " " + i18n.T("status.attempt", status.Attempt)
+	case control.StateDisconnected:
+		return i18n.T("status.offline")
+	default:
+		return i18n.T("status.connecting")
+	}
+}
+
+// printStatus renders the status as aligned tables: the session, then the
+// tunnels.
+func printStatus(status *control.Status) {
+	uptime := time.Duration(status.UptimeSeconds) * time.Second
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\n", i18n.T("status.state"), stateText(status))
+	fmt.Fprintf(w, "%s\t%s\n", i18n.T("status.server"), status.Server)
+	fmt.Fprintf(w, "%s\t%s (PID %d)\n", i18n.T("tui.version"), status.Version, status.PID)
+	fmt.Fprintf(w, "%s\t%s\n", i18n.T("status.uptime"), uptime)
+	if status.ConnectedAt != nil {
+		fmt.Fprintf(w, "%s\t%s\n", i18n.T("status.connected_for"), time.Since(*status.ConnectedAt).Round(time.Second))
+	}
+	fmt.Fprintf(w, "%s\t%.0fms\n", i18n.T("tui.latency"), status.Stats.ServerLatencyMs)
+	fmt.Fprintf(w, "%s\t%s\n", i18n.T("tui.connections"),
+		i18n.T("status.connections", status.Stats.TotalConnections, status.Stats.OpenConnections))
+	fmt.Fprintf(w, "%s\t%s\n", i18n.T("status.requests"),
+		i18n.T("status.request_stats", status.Stats.TotalRequests, formatBytes(status.Stats.TotalBytes), status.Stats.P50Ms, status.Stats.P90Ms))
+	w.Flush()
+
+	fmt.Println()
+	if len(status.Tunnels) == 0 {
+		fmt.Println(i18n.T("status.no_tunnels"))
+		return
+	}
+	w = tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "%s\t%s\t%s\n", i18n.T("status.tunnel"), i18n.T("status.public_url"), i18n.T("status.local"))
+	for _, t := range status.Tunnels {
+		name := t.Name
+		if name == "" {
+			name = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", name, t.PublicURL, t.LocalPort)
+	}
+	w.Flush()
+}
+
+// formatBytes renders a byte count with a binary unit, e.g. 1.5 MB.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,130 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/telemetry"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var telemetryCmd = &cobra.Command{
+	Use:   "telemetry",
+	Short: "Manage anonymous usage reports (off by default)",
+}
+
+var telemetryOnCmd = &cobra.Command{
+	Use:   "on",
+	Short: "Send an anonymous usage report when a tunnel stops",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setTelemetry(true)
+	},
+}
+
+var telemetryOffCmd = &cobra.Command{
+	Use:   "off",
+	Short: "Stop sending usage reports and forget the install ID",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		setTelemetry(false)
+	},
+}
+
+var telemetryStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether usage reports are sent, where, and what they contain",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.config_load_error", err))
+			os.Exit(1)
+		}
+		printTelemetryStatus(cfg)
+	},
+}
+
+func init() {
+	telemetryCmd.AddCommand(telemetryOnCmd, telemetryOffCmd, telemetryStatusCmd)
+}
+
+func setTelemetry(enabled bool) {
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_load_error", err))
+		os.Exit(1)
+	}
+	cfg.Telemetry = enabled
+	if !enabled {
+		cfg.TelemetryID = ""
+	} else if cfg.TelemetryID == "" {
+		cfg.TelemetryID = telemetry.NewID()
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_save_error", err))
+		os.Exit(1)
+	}
+	printTelemetryStatus(cfg)
+}
+
+func printTelemetryStatus(cfg *config.Config) {
+	if !cfg.Telemetry {
+		fmt.Println(i18n.T("telemetry.off"))
+		return
+	}
+	fmt.Println(i18n.T("telemetry.on"))
+	if endpoint := telemetry.Endpoint(cfg); endpoint != "" {
+		fmt.Println(i18n.T("telemetry.endpoint", endpoint))
+	} else {
+		fmt.Println(i18n.T("telemetry.no_endpoint"))
+	}
+
+	// Show an example next to the last report, so nothing sent is a surprise
+	example := telemetry.NewCollector()
+	example.Feature("tui")
+	example.Feature("flag:force")
+	example.Error("network_unreachable")
+	payload, _ := json.MarshalIndent(example.Report(cfg.TelemetryID), "", "  ")
+	fmt.Printf("\n%s\n%s\n", i18n.T("telemetry.example"), payload)
+
+	last, err := telemetry.LastSent()
+	switch {
+	case err != nil:
+		fmt.Printf("\n%s\n", i18n.T("cli.error", err))
+	case last == nil:
+		fmt.Printf("\n%s\n", i18n.T("telemetry.nothing_sent"))
+	default:
+		fmt.Printf("\n%s\n%s\n", i18n.T("telemetry.last_sent"), last)
+	}
+}
+
+// trackUsage records the features a start command uses: flag names, never
+// their values.
+func trackUsage(usage *telemetry.Collector, cmd *cobra.Command, useTUI, multiTunnel bool) {
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		usage.Feature("flag:" + f.Name)
+	})
+	if useTUI {
+		usage.Feature("tui")
+	}
+	if multiTunnel {
+		usage.Feature("gopublic.yaml")
+	}
+}
+
+// sendUsage sends the usage report of a start command if the user opted
+// in. Failures are ignored: reports must never get in the way.
+func sendUsage(cfg *config.Config, usage *telemetry.Collector) {
+	endpoint := telemetry.Endpoint(cfg)
+	if !cfg.Telemetry || endpoint == "" || cfg.TelemetryID == "" {
+		return
+	}
+	telemetry.Send(context.Background(), endpoint, usage.Report(cfg.TelemetryID))
+}
@@ -0,0 +1,110 @@
+package cli
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/tunnel"
+
+	"github.com/spf13/cobra"
+)
+
+var trustCmd = &cobra.Command{
+	Use:   "trust [server]",
+	Short: "Fetch the server certificate and pin it for later connections",
+	Long: "Connects to the server (the built-in one by default), shows its certificate and pins its public key " +
+		"in the user config. Later connections accept that key only, so self-signed servers work without --insecure.",
+	Args: cobra.MaximumNArgs(1),
+	Run:  runTrust,
+}
+
+func init() {
+	trustCmd.Flags().Duration("timeout", 10*time.Second, "Connect timeout")
+	trustCmd.Flags().Bool("remove", false, "Forget the pin of the server instead")
+}
+
+// addTLSFlags adds the flags that control how the server is verified.
+func addTLSFlags(cmd *cobra.Command) {
+	cmd.Flags().String("ca-cert", "", "PEM file with extra CA certificates to verify the server with")
+	cmd.Flags().Bool("insecure", false, "Skip server certificate verification (development only)")
+}
+
+// tlsConfig builds the server verification from the TLS flags and the pin
+// saved by "gopublic trust" for ServerAddr, if any.
+func tlsConfig(cmd *cobra.Command, cfg *config.Config) (*tunnel.TLSConfig, error) {
+	insecure, _ := cmd.Flags().GetBool("insecure")
+	caCert, _ := cmd.Flags().GetString("ca-cert")
+	tc := &tunnel.TLSConfig{InsecureSkipVerify: insecure}
+	if caCert != "" {
+		pool, err := tunnel.LoadCAFile(caCert)
+		if err != nil {
+			return nil, err
+		}
+		tc.RootCAs = pool
+	}
+	if cfg != nil && !insecure {
+		tc.Pin = cfg.TLSPins[ServerAddr]
+	}
+	return tc, nil
+}
+
+func runTrust(cmd *cobra.Command, args []string) {
+	server := ServerAddr
+	if len(args) == 1 {
+		server = args[0]
+	}
+	cfg, err := config.LoadConfig()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_load_error", err))
+		os.Exit(1)
+	}
+
+	if remove, _ := cmd.Flags().GetBool("remove"); remove {
+		delete(cfg.TLSPins, server)
+		if err := config.SaveConfig(cfg); err != nil {
+			fmt.Fprintln(os.Stderr, i18n.T("cli.config_save_error", err))
+			os.Exit(1)
+		}
+		fmt.Println(i18n.T("trust.removed", server))
+		return
+	}
+
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	// Anything is accepted here: the user checks the certificate shown
+	dialer := &tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", server)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("trust.error", server, err))
+		os.Exit(1)
+	}
+	state := conn.(*tls.Conn).ConnectionState()
+	conn.Close()
+	cert := state.PeerCertificates[0]
+	pin := tunnel.KeyPin(cert)
+
+	fmt.Println(i18n.T("trust.subject", cert.Subject))
+	fmt.Println(i18n.T("trust.issuer", cert.Issuer))
+	fmt.Println(i18n.T("trust.expires", cert.NotAfter.Format(time.DateOnly)))
+	fmt.Println(i18n.T("trust.pin", pin))
+	if host, _, err := net.SplitHostPort(server); err == nil && cert.VerifyHostname(host) != nil {
+		fmt.Println(i18n.T("trust.host_mismatch", host))
+	}
+
+	if cfg.TLSPins == nil {
+		cfg.TLSPins = make(map[string]string)
+	}
+	cfg.TLSPins[server] = pin
+	if err := config.SaveConfig(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("cli.config_save_error", err))
+		os.Exit(1)
+	}
+	fmt.Println(i18n.T("trust.saved", server))
+}
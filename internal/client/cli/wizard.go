@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/tui"
+
+	"golang.org/x/term"
+)
+
+// projectConfigFile is the gopublic.yaml of the current directory.
+const projectConfigFile = "gopublic.yaml"
+
+// canRunWizard reports whether "gopublic start" without a token may ask
+// for one: there is someone at the terminal and no gopublic.yaml that
+// the wizard could have been meant to skip.
+func canRunWizard() bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) || !term.IsTerminal(int(os.Stdout.Fd())) {
+		return false
+	}
+	_, err := os.Stat(projectConfigFile)
+	return errors.Is(err, fs.ErrNotExist)
+}
+
+// runWizard runs the first-run wizard, then saves the token and server to
+// the user config and the tunnel to gopublic.yaml. cfg is updated so that
+// the start can go on.
+func runWizard(cfg *config.Config, args []string) error {
+	defaults := tui.WizardResult{Server: ServerAddr, Port: "3000"}
+	if len(args) > 0 {
+		defaults.Port = args[0]
+	}
+	result, err := tui.RunWizard(defaults)
+	if err != nil {
+		return err
+	}
+
+	cfg.Token = result.Token
+	if result.Server != ServerAddr {
+		cfg.Server = result.Server
+		ServerAddr = result.Server
+	}
+	if err := config.SaveConfig(cfg); err != nil {
+		return errors.New(i18n.T("cli.config_save_error", err))
+	}
+	path, _ := config.GetConfigPath()
+	fmt.Println(i18n.T("cli.token_saved", path))
+
+	if err := os.WriteFile(projectConfigFile, wizardProjectConfig(result), 0644); err != nil {
+		return err
+	}
+	fmt.Println(i18n.T("cli.wizard_project_saved", projectConfigFile))
+	return nil
+}
+
+// wizardProjectConfig returns the gopublic.yaml with the tunnel chosen in
+// the wizard. It is written by hand to leave out the unset sections.
+func wizardProjectConfig(result *tui.WizardResult) []byte {
+	return []byte(fmt.Sprintf(`version: "1"
+tunnels:
+  web:
+    proto: http
+    addr: %s
+    subdomain: %s
+`, strconv.Quote(result.Port), result.Subdomain))
+}
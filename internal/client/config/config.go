@@ -1,35 +1,275 @@
 package config
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
 
 type Config struct {
-	Token string `yaml:"token"`
+	Token        string `yaml:"token"`
+	Server       string `yaml:"server,omitempty"`        // host:port, overrides the built-in server address
+	RefreshToken string `yaml:"refresh_token,omitempty"` // Renews an expiring Token, see "gopublic auth --refresh"
+	Language     string `yaml:"language,omitempty"`      // CLI/TUI language (en, ru); defaults to LANG
+
+	// Opt-in usage reports, see "gopublic telemetry"
+	Telemetry         bool   `yaml:"telemetry,omitempty"`
+	TelemetryID       string `yaml:"telemetry_id,omitempty"`       // Random, unrelated to the token
+	TelemetryEndpoint string `yaml:"telemetry_endpoint,omitempty"` // Overrides the built-in endpoint
+
+	// Server address -> SHA-256 of its public key, see "gopublic trust"
+	TLSPins map[string]string `yaml:"tls_pins,omitempty"`
+
+	// Flag name -> value used when the flag is not given, e.g.
+	// reconnect-max-delay: 30s. Environment and gopublic.yaml take precedence.
+	Defaults map[string]string `yaml:"defaults,omitempty"`
 }
 
-// ProjectConfig represents gopublic.yaml project configuration
+// ProjectConfig represents gopublic.yaml project configuration. The desc
+// and schema tags describe the fields for "gopublic schema", see schema.go.
 type ProjectConfig struct {
-	Version string             `yaml:"version"`
-	Tunnels map[string]*Tunnel `yaml:"tunnels"`
+	Version   string             `yaml:"version" desc:"Config format version, currently 1" schema:"type=string|integer"`
+	Labels    map[string]string  `yaml:"labels" desc:"Labels sent to the server to identify the session, e.g. env: staging"`
+	Reconnect *Reconnect         `yaml:"reconnect" desc:"Reconnect policy; overridden by the --reconnect-* flags"`
+	Keepalive *Keepalive         `yaml:"keepalive" desc:"Dead connection detection; overridden by the --keepalive-* flags"`
+	Tunnels   map[string]*Tunnel `yaml:"tunnels" desc:"Tunnels by name, all started by 'gopublic start'"`
+}
+
+// Reconnect overrides the client's reconnect policy; zero fields keep the
+// defaults.
+type Reconnect struct {
+	InitialDelay time.Duration `yaml:"initial_delay" desc:"Delay before the first reconnect attempt, e.g. 1s"`
+	MaxDelay     time.Duration `yaml:"max_delay" desc:"Maximum delay between reconnect attempts, e.g. 60s"`
+	Multiplier   float64       `yaml:"multiplier" desc:"Backoff factor between attempts (default 2)" schema:"minimum=1"`
+	MaxAttempts  int           `yaml:"max_attempts" desc:"Give up after this many failed attempts; 0 never gives up" schema:"minimum=0"`
+	Jitter       float64       `yaml:"jitter" desc:"Randomize each delay by up to this fraction" schema:"minimum=0,maximum=1"`
+	OfflineStart bool          `yaml:"offline_start" desc:"Keep retrying until the first connection, whatever max_attempts"`
+}
+
+// Keepalive overrides how fast a dead connection to the server is
+// detected; zero fields keep the defaults.
+type Keepalive struct {
+	Interval         time.Duration `yaml:"interval" desc:"Ping the server this often, e.g. 5s"`
+	Timeout          time.Duration `yaml:"timeout" desc:"Reconnect if a ping is not answered within this time, e.g. 10s"`
+	HandshakeTimeout time.Duration `yaml:"handshake_timeout" desc:"Reconnect if a handshake or control message is not answered within this time, e.g. 10s"`
+}
+
+// HasTCPTunnels reports whether any tunnel uses proto: tcp.
+func (c *ProjectConfig) HasTCPTunnels() bool {
+	for _, t := range c.Tunnels {
+		if t.Proto == "tcp" {
+			return true
+		}
+	}
+	return false
 }
 
 // Tunnel represents a single tunnel configuration
 type Tunnel struct {
-	Proto     string `yaml:"proto"`     // http, https, tcp
-	Addr      string `yaml:"addr"`      // local port or host:port
-	Subdomain string `yaml:"subdomain"` // subdomain to bind
+	Proto        string        `yaml:"proto" desc:"Tunnel protocol; tcp tunnels must be started alone" schema:"enum=http|https|tcp"`
+	Addr         string        `yaml:"addr" desc:"Local port or host:port of the service" schema:"type=string|integer"`
+	Subdomain    string        `yaml:"subdomain" desc:"Subdomain to bind, e.g. misty-river"`
+	StartTimeout time.Duration `yaml:"start_timeout" desc:"Report the tunnel as failed if not bound by then, e.g. 30s"`
+	BasicAuth    string        `yaml:"basic_auth" desc:"user:pass; visitors must log in before reaching the service" schema:"pattern=^[^:]+:.+$"`
+	RateLimit    float64       `yaml:"rate_limit" desc:"Requests per second the server forwards; more get 429 (default: the server limit)" schema:"minimum=0"`
+	RateBurst    int           `yaml:"rate_burst" desc:"Requests allowed at once on top of rate_limit (default: the rate)" schema:"minimum=0"`
+	Filters      []string      `yaml:"filters" desc:"WASM filter modules applied in order, reloaded when changed"`
+
+	// Serve the service under a path prefix of the subdomain, e.g. /app, so
+	// several services can share one domain. The prefix is stripped toward
+	// the service unless keep_mount_path is set.
+	MountPath     string `yaml:"mount_path" desc:"Serve the service under this path prefix of the subdomain, e.g. /app" schema:"pattern=^/"`
+	KeepMountPath bool   `yaml:"keep_mount_path" desc:"Forward mount_path to the service instead of stripping it"`
+
+	RequestHeaders  *HeaderRules `yaml:"request_headers" desc:"Headers to add to or remove from requests before they reach the service"`
+	ResponseHeaders *HeaderRules `yaml:"response_headers" desc:"Headers to add to or remove from responses of the service, e.g. CORS headers"`
+}
+
+// HeaderRules rewrite the headers of requests or responses of a tunnel.
+// Removal runs first, so a header can be replaced by removing and adding it.
+type HeaderRules struct {
+	Add    map[string]string `yaml:"add" desc:"Headers to set, replacing existing values, e.g. X-Forwarded-Proto: https"`
+	Remove []string          `yaml:"remove" desc:"Headers to remove, e.g. Cookie"`
+}
+
+// framingHeaders are managed by the client and cannot be rewritten.
+var framingHeaders = map[string]bool{
+	"Content-Length":    true,
+	"Transfer-Encoding": true,
+	"Connection":        true,
+	"Host":              true,
+}
+
+// validate rejects header names that are not HTTP tokens or that frame
+// the message.
+func (r *HeaderRules) validate() error {
+	if r == nil {
+		return nil
+	}
+	names := append([]string(nil), r.Remove...)
+	for name := range r.Add {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if name == "" || strings.ContainsFunc(name, func(c rune) bool {
+			return c <= ' ' || c >= 0x7f || strings.ContainsRune(`"(),/:;<=>?@[\]{}`, c)
+		}) {
+			return fmt.Errorf("invalid header name %q", name)
+		}
+		if framingHeaders[http.CanonicalHeaderKey(name)] {
+			return fmt.Errorf("header %s cannot be rewritten", http.CanonicalHeaderKey(name))
+		}
+	}
+	return nil
+}
+
+// validate rejects values the schema of a field does not allow and that
+// YAML decoding cannot catch.
+func (c *ProjectConfig) validate() error {
+	for name, t := range c.Tunnels {
+		if t == nil {
+			continue
+		}
+		switch t.Proto {
+		case "", "http", "https", "tcp":
+		default:
+			return fmt.Errorf("tunnel '%s': unknown proto %q, use http, https or tcp", name, t.Proto)
+		}
+		if t.Proto == "tcp" && (t.RequestHeaders != nil || t.ResponseHeaders != nil) {
+			return fmt.Errorf("tunnel '%s': header rules are not supported for tcp tunnels", name)
+		}
+		if t.Proto == "tcp" && len(t.Filters) > 0 {
+			return fmt.Errorf("tunnel '%s': filters are not supported for tcp tunnels", name)
+		}
+		if err := t.RequestHeaders.validate(); err != nil {
+			return fmt.Errorf("tunnel '%s': request_headers: %w", name, err)
+		}
+		if err := t.ResponseHeaders.validate(); err != nil {
+			return fmt.Errorf("tunnel '%s': response_headers: %w", name, err)
+		}
+	}
+	return c.validateMounts()
+}
+
+// validateMounts rejects mount options the tunnels cannot honor: mounts on
+// TCP tunnels and two tunnels serving the same path of a subdomain.
+func (c *ProjectConfig) validateMounts() error {
+	served := make(map[string]string) // subdomain + path -> tunnel name
+	names := make([]string, 0, len(c.Tunnels))
+	for name := range c.Tunnels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		t := c.Tunnels[name]
+		if t == nil {
+			continue
+		}
+		path := strings.Trim(t.MountPath, "/")
+		if t.Proto == "tcp" {
+			if path != "" || t.KeepMountPath {
+				return fmt.Errorf("tunnel '%s': mount_path is not supported for tcp tunnels", name)
+			}
+			continue
+		}
+		if t.KeepMountPath && path == "" {
+			return fmt.Errorf("tunnel '%s': keep_mount_path requires mount_path", name)
+		}
+		key := t.Subdomain + "/" + path
+		if other, ok := served[key]; ok {
+			return fmt.Errorf("tunnels '%s' and '%s' both serve %s/%s", other, name, t.Subdomain, path)
+		}
+		served[key] = name
+	}
+	return nil
 }
 
+// GetConfigPath returns the user config path:
+// ~/.config/gopublic/config.yaml on Unix ($XDG_CONFIG_HOME), where a
+// legacy ~/.gopublic is moved, and %APPDATA%\gopublic\config.yaml on
+// Windows.
 func GetConfigPath() (string, error) {
-	home, err := os.UserHomeDir()
+	return configPath()
+}
+
+// StateDir returns the directory of the files the client keeps between
+// runs: ~/.local/state/gopublic on Unix ($XDG_STATE_HOME),
+// %APPDATA%\gopublic on Windows.
+func StateDir() (string, error) {
+	return stateDir()
+}
+
+// CacheDir returns the directory of files that may be deleted at any
+// time: ~/.cache/gopublic on Unix ($XDG_CACHE_HOME), %LOCALAPPDATA%\gopublic
+// on Windows.
+func CacheDir() (string, error) {
+	return cacheDir()
+}
+
+// CrashDir returns the directory of crash reports: crash in StateDir.
+func CrashDir() (string, error) {
+	dir, err := stateDir()
 	if err != nil {
 		return "", err
 	}
-	return filepath.Join(home, ".gopublic"), nil
+	return filepath.Join(dir, "crash"), nil
+}
+
+// TelemetryPath returns where the last telemetry report sent is kept for
+// "gopublic telemetry status": telemetry.json in StateDir.
+func TelemetryPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "telemetry.json"), nil
+}
+
+// InspectorDBPath returns where the inspector keeps captured traffic with
+// --persist-inspector: inspector.db in StateDir.
+func InspectorDBPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "inspector.db"), nil
+}
+
+// InspectorPrefsPath returns where the inspector UI keeps its preferences:
+// inspector-prefs.json next to the config.
+func InspectorPrefsPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "inspector-prefs.json"), nil
+}
+
+// InspectorScenariosPath returns where the inspector keeps its scenarios:
+// inspector-scenarios.json next to the config.
+func InspectorScenariosPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "inspector-scenarios.json"), nil
+}
+
+// ControlSocketPath returns where the running client serves its control
+// API: the unix socket control.sock in StateDir, or the named pipe
+// \\.\pipe\gopublic-<user> on Windows.
+func ControlSocketPath() (string, error) {
+	return controlPath()
 }
 
 func LoadConfig() (*Config, error) {
@@ -65,6 +305,9 @@ func SaveConfig(cfg *Config) error {
 		return err
 	}
 
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
 	return os.WriteFile(path, data, 0600)
 }
 
@@ -80,8 +323,13 @@ func LoadProjectConfig(path string) (*ProjectConfig, error) {
 	}
 
 	var cfg ProjectConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true) // Typos such as subdomian: must not be ignored
+	if err := decoder.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, describeYAMLError(path, err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
 	}
 
 	return &cfg, nil
@@ -1,9 +1,12 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestLoadProjectConfig(t *testing.T) {
@@ -63,6 +66,47 @@ tunnels:
 	}
 }
 
+func TestLoadProjectConfig_Reconnect(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "gopublic.yaml")
+	configContent := `version: "1"
+reconnect:
+  initial_delay: 500ms
+  max_delay: 2m
+  max_attempts: 10
+  jitter: 0.2
+  offline_start: true
+tunnels:
+  frontend:
+    addr: "3000"
+    subdomain: misty-river
+    start_timeout: 30s
+    basic_auth: "demo:s3cret"
+    rate_limit: 2.5
+    rate_burst: 10
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	cfg, err := LoadProjectConfig(configPath)
+	if err != nil {
+		t.Fatalf("LoadProjectConfig() error = %v", err)
+	}
+	want := Reconnect{InitialDelay: 500 * time.Millisecond, MaxDelay: 2 * time.Minute, MaxAttempts: 10, Jitter: 0.2, OfflineStart: true}
+	if cfg.Reconnect == nil || *cfg.Reconnect != want {
+		t.Errorf("Reconnect = %+v, want %+v", cfg.Reconnect, want)
+	}
+	if got := cfg.Tunnels["frontend"].StartTimeout; got != 30*time.Second {
+		t.Errorf("StartTimeout = %v, want 30s", got)
+	}
+	if got := cfg.Tunnels["frontend"].BasicAuth; got != "demo:s3cret" {
+		t.Errorf("BasicAuth = %q, want %q", got, "demo:s3cret")
+	}
+	if got := cfg.Tunnels["frontend"]; got.RateLimit != 2.5 || got.RateBurst != 10 {
+		t.Errorf("RateLimit, RateBurst = %v, %d, want 2.5, 10", got.RateLimit, got.RateBurst)
+	}
+}
+
 func TestLoadProjectConfig_NotFound(t *testing.T) {
 	_, err := LoadProjectConfig("/nonexistent/path/gopublic.yaml")
 	if err == nil {
@@ -85,6 +129,63 @@ func TestLoadProjectConfig_InvalidYAML(t *testing.T) {
 	}
 }
 
+func TestLoadProjectConfig_UnknownKeys(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "gopublic.yaml")
+	content := `version: "1"
+tunnels:
+  web:
+    addr: "3000"
+    subdomian: misty-river
+  api:
+    adr: "8080"
+reconect:
+  max_delay: 30s
+`
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	_, err := LoadProjectConfig(configPath)
+	var cfgErr *ProjectConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("LoadProjectConfig() error = %v, want a ProjectConfigError", err)
+	}
+	want := []string{
+		`line 5: unknown key "subdomian" in tunnel, did you mean "subdomain"?`,
+		`line 7: unknown key "adr" in tunnel, did you mean "addr"?`,
+		`line 8: unknown key "reconect" in gopublic.yaml, did you mean "reconnect"?`,
+	}
+	if strings.Join(cfgErr.Problems, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Problems =\n%s\nwant\n%s", strings.Join(cfgErr.Problems, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestLoadProjectConfig_Empty(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "gopublic.yaml")
+	if err := os.WriteFile(configPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+	if _, err := LoadProjectConfig(configPath); err != nil {
+		t.Errorf("LoadProjectConfig() error = %v for an empty file", err)
+	}
+}
+
+func TestClosest(t *testing.T) {
+	candidates := []string{"proto", "addr", "subdomain", "start_timeout", "basic_auth"}
+	tests := []struct{ key, want string }{
+		{"subdomian", "subdomain"},
+		{"adress", ""},
+		{"basicauth", "basic_auth"},
+		{"port", ""},
+		{"hostname", ""},
+	}
+	for _, tt := range tests {
+		if got := closest(tt.key, candidates); got != tt.want {
+			t.Errorf("closest(%q) = %q, want %q", tt.key, got, tt.want)
+		}
+	}
+}
+
 func TestConfig_SaveAndLoad(t *testing.T) {
 	// Save original config path
 	origHome := os.Getenv("HOME")
@@ -95,7 +196,7 @@ func TestConfig_SaveAndLoad(t *testing.T) {
 	defer os.Setenv("HOME", origHome)
 
 	// Save config
-	cfg := &Config{Token: "test-token-123"}
+	cfg := &Config{Token: "test-token-123", TLSPins: map[string]string{"tunnel.example.com:4443": "ab12"}}
 	if err := SaveConfig(cfg); err != nil {
 		t.Fatalf("SaveConfig() error = %v", err)
 	}
@@ -109,4 +210,137 @@ func TestConfig_SaveAndLoad(t *testing.T) {
 	if loaded.Token != cfg.Token {
 		t.Errorf("Token = %s, want %s", loaded.Token, cfg.Token)
 	}
+	if got := loaded.TLSPins["tunnel.example.com:4443"]; got != "ab12" {
+		t.Errorf("TLSPins = %v, want the saved pin", loaded.TLSPins)
+	}
+}
+
+func TestLoadProjectConfig_MountPath(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"shared domain", `tunnels:
+  web:
+    addr: "3000"
+    subdomain: misty-river
+  api:
+    addr: "8080"
+    subdomain: misty-river
+    mount_path: /api
+    keep_mount_path: true
+`, false},
+		{"same path twice", `tunnels:
+  web:
+    addr: "3000"
+    subdomain: misty-river
+    mount_path: /app
+  api:
+    addr: "8080"
+    subdomain: misty-river
+    mount_path: /app/
+`, true},
+		{"same subdomain without mounts", `tunnels:
+  web:
+    addr: "3000"
+    subdomain: misty-river
+  api:
+    addr: "8080"
+    subdomain: misty-river
+`, true},
+		{"tcp", `tunnels:
+  db:
+    proto: tcp
+    addr: "5432"
+    mount_path: /db
+`, true},
+		{"keep without mount", `tunnels:
+  web:
+    addr: "3000"
+    subdomain: misty-river
+    keep_mount_path: true
+`, true},
+		{"unknown proto", `tunnels:
+  web:
+    proto: htpp
+    addr: "3000"
+`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gopublic.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadProjectConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadProjectConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.name == "shared domain" {
+				if api := cfg.Tunnels["api"]; api.MountPath != "/api" || !api.KeepMountPath {
+					t.Errorf("api = %+v", api)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadProjectConfig_HeaderRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr bool
+	}{
+		{"add and remove", `tunnels:
+  web:
+    addr: "3000"
+    request_headers:
+      add:
+        X-Forwarded-Proto: https
+      remove: [Cookie]
+    response_headers:
+      add:
+        Access-Control-Allow-Origin: "*"
+`, false},
+		{"invalid name", `tunnels:
+  web:
+    addr: "3000"
+    request_headers:
+      add:
+        "X Bad": "1"
+`, true},
+		{"framing header", `tunnels:
+  web:
+    addr: "3000"
+    response_headers:
+      remove: [content-length]
+`, true},
+		{"tcp", `tunnels:
+  db:
+    proto: tcp
+    addr: "5432"
+    request_headers:
+      remove: [Cookie]
+`, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "gopublic.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := LoadProjectConfig(path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadProjectConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				web := cfg.Tunnels["web"]
+				if web.RequestHeaders.Add["X-Forwarded-Proto"] != "https" || web.RequestHeaders.Remove[0] != "Cookie" ||
+					web.ResponseHeaders.Add["Access-Control-Allow-Origin"] != "*" {
+					t.Errorf("web = %+v", web)
+				}
+			}
+		})
+	}
 }
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"syscall"
 	"time"
 )
 
@@ -21,11 +20,7 @@ var ErrAlreadyRunning = errors.New("another gopublic instance is already running
 
 // LockFilePath returns the path to the lock file.
 func LockFilePath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
-	}
-	return filepath.Join(home, ".gopublic.lock"), nil
+	return lockPath()
 }
 
 // AcquireLock tries to acquire the lock file.
@@ -97,15 +92,8 @@ func writeLockFile(path string) error {
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(path, data, 0600)
-}
-
-func isProcessRunning(pid int) bool {
-	process, err := os.FindProcess(pid)
-	if err != nil {
-		return false
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
 	}
-	// On Unix, FindProcess always succeeds. Send signal 0 to check if process exists.
-	err = process.Signal(syscall.Signal(0))
-	return err == nil
+	return os.WriteFile(path, data, 0600)
 }
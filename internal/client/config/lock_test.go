@@ -0,0 +1,37 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestIsProcessRunning(t *testing.T) {
+	if !isProcessRunning(os.Getpid()) {
+		t.Error("current process should be reported as running")
+	}
+}
+
+func TestAcquireLock(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("HOME", tmpDir)
+	t.Setenv("USERPROFILE", tmpDir)
+	t.Setenv("APPDATA", tmpDir)
+
+	if err := AcquireLock(); err != nil {
+		t.Fatalf("AcquireLock() error = %v", err)
+	}
+	defer ReleaseLock()
+
+	// The lock is held by this (running) process
+	if err := AcquireLock(); !errors.Is(err, ErrAlreadyRunning) {
+		t.Errorf("second AcquireLock() = %v, want ErrAlreadyRunning", err)
+	}
+
+	if err := ReleaseLock(); err != nil {
+		t.Fatalf("ReleaseLock() error = %v", err)
+	}
+	if err := AcquireLock(); err != nil {
+		t.Errorf("AcquireLock() after release = %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// xdgDir returns the gopublic directory under the XDG base directory set
+// in env, or under fallback in the home directory. Relative paths in env
+// are ignored, as the XDG spec asks.
+func xdgDir(env, fallback string) (string, error) {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, "gopublic"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, fallback, "gopublic"), nil
+}
+
+// configDir returns ~/.config/gopublic ($XDG_CONFIG_HOME), for the config
+// and other files the user may edit or back up.
+func configDir() (string, error) {
+	migrateLegacy()
+	return xdgDir("XDG_CONFIG_HOME", ".config")
+}
+
+// stateDir returns ~/.local/state/gopublic ($XDG_STATE_HOME), for files
+// the client keeps between runs: crash reports, the inspector database,
+// the lock and the control socket.
+func stateDir() (string, error) {
+	migrateLegacy()
+	return xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+}
+
+// cacheDir returns ~/.cache/gopublic ($XDG_CACHE_HOME), for files that may
+// be deleted at any time.
+func cacheDir() (string, error) {
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// configPath returns ~/.config/gopublic/config.yaml.
+func configPath() (string, error) {
+	dir, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// lockPath returns ~/.local/state/gopublic/gopublic.lock.
+func lockPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopublic.lock"), nil
+}
+
+// controlPath returns ~/.local/state/gopublic/control.sock.
+func controlPath() (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "control.sock"), nil
+}
+
+// migrateLegacy moves the files of older versions, the ~/.gopublic config
+// and the ~/.gopublic.d directory, to the XDG directories. Files already
+// at their new place are left alone, so it is cheap to run every time.
+func migrateLegacy() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+	config, err := xdgDir("XDG_CONFIG_HOME", ".config")
+	if err != nil {
+		return
+	}
+	state, err := xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+	if err != nil {
+		return
+	}
+
+	legacyData := filepath.Join(home, ".gopublic.d")
+	moves := []struct {
+		from, to string
+		dir      bool
+	}{
+		{filepath.Join(home, ".gopublic"), filepath.Join(config, "config.yaml"), false},
+		{filepath.Join(legacyData, "inspector-prefs.json"), filepath.Join(config, "inspector-prefs.json"), false},
+		{filepath.Join(legacyData, "inspector-scenarios.json"), filepath.Join(config, "inspector-scenarios.json"), false},
+		{filepath.Join(legacyData, "inspector.db"), filepath.Join(state, "inspector.db"), false},
+		{filepath.Join(legacyData, "telemetry.json"), filepath.Join(state, "telemetry.json"), false},
+		{filepath.Join(legacyData, "crash"), filepath.Join(state, "crash"), true},
+	}
+	for _, m := range moves {
+		moveLegacy(m.from, m.to, m.dir)
+	}
+	// Only goes away once empty, e.g. not with files of a newer version
+	os.Remove(legacyData)
+}
+
+// moveLegacy moves the regular file (or directory, with dir) from to the
+// path to, unless from is missing or of the other kind, or to exists.
+// Files on another file system are copied; directories stay put then.
+func moveLegacy(from, to string, dir bool) {
+	fi, err := os.Lstat(from)
+	if err != nil || fi.IsDir() != dir || (!dir && !fi.Mode().IsRegular()) {
+		return
+	}
+	if _, err := os.Lstat(to); err == nil {
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+		return
+	}
+	if os.Rename(from, to) == nil || dir {
+		return
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(to, data, fi.Mode().Perm()); err != nil {
+		os.Remove(to)
+		return
+	}
+	os.Remove(from)
+}
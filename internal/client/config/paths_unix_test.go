@@ -0,0 +1,109 @@
+//go:build !windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// setHome points the home and XDG directories at a temporary directory.
+func setHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_STATE_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	return home
+}
+
+func TestPaths_Default(t *testing.T) {
+	home := setHome(t)
+
+	tests := []struct {
+		name string
+		fn   func() (string, error)
+		want string
+	}{
+		{"GetConfigPath", GetConfigPath, filepath.Join(home, ".config", "gopublic", "config.yaml")},
+		{"InspectorPrefsPath", InspectorPrefsPath, filepath.Join(home, ".config", "gopublic", "inspector-prefs.json")},
+		{"StateDir", StateDir, filepath.Join(home, ".local", "state", "gopublic")},
+		{"CrashDir", CrashDir, filepath.Join(home, ".local", "state", "gopublic", "crash")},
+		{"LockFilePath", LockFilePath, filepath.Join(home, ".local", "state", "gopublic", "gopublic.lock")},
+		{"CacheDir", CacheDir, filepath.Join(home, ".cache", "gopublic")},
+	}
+	for _, tt := range tests {
+		got, err := tt.fn()
+		if err != nil {
+			t.Fatalf("%s() error = %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s() = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPaths_XDG(t *testing.T) {
+	setHome(t)
+	xdg := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(xdg, "config"))
+	t.Setenv("XDG_STATE_HOME", filepath.Join(xdg, "state"))
+	t.Setenv("XDG_CACHE_HOME", "relative/cache") // Ignored
+
+	if got, _ := GetConfigPath(); got != filepath.Join(xdg, "config", "gopublic", "config.yaml") {
+		t.Errorf("GetConfigPath() = %s", got)
+	}
+	if got, _ := InspectorDBPath(); got != filepath.Join(xdg, "state", "gopublic", "inspector.db") {
+		t.Errorf("InspectorDBPath() = %s", got)
+	}
+	if got, _ := CacheDir(); filepath.Base(filepath.Dir(got)) != ".cache" {
+		t.Errorf("CacheDir() = %s, want the default for a relative XDG_CACHE_HOME", got)
+	}
+}
+
+func TestMigrateLegacy(t *testing.T) {
+	home := setHome(t)
+	legacyData := filepath.Join(home, ".gopublic.d")
+	files := map[string]string{
+		filepath.Join(home, ".gopublic"):                      "token: legacy\n",
+		filepath.Join(legacyData, "inspector-scenarios.json"): "[]",
+		filepath.Join(legacyData, "inspector.db"):             "db",
+		filepath.Join(legacyData, "crash", "crash-1.txt"):     "report",
+	}
+	for path, content := range files {
+		os.MkdirAll(filepath.Dir(path), 0700)
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.Token != "legacy" {
+		t.Errorf("Token = %q, want the legacy config's", cfg.Token)
+	}
+	for _, path := range []string{
+		filepath.Join(home, ".config", "gopublic", "inspector-scenarios.json"),
+		filepath.Join(home, ".local", "state", "gopublic", "inspector.db"),
+		filepath.Join(home, ".local", "state", "gopublic", "crash", "crash-1.txt"),
+	} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("%s not migrated: %v", path, err)
+		}
+	}
+	for _, path := range []string{filepath.Join(home, ".gopublic"), legacyData} {
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("%s left behind", path)
+		}
+	}
+
+	// A legacy file reappearing does not replace the migrated one
+	os.WriteFile(filepath.Join(home, ".gopublic"), []byte("token: other\n"), 0600)
+	if cfg, _ := LoadConfig(); cfg.Token != "legacy" {
+		t.Errorf("Token = %q after a second migration, want the migrated one", cfg.Token)
+	}
+}
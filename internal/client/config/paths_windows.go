@@ -0,0 +1,79 @@
+//go:build windows
+
+package config
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+// appDir returns %APPDATA%\gopublic.
+func appDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopublic"), nil
+}
+
+// configPath returns %APPDATA%\gopublic\config.yaml. A legacy
+// %USERPROFILE%\.gopublic written by older versions is still used if present.
+func configPath() (string, error) {
+	if home, err := os.UserHomeDir(); err == nil {
+		legacy := filepath.Join(home, ".gopublic")
+		if fi, err := os.Stat(legacy); err == nil && fi.Mode().IsRegular() {
+			return legacy, nil
+		}
+	}
+
+	dir, err := appDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// configDir returns %APPDATA%\gopublic.
+func configDir() (string, error) {
+	return appDir()
+}
+
+// stateDir returns %APPDATA%\gopublic, which also holds the config.
+func stateDir() (string, error) {
+	return appDir()
+}
+
+// cacheDir returns %LOCALAPPDATA%\gopublic, which is not roamed.
+func cacheDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopublic"), nil
+}
+
+// lockPath returns %APPDATA%\gopublic\gopublic.lock.
+func lockPath() (string, error) {
+	dir, err := appDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "gopublic.lock"), nil
+}
+
+// controlPath returns the named pipe \\.\pipe\gopublic-<user>. Pipes are
+// not files, so it cannot live in appDir; the user name keeps the clients
+// of several users on one machine apart.
+func controlPath() (string, error) {
+	name := os.Getenv("USERNAME")
+	if u, err := user.Current(); err == nil {
+		name = u.Username // DOMAIN\user
+	}
+	if name == "" {
+		return "", errors.New("cannot determine the user name")
+	}
+	return `\\.\pipe\gopublic-` + strings.ReplaceAll(name, `\`, "."), nil
+}
@@ -0,0 +1,54 @@
+//go:build windows
+
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGetConfigPath_AppData(t *testing.T) {
+	appData := t.TempDir()
+	t.Setenv("APPDATA", appData)
+	t.Setenv("USERPROFILE", t.TempDir())
+
+	path, err := GetConfigPath()
+	if err != nil {
+		t.Fatalf("GetConfigPath() error = %v", err)
+	}
+	if want := filepath.Join(appData, "gopublic", "config.yaml"); path != want {
+		t.Errorf("GetConfigPath() = %s, want %s", path, want)
+	}
+}
+
+func TestGetConfigPath_Legacy(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("APPDATA", t.TempDir())
+	t.Setenv("USERPROFILE", home)
+
+	legacy := filepath.Join(home, ".gopublic")
+	if err := os.WriteFile(legacy, []byte("token: x\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	path, err := GetConfigPath()
+	if err != nil {
+		t.Fatalf("GetConfigPath() error = %v", err)
+	}
+	if path != legacy {
+		t.Errorf("GetConfigPath() = %s, want legacy %s", path, legacy)
+	}
+}
+
+func TestControlSocketPath_NamedPipe(t *testing.T) {
+	path, err := ControlSocketPath()
+	if err != nil {
+		t.Fatalf("ControlSocketPath() error = %v", err)
+	}
+	name, ok := strings.CutPrefix(path, `\\.\pipe\gopublic-`)
+	if !ok || name == "" || strings.Contains(name, `\`) {
+		t.Errorf("ControlSocketPath() = %s, want \\\\.\\pipe\\gopublic-<user>", path)
+	}
+}
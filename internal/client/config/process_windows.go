@@ -0,0 +1,24 @@
+//go:build windows
+
+package config
+
+import "golang.org/x/sys/windows"
+
+// stillActive is the exit code reported for a process that has not exited.
+const stillActive = 259
+
+// isProcessRunning opens the process and checks its exit code, since
+// Windows does not support signal 0.
+func isProcessRunning(pid int) bool {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer windows.CloseHandle(h)
+
+	var code uint32
+	if err := windows.GetExitCodeProcess(h, &code); err != nil {
+		return false
+	}
+	return code == stillActive
+}
@@ -0,0 +1,89 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// durationPattern matches the durations accepted by time.ParseDuration.
+const durationPattern = `^(0|-?([0-9]+(\.[0-9]+)?(ns|us|µs|ms|s|m|h))+)$`
+
+// ProjectSchema returns a JSON Schema (draft 2020-12) of gopublic.yaml,
+// generated from ProjectConfig: yaml tags name the properties, desc tags
+// describe them and schema tags add constraints, e.g.
+// `schema:"enum=http|tcp"`, `schema:"minimum=0,maximum=1"` or
+// `schema:"type=string|integer"`.
+func ProjectSchema() ([]byte, error) {
+	schema := typeSchema(reflect.TypeOf(ProjectConfig{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "gopublic.yaml"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// typeSchema returns the schema of values of type t.
+func typeSchema(t reflect.Type) map[string]any {
+	if t == reflect.TypeOf(time.Duration(0)) {
+		return map[string]any{"type": "string", "pattern": durationPattern}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		return typeSchema(t.Elem())
+	case reflect.Struct:
+		props := make(map[string]any)
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			name, _, _ := strings.Cut(f.Tag.Get("yaml"), ",")
+			if !f.IsExported() || name == "" || name == "-" {
+				continue
+			}
+			props[name] = fieldSchema(f)
+		}
+		return map[string]any{"type": "object", "properties": props, "additionalProperties": false}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Slice:
+		return map[string]any{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+// fieldSchema returns the schema of a struct field, with its desc and
+// schema tags applied.
+func fieldSchema(f reflect.StructField) map[string]any {
+	schema := typeSchema(f.Type)
+	if desc := f.Tag.Get("desc"); desc != "" {
+		schema["description"] = desc
+	}
+	constraints := f.Tag.Get("schema")
+	if constraints == "" {
+		return schema
+	}
+	for _, c := range strings.Split(constraints, ",") {
+		key, value, _ := strings.Cut(c, "=")
+		switch key {
+		case "enum":
+			schema["enum"] = strings.Split(value, "|")
+		case "type":
+			schema["type"] = strings.Split(value, "|")
+		case "minimum", "maximum":
+			n, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				panic("config: bad schema tag on " + f.Name + ": " + c)
+			}
+			schema[key] = n
+		default:
+			schema[key] = value
+		}
+	}
+	return schema
+}
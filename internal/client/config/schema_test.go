@@ -0,0 +1,45 @@
+package config
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestProjectSchema(t *testing.T) {
+	data, err := ProjectSchema()
+	if err != nil {
+		t.Fatalf("ProjectSchema() error = %v", err)
+	}
+	var schema map[string]any
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("schema is not JSON: %v", err)
+	}
+
+	props := schema["properties"].(map[string]any)
+	tunnel := props["tunnels"].(map[string]any)["additionalProperties"].(map[string]any)
+	reconnect := props["reconnect"].(map[string]any)
+	for _, object := range []map[string]any{schema, tunnel, reconnect} {
+		if object["additionalProperties"] != false {
+			t.Errorf("object allows unknown properties: %v", object)
+		}
+		for name, prop := range object["properties"].(map[string]any) {
+			if prop.(map[string]any)["description"] == nil {
+				t.Errorf("property %q has no description", name)
+			}
+		}
+	}
+
+	tunnelProps := tunnel["properties"].(map[string]any)
+	proto := tunnelProps["proto"].(map[string]any)
+	if got, _ := json.Marshal(proto["enum"]); string(got) != `["http","https","tcp"]` {
+		t.Errorf("proto enum = %s", got)
+	}
+	timeout := tunnelProps["start_timeout"].(map[string]any)
+	if timeout["type"] != "string" || timeout["pattern"] == nil {
+		t.Errorf("start_timeout = %v, want a duration string", timeout)
+	}
+	jitter := reconnect["properties"].(map[string]any)["jitter"].(map[string]any)
+	if jitter["type"] != "number" || jitter["minimum"] != 0.0 || jitter["maximum"] != 1.0 {
+		t.Errorf("jitter = %v, want a number in 0-1", jitter)
+	}
+}
@@ -0,0 +1,131 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ProjectConfigError lists every problem found in a project config, one
+// per line, with line numbers where YAML decoding provides them.
+type ProjectConfigError struct {
+	Path     string
+	Problems []string
+}
+
+func (e *ProjectConfigError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Path + ": " + e.Problems[0]
+	}
+	return fmt.Sprintf("%s: %d problems:\n  %s", e.Path, len(e.Problems), strings.Join(e.Problems, "\n  "))
+}
+
+// unknownFieldRe matches the yaml.v3 error for a key no field accepts.
+var unknownFieldRe = regexp.MustCompile(`^line (\d+): field (\S+) not found in type \S+\.(\w+)$`)
+
+// sections names the project config types as users know them.
+var sections = map[string]string{
+	"ProjectConfig": "gopublic.yaml",
+	"Tunnel":        "tunnel",
+	"Reconnect":     "reconnect",
+	"Keepalive":     "keepalive",
+}
+
+// describeYAMLError turns a decoding error of path into a
+// ProjectConfigError. Unknown keys are reported with the closest known
+// key, e.g. subdomian -> subdomain.
+func describeYAMLError(path string, err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	fields := knownFields(reflect.TypeOf(ProjectConfig{}), nil)
+	problems := make([]string, 0, len(typeErr.Errors))
+	for _, msg := range typeErr.Errors {
+		m := unknownFieldRe.FindStringSubmatch(msg)
+		if m == nil {
+			problems = append(problems, msg)
+			continue
+		}
+		line, key, typeName := m[1], m[2], m[3]
+		section := sections[typeName]
+		if section == "" {
+			section = typeName
+		}
+		problem := fmt.Sprintf("line %s: unknown key %q in %s", line, key, section)
+		if suggestion := closest(key, fields[typeName]); suggestion != "" {
+			problem += fmt.Sprintf(", did you mean %q?", suggestion)
+		}
+		problems = append(problems, problem)
+	}
+	return &ProjectConfigError{Path: path, Problems: problems}
+}
+
+// knownFields collects the yaml keys of t and of the structs it contains,
+// by type name.
+func knownFields(t reflect.Type, fields map[string][]string) map[string][]string {
+	if fields == nil {
+		fields = make(map[string][]string)
+	}
+	for t.Kind() == reflect.Pointer || t.Kind() == reflect.Map || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return fields
+	}
+	if _, seen := fields[t.Name()]; seen {
+		return fields
+	}
+	fields[t.Name()] = []string{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[t.Name()] = append(fields[t.Name()], name)
+		knownFields(t.Field(i).Type, fields)
+	}
+	return fields
+}
+
+// closest returns the candidate nearest to key if it is a plausible typo:
+// at most a third of its letters differ.
+func closest(key string, candidates []string) string {
+	best, bestDist := "", len(key)/3+1
+	for _, c := range candidates {
+		if d := editDistance(key, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// editDistance returns the Damerau-Levenshtein distance (optimal string
+// alignment) between a and b, so that swapped letters count once.
+func editDistance(a, b string) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				d[i][j] = min(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
@@ -0,0 +1,130 @@
+package control
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gopublic/internal/client/inspector"
+)
+
+// ErrNotRunning is returned when no client listens on the control socket.
+var ErrNotRunning = errors.New("no running gopublic client")
+
+// Client talks to the control API of a running client.
+type Client struct {
+	http *http.Client
+}
+
+// dialTimeout bounds connecting to the control socket.
+const dialTimeout = 2 * time.Second
+
+// NewClient creates a client of the control socket at path.
+func NewClient(path string) *Client {
+	return &Client{http: &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				ctx, cancel := context.WithTimeout(ctx, dialTimeout)
+				defer cancel()
+				conn, err := dial(ctx, path)
+				if err != nil {
+					return nil, fmt.Errorf("%w: %v", ErrNotRunning, err)
+				}
+				return conn, nil
+			},
+		},
+	}}
+}
+
+// Status returns the state of the running client.
+func (c *Client) Status(ctx context.Context) (*Status, error) {
+	var status Status
+	if err := c.do(ctx, http.MethodGet, "/status", nil, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// Stats returns the traffic of the running client.
+func (c *Client) Stats(ctx context.Context) (*Stats, error) {
+	var stats Stats
+	if err := c.do(ctx, http.MethodGet, "/stats", nil, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// Tunnels returns the public URLs bound by the running client.
+func (c *Client) Tunnels(ctx context.Context) ([]inspector.TunnelInfo, error) {
+	var list struct {
+		Tunnels []inspector.TunnelInfo `json:"tunnels"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/tunnels", nil, &list); err != nil {
+		return nil, err
+	}
+	return list.Tunnels, nil
+}
+
+// AddTunnel asks the running client to start a tunnel.
+func (c *Client) AddTunnel(ctx context.Context, spec TunnelSpec) error {
+	return c.do(ctx, http.MethodPost, "/tunnels", spec, nil)
+}
+
+// RemoveTunnel asks the running client to stop the tunnel of that name.
+func (c *Client) RemoveTunnel(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodDelete, "/tunnels/"+url.PathEscape(name), nil, nil)
+}
+
+// Reconnect asks the running client to reconnect to the server.
+func (c *Client) Reconnect(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/reconnect", nil, nil)
+}
+
+// do sends a request to the control API with body as JSON, if set, and
+// decodes the JSON answer into v, if set. Errors are reported by the API
+// as {"error": "..."}.
+func (c *Client) do(ctx context.Context, method, path string, body, v any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, "http://gopublic"+path, reader)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		var apiErr struct {
+			Error string `json:"error"`
+		}
+		if json.NewDecoder(resp.Body).Decode(&apiErr) == nil && apiErr.Error != "" {
+			return errors.New(apiErr.Error)
+		}
+		return fmt.Errorf("control API: %s", strings.ToLower(http.StatusText(resp.StatusCode)))
+	}
+	if v == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
@@ -0,0 +1,268 @@
+// Package control serves the local control API of a running client on a
+// unix socket, or a named pipe on Windows, so that other gopublic commands and editor integrations
+// can query and change it.
+package control
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/inspector"
+	"gopublic/internal/client/stats"
+)
+
+// Connection states reported in Status.State.
+const (
+	StateConnecting   = "connecting"
+	StateConnected    = "connected"
+	StateReconnecting = "reconnecting"
+	StateDisconnected = "disconnected"
+)
+
+// Errors of a Controller: ErrTunnelNotFound is answered with 404, the
+// others with 409.
+var (
+	ErrTunnelNotFound = errors.New("no such tunnel")
+	ErrTunnelExists   = errors.New("tunnel already exists")
+	ErrFixedTunnels   = errors.New("tunnels can only be changed when started from gopublic.yaml")
+	ErrNotConnected   = errors.New("not connected to the server")
+)
+
+// Controller changes the tunnels of the running client.
+type Controller interface {
+	// AddTunnel starts a tunnel next to the running ones.
+	AddTunnel(spec TunnelSpec) error
+	// RemoveTunnel stops the tunnel of that name.
+	RemoveTunnel(name string) error
+	// Reconnect drops the connection to the server, which is then
+	// established again with the same tunnels.
+	Reconnect() error
+}
+
+// TunnelSpec is a tunnel added at runtime, like one of gopublic.yaml.
+type TunnelSpec struct {
+	Name      string `json:"name"`
+	Addr      string `json:"addr"` // Local port or host:port of the service
+	Subdomain string `json:"subdomain"`
+	BasicAuth string `json:"basic_auth,omitempty"`
+}
+
+// Status is the state of a running client, as shown by "gopublic status".
+type Status struct {
+	PID           int                    `json:"pid"`
+	Version       string                 `json:"version"`
+	Server        string                 `json:"server"`
+	State         string                 `json:"state"`             // State*
+	Attempt       int                    `json:"attempt,omitempty"` // Reconnect attempt while reconnecting
+	StartedAt     time.Time              `json:"started_at"`
+	ConnectedAt   *time.Time             `json:"connected_at,omitempty"`
+	UptimeSeconds int64                  `json:"uptime_seconds"`
+	Tunnels       []inspector.TunnelInfo `json:"tunnels"`
+	Stats         Stats                  `json:"stats"`
+}
+
+// Stats is the client's traffic since it started.
+type Stats struct {
+	TotalConnections int64   `json:"total_connections"`
+	OpenConnections  int64   `json:"open_connections"`
+	TotalRequests    int64   `json:"total_requests"`
+	TotalBytes       int64   `json:"total_bytes"`
+	P50Ms            float64 `json:"p50_ms"`
+	P90Ms            float64 `json:"p90_ms"`
+	ServerLatencyMs  float64 `json:"server_latency_ms"`
+}
+
+func statsFrom(s stats.Snapshot) Stats {
+	return Stats{
+		TotalConnections: s.TotalConnections,
+		OpenConnections:  s.OpenConnections,
+		TotalRequests:    s.TotalRequests,
+		TotalBytes:       s.TotalBytes,
+		P50Ms:            milliseconds(s.P50),
+		P90Ms:            milliseconds(s.P90),
+		ServerLatencyMs:  milliseconds(s.ServerLatency),
+	}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// Server serves the control API of the running client.
+type Server struct {
+	version    string
+	controller Controller // nil = read-only
+	stats      *stats.Stats
+	tunnels    inspector.TunnelList
+	started    time.Time
+
+	mu          sync.RWMutex
+	server      string
+	state       string
+	attempt     int
+	connectedAt time.Time
+}
+
+// NewServer creates the control server of a client connecting to
+// serverAddr.
+func NewServer(serverAddr, version string, st *stats.Stats) *Server {
+	return &Server{
+		version: version,
+		stats:   st,
+		started: time.Now(),
+		server:  serverAddr,
+		state:   StateConnecting,
+	}
+}
+
+// SetController lets the API change the tunnels through c.
+func (s *Server) SetController(c Controller) {
+	s.controller = c
+}
+
+// Track follows the connection state and bound tunnels on the client's
+// event bus until the bus is closed.
+func (s *Server) Track(bus *events.Bus) {
+	s.tunnels.Track(bus)
+	ch := bus.Subscribe()
+	go func() {
+		for event := range ch {
+			s.handle(event)
+		}
+	}()
+}
+
+func (s *Server) handle(event events.Event) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch event.Type {
+	case events.EventConnecting:
+		s.state = StateConnecting
+	case events.EventConnected:
+		s.state = StateConnected
+		s.attempt = 0
+		s.connectedAt = event.Timestamp
+		if data, ok := event.Data.(events.ConnectedData); ok && data.ServerAddr != "" {
+			s.server = data.ServerAddr
+		}
+	case events.EventReconnecting:
+		s.state = StateReconnecting
+		if data, ok := event.Data.(events.ReconnectingData); ok {
+			s.attempt = data.Attempt
+		}
+	case events.EventDisconnected:
+		s.state = StateDisconnected
+	}
+}
+
+// Status returns the current state of the client.
+func (s *Server) Status() Status {
+	s.mu.RLock()
+	status := Status{
+		PID:           os.Getpid(),
+		Version:       s.version,
+		Server:        s.server,
+		State:         s.state,
+		Attempt:       s.attempt,
+		StartedAt:     s.started,
+		UptimeSeconds: int64(time.Since(s.started).Seconds()),
+		Tunnels:       s.tunnels.List(),
+	}
+	if s.state == StateConnected {
+		connectedAt := s.connectedAt
+		status.ConnectedAt = &connectedAt
+	}
+	s.mu.RUnlock()
+
+	if s.stats != nil {
+		status.Stats = statsFrom(s.stats.Snapshot())
+	}
+	return status
+}
+
+// handler routes the API: GET /status, GET /stats, GET /tunnels, POST
+// /tunnels, DELETE /tunnels/{name} and POST /reconnect.
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Status())
+	})
+	mux.HandleFunc("GET /stats", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Status().Stats)
+	})
+	mux.HandleFunc("GET /tunnels", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, map[string]any{"tunnels": s.tunnels.List()})
+	})
+	mux.HandleFunc("POST /tunnels", func(w http.ResponseWriter, r *http.Request) {
+		var spec TunnelSpec
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 64*1024)).Decode(&spec); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid JSON: "+err.Error())
+			return
+		}
+		s.control(w, func(c Controller) error { return c.AddTunnel(spec) })
+	})
+	mux.HandleFunc("DELETE /tunnels/{name}", func(w http.ResponseWriter, r *http.Request) {
+		s.control(w, func(c Controller) error { return c.RemoveTunnel(r.PathValue("name")) })
+	})
+	mux.HandleFunc("POST /reconnect", func(w http.ResponseWriter, r *http.Request) {
+		s.control(w, func(c Controller) error { return c.Reconnect() })
+	})
+	return mux
+}
+
+// control runs a change through the controller and answers 202: the
+// tunnels restart in the background, see GET /status for the result.
+func (s *Server) control(w http.ResponseWriter, change func(Controller) error) {
+	if s.controller == nil {
+		writeError(w, http.StatusNotImplemented, "this client cannot be changed")
+		return
+	}
+	err := change(s.controller)
+	switch {
+	case err == nil:
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "accepted"})
+	case errors.Is(err, ErrTunnelNotFound):
+		writeError(w, http.StatusNotFound, err.Error())
+	case errors.Is(err, ErrTunnelExists), errors.Is(err, ErrFixedTunnels), errors.Is(err, ErrNotConnected):
+		writeError(w, http.StatusConflict, err.Error())
+	default:
+		writeError(w, http.StatusBadRequest, err.Error())
+	}
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, code int, msg string) {
+	writeJSON(w, code, map[string]string{"error": msg})
+}
+
+// Start listens on the unix socket (or Windows named pipe) at path and
+// serves the control API until ctx is done. Only the current user may
+// connect.
+func (s *Server) Start(ctx context.Context, path string) error {
+	ln, err := listen(path)
+	if err != nil {
+		return err
+	}
+
+	httpSrv := &http.Server{Handler: s.handler(), ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		httpSrv.Shutdown(shutdownCtx)
+	}()
+	go httpSrv.Serve(ln)
+	return nil
+}
@@ -0,0 +1,168 @@
+package control
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/stats"
+)
+
+// testPath returns a control socket path of the test: a socket in a
+// temporary directory, or a named pipe on Windows.
+func testPath(t *testing.T) string {
+	if runtime.GOOS == "windows" {
+		return fmt.Sprintf(`\\.\pipe\gopublic-test-%d-%s`, os.Getpid(), t.Name())
+	}
+	return filepath.Join(t.TempDir(), "control.sock")
+}
+
+// startServer serves a control API on a test socket.
+func startServer(t *testing.T) (*Server, *events.Bus, *Client) {
+	t.Helper()
+	bus := events.NewBus()
+	st := stats.New()
+	st.RecordRequest(20*time.Millisecond, 512)
+	srv := NewServer("example.com:4443", "1.2.3", st)
+	srv.Track(bus)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	path := testPath(t)
+	if err := srv.Start(ctx, path); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	return srv, bus, NewClient(path)
+}
+
+// waitStatus polls the status until ok accepts it; events are handled
+// asynchronously.
+func waitStatus(t *testing.T, c *Client, ok func(*Status) bool) *Status {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		status, err := c.Status(context.Background())
+		if err != nil {
+			t.Fatalf("Status() error = %v", err)
+		}
+		if ok(status) {
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("status = %+v", status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestServer_Status(t *testing.T) {
+	_, bus, c := startServer(t)
+
+	status := waitStatus(t, c, func(s *Status) bool { return s.State == StateConnecting })
+	if status.Server != "example.com:4443" || status.Version != "1.2.3" || status.ConnectedAt != nil {
+		t.Errorf("status before connecting = %+v", status)
+	}
+	if status.Stats.TotalRequests != 1 || status.Stats.TotalBytes != 512 {
+		t.Errorf("stats = %+v", status.Stats)
+	}
+
+	bus.Publish(events.Event{Type: events.EventConnected, Data: events.ConnectedData{ServerAddr: "example.com:4443"}})
+	bus.Publish(events.Event{Type: events.EventTunnelReady, Data: events.TunnelReadyData{
+		Name: "web", LocalPort: "3000", BoundDomains: []string{"misty-river.example.com"}, Scheme: "https",
+	}})
+	status = waitStatus(t, c, func(s *Status) bool { return s.State == StateConnected && len(s.Tunnels) == 1 })
+	if status.ConnectedAt == nil {
+		t.Error("ConnectedAt not set while connected")
+	}
+	if tun := status.Tunnels[0]; tun.Name != "web" || tun.PublicURL != "https://misty-river.example.com" || tun.LocalPort != "3000" {
+		t.Errorf("tunnel = %+v", tun)
+	}
+
+	bus.Publish(events.Event{Type: events.EventReconnecting, Data: events.ReconnectingData{Attempt: 3}})
+	status = waitStatus(t, c, func(s *Status) bool { return s.State == StateReconnecting })
+	if status.Attempt != 3 || len(status.Tunnels) != 0 {
+		t.Errorf("status while reconnecting = %+v", status)
+	}
+}
+
+func TestClient_NotRunning(t *testing.T) {
+	c := NewClient(testPath(t))
+	if _, err := c.Status(context.Background()); !errors.Is(err, ErrNotRunning) {
+		t.Errorf("Status() error = %v, want ErrNotRunning", err)
+	}
+}
+
+// fakeController records the changes asked through the API.
+type fakeController struct {
+	added     []TunnelSpec
+	removed   []string
+	reconnect int
+}
+
+func (f *fakeController) AddTunnel(spec TunnelSpec) error {
+	if spec.Name == "web" {
+		return ErrTunnelExists
+	}
+	f.added = append(f.added, spec)
+	return nil
+}
+
+func (f *fakeController) RemoveTunnel(name string) error {
+	if name != "api" {
+		return ErrTunnelNotFound
+	}
+	f.removed = append(f.removed, name)
+	return nil
+}
+
+func (f *fakeController) Reconnect() error {
+	f.reconnect++
+	return nil
+}
+
+func TestServer_Controller(t *testing.T) {
+	srv, _, c := startServer(t)
+	ctx := context.Background()
+
+	if err := c.Reconnect(ctx); err == nil {
+		t.Error("Reconnect() without a controller succeeded")
+	}
+
+	fake := &fakeController{}
+	srv.SetController(fake)
+	spec := TunnelSpec{Name: "api", Addr: "8080", Subdomain: "misty-river"}
+	if err := c.AddTunnel(ctx, spec); err != nil {
+		t.Fatalf("AddTunnel() error = %v", err)
+	}
+	if len(fake.added) != 1 || fake.added[0] != spec {
+		t.Errorf("added = %+v, want %+v", fake.added, spec)
+	}
+	if err := c.AddTunnel(ctx, TunnelSpec{Name: "web"}); err == nil || err.Error() != ErrTunnelExists.Error() {
+		t.Errorf("AddTunnel() of an existing tunnel error = %v", err)
+	}
+
+	if err := c.RemoveTunnel(ctx, "api"); err != nil {
+		t.Fatalf("RemoveTunnel() error = %v", err)
+	}
+	if err := c.RemoveTunnel(ctx, "nope"); err == nil || err.Error() != ErrTunnelNotFound.Error() {
+		t.Errorf("RemoveTunnel() of an unknown tunnel error = %v", err)
+	}
+
+	if err := c.Reconnect(ctx); err != nil || fake.reconnect != 1 {
+		t.Errorf("Reconnect() error = %v, reconnects = %d", err, fake.reconnect)
+	}
+
+	st, err := c.Stats(ctx)
+	if err != nil || st.TotalRequests != 1 {
+		t.Errorf("Stats() = %+v, %v", st, err)
+	}
+	if tunnels, err := c.Tunnels(ctx); err != nil || len(tunnels) != 0 {
+		t.Errorf("Tunnels() = %+v, %v, want none bound", tunnels, err)
+	}
+}
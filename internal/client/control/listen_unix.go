@@ -0,0 +1,34 @@
+//go:build !windows
+
+package control
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+)
+
+// listen creates the unix socket at path, readable only by the user. A
+// socket left behind by a crashed client is replaced; the caller holds the
+// instance lock, so it is not in use.
+func listen(path string) (net.Listener, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	os.Remove(path)
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
+func dial(ctx context.Context, path string) (net.Conn, error) {
+	var d net.Dialer
+	return d.DialContext(ctx, "unix", path)
+}
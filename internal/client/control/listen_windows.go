@@ -0,0 +1,30 @@
+//go:build windows
+
+package control
+
+import (
+	"context"
+	"net"
+
+	"github.com/Microsoft/go-winio"
+	"golang.org/x/sys/windows"
+)
+
+// listen creates the named pipe at path, e.g. \\.\pipe\gopublic-alice. Its
+// security descriptor grants access to the current user only, like the
+// 0600 unix socket. A pipe vanishes with the process that created it, so
+// none is left behind by a crashed client.
+func listen(path string) (net.Listener, error) {
+	token := windows.GetCurrentProcessToken()
+	tu, err := token.GetTokenUser()
+	if err != nil {
+		return nil, err
+	}
+	return winio.ListenPipe(path, &winio.PipeConfig{
+		SecurityDescriptor: "D:P(A;;GA;;;" + tu.User.Sid.String() + ")",
+	})
+}
+
+func dial(ctx context.Context, path string) (net.Conn, error) {
+	return winio.DialPipeContext(ctx, path)
+}
@@ -0,0 +1,201 @@
+// Package crash writes a crash report when the client panics, so that bug
+// reports come with the stack and the events leading up to the panic.
+package crash
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/version"
+
+	"gopkg.in/yaml.v3"
+)
+
+// maxEvents is the number of recent events kept for the report.
+const maxEvents = 100
+
+var (
+	mu         sync.Mutex
+	recent     []events.Event // Ring buffer, next is the oldest once full
+	next       int
+	beforeExit map[int]func()
+	nextHook   int
+
+	crashing sync.Mutex // Held forever by the first panicking goroutine
+
+	exit = os.Exit // Replaced in tests
+)
+
+// Record keeps the most recent events of bus for crash reports until the
+// bus is closed.
+func Record(bus *events.Bus) {
+	ch := bus.Subscribe()
+	go func() {
+		for event := range ch {
+			add(event)
+		}
+	}()
+}
+
+func add(event events.Event) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(recent) < maxEvents {
+		recent = append(recent, event)
+		return
+	}
+	recent[next] = event
+	next = (next + 1) % maxEvents
+}
+
+// Events returns the recorded events, oldest first.
+func Events() []events.Event {
+	mu.Lock()
+	defer mu.Unlock()
+
+	out := make([]events.Event, 0, len(recent))
+	out = append(out, recent[next:]...)
+	return append(out, recent[:next]...)
+}
+
+// BeforeExit registers fn to run before the process exits on a panic,
+// e.g. to restore the terminal. The returned func unregisters it.
+func BeforeExit(fn func()) (remove func()) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if beforeExit == nil {
+		beforeExit = make(map[int]func())
+	}
+	id := nextHook
+	nextHook++
+	beforeExit[id] = fn
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(beforeExit, id)
+	}
+}
+
+// Go runs fn in a new goroutine that writes a crash report if fn panics.
+func Go(fn func()) {
+	go func() {
+		defer Recover()
+		fn()
+	}()
+}
+
+// Recover writes a crash report and exits with status 2, like an
+// unrecovered panic, if the goroutine is panicking. It must be deferred
+// directly.
+func Recover() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	stack := debug.Stack()
+
+	// Concurrent panics wait here until the process exits
+	crashing.Lock()
+
+	mu.Lock()
+	hooks := make([]func(), 0, len(beforeExit))
+	for _, fn := range beforeExit {
+		hooks = append(hooks, fn)
+	}
+	mu.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+
+	fmt.Fprintf(os.Stderr, "panic: %v\n\n%s\n", r, stack)
+	dir, err := config.CrashDir()
+	if err == nil {
+		var path string
+		if path, err = WriteReport(dir, r, stack); err == nil {
+			fmt.Fprintln(os.Stderr, i18n.T("crash.saved", path))
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, i18n.T("crash.save_failed", err))
+	}
+	exit(2)
+}
+
+// WriteReport writes a crash report for the panic value r to a new file in
+// dir and returns its path. The token is redacted from the config.
+func WriteReport(dir string, r any, stack []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	now := time.Now()
+	path := filepath.Join(dir, "crash-"+now.Format("20060102-150405")+".txt")
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "gopublic %s crash report\n", version.Version)
+	fmt.Fprintf(&b, "Time: %s\n", now.Format(time.RFC3339))
+	fmt.Fprintf(&b, "Go: %s %s/%s\n", runtime.Version(), runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(&b, "Panic: %v\n", r)
+
+	fmt.Fprintf(&b, "\n== Stack ==\n%s", stack)
+
+	b.WriteString("\n== Config ==\n")
+	if cfg, err := config.LoadConfig(); err != nil {
+		fmt.Fprintf(&b, "error: %v\n", err)
+	} else {
+		if cfg.Token != "" {
+			cfg.Token = "[redacted]"
+		}
+		writeYAML(&b, cfg)
+	}
+
+	b.WriteString("\n== gopublic.yaml ==\n")
+	if project, err := config.LoadProjectConfig(""); err != nil {
+		fmt.Fprintf(&b, "none: %v\n", err)
+	} else {
+		writeYAML(&b, project)
+	}
+
+	b.WriteString("\n== Recent events ==\n")
+	for _, event := range Events() {
+		fmt.Fprintf(&b, "%s %s %s\n", event.Timestamp.Format("15:04:05.000"), event.Type, describe(event.Data))
+	}
+
+	if err := os.WriteFile(path, b.Bytes(), 0600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func writeYAML(b *bytes.Buffer, v any) {
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		fmt.Fprintf(b, "error: %v\n", err)
+		return
+	}
+	b.Write(data)
+}
+
+// describe formats event data for the report. Query strings are dropped
+// from request paths since they often carry credentials.
+func describe(data any) string {
+	if data == nil {
+		return ""
+	}
+	if req, ok := data.(events.RequestData); ok {
+		req.Path, _, _ = strings.Cut(req.Path, "?")
+		data = req
+	}
+	return fmt.Sprintf("%+v", data)
+}
@@ -0,0 +1,98 @@
+package crash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/events"
+)
+
+func resetEvents() {
+	mu.Lock()
+	defer mu.Unlock()
+	recent, next = nil, 0
+}
+
+func TestEvents_KeepsMostRecent(t *testing.T) {
+	resetEvents()
+	defer resetEvents()
+
+	for i := 0; i < maxEvents+5; i++ {
+		add(events.Event{Type: events.EventLog, Data: events.LogData{Message: string(rune('a' + i%26))}, Timestamp: time.Unix(int64(i), 0)})
+	}
+	got := Events()
+	if len(got) != maxEvents {
+		t.Fatalf("len(Events()) = %d, want %d", len(got), maxEvents)
+	}
+	if got[0].Timestamp.Unix() != 5 || got[len(got)-1].Timestamp.Unix() != maxEvents+4 {
+		t.Errorf("Events() spans %d..%d, want 5..%d", got[0].Timestamp.Unix(), got[len(got)-1].Timestamp.Unix(), maxEvents+4)
+	}
+}
+
+func TestWriteReport(t *testing.T) {
+	resetEvents()
+	defer resetEvents()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+	if err := config.SaveConfig(&config.Config{Token: "sk_live_secret", Language: "en"}); err != nil {
+		t.Fatalf("SaveConfig: %v", err)
+	}
+	add(events.Event{Type: events.EventRequestStart, Data: events.RequestData{Method: "GET", Path: "/hook?token=abc"}})
+
+	path, err := WriteReport(t.TempDir(), "boom", []byte("goroutine 1 [running]:\n"))
+	if err != nil {
+		t.Fatalf("WriteReport() error = %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	report := string(data)
+	for _, want := range []string{"Panic: boom", "goroutine 1 [running]", "language: en", "[redacted]", "request_start", "Path:/hook "} {
+		if !strings.Contains(report, want) {
+			t.Errorf("report missing %q:\n%s", want, report)
+		}
+	}
+	for _, secret := range []string{"sk_live_secret", "token=abc"} {
+		if strings.Contains(report, secret) {
+			t.Errorf("report leaks %q", secret)
+		}
+	}
+}
+
+func TestGo_Recovers(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("APPDATA", home)
+
+	exited := make(chan int, 1)
+	exit = func(code int) {
+		exited <- code
+		select {} // Like os.Exit, never return
+	}
+	defer func() { exit = os.Exit }()
+	hookRan := false
+	defer BeforeExit(func() { hookRan = true })()
+
+	Go(func() { panic("boom") })
+
+	select {
+	case code := <-exited:
+		if code != 2 {
+			t.Errorf("exit code = %d, want 2", code)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("panic was not handled")
+	}
+	if !hookRan {
+		t.Error("BeforeExit hook did not run")
+	}
+	dir, _ := config.CrashDir()
+	if files, _ := filepath.Glob(filepath.Join(dir, "crash-*.txt")); len(files) != 1 {
+		t.Errorf("crash reports = %v, want one", files)
+	}
+}
@@ -0,0 +1,355 @@
+// Package diagnose runs connectivity checks against the tunnel server and
+// the local service, to triage "tunnel is slow / won't connect" reports.
+package diagnose
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"gopublic/pkg/protocol"
+
+	"github.com/hashicorp/yamux"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	StatusOK   Status = "ok"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Check identifiers, in the order they run.
+const (
+	CheckDNS       = "dns"
+	CheckTCP       = "tcp"
+	CheckIfaceMTU  = "interface_mtu"
+	CheckTLS       = "tls"
+	CheckHandshake = "handshake"
+	CheckLocal     = "local"
+)
+
+// DefaultTimeout bounds each network check.
+const DefaultTimeout = 5 * time.Second
+
+// standardMTU is the Ethernet MTU; smaller interface MTUs usually mean a
+// VPN or PPPoE link where large packets may be fragmented or dropped.
+const standardMTU = 1500
+
+// Options configures a diagnostics run.
+type Options struct {
+	ServerAddr string        // host:port of the control plane
+	Token      string        // Auth token; empty skips the handshake check
+	LocalPort  string        // Local service port; empty skips the local check
+	Timeout    time.Duration // Per-check timeout (default DefaultTimeout)
+	TLS        *tls.Config   // Verifies the server for the handshake check; nil = system roots
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Check    string
+	Status   Status
+	Duration time.Duration
+	Detail   string
+}
+
+// Failed reports whether any check failed.
+func Failed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == StatusFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Run executes all checks in order. Checks that depend on a failed
+// earlier check are skipped.
+func Run(ctx context.Context, opts Options) []Result {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultTimeout
+	}
+	host, _, err := net.SplitHostPort(opts.ServerAddr)
+	if err != nil {
+		return []Result{{Check: CheckDNS, Status: StatusFail, Detail: fmt.Sprintf("invalid server address %q: %v", opts.ServerAddr, err)}}
+	}
+
+	var results []Result
+	dns := checkDNS(ctx, host, opts.Timeout)
+	results = append(results, dns)
+
+	reachable := dns.Status != StatusFail
+	var localIP net.IP
+	if reachable {
+		var tcp Result
+		tcp, localIP = checkTCP(ctx, opts.ServerAddr, opts.Timeout)
+		results = append(results, tcp)
+		reachable = tcp.Status != StatusFail
+	} else {
+		results = append(results, skipped(CheckTCP, "server address did not resolve"))
+	}
+
+	if localIP != nil {
+		results = append(results, checkIfaceMTU(localIP))
+	} else {
+		results = append(results, skipped(CheckIfaceMTU, "no connection to the server"))
+	}
+
+	switch {
+	case !reachable:
+		results = append(results, skipped(CheckTLS, "server is unreachable"))
+	case isLocal(host):
+		results = append(results, skipped(CheckTLS, "local servers use plain TCP"))
+	default:
+		results = append(results, checkTLS(ctx, opts.ServerAddr, host, opts.Timeout))
+	}
+
+	switch {
+	case !reachable:
+		results = append(results, skipped(CheckHandshake, "server is unreachable"))
+	case opts.Token == "":
+		results = append(results, skipped(CheckHandshake, "no token configured (run 'gopublic auth <token>')"))
+	default:
+		results = append(results, checkHandshake(ctx, opts.ServerAddr, host, opts.Token, opts.Timeout, opts.TLS))
+	}
+
+	if opts.LocalPort == "" {
+		results = append(results, skipped(CheckLocal, "no local port given"))
+	} else {
+		results = append(results, checkLocal(ctx, opts.LocalPort, opts.Timeout))
+	}
+
+	return results
+}
+
+func skipped(check, reason string) Result {
+	return Result{Check: check, Status: StatusSkip, Detail: reason}
+}
+
+// isLocal mirrors the tunnel's rule for using plain TCP.
+func isLocal(host string) bool {
+	return host == "localhost" || host == "127.0.0.1" || host == "::1"
+}
+
+func checkDNS(ctx context.Context, host string, timeout time.Duration) Result {
+	if net.ParseIP(host) != nil {
+		return Result{Check: CheckDNS, Status: StatusOK, Detail: "literal IP address"}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+	r := Result{Check: CheckDNS, Duration: time.Since(start)}
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = err.Error()
+		return r
+	}
+	r.Status = StatusOK
+	r.Detail = strings.Join(addrs, ", ")
+	return r
+}
+
+// checkTCP connects to the server and returns the local address used,
+// which identifies the outgoing interface for the MTU check.
+func checkTCP(ctx context.Context, addr string, timeout time.Duration) (Result, net.IP) {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	r := Result{Check: CheckTCP, Duration: time.Since(start)}
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = err.Error()
+		return r, nil
+	}
+	defer conn.Close()
+
+	r.Status = StatusOK
+	r.Detail = conn.RemoteAddr().String()
+	if tcpAddr, ok := conn.LocalAddr().(*net.TCPAddr); ok {
+		return r, tcpAddr.IP
+	}
+	return r, nil
+}
+
+// checkIfaceMTU reports the configured MTU of the interface used to reach
+// the server. It does not probe the path MTU, which may be smaller.
+func checkIfaceMTU(localIP net.IP) Result {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return Result{Check: CheckIfaceMTU, Status: StatusWarn, Detail: err.Error()}
+	}
+
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, a := range addrs {
+			ipNet, ok := a.(*net.IPNet)
+			if !ok || !ipNet.IP.Equal(localIP) {
+				continue
+			}
+			r := Result{Check: CheckIfaceMTU, Status: StatusOK, Detail: fmt.Sprintf("%s mtu %d", iface.Name, iface.MTU)}
+			if iface.MTU < standardMTU {
+				r.Status = StatusWarn
+				r.Detail += fmt.Sprintf(" (below %d, large packets may be fragmented; VPN or PPPoE link?)", standardMTU)
+			}
+			return r
+		}
+	}
+	return Result{Check: CheckIfaceMTU, Status: StatusWarn, Detail: fmt.Sprintf("no interface found for %s", localIP)}
+}
+
+// checkTLS performs a TLS handshake verified against the system roots. An
+// untrusted certificate is a warning, since the client accepts it if it is
+// pinned or signed by --ca-cert; the handshake check tells.
+func checkTLS(ctx context.Context, addr, host string, timeout time.Duration) Result {
+	dialer := &tls.Dialer{NetDialer: &net.Dialer{Timeout: timeout}, Config: &tls.Config{ServerName: host}}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	r := Result{Check: CheckTLS, Duration: time.Since(start)}
+	if err == nil {
+		defer conn.Close()
+		state := conn.(*tls.Conn).ConnectionState()
+		r.Status = StatusOK
+		r.Detail = tls.VersionName(state.Version)
+		if len(state.PeerCertificates) > 0 {
+			r.Detail += ", certificate valid until " + state.PeerCertificates[0].NotAfter.Format("2006-01-02")
+		}
+		return r
+	}
+
+	var certErr *tls.CertificateVerificationError
+	if !errors.As(err, &certErr) {
+		r.Status = StatusFail
+		r.Detail = err.Error()
+		return r
+	}
+
+	// Retry without verification to tell a bad certificate from a broken handshake
+	dialer.Config = &tls.Config{ServerName: host, InsecureSkipVerify: true}
+	start = time.Now()
+	conn, err = dialer.DialContext(ctx, "tcp", addr)
+	r.Duration = time.Since(start)
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = err.Error()
+		return r
+	}
+	conn.Close()
+	r.Status = StatusWarn
+	r.Detail = "certificate not trusted: " + certErr.Err.Error()
+	return r
+}
+
+// checkHandshake authenticates with a probe session, measuring the round
+// trip. Probe sessions bind no domains and leave running tunnels alone.
+func checkHandshake(ctx context.Context, addr, host, token string, timeout time.Duration, tlsConfig *tls.Config) Result {
+	r := Result{Check: CheckHandshake}
+
+	conn, err := dialServer(ctx, addr, host, timeout, tlsConfig)
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = err.Error()
+		return r
+	}
+	defer conn.Close()
+
+	session, err := yamux.Client(conn, nil)
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = err.Error()
+		return r
+	}
+	defer session.Close()
+
+	start := time.Now()
+	resp, err := handshake(session, protocol.AuthRequest{Token: token, Probe: true}, timeout)
+	r.Duration = time.Since(start)
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = err.Error()
+		return r
+	}
+
+	if resp.Success {
+		r.Status = StatusOK
+		r.Detail = "authenticated"
+	} else {
+		r.Status = StatusFail
+		r.Detail = resp.Error
+	}
+	return r
+}
+
+// dialServer connects the way the tunnel does: plain TCP for local
+// servers, TLS verified with tlsConfig otherwise, as the token is sent.
+func dialServer(ctx context.Context, addr, host string, timeout time.Duration, tlsConfig *tls.Config) (net.Conn, error) {
+	netDialer := &net.Dialer{Timeout: timeout}
+	if isLocal(host) {
+		return netDialer.DialContext(ctx, "tcp", addr)
+	}
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	}
+	tlsConfig = tlsConfig.Clone()
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	dialer := &tls.Dialer{NetDialer: netDialer, Config: tlsConfig}
+	return dialer.DialContext(ctx, "tcp", addr)
+}
+
+// handshake sends the auth and tunnel requests and reads the server's reply.
+func handshake(session *yamux.Session, auth protocol.AuthRequest, timeout time.Duration) (*protocol.InitResponse, error) {
+	stream, err := session.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open handshake stream: %w", err)
+	}
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(timeout))
+
+	enc := json.NewEncoder(stream)
+	if err := enc.Encode(auth); err != nil {
+		return nil, err
+	}
+	if err := enc.Encode(protocol.TunnelRequest{}); err != nil {
+		return nil, err
+	}
+
+	var resp protocol.InitResponse
+	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("handshake read failed: %w", err)
+	}
+	return &resp, nil
+}
+
+func checkLocal(ctx context.Context, port string, timeout time.Duration) Result {
+	dialer := &net.Dialer{Timeout: timeout}
+
+	start := time.Now()
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort("localhost", port))
+	r := Result{Check: CheckLocal, Duration: time.Since(start)}
+	if err != nil {
+		r.Status = StatusFail
+		r.Detail = fmt.Sprintf("nothing listening on localhost:%s: %v", port, err)
+		return r
+	}
+	conn.Close()
+	r.Status = StatusOK
+	r.Detail = "localhost:" + port + " accepts connections"
+	return r
+}
@@ -0,0 +1,147 @@
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+	"time"
+
+	"gopublic/pkg/protocol"
+
+	"github.com/hashicorp/yamux"
+)
+
+// fakeServer accepts probe handshakes and replies with resp. Non-probe
+// handshakes are rejected, since diagnostics must not bind domains.
+func fakeServer(t *testing.T, resp protocol.InitResponse) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				session, err := yamux.Server(conn, nil)
+				if err != nil {
+					return
+				}
+				defer session.Close()
+				stream, err := session.Accept()
+				if err != nil {
+					return
+				}
+				dec := json.NewDecoder(stream)
+				var auth protocol.AuthRequest
+				var req protocol.TunnelRequest
+				if dec.Decode(&auth) != nil || dec.Decode(&req) != nil {
+					return
+				}
+				if !auth.Probe {
+					json.NewEncoder(stream).Encode(protocol.InitResponse{Error: "not a probe"})
+					return
+				}
+				json.NewEncoder(stream).Encode(resp)
+				stream.Close()
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func byCheck(results []Result) map[string]Result {
+	m := make(map[string]Result, len(results))
+	for _, r := range results {
+		m[r.Check] = r
+	}
+	return m
+}
+
+func TestRun_AllChecks(t *testing.T) {
+	addr := fakeServer(t, protocol.InitResponse{Success: true})
+
+	local, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer local.Close()
+	_, localPort, _ := net.SplitHostPort(local.Addr().String())
+
+	results := byCheck(Run(context.Background(), Options{
+		ServerAddr: addr,
+		Token:      "sk_test",
+		LocalPort:  localPort,
+		Timeout:    time.Second,
+	}))
+
+	want := map[string]Status{
+		CheckDNS:       StatusOK,
+		CheckTCP:       StatusOK,
+		CheckTLS:       StatusSkip, // Local server
+		CheckHandshake: StatusOK,
+		CheckLocal:     StatusOK,
+	}
+	for check, status := range want {
+		if got := results[check].Status; got != status {
+			t.Errorf("%s: status = %s, want %s (%s)", check, got, status, results[check].Detail)
+		}
+	}
+	if _, ok := results[CheckIfaceMTU]; !ok {
+		t.Error("missing interface MTU check")
+	}
+}
+
+func TestRun_HandshakeErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		resp protocol.InitResponse
+		want Status
+	}{
+		{"invalid token", protocol.InitResponse{Error: "Invalid token", ErrorCode: protocol.ErrorCodeInvalidToken}, StatusFail},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr := fakeServer(t, tt.resp)
+			results := byCheck(Run(context.Background(), Options{ServerAddr: addr, Token: "sk_test", Timeout: time.Second}))
+			if got := results[CheckHandshake].Status; got != tt.want {
+				t.Errorf("handshake status = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRun_Unreachable(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	results := Run(context.Background(), Options{ServerAddr: addr, Token: "sk_test", LocalPort: "1", Timeout: time.Second})
+	if !Failed(results) {
+		t.Fatal("expected failure")
+	}
+	m := byCheck(results)
+	if m[CheckTCP].Status != StatusFail {
+		t.Errorf("tcp status = %s, want fail", m[CheckTCP].Status)
+	}
+	if m[CheckHandshake].Status != StatusSkip {
+		t.Errorf("handshake status = %s, want skip", m[CheckHandshake].Status)
+	}
+}
+
+func TestRun_InvalidAddress(t *testing.T) {
+	results := Run(context.Background(), Options{ServerAddr: "no-port"})
+	if len(results) != 1 || results[0].Status != StatusFail {
+		t.Errorf("results = %+v, want a single failure", results)
+	}
+}
@@ -0,0 +1,200 @@
+package diagnose
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"time"
+
+	"gopublic/pkg/protocol"
+
+	"github.com/hashicorp/yamux"
+)
+
+// Speedtest defaults.
+const (
+	DefaultSpeedtestBytes = 10 << 20
+	DefaultSpeedtestPings = 5
+)
+
+// SpeedtestOptions configures a speed test.
+type SpeedtestOptions struct {
+	ServerAddr string
+	Token      string
+	Bytes      int64         // Payload per direction (default DefaultSpeedtestBytes)
+	Pings      int           // Latency samples (default DefaultSpeedtestPings)
+	Timeout    time.Duration // Connect and per-transfer timeout (default 30s)
+	TLS        *tls.Config   // Verifies the server; nil = system roots
+}
+
+// Throughput is a measured transfer.
+type Throughput struct {
+	Bytes    int64
+	Duration time.Duration
+}
+
+// Mbps returns the transfer rate in megabits per second.
+func (t Throughput) Mbps() float64 {
+	if t.Duration <= 0 {
+		return 0
+	}
+	return float64(t.Bytes) * 8 / t.Duration.Seconds() / 1e6
+}
+
+// SpeedtestResult holds latency and throughput through the tunnel transport.
+type SpeedtestResult struct {
+	LatencyMin time.Duration
+	LatencyAvg time.Duration
+	LatencyMax time.Duration
+	Download   Throughput // Server -> client
+	Upload     Throughput // Client -> server
+}
+
+// Speedtest opens a temporary probe session to the server and measures
+// round-trip latency and throughput in both directions over the same
+// multiplexed connection that tunnel traffic uses.
+func Speedtest(ctx context.Context, opts SpeedtestOptions) (*SpeedtestResult, error) {
+	if opts.Bytes <= 0 {
+		opts.Bytes = DefaultSpeedtestBytes
+	}
+	if opts.Bytes > protocol.MaxSpeedtestBytes {
+		return nil, fmt.Errorf("payload size is limited to %d bytes", protocol.MaxSpeedtestBytes)
+	}
+	if opts.Pings <= 0 {
+		opts.Pings = DefaultSpeedtestPings
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+	host, _, err := net.SplitHostPort(opts.ServerAddr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid server address %q: %w", opts.ServerAddr, err)
+	}
+
+	conn, err := dialServer(ctx, opts.ServerAddr, host, opts.Timeout, opts.TLS)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	session, err := yamux.Client(conn, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	resp, err := handshake(session, protocol.AuthRequest{Token: opts.Token, Probe: true}, opts.Timeout)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Success {
+		return nil, fmt.Errorf("server error: %s", resp.Error)
+	}
+
+	result := &SpeedtestResult{}
+	var total time.Duration
+	for i := 0; i < opts.Pings; i++ {
+		rtt, err := ping(session, opts.Timeout)
+		if err != nil {
+			return nil, fmt.Errorf("ping: %w", err)
+		}
+		total += rtt
+		if result.LatencyMin == 0 || rtt < result.LatencyMin {
+			result.LatencyMin = rtt
+		}
+		if rtt > result.LatencyMax {
+			result.LatencyMax = rtt
+		}
+	}
+	result.LatencyAvg = total / time.Duration(opts.Pings)
+
+	if result.Download, err = download(session, opts.Bytes, opts.Timeout); err != nil {
+		return nil, fmt.Errorf("download: %w", err)
+	}
+	if result.Upload, err = upload(session, opts.Bytes, opts.Timeout); err != nil {
+		return nil, fmt.Errorf("upload: %w", err)
+	}
+	return result, nil
+}
+
+// openSpeedtest opens a stream and sends the measurement request.
+func openSpeedtest(session *yamux.Session, req protocol.SpeedtestRequest, timeout time.Duration) (net.Conn, error) {
+	stream, err := session.Open()
+	if err != nil {
+		return nil, err
+	}
+	stream.SetDeadline(time.Now().Add(timeout))
+	if err := json.NewEncoder(stream).Encode(req); err != nil {
+		stream.Close()
+		return nil, err
+	}
+	return stream, nil
+}
+
+func ping(session *yamux.Session, timeout time.Duration) (time.Duration, error) {
+	start := time.Now()
+	stream, err := openSpeedtest(session, protocol.SpeedtestRequest{Mode: protocol.SpeedtestPing}, timeout)
+	if err != nil {
+		return 0, err
+	}
+	defer stream.Close()
+
+	var result protocol.SpeedtestResult
+	if err := json.NewDecoder(stream).Decode(&result); err != nil {
+		return 0, err
+	}
+	if result.Error != "" {
+		return 0, errors.New(result.Error)
+	}
+	return time.Since(start), nil
+}
+
+func download(session *yamux.Session, size int64, timeout time.Duration) (Throughput, error) {
+	start := time.Now()
+	stream, err := openSpeedtest(session, protocol.SpeedtestRequest{Mode: protocol.SpeedtestDownload, Bytes: size}, timeout)
+	if err != nil {
+		return Throughput{}, err
+	}
+	defer stream.Close()
+
+	n, err := io.CopyN(io.Discard, stream, size)
+	if err != nil {
+		return Throughput{}, fmt.Errorf("received %d of %d bytes: %w", n, size, err)
+	}
+	return Throughput{Bytes: n, Duration: time.Since(start)}, nil
+}
+
+func upload(session *yamux.Session, size int64, timeout time.Duration) (Throughput, error) {
+	start := time.Now()
+	stream, err := openSpeedtest(session, protocol.SpeedtestRequest{Mode: protocol.SpeedtestUpload, Bytes: size}, timeout)
+	if err != nil {
+		return Throughput{}, err
+	}
+	defer stream.Close()
+
+	if _, err := io.CopyN(stream, zeroReader{}, size); err != nil {
+		return Throughput{}, err
+	}
+
+	// The server replies once it has received the whole payload
+	var result protocol.SpeedtestResult
+	if err := json.NewDecoder(stream).Decode(&result); err != nil {
+		return Throughput{}, err
+	}
+	if result.Error != "" {
+		return Throughput{}, errors.New(result.Error)
+	}
+	return Throughput{Bytes: result.Bytes, Duration: time.Since(start)}, nil
+}
+
+// zeroReader is an endless source of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,116 @@
+package diagnose
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"gopublic/pkg/protocol"
+
+	"github.com/hashicorp/yamux"
+)
+
+// fakeProbeServer accepts a probe handshake and answers speedtest streams.
+func fakeProbeServer(t *testing.T) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		session, err := yamux.Server(conn, nil)
+		if err != nil {
+			return
+		}
+		defer session.Close()
+
+		stream, err := session.Accept()
+		if err != nil {
+			return
+		}
+		dec := json.NewDecoder(stream)
+		var auth protocol.AuthRequest
+		var req protocol.TunnelRequest
+		if dec.Decode(&auth) != nil || dec.Decode(&req) != nil {
+			return
+		}
+		json.NewEncoder(stream).Encode(protocol.InitResponse{Success: auth.Probe})
+		stream.Close()
+
+		for {
+			stream, err := session.Accept()
+			if err != nil {
+				return
+			}
+			go func(s net.Conn) {
+				defer s.Close()
+				dec := json.NewDecoder(s)
+				var req protocol.SpeedtestRequest
+				if dec.Decode(&req) != nil {
+					return
+				}
+				switch req.Mode {
+				case protocol.SpeedtestPing:
+					json.NewEncoder(s).Encode(protocol.SpeedtestResult{})
+				case protocol.SpeedtestDownload:
+					io.CopyN(s, zeroReader{}, req.Bytes)
+				case protocol.SpeedtestUpload:
+					n, _ := io.CopyN(io.Discard, io.MultiReader(dec.Buffered(), s), req.Bytes)
+					json.NewEncoder(s).Encode(protocol.SpeedtestResult{Bytes: n})
+				}
+			}(stream)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSpeedtest(t *testing.T) {
+	addr := fakeProbeServer(t)
+
+	result, err := Speedtest(context.Background(), SpeedtestOptions{
+		ServerAddr: addr,
+		Token:      "sk_test",
+		Bytes:      1 << 20,
+		Pings:      3,
+		Timeout:    5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Speedtest: %v", err)
+	}
+	if result.Download.Bytes != 1<<20 || result.Upload.Bytes != 1<<20 {
+		t.Errorf("bytes = %d down / %d up, want %d", result.Download.Bytes, result.Upload.Bytes, 1<<20)
+	}
+	if result.LatencyMin <= 0 || result.LatencyMin > result.LatencyAvg || result.LatencyAvg > result.LatencyMax {
+		t.Errorf("latency min/avg/max = %v/%v/%v", result.LatencyMin, result.LatencyAvg, result.LatencyMax)
+	}
+	if result.Download.Mbps() <= 0 {
+		t.Error("download rate should be positive")
+	}
+}
+
+func TestSpeedtest_RejectsOversize(t *testing.T) {
+	_, err := Speedtest(context.Background(), SpeedtestOptions{ServerAddr: "127.0.0.1:1", Bytes: protocol.MaxSpeedtestBytes + 1})
+	if err == nil {
+		t.Fatal("expected error for oversized payload")
+	}
+}
+
+func TestThroughput_Mbps(t *testing.T) {
+	tp := Throughput{Bytes: 1_250_000, Duration: time.Second}
+	if got := tp.Mbps(); got != 10 {
+		t.Errorf("Mbps() = %v, want 10", got)
+	}
+	if got := (Throughput{}).Mbps(); got != 0 {
+		t.Errorf("zero duration Mbps() = %v, want 0", got)
+	}
+}
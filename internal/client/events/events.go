@@ -30,6 +30,13 @@ const (
 
 	// Tunnel info events
 	EventTunnelReady
+	EventTunnelFailed // A configured subdomain was not bound; retried in the background
+
+	// Usage pushed by the server while connected
+	EventServerStats
+
+	// A command sent from the dashboard fleet view, e.g. reload
+	EventRemoteCommand
 )
 
 // String returns a human-readable name for the event type.
@@ -55,6 +62,12 @@ func (t EventType) String() string {
 		return "log"
 	case EventTunnelReady:
 		return "tunnel_ready"
+	case EventTunnelFailed:
+		return "tunnel_failed"
+	case EventServerStats:
+		return "server_stats"
+	case EventRemoteCommand:
+		return "remote_command"
 	default:
 		return "unknown"
 	}
@@ -69,12 +82,38 @@ type Event struct {
 
 // ConnectedData contains data for EventConnected.
 type ConnectedData struct {
-	ServerAddr       string
-	BoundDomains     []string
-	Latency          time.Duration
-	BandwidthToday   int64 // Bytes used today
-	BandwidthTotal   int64 // Total bytes used all time
-	BandwidthLimit   int64 // Daily bandwidth limit in bytes
+	ServerAddr     string
+	BoundDomains   []string
+	Latency        time.Duration
+	BandwidthToday int64 // Bytes used today
+	BandwidthTotal int64 // Total bytes used all time
+	BandwidthLimit int64 // Daily bandwidth limit in bytes
+	Labels         map[string]string
+}
+
+// ServerStatsData contains data for EventServerStats.
+type ServerStatsData struct {
+	BandwidthToday int64 // Bytes used today
+	BandwidthTotal int64 // Total bytes used all time
+	BandwidthLimit int64 // Daily bandwidth limit in bytes
+	Domains        []DomainStatsData
+	StreamsActive  int  // Concurrent requests in flight on the server
+	StreamsLimit   int  // Their limit (0 = unlimited)
+	QuotaExceeded  bool // Daily limit used up; visitors get 429 until it resets
+}
+
+// DomainStatsData counts the traffic of a bound domain since it was bound.
+type DomainStatsData struct {
+	Domain    string
+	Requests  int64
+	Bytes     int64
+	Throttled int64   // Requests refused with 429 over the rate limit
+	RateLimit float64 // Requests per second (0 = unlimited)
+}
+
+// RemoteCommandData contains data for EventRemoteCommand.
+type RemoteCommandData struct {
+	Command string // protocol.Command*, never CommandDisconnect
 }
 
 // ReconnectingData contains data for EventReconnecting.
@@ -107,6 +146,13 @@ type TunnelReadyData struct {
 	Scheme       string
 }
 
+// TunnelFailedData contains data for EventTunnelFailed.
+type TunnelFailedData struct {
+	Name      string
+	LocalPort string
+	Error     error
+}
+
 // LogData contains data for EventLog.
 type LogData struct {
 	Level   string // "info", "warn", "error"
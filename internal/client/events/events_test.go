@@ -261,6 +261,8 @@ func TestEventTypeString(t *testing.T) {
 		{EventRequestComplete, "request_complete"},
 		{EventError, "error"},
 		{EventTunnelReady, "tunnel_ready"},
+		{EventTunnelFailed, "tunnel_failed"},
+		{EventServerStats, "server_stats"},
 		{EventType(999), "unknown"},
 	}
 
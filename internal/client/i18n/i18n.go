@@ -0,0 +1,131 @@
+// Package i18n localizes user-facing CLI and TUI strings.
+//
+// Messages live in locales/<lang>.yaml as flat "key: text" maps, so adding
+// a language only requires a new message file. Keys missing from a bundle
+// fall back to English.
+package i18n
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLanguage is used when no supported language is configured.
+const DefaultLanguage = "en"
+
+//go:embed locales/*.yaml
+var localeFS embed.FS
+
+var (
+	bundles = mustLoad(localeFS)
+
+	mu      sync.RWMutex
+	current = DefaultLanguage
+)
+
+// mustLoad parses every message file in locales/.
+func mustLoad(fsys fs.FS) map[string]map[string]string {
+	files, err := fs.Glob(fsys, "locales/*.yaml")
+	if err != nil {
+		panic(err)
+	}
+
+	loaded := make(map[string]map[string]string, len(files))
+	for _, file := range files {
+		data, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			panic(err)
+		}
+		var messages map[string]string
+		if err := yaml.Unmarshal(data, &messages); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", file, err))
+		}
+		loaded[strings.TrimSuffix(path.Base(file), ".yaml")] = messages
+	}
+	return loaded
+}
+
+// T returns the message for key in the current language, formatted with
+// args if any are given. Unknown keys are returned as is.
+func T(key string, args ...any) string {
+	mu.RLock()
+	lang := current
+	mu.RUnlock()
+
+	msg, ok := bundles[lang][key]
+	if !ok {
+		if msg, ok = bundles[DefaultLanguage][key]; !ok {
+			msg = key
+		}
+	}
+	if len(args) > 0 {
+		return fmt.Sprintf(msg, args...)
+	}
+	return msg
+}
+
+// SetLanguage switches the current language. Unsupported languages select
+// DefaultLanguage. Returns the language in effect.
+func SetLanguage(lang string) string {
+	lang = normalize(lang)
+	if _, ok := bundles[lang]; !ok {
+		lang = DefaultLanguage
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	current = lang
+	return lang
+}
+
+// Language returns the current language.
+func Language() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// Languages returns the supported languages, sorted.
+func Languages() []string {
+	langs := make([]string, 0, len(bundles))
+	for lang := range bundles {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Detect picks the language to use: the configured one if supported,
+// otherwise the first supported locale from LC_ALL, LC_MESSAGES or LANG.
+func Detect(configured string) string {
+	candidates := []string{configured, os.Getenv("LC_ALL"), os.Getenv("LC_MESSAGES"), os.Getenv("LANG")}
+	for _, c := range candidates {
+		if lang := normalize(c); lang != "" {
+			if _, ok := bundles[lang]; ok {
+				return lang
+			}
+		}
+	}
+	return DefaultLanguage
+}
+
+// normalize reduces a locale such as "ru_RU.UTF-8" or "en-US" to its
+// primary language subtag.
+func normalize(locale string) string {
+	locale = strings.ToLower(strings.TrimSpace(locale))
+	if i := strings.IndexAny(locale, "_-.@"); i >= 0 {
+		locale = locale[:i]
+	}
+	if locale == "c" || locale == "posix" {
+		return ""
+	}
+	return locale
+}
@@ -0,0 +1,80 @@
+package i18n
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestBundlesComplete(t *testing.T) {
+	en := bundles[DefaultLanguage]
+	if len(en) == 0 {
+		t.Fatal("English bundle is empty")
+	}
+	for _, lang := range Languages() {
+		for key, msg := range en {
+			translated, ok := bundles[lang][key]
+			if !ok {
+				t.Errorf("%s: missing key %q", lang, key)
+				continue
+			}
+			if strings.Count(translated, "%") != strings.Count(msg, "%") {
+				t.Errorf("%s: key %q has mismatched format verbs", lang, key)
+			}
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	defer SetLanguage(DefaultLanguage)
+
+	SetLanguage("en")
+	if got := T("cli.token_saved", "/tmp/x"); got != "Token saved to /tmp/x" {
+		t.Errorf("en = %q", got)
+	}
+
+	SetLanguage("ru")
+	if got := T("cli.token_saved", "/tmp/x"); got != "Токен сохранён в /tmp/x" {
+		t.Errorf("ru = %q", got)
+	}
+	if got := T("no.such.key"); got != "no.such.key" {
+		t.Errorf("unknown key = %q", got)
+	}
+}
+
+func TestSetLanguage_Unsupported(t *testing.T) {
+	defer SetLanguage(DefaultLanguage)
+
+	if got := SetLanguage("xx"); got != DefaultLanguage {
+		t.Errorf("SetLanguage(xx) = %q, want %q", got, DefaultLanguage)
+	}
+	if got := SetLanguage("ru_RU.UTF-8"); got != "ru" {
+		t.Errorf("SetLanguage(ru_RU.UTF-8) = %q, want ru", got)
+	}
+}
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured string
+		lcAll      string
+		lang       string
+		want       string
+	}{
+		{"config wins", "ru", "", "en_US.UTF-8", "ru"},
+		{"LANG", "", "", "ru_RU.UTF-8", "ru"},
+		{"LC_ALL overrides LANG", "", "en_US.UTF-8", "ru_RU.UTF-8", "en"},
+		{"unsupported config", "de", "", "ru_RU", "ru"},
+		{"POSIX locale", "", "", "C", "en"},
+		{"nothing set", "", "", "", "en"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LC_ALL", tt.lcAll)
+			t.Setenv("LC_MESSAGES", "")
+			t.Setenv("LANG", tt.lang)
+			if got := Detect(tt.configured); got != tt.want {
+				t.Errorf("Detect(%q) = %q, want %q", tt.configured, got, tt.want)
+			}
+		})
+	}
+}
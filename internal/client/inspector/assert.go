@@ -0,0 +1,160 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Assertion checks the response of a replayed request. Exactly one of
+// Status, BodyContains and JSONPath is set; JSONPath compares the value at
+// a path like "$.data.items[0].id" with the JSON value Equals.
+type Assertion struct {
+	Status       int             `json:"status,omitempty"`
+	BodyContains string          `json:"body_contains,omitempty"`
+	JSONPath     string          `json:"json_path,omitempty"`
+	Equals       json.RawMessage `json:"equals,omitempty"`
+}
+
+// AssertionResult is the outcome of an assertion; Actual is the value
+// found in the response, if any.
+type AssertionResult struct {
+	Assertion
+	Pass   bool   `json:"pass"`
+	Actual string `json:"actual,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// validate reports whether a is well-formed.
+func (a Assertion) validate() error {
+	set := 0
+	for _, ok := range []bool{a.Status != 0, a.BodyContains != "", a.JSONPath != ""} {
+		if ok {
+			set++
+		}
+	}
+	if set != 1 {
+		return errors.New("an assertion needs exactly one of status, body_contains and json_path")
+	}
+	if a.JSONPath != "" {
+		if _, err := parseJSONPath(a.JSONPath); err != nil {
+			return err
+		}
+		var v any
+		if err := json.Unmarshal(a.Equals, &v); err != nil {
+			return fmt.Errorf("json_path %s: equals must be a JSON value", a.JSONPath)
+		}
+	}
+	return nil
+}
+
+// check runs a against a response.
+func (a Assertion) check(resp *http.Response, body []byte) AssertionResult {
+	result := AssertionResult{Assertion: a}
+	switch {
+	case a.Status != 0:
+		result.Actual = strconv.Itoa(resp.StatusCode)
+		result.Pass = resp.StatusCode == a.Status
+	case a.BodyContains != "":
+		result.Pass = bytes.Contains(body, []byte(a.BodyContains))
+	default:
+		var doc, want any
+		if err := json.Unmarshal(body, &doc); err != nil {
+			result.Error = "response is not JSON"
+			return result
+		}
+		path, _ := parseJSONPath(a.JSONPath)
+		got, ok := lookupJSONPath(doc, path)
+		if !ok {
+			result.Error = "path not found"
+			return result
+		}
+		actual, _ := json.Marshal(got)
+		result.Actual = string(actual)
+		json.Unmarshal(a.Equals, &want)
+		result.Pass = reflect.DeepEqual(got, want)
+	}
+	return result
+}
+
+// checkAssertions runs assertions against a response and reports whether
+// all passed.
+func checkAssertions(assertions []Assertion, resp *http.Response, body []byte) ([]AssertionResult, bool) {
+	results := make([]AssertionResult, 0, len(assertions))
+	passed := true
+	for _, a := range assertions {
+		result := a.check(resp, body)
+		passed = passed && result.Pass
+		results = append(results, result)
+	}
+	return results, passed
+}
+
+// parseJSONPath splits a path like "$.items[0].name" or "items.0.name"
+// into object keys and array indexes. Keys with dots or brackets can be
+// quoted: $["a.b"].
+func parseJSONPath(path string) ([]string, error) {
+	rest := strings.TrimPrefix(path, "$")
+	var parts []string
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSON path %q", path)
+			}
+			parts, rest = append(parts, rest[:end]), rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: missing ]", path)
+			}
+			key := rest[1:end]
+			if unquoted, err := strconv.Unquote(key); err == nil {
+				key = unquoted
+			} else if _, err := strconv.Atoi(key); err != nil {
+				return nil, fmt.Errorf("invalid JSON path %q: %s is neither an index nor a quoted key", path, key)
+			}
+			parts, rest = append(parts, key), rest[end+1:]
+		default:
+			if len(parts) > 0 || path[0] == '$' {
+				return nil, fmt.Errorf("invalid JSON path %q", path)
+			}
+			rest = "." + rest // A leading key without "$."
+		}
+	}
+	return parts, nil
+}
+
+// lookupJSONPath returns the value at path in a decoded JSON document.
+// Numeric parts index arrays and are keys in objects.
+func lookupJSONPath(doc any, path []string) (any, bool) {
+	for _, part := range path {
+		switch v := doc.(type) {
+		case map[string]any:
+			next, ok := v[part]
+			if !ok {
+				return nil, false
+			}
+			doc = next
+		case []any:
+			i, err := strconv.Atoi(part)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			doc = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return doc, true
+}
@@ -0,0 +1,108 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseJSONPath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []string
+	}{
+		{"$", nil},
+		{"$.data.items[0].id", []string{"data", "items", "0", "id"}},
+		{"data.items.0.id", []string{"data", "items", "0", "id"}},
+		{`$["a.b"][1]`, []string{"a.b", "1"}},
+	}
+	for _, tt := range tests {
+		got, err := parseJSONPath(tt.path)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseJSONPath(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+	for _, path := range []string{"$.", "$..a", "$[x]", "$[0", "$.a[0]b", "$a"} {
+		if _, err := parseJSONPath(path); err == nil {
+			t.Errorf("parseJSONPath(%q) succeeded, want an error", path)
+		}
+	}
+}
+
+func TestCheckAssertions(t *testing.T) {
+	resp := &http.Response{StatusCode: http.StatusCreated}
+	body := []byte(`{"ok":true,"data":{"items":[{"id":7,"tags":["a"]}]}}`)
+
+	tests := []struct {
+		assertion Assertion
+		pass      bool
+	}{
+		{Assertion{Status: 201}, true},
+		{Assertion{Status: 200}, false},
+		{Assertion{BodyContains: `"ok":true`}, true},
+		{Assertion{BodyContains: "error"}, false},
+		{Assertion{JSONPath: "$.data.items[0].id", Equals: json.RawMessage(`7`)}, true},
+		{Assertion{JSONPath: "$.data.items[0].tags", Equals: json.RawMessage(`["a"]`)}, true},
+		{Assertion{JSONPath: "$.ok", Equals: json.RawMessage(`"true"`)}, false},
+		{Assertion{JSONPath: "$.data.items[1]", Equals: json.RawMessage(`null`)}, false},
+	}
+	for _, tt := range tests {
+		results, passed := checkAssertions([]Assertion{tt.assertion}, resp, body)
+		if passed != tt.pass || results[0].Pass != tt.pass {
+			t.Errorf("%+v: pass = %v, want %v (%+v)", tt.assertion, passed, tt.pass, results[0])
+		}
+	}
+
+	results, _ := checkAssertions([]Assertion{{JSONPath: "$.a", Equals: json.RawMessage(`1`)}}, resp, []byte("<html>"))
+	if results[0].Error != "response is not JSON" {
+		t.Errorf("assertion on HTML = %+v", results[0])
+	}
+}
+
+func TestServer_ReplayAssertions(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer local.Close()
+
+	store := NewInMemoryStore(10)
+	store.Insert(HTTPExchange{ID: 1, Request: &HTTPRequest{Method: "GET", URL: "/health"}})
+	srv := NewServer(store)
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	replay := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/replay/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := replay(`{"target":"` + local.URL + `","assertions":[{"status":200},{"json_path":"$.status","equals":"ok"},{"body_contains":"fail"}]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("replay = %d: %s", w.Code, w.Body)
+	}
+	var result struct {
+		Passed     bool              `json:"passed"`
+		Assertions []AssertionResult `json:"assertions"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Passed || len(result.Assertions) != 3 || !result.Assertions[0].Pass || !result.Assertions[1].Pass || result.Assertions[2].Pass {
+		t.Errorf("result = %+v, want the last assertion to fail", result)
+	}
+
+	for _, body := range []string{
+		`{"assertions":[{}]}`,
+		`{"assertions":[{"status":200,"body_contains":"ok"}]}`,
+		`{"assertions":[{"json_path":"$.a","equals":nope}]}`,
+		`{"assertions":[{"json_path":"$.a"}]}`,
+	} {
+		if w := replay(body); w.Code != http.StatusBadRequest {
+			t.Errorf("replay with %s = %d, want 400", body, w.Code)
+		}
+	}
+}
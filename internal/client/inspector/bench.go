@@ -0,0 +1,201 @@
+package inspector
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Bench limits: a smoke load test of the local app, not a load generator.
+const (
+	defaultBenchCount = 100
+	maxBenchCount     = 10000
+	maxBenchRate      = 1000 // Requests per second
+	benchConcurrency  = 64   // Requests in flight at most
+)
+
+// BenchOptions replays an exchange Count times at Rate requests per
+// second, with the same overrides as a single replay. A Rate of 0 sends
+// the requests as fast as benchConcurrency allows.
+type BenchOptions struct {
+	ReplayOverrides
+	Count int     `json:"count"`
+	Rate  float64 `json:"rate"`
+}
+
+// BenchLatency summarizes the latencies of a bench in milliseconds, from
+// sending the request to reading the whole response.
+type BenchLatency struct {
+	Min  float64 `json:"min"`
+	Mean float64 `json:"mean"`
+	P50  float64 `json:"p50"`
+	P90  float64 `json:"p90"`
+	P99  float64 `json:"p99"`
+	Max  float64 `json:"max"`
+}
+
+// BenchResult is the outcome of a bench. Errors counts requests that got
+// no response; Statuses counts the others by status code.
+type BenchResult struct {
+	Target     string       `json:"target"`
+	Requests   int          `json:"requests"`
+	Errors     int          `json:"errors"`
+	Statuses   map[int]int  `json:"statuses"`
+	DurationMS float64      `json:"duration_ms"`
+	Rate       float64      `json:"rate"` // Achieved requests per second
+	Latency    BenchLatency `json:"latency_ms"`
+	LastError  string       `json:"last_error,omitempty"`
+}
+
+// runBench sends request to base opts.Count times, paced at opts.Rate,
+// until done or ctx is cancelled.
+func runBench(ctx context.Context, client *http.Client, base string, request HTTPRequest, opts BenchOptions) BenchResult {
+	result := BenchResult{Target: base, Statuses: make(map[int]int)}
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		latencies []time.Duration
+	)
+	send := func() {
+		defer wg.Done()
+		start := time.Now()
+		status, err := benchRequest(ctx, client, base, request)
+		elapsed := time.Since(start)
+
+		mu.Lock()
+		defer mu.Unlock()
+		if err != nil {
+			result.Errors++
+			result.LastError = err.Error()
+			return
+		}
+		result.Statuses[status]++
+		latencies = append(latencies, elapsed)
+	}
+
+	var interval time.Duration
+	if opts.Rate > 0 {
+		interval = time.Duration(float64(time.Second) / opts.Rate)
+	}
+	inFlight := make(chan struct{}, benchConcurrency)
+	start := time.Now()
+	for i := 0; i < opts.Count; i++ {
+		// Pace from the start, so slow responses do not lower the rate
+		if wait := time.Until(start.Add(time.Duration(i) * interval)); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		inFlight <- struct{}{}
+		result.Requests++
+		wg.Add(1)
+		go func() {
+			defer func() { <-inFlight }()
+			send()
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(start)
+	result.DurationMS = milliseconds(elapsed)
+	if elapsed > 0 {
+		result.Rate = float64(result.Requests) / elapsed.Seconds()
+	}
+	result.Latency = summarizeLatencies(latencies)
+	return result
+}
+
+// benchRequest sends one request of a bench and reads the response.
+func benchRequest(ctx context.Context, client *http.Client, base string, request HTTPRequest) (int, error) {
+	req, err := request.newHTTPRequest(ctx, base)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return 0, err
+	}
+	return resp.StatusCode, nil
+}
+
+// summarizeLatencies returns the minimum, mean, percentiles and maximum
+// of latencies, which it sorts.
+func summarizeLatencies(latencies []time.Duration) BenchLatency {
+	if len(latencies) == 0 {
+		return BenchLatency{}
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	var total time.Duration
+	for _, l := range latencies {
+		total += l
+	}
+	percentile := func(p float64) float64 {
+		i := int(math.Ceil(p*float64(len(latencies)))) - 1
+		return milliseconds(latencies[max(i, 0)])
+	}
+	return BenchLatency{
+		Min:  milliseconds(latencies[0]),
+		Mean: milliseconds(total / time.Duration(len(latencies))),
+		P50:  percentile(0.50),
+		P90:  percentile(0.90),
+		P99:  percentile(0.99),
+		Max:  milliseconds(latencies[len(latencies)-1]),
+	}
+}
+
+// milliseconds converts d to fractional milliseconds.
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// handleBench replays a stored request repeatedly and answers with a
+// BenchResult once all requests are done. The bench stops early when the
+// caller goes away.
+func (s *Server) handleBench(w http.ResponseWriter, r *http.Request, idStr string) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	opts := BenchOptions{Count: defaultBenchCount}
+	if !readJSONBody(w, r, &opts) {
+		return
+	}
+	if opts.Count <= 0 || opts.Count > maxBenchCount {
+		http.Error(w, "count must be between 1 and 10000", http.StatusBadRequest)
+		return
+	}
+	if opts.Rate < 0 || opts.Rate > maxBenchRate {
+		http.Error(w, "rate must be between 0 and 1000 requests per second", http.StatusBadRequest)
+		return
+	}
+	base, request, ok := s.prepareReplay(w, idStr, &opts.ReplayOverrides)
+	if !ok {
+		return
+	}
+
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{MaxIdleConnsPerHost: benchConcurrency},
+	}
+	defer client.CloseIdleConnections()
+	result := runBench(r.Context(), client, base, request, opts)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
@@ -0,0 +1,105 @@
+package inspector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunBench(t *testing.T) {
+	var hits atomic.Int64
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hits.Add(1)%4 == 0 {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer local.Close()
+
+	result := runBench(context.Background(), local.Client(), local.URL, HTTPRequest{Method: "GET", URL: "/"}, BenchOptions{Count: 20})
+	if result.Requests != 20 || result.Errors != 0 || hits.Load() != 20 {
+		t.Fatalf("result = %+v, hits = %d; want 20 requests", result, hits.Load())
+	}
+	if result.Statuses[200] != 15 || result.Statuses[500] != 5 {
+		t.Errorf("statuses = %v, want 15 x 200 and 5 x 500", result.Statuses)
+	}
+	l := result.Latency
+	if l.Min <= 0 || l.Min > l.P50 || l.P50 > l.P99 || l.P99 > l.Max {
+		t.Errorf("latency = %+v, want ordered", l)
+	}
+}
+
+func TestRunBench_Rate(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer local.Close()
+
+	start := time.Now()
+	result := runBench(context.Background(), local.Client(), local.URL, HTTPRequest{Method: "GET", URL: "/"}, BenchOptions{Count: 5, Rate: 50})
+	// 5 requests at 50/s start over 80ms
+	if elapsed := time.Since(start); elapsed < 80*time.Millisecond {
+		t.Errorf("bench took %v, want at least 80ms at 50 requests per second", elapsed)
+	}
+	if result.Requests != 5 {
+		t.Errorf("requests = %d, want 5", result.Requests)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if result := runBench(ctx, local.Client(), local.URL, HTTPRequest{Method: "GET", URL: "/"}, BenchOptions{Count: 5, Rate: 1}); result.Requests != 0 {
+		t.Errorf("cancelled bench sent %d requests", result.Requests)
+	}
+}
+
+func TestSummarizeLatencies(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i >= 1; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	got := summarizeLatencies(latencies)
+	want := BenchLatency{Min: 1, Mean: 50.5, P50: 50, P90: 90, P99: 99, Max: 100}
+	if got != want {
+		t.Errorf("summarizeLatencies() = %+v, want %+v", got, want)
+	}
+	if got := summarizeLatencies(nil); got != (BenchLatency{}) {
+		t.Errorf("summarizeLatencies(nil) = %+v", got)
+	}
+}
+
+func TestServer_BenchEndpoint(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer local.Close()
+
+	store := NewInMemoryStore(10)
+	store.Insert(HTTPExchange{ID: 1, Request: &HTTPRequest{Method: "GET", URL: "/health"}})
+	srv := NewServer(store)
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	bench := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/bench/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := bench(`{"count":3,"target":"` + local.URL + `"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("bench = %d: %s", w.Code, w.Body)
+	}
+	var result BenchResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Requests != 3 || result.Statuses[200] != 3 || result.Target != local.URL {
+		t.Errorf("result = %+v", result)
+	}
+
+	for _, body := range []string{`{"count":0}`, `{"count":10001}`, `{"rate":-1}`, `{"rate":5000}`} {
+		if w := bench(body); w.Code != http.StatusBadRequest {
+			t.Errorf("bench with %s = %d, want 400", body, w.Code)
+		}
+	}
+}
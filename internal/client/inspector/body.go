@@ -0,0 +1,214 @@
+package inspector
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// maxRawBodyBytes bounds the raw bodies a server keeps for download
+	// and replay, see bodyStore.
+	maxRawBodyBytes = 32 * 1024 * 1024
+	// binaryPreviewSize is how much of a binary body the preview shows.
+	binaryPreviewSize = 64
+	// sniffSize is how much of a body without a known media type is
+	// checked for binary data.
+	sniffSize = 512
+)
+
+// binaryTypes are media types and prefixes of bodies that are never text.
+var binaryTypes = []string{
+	"image/", "audio/", "video/", "font/",
+	"application/octet-stream", "application/protobuf", "application/x-protobuf",
+	"application/grpc", "application/pdf", "application/zip", "application/gzip",
+	"application/wasm", "application/msgpack", "application/x-msgpack",
+}
+
+// isBinaryBody reports whether a body should be kept as a hash and a
+// preview rather than text: by its media type, or for other types if it
+// contains NUL bytes or invalid UTF-8.
+func isBinaryBody(headers map[string][]string, body []byte) bool {
+	if len(body) == 0 {
+		return false
+	}
+	mt := mediaType(headers)
+	if strings.HasPrefix(mt, "text/") || strings.HasSuffix(mt, "json") || strings.HasSuffix(mt, "xml") ||
+		mt == "application/javascript" || mt == "application/x-www-form-urlencoded" {
+		return false
+	}
+	for _, t := range binaryTypes {
+		if strings.HasPrefix(mt, t) {
+			return true
+		}
+	}
+
+	sniff := body[:min(len(body), sniffSize)]
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		return true
+	}
+	for i := 0; i < len(sniff); {
+		r, n := utf8.DecodeRune(sniff[i:])
+		if r == utf8.RuneError && n == 1 {
+			// A rune cut at the end of the sniffed part is not an error
+			return len(sniff) == len(body) || len(sniff)-i >= utf8.UTFMax
+		}
+		i += n
+	}
+	return false
+}
+
+// BinaryBody describes a body kept as a hash and a preview instead of
+// text. The raw bytes can be downloaded while the server keeps them.
+type BinaryBody struct {
+	Binary  bool   `json:"binary,omitempty"`
+	SHA256  string `json:"sha256,omitempty"`
+	Preview string `json:"preview,omitempty"` // Hex dump of the first bytes
+}
+
+func (b *BinaryBody) set(body []byte) {
+	sum := sha256.Sum256(body)
+	b.Binary = true
+	b.SHA256 = hex.EncodeToString(sum[:])
+	b.Preview = hex.Dump(body[:min(len(body), binaryPreviewSize)])
+}
+
+// bodyKey identifies the request or response body of an exchange.
+type bodyKey struct {
+	id       int64
+	response bool
+}
+
+// bodyStore keeps the raw bytes of bodies the exchanges do not hold in
+// full, binary bodies and text beyond maxBodySize, up to limit bytes in
+// total. The oldest bodies are dropped first.
+type bodyStore struct {
+	mu     sync.Mutex
+	limit  int64
+	size   int64
+	bodies map[bodyKey][]byte
+	order  []bodyKey
+}
+
+func (b *bodyStore) put(key bodyKey, body []byte) {
+	if int64(len(body)) > b.limit {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.bodies == nil {
+		b.bodies = make(map[bodyKey][]byte)
+	}
+	for b.size+int64(len(body)) > b.limit && len(b.order) > 0 {
+		oldest := b.order[0]
+		b.order = b.order[1:]
+		b.size -= int64(len(b.bodies[oldest]))
+		delete(b.bodies, oldest)
+	}
+	b.bodies[key] = body
+	b.order = append(b.order, key)
+	b.size += int64(len(body))
+}
+
+func (b *bodyStore) get(key bodyKey) ([]byte, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	body, ok := b.bodies[key]
+	return body, ok
+}
+
+func (b *bodyStore) clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.bodies = nil
+	b.order = nil
+	b.size = 0
+}
+
+// keepRaw stores body for download if the exchange does not hold it in full.
+func (s *Server) keepRaw(key bodyKey, binary bool, body []byte) {
+	if binary || int64(len(body)) > maxBodySize {
+		s.bodies.put(key, body)
+	}
+}
+
+// rawBody returns the full body of a side of an exchange: the raw bytes
+// if they are kept, else the stored text unless it was cut or is binary.
+func (s *Server) rawBody(ex *HTTPExchange, response bool) ([]byte, bool) {
+	if raw, ok := s.bodies.get(bodyKey{ex.ID, response}); ok {
+		return raw, true
+	}
+	if response {
+		resp := ex.Response
+		if resp == nil || resp.Binary || resp.Size > maxBodySize || (resp.Decoded && resp.DecodedSize > maxBodySize) {
+			return nil, false
+		}
+		return []byte(resp.Body), true
+	}
+	if ex.Request.Binary || ex.Request.Size > maxBodySize {
+		return nil, false
+	}
+	return []byte(ex.Request.Body), true
+}
+
+// handleBody serves the raw bytes of a request or response body as a
+// download: GET /api/exchanges/:id/body?side=request|response. Response
+// bodies are served decoded.
+func (s *Server) handleBody(w http.ResponseWriter, r *http.Request, idStr string) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	side := r.URL.Query().Get("side")
+	if side == "" {
+		side = "response"
+	}
+	if side != "request" && side != "response" {
+		http.Error(w, "side must be request or response", http.StatusBadRequest)
+		return
+	}
+	ex, ok := s.store.Get(id)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	response := side == "response"
+	headers := ex.Request.Headers
+	if response {
+		if ex.Response == nil {
+			http.Error(w, "No response", http.StatusNotFound)
+			return
+		}
+		headers = ex.Response.Headers
+	}
+	body, ok := s.rawBody(ex, response)
+	if !ok {
+		http.Error(w, "Body is no longer kept", http.StatusGone)
+		return
+	}
+
+	ct := http.Header(headers).Get("Content-Type")
+	if ct == "" {
+		ct = "application/octet-stream"
+	}
+	name := fmt.Sprintf("exchange-%d-%s", id, side)
+	if exts, _ := mime.ExtensionsByType(mediaType(headers)); len(exts) > 0 {
+		name += exts[0]
+	}
+	// Captured content must not run on the inspector's origin
+	w.Header().Set("Content-Type", ct)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "sandbox")
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.Write(body)
+}
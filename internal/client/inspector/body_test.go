@@ -0,0 +1,129 @@
+package inspector
+
+import (
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIsBinaryBody(t *testing.T) {
+	tests := []struct {
+		name        string
+		contentType string
+		body        []byte
+		want        bool
+	}{
+		{"json", "application/json; charset=utf-8", []byte(`{"a":1}`), false},
+		{"svg", "image/svg+xml", []byte("<svg/>"), false},
+		{"png", "image/png", []byte("\x89PNG\r\n"), true},
+		{"protobuf", "application/x-protobuf", []byte("\x08\x96\x01"), true},
+		{"untyped text", "", []byte("hello, мир"), false},
+		{"untyped with NUL", "", []byte("ab\x00cd"), true},
+		{"invalid utf-8", "application/custom", []byte("ab\xffcd"), true},
+		{"empty", "image/png", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string][]string{}
+			if tt.contentType != "" {
+				headers["Content-Type"] = []string{tt.contentType}
+			}
+			if got := isBinaryBody(headers, tt.body); got != tt.want {
+				t.Errorf("isBinaryBody() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// A rune cut where sniffing stops is still text
+	long := strings.Repeat("a", sniffSize-1) + "мир"
+	if isBinaryBody(map[string][]string{}, []byte(long)) {
+		t.Error("isBinaryBody() = true for text with a rune across the sniff limit")
+	}
+}
+
+func TestBodyStore_Evicts(t *testing.T) {
+	b := bodyStore{limit: 10}
+	b.put(bodyKey{1, false}, []byte("aaaa"))
+	b.put(bodyKey{2, false}, []byte("bbbb"))
+	b.put(bodyKey{3, true}, []byte("cccc"))
+	if _, ok := b.get(bodyKey{1, false}); ok {
+		t.Error("oldest body kept beyond the limit")
+	}
+	if got, ok := b.get(bodyKey{3, true}); !ok || string(got) != "cccc" {
+		t.Errorf("get() = %q, %v", got, ok)
+	}
+	b.put(bodyKey{4, false}, bytes.Repeat([]byte("d"), 11))
+	if _, ok := b.get(bodyKey{4, false}); ok {
+		t.Error("body larger than the limit was kept")
+	}
+}
+
+func TestServer_BinaryBody(t *testing.T) {
+	got := make(chan []byte, 1)
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- body
+	}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	srv := NewServer(nil, WithLocalPort(port))
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	upload := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0xff}, 200)...)
+	req, _ := http.NewRequest("POST", "/avatar", nil)
+	req.Header.Set("Content-Type", "image/png")
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/html"}}}
+	id := srv.AddExchange(req, upload, resp, []byte("<script>alert(1)</script>"), 0)
+	srv.Flush()
+
+	ex, _ := srv.store.Get(id)
+	if !ex.Request.Binary || ex.Request.Body != "" || ex.Request.Size != int64(len(upload)) {
+		t.Fatalf("request = %+v, want a binary body without text", ex.Request)
+	}
+	if len(ex.Request.SHA256) != 64 || !strings.Contains(ex.Request.Preview, "89 50 4e 47") {
+		t.Errorf("hash %q, preview %q", ex.Request.SHA256, ex.Request.Preview)
+	}
+
+	download := func(side string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/exchanges/"+strconv.FormatInt(id, 10)+"/body?side="+side, nil))
+		return w
+	}
+	w := download("request")
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), upload) || w.Header().Get("Content-Type") != "image/png" {
+		t.Errorf("request body download = %d %q", w.Code, w.Header().Get("Content-Type"))
+	}
+	// Captured HTML must not render on the inspector's origin
+	w = download("response")
+	if w.Body.String() != "<script>alert(1)</script>" || !strings.HasPrefix(w.Header().Get("Content-Disposition"), "attachment") ||
+		w.Header().Get("Content-Security-Policy") != "sandbox" {
+		t.Errorf("response body download = %q, headers %v", w.Body, w.Header())
+	}
+	if w := download("both"); w.Code != http.StatusBadRequest {
+		t.Errorf("side=both = %d, want 400", w.Code)
+	}
+
+	// Replay sends the raw bytes, not the preview
+	r := httptest.NewRequest(http.MethodPost, "/api/replay/"+strconv.FormatInt(id, 10), nil)
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("replay = %d: %s", w.Code, w.Body)
+	}
+	if body := <-got; !bytes.Equal(body, upload) {
+		t.Errorf("replay sent %d bytes, want the %d captured", len(body), len(upload))
+	}
+
+	// Without the raw bytes a binary body cannot be downloaded
+	srv.bodies.clear()
+	if w := download("request"); w.Code != http.StatusGone {
+		t.Errorf("download after eviction = %d, want 410", w.Code)
+	}
+}
@@ -0,0 +1,218 @@
+package inspector
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// captureQueueSize bounds the captures waiting for the writer goroutine.
+const captureQueueSize = 1024
+
+// captureQueue feeds a server's store. Captures are stored by a single
+// writer goroutine, started with the first capture, so that storing
+// exchanges never delays proxied requests. When the writer falls behind,
+// new exchanges are dropped and counted instead of waited for; updates of
+// queued exchanges always wait.
+// A capture returns the ID of the exchange it added or updated, or -1.
+type captureQueue struct {
+	queue   chan func(Store) int64
+	writer  sync.Once
+	dropped atomic.Int64
+	nextID  atomic.Int64
+}
+
+// startCaptureWriter starts writeCaptures once.
+func (s *Server) startCaptureWriter() {
+	s.captures.writer.Do(func() { go s.writeCaptures() })
+}
+
+// writeCaptures applies queued captures to the store in order and streams
+// the exchanges they touch.
+func (s *Server) writeCaptures() {
+	for capture := range s.captures.queue {
+		s.stream.publishStored(s.store, capture(s.store))
+	}
+}
+
+// enqueue queues capture without blocking. Reports false if the queue is
+// full and the capture was dropped.
+func (s *Server) enqueue(capture func(Store) int64) bool {
+	s.startCaptureWriter()
+	select {
+	case s.captures.queue <- capture:
+		return true
+	default:
+		s.captures.dropped.Add(1)
+		return false
+	}
+}
+
+// enqueueUpdate queues an update of a queued exchange, waiting for room
+// if needed. Updates of dropped exchanges (id -1) are skipped.
+func (s *Server) enqueueUpdate(id int64, update func(*HTTPExchange)) {
+	if id < 0 {
+		return
+	}
+	s.startCaptureWriter()
+	s.captures.queue <- func(store Store) int64 {
+		store.Update(id, update)
+		return id
+	}
+}
+
+// enqueueExchange queues an exchange and returns the ID it will be stored
+// under, or -1 if it was dropped. build runs on the writer goroutine.
+func (s *Server) enqueueExchange(build func() HTTPExchange, then func(*HTTPExchange)) int64 {
+	id := s.captures.nextID.Add(1) - 1
+	ok := s.enqueue(func(store Store) int64 {
+		ex := build()
+		ex.ID = id
+		if then != nil {
+			then(&ex)
+		}
+		store.Insert(ex)
+		return id
+	})
+	if !ok {
+		return -1
+	}
+	return id
+}
+
+// newExchange converts a proxied request and its response, if any, to an
+// exchange without bodies. Headers are copied, so the caller may modify
+// req and resp once it returns.
+func newExchange(req *http.Request, resp *http.Response, timestamp time.Time, duration time.Duration) HTTPExchange {
+	origin := originOf(req)
+	exchange := HTTPExchange{
+		Timestamp: timestamp,
+		Duration:  duration.Milliseconds(),
+		Tunnel:    origin.Tunnel,
+		LocalPort: origin.LocalPort,
+		Request: &HTTPRequest{
+			Method:  req.Method,
+			URL:     req.URL.String(),
+			Proto:   req.Proto,
+			Headers: req.Header.Clone(),
+		},
+	}
+
+	if resp != nil {
+		exchange.Response = &HTTPResponse{
+			Status:  resp.StatusCode,
+			Proto:   resp.Proto,
+			Headers: resp.Header.Clone(),
+		}
+	}
+	return exchange
+}
+
+// setBodies stores the bodies of an exchange, truncated to maxBodySize.
+// Compressed response bodies are stored decoded; request bodies stay as
+// sent, so that replay matches their Content-Encoding. Binary bodies are
+// stored as a hash and a preview. The raw bytes of bodies not stored in
+// full are kept for download and replay, see bodyStore.
+func (s *Server) setBodies(exchange *HTTPExchange, reqBody, respBody []byte) {
+	exchange.Request.Size = int64(len(reqBody))
+	if isBinaryBody(exchange.Request.Headers, reqBody) {
+		exchange.Request.BinaryBody.set(reqBody)
+	} else {
+		exchange.Request.Body = truncateBody(reqBody)
+	}
+	s.keepRaw(bodyKey{exchange.ID, false}, exchange.Request.Binary, reqBody)
+
+	if resp := exchange.Response; resp != nil {
+		resp.Size = int64(len(respBody))
+		if codings := contentCodings(http.Header(resp.Headers)); len(codings) > 0 && len(respBody) > 0 {
+			resp.ContentEncoding = strings.Join(codings, ", ")
+			if decoded, err := decodeBody(respBody, codings); err == nil {
+				respBody = decoded
+				resp.Decoded = true
+				resp.DecodedSize = int64(len(decoded))
+			}
+		}
+		if isBinaryBody(resp.Headers, respBody) {
+			resp.BinaryBody.set(respBody)
+		} else {
+			resp.Body = truncateBody(respBody)
+		}
+		s.keepRaw(bodyKey{exchange.ID, true}, resp.Binary, respBody)
+	}
+}
+
+// AddExchange queues a complete HTTP exchange for recording and returns
+// its ID, or -1 if the capture was dropped. Headers are copied before it
+// returns; the bodies must not be modified afterwards. A nil Server, as
+// used by tunnels without an inspector, records nothing.
+func (s *Server) AddExchange(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, duration time.Duration) int64 {
+	if s == nil {
+		return -1
+	}
+	exchange := newExchange(req, resp, time.Now(), duration)
+	return s.enqueueExchange(func() HTTPExchange { return exchange }, func(ex *HTTPExchange) {
+		s.setBodies(ex, reqBody, respBody)
+	})
+}
+
+// MarkAborted records that the public caller disconnected after bytesSent
+// bytes of the exchange's response.
+func (s *Server) MarkAborted(id, bytesSent int64) {
+	if s == nil {
+		return
+	}
+	s.enqueueUpdate(id, func(ex *HTTPExchange) {
+		ex.Aborted = true
+		ex.BytesSent = bytesSent
+	})
+}
+
+// AddUpgrade records a request the local service upgraded, e.g. to
+// WebSocket, as an open WebSocket exchange. Call CloseUpgrade when the
+// connection ends.
+func (s *Server) AddUpgrade(req *http.Request, resp *http.Response) int64 {
+	if s == nil {
+		return -1
+	}
+	exchange := newExchange(req, resp, time.Now(), 0)
+	return s.enqueueExchange(func() HTTPExchange { return exchange }, func(ex *HTTPExchange) {
+		ex.WebSocket = true
+		ex.WSOpen = true
+	})
+}
+
+// CloseUpgrade records the end of an upgraded connection, its total
+// duration and the bytes copied each way.
+func (s *Server) CloseUpgrade(id, bytesIn, bytesOut int64, duration time.Duration) {
+	if s == nil {
+		return
+	}
+	s.enqueueUpdate(id, func(ex *HTTPExchange) {
+		ex.WSOpen = false
+		ex.WSIn = bytesIn
+		ex.WSOut = bytesOut
+		ex.Duration = duration.Milliseconds()
+	})
+}
+
+// Flush waits until the captures queued so far are stored.
+func (s *Server) Flush() {
+	s.startCaptureWriter()
+	done := make(chan struct{})
+	s.captures.queue <- func(Store) int64 {
+		close(done)
+		return -1
+	}
+	<-done
+}
+
+// DroppedCaptures returns how many captures were dropped because the
+// writer fell behind.
+func (s *Server) DroppedCaptures() int64 {
+	if s == nil {
+		return 0
+	}
+	return s.captures.dropped.Load()
+}
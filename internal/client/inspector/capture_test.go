@@ -0,0 +1,155 @@
+package inspector
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAddExchange_Queued(t *testing.T) {
+	s := NewServer(nil)
+	req, _ := http.NewRequest("POST", "http://example.com/orders", nil)
+	resp := &http.Response{StatusCode: http.StatusCreated, Proto: "HTTP/1.1", Header: http.Header{}}
+
+	id := s.AddExchange(req, []byte(`{"qty":1}`), resp, []byte("ok"), 0)
+	if id < 0 {
+		t.Fatal("AddExchange() dropped the capture with an empty queue")
+	}
+	s.MarkAborted(id, 1)
+	s.Flush()
+
+	ex, ok := s.store.Get(id)
+	if !ok {
+		t.Fatal("exchange not stored after Flush")
+	}
+	if ex.Request.Body != `{"qty":1}` || ex.Response.Status != http.StatusCreated {
+		t.Errorf("stored exchange = %+v, %+v", ex.Request, ex.Response)
+	}
+	if !ex.Aborted || ex.BytesSent != 1 {
+		t.Errorf("aborted = %v, bytes sent = %d; updates must apply after the add", ex.Aborted, ex.BytesSent)
+	}
+}
+
+func TestAddExchange_CopiesHeaders(t *testing.T) {
+	s := NewServer(nil)
+	req, _ := http.NewRequest("GET", "http://example.com/items?page=1", nil)
+	req.Header.Set("Accept", "text/html")
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Type": {"text/html"}}}
+
+	id := s.AddExchange(req, nil, resp, nil, 0)
+	// The tunnel keeps using both once the capture is queued
+	req.Header.Set("Accept", "changed")
+	req.URL.RawQuery = "page=2"
+	resp.Header.Set("Content-Type", "changed")
+	s.Flush()
+
+	ex, ok := s.store.Get(id)
+	if !ok {
+		t.Fatal("exchange not stored after Flush")
+	}
+	if got := ex.Request.Headers["Accept"]; len(got) != 1 || got[0] != "text/html" {
+		t.Errorf("request Accept = %v, want the value at capture time", got)
+	}
+	if ex.Request.URL != "http://example.com/items?page=1" {
+		t.Errorf("request URL = %q, want the URL at capture time", ex.Request.URL)
+	}
+	if got := ex.Response.Headers["Content-Type"]; len(got) != 1 || got[0] != "text/html" {
+		t.Errorf("response Content-Type = %v, want the value at capture time", got)
+	}
+}
+
+func TestAddExchange_Origin(t *testing.T) {
+	s := NewServer(nil)
+	req, _ := http.NewRequest("GET", "/health", nil)
+	req = WithOrigin(req, Origin{Tunnel: "api", LocalPort: "8080"})
+
+	id := s.AddExchange(req, nil, nil, nil, 0)
+	s.Flush()
+	if ex, _ := s.store.Get(id); ex.Tunnel != "api" || ex.LocalPort != "8080" {
+		t.Errorf("origin = %q, %q; want api, 8080", ex.Tunnel, ex.LocalPort)
+	}
+}
+
+func TestAddExchange_DropsWhenQueueFull(t *testing.T) {
+	s := NewServer(nil)
+	// Hold the writer until the queue is full
+	release := make(chan struct{})
+	held := make(chan struct{})
+	s.startCaptureWriter()
+	s.captures.queue <- func(Store) int64 {
+		close(held)
+		<-release
+		return -1
+	}
+	<-held
+	for i := 0; i < captureQueueSize; i++ {
+		s.enqueue(func(Store) int64 { return -1 })
+	}
+
+	dropped := s.DroppedCaptures()
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if id := s.AddExchange(req, nil, nil, nil, 0); id != -1 {
+		t.Errorf("AddExchange() with a full queue = %d, want -1", id)
+	}
+	if got := s.DroppedCaptures(); got != dropped+1 {
+		t.Errorf("DroppedCaptures() = %d, want %d", got, dropped+1)
+	}
+
+	close(release)
+	s.Flush()
+}
+
+func TestMarkAborted_WaitsWhenQueueFull(t *testing.T) {
+	s := NewServer(nil)
+	req, _ := http.NewRequest("GET", "http://example.com/download", nil)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}
+	id := s.AddExchange(req, nil, resp, nil, 0)
+
+	// Hold the writer until the queue is full
+	release := make(chan struct{})
+	held := make(chan struct{})
+	s.startCaptureWriter()
+	s.captures.queue <- func(Store) int64 {
+		close(held)
+		<-release
+		return -1
+	}
+	<-held
+	for len(s.captures.queue) < captureQueueSize {
+		s.enqueue(func(Store) int64 { return -1 })
+	}
+
+	marked := make(chan struct{})
+	go func() {
+		s.MarkAborted(id, 512)
+		close(marked)
+	}()
+	select {
+	case <-marked:
+		t.Fatal("MarkAborted() returned with a full queue; the update was dropped")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	<-marked
+	s.Flush()
+	if ex, ok := s.store.Get(id); !ok || !ex.Aborted || ex.BytesSent != 512 {
+		t.Errorf("exchange after MarkAborted = %+v, %v; want aborted after 512 bytes", ex, ok)
+	}
+}
+
+func TestNilServer(t *testing.T) {
+	var s *Server
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	if id := s.AddExchange(req, nil, nil, nil, 0); id != -1 {
+		t.Errorf("AddExchange() on a nil server = %d, want -1", id)
+	}
+	s.MarkAborted(-1, 0)
+	if id := s.AddUpgrade(req, &http.Response{StatusCode: http.StatusSwitchingProtocols, Header: http.Header{}}); id != -1 {
+		t.Errorf("AddUpgrade() on a nil server = %d, want -1", id)
+	}
+	s.CloseUpgrade(-1, 0, 0, 0)
+	if got := s.DroppedCaptures(); got != 0 {
+		t.Errorf("DroppedCaptures() on a nil server = %d, want 0", got)
+	}
+}
@@ -0,0 +1,318 @@
+package inspector
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// curlFlags lists the curl options without a value that do not change the
+// request, e.g. -s or --compressed.
+var curlFlags = map[string]bool{
+	"-s": true, "--silent": true, "-S": true, "--show-error": true, "-v": true, "--verbose": true,
+	"-k": true, "--insecure": true, "-L": true, "--location": true, "-i": true, "--include": true,
+	"-f": true, "--fail": true, "--compressed": true, "-N": true, "--no-buffer": true,
+	"-g": true, "--globoff": true, "--http1.1": true, "--http2": true,
+}
+
+// curlIgnored lists the curl options with a value that do not change the
+// request, e.g. -o file.
+var curlIgnored = map[string]bool{
+	"-o": true, "--output": true, "-m": true, "--max-time": true, "--connect-timeout": true,
+	"-w": true, "--write-out": true, "--retry": true, "--cacert": true, "--resolve": true,
+}
+
+// parseCurl converts a curl command line, as copied from browser developer
+// tools or a bug report, to a request. Line continuations and shell quoting
+// are understood; options that would change the request but are not
+// supported, e.g. file uploads, are errors.
+func parseCurl(command string) (*http.Request, []byte, error) {
+	args, err := shellSplit(command)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(args) > 0 && args[0] == "curl" {
+		args = args[1:]
+	}
+
+	var (
+		method, rawURL string
+		data           []string
+		get, head      bool
+		header         = make(http.Header)
+	)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			if rawURL != "" {
+				return nil, nil, fmt.Errorf("more than one URL: %s", arg)
+			}
+			rawURL = arg
+			continue
+		}
+		if curlFlags[arg] {
+			continue
+		}
+		if arg == "-G" || arg == "--get" {
+			get = true
+			continue
+		}
+		if arg == "-I" || arg == "--head" {
+			head = true
+			continue
+		}
+		if isShortFlagGroup(arg) {
+			continue
+		}
+
+		// Options with a value, given as the next argument or attached
+		// to a short option (-XPOST)
+		name, value := arg, ""
+		if !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			name, value = arg[:2], arg[2:]
+		} else {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("option %s needs a value", arg)
+			}
+			i++
+			value = args[i]
+		}
+		switch name {
+		case "-X", "--request":
+			method = value
+		case "-H", "--header":
+			k, v, ok := strings.Cut(value, ":")
+			if !ok {
+				return nil, nil, fmt.Errorf("invalid header %q", value)
+			}
+			header.Add(strings.TrimSpace(k), strings.TrimSpace(v))
+		case "-d", "--data", "--data-raw", "--data-binary", "--data-ascii":
+			if strings.HasPrefix(value, "@") && name != "--data-raw" {
+				return nil, nil, errors.New("request bodies from files are not supported")
+			}
+			data = append(data, value)
+		case "--data-urlencode":
+			if k, v, ok := strings.Cut(value, "="); ok {
+				data = append(data, k+"="+url.QueryEscape(v))
+			} else {
+				data = append(data, url.QueryEscape(value))
+			}
+		case "--json":
+			data = append(data, value)
+			if header.Get("Content-Type") == "" {
+				header.Set("Content-Type", "application/json")
+			}
+			if header.Get("Accept") == "" {
+				header.Set("Accept", "application/json")
+			}
+		case "-u", "--user":
+			header.Set("Authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(value)))
+		case "-A", "--user-agent":
+			header.Set("User-Agent", value)
+		case "-e", "--referer":
+			header.Set("Referer", value)
+		case "-b", "--cookie":
+			header.Add("Cookie", value)
+		case "--url":
+			rawURL = value
+		default:
+			if !curlIgnored[name] {
+				return nil, nil, fmt.Errorf("unsupported curl option %s", name)
+			}
+		}
+	}
+	if rawURL == "" {
+		return nil, nil, errors.New("no URL in the curl command")
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "http://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid URL: %w", err)
+	}
+
+	var body []byte
+	switch {
+	case get && len(data) > 0:
+		if u.RawQuery != "" {
+			u.RawQuery += "&"
+		}
+		u.RawQuery += strings.Join(data, "&")
+	case len(data) > 0:
+		body = []byte(strings.Join(data, "&"))
+		if header.Get("Content-Type") == "" {
+			header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+	}
+	if method == "" {
+		switch {
+		case head:
+			method = http.MethodHead
+		case body != nil:
+			method = http.MethodPost
+		default:
+			method = http.MethodGet
+		}
+	}
+
+	req, err := http.NewRequest(method, u.String(), nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header = header
+	return req, body, nil
+}
+
+// isShortFlagGroup reports whether arg combines short options without a
+// value, e.g. -sSL.
+func isShortFlagGroup(arg string) bool {
+	if strings.HasPrefix(arg, "--") || len(arg) < 3 {
+		return false
+	}
+	for _, c := range arg[1:] {
+		if !curlFlags["-"+string(c)] {
+			return false
+		}
+	}
+	return true
+}
+
+// shellSplit splits a POSIX shell command line into words, handling single
+// and double quotes, backslash escapes and line continuations.
+func shellSplit(s string) ([]string, error) {
+	var (
+		words   []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, c := range s {
+		switch {
+		case escaped:
+			escaped = false
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", c) {
+				word.WriteRune('\\') // Kept before other characters in double quotes
+			}
+			if c != '\n' {
+				word.WriteRune(c)
+				inWord = true
+			}
+		case quote == '\'':
+			if c == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(c)
+			}
+		case quote == '"':
+			switch c {
+			case '"':
+				quote = 0
+			case '\\':
+				escaped = true
+			default:
+				word.WriteRune(c)
+			}
+		case c == '\\':
+			escaped = true
+		case c == '\'' || c == '"':
+			quote = c
+			inWord = true
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(c)
+			inWord = true
+		}
+	}
+	if quote != 0 || escaped {
+		return nil, errors.New("unterminated quote in the curl command")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
+
+// curlCommand renders req, sent to base, as a curl command that parseCurl
+// reads back. Content-Length is left to curl; headers are sorted.
+func curlCommand(base string, req HTTPRequest) string {
+	var b strings.Builder
+	b.WriteString("curl")
+	switch {
+	case req.Method == http.MethodGet && req.Body == "":
+	case req.Method == http.MethodPost && req.Body != "":
+	case req.Method == http.MethodHead && req.Body == "":
+		b.WriteString(" -I")
+	default:
+		b.WriteString(" -X " + shellQuote(req.Method))
+	}
+	b.WriteString(" " + shellQuote(base+req.URL))
+
+	names := make([]string, 0, len(req.Headers))
+	for name := range req.Headers {
+		if !strings.EqualFold(name, "Content-Length") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range req.Headers[name] {
+			b.WriteString(" \\\n  -H " + shellQuote(name+": "+value))
+		}
+	}
+	if req.Body != "" {
+		b.WriteString(" \\\n  --data-raw " + shellQuote(req.Body))
+	}
+	return b.String()
+}
+
+// shellQuote quotes s for a POSIX shell unless it is made only of
+// characters that need no quoting.
+func shellQuote(s string) string {
+	if s != "" && strings.Trim(s, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=@%+,") == "" {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// handleCurl answers with the stored request idStr as a curl command for
+// its local port, or for the base URL in the target query parameter.
+func (s *Server) handleCurl(w http.ResponseWriter, r *http.Request, idStr string) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return
+	}
+	exchange, ok := s.store.Get(id)
+	if !ok {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	base, err := s.replayTarget(exchange, r.URL.Query().Get("target"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if base == "" {
+		base = "http://localhost"
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, curlCommand(base, *exchange.Request)+"\n")
+}
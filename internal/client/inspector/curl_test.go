@@ -0,0 +1,139 @@
+package inspector
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestShellSplit(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{`curl  http://a/b`, []string{"curl", "http://a/b"}},
+		{`curl -H 'X-A: 1 2' "x\"y" a\ b`, []string{"curl", "-H", "X-A: 1 2", `x"y`, "a b"}},
+		{"curl \\\n  -d \"a\\b\"", []string{"curl", "-d", `a\b`}},
+		{`curl ''`, []string{"curl", ""}},
+	}
+	for _, tt := range tests {
+		got, err := shellSplit(tt.in)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("shellSplit(%q) = %q, %v; want %q", tt.in, got, err, tt.want)
+		}
+	}
+	if _, err := shellSplit(`curl 'open`); err == nil {
+		t.Error("shellSplit() accepted an unterminated quote")
+	}
+}
+
+func TestParseCurl(t *testing.T) {
+	req, body, err := parseCurl(`curl 'https://example.com/api/orders?x=1' \
+  -H 'Content-Type: application/json' -sSL --compressed \
+  --data-raw '{"qty":1}' -u user:pass`)
+	if err != nil {
+		t.Fatalf("parseCurl() error = %v", err)
+	}
+	if req.Method != http.MethodPost || req.URL.RequestURI() != "/api/orders?x=1" {
+		t.Errorf("request = %s %s", req.Method, req.URL)
+	}
+	if req.Header.Get("Content-Type") != "application/json" || req.Header.Get("Authorization") != "Basic dXNlcjpwYXNz" {
+		t.Errorf("headers = %v", req.Header)
+	}
+	if string(body) != `{"qty":1}` {
+		t.Errorf("body = %q", body)
+	}
+
+	tests := []struct {
+		command, method, uri, body string
+	}{
+		{`curl example.com/health`, "GET", "/health", ""},
+		{`curl -XDELETE http://h/items/1`, "DELETE", "/items/1", ""},
+		{`curl -I http://h/`, "HEAD", "/", ""},
+		{`curl -G -d q=go --data-urlencode 'n=a b' http://h/s`, "GET", "/s?q=go&n=a+b", ""},
+		{`curl -d a=1 -d b=2 http://h/f`, "POST", "/f", "a=1&b=2"},
+	}
+	for _, tt := range tests {
+		req, body, err := parseCurl(tt.command)
+		if err != nil {
+			t.Errorf("parseCurl(%q) error = %v", tt.command, err)
+			continue
+		}
+		if req.Method != tt.method || req.URL.RequestURI() != tt.uri || string(body) != tt.body {
+			t.Errorf("parseCurl(%q) = %s %s %q", tt.command, req.Method, req.URL.RequestURI(), body)
+		}
+	}
+
+	for _, command := range []string{
+		`curl`,
+		`curl -d @payload.json http://h/`,
+		`curl -F file=@a.png http://h/`,
+		`curl http://a/ http://b/`,
+		`curl -H NoColon http://h/`,
+	} {
+		if _, _, err := parseCurl(command); err == nil {
+			t.Errorf("parseCurl(%q) succeeded, want an error", command)
+		}
+	}
+}
+
+func TestCurlCommand_RoundTrip(t *testing.T) {
+	requests := []HTTPRequest{
+		{Method: "GET", URL: "/health"},
+		{Method: "HEAD", URL: "/"},
+		{Method: "POST", URL: "/orders?x=1&y=2", Headers: map[string][]string{
+			"Content-Type":   {"application/json"},
+			"Content-Length": {"22"},
+			"X-Note":         {"it's \"quoted\" $HOME"},
+		}, Body: `{"name":"O'Brien"}` + "\n"},
+		{Method: "DELETE", URL: "/items/7", Headers: map[string][]string{"Accept": {"*/*", "text/plain"}}},
+		{Method: "GET", URL: "/search", Body: "q=1"},
+	}
+	for _, want := range requests {
+		command := curlCommand("http://localhost:3000", want)
+		req, body, err := parseCurl(command)
+		if err != nil {
+			t.Errorf("parseCurl(%s) error = %v", command, err)
+			continue
+		}
+		if req.Method != want.Method || req.URL.String() != "http://localhost:3000"+want.URL || string(body) != want.Body {
+			t.Errorf("%s read back as %s %s %q", command, req.Method, req.URL, body)
+		}
+		for name, values := range want.Headers {
+			if name == "Content-Length" {
+				if req.Header.Get(name) != "" {
+					t.Errorf("%s: Content-Length should be left to curl", command)
+				}
+				continue
+			}
+			if !reflect.DeepEqual(req.Header[name], values) {
+				t.Errorf("%s: header %s = %q, want %q", command, name, req.Header[name], values)
+			}
+		}
+	}
+}
+
+func TestServer_CurlEndpoint(t *testing.T) {
+	store := NewInMemoryStore(10)
+	store.Insert(HTTPExchange{ID: 1, LocalPort: "8080", Request: &HTTPRequest{Method: "PUT", URL: "/a b", Body: "x"}})
+	srv := NewServer(store)
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+	if w := get("/api/exchanges/1/curl"); w.Code != http.StatusOK || w.Body.String() != "curl -X PUT 'http://localhost:8080/a b' \\\n  --data-raw x\n" {
+		t.Errorf("curl = %d: %q", w.Code, w.Body)
+	}
+	if w := get("/api/exchanges/1/curl?target=https://staging.example.com"); !strings.Contains(w.Body.String(), "https://staging.example.com/a b") {
+		t.Errorf("curl with target = %q", w.Body)
+	}
+	if w := get("/api/exchanges/2/curl"); w.Code != http.StatusNotFound {
+		t.Errorf("unknown exchange = %d, want 404", w.Code)
+	}
+}
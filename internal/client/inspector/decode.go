@@ -0,0 +1,77 @@
+package inspector
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// contentCodings returns the codings of a Content-Encoding header in the
+// order they were applied, without identity.
+func contentCodings(header http.Header) []string {
+	var codings []string
+	for _, value := range header.Values("Content-Encoding") {
+		for _, coding := range strings.Split(value, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding != "" && coding != "identity" {
+				codings = append(codings, coding)
+			}
+		}
+	}
+	return codings
+}
+
+// decodeBody undoes the codings of a body, last applied first. At most
+// maxBodySize+1 bytes are decoded, so truncateBody still marks long
+// bodies and compression bombs stay harmless. Unknown codings are errors.
+func decodeBody(body []byte, codings []string) ([]byte, error) {
+	for i := len(codings) - 1; i >= 0; i-- {
+		var r io.Reader
+		switch codings[i] {
+		case "gzip", "x-gzip":
+			zr, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		case "deflate":
+			// Deflate should be zlib-wrapped, but some servers send raw
+			// deflate data
+			if zr, err := zlib.NewReader(bytes.NewReader(body)); err == nil {
+				r = zr
+			} else {
+				r = flate.NewReader(bytes.NewReader(body))
+			}
+		case "br":
+			r = brotli.NewReader(bytes.NewReader(body))
+		default:
+			return nil, fmt.Errorf("cannot decode %s", codings[i])
+		}
+		decoded, err := io.ReadAll(io.LimitReader(r, maxBodySize+1))
+		if err != nil {
+			return nil, err
+		}
+		body = decoded
+	}
+	return body, nil
+}
+
+// decodedBody returns body decoded as its Content-Encoding header says,
+// or body itself if it is not encoded or does not decode.
+func decodedBody(header http.Header, body []byte) []byte {
+	codings := contentCodings(header)
+	if len(codings) == 0 {
+		return body
+	}
+	if decoded, err := decodeBody(body, codings); err == nil {
+		return decoded
+	}
+	return body
+}
@@ -0,0 +1,117 @@
+package inspector
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+)
+
+func compress(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&buf)
+	case "deflate":
+		w = zlib.NewWriter(&buf)
+	case "raw-deflate":
+		w, _ = flate.NewWriter(&buf, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&buf)
+	}
+	w.Write(data)
+	w.Close()
+	return buf.Bytes()
+}
+
+func TestDecodeBody(t *testing.T) {
+	body := []byte(`{"items":[1,2,3]}`)
+	tests := []struct {
+		name    string
+		codings []string
+		data    []byte
+	}{
+		{"gzip", []string{"gzip"}, compress(t, "gzip", body)},
+		{"deflate", []string{"deflate"}, compress(t, "deflate", body)},
+		{"raw deflate", []string{"deflate"}, compress(t, "raw-deflate", body)},
+		{"br", []string{"br"}, compress(t, "br", body)},
+		{"chain with br", []string{"gzip", "br"}, compress(t, "br", compress(t, "gzip", body))},
+		{"chain", []string{"deflate", "gzip"}, compress(t, "gzip", compress(t, "deflate", body))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := decodeBody(tt.data, tt.codings)
+			if err != nil {
+				t.Fatalf("decodeBody() error = %v", err)
+			}
+			if !bytes.Equal(got, body) {
+				t.Errorf("decodeBody() = %q, want %q", got, body)
+			}
+		})
+	}
+
+	if _, err := decodeBody([]byte("not brotli"), []string{"br"}); err == nil {
+		t.Error("decodeBody() accepted a corrupt brotli body")
+	}
+	if _, err := decodeBody(body, []string{"zstd"}); err == nil {
+		t.Error("decodeBody() accepted an unknown coding")
+	}
+	if _, err := decodeBody([]byte("not gzip"), []string{"gzip"}); err == nil {
+		t.Error("decodeBody() accepted a corrupt gzip body")
+	}
+}
+
+func TestContentCodings(t *testing.T) {
+	h := http.Header{"Content-Encoding": {"deflate, identity", " GZIP"}}
+	got := contentCodings(h)
+	if len(got) != 2 || got[0] != "deflate" || got[1] != "gzip" {
+		t.Errorf("contentCodings() = %q", got)
+	}
+}
+
+func TestAddExchange_DecodesResponse(t *testing.T) {
+	s := NewServer(nil)
+	req, _ := http.NewRequest("GET", "http://example.com/", nil)
+	body := bytes.Repeat([]byte("hello "), 100)
+	gz := compress(t, "gzip", body)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Encoding": {"gzip"}}}
+	id := s.AddExchange(req, nil, resp, gz, 0)
+
+	brBody := compress(t, "br", body)
+	br := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Encoding": {"br"}}}
+	brID := s.AddExchange(req, nil, br, brBody, 0)
+
+	zstd := &http.Response{StatusCode: http.StatusOK, Header: http.Header{"Content-Encoding": {"zstd"}}}
+	zstdID := s.AddExchange(req, nil, zstd, []byte("\x28\xb5\x2f\xfd"), 0)
+	s.Flush()
+
+	ex, _ := s.store.Get(id)
+	r := ex.Response
+	if r.Body != string(body) || !r.Decoded || r.ContentEncoding != "gzip" {
+		t.Errorf("response = %+v, want decoded gzip body", r)
+	}
+	if r.Size != int64(len(gz)) || r.DecodedSize != int64(len(body)) {
+		t.Errorf("size = %d, decoded = %d; want %d, %d", r.Size, r.DecodedSize, len(gz), len(body))
+	}
+
+	ex, _ = s.store.Get(brID)
+	if r := ex.Response; r.Body != string(body) || !r.Decoded || r.ContentEncoding != "br" || r.Size != int64(len(brBody)) {
+		t.Errorf("brotli response = %+v, want decoded body", r)
+	}
+
+	// Undecoded bodies are binary and kept as transferred for download
+	ex, _ = s.store.Get(zstdID)
+	if r := ex.Response; r.Decoded || r.ContentEncoding != "zstd" || !r.Binary {
+		t.Errorf("zstd response = %+v, want binary", r)
+	}
+	if raw, ok := s.rawBody(ex, true); !ok || string(raw) != "\x28\xb5\x2f\xfd" {
+		t.Errorf("raw zstd body = %q, %v", raw, ok)
+	}
+}
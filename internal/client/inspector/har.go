@@ -0,0 +1,227 @@
+package inspector
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"gopublic/internal/version"
+)
+
+// HTTP Archive 1.2 (http://www.softwareishard.com/blog/har-12-spec/), the
+// fields the inspector reads and writes. Sizes it does not know are -1.
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime time.Time    `json:"startedDateTime"`
+	Time            float64      `json:"time"` // Milliseconds
+	Request         harRequest   `json:"request"`
+	Response        *harResponse `json:"response"`
+	Cache           struct{}     `json:"cache"`
+	Timings         harTimings   `json:"timings"`
+	Comment         string       `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int64          `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size        int64  `json:"size"`
+	Compression int64  `json:"compression,omitempty"`
+	MimeType    string `json:"mimeType"`
+	Text        string `json:"text,omitempty"`
+	Encoding    string `json:"encoding,omitempty"`
+}
+
+type harTimings struct {
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+}
+
+// harNameValues converts headers to sorted HAR name/value pairs.
+func harNameValues(headers map[string][]string) []harNameValue {
+	pairs := []harNameValue{}
+	for name, values := range headers {
+		for _, v := range values {
+			pairs = append(pairs, harNameValue{Name: name, Value: v})
+		}
+	}
+	sort.SliceStable(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+	return pairs
+}
+
+// mediaType returns the media type of a Content-Type header, or "".
+func mediaType(headers map[string][]string) string {
+	ct := http.Header(headers).Get("Content-Type")
+	if mt, _, err := mime.ParseMediaType(ct); err == nil {
+		return mt
+	}
+	return ct
+}
+
+// harEntryFor converts an exchange, whose URL is resolved against base.
+func harEntryFor(base string, ex HTTPExchange) harEntry {
+	entry := harEntry{
+		StartedDateTime: ex.Timestamp,
+		Time:            float64(ex.Duration),
+		Request: harRequest{
+			Method:      ex.Request.Method,
+			URL:         base + ex.Request.URL,
+			HTTPVersion: ex.Request.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harNameValues(ex.Request.Headers),
+			QueryString: []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    ex.Request.Size,
+		},
+		// The inspector does not time the phases of an exchange
+		Timings: harTimings{Send: 0, Wait: float64(ex.Duration), Receive: 0},
+	}
+	if u, err := url.Parse(entry.Request.URL); err == nil {
+		for name, values := range u.Query() {
+			for _, v := range values {
+				entry.Request.QueryString = append(entry.Request.QueryString, harNameValue{Name: name, Value: v})
+			}
+		}
+		sort.SliceStable(entry.Request.QueryString, func(i, j int) bool {
+			return entry.Request.QueryString[i].Name < entry.Request.QueryString[j].Name
+		})
+	}
+	if ex.Request.Body != "" {
+		entry.Request.PostData = &harPostData{MimeType: mediaType(ex.Request.Headers), Text: ex.Request.Body}
+	}
+
+	// HAR has no place for requests without response; DevTools writes
+	// cancelled requests with status 0 too
+	resp := ex.Response
+	if resp == nil {
+		resp = &HTTPResponse{}
+	}
+	entry.Response = &harResponse{
+		Status:      resp.Status,
+		StatusText:  http.StatusText(resp.Status),
+		HTTPVersion: resp.Proto,
+		Cookies:     []harNameValue{},
+		Headers:     harNameValues(resp.Headers),
+		Content: harContent{
+			Size:     resp.Size,
+			MimeType: mediaType(resp.Headers),
+			Text:     resp.Body,
+		},
+		RedirectURL: http.Header(resp.Headers).Get("Location"),
+		HeadersSize: -1,
+		BodySize:    resp.Size,
+	}
+	switch {
+	case resp.Decoded:
+		// HAR content is decoded; bodySize stays the transferred size
+		entry.Response.Content.Size = resp.DecodedSize
+		entry.Response.Content.Compression = resp.DecodedSize - resp.Size
+	case resp.ContentEncoding != "" && resp.Body != "":
+		entry.Response.Content.Text = base64.StdEncoding.EncodeToString([]byte(resp.Body))
+		entry.Response.Content.Encoding = "base64"
+	}
+
+	var notes []string
+	if ex.Tunnel != "" {
+		notes = append(notes, "tunnel "+ex.Tunnel)
+	}
+	if ex.Aborted {
+		notes = append(notes, fmt.Sprintf("client aborted after %d bytes", ex.BytesSent))
+	}
+	if ex.WebSocket {
+		notes = append(notes, fmt.Sprintf("WebSocket, %d bytes in, %d bytes out", ex.WSIn, ex.WSOut))
+	}
+	entry.Comment = strings.Join(notes, "; ")
+	return entry
+}
+
+// handleExportHAR answers with the stored exchanges as a HAR file, oldest
+// first. URLs point at the local port of each exchange, or at the base
+// URL in the base query parameter, e.g. the public URL of the tunnel.
+func (s *Server) handleExportHAR(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	exchanges := s.store.List()
+	sort.SliceStable(exchanges, func(i, j int) bool { return exchanges[i].Timestamp.Before(exchanges[j].Timestamp) })
+
+	har := harFile{Log: harLog{
+		Version: "1.2",
+		Creator: harCreator{Name: "gopublic inspector", Version: version.Version},
+		Entries: make([]harEntry, 0, len(exchanges)),
+	}}
+	for _, ex := range exchanges {
+		if ex.Request == nil {
+			continue
+		}
+		base, err := s.replayTarget(&ex, r.URL.Query().Get("base"))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if base == "" {
+			base = "http://localhost"
+		}
+		har.Log.Entries = append(har.Log.Entries, harEntryFor(base, ex))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", `attachment; filename="gopublic-`+time.Now().Format("20060102-150405")+`.har"`)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(har)
+}
@@ -0,0 +1,74 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestServer_ExportHAR(t *testing.T) {
+	store := NewInMemoryStore(10)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	store.Insert(HTTPExchange{ID: 2, Timestamp: start.Add(time.Second), Request: &HTTPRequest{Method: "GET", URL: "/pending", Proto: "HTTP/1.1"}})
+	store.Insert(HTTPExchange{
+		ID: 1, Timestamp: start, Duration: 12, Tunnel: "api", LocalPort: "8080",
+		Request: &HTTPRequest{
+			Method: "POST", URL: "/orders?page=2", Proto: "HTTP/1.1",
+			Headers: map[string][]string{"Content-Type": {"application/json; charset=utf-8"}},
+			Body:    `{"qty":1}`, Size: 9,
+		},
+		Response: &HTTPResponse{
+			Status: 201, Proto: "HTTP/1.1",
+			Headers: map[string][]string{"Content-Type": {"application/json"}},
+			Body:    `{"id":7}`, Size: 8,
+		},
+	})
+	srv := NewServer(store, WithLocalPort("3000"))
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export/har", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("export = %d: %s", w.Code, w.Body)
+	}
+	var har harFile
+	if err := json.Unmarshal(w.Body.Bytes(), &har); err != nil {
+		t.Fatalf("export is not JSON: %v", err)
+	}
+	if har.Log.Version != "1.2" || len(har.Log.Entries) != 2 {
+		t.Fatalf("log = %+v", har.Log)
+	}
+
+	entry := har.Log.Entries[0]
+	if entry.Request.URL != "http://localhost:8080/orders?page=2" || entry.Time != 12 || entry.Comment != "tunnel api" {
+		t.Errorf("first entry = %+v, want the oldest exchange at its own local port", entry)
+	}
+	if len(entry.Request.QueryString) != 1 || entry.Request.QueryString[0] != (harNameValue{"page", "2"}) {
+		t.Errorf("query string = %v", entry.Request.QueryString)
+	}
+	if entry.Request.PostData == nil || entry.Request.PostData.MimeType != "application/json" || entry.Request.PostData.Text != `{"qty":1}` {
+		t.Errorf("post data = %+v", entry.Request.PostData)
+	}
+	if entry.Response.Status != 201 || entry.Response.StatusText != "Created" || entry.Response.Content.Text != `{"id":7}` {
+		t.Errorf("response = %+v", entry.Response)
+	}
+	if pending := har.Log.Entries[1]; pending.Request.URL != "http://localhost:3000/pending" || pending.Response.Status != 0 {
+		t.Errorf("pending entry = %+v", pending)
+	}
+
+	// The export imports back, without the pending response
+	imported, err := parseHAR(w.Body.Bytes())
+	if err != nil || len(imported) != 2 || imported[0].Request.URL != "/orders?page=2" || imported[1].Response != nil {
+		t.Errorf("parseHAR(export) = %+v, %v", imported, err)
+	}
+
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/export/har?base=https://misty-river.example.com", nil))
+	json.Unmarshal(w.Body.Bytes(), &har)
+	if got := har.Log.Entries[0].Request.URL; got != "https://misty-river.example.com/orders?page=2" {
+		t.Errorf("URL with base = %q", got)
+	}
+}
@@ -0,0 +1,156 @@
+package inspector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// maxImportSize limits the curl command or HAR file /api/import accepts.
+const maxImportSize = 10 << 20
+
+// harHeaders converts HAR headers, leaving out the HTTP/2 pseudo-headers
+// (":authority") and Host, which replay sets itself.
+func harHeaders(headers []harNameValue) map[string][]string {
+	h := make(http.Header)
+	for _, hdr := range headers {
+		if strings.HasPrefix(hdr.Name, ":") || strings.EqualFold(hdr.Name, "Host") {
+			continue
+		}
+		h.Add(hdr.Name, hdr.Value)
+	}
+	return h
+}
+
+// requestURI returns the path and query of rawURL, which replay sends to
+// the local port.
+func requestURI(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid URL %q: %w", rawURL, err)
+	}
+	return u.RequestURI(), nil
+}
+
+// parseHAR converts the entries of a HAR file to exchanges, with their
+// responses if the file has them.
+func parseHAR(data []byte) ([]HTTPExchange, error) {
+	var har harFile
+	if err := json.Unmarshal(data, &har); err != nil {
+		return nil, fmt.Errorf("invalid HAR file: %w", err)
+	}
+	if len(har.Log.Entries) == 0 {
+		return nil, errors.New("the HAR file has no entries")
+	}
+
+	exchanges := make([]HTTPExchange, 0, len(har.Log.Entries))
+	for _, entry := range har.Log.Entries {
+		uri, err := requestURI(entry.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		ex := HTTPExchange{
+			Timestamp: entry.StartedDateTime,
+			Duration:  int64(entry.Time),
+			Imported:  true,
+			Request: &HTTPRequest{
+				Method:  entry.Request.Method,
+				URL:     uri,
+				Proto:   entry.Request.HTTPVersion,
+				Headers: harHeaders(entry.Request.Headers),
+			},
+		}
+		if entry.Request.PostData != nil {
+			ex.Request.Body = truncateBody([]byte(entry.Request.PostData.Text))
+			ex.Request.Size = int64(len(entry.Request.PostData.Text))
+		}
+		// Browsers record blocked or cancelled requests with status 0
+		if resp := entry.Response; resp != nil && resp.Status > 0 {
+			body := resp.Content.Text
+			if resp.Content.Encoding == "base64" {
+				body = "(base64) " + body
+			}
+			ex.Response = &HTTPResponse{
+				Status:  resp.Status,
+				Proto:   resp.HTTPVersion,
+				Headers: harHeaders(resp.Headers),
+				Body:    truncateBody([]byte(body)),
+				Size:    int64(len(body)),
+			}
+		}
+		if ex.Timestamp.IsZero() {
+			ex.Timestamp = time.Now()
+		}
+		exchanges = append(exchanges, ex)
+	}
+	return exchanges, nil
+}
+
+// curlExchange converts a curl command to an exchange without response.
+func curlExchange(command string) (HTTPExchange, error) {
+	req, body, err := parseCurl(command)
+	if err != nil {
+		return HTTPExchange{}, err
+	}
+	return HTTPExchange{
+		Timestamp: time.Now(),
+		Imported:  true,
+		Request: &HTTPRequest{
+			Method:  req.Method,
+			URL:     req.URL.RequestURI(),
+			Proto:   "HTTP/1.1",
+			Headers: req.Header,
+			Body:    truncateBody(body),
+			Size:    int64(len(body)),
+		},
+	}, nil
+}
+
+// handleImport adds the requests of a curl command or a HAR file (a JSON
+// body) as exchanges that can be replayed against the local port.
+func (s *Server) handleImport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxImportSize+1))
+	if err != nil {
+		http.Error(w, "Failed to read body", http.StatusBadRequest)
+		return
+	}
+	if len(data) > maxImportSize {
+		http.Error(w, "Import too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	var exchanges []HTTPExchange
+	if trimmed := bytes.TrimSpace(data); bytes.HasPrefix(trimmed, []byte("{")) {
+		exchanges, err = parseHAR(trimmed)
+	} else {
+		var ex HTTPExchange
+		ex, err = curlExchange(string(trimmed))
+		exchanges = []HTTPExchange{ex}
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ids := make([]int64, 0, len(exchanges))
+	for _, ex := range exchanges {
+		id := s.enqueueExchange(func() HTTPExchange { return ex }, nil)
+		if id < 0 {
+			http.Error(w, "Inspector is busy, try again", http.StatusServiceUnavailable)
+			return
+		}
+		ids = append(ids, id)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"imported": ids})
+}
@@ -0,0 +1,89 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testHAR = `{"log":{"version":"1.2","entries":[
+ {"startedDateTime":"2026-01-02T03:04:05Z","time":42.5,
+  "request":{"method":"PUT","url":"https://app.example.com/api/items/7?v=2","httpVersion":"HTTP/2",
+   "headers":[{"name":":authority","value":"app.example.com"},{"name":"Host","value":"app.example.com"},{"name":"Content-Type","value":"application/json"}],
+   "postData":{"mimeType":"application/json","text":"{\"name\":\"x\"}"}},
+  "response":{"status":200,"httpVersion":"HTTP/2","headers":[{"name":"Content-Type","value":"application/json"}],"content":{"text":"{}"}}},
+ {"startedDateTime":"2026-01-02T03:04:06Z","time":0,
+  "request":{"method":"GET","url":"https://app.example.com/","httpVersion":"HTTP/1.1","headers":[]},
+  "response":{"status":0,"httpVersion":"","headers":[],"content":{}}}
+]}}`
+
+func TestParseHAR(t *testing.T) {
+	exchanges, err := parseHAR([]byte(testHAR))
+	if err != nil {
+		t.Fatalf("parseHAR() error = %v", err)
+	}
+	if len(exchanges) != 2 {
+		t.Fatalf("got %d exchanges, want 2", len(exchanges))
+	}
+
+	ex := exchanges[0]
+	if ex.Request.Method != "PUT" || ex.Request.URL != "/api/items/7?v=2" || ex.Duration != 42 || !ex.Imported {
+		t.Errorf("exchange = %+v, request = %+v", ex, ex.Request)
+	}
+	if len(ex.Request.Headers) != 1 || ex.Request.Headers["Content-Type"][0] != "application/json" {
+		t.Errorf("headers = %v, want pseudo-headers and Host left out", ex.Request.Headers)
+	}
+	if ex.Request.Body != `{"name":"x"}` || ex.Response == nil || ex.Response.Status != 200 {
+		t.Errorf("request body = %q, response = %+v", ex.Request.Body, ex.Response)
+	}
+	if exchanges[1].Response != nil {
+		t.Errorf("a status 0 entry got response %+v", exchanges[1].Response)
+	}
+
+	if _, err := parseHAR([]byte(`{"log":{"entries":[]}}`)); err == nil {
+		t.Error("parseHAR() accepted a file without entries")
+	}
+}
+
+func TestServer_ImportEndpoint(t *testing.T) {
+	srv := NewServer(NewInMemoryStore(10), WithLocalPort("3000"))
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	post := func(body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/import", strings.NewReader(body)))
+		return w
+	}
+
+	w := post(`curl -X POST http://localhost:8080/hooks -d ping=1`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("curl import = %d: %s", w.Code, w.Body)
+	}
+	var result struct {
+		Imported []int64 `json:"imported"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if len(result.Imported) != 1 {
+		t.Fatalf("imported = %v", result.Imported)
+	}
+	srv.Flush()
+	ex, ok := srv.store.Get(result.Imported[0])
+	if !ok || ex.Request.URL != "/hooks" || ex.Request.Body != "ping=1" || !ex.Imported {
+		t.Errorf("stored exchange = %+v", ex.Request)
+	}
+
+	if w := post(testHAR); w.Code != http.StatusOK || !strings.Contains(w.Body.String(), `"imported":[`) {
+		t.Errorf("HAR import = %d: %s", w.Code, w.Body)
+	}
+	srv.Flush()
+	if n := len(srv.store.List()); n != 3 {
+		t.Errorf("store holds %d exchanges, want 3", n)
+	}
+
+	if w := post(`wget http://h/`); w.Code != http.StatusBadRequest {
+		t.Errorf("invalid import = %d, want 400", w.Code)
+	}
+}
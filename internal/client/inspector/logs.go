@@ -0,0 +1,43 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"gopublic/internal/client/logger"
+)
+
+// logLevels orders the levels for the level filter of /api/logs.
+var logLevels = map[string]int{"info": 0, "warn": 1, "error": 2}
+
+// filterLogs returns the entries at level or above, all of them if level
+// is empty.
+func filterLogs(entries []logger.Entry, level string) []logger.Entry {
+	min, ok := logLevels[level]
+	if !ok {
+		return entries
+	}
+	filtered := make([]logger.Entry, 0, len(entries))
+	for _, e := range entries {
+		if logLevels[e.Level] >= min {
+			filtered = append(filtered, e)
+		}
+	}
+	return filtered
+}
+
+// handleLogs serves the client's latest log messages, oldest first:
+// GET /api/logs?level=warn
+func handleLogs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	level := r.URL.Query().Get("level")
+	if _, ok := logLevels[level]; level != "" && !ok {
+		http.Error(w, "Invalid level: use info, warn or error", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(filterLogs(logger.Recent(), level))
+}
@@ -0,0 +1,48 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gopublic/internal/client/logger"
+)
+
+func TestFilterLogs(t *testing.T) {
+	entries := []logger.Entry{
+		{Level: "info", Msg: "Connecting"},
+		{Level: "warn", Msg: "Reconnecting"},
+		{Level: "error", Msg: "Local service not responding"},
+	}
+	if got := filterLogs(entries, ""); len(got) != 3 {
+		t.Errorf("filterLogs(\"\") = %v, want all", got)
+	}
+	got := filterLogs(entries, "warn")
+	if len(got) != 2 || got[0].Msg != "Reconnecting" || got[1].Msg != "Local service not responding" {
+		t.Errorf("filterLogs(warn) = %v", got)
+	}
+}
+
+func TestHandleLogs(t *testing.T) {
+	logger.Error("Local service on port %s is not responding", "3000")
+
+	rec := httptest.NewRecorder()
+	handleLogs(rec, httptest.NewRequest(http.MethodGet, "/api/logs?level=error", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var entries []logger.Entry
+	if err := json.Unmarshal(rec.Body.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) == 0 || entries[len(entries)-1].Msg != "Local service on port 3000 is not responding" {
+		t.Errorf("entries = %v", entries)
+	}
+
+	rec = httptest.NewRecorder()
+	handleLogs(rec, httptest.NewRequest(http.MethodGet, "/api/logs?level=debug", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("status for an unknown level = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,27 @@
+package inspector
+
+import (
+	"context"
+	"net/http"
+)
+
+// Origin is the tunnel that forwarded a captured request.
+type Origin struct {
+	Tunnel    string // Tunnel name or subdomain, if known
+	LocalPort string // Local port the request was forwarded to
+}
+
+type originKey struct{}
+
+// WithOrigin returns req tagged with the tunnel forwarding it. Exchanges
+// captured for the returned request record the origin, and replay sends
+// them to its local port.
+func WithOrigin(req *http.Request, origin Origin) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), originKey{}, origin))
+}
+
+// originOf returns the origin WithOrigin tagged req with.
+func originOf(req *http.Request) Origin {
+	origin, _ := req.Context().Value(originKey{}).(Origin)
+	return origin
+}
@@ -0,0 +1,143 @@
+package inspector
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Themes of the inspector UI.
+const (
+	ThemeSystem = "system" // Follow the browser's prefers-color-scheme
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+)
+
+// Preferences are the settings of the inspector UI, kept across runs.
+type Preferences struct {
+	Theme        string `json:"theme"`
+	RelativeTime bool   `json:"relative_time"` // "2m ago" instead of the time of day
+	Shortcuts    bool   `json:"shortcuts"`     // Keyboard navigation of the request list
+}
+
+// DefaultPreferences returns the settings before the user changed any.
+func DefaultPreferences() Preferences {
+	return Preferences{Theme: ThemeSystem, RelativeTime: true, Shortcuts: true}
+}
+
+// validate rejects settings the UI does not know.
+func (p Preferences) validate() error {
+	switch p.Theme {
+	case ThemeSystem, ThemeLight, ThemeDark:
+		return nil
+	}
+	return errors.New("theme must be system, light or dark")
+}
+
+// PreferenceStore serves the UI preferences on /api/preferences and keeps
+// them in a JSON file, or in memory without a path.
+type PreferenceStore struct {
+	mu     sync.Mutex
+	path   string
+	prefs  Preferences
+	loaded bool
+}
+
+// Get returns the preferences, read from the file on first use. A missing
+// or unreadable file yields the defaults.
+func (s *PreferenceStore) Get() Preferences {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+func (s *PreferenceStore) load() Preferences {
+	if s.loaded {
+		return s.prefs
+	}
+	s.loaded = true
+	s.prefs = DefaultPreferences()
+	if s.path == "" {
+		return s.prefs
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return s.prefs
+	}
+	prefs := DefaultPreferences()
+	if json.Unmarshal(data, &prefs) == nil && prefs.validate() == nil {
+		s.prefs = prefs
+	}
+	return s.prefs
+}
+
+// Set validates and stores prefs.
+func (s *PreferenceStore) Set(prefs Preferences) error {
+	if err := prefs.validate(); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.path != "" {
+		data, err := json.MarshalIndent(prefs, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(s.path, data); err != nil {
+			return err
+		}
+	}
+	s.prefs, s.loaded = prefs, true
+	return nil
+}
+
+// writeFileAtomic writes data to a private file at path, creating its
+// directory. It writes a temporary file and renames it, so a crash never
+// leaves a truncated file.
+func writeFileAtomic(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ServeHTTP returns the preferences on GET and replaces them on PUT. The
+// JSON content type keeps other sites from changing them with a simple
+// cross-origin form post.
+func (s *PreferenceStore) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+			http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+			return
+		}
+		prefs := s.Get()
+		if err := json.NewDecoder(io.LimitReader(r.Body, 4096)).Decode(&prefs); err != nil {
+			http.Error(w, "Invalid JSON", http.StatusBadRequest)
+			return
+		}
+		if err := prefs.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.Set(prefs); err != nil {
+			http.Error(w, "Failed to save preferences", http.StatusInternalServerError)
+			return
+		}
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(s.Get())
+}
@@ -0,0 +1,46 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreferenceStore_ServeHTTP(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inspector-prefs.json")
+	store := &PreferenceStore{path: path}
+
+	w := httptest.NewRecorder()
+	store.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/preferences", nil))
+	var got Preferences
+	json.NewDecoder(w.Body).Decode(&got)
+	if got != DefaultPreferences() {
+		t.Errorf("GET = %+v, want the defaults", got)
+	}
+
+	put := func(body, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/api/preferences", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		store.ServeHTTP(w, req)
+		return w
+	}
+	if w := put(`{"theme":"dark"}`, "text/plain"); w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("PUT as text/plain = %d, want 415", w.Code)
+	}
+	if w := put(`{"theme":"purple"}`, "application/json"); w.Code != http.StatusBadRequest {
+		t.Errorf("PUT unknown theme = %d, want 400", w.Code)
+	}
+	if w := put(`{"theme":"dark","relative_time":false}`, "application/json; charset=utf-8"); w.Code != http.StatusOK {
+		t.Fatalf("PUT = %d: %s", w.Code, w.Body)
+	}
+
+	// A new store reads them back, keeping fields the PUT left out
+	reloaded := (&PreferenceStore{path: path}).Get()
+	if want := (Preferences{Theme: ThemeDark, RelativeTime: false, Shortcuts: true}); reloaded != want {
+		t.Errorf("reloaded = %+v, want %+v", reloaded, want)
+	}
+}
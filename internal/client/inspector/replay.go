@@ -0,0 +1,209 @@
+package inspector
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayOverrides changes a captured request before it is replayed. Nil
+// fields keep the captured value; Headers replaces all captured headers.
+// Target sends the request to another base URL, e.g.
+// "http://localhost:9000" or "https://staging.example.com", instead of
+// the local port of its tunnel.
+type ReplayOverrides struct {
+	Target  string              `json:"target,omitempty"`
+	Method  *string             `json:"method,omitempty"`
+	Path    *string             `json:"path,omitempty"` // Path and query, e.g. "/orders?page=2"
+	Headers map[string][]string `json:"headers,omitempty"`
+	Body    *string             `json:"body,omitempty"`
+}
+
+// apply returns a copy of req with the overrides.
+func (o *ReplayOverrides) apply(req HTTPRequest) (HTTPRequest, error) {
+	if o.Method != nil {
+		req.Method = strings.ToUpper(strings.TrimSpace(*o.Method))
+	}
+	if o.Path != nil {
+		if !strings.HasPrefix(*o.Path, "/") {
+			return req, errors.New("path must start with /")
+		}
+		req.URL = *o.Path
+	}
+	if o.Headers != nil {
+		req.Headers = o.Headers
+	}
+	if o.Body != nil {
+		req.Body = *o.Body
+	}
+	return req, nil
+}
+
+// newHTTPRequest rebuilds req for sending to base, a URL without path.
+func (req HTTPRequest) newHTTPRequest(ctx context.Context, base string) (*http.Request, error) {
+	r, err := http.NewRequestWithContext(ctx, req.Method, base+req.URL, bytes.NewReader([]byte(req.Body)))
+	if err != nil {
+		return nil, err
+	}
+	for k, vv := range req.Headers {
+		for _, v := range vv {
+			r.Header.Add(k, v)
+		}
+	}
+	return r, nil
+}
+
+// readJSONBody decodes the optional JSON body of r into v. On failure it
+// writes the error to w and returns false.
+func readJSONBody(w http.ResponseWriter, r *http.Request, v any) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	// A JSON content type cannot be sent cross-origin without a
+	// preflight, so other sites cannot replay to targets of their own
+	if ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); ct != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxImportSize)).Decode(v); err != nil && err != io.EOF {
+		http.Error(w, "Invalid request: "+err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+// prepareReplay returns the stored request idStr with overrides applied
+// and the base URL to send it to. On failure it writes the error to w and
+// returns false.
+func (s *Server) prepareReplay(w http.ResponseWriter, idStr string, overrides *ReplayOverrides) (string, HTTPRequest, bool) {
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid ID", http.StatusBadRequest)
+		return "", HTTPRequest{}, false
+	}
+
+	exchange, ok := s.store.Get(id)
+	if !ok {
+		http.Error(w, "Exchange not found", http.StatusNotFound)
+		return "", HTTPRequest{}, false
+	}
+
+	stored := *exchange.Request
+	if raw, ok := s.bodies.get(bodyKey{id, false}); ok {
+		stored.Body = string(raw) // Binary or cut in the exchange
+	}
+	request, err := overrides.apply(stored)
+	if err != nil {
+		http.Error(w, "Invalid overrides: "+err.Error(), http.StatusBadRequest)
+		return "", HTTPRequest{}, false
+	}
+	base, err := s.replayTarget(exchange, overrides.Target)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return "", HTTPRequest{}, false
+	}
+	if base == "" {
+		http.Error(w, "Replay not configured (no local port)", http.StatusInternalServerError)
+		return "", HTTPRequest{}, false
+	}
+	return base, request, true
+}
+
+// ReplayRequest is the optional JSON body of a replay: overrides of the
+// stored request and assertions on the response.
+type ReplayRequest struct {
+	ReplayOverrides
+	Assertions []Assertion `json:"assertions,omitempty"`
+}
+
+// handleReplay sends a stored request to the local port again. An optional
+// JSON ReplayRequest edits the request first, the stored exchange is left
+// unchanged, and checks the response.
+func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request, idStr string) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var replay ReplayRequest
+	if !readJSONBody(w, r, &replay) {
+		return
+	}
+	for _, a := range replay.Assertions {
+		if err := a.validate(); err != nil {
+			http.Error(w, "Invalid assertion: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	base, request, ok := s.prepareReplay(w, idStr, &replay.ReplayOverrides)
+	if !ok {
+		return
+	}
+
+	req, err := request.newHTTPRequest(r.Context(), base)
+	if err != nil {
+		http.Error(w, "Failed to create request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Execute request
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		http.Error(w, "Replay failed: "+err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, "Failed to read response: "+err.Error(), http.StatusInternalServerError)
+		return
+	}
+	respBody = decodedBody(resp.Header, respBody)
+
+	// Return response
+	result := map[string]interface{}{
+		"target":  base,
+		"status":  resp.StatusCode,
+		"headers": resp.Header,
+		"body":    string(respBody),
+	}
+	if len(replay.Assertions) > 0 {
+		result["assertions"], result["passed"] = checkAssertions(replay.Assertions, resp, respBody)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// replayTarget returns the base URL a replay of exchange goes to: target
+// if given, else the local port that served the exchange, else the
+// server's local port. Returns "" if none is known.
+func (s *Server) replayTarget(exchange *HTTPExchange, target string) (string, error) {
+	if target != "" {
+		u, err := url.Parse(target)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.RawQuery != "" {
+			return "", fmt.Errorf("invalid target %q: want an http or https base URL", target)
+		}
+		return strings.TrimSuffix(target, "/"), nil
+	}
+	port := exchange.LocalPort
+	if port == "" {
+		s.mu.RLock()
+		port = s.localPort
+		s.mu.RUnlock()
+	}
+	if port == "" {
+		return "", nil
+	}
+	return "http://localhost:" + port, nil
+}
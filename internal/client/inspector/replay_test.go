@@ -0,0 +1,112 @@
+package inspector
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_ReplayOverrides(t *testing.T) {
+	type seen struct {
+		method, uri, auth, body string
+	}
+	got := make(chan seen, 1)
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- seen{r.Method, r.RequestURI, r.Header.Get("Authorization"), string(body)}
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	store := NewInMemoryStore(10)
+	store.Insert(HTTPExchange{ID: 1, LocalPort: port, Request: &HTTPRequest{
+		Method:  "POST",
+		URL:     "/orders",
+		Headers: map[string][]string{"Authorization": {"Bearer old"}},
+		Body:    `{"qty":1}`,
+	}})
+	// The exchange's own local port wins over the server's
+	srv := NewServer(store, WithLocalPort("1"))
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	replay := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/api/replay/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	if w := replay(""); w.Code != http.StatusOK {
+		t.Fatalf("replay = %d: %s", w.Code, w.Body)
+	}
+	if s := <-got; s != (seen{"POST", "/orders", "Bearer old", `{"qty":1}`}) {
+		t.Errorf("plain replay sent %+v", s)
+	}
+
+	w := replay(`{"method":"put","path":"/orders/7?dry=1","headers":{"Authorization":["Bearer new"]},"body":"{\"qty\":2}"}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("edited replay = %d: %s", w.Code, w.Body)
+	}
+	if s := <-got; s != (seen{"PUT", "/orders/7?dry=1", "Bearer new", `{"qty":2}`}) {
+		t.Errorf("edited replay sent %+v", s)
+	}
+	var result struct {
+		Status int `json:"status"`
+	}
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Status != http.StatusAccepted {
+		t.Errorf("result status = %d, want 202", result.Status)
+	}
+
+	// The stored exchange keeps the captured request
+	if ex, _ := store.Get(1); ex.Request.Method != "POST" || ex.Request.Body != `{"qty":1}` {
+		t.Errorf("stored request changed to %+v", ex.Request)
+	}
+
+	for _, body := range []string{`{"path":"orders"}`, `{"method":"GE T"}`, `not json`, `{"target":"ftp://h"}`, `{"target":"localhost:3000"}`} {
+		if w := replay(body); w.Code != http.StatusBadRequest {
+			t.Errorf("replay with %s = %d, want 400", body, w.Code)
+		}
+	}
+}
+
+func TestServer_ReplayTarget(t *testing.T) {
+	hits := make(chan string, 1)
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits <- r.RequestURI
+	}))
+	defer target.Close()
+
+	store := NewInMemoryStore(10)
+	store.Insert(HTTPExchange{ID: 1, Tunnel: "api", LocalPort: "1", Request: &HTTPRequest{Method: "GET", URL: "/health"}})
+	srv := NewServer(store)
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/replay/1", strings.NewReader(`{"target":"`+target.URL+`/v2/"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("replay = %d: %s", w.Code, w.Body)
+	}
+	if uri := <-hits; uri != "/v2/health" {
+		t.Errorf("target got %q, want /v2/health", uri)
+	}
+
+	// Overrides must be JSON, so that other sites cannot send them
+	req = httptest.NewRequest(http.MethodPost, "/api/replay/1", strings.NewReader(`{"target":"`+target.URL+`"}`))
+	req.Header.Set("Content-Type", "text/plain")
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, req)
+	if w.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("replay as text/plain = %d, want 415", w.Code)
+	}
+}
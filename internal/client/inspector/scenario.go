@@ -0,0 +1,468 @@
+package inspector
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Scenario limits.
+const (
+	maxScenarioSteps    = 50
+	maxScenarioRespSize = 10 << 20 // Response bytes read per step
+)
+
+// scenarioName is the form of scenario names, which appear in URLs.
+var scenarioName = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// Scenario variables are used as {{name}} in a step's request.
+var (
+	scenarioVarName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	scenarioVar     = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// Scenario is a named sequence of requests copied from captured
+// exchanges. Steps pass values on through variables: a step extracts them
+// from its response and later steps use them as {{name}} in the URL,
+// header values and body.
+type Scenario struct {
+	Name  string         `json:"name"`
+	Steps []ScenarioStep `json:"steps"`
+}
+
+// ScenarioStep is a request of a scenario. Extract maps variable names to
+// "$.json.path" in the response body, "header:Name" or "status".
+// Overrides edit a request copied from an exchange when the scenario is
+// saved, e.g. to put a {{token}} into its Authorization header.
+type ScenarioStep struct {
+	Name       string            `json:"name,omitempty"`
+	ExchangeID int64             `json:"exchange_id,omitempty"` // Exchange the request was copied from
+	Overrides  *ReplayOverrides  `json:"overrides,omitempty"`
+	Request    *HTTPRequest      `json:"request,omitempty"`
+	LocalPort  string            `json:"local_port,omitempty"`
+	Extract    map[string]string `json:"extract,omitempty"`
+	Assertions []Assertion       `json:"assertions,omitempty"`
+}
+
+// validate checks a scenario whose steps all have a request.
+func (sc *Scenario) validate() error {
+	if !scenarioName.MatchString(sc.Name) {
+		return errors.New("name must be 1-64 letters, digits, dots, dashes or underscores")
+	}
+	if len(sc.Steps) == 0 || len(sc.Steps) > maxScenarioSteps {
+		return fmt.Errorf("a scenario needs 1 to %d steps", maxScenarioSteps)
+	}
+	for i, step := range sc.Steps {
+		if step.Request == nil || !strings.HasPrefix(step.Request.URL, "/") {
+			return fmt.Errorf("step %d: no request with a path", i+1)
+		}
+		for name, from := range step.Extract {
+			if !scenarioVarName.MatchString(name) {
+				return fmt.Errorf("step %d: invalid variable name %q", i+1, name)
+			}
+			if err := validateExtract(from); err != nil {
+				return fmt.Errorf("step %d: %s: %w", i+1, name, err)
+			}
+		}
+		for _, a := range step.Assertions {
+			if err := a.validate(); err != nil {
+				return fmt.Errorf("step %d: %w", i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateExtract checks where a variable is extracted from.
+func validateExtract(from string) error {
+	switch {
+	case from == "status":
+		return nil
+	case strings.HasPrefix(from, "header:"):
+		if strings.TrimPrefix(from, "header:") == "" {
+			return errors.New("header: needs a header name")
+		}
+		return nil
+	case strings.HasPrefix(from, "$"):
+		_, err := parseJSONPath(from)
+		return err
+	}
+	return fmt.Errorf("extract from %q: want $.json.path, header:Name or status", from)
+}
+
+// extract returns the value of a variable from a step's response. JSON
+// strings are used as they are, other JSON values in their JSON form.
+func extract(from string, resp *http.Response, body []byte) (string, error) {
+	switch {
+	case from == "status":
+		return strconv.Itoa(resp.StatusCode), nil
+	case strings.HasPrefix(from, "header:"):
+		name := strings.TrimPrefix(from, "header:")
+		if values := resp.Header.Values(name); len(values) > 0 {
+			return values[0], nil
+		}
+		return "", fmt.Errorf("no %s header in the response", name)
+	}
+	var doc any
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return "", errors.New("response is not JSON")
+	}
+	path, _ := parseJSONPath(from)
+	value, ok := lookupJSONPath(doc, path)
+	if !ok {
+		return "", fmt.Errorf("%s not found in the response", from)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	data, _ := json.Marshal(value)
+	return string(data), nil
+}
+
+// expand replaces the {{name}} placeholders of req with vars.
+func expand(req HTTPRequest, vars map[string]string) (HTTPRequest, error) {
+	var missing []string
+	replace := func(s string) string {
+		return scenarioVar.ReplaceAllStringFunc(s, func(m string) string {
+			name := scenarioVar.FindStringSubmatch(m)[1]
+			value, ok := vars[name]
+			if !ok {
+				missing = append(missing, name)
+			}
+			return value
+		})
+	}
+	req.URL = replace(req.URL)
+	req.Body = replace(req.Body)
+	headers := make(map[string][]string, len(req.Headers))
+	for name, values := range req.Headers {
+		for _, v := range values {
+			headers[name] = append(headers[name], replace(v))
+		}
+	}
+	req.Headers = headers
+	if len(missing) > 0 {
+		return req, fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+	return req, nil
+}
+
+// ScenarioStore keeps the scenarios in a JSON file, or in memory without
+// a path.
+type ScenarioStore struct {
+	mu        sync.Mutex
+	path      string
+	scenarios map[string]Scenario
+}
+
+// load reads the file on first use; a missing or unreadable file yields
+// no scenarios.
+func (s *ScenarioStore) load() {
+	if s.scenarios != nil {
+		return
+	}
+	s.scenarios = make(map[string]Scenario)
+	if s.path == "" {
+		return
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	var list []Scenario
+	if json.Unmarshal(data, &list) != nil {
+		return
+	}
+	for _, sc := range list {
+		s.scenarios[sc.Name] = sc
+	}
+}
+
+// save writes the scenarios to the file, ordered by name.
+func (s *ScenarioStore) save() error {
+	if s.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(s.list(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data)
+}
+
+func (s *ScenarioStore) list() []Scenario {
+	list := make([]Scenario, 0, len(s.scenarios))
+	for _, sc := range s.scenarios {
+		list = append(list, sc)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+// List returns the scenarios ordered by name.
+func (s *ScenarioStore) List() []Scenario {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	return s.list()
+}
+
+// Get returns the scenario called name.
+func (s *ScenarioStore) Get(name string) (Scenario, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	sc, ok := s.scenarios[name]
+	return sc, ok
+}
+
+// Put adds sc or replaces the scenario of the same name.
+func (s *ScenarioStore) Put(sc Scenario) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	old, existed := s.scenarios[sc.Name]
+	s.scenarios[sc.Name] = sc
+	if err := s.save(); err != nil {
+		if existed {
+			s.scenarios[sc.Name] = old
+		} else {
+			delete(s.scenarios, sc.Name)
+		}
+		return err
+	}
+	return nil
+}
+
+// Delete removes the scenario called name and reports whether it existed.
+func (s *ScenarioStore) Delete(name string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.load()
+	sc, ok := s.scenarios[name]
+	if !ok {
+		return false, nil
+	}
+	delete(s.scenarios, name)
+	if err := s.save(); err != nil {
+		s.scenarios[name] = sc
+		return true, err
+	}
+	return true, nil
+}
+
+// ScenarioRun configures a run: Target replaces the local port of every
+// step, as for a replay, and Vars sets variables before the first step.
+type ScenarioRun struct {
+	Target string            `json:"target,omitempty"`
+	Vars   map[string]string `json:"vars,omitempty"`
+}
+
+// StepResult is the outcome of a scenario step.
+type StepResult struct {
+	Name       string            `json:"name,omitempty"`
+	Method     string            `json:"method"`
+	URL        string            `json:"url"`
+	Status     int               `json:"status,omitempty"`
+	DurationMS float64           `json:"duration_ms"`
+	Extracted  map[string]string `json:"extracted,omitempty"`
+	Assertions []AssertionResult `json:"assertions,omitempty"`
+	Passed     bool              `json:"passed"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// ScenarioResult is the outcome of a run. A run stops at the first step
+// that fails: no response, a failed assertion or a missing variable.
+type ScenarioResult struct {
+	Scenario string            `json:"scenario"`
+	Passed   bool              `json:"passed"`
+	Steps    []StepResult      `json:"steps"`
+	Vars     map[string]string `json:"vars"`
+}
+
+// runScenario runs the steps of sc in order.
+func (s *Server) runScenario(ctx context.Context, client *http.Client, sc Scenario, run ScenarioRun) ScenarioResult {
+	result := ScenarioResult{Scenario: sc.Name, Passed: true, Vars: make(map[string]string)}
+	for k, v := range run.Vars {
+		result.Vars[k] = v
+	}
+	for _, step := range sc.Steps {
+		stepResult := s.runStep(ctx, client, step, run.Target, result.Vars)
+		result.Steps = append(result.Steps, stepResult)
+		if !stepResult.Passed {
+			result.Passed = false
+			break
+		}
+	}
+	return result
+}
+
+// runStep sends a step's request and extracts its variables into vars.
+func (s *Server) runStep(ctx context.Context, client *http.Client, step ScenarioStep, target string, vars map[string]string) StepResult {
+	result := StepResult{Name: step.Name, Method: step.Request.Method, URL: step.Request.URL}
+	request, err := expand(*step.Request, vars)
+	result.URL = request.URL
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	base, err := s.replayTarget(&HTTPExchange{LocalPort: step.LocalPort}, target)
+	if err != nil || base == "" {
+		result.Error = "no target: give one or set the local port"
+		if err != nil {
+			result.Error = err.Error()
+		}
+		return result
+	}
+	req, err := request.newHTTPRequest(ctx, base)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxScenarioRespSize))
+	result.DurationMS = milliseconds(time.Since(start))
+	result.Status = resp.StatusCode
+	if err != nil {
+		result.Error = "failed to read response: " + err.Error()
+		return result
+	}
+	body = decodedBody(resp.Header, body)
+
+	result.Passed = true
+	if len(step.Assertions) > 0 {
+		result.Assertions, result.Passed = checkAssertions(step.Assertions, resp, body)
+	}
+	names := make([]string, 0, len(step.Extract))
+	for name := range step.Extract {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value, err := extract(step.Extract[name], resp, body)
+		if err != nil {
+			result.Passed = false
+			result.Error = fmt.Sprintf("%s: %v", name, err)
+			break
+		}
+		if result.Extracted == nil {
+			result.Extracted = make(map[string]string)
+		}
+		result.Extracted[name] = value
+		vars[name] = value
+	}
+	return result
+}
+
+// handleScenarios lists the scenarios on GET and saves one on POST. Steps
+// with an exchange_id and no request copy the stored exchange's request
+// and local port.
+func (s *Server) handleScenarios(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{"scenarios": s.scenarios.List()})
+	case http.MethodPost:
+		var sc Scenario
+		if r.ContentLength == 0 {
+			http.Error(w, "Missing scenario", http.StatusBadRequest)
+			return
+		}
+		if !readJSONBody(w, r, &sc) {
+			return
+		}
+		for i := range sc.Steps {
+			step := &sc.Steps[i]
+			if step.Request != nil || step.ExchangeID == 0 {
+				continue
+			}
+			exchange, ok := s.store.Get(step.ExchangeID)
+			if !ok {
+				http.Error(w, fmt.Sprintf("step %d: exchange %d not found", i+1, step.ExchangeID), http.StatusBadRequest)
+				return
+			}
+			request := *exchange.Request
+			step.Request = &request
+			if step.LocalPort == "" {
+				step.LocalPort = exchange.LocalPort
+			}
+		}
+		for i := range sc.Steps {
+			step := &sc.Steps[i]
+			if step.Overrides == nil || step.Request == nil {
+				continue
+			}
+			request, err := step.Overrides.apply(*step.Request)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("step %d: %v", i+1, err), http.StatusBadRequest)
+				return
+			}
+			step.Request, step.Overrides = &request, nil
+		}
+		if err := sc.validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.scenarios.Put(sc); err != nil {
+			http.Error(w, "Failed to save scenario", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(sc)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleScenario serves /api/scenarios/{name}: GET returns the scenario,
+// DELETE removes it, and POST on /api/scenarios/{name}/run runs it with an
+// optional ScenarioRun and answers with the ScenarioResult.
+func (s *Server) handleScenario(w http.ResponseWriter, r *http.Request, path string) {
+	name, run := strings.CutSuffix(path, "/run")
+	sc, ok := s.scenarios.Get(name)
+	if !ok {
+		http.Error(w, "Scenario not found", http.StatusNotFound)
+		return
+	}
+
+	switch {
+	case run && r.Method == http.MethodPost:
+		var opts ScenarioRun
+		if !readJSONBody(w, r, &opts) {
+			return
+		}
+		client := &http.Client{Timeout: 30 * time.Second}
+		result := s.runScenario(r.Context(), client, sc, opts)
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	case !run && r.Method == http.MethodGet:
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(sc)
+	case !run && r.Method == http.MethodDelete:
+		if _, err := s.scenarios.Delete(name); err != nil {
+			http.Error(w, "Failed to delete scenario", http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
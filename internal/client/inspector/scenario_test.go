@@ -0,0 +1,128 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExpand(t *testing.T) {
+	req := HTTPRequest{
+		URL:     "/orders/{{id}}",
+		Headers: map[string][]string{"Authorization": {"Bearer {{ token }}"}},
+		Body:    `{"id":"{{id}}"}`,
+	}
+	got, err := expand(req, map[string]string{"id": "7", "token": "t-1"})
+	if err != nil {
+		t.Fatalf("expand() error = %v", err)
+	}
+	if got.URL != "/orders/7" || got.Headers["Authorization"][0] != "Bearer t-1" || got.Body != `{"id":"7"}` {
+		t.Errorf("expand() = %+v", got)
+	}
+	if req.Headers["Authorization"][0] != "Bearer {{ token }}" {
+		t.Error("expand() changed the step's headers")
+	}
+	if _, err := expand(req, map[string]string{"id": "7"}); err == nil || !strings.Contains(err.Error(), "token") {
+		t.Errorf("expand() without token error = %v", err)
+	}
+}
+
+func TestServer_Scenario(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/login":
+			w.Header().Set("X-Session", "s-9")
+			w.Write([]byte(`{"token":"t-1","user":{"id":42}}`))
+		case "/users/42":
+			if r.Header.Get("Authorization") != "Bearer t-1" || r.Header.Get("X-Session") != "s-9" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"name":"Ivan"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	store := NewInMemoryStore(10)
+	store.Insert(HTTPExchange{ID: 1, LocalPort: port, Request: &HTTPRequest{Method: "POST", URL: "/login", Body: `{"user":"ivan"}`}})
+	store.Insert(HTTPExchange{ID: 2, LocalPort: port, Request: &HTTPRequest{Method: "GET", URL: "/users/1", Headers: map[string][]string{"Authorization": {"Bearer old"}}}})
+	path := filepath.Join(t.TempDir(), "scenarios.json")
+	srv := NewServer(store, WithScenariosPath(path))
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	do := func(method, url, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, url, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	w := do("POST", "/api/scenarios", `{"name":"login-flow","steps":[
+		{"name":"login","exchange_id":1,"extract":{"token":"$.token","uid":"$.user.id","session":"header:X-Session"},"assertions":[{"status":200}]},
+		{"name":"profile","exchange_id":2,"overrides":{"path":"/users/{{uid}}","headers":{"Authorization":["Bearer {{token}}"],"X-Session":["{{session}}"]}},
+		 "assertions":[{"json_path":"$.name","equals":"Ivan"}]}
+	]}`)
+	if w.Code != http.StatusCreated {
+		t.Fatalf("create = %d: %s", w.Code, w.Body)
+	}
+
+	// Scenarios outlive the exchanges and the server
+	store.Clear()
+	srv = NewServer(store, WithScenariosPath(path))
+	mux = http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	w = do("POST", "/api/scenarios/login-flow/run", "")
+	var result ScenarioResult
+	json.NewDecoder(w.Body).Decode(&result)
+	if !result.Passed || len(result.Steps) != 2 {
+		t.Fatalf("run = %d: %+v", w.Code, result)
+	}
+	if result.Vars["uid"] != "42" || result.Vars["token"] != "t-1" || result.Steps[1].URL != "/users/42" {
+		t.Errorf("vars = %v, steps = %+v", result.Vars, result.Steps)
+	}
+
+	// A wrong token fails the second step
+	w = do("POST", "/api/scenarios/login-flow/run", `{"vars":{"token":"stale"}}`)
+	json.NewDecoder(w.Body).Decode(&result)
+	if !result.Passed {
+		t.Errorf("vars given to the run should be overwritten by extraction: %+v", result)
+	}
+
+	w = do("POST", "/api/scenarios/login-flow/run", `{"target":"http://localhost:1"}`)
+	result = ScenarioResult{}
+	json.NewDecoder(w.Body).Decode(&result)
+	if result.Passed || len(result.Steps) != 1 || result.Steps[0].Error == "" {
+		t.Errorf("run against a closed port = %+v, want the first step to fail", result)
+	}
+
+	for _, body := range []string{
+		`{"name":"bad name","steps":[{"request":{"method":"GET","url":"/"}}]}`,
+		`{"name":"empty","steps":[]}`,
+		`{"name":"missing","steps":[{"exchange_id":99}]}`,
+		`{"name":"extract","steps":[{"request":{"method":"GET","url":"/"},"extract":{"x":"body"}}]}`,
+	} {
+		if w := do("POST", "/api/scenarios", body); w.Code != http.StatusBadRequest {
+			t.Errorf("create %s = %d, want 400", body, w.Code)
+		}
+	}
+
+	if w := do("GET", "/api/scenarios", ""); !strings.Contains(w.Body.String(), `"login-flow"`) {
+		t.Errorf("list = %s", w.Body)
+	}
+	if w := do("DELETE", "/api/scenarios/login-flow", ""); w.Code != http.StatusNoContent {
+		t.Errorf("delete = %d", w.Code)
+	}
+	if w := do("POST", "/api/scenarios/login-flow/run", ""); w.Code != http.StatusNotFound {
+		t.Errorf("run after delete = %d, want 404", w.Code)
+	}
+}
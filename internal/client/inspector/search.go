@@ -0,0 +1,154 @@
+package inspector
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultSearchLimit is the page size when the request sets none.
+	defaultSearchLimit = 50
+	// maxSearchLimit bounds the page size.
+	maxSearchLimit = 500
+)
+
+// SearchQuery filters stored exchanges. Zero fields match everything.
+type SearchQuery struct {
+	Text   string // Case-insensitive, in the URL, header names and values, and bodies
+	Method string // Exact, case-insensitive
+	Status int    // Exact status, or its class if StatusClass is set
+	// StatusClass matches any status of the hundred of Status, e.g. "4xx".
+	StatusClass bool
+	Offset      int
+	Limit       int
+}
+
+// SearchResult is a page of matching exchanges, newest first.
+type SearchResult struct {
+	Total     int            `json:"total"` // Matches across all pages
+	Offset    int            `json:"offset"`
+	Limit     int            `json:"limit"`
+	Exchanges []HTTPExchange `json:"exchanges"`
+}
+
+// parseSearchQuery reads q, method, status (e.g. 404 or 4xx), offset and
+// limit from URL parameters.
+func parseSearchQuery(values url.Values) (SearchQuery, error) {
+	q := SearchQuery{
+		Text:   values.Get("q"),
+		Method: values.Get("method"),
+		Limit:  defaultSearchLimit,
+	}
+	if status := values.Get("status"); status != "" {
+		digits := status
+		if len(status) == 3 && strings.EqualFold(status[1:], "xx") {
+			digits = status[:1] + "00"
+			q.StatusClass = true
+		}
+		n, err := strconv.Atoi(digits)
+		if err != nil || n < 100 || n > 599 {
+			return q, errors.New("invalid status")
+		}
+		q.Status = n
+	}
+	if offset := values.Get("offset"); offset != "" {
+		n, err := strconv.Atoi(offset)
+		if err != nil || n < 0 {
+			return q, errors.New("invalid offset")
+		}
+		q.Offset = n
+	}
+	if limit := values.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n <= 0 {
+			return q, errors.New("invalid limit")
+		}
+		q.Limit = min(n, maxSearchLimit)
+	}
+	return q, nil
+}
+
+// Search returns the page of exchanges matching q.
+func Search(store Store, q SearchQuery) SearchResult {
+	result := SearchResult{Offset: q.Offset, Limit: q.Limit, Exchanges: []HTTPExchange{}}
+	text := strings.ToLower(q.Text)
+	for _, ex := range store.List() {
+		if !q.matches(&ex, text) {
+			continue
+		}
+		if result.Total >= q.Offset && len(result.Exchanges) < q.Limit {
+			result.Exchanges = append(result.Exchanges, ex)
+		}
+		result.Total++
+	}
+	return result
+}
+
+// matches reports whether ex passes the filters; text is q.Text lowercased.
+func (q SearchQuery) matches(ex *HTTPExchange, text string) bool {
+	if ex.Request == nil {
+		return false
+	}
+	if q.Method != "" && !strings.EqualFold(ex.Request.Method, q.Method) {
+		return false
+	}
+	if q.Status != 0 {
+		if ex.Response == nil {
+			return false
+		}
+		if q.StatusClass && ex.Response.Status/100 != q.Status/100 {
+			return false
+		}
+		if !q.StatusClass && ex.Response.Status != q.Status {
+			return false
+		}
+	}
+	if text == "" {
+		return true
+	}
+	if containsFold(ex.Request.URL, text) || containsFold(ex.Request.Body, text) || headersContain(ex.Request.Headers, text) {
+		return true
+	}
+	return ex.Response != nil && (containsFold(ex.Response.Body, text) || headersContain(ex.Response.Headers, text))
+}
+
+// containsFold reports whether s contains lower, which is lowercase.
+func containsFold(s, lower string) bool {
+	return strings.Contains(strings.ToLower(s), lower)
+}
+
+func headersContain(headers map[string][]string, lower string) bool {
+	for name, values := range headers {
+		if containsFold(name, lower) {
+			return true
+		}
+		for _, v := range values {
+			if containsFold(v, lower) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// searchHandler serves GET /api/exchanges/search from store, see
+// parseSearchQuery for the parameters.
+func searchHandler(store Store) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		q, err := parseSearchQuery(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(Search(store, q))
+	}
+}
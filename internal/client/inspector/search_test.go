@@ -0,0 +1,115 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func searchStore() *InMemoryStore {
+	store := NewInMemoryStore(100)
+	store.Add(HTTPExchange{
+		Request:  &HTTPRequest{Method: "GET", URL: "/health"},
+		Response: &HTTPResponse{Status: 200, Body: "ok"},
+	})
+	store.Add(HTTPExchange{
+		Request:  &HTTPRequest{Method: "POST", URL: "/orders", Body: `{"sku":"ABC-42"}`},
+		Response: &HTTPResponse{Status: 422, Body: "out of stock"},
+	})
+	store.Add(HTTPExchange{
+		Request:  &HTTPRequest{Method: "GET", URL: "/orders/7", Headers: map[string][]string{"X-Trace-Id": {"trace-99"}}},
+		Response: &HTTPResponse{Status: 404},
+	})
+	store.Add(HTTPExchange{Request: &HTTPRequest{Method: "GET", URL: "/pending"}})
+	return store
+}
+
+func TestSearch(t *testing.T) {
+	store := searchStore()
+
+	tests := []struct {
+		name  string
+		query SearchQuery
+		want  []string // URLs, newest first
+	}{
+		{"all", SearchQuery{Limit: 10}, []string{"/pending", "/orders/7", "/orders", "/health"}},
+		{"path", SearchQuery{Text: "ORDERS", Limit: 10}, []string{"/orders/7", "/orders"}},
+		{"request body", SearchQuery{Text: "abc-42", Limit: 10}, []string{"/orders"}},
+		{"response body", SearchQuery{Text: "stock", Limit: 10}, []string{"/orders"}},
+		{"header value", SearchQuery{Text: "trace-99", Limit: 10}, []string{"/orders/7"}},
+		{"header name", SearchQuery{Text: "x-trace", Limit: 10}, []string{"/orders/7"}},
+		{"method", SearchQuery{Method: "post", Limit: 10}, []string{"/orders"}},
+		{"status", SearchQuery{Status: 404, Limit: 10}, []string{"/orders/7"}},
+		{"status class", SearchQuery{Status: 400, StatusClass: true, Limit: 10}, []string{"/orders/7", "/orders"}},
+		{"combined", SearchQuery{Text: "orders", Method: "GET", Limit: 10}, []string{"/orders/7"}},
+		{"no match", SearchQuery{Text: "nothing", Limit: 10}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := Search(store, tt.query)
+			if result.Total != len(tt.want) || len(result.Exchanges) != len(tt.want) {
+				t.Fatalf("Search() found %d (%d on page), want %d", result.Total, len(result.Exchanges), len(tt.want))
+			}
+			for i, ex := range result.Exchanges {
+				if ex.Request.URL != tt.want[i] {
+					t.Errorf("result %d = %s, want %s", i, ex.Request.URL, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestSearch_Paging(t *testing.T) {
+	result := Search(searchStore(), SearchQuery{Offset: 1, Limit: 2})
+	if result.Total != 4 {
+		t.Errorf("Total = %d, want 4", result.Total)
+	}
+	if len(result.Exchanges) != 2 || result.Exchanges[0].Request.URL != "/orders/7" || result.Exchanges[1].Request.URL != "/orders" {
+		t.Errorf("page = %+v, want /orders/7 and /orders", result.Exchanges)
+	}
+}
+
+func TestParseSearchQuery(t *testing.T) {
+	req := httptest.NewRequest("GET", "/api/exchanges/search?q=err&method=POST&status=5xx&offset=10&limit=1000", nil)
+	q, err := parseSearchQuery(req.URL.Query())
+	if err != nil {
+		t.Fatalf("parseSearchQuery() error = %v", err)
+	}
+	want := SearchQuery{Text: "err", Method: "POST", Status: 500, StatusClass: true, Offset: 10, Limit: maxSearchLimit}
+	if q != want {
+		t.Errorf("parseSearchQuery() = %+v, want %+v", q, want)
+	}
+
+	for _, bad := range []string{"status=abc", "status=99", "status=6xx", "offset=-1", "limit=0"} {
+		req := httptest.NewRequest("GET", "/api/exchanges/search?"+bad, nil)
+		if _, err := parseSearchQuery(req.URL.Query()); err == nil {
+			t.Errorf("parseSearchQuery(%s) = nil error, want error", bad)
+		}
+	}
+}
+
+func TestServer_SearchEndpoint(t *testing.T) {
+	srv := NewServer(searchStore(), WithLocalPort("3000"))
+	mux := http.NewServeMux()
+	srv.setupRoutes(mux)
+
+	rec := httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/exchanges/search?q=orders&limit=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", rec.Code)
+	}
+	var result SearchResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if result.Total != 2 || len(result.Exchanges) != 1 || result.Exchanges[0].Request.URL != "/orders/7" {
+		t.Errorf("result = %+v, want 2 matches with /orders/7 first", result)
+	}
+
+	rec = httptest.NewRecorder()
+	mux.ServeHTTP(rec, httptest.NewRequest("GET", "/api/exchanges/search?status=abc", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid status: code = %d, want 400", rec.Code)
+	}
+}
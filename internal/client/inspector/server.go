@@ -1,16 +1,16 @@
 package inspector
 
 import (
-	"bytes"
 	"context"
 	_ "embed"
 	"encoding/json"
-	"io"
 	"net/http"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
+
+	"gopublic/internal/client/events"
 )
 
 //go:embed index.html
@@ -23,6 +23,24 @@ type HTTPExchange struct {
 	Response  *HTTPResponse `json:"response,omitempty"`
 	Duration  int64         `json:"duration_ms"`
 	Timestamp time.Time     `json:"timestamp"`
+	Aborted   bool          `json:"aborted,omitempty"`    // Public caller disconnected mid-response
+	BytesSent int64         `json:"bytes_sent,omitempty"` // Response bytes sent before the abort
+
+	// Tunnel and LocalPort record the tunnel that forwarded the request,
+	// see WithOrigin; replay uses LocalPort before the server's port.
+	Tunnel    string `json:"tunnel,omitempty"`
+	LocalPort string `json:"local_port,omitempty"`
+
+	// WebSocket marks a connection upgraded by the local service, see AddUpgrade.
+	// Its frames are not captured, only how many bytes went each way.
+	WebSocket bool  `json:"websocket,omitempty"`
+	WSOpen    bool  `json:"ws_open,omitempty"`      // Still connected
+	WSIn      int64 `json:"ws_bytes_in,omitempty"`  // Bytes from the public caller after the upgrade
+	WSOut     int64 `json:"ws_bytes_out,omitempty"` // Bytes from the local service after the upgrade
+
+	// Imported marks an exchange added from a curl command or HAR file
+	// through /api/import rather than captured from the tunnel.
+	Imported bool `json:"imported,omitempty"`
 }
 
 // HTTPRequest captures request details
@@ -33,6 +51,7 @@ type HTTPRequest struct {
 	Headers map[string][]string `json:"headers"`
 	Body    string              `json:"body"`
 	Size    int64               `json:"size"`
+	BinaryBody
 }
 
 // HTTPResponse captures response details
@@ -41,29 +60,82 @@ type HTTPResponse struct {
 	Proto   string              `json:"proto"`
 	Headers map[string][]string `json:"headers"`
 	Body    string              `json:"body"`
-	Size    int64               `json:"size"`
+	Size    int64               `json:"size"` // Bytes transferred, before decoding
+
+	// ContentEncoding lists the codings of a compressed body; Decoded
+	// reports whether Body holds it decoded, DecodedSize bytes long.
+	// Bodies that do not decode, e.g. brotli, are kept as transferred.
+	ContentEncoding string `json:"content_encoding,omitempty"`
+	Decoded         bool   `json:"decoded,omitempty"`
+	DecodedSize     int64  `json:"decoded_size,omitempty"`
+
+	BinaryBody
 }
 
 const maxBodySize int64 = 1024 * 1024 // 1MB max body capture
 
+// defaultAddr is where the inspector listens unless WithAddr says otherwise.
+const defaultAddr = ":4040"
+
 // Server represents the inspector HTTP server with its own state.
 type Server struct {
 	store     Store
-	localPort string
+	captures  captureQueue
+	tunnels   TunnelList
+	stream    Stream
+	prefs     PreferenceStore
+	scenarios ScenarioStore
+	bodies    bodyStore
 	httpSrv   *http.Server
 	addr      string
+
+	mu        sync.RWMutex
+	localPort string
+}
+
+// Option configures a Server.
+type Option func(*Server)
+
+// WithAddr sets the listen address (default ":4040").
+func WithAddr(addr string) Option {
+	return func(s *Server) { s.addr = addr }
+}
+
+// WithLocalPort sets the local port replayed requests are sent to when
+// their exchange does not record one.
+func WithLocalPort(port string) Option {
+	return func(s *Server) { s.localPort = port }
+}
+
+// WithPreferencesPath keeps the UI preferences in a JSON file at path
+// instead of memory.
+func WithPreferencesPath(path string) Option {
+	return func(s *Server) { s.prefs.path = path }
 }
 
-// NewServer creates a new inspector server.
-func NewServer(port, localPort string, store Store) *Server {
+// WithScenariosPath keeps the scenarios in a JSON file at path instead of
+// memory.
+func WithScenariosPath(path string) Option {
+	return func(s *Server) { s.scenarios.path = path }
+}
+
+// NewServer creates an inspector server recording to store, or to an
+// in-memory store of 100 exchanges if store is nil.
+func NewServer(store Store, opts ...Option) *Server {
 	if store == nil {
 		store = NewInMemoryStore(100)
 	}
-	return &Server{
-		store:     store,
-		localPort: localPort,
-		addr:      ":" + port,
+	s := &Server{
+		store:  store,
+		addr:   defaultAddr,
+		bodies: bodyStore{limit: maxRawBodyBytes},
+	}
+	s.captures.queue = make(chan func(Store) int64, captureQueueSize)
+	s.captures.nextID.Store(store.NextID())
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
 }
 
 // Start starts the inspector server and blocks until context is cancelled.
@@ -104,34 +176,6 @@ func (s *Server) Shutdown(ctx context.Context) error {
 	return s.httpSrv.Shutdown(ctx)
 }
 
-// AddExchange adds an exchange to the server's store.
-func (s *Server) AddExchange(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, duration time.Duration) int64 {
-	exchange := HTTPExchange{
-		Timestamp: time.Now(),
-		Duration:  duration.Milliseconds(),
-		Request: &HTTPRequest{
-			Method:  req.Method,
-			URL:     req.URL.String(),
-			Proto:   req.Proto,
-			Headers: req.Header,
-			Body:    truncateBody(reqBody),
-			Size:    int64(len(reqBody)),
-		},
-	}
-
-	if resp != nil {
-		exchange.Response = &HTTPResponse{
-			Status:  resp.StatusCode,
-			Proto:   resp.Proto,
-			Headers: resp.Header,
-			Body:    truncateBody(respBody),
-			Size:    int64(len(respBody)),
-		}
-	}
-
-	return s.store.Add(exchange)
-}
-
 // Store returns the server's exchange store.
 func (s *Server) Store() Store {
 	return s.store
@@ -139,9 +183,16 @@ func (s *Server) Store() Store {
 
 // SetLocalPort updates the local port for replay functionality.
 func (s *Server) SetLocalPort(port string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.localPort = port
 }
 
+// TrackTunnels feeds /api/tunnels from the client's event bus.
+func (s *Server) TrackTunnels(bus *events.Bus) {
+	s.tunnels.Track(bus)
+}
+
 func (s *Server) setupRoutes(mux *http.ServeMux) {
 	// Serve UI
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
@@ -160,6 +211,9 @@ func (s *Server) setupRoutes(mux *http.ServeMux) {
 		json.NewEncoder(w).Encode(exchanges)
 	})
 
+	// Search exchanges
+	mux.HandleFunc("/api/exchanges/search", searchHandler(s.store))
+
 	// Get single exchange or replay
 	mux.HandleFunc("/api/exchanges/", func(w http.ResponseWriter, r *http.Request) {
 		idStr := strings.TrimPrefix(r.URL.Path, "/api/exchanges/")
@@ -170,6 +224,18 @@ func (s *Server) setupRoutes(mux *http.ServeMux) {
 			return
 		}
 
+		// A raw body as a download
+		if rest, ok := strings.CutSuffix(idStr, "/body"); ok {
+			s.handleBody(w, r, rest)
+			return
+		}
+
+		// The request as a curl command
+		if rest, ok := strings.CutSuffix(idStr, "/curl"); ok {
+			s.handleCurl(w, r, rest)
+			return
+		}
+
 		id, err := strconv.ParseInt(idStr, 10, 64)
 		if err != nil {
 			http.Error(w, "Invalid ID", http.StatusBadRequest)
@@ -195,282 +261,51 @@ func (s *Server) setupRoutes(mux *http.ServeMux) {
 		s.handleReplay(w, r, strings.TrimPrefix(r.URL.Path, "/api/replay/"))
 	})
 
-	// Clear exchanges
-	mux.HandleFunc("/api/clear", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != "POST" {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		s.store.Clear()
-		w.WriteHeader(http.StatusOK)
-	})
-}
-
-func (s *Server) handleReplay(w http.ResponseWriter, r *http.Request, idStr string) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
-	}
-
-	exchange, ok := s.store.Get(id)
-	if !ok {
-		http.Error(w, "Exchange not found", http.StatusNotFound)
-		return
-	}
-
-	if s.localPort == "" {
-		http.Error(w, "Replay not configured (no local port)", http.StatusInternalServerError)
-		return
-	}
-
-	// Reconstruct the request
-	reqURL := "http://localhost:" + s.localPort + exchange.Request.URL
-	req, err := http.NewRequest(exchange.Request.Method, reqURL, bytes.NewReader([]byte(exchange.Request.Body)))
-	if err != nil {
-		http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Copy headers
-	for k, vv := range exchange.Request.Headers {
-		for _, v := range vv {
-			req.Header.Add(k, v)
-		}
-	}
-
-	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		http.Error(w, "Replay failed: "+err.Error(), http.StatusBadGateway)
-		return
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		http.Error(w, "Failed to read response: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Return response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  resp.StatusCode,
-		"headers": resp.Header,
-		"body":    string(respBody),
+	// Repeated replay for smoke load tests
+	mux.HandleFunc("/api/bench/", func(w http.ResponseWriter, r *http.Request) {
+		s.handleBench(w, r, strings.TrimPrefix(r.URL.Path, "/api/bench/"))
 	})
-}
-
-// truncateBody limits body size for storage
-func truncateBody(body []byte) string {
-	if int64(len(body)) > maxBodySize {
-		return string(body[:maxBodySize]) + "\n... (truncated)"
-	}
-	return string(body)
-}
-
-// ============================================================================
-// Global state and functions for backward compatibility
-// These will be used until CLI is refactored to use Server directly
-// ============================================================================
-
-var (
-	globalStore Store
-	globalMu    sync.RWMutex
-	globalPort  string
-)
-
-func init() {
-	globalStore = NewInMemoryStore(100)
-}
-
-// SetLocalPort configures the local port for replay functionality (global).
-func SetLocalPort(port string) {
-	globalMu.Lock()
-	defer globalMu.Unlock()
-	globalPort = port
-}
 
-// AddExchange records a complete HTTP exchange (global).
-func AddExchange(req *http.Request, reqBody []byte, resp *http.Response, respBody []byte, duration time.Duration) int64 {
-	exchange := HTTPExchange{
-		Timestamp: time.Now(),
-		Duration:  duration.Milliseconds(),
-		Request: &HTTPRequest{
-			Method:  req.Method,
-			URL:     req.URL.String(),
-			Proto:   req.Proto,
-			Headers: req.Header,
-			Body:    truncateBody(reqBody),
-			Size:    int64(len(reqBody)),
-		},
-	}
+	// Public URLs of the running client
+	mux.Handle("/api/tunnels", &s.tunnels)
 
-	if resp != nil {
-		exchange.Response = &HTTPResponse{
-			Status:  resp.StatusCode,
-			Proto:   resp.Proto,
-			Headers: resp.Header,
-			Body:    truncateBody(respBody),
-			Size:    int64(len(respBody)),
-		}
-	}
-
-	return globalStore.Add(exchange)
-}
+	// Live exchanges
+	mux.Handle("/api/stream", &s.stream)
 
-// GetExchange retrieves a specific exchange by ID (global).
-func GetExchange(id int64) (*HTTPExchange, bool) {
-	return globalStore.Get(id)
-}
+	// Latest client log messages
+	mux.HandleFunc("/api/logs", handleLogs)
 
-// Start launches the inspector web server (global, legacy).
-func Start(port string) {
-	mux := http.NewServeMux()
+	// UI preferences
+	mux.Handle("/api/preferences", &s.prefs)
 
-	// Serve UI
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		if r.URL.Path != "/" {
-			http.NotFound(w, r)
-			return
-		}
-		w.Header().Set("Content-Type", "text/html")
-		w.Write(indexHTML)
+	// Multi-step scenarios built from exchanges
+	mux.HandleFunc("/api/scenarios", s.handleScenarios)
+	mux.HandleFunc("/api/scenarios/", func(w http.ResponseWriter, r *http.Request) {
+		s.handleScenario(w, r, strings.TrimPrefix(r.URL.Path, "/api/scenarios/"))
 	})
 
-	// List all exchanges
-	mux.HandleFunc("/api/exchanges", func(w http.ResponseWriter, r *http.Request) {
-		exchanges := globalStore.List()
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(exchanges)
-	})
-
-	// Get single exchange
-	mux.HandleFunc("/api/exchanges/", func(w http.ResponseWriter, r *http.Request) {
-		idStr := strings.TrimPrefix(r.URL.Path, "/api/exchanges/")
-
-		// Handle replay endpoint
-		if strings.HasPrefix(idStr, "replay/") {
-			handleGlobalReplay(w, r, strings.TrimPrefix(idStr, "replay/"))
-			return
-		}
-
-		id, err := strconv.ParseInt(idStr, 10, 64)
-		if err != nil {
-			http.Error(w, "Invalid ID", http.StatusBadRequest)
-			return
-		}
-
-		exchange, ok := GetExchange(id)
-		if !ok {
-			http.Error(w, "Not found", http.StatusNotFound)
-			return
-		}
+	// Stored exchanges as an HTTP Archive
+	mux.HandleFunc("/api/export/har", s.handleExportHAR)
 
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(exchange)
-	})
+	// Requests from curl commands or HAR files, for replay
+	mux.HandleFunc("/api/import", s.handleImport)
 
-	// Replay endpoint
-	mux.HandleFunc("/api/replay/", func(w http.ResponseWriter, r *http.Request) {
+	// Clear exchanges
+	mux.HandleFunc("/api/clear", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
 			return
 		}
-		handleGlobalReplay(w, r, strings.TrimPrefix(r.URL.Path, "/api/replay/"))
-	})
-
-	go http.ListenAndServe(":"+port, mux)
-}
-
-// handleGlobalReplay handles replay using global state.
-func handleGlobalReplay(w http.ResponseWriter, r *http.Request, idStr string) {
-	if r.Method != "POST" {
-		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		return
-	}
-
-	id, err := strconv.ParseInt(idStr, 10, 64)
-	if err != nil {
-		http.Error(w, "Invalid ID", http.StatusBadRequest)
-		return
-	}
-
-	exchange, ok := GetExchange(id)
-	if !ok {
-		http.Error(w, "Exchange not found", http.StatusNotFound)
-		return
-	}
-
-	globalMu.RLock()
-	port := globalPort
-	globalMu.RUnlock()
-
-	if port == "" {
-		http.Error(w, "Replay not configured (no local port)", http.StatusInternalServerError)
-		return
-	}
-
-	// Reconstruct the request
-	reqURL := "http://localhost:" + port + exchange.Request.URL
-	req, err := http.NewRequest(exchange.Request.Method, reqURL, bytes.NewReader([]byte(exchange.Request.Body)))
-	if err != nil {
-		http.Error(w, "Failed to create request: "+err.Error(), http.StatusInternalServerError)
-		return
-	}
-
-	// Copy headers
-	for k, vv := range exchange.Request.Headers {
-		for _, v := range vv {
-			req.Header.Add(k, v)
-		}
-	}
-
-	// Execute request
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		http.Error(w, "Replay failed: "+err.Error(), http.StatusBadGateway)
-		return
-	}
-	defer resp.Body.Close()
-
-	respBody, _ := io.ReadAll(resp.Body)
-
-	// Return response
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  resp.StatusCode,
-		"headers": resp.Header,
-		"body":    string(respBody),
+		s.store.Clear()
+		s.bodies.clear()
+		w.WriteHeader(http.StatusOK)
 	})
 }
 
-// AddRequest is a legacy function for backward compatibility.
-func AddRequest(method, host, path string, status int) {
-	exchange := HTTPExchange{
-		Timestamp: time.Now(),
-		Request: &HTTPRequest{
-			Method: method,
-			URL:    path,
-			Headers: map[string][]string{
-				"Host": {host},
-			},
-		},
-	}
-
-	if status > 0 {
-		exchange.Response = &HTTPResponse{
-			Status: status,
-		}
+// truncateBody limits body size for storage
+func truncateBody(body []byte) string {
+	if int64(len(body)) > maxBodySize {
+		return string(body[:maxBodySize]) + "\n... (truncated)"
 	}
-
-	globalStore.Add(exchange)
+	return string(body)
 }
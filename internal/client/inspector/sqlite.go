@@ -0,0 +1,236 @@
+//go:build cgo
+
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"gopublic/internal/client/logger"
+)
+
+// storedExchange is the row of an exchange in SQLiteStore. The exchange
+// itself is kept as JSON, so new HTTPExchange fields need no migration.
+type storedExchange struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement:false"`
+	Timestamp time.Time `gorm:"index"`
+	Data      []byte
+}
+
+func (storedExchange) TableName() string { return "exchanges" }
+
+// SQLiteStore implements Store in a SQLite database, so captured traffic
+// survives client restarts. The oldest exchanges are evicted beyond
+// maxSize, and exchanges older than maxAge when one is set.
+//
+// Store methods cannot fail, so database errors are logged and the
+// exchange concerned is lost.
+type SQLiteStore struct {
+	db      *gorm.DB
+	maxSize int
+	maxAge  time.Duration
+
+	mu     sync.Mutex // Serializes writes and guards nextID
+	nextID int64
+}
+
+// OpenSQLiteStore opens or creates the store at path. maxSize <= 0 keeps
+// 1000 exchanges; maxAge 0 keeps exchanges regardless of age.
+func OpenSQLiteStore(path string, maxSize int, maxAge time.Duration) (*SQLiteStore, error) {
+	if maxSize <= 0 {
+		maxSize = 1000
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, err
+	}
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{Logger: gormlogger.Discard})
+	if err != nil {
+		return nil, fmt.Errorf("open inspector database: %w", err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// A single connection avoids SQLITE_BUSY between the capture writer
+	// and the API handlers
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&storedExchange{}); err != nil {
+		sqlDB.Close()
+		return nil, fmt.Errorf("migrate inspector database: %w", err)
+	}
+
+	s := &SQLiteStore{db: db, maxSize: maxSize, maxAge: maxAge}
+	var last []int64
+	if err := db.Model(&storedExchange{}).Order("id DESC").Limit(1).Pluck("id", &last).Error; err != nil {
+		sqlDB.Close()
+		return nil, err
+	}
+	if len(last) > 0 {
+		s.nextID = last[0] + 1
+	}
+	s.evict()
+	return s, nil
+}
+
+// Close closes the database.
+func (s *SQLiteStore) Close() error {
+	sqlDB, err := s.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// Add adds a new exchange and returns its ID.
+func (s *SQLiteStore) Add(exchange HTTPExchange) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	exchange.ID = s.nextID
+	s.insertLocked(exchange)
+	return exchange.ID
+}
+
+// Insert adds an exchange under the ID set by the caller, which must be
+// unique. Later calls to Add continue after it.
+func (s *SQLiteStore) Insert(exchange HTTPExchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.insertLocked(exchange)
+}
+
+// insertLocked stores exchange and evicts what no longer fits.
+// The caller must hold s.mu.
+func (s *SQLiteStore) insertLocked(exchange HTTPExchange) {
+	if exchange.ID >= s.nextID {
+		s.nextID = exchange.ID + 1
+	}
+	data, err := json.Marshal(exchange)
+	if err != nil {
+		logger.Warn("Inspector: failed to encode exchange %d: %v", exchange.ID, err)
+		return
+	}
+	row := storedExchange{ID: exchange.ID, Timestamp: exchange.Timestamp, Data: data}
+	if err := s.db.Create(&row).Error; err != nil {
+		logger.Warn("Inspector: failed to store exchange %d: %v", exchange.ID, err)
+		return
+	}
+	s.evict()
+}
+
+// evict deletes the exchanges beyond maxSize and those older than maxAge.
+func (s *SQLiteStore) evict() {
+	keep := s.db.Model(&storedExchange{}).Select("id").Order("id DESC").Limit(s.maxSize)
+	if err := s.db.Where("id NOT IN (?)", keep).Delete(&storedExchange{}).Error; err != nil {
+		logger.Warn("Inspector: failed to evict exchanges: %v", err)
+	}
+	if s.maxAge > 0 {
+		cutoff := time.Now().Add(-s.maxAge)
+		if err := s.db.Where("timestamp < ?", cutoff).Delete(&storedExchange{}).Error; err != nil {
+			logger.Warn("Inspector: failed to evict exchanges: %v", err)
+		}
+	}
+}
+
+// NextID returns the ID the next call to Add would assign.
+func (s *SQLiteStore) NextID() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.nextID
+}
+
+// Get retrieves an exchange by ID.
+func (s *SQLiteStore) Get(id int64) (*HTTPExchange, bool) {
+	var rows []storedExchange
+	if err := s.db.Where("id = ?", id).Limit(1).Find(&rows).Error; err != nil {
+		logger.Warn("Inspector: failed to load exchange %d: %v", id, err)
+		return nil, false
+	}
+	if len(rows) == 0 {
+		return nil, false
+	}
+	ex, err := decodeExchange(rows[0])
+	if err != nil {
+		return nil, false
+	}
+	return &ex, true
+}
+
+// Update applies fn to the exchange with the given ID and stores the
+// result. Reports whether it was found.
+func (s *SQLiteStore) Update(id int64, fn func(*HTTPExchange)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ex, ok := s.Get(id)
+	if !ok {
+		return false
+	}
+	fn(ex)
+	data, err := json.Marshal(ex)
+	if err != nil {
+		logger.Warn("Inspector: failed to encode exchange %d: %v", id, err)
+		return false
+	}
+	if err := s.db.Model(&storedExchange{}).Where("id = ?", id).Update("data", data).Error; err != nil {
+		logger.Warn("Inspector: failed to update exchange %d: %v", id, err)
+		return false
+	}
+	return true
+}
+
+// List returns all exchanges, newest first.
+func (s *SQLiteStore) List() []HTTPExchange {
+	var rows []storedExchange
+	if err := s.db.Order("id DESC").Find(&rows).Error; err != nil {
+		logger.Warn("Inspector: failed to list exchanges: %v", err)
+		return nil
+	}
+	exchanges := make([]HTTPExchange, 0, len(rows))
+	for _, row := range rows {
+		if ex, err := decodeExchange(row); err == nil {
+			exchanges = append(exchanges, ex)
+		}
+	}
+	return exchanges
+}
+
+// Clear removes all exchanges. IDs are not reused.
+func (s *SQLiteStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.db.Where("1 = 1").Delete(&storedExchange{}).Error; err != nil {
+		logger.Warn("Inspector: failed to clear exchanges: %v", err)
+	}
+}
+
+// Count returns the number of stored exchanges.
+func (s *SQLiteStore) Count() int {
+	var n int64
+	if err := s.db.Model(&storedExchange{}).Count(&n).Error; err != nil {
+		logger.Warn("Inspector: failed to count exchanges: %v", err)
+		return 0
+	}
+	return int(n)
+}
+
+// decodeExchange returns the exchange stored in row.
+func decodeExchange(row storedExchange) (HTTPExchange, error) {
+	var ex HTTPExchange
+	if err := json.Unmarshal(row.Data, &ex); err != nil {
+		logger.Warn("Inspector: skipping unreadable exchange %d: %v", row.ID, err)
+		return HTTPExchange{}, err
+	}
+	ex.ID = row.ID
+	return ex, nil
+}
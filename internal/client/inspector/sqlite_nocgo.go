@@ -0,0 +1,27 @@
+//go:build !cgo
+
+package inspector
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNoSQLite is returned by OpenSQLiteStore in builds without cgo, which
+// the SQLite driver needs.
+var ErrNoSQLite = errors.New("this build has no SQLite support (built without cgo)")
+
+// SQLiteStore is not available without cgo; see OpenSQLiteStore.
+type SQLiteStore struct {
+	Store
+}
+
+// OpenSQLiteStore returns ErrNoSQLite.
+func OpenSQLiteStore(path string, maxSize int, maxAge time.Duration) (*SQLiteStore, error) {
+	return nil, ErrNoSQLite
+}
+
+// Close does nothing.
+func (s *SQLiteStore) Close() error {
+	return nil
+}
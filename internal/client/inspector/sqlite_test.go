@@ -0,0 +1,79 @@
+//go:build cgo
+
+package inspector
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func openTestSQLiteStore(t *testing.T, path string, maxSize int, maxAge time.Duration) *SQLiteStore {
+	t.Helper()
+	store, err := OpenSQLiteStore(path, maxSize, maxAge)
+	if err != nil {
+		t.Fatalf("OpenSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestSQLiteStore_Persists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "inspector.db")
+	store := openTestSQLiteStore(t, path, 10, 0)
+
+	id := store.Add(HTTPExchange{
+		Timestamp: time.Now(),
+		Request:   &HTTPRequest{Method: "POST", URL: "/orders", Headers: map[string][]string{"X-Id": {"1"}}, Body: "{}"},
+		Response:  &HTTPResponse{Status: 201},
+	})
+	store.Insert(HTTPExchange{ID: 5, Timestamp: time.Now(), Request: &HTTPRequest{Method: "GET", URL: "/"}})
+	if !store.Update(id, func(ex *HTTPExchange) { ex.Aborted = true }) {
+		t.Fatal("Update() = false")
+	}
+	if store.Update(42, func(*HTTPExchange) {}) {
+		t.Error("Update(unknown) = true")
+	}
+	store.Close()
+
+	// A new client sees the traffic captured by the previous one
+	reopened := openTestSQLiteStore(t, path, 10, 0)
+	if got := reopened.NextID(); got != 6 {
+		t.Errorf("NextID() = %d, want 6", got)
+	}
+	list := reopened.List()
+	if len(list) != 2 || list[0].ID != 5 || list[1].ID != id {
+		t.Fatalf("List() = %+v, want IDs [5 %d]", list, id)
+	}
+	ex, ok := reopened.Get(id)
+	if !ok {
+		t.Fatal("Get() not found")
+	}
+	if !ex.Aborted || ex.Request.Body != "{}" || ex.Request.Headers["X-Id"][0] != "1" || ex.Response.Status != 201 {
+		t.Errorf("Get() = %+v", ex)
+	}
+
+	reopened.Clear()
+	if n := reopened.Count(); n != 0 {
+		t.Errorf("Count() after Clear = %d", n)
+	}
+	if got := reopened.Add(HTTPExchange{Timestamp: time.Now(), Request: &HTTPRequest{}}); got != 6 {
+		t.Errorf("Add() after Clear = %d, want 6", got)
+	}
+}
+
+func TestSQLiteStore_Eviction(t *testing.T) {
+	store := openTestSQLiteStore(t, filepath.Join(t.TempDir(), "inspector.db"), 3, time.Hour)
+
+	store.Add(HTTPExchange{Timestamp: time.Now().Add(-2 * time.Hour), Request: &HTTPRequest{}})
+	if n := store.Count(); n != 0 {
+		t.Errorf("Count() with an expired exchange = %d, want 0", n)
+	}
+	for i := 0; i < 5; i++ {
+		store.Add(HTTPExchange{Timestamp: time.Now(), Request: &HTTPRequest{}})
+	}
+	list := store.List()
+	if len(list) != 3 || list[0].ID != 5 || list[2].ID != 3 {
+		t.Errorf("List() IDs = %v, want the newest 3 (5..3)", list)
+	}
+}
@@ -8,8 +8,15 @@ import (
 type Store interface {
 	// Add adds a new exchange and returns its ID.
 	Add(exchange HTTPExchange) int64
+	// Insert adds an exchange under the ID set by the caller, which must
+	// be unique. Later calls to Add continue after it.
+	Insert(exchange HTTPExchange)
+	// NextID returns the ID the next call to Add would assign.
+	NextID() int64
 	// Get retrieves an exchange by ID.
 	Get(id int64) (*HTTPExchange, bool)
+	// Update modifies a stored exchange in place. Reports whether it was found.
+	Update(id int64, fn func(*HTTPExchange)) bool
 	// List returns all exchanges, newest first.
 	List() []HTTPExchange
 	// Clear removes all exchanges.
@@ -45,8 +52,26 @@ func (s *InMemoryStore) Add(exchange HTTPExchange) int64 {
 
 	exchange.ID = s.nextID
 	s.nextID++
+	s.prepend(exchange)
 
-	// Prepend to list (newest first)
+	return exchange.ID
+}
+
+// Insert adds an exchange under the ID set by the caller, which must be
+// unique (thread-safe). Later calls to Add continue after it.
+func (s *InMemoryStore) Insert(exchange HTTPExchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if exchange.ID >= s.nextID {
+		s.nextID = exchange.ID + 1
+	}
+	s.prepend(exchange)
+}
+
+// prepend puts exchange first (newest first), dropping the oldest when full.
+// The caller must hold s.mu.
+func (s *InMemoryStore) prepend(exchange HTTPExchange) {
 	// Use efficient prepend by creating a new slice only when necessary
 	if len(s.exchanges) >= s.maxSize {
 		// Shift elements to make room, drop oldest
@@ -59,8 +84,13 @@ func (s *InMemoryStore) Add(exchange HTTPExchange) int64 {
 		copy(newExchanges[1:], s.exchanges)
 		s.exchanges = newExchanges
 	}
+}
 
-	return exchange.ID
+// NextID returns the ID the next call to Add would assign (thread-safe).
+func (s *InMemoryStore) NextID() int64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.nextID
 }
 
 // Get retrieves an exchange by ID (thread-safe).
@@ -78,6 +108,20 @@ func (s *InMemoryStore) Get(id int64) (*HTTPExchange, bool) {
 	return nil, false
 }
 
+// Update applies fn to the exchange with the given ID (thread-safe).
+func (s *InMemoryStore) Update(id int64, fn func(*HTTPExchange)) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.exchanges {
+		if s.exchanges[i].ID == id {
+			fn(&s.exchanges[i])
+			return true
+		}
+	}
+	return false
+}
+
 // List returns all exchanges (thread-safe).
 // Returns a copy to prevent mutation of internal state.
 func (s *InMemoryStore) List() []HTTPExchange {
@@ -241,3 +241,40 @@ func TestInMemoryStore_DefaultMaxSize(t *testing.T) {
 		t.Errorf("expected default maxSize 100 for negative, got %d", store2.maxSize)
 	}
 }
+
+func TestInMemoryStore_Update(t *testing.T) {
+	store := NewInMemoryStore(10)
+	id := store.Add(HTTPExchange{Request: &HTTPRequest{Method: "GET"}})
+
+	ok := store.Update(id, func(ex *HTTPExchange) {
+		ex.Aborted = true
+		ex.BytesSent = 42
+	})
+	if !ok {
+		t.Fatal("Update should find the exchange")
+	}
+
+	ex, _ := store.Get(id)
+	if !ex.Aborted || ex.BytesSent != 42 {
+		t.Errorf("exchange = %+v, want aborted after 42 bytes", ex)
+	}
+
+	if store.Update(id+1, func(*HTTPExchange) {}) {
+		t.Error("Update should report missing exchange")
+	}
+}
+
+func TestInMemoryStore_Insert(t *testing.T) {
+	store := NewInMemoryStore(100)
+
+	store.Insert(HTTPExchange{ID: 7, Request: &HTTPRequest{Method: "GET", URL: "/seven"}})
+	ex, ok := store.Get(7)
+	if !ok || ex.Request.URL != "/seven" {
+		t.Fatalf("Get(7) = %+v, %v; want the inserted exchange", ex, ok)
+	}
+
+	// Add continues after inserted IDs
+	if id := store.Add(HTTPExchange{Request: &HTTPRequest{Method: "GET", URL: "/next"}}); id != 8 {
+		t.Errorf("expected Add after Insert to return 8, got %d", id)
+	}
+}
@@ -0,0 +1,109 @@
+package inspector
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// streamBuffer is how many exchanges a slow subscriber may lag behind
+	// before further ones are skipped for it.
+	streamBuffer = 64
+	// streamKeepAlive is the interval of SSE comments that keep proxies
+	// from closing an idle stream.
+	streamKeepAlive = 15 * time.Second
+)
+
+// Stream fans stored and updated exchanges out to live subscribers, such
+// as the UI connected to /api/stream. The zero value is ready to use.
+type Stream struct {
+	mu   sync.Mutex
+	subs map[chan HTTPExchange]struct{}
+}
+
+// Subscribe returns a channel of exchanges as they are added or updated,
+// and a function that ends the subscription.
+func (s *Stream) Subscribe() (<-chan HTTPExchange, func()) {
+	ch := make(chan HTTPExchange, streamBuffer)
+	s.mu.Lock()
+	if s.subs == nil {
+		s.subs = make(map[chan HTTPExchange]struct{})
+	}
+	s.subs[ch] = struct{}{}
+	s.mu.Unlock()
+
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subs, ch)
+		s.mu.Unlock()
+	}
+}
+
+// hasSubscribers reports whether publishing would reach anyone.
+func (s *Stream) hasSubscribers() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.subs) > 0
+}
+
+// publish sends ex to every subscriber without blocking; subscribers that
+// fell behind miss it and catch up through /api/exchanges.
+func (s *Stream) publish(ex HTTPExchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subs {
+		select {
+		case ch <- ex:
+		default:
+		}
+	}
+}
+
+// publishStored publishes the stored state of exchange id, if any.
+func (s *Stream) publishStored(store Store, id int64) {
+	if id < 0 || !s.hasSubscribers() {
+		return
+	}
+	if ex, ok := store.Get(id); ok {
+		s.publish(*ex)
+	}
+}
+
+// ServeHTTP streams exchanges as Server-Sent Events named "exchange", each
+// carrying the exchange as JSON, until the client disconnects.
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+	ch, cancel := s.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case ex := <-ch:
+			data, err := json.Marshal(ex)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: exchange\ndata: %s\n\n", data)
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		flusher.Flush()
+	}
+}
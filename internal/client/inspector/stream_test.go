@@ -0,0 +1,80 @@
+package inspector
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStream_PublishesAddsAndUpdates(t *testing.T) {
+	s := NewServer(nil)
+	ch, cancel := s.stream.Subscribe()
+	defer cancel()
+
+	req, _ := http.NewRequest("GET", "http://example.com/live", nil)
+	id := s.AddExchange(req, nil, &http.Response{StatusCode: http.StatusOK, Header: http.Header{}}, nil, 0)
+	s.MarkAborted(id, 3)
+	s.Flush()
+
+	for _, aborted := range []bool{false, true} {
+		select {
+		case ex := <-ch:
+			if ex.ID != id || ex.Aborted != aborted {
+				t.Errorf("streamed exchange %d aborted=%v, want %d aborted=%v", ex.ID, ex.Aborted, id, aborted)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("exchange not streamed")
+		}
+	}
+}
+
+func TestStream_ServeSSE(t *testing.T) {
+	s := NewServer(nil, WithLocalPort("3000"))
+	mux := http.NewServeMux()
+	s.setupRoutes(mux)
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/api/stream")
+	if err != nil {
+		t.Fatalf("GET /api/stream: %v", err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q, want text/event-stream", ct)
+	}
+
+	// The subscription is registered before the headers are flushed
+	req, _ := http.NewRequest("POST", "http://example.com/hook", nil)
+	id := s.AddExchange(req, []byte("ping"), nil, nil, 0)
+
+	lines := bufio.NewScanner(resp.Body)
+	var event string
+	for lines.Scan() {
+		line := lines.Text()
+		if name, ok := strings.CutPrefix(line, "event: "); ok {
+			event = name
+			continue
+		}
+		data, ok := strings.CutPrefix(line, "data: ")
+		if !ok {
+			continue
+		}
+		if event != "exchange" {
+			t.Errorf("event = %q, want exchange", event)
+		}
+		var ex HTTPExchange
+		if err := json.Unmarshal([]byte(data), &ex); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if ex.ID != id || ex.Request.Body != "ping" {
+			t.Errorf("streamed exchange = %d %q, want %d %q", ex.ID, ex.Request.Body, id, "ping")
+		}
+		return
+	}
+	t.Fatalf("stream ended without an exchange: %v", lines.Err())
+}
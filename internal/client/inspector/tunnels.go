@@ -0,0 +1,88 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"gopublic/internal/client/events"
+)
+
+// TunnelInfo is a public URL served by the running client.
+type TunnelInfo struct {
+	Name      string `json:"name,omitempty"` // Tunnel name from gopublic.yaml
+	PublicURL string `json:"public_url"`
+	Domain    string `json:"domain"`
+	Scheme    string `json:"scheme"`
+	LocalPort string `json:"local_port"`
+}
+
+// TunnelList tracks the tunnels bound by the client from its event bus.
+// The list is emptied while the client is disconnected.
+type TunnelList struct {
+	mu      sync.RWMutex
+	tunnels map[string]TunnelInfo // By domain
+}
+
+// Track updates the list from the bus until the bus is closed.
+func (l *TunnelList) Track(bus *events.Bus) {
+	ch := bus.Subscribe()
+	go func() {
+		for event := range ch {
+			l.handle(event)
+		}
+	}()
+}
+
+func (l *TunnelList) handle(event events.Event) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	switch event.Type {
+	case events.EventConnecting, events.EventDisconnected, events.EventReconnecting:
+		l.tunnels = nil
+	case events.EventTunnelReady:
+		data, ok := event.Data.(events.TunnelReadyData)
+		if !ok {
+			return
+		}
+		if l.tunnels == nil {
+			l.tunnels = make(map[string]TunnelInfo)
+		}
+		for _, domain := range data.BoundDomains {
+			l.tunnels[domain] = TunnelInfo{
+				Name:      data.Name,
+				PublicURL: data.Scheme + "://" + domain,
+				Domain:    domain,
+				Scheme:    data.Scheme,
+				LocalPort: data.LocalPort,
+			}
+		}
+	}
+}
+
+// List returns the bound tunnels ordered by domain.
+func (l *TunnelList) List() []TunnelInfo {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	list := make([]TunnelInfo, 0, len(l.tunnels))
+	for _, t := range l.tunnels {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	return list
+}
+
+// ServeHTTP lists the bound tunnels as JSON.
+func (l *TunnelList) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"tunnels": l.List(),
+	})
+}
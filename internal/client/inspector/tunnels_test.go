@@ -0,0 +1,79 @@
+package inspector
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gopublic/internal/client/events"
+)
+
+func TestTunnelList(t *testing.T) {
+	var l TunnelList
+	l.handle(events.Event{Type: events.EventTunnelReady, Data: events.TunnelReadyData{
+		Name:         "web",
+		LocalPort:    "3000",
+		BoundDomains: []string{"misty-river.example.com"},
+		Scheme:       "https",
+	}})
+	l.handle(events.Event{Type: events.EventTunnelReady, Data: events.TunnelReadyData{
+		Name:         "api",
+		LocalPort:    "8000",
+		BoundDomains: []string{"brave-fox.example.com"},
+		Scheme:       "https",
+	}})
+
+	rec := httptest.NewRecorder()
+	l.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tunnels", nil))
+	var body struct {
+		Tunnels []TunnelInfo `json:"tunnels"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	want := []TunnelInfo{
+		{Name: "api", PublicURL: "https://brave-fox.example.com", Domain: "brave-fox.example.com", Scheme: "https", LocalPort: "8000"},
+		{Name: "web", PublicURL: "https://misty-river.example.com", Domain: "misty-river.example.com", Scheme: "https", LocalPort: "3000"},
+	}
+	if len(body.Tunnels) != len(want) {
+		t.Fatalf("got %d tunnels, want %d", len(body.Tunnels), len(want))
+	}
+	for i := range want {
+		if body.Tunnels[i] != want[i] {
+			t.Errorf("tunnel %d = %+v, want %+v", i, body.Tunnels[i], want[i])
+		}
+	}
+
+	// Reconnecting drops the URLs until the server binds them again
+	l.handle(events.Event{Type: events.EventReconnecting})
+	if got := l.List(); len(got) != 0 {
+		t.Errorf("List() after reconnect = %v, want empty", got)
+	}
+
+	rec = httptest.NewRecorder()
+	l.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/tunnels", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST status = %d, want 405", rec.Code)
+	}
+}
+
+func TestTunnelListTrack(t *testing.T) {
+	bus := events.NewBus()
+	var l TunnelList
+	l.Track(bus)
+
+	bus.Publish(events.Event{Type: events.EventTunnelReady, Data: events.TunnelReadyData{
+		LocalPort: "3000", BoundDomains: []string{"localhost"}, Scheme: "http",
+	}})
+	bus.Close()
+
+	deadline := time.Now().Add(time.Second)
+	for len(l.List()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if got := l.List(); len(got) != 1 || got[0].PublicURL != "http://localhost" {
+		t.Errorf("List() = %+v", got)
+	}
+}
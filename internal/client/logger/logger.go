@@ -1,23 +1,33 @@
 package logger
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"os"
 	"sync"
+	"time"
 
 	"gopublic/internal/client/events"
 )
 
+// Log formats used outside TUI mode.
+const (
+	FormatText = "text" // Standard log lines
+	FormatJSON = "json" // One {"time","level","msg"} object per line, for log collectors
+)
+
 // Logger wraps standard logging with event bus integration for TUI mode.
 type Logger struct {
 	mu       sync.RWMutex
 	eventBus *events.Bus
 	tuiMode  bool
+	json     bool
 }
 
 var (
-	defaultLogger = &Logger{}
+	defaultLogger  = &Logger{}
 	originalWriter io.Writer
 )
 
@@ -47,6 +57,30 @@ func SetTUIMode(enabled bool) {
 	}
 }
 
+// SetFormat selects how messages are written outside TUI mode.
+func SetFormat(format string) error {
+	if format != FormatText && format != FormatJSON {
+		return fmt.Errorf("unknown log format %q: use %s or %s", format, FormatText, FormatJSON)
+	}
+	defaultLogger.mu.Lock()
+	defer defaultLogger.mu.Unlock()
+	defaultLogger.json = format == FormatJSON
+	return nil
+}
+
+// Print writes a progress line for the user outside TUI mode: as is in
+// text format, as an info message in JSON format.
+func Print(message string) {
+	defaultLogger.mu.RLock()
+	asJSON := defaultLogger.json
+	defaultLogger.mu.RUnlock()
+	if asJSON {
+		os.Stdout.Write(jsonLine("info", message))
+		return
+	}
+	fmt.Println(message)
+}
+
 // Info logs an informational message.
 func Info(format string, args ...interface{}) {
 	defaultLogger.log("info", format, args...)
@@ -65,15 +99,30 @@ func Error(format string, args ...interface{}) {
 func (l *Logger) log(level, format string, args ...interface{}) {
 	message := fmt.Sprintf(format, args...)
 
+	recent.add(Entry{Time: time.Now(), Level: level, Msg: message})
+
 	l.mu.RLock()
 	tuiMode := l.tuiMode
 	bus := l.eventBus
+	asJSON := l.json
 	l.mu.RUnlock()
 
 	if tuiMode && bus != nil {
 		bus.PublishLog(level, message)
+	} else if asJSON {
+		log.Writer().Write(jsonLine(level, message))
 	} else {
 		// Fallback to standard log
 		log.Print(message)
 	}
 }
+
+// jsonLine formats a message in FormatJSON.
+func jsonLine(level, message string) []byte {
+	line, _ := json.Marshal(struct {
+		Time  string `json:"time"`
+		Level string `json:"level"`
+		Msg   string `json:"msg"`
+	}{time.Now().UTC().Format(time.RFC3339Nano), level, message})
+	return append(line, '\n')
+}
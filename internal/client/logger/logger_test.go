@@ -0,0 +1,34 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"testing"
+)
+
+func TestSetFormat_JSON(t *testing.T) {
+	var buf bytes.Buffer
+	log.SetOutput(&buf)
+	t.Cleanup(func() {
+		log.SetOutput(os.Stderr)
+		SetFormat(FormatText)
+	})
+
+	if err := SetFormat("yaml"); err == nil {
+		t.Error("SetFormat(yaml) error = nil, want error")
+	}
+	if err := SetFormat(FormatJSON); err != nil {
+		t.Fatalf("SetFormat(json) error = %v", err)
+	}
+	Warn("Tunnel %s reconnecting", "web")
+
+	var entry map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("output %q is no JSON: %v", buf.String(), err)
+	}
+	if entry["level"] != "warn" || entry["msg"] != "Tunnel web reconnecting" || entry["time"] == "" {
+		t.Errorf("entry = %v", entry)
+	}
+}
@@ -0,0 +1,52 @@
+package logger
+
+import (
+	"sync"
+	"time"
+)
+
+// recentSize is how many of the latest messages Recent keeps.
+const recentSize = 500
+
+// Entry is a logged message kept in memory for Recent.
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Level string    `json:"level"`
+	Msg   string    `json:"msg"`
+}
+
+// ring keeps the latest entries, overwriting the oldest once full.
+type ring struct {
+	mu      sync.Mutex
+	entries []Entry
+	next    int // Where the next entry goes once full
+}
+
+var recent = &ring{}
+
+func (r *ring) add(e Entry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if len(r.entries) < recentSize {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.next] = e
+	r.next = (r.next + 1) % recentSize
+}
+
+func (r *ring) list() []Entry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	list := make([]Entry, 0, len(r.entries))
+	list = append(list, r.entries[r.next:]...)
+	return append(list, r.entries[:r.next]...)
+}
+
+// Recent returns the latest messages logged with Info, Warn and Error,
+// oldest first, whichever mode they were written in. It lets the TUI and
+// the inspector show warnings that would otherwise only reach stderr in
+// headless mode.
+func Recent() []Entry {
+	return recent.list()
+}
@@ -0,0 +1,32 @@
+package logger
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing(t *testing.T) {
+	r := &ring{}
+	if list := r.list(); len(list) != 0 {
+		t.Fatalf("list() of an empty ring = %v", list)
+	}
+	for i := 0; i < recentSize+3; i++ {
+		r.add(Entry{Level: "info", Msg: fmt.Sprint(i)})
+	}
+	list := r.list()
+	if len(list) != recentSize {
+		t.Fatalf("len(list()) = %d, want %d", len(list), recentSize)
+	}
+	if list[0].Msg != "3" || list[recentSize-1].Msg != fmt.Sprint(recentSize+2) {
+		t.Errorf("list() runs from %s to %s, want 3 to %d", list[0].Msg, list[recentSize-1].Msg, recentSize+2)
+	}
+}
+
+func TestRecent(t *testing.T) {
+	Warn("Local service on port %s is not responding", "3000")
+	list := Recent()
+	last := list[len(list)-1]
+	if last.Level != "warn" || last.Msg != "Local service on port 3000 is not responding" || last.Time.IsZero() {
+		t.Errorf("last entry = %+v", last)
+	}
+}
@@ -14,6 +14,7 @@ type Stats struct {
 	openConns     int64
 	totalRequests int64
 	totalBytes    int64
+	skipped       int64 // Bodies streamed without capture, see RecordCaptureSkipped
 
 	// Ring buffer for request times (for percentile calculations)
 	requestTimes []time.Duration
@@ -31,6 +32,7 @@ type Snapshot struct {
 	OpenConnections  int64
 	TotalRequests    int64
 	TotalBytes       int64
+	CaptureSkipped   int64 // Bodies not captured for the inspector (memory cap)
 
 	// Request timing metrics
 	RT1 time.Duration // Last request time
@@ -97,6 +99,14 @@ func (s *Stats) RecordRequest(duration time.Duration, bytes int64) {
 	s.requestTimes = append(s.requestTimes, duration)
 }
 
+// RecordCaptureSkipped counts a body streamed without capture because the
+// in-flight capture budget was used up.
+func (s *Stats) RecordCaptureSkipped() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.skipped++
+}
+
 // SetServerLatency sets the measured server latency.
 func (s *Stats) SetServerLatency(latency time.Duration) {
 	s.mu.Lock()
@@ -114,6 +124,7 @@ func (s *Stats) Snapshot() Snapshot {
 		OpenConnections:  s.openConns,
 		TotalRequests:    s.totalRequests,
 		TotalBytes:       s.totalBytes,
+		CaptureSkipped:   s.skipped,
 		ServerLatency:    s.serverLatency,
 		Uptime:           time.Since(s.startTime),
 	}
@@ -166,6 +177,7 @@ func (s *Stats) Reset() {
 	s.openConns = 0
 	s.totalRequests = 0
 	s.totalBytes = 0
+	s.skipped = 0
 	s.requestTimes = s.requestTimes[:0]
 	s.serverLatency = 0
 	s.startTime = time.Now()
@@ -243,3 +243,17 @@ func TestEmptySnapshot(t *testing.T) {
 		t.Errorf("expected P90 0, got %v", snap.P90)
 	}
 }
+
+func TestRecordCaptureSkipped(t *testing.T) {
+	s := New()
+	s.RecordCaptureSkipped()
+	s.RecordCaptureSkipped()
+
+	if got := s.Snapshot().CaptureSkipped; got != 2 {
+		t.Errorf("expected 2 skipped captures, got %d", got)
+	}
+	s.Reset()
+	if got := s.Snapshot().CaptureSkipped; got != 0 {
+		t.Errorf("expected 0 skipped captures after reset, got %d", got)
+	}
+}
@@ -0,0 +1,175 @@
+// Package telemetry sends opt-in, anonymous usage reports: one per
+// "gopublic start" run, when the tunnel stops. Report is the complete
+// payload; it carries no token, domain, address or request data.
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/events"
+	"gopublic/internal/version"
+)
+
+// DefaultEndpoint receives reports unless the config overrides it. It is
+// set via ldflags, e.g. -X gopublic/internal/client/telemetry.DefaultEndpoint=https://...
+var DefaultEndpoint = ""
+
+// sendTimeout bounds how long a report may delay the exit.
+const sendTimeout = 3 * time.Second
+
+// Report is a usage report.
+type Report struct {
+	ID       string         `json:"id"` // Random per install, see NewID
+	Version  string         `json:"version"`
+	OS       string         `json:"os"`
+	Arch     string         `json:"arch"`
+	Features []string       `json:"features"`         // e.g. "tui", "flag:force"; flag names only, never values
+	Errors   map[string]int `json:"errors,omitempty"` // Error counts by category, e.g. "network_unreachable"
+}
+
+// NewID returns a random install ID.
+func NewID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Endpoint returns the endpoint reports are sent to, or "" if there is none.
+func Endpoint(cfg *config.Config) string {
+	if cfg.TelemetryEndpoint != "" {
+		return cfg.TelemetryEndpoint
+	}
+	return DefaultEndpoint
+}
+
+// Collector gathers the features used and errors seen during a run.
+type Collector struct {
+	mu       sync.Mutex
+	features map[string]bool
+	errors   map[string]int
+}
+
+// NewCollector creates an empty collector.
+func NewCollector() *Collector {
+	return &Collector{features: make(map[string]bool), errors: make(map[string]int)}
+}
+
+// Feature records that a feature was used.
+func (c *Collector) Feature(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.features[name] = true
+}
+
+// Error counts an error of category.
+func (c *Collector) Error(category string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.errors[category]++
+}
+
+// Track counts the connection and tunnel errors published on bus until it
+// is closed. categorize maps an error to its category.
+func (c *Collector) Track(bus *events.Bus, categorize func(error) string) {
+	ch := bus.Subscribe()
+	go func() {
+		for event := range ch {
+			switch data := event.Data.(type) {
+			case events.ReconnectingData:
+				if data.Error != nil {
+					c.Error(categorize(data.Error))
+				}
+			case events.TunnelFailedData:
+				if data.Error != nil {
+					c.Error(categorize(data.Error))
+				}
+			}
+		}
+	}()
+}
+
+// Report builds the report of the run so far.
+func (c *Collector) Report(id string) Report {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	r := Report{
+		ID:       id,
+		Version:  version.Version,
+		OS:       runtime.GOOS,
+		Arch:     runtime.GOARCH,
+		Features: make([]string, 0, len(c.features)),
+	}
+	for name := range c.features {
+		r.Features = append(r.Features, name)
+	}
+	sort.Strings(r.Features)
+	if len(c.errors) > 0 {
+		r.Errors = make(map[string]int, len(c.errors))
+		for category, n := range c.errors {
+			r.Errors[category] = n
+		}
+	}
+	return r
+}
+
+// Send posts r to endpoint and keeps a copy of what was sent for
+// LastSent.
+func Send(ctx context.Context, endpoint string, r Report) error {
+	payload, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned %s", resp.Status)
+	}
+
+	path, err := config.TelemetryPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return os.WriteFile(path, payload, 0600)
+}
+
+// LastSent returns the payload of the last report sent, or nil if none
+// was sent yet.
+func LastSent() ([]byte, error) {
+	path, err := config.TelemetryPath()
+	if err != nil {
+		return nil, err
+	}
+	payload, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return payload, err
+}
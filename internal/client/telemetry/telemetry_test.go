@@ -0,0 +1,104 @@
+package telemetry
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/events"
+)
+
+func TestCollector_Report(t *testing.T) {
+	c := NewCollector()
+	c.Feature("tui")
+	c.Feature("flag:force")
+	c.Feature("tui")
+	c.Error("auth_failed")
+
+	r := c.Report("abc")
+	if r.ID != "abc" || r.OS == "" || r.Version == "" {
+		t.Errorf("Report() = %+v", r)
+	}
+	if want := []string{"flag:force", "tui"}; !reflect.DeepEqual(r.Features, want) {
+		t.Errorf("Features = %v, want %v", r.Features, want)
+	}
+	if r.Errors["auth_failed"] != 1 {
+		t.Errorf("Errors = %v", r.Errors)
+	}
+}
+
+func TestCollector_Track(t *testing.T) {
+	bus := events.NewBus()
+	c := NewCollector()
+	c.Track(bus, func(err error) string { return err.Error() })
+
+	bus.Publish(events.Event{Type: events.EventReconnecting, Data: events.ReconnectingData{Attempt: 2, Error: errors.New("network_unreachable")}})
+	bus.Publish(events.Event{Type: events.EventReconnecting, Data: events.ReconnectingData{Attempt: 3, Error: errors.New("network_unreachable")}})
+	bus.Publish(events.Event{Type: events.EventTunnelFailed, Data: events.TunnelFailedData{Name: "api", Error: errors.New("domain_taken")}})
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		r := c.Report("abc")
+		if r.Errors["network_unreachable"] == 2 && r.Errors["domain_taken"] == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Errors = %v", r.Errors)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	bus.Close()
+}
+
+func TestSend(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("APPDATA", t.TempDir())
+
+	var received Report
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+	}))
+	defer srv.Close()
+
+	if last, err := LastSent(); err != nil || last != nil {
+		t.Fatalf("LastSent() before sending = %s, %v", last, err)
+	}
+
+	report := Report{ID: "abc", Version: "1.0.0", OS: "linux", Arch: "amd64", Features: []string{"tui"}}
+	if err := Send(context.Background(), srv.URL, report); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+	if !reflect.DeepEqual(received, report) {
+		t.Errorf("server received %+v, want %+v", received, report)
+	}
+
+	last, err := LastSent()
+	if err != nil {
+		t.Fatalf("LastSent() error = %v", err)
+	}
+	var saved Report
+	if err := json.Unmarshal(last, &saved); err != nil || !reflect.DeepEqual(saved, report) {
+		t.Errorf("LastSent() = %s, %v", last, err)
+	}
+}
+
+func TestEndpoint(t *testing.T) {
+	saved := DefaultEndpoint
+	DefaultEndpoint = "https://telemetry.example.com"
+	defer func() { DefaultEndpoint = saved }()
+
+	if got := Endpoint(&config.Config{}); got != DefaultEndpoint {
+		t.Errorf("Endpoint() = %q, want default", got)
+	}
+	if got := Endpoint(&config.Config{TelemetryEndpoint: "https://own.example.com"}); got != "https://own.example.com" {
+		t.Errorf("Endpoint() = %q, want config override", got)
+	}
+}
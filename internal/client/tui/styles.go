@@ -1,6 +1,10 @@
 package tui
 
-import "github.com/charmbracelet/lipgloss"
+import (
+	"gopublic/internal/client/i18n"
+
+	"github.com/charmbracelet/lipgloss"
+)
 
 // Color palette - inspired by ngrok's terminal UI
 var (
@@ -117,13 +121,13 @@ var (
 func StatusText(status string) string {
 	switch status {
 	case "online":
-		return statusOnlineStyle.Render("online")
+		return statusOnlineStyle.Render(i18n.T("status.online"))
 	case "connecting":
-		return statusConnectingStyle.Render("connecting")
+		return statusConnectingStyle.Render(i18n.T("status.connecting"))
 	case "reconnecting":
-		return statusConnectingStyle.Render("reconnecting")
+		return statusConnectingStyle.Render(i18n.T("status.reconnecting"))
 	case "offline":
-		return statusOfflineStyle.Render("offline")
+		return statusOfflineStyle.Render(i18n.T("status.offline"))
 	default:
 		return valueStyle.Render(status)
 	}
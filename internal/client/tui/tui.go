@@ -7,8 +7,12 @@ import (
 	"time"
 
 	"gopublic/internal/client/events"
+	"gopublic/internal/client/i18n"
+	"gopublic/internal/client/inspector"
+	"gopublic/internal/client/logger"
 	"gopublic/internal/client/stats"
 	"gopublic/internal/client/updater"
+	"gopublic/pkg/protocol"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
@@ -23,6 +27,7 @@ type TunnelInfo struct {
 	LocalPort    string
 	BoundDomains []string
 	Scheme       string
+	Error        string // Why the subdomain was not bound; cleared once it is
 }
 
 // RequestEntry represents a recent request for display
@@ -44,7 +49,8 @@ type LogEntry struct {
 // Model is the main Bubble Tea model
 type Model struct {
 	// Connection state
-	status string // "connecting", "online", "reconnecting", "offline"
+	status        string // "connecting", "online", "reconnecting", "offline"
+	connectedOnce bool   // Whether the session has been online since start
 
 	// Detailed connection status
 	connectionStage   string // Current stage of connection
@@ -54,9 +60,10 @@ type Model struct {
 	tunnels []TunnelInfo
 
 	// Dependencies
-	stats    *stats.Stats
-	eventBus *events.Bus
-	eventSub <-chan events.Event
+	stats     *stats.Stats
+	eventBus  *events.Bus
+	eventSub  <-chan events.Event
+	inspector *inspector.Server // Reports dropped captures; optional
 
 	// Display state
 	width     int
@@ -66,6 +73,7 @@ type Model struct {
 	// Server info
 	serverAddr    string
 	serverLatency time.Duration
+	labels        map[string]string
 
 	// Recent requests for display
 	requests    []RequestEntry
@@ -78,19 +86,28 @@ type Model struct {
 	logs    []LogEntry
 	maxLogs int
 
+	// showLogs switches to the logs tab, toggled with "l"
+	showLogs bool
+
 	// Update state
-	updateInfo     *updater.UpdateInfo
-	updateChecked  bool
-	updateStatus   string // "", "checking", "downloading", "done", "error"
-	updateMessage  string
+	updateInfo    *updater.UpdateInfo
+	updateChecked bool
+	updateStatus  string // "", "checking", "downloading", "done", "error"
+	updateMessage string
 
-	// Server bandwidth stats (initial values from server)
+	// Server bandwidth stats (from the handshake, then from each stats push)
 	serverBandwidthToday int64
 	serverBandwidthTotal int64
 	serverBandwidthLimit int64
+	quotaExceeded        bool // The server reported the daily limit used up
 
-	// Session bandwidth (accumulated during this session)
+	// Session bandwidth (accumulated since the server's last stats)
 	sessionBandwidth int64
+
+	// Live usage pushed by the server (nil until the first push)
+	domainStats   map[string]events.DomainStatsData
+	streamsActive int
+	streamsLimit  int
 }
 
 // NewModel creates a new TUI model
@@ -114,6 +131,11 @@ func NewModel(eventBus *events.Bus, statsTracker *stats.Stats) Model {
 	}
 }
 
+// SetInspector shows the captures insp dropped next to the stats.
+func (m *Model) SetInspector(insp *inspector.Server) {
+	m.inspector = insp
+}
+
 // Messages
 type tickMsg time.Time
 type eventMsg events.Event
@@ -180,11 +202,13 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		switch msg.String() {
 		case "q", "ctrl+c":
 			return m, tea.Quit
+		case "l":
+			m.showLogs = !m.showLogs
 		case "ctrl+u", "u":
 			// Trigger update if available
 			if m.updateInfo != nil && m.updateInfo.Available && m.updateStatus == "" {
 				m.updateStatus = "downloading"
-				m.updateMessage = "Downloading update..."
+				m.updateMessage = i18n.T("tui.update_downloading")
 				return m, performUpdateCmd(m.updateInfo)
 			}
 		}
@@ -233,6 +257,7 @@ func (m Model) handleEvent(event events.Event) Model {
 
 	case events.EventConnected:
 		m.status = "online"
+		m.connectedOnce = true
 		m.connectionStage = ""
 		m.connectionMessage = ""
 		// Clear connection-related logs (e.g., "Connecting to...")
@@ -243,6 +268,25 @@ func (m Model) handleEvent(event events.Event) Model {
 			m.serverBandwidthToday = data.BandwidthToday
 			m.serverBandwidthTotal = data.BandwidthTotal
 			m.serverBandwidthLimit = data.BandwidthLimit
+			m.quotaExceeded = false
+			m.sessionBandwidth = 0
+			m.labels = data.Labels
+		}
+		m.domainStats = nil
+
+	case events.EventServerStats:
+		if data, ok := event.Data.(events.ServerStatsData); ok {
+			m.serverBandwidthToday = data.BandwidthToday
+			m.serverBandwidthTotal = data.BandwidthTotal
+			m.serverBandwidthLimit = data.BandwidthLimit
+			m.quotaExceeded = data.QuotaExceeded
+			m.sessionBandwidth = 0
+			m.domainStats = make(map[string]events.DomainStatsData, len(data.Domains))
+			for _, d := range data.Domains {
+				m.domainStats[d.Domain] = d
+			}
+			m.streamsActive = data.StreamsActive
+			m.streamsLimit = data.StreamsLimit
 		}
 
 	case events.EventDisconnected:
@@ -252,6 +296,14 @@ func (m Model) handleEvent(event events.Event) Model {
 
 	case events.EventReconnecting:
 		m.status = "reconnecting"
+		if data, ok := event.Data.(events.ReconnectingData); ok {
+			if !m.connectedOnce {
+				// Started while the server is unreachable
+				m.status = "offline"
+			}
+			m.connectionStage = "reconnecting"
+			m.connectionMessage = i18n.T("tui.retrying", data.Delay.Round(time.Second), data.Attempt)
+		}
 
 	case events.EventConnectionStatus:
 		if data, ok := event.Data.(events.ConnectionStatusData); ok {
@@ -266,6 +318,8 @@ func (m Model) handleEvent(event events.Event) Model {
 			for i, t := range m.tunnels {
 				if t.LocalPort == data.LocalPort {
 					m.tunnels[i].BoundDomains = append(m.tunnels[i].BoundDomains, data.BoundDomains...)
+					m.tunnels[i].Scheme = data.Scheme
+					m.tunnels[i].Error = ""
 					found = true
 					break
 				}
@@ -280,6 +334,25 @@ func (m Model) handleEvent(event events.Event) Model {
 			}
 		}
 
+	case events.EventTunnelFailed:
+		if data, ok := event.Data.(events.TunnelFailedData); ok && data.Error != nil {
+			found := false
+			for i, t := range m.tunnels {
+				if t.LocalPort == data.LocalPort {
+					m.tunnels[i].Error = data.Error.Error()
+					found = true
+					break
+				}
+			}
+			if !found {
+				m.tunnels = append(m.tunnels, TunnelInfo{
+					Name:      data.Name,
+					LocalPort: data.LocalPort,
+					Error:     data.Error.Error(),
+				})
+			}
+		}
+
 	case events.EventRequestComplete:
 		if data, ok := event.Data.(events.RequestData); ok {
 			entry := RequestEntry{
@@ -338,6 +411,11 @@ func (m Model) View() string {
 	b.WriteString(m.renderHeader())
 	b.WriteString("\n\n")
 
+	if m.showLogs {
+		b.WriteString(m.renderLogTab())
+		return b.String()
+	}
+
 	// Status section
 	b.WriteString(m.renderStatus())
 	b.WriteString("\n")
@@ -370,10 +448,12 @@ func (m Model) renderHeader() string {
 
 	// Build hint based on update status
 	var hint string
-	if m.updateInfo != nil && m.updateInfo.Available && m.updateStatus == "" {
-		hint = hintStyle.Render("(Ctrl+C quit, ") + updateAvailableStyle.Render("U update") + hintStyle.Render(")")
+	if m.showLogs {
+		hint = hintStyle.Render(i18n.T("tui.hint_logs"))
+	} else if m.updateInfo != nil && m.updateInfo.Available && m.updateStatus == "" {
+		hint = hintStyle.Render(i18n.T("tui.hint_quit_short")) + updateAvailableStyle.Render(i18n.T("tui.hint_update")) + hintStyle.Render(")")
 	} else {
-		hint = hintStyle.Render("(Ctrl+C to quit)")
+		hint = hintStyle.Render(i18n.T("tui.hint_quit"))
 	}
 
 	// Calculate spacing
@@ -400,14 +480,14 @@ func (m Model) renderStatus() string {
 	if m.connectionMessage != "" && m.status != "online" {
 		statusText = statusText + " " + connectionDetailStyle.Render("("+m.connectionMessage+")")
 	}
-	lines = append(lines, m.renderField("Session Status", statusText))
+	lines = append(lines, m.renderField(i18n.T("tui.session_status"), statusText))
 
 	// Version with update info
 	versionStr := Version
 	if m.updateInfo != nil && m.updateInfo.Available {
-		versionStr = Version + " " + updateAvailableStyle.Render("→ "+m.updateInfo.LatestVersion+" available")
+		versionStr = Version + " " + updateAvailableStyle.Render(i18n.T("tui.update_available", m.updateInfo.LatestVersion))
 	}
-	lines = append(lines, m.renderField("Version", versionStr))
+	lines = append(lines, m.renderField(i18n.T("tui.version"), versionStr))
 
 	// Update status (if downloading or completed)
 	if m.updateStatus != "" {
@@ -420,7 +500,7 @@ func (m Model) renderStatus() string {
 		case "error":
 			updateStatusText = updateErrorStyle.Render(m.updateMessage)
 		}
-		lines = append(lines, m.renderField("Update", updateStatusText))
+		lines = append(lines, m.renderField(i18n.T("tui.update"), updateStatusText))
 	}
 
 	// Latency
@@ -428,10 +508,15 @@ func (m Model) renderStatus() string {
 	if m.serverLatency > 0 {
 		latencyStr = fmt.Sprintf("%dms", m.serverLatency.Milliseconds())
 	}
-	lines = append(lines, m.renderField("Latency", latencyStr))
+	lines = append(lines, m.renderField(i18n.T("tui.latency"), latencyStr))
+
+	// Labels (only if set)
+	if len(m.labels) > 0 {
+		lines = append(lines, m.renderField(i18n.T("tui.labels"), protocol.FormatLabels(m.labels)))
+	}
 
 	// Web Interface
-	lines = append(lines, m.renderField("Web Interface", urlStyle.Render("http://127.0.0.1:4040")))
+	lines = append(lines, m.renderField(i18n.T("tui.web_interface"), urlStyle.Render("http://127.0.0.1:4040")))
 
 	return strings.Join(lines, "\n")
 }
@@ -444,17 +529,31 @@ func (m Model) renderForwarding() string {
 	var lines []string
 	lines = append(lines, "") // Empty line before
 
-	for i, t := range m.tunnels {
-		for j, domain := range t.BoundDomains {
+	for _, t := range m.tunnels {
+		for _, domain := range t.BoundDomains {
 			label := ""
-			if i == 0 && j == 0 {
-				label = "Forwarding"
+			if len(lines) == 1 {
+				label = i18n.T("tui.forwarding")
 			}
 
 			url := fmt.Sprintf("%s://%s", t.Scheme, domain)
 			local := fmt.Sprintf("http://localhost:%s", t.LocalPort)
 
 			value := urlStyle.Render(url) + arrowStyle.Render(" -> ") + valueStyle.Render(local)
+			if d, ok := m.domainStats[domain]; ok {
+				value += durationStyle.Render(" " + i18n.T("tui.domain_traffic", d.Requests, formatBytesShort(d.Bytes)))
+				if d.Throttled > 0 {
+					value += statusErrorStyle.Render(" " + i18n.T("tui.domain_throttled", d.Throttled, d.RateLimit))
+				}
+			}
+			lines = append(lines, labelStyle.Render(label)+value)
+		}
+		if t.Error != "" && len(t.BoundDomains) == 0 {
+			label := ""
+			if len(lines) == 1 {
+				label = i18n.T("tui.forwarding")
+			}
+			value := statusErrorStyle.Render(i18n.T("tui.tunnel_failed", t.Name, t.Error)) + arrowStyle.Render(" -> ") + valueStyle.Render(fmt.Sprintf("http://localhost:%s", t.LocalPort))
 			lines = append(lines, labelStyle.Render(label)+value)
 		}
 	}
@@ -474,7 +573,7 @@ func (m Model) renderStats() string {
 
 	// Header row
 	headers := []string{"ttl", "opn", "rt1", "rt5", "p50", "p90"}
-	headerRow := labelStyle.Render("Connections")
+	headerRow := labelStyle.Render(i18n.T("tui.connections"))
 	for _, h := range headers {
 		headerRow += statsHeaderStyle.Render(h)
 	}
@@ -489,14 +588,20 @@ func (m Model) renderStats() string {
 	valueRow += statsValueStyle.Render(formatDuration(snap.P50))
 	valueRow += statsValueStyle.Render(formatDuration(snap.P90))
 	lines = append(lines, valueRow)
+	if snap.CaptureSkipped > 0 {
+		lines = append(lines, labelStyle.Render("")+statusErrorStyle.Render(i18n.T("tui.capture_skipped", snap.CaptureSkipped)))
+	}
+	if dropped := m.inspector.DroppedCaptures(); dropped > 0 {
+		lines = append(lines, labelStyle.Render("")+statusErrorStyle.Render(i18n.T("tui.captures_dropped", dropped)))
+	}
 
 	// Bandwidth stats from server (if available)
 	if m.serverBandwidthLimit > 0 {
 		lines = append(lines, "")
-		bandwidthLine := labelStyle.Render("Bandwidth") +
-			statsHeaderStyle.Render("today") +
-			statsHeaderStyle.Render("total") +
-			statsHeaderStyle.Render("limit")
+		bandwidthLine := labelStyle.Render(i18n.T("tui.bandwidth")) +
+			statsHeaderStyle.Render(i18n.T("tui.bandwidth_today")) +
+			statsHeaderStyle.Render(i18n.T("tui.bandwidth_total")) +
+			statsHeaderStyle.Render(i18n.T("tui.bandwidth_limit"))
 		lines = append(lines, bandwidthLine)
 
 		// Calculate current bandwidth: server initial + session accumulated
@@ -508,6 +613,18 @@ func (m Model) renderStats() string {
 			statsValueStyle.Render(formatBytesShort(currentTotal)) +
 			statsValueStyle.Render(formatBytesShort(m.serverBandwidthLimit))
 		lines = append(lines, bandwidthValueRow)
+		if m.quotaExceeded {
+			lines = append(lines, labelStyle.Render("")+statusErrorStyle.Render(i18n.T("tui.quota_exceeded")))
+		}
+	}
+
+	// Concurrent requests on the server (once it has pushed them)
+	if m.domainStats != nil {
+		streams := fmt.Sprintf("%d", m.streamsActive)
+		if m.streamsLimit > 0 {
+			streams = fmt.Sprintf("%d / %d", m.streamsActive, m.streamsLimit)
+		}
+		lines = append(lines, "", m.renderField(i18n.T("tui.streams"), streams))
 	}
 
 	return strings.Join(lines, "\n")
@@ -516,7 +633,7 @@ func (m Model) renderStats() string {
 func (m Model) renderRequests() string {
 	var lines []string
 	lines = append(lines, "") // Empty line before
-	lines = append(lines, labelStyle.Render("HTTP Requests"))
+	lines = append(lines, labelStyle.Render(i18n.T("tui.http_requests")))
 
 	for _, req := range m.requests {
 		method := MethodText(req.Method)
@@ -534,18 +651,10 @@ func (m Model) renderRequests() string {
 func (m Model) renderLogs() string {
 	var lines []string
 	lines = append(lines, "") // Empty line before
-	lines = append(lines, labelStyle.Render("Logs"))
+	lines = append(lines, labelStyle.Render(i18n.T("tui.logs")))
 
 	for _, log := range m.logs {
-		var levelStyle lipgloss.Style
-		switch log.Level {
-		case "error":
-			levelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9")) // Red
-		case "warn":
-			levelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("11")) // Yellow
-		default:
-			levelStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("8")) // Gray
-		}
+		levelStyle := logLevelStyle(log.Level)
 
 		// Wrap long messages based on terminal width
 		msg := log.Message
@@ -564,8 +673,52 @@ func (m Model) renderLogs() string {
 	return strings.Join(lines, "\n")
 }
 
+// renderLogTab renders the logs tab: the latest messages kept by the
+// logger, newest last, as many as fit the terminal.
+func (m Model) renderLogTab() string {
+	lines := []string{labelStyle.Render(i18n.T("tui.logs"))}
+	entries := logger.Recent()
+	if len(entries) == 0 {
+		lines = append(lines, hintStyle.Render(i18n.T("tui.logs_empty")))
+		return strings.Join(lines, "\n")
+	}
+
+	maxLen := 70
+	if m.width > 20 {
+		maxLen = m.width - 4
+	}
+	var body []string
+	for _, e := range entries {
+		levelStyle := logLevelStyle(e.Level)
+		for _, wl := range wrapText(e.Time.Format("15:04:05")+" "+e.Msg, maxLen) {
+			body = append(body, levelStyle.Render(wl))
+		}
+	}
+
+	// Header, blank line and title take the first lines
+	maxLines := 20
+	if m.height > 4 {
+		maxLines = m.height - 4
+	}
+	if len(body) > maxLines {
+		body = body[len(body)-maxLines:]
+	}
+	return strings.Join(append(lines, body...), "\n")
+}
+
 // Helper functions
 
+func logLevelStyle(level string) lipgloss.Style {
+	switch level {
+	case "error":
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("9")) // Red
+	case "warn":
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("11")) // Yellow
+	default:
+		return lipgloss.NewStyle().Foreground(lipgloss.Color("8")) // Gray
+	}
+}
+
 func formatDuration(d time.Duration) string {
 	if d == 0 {
 		return "0.00"
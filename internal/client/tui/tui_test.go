@@ -1,11 +1,13 @@
 package tui
 
 import (
+	"errors"
 	"strings"
 	"testing"
 	"time"
 
 	"gopublic/internal/client/events"
+	"gopublic/internal/client/logger"
 	"gopublic/internal/client/stats"
 
 	tea "github.com/charmbracelet/bubbletea"
@@ -69,6 +71,29 @@ func TestModel_Update_Quit(t *testing.T) {
 	}
 }
 
+func TestModel_Update_LogsTab(t *testing.T) {
+	logger.Warn("Local service on port %s is not responding", "3000")
+	model := NewModel(nil, nil)
+
+	newModel, _ := model.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	m := newModel.(Model)
+	if !m.showLogs {
+		t.Fatal("'l' should open the logs tab")
+	}
+	view := m.View()
+	if !strings.Contains(view, "Local service on port 3000 is not responding") {
+		t.Errorf("logs tab should show the logged warning, got:\n%s", view)
+	}
+	if strings.Contains(view, "Session Status") {
+		t.Error("logs tab should replace the status view")
+	}
+
+	newModel, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{'l'}})
+	if newModel.(Model).showLogs {
+		t.Error("'l' again should close the logs tab")
+	}
+}
+
 func TestModel_Update_WindowSize(t *testing.T) {
 	model := NewModel(nil, nil)
 
@@ -139,6 +164,28 @@ func TestModel_HandleEvent_Reconnecting(t *testing.T) {
 	}
 }
 
+func TestModel_HandleEvent_Reconnecting_OfflineStart(t *testing.T) {
+	model := NewModel(nil, nil)
+
+	retry := events.Event{
+		Type: events.EventReconnecting,
+		Data: events.ReconnectingData{Attempt: 3, Delay: 4 * time.Second, Error: errors.New("connection refused")},
+	}
+	model = model.handleEvent(retry)
+	if model.status != "offline" {
+		t.Errorf("expected status 'offline' before the first connection, got '%s'", model.status)
+	}
+	if view := model.View(); !strings.Contains(view, "retrying in 4s, attempt 3") {
+		t.Error("view should show the next retry")
+	}
+
+	model = model.handleEvent(events.Event{Type: events.EventConnected})
+	model = model.handleEvent(retry)
+	if model.status != "reconnecting" {
+		t.Errorf("expected status 'reconnecting' after a connection, got '%s'", model.status)
+	}
+}
+
 func TestModel_HandleEvent_TunnelReady(t *testing.T) {
 	model := NewModel(nil, nil)
 
@@ -193,6 +240,67 @@ func TestModel_HandleEvent_TunnelReady_AddDomain(t *testing.T) {
 	}
 }
 
+func TestModel_HandleEvent_TunnelFailed(t *testing.T) {
+	model := NewModel(nil, nil)
+
+	model = model.handleEvent(events.Event{
+		Type: events.EventTunnelFailed,
+		Data: events.TunnelFailedData{
+			Name:      "taken",
+			LocalPort: "8080",
+			Error:     errors.New("subdomain 'taken' was not bound by the server"),
+		},
+	})
+	if len(model.tunnels) != 1 || model.tunnels[0].Error == "" {
+		t.Fatalf("expected failed tunnel, got %+v", model.tunnels)
+	}
+	if view := model.View(); !strings.Contains(view, "taken: subdomain 'taken' was not bound") {
+		t.Error("view should contain the failure")
+	}
+
+	// A later bind clears the failure
+	model = model.handleEvent(events.Event{
+		Type: events.EventTunnelReady,
+		Data: events.TunnelReadyData{
+			Name:         "taken",
+			LocalPort:    "8080",
+			BoundDomains: []string{"taken.example.com"},
+			Scheme:       "https",
+		},
+	})
+	if len(model.tunnels) != 1 || model.tunnels[0].Error != "" || len(model.tunnels[0].BoundDomains) != 1 {
+		t.Errorf("expected bound tunnel, got %+v", model.tunnels)
+	}
+}
+
+func TestModel_HandleEvent_ServerStats(t *testing.T) {
+	model := NewModel(nil, nil)
+	model.tunnels = []TunnelInfo{{LocalPort: "3000", BoundDomains: []string{"test.example.com"}, Scheme: "https"}}
+	model.sessionBandwidth = 4096
+
+	model = model.handleEvent(events.Event{
+		Type: events.EventServerStats,
+		Data: events.ServerStatsData{
+			BandwidthToday: 10 * 1024,
+			BandwidthLimit: 1024 * 1024,
+			Domains:        []events.DomainStatsData{{Domain: "test.example.com", Requests: 7, Bytes: 2048}},
+			StreamsActive:  3,
+			StreamsLimit:   100,
+		},
+	})
+	if model.serverBandwidthToday != 10*1024 || model.sessionBandwidth != 0 {
+		t.Errorf("bandwidth = %d + %d, want the server's figure alone", model.serverBandwidthToday, model.sessionBandwidth)
+	}
+
+	view := model.View()
+	if !strings.Contains(view, "(7 req, 2K)") {
+		t.Error("view should contain the domain's traffic")
+	}
+	if !strings.Contains(view, "3 / 100") {
+		t.Error("view should contain the streams in use")
+	}
+}
+
 func TestModel_HandleEvent_RequestComplete(t *testing.T) {
 	model := NewModel(nil, nil)
 	model.maxRequests = 5
@@ -0,0 +1,218 @@
+package tui
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"gopublic/internal/client/i18n"
+	"gopublic/pkg/protocol"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// ErrWizardCanceled is returned by RunWizard when the user quits it.
+var ErrWizardCanceled = errors.New("setup canceled")
+
+// WizardResult is what the first-run wizard asked for.
+type WizardResult struct {
+	Server    string // host:port of the gopublic server
+	Token     string
+	Port      string // Local port or host:port of the service
+	Subdomain string // Domain of the account to bind, e.g. misty-river
+}
+
+// Wizard steps, one per WizardResult field, then the confirmation.
+const (
+	wizardServer = iota
+	wizardToken
+	wizardPort
+	wizardSubdomain
+	wizardConfirm
+)
+
+// WizardModel walks a first-time user through the server address, the
+// token from the dashboard and the tunnel to write to gopublic.yaml.
+type WizardModel struct {
+	step     int
+	values   [wizardConfirm]string
+	err      string
+	done     bool
+	canceled bool
+}
+
+// NewWizardModel creates a wizard prefilled with defaults.
+func NewWizardModel(defaults WizardResult) WizardModel {
+	return WizardModel{values: [wizardConfirm]string{defaults.Server, defaults.Token, defaults.Port, defaults.Subdomain}}
+}
+
+// Init initializes the model
+func (m WizardModel) Init() tea.Cmd {
+	return nil
+}
+
+// Update handles key presses: Enter checks the value and moves on, Esc
+// goes back a step.
+func (m WizardModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.Type {
+	case tea.KeyCtrlC:
+		m.canceled = true
+		return m, tea.Quit
+	case tea.KeyEsc:
+		if m.step == wizardServer {
+			m.canceled = true
+			return m, tea.Quit
+		}
+		m.step--
+		m.err = ""
+	case tea.KeyEnter:
+		if m.step == wizardConfirm {
+			m.done = true
+			return m, tea.Quit
+		}
+		m.values[m.step] = strings.TrimSpace(m.values[m.step])
+		if err := validateWizardStep(m.step, m.values[m.step]); err != nil {
+			m.err = err.Error()
+			return m, nil
+		}
+		m.err = ""
+		m.step++
+	case tea.KeyBackspace:
+		if m.step < wizardConfirm {
+			runes := []rune(m.values[m.step])
+			if len(runes) > 0 {
+				m.values[m.step] = string(runes[:len(runes)-1])
+			}
+		}
+	case tea.KeyRunes, tea.KeySpace:
+		if m.step < wizardConfirm {
+			m.values[m.step] += string(key.Runes)
+		}
+	}
+	return m, nil
+}
+
+// validateWizardStep checks the value entered at a step.
+func validateWizardStep(step int, value string) error {
+	switch step {
+	case wizardServer:
+		if host, port, err := net.SplitHostPort(value); err != nil || host == "" || port == "" {
+			return errors.New(i18n.T("wizard.invalid_server"))
+		}
+	case wizardToken:
+		if value == "" || strings.ContainsAny(value, " \t") {
+			return errors.New(i18n.T("wizard.invalid_token"))
+		}
+	case wizardPort:
+		port := value
+		if _, p, err := net.SplitHostPort(value); err == nil {
+			port = p
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return errors.New(i18n.T("wizard.invalid_port"))
+		}
+	case wizardSubdomain:
+		if err := protocol.ValidateSubdomain(value); err != nil {
+			return errors.New(i18n.T("wizard.invalid_subdomain", err))
+		}
+	}
+	return nil
+}
+
+// Result returns the values entered, and false if the wizard was not
+// completed.
+func (m WizardModel) Result() (WizardResult, bool) {
+	return WizardResult{
+		Server:    m.values[wizardServer],
+		Token:     m.values[wizardToken],
+		Port:      m.values[wizardPort],
+		Subdomain: m.values[wizardSubdomain],
+	}, m.done
+}
+
+// View renders the model
+func (m WizardModel) View() string {
+	var b strings.Builder
+	b.WriteString(titleStyle.Render("gopublic") + "  " + hintStyle.Render(i18n.T("wizard.hint")))
+	b.WriteString("\n\n")
+	b.WriteString(sectionStyle.Render(i18n.T("wizard.title", min(m.step+1, wizardConfirm), wizardConfirm)))
+	b.WriteString("\n\n")
+
+	if m.step == wizardConfirm {
+		result, _ := m.Result()
+		b.WriteString(m.renderField(i18n.T("wizard.server"), result.Server) + "\n")
+		b.WriteString(m.renderField(i18n.T("wizard.token"), maskToken(result.Token)) + "\n")
+		b.WriteString(m.renderField(i18n.T("wizard.port"), result.Port) + "\n")
+		b.WriteString(m.renderField(i18n.T("wizard.subdomain"), result.Subdomain) + "\n\n")
+		b.WriteString(valueStyle.Render(i18n.T("wizard.confirm")) + "\n")
+		return b.String()
+	}
+
+	var label, prompt, value string
+	switch m.step {
+	case wizardServer:
+		label, prompt = i18n.T("wizard.server"), i18n.T("wizard.server_prompt")
+	case wizardToken:
+		label, prompt = i18n.T("wizard.token"), i18n.T("wizard.token_prompt", dashboardURL(m.values[wizardServer]))
+	case wizardPort:
+		label, prompt = i18n.T("wizard.port"), i18n.T("wizard.port_prompt")
+	case wizardSubdomain:
+		label, prompt = i18n.T("wizard.subdomain"), i18n.T("wizard.subdomain_prompt")
+	}
+	value = m.values[m.step]
+	if m.step == wizardToken {
+		value = maskToken(value)
+	}
+	b.WriteString(connectionDetailStyle.Render(prompt) + "\n")
+	b.WriteString(labelStyle.Render(label) + valueStyle.Render(value) + urlStyle.Render("█") + "\n")
+	if m.err != "" {
+		b.WriteString("\n" + statusErrorStyle.Render(m.err) + "\n")
+	}
+	return b.String()
+}
+
+func (m WizardModel) renderField(label, value string) string {
+	return labelStyle.Render(label) + valueStyle.Render(value)
+}
+
+// dashboardURL guesses the dashboard of a server from its address: the
+// server's domain with the app. prefix.
+func dashboardURL(server string) string {
+	host, _, err := net.SplitHostPort(server)
+	if err != nil {
+		host = server
+	}
+	if host == "localhost" || host == "127.0.0.1" {
+		return "http://" + host
+	}
+	return "https://app." + host
+}
+
+// maskToken hides all but the start of a token.
+func maskToken(token string) string {
+	const shown = 8
+	if len(token) <= shown {
+		return strings.Repeat("•", len(token))
+	}
+	return token[:shown] + strings.Repeat("•", min(len(token)-shown, 16))
+}
+
+// RunWizard runs the first-run wizard and returns what the user entered,
+// or ErrWizardCanceled if they quit it.
+func RunWizard(defaults WizardResult) (*WizardResult, error) {
+	final, err := tea.NewProgram(NewWizardModel(defaults)).Run()
+	if err != nil {
+		return nil, fmt.Errorf("setup wizard: %w", err)
+	}
+	result, ok := final.(WizardModel).Result()
+	if !ok {
+		return nil, ErrWizardCanceled
+	}
+	return &result, nil
+}
@@ -0,0 +1,109 @@
+package tui
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// typeInto sends text to the wizard key by key, then Enter.
+func typeInto(m WizardModel, text string) WizardModel {
+	for _, r := range text {
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}})
+		m = next.(WizardModel)
+	}
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	return next.(WizardModel)
+}
+
+func TestWizardModel_Steps(t *testing.T) {
+	m := NewWizardModel(WizardResult{Server: "example.com:4443", Port: "3000"})
+
+	m = typeInto(m, "") // Keep the server
+	m = typeInto(m, "") // No token yet
+	if m.step != wizardToken || m.err == "" {
+		t.Fatalf("step = %d, err = %q, want to stay on the token with an error", m.step, m.err)
+	}
+	m = typeInto(m, "tok-123456789")
+	if strings.Contains(m.View(), "tok-123456789") {
+		t.Error("View() shows the token unmasked")
+	}
+	m = typeInto(m, "") // Keep port 3000
+	m = typeInto(m, "Bad_Name")
+	if m.step != wizardSubdomain || m.err == "" {
+		t.Fatalf("step = %d, err = %q, want to stay on the subdomain with an error", m.step, m.err)
+	}
+	for range len("Bad_Name") {
+		next, _ := m.Update(tea.KeyMsg{Type: tea.KeyBackspace})
+		m = next.(WizardModel)
+	}
+	m = typeInto(m, "misty-river")
+	if m.step != wizardConfirm {
+		t.Fatalf("step = %d, want confirm", m.step)
+	}
+	if _, done := m.Result(); done {
+		t.Error("Result() done before confirming")
+	}
+
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEnter})
+	if cmd == nil {
+		t.Error("expected quit command after confirming")
+	}
+	result, done := next.(WizardModel).Result()
+	want := WizardResult{Server: "example.com:4443", Token: "tok-123456789", Port: "3000", Subdomain: "misty-river"}
+	if !done || result != want {
+		t.Errorf("Result() = %+v, %v, want %+v, true", result, done, want)
+	}
+}
+
+func TestWizardModel_Cancel(t *testing.T) {
+	m := NewWizardModel(WizardResult{Server: "example.com:4443"})
+	m = typeInto(m, "")
+
+	// Esc goes back, then cancels on the first step
+	next, _ := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if m = next.(WizardModel); m.step != wizardServer {
+		t.Fatalf("step after Esc = %d, want server", m.step)
+	}
+	next, cmd := m.Update(tea.KeyMsg{Type: tea.KeyEsc})
+	if cmd == nil {
+		t.Error("expected quit command")
+	}
+	if _, done := next.(WizardModel).Result(); done {
+		t.Error("Result() done after canceling")
+	}
+}
+
+func TestValidateWizardStep(t *testing.T) {
+	tests := []struct {
+		step  int
+		value string
+		ok    bool
+	}{
+		{wizardServer, "example.com:4443", true},
+		{wizardServer, "example.com", false},
+		{wizardToken, "abc", true},
+		{wizardToken, "a b", false},
+		{wizardPort, "8080", true},
+		{wizardPort, "127.0.0.1:8080", true},
+		{wizardPort, "0", false},
+		{wizardPort, "http", false},
+		{wizardSubdomain, "misty-river", true},
+		{wizardSubdomain, "", false},
+	}
+	for _, tt := range tests {
+		if err := validateWizardStep(tt.step, tt.value); (err == nil) != tt.ok {
+			t.Errorf("validateWizardStep(%d, %q) = %v, want ok %v", tt.step, tt.value, err, tt.ok)
+		}
+	}
+}
+
+func TestDashboardURL(t *testing.T) {
+	if got := dashboardURL("example.com:4443"); got != "https://app.example.com" {
+		t.Errorf("dashboardURL() = %q", got)
+	}
+	if got := dashboardURL("localhost:4443"); got != "http://localhost" {
+		t.Errorf("dashboardURL() = %q", got)
+	}
+}
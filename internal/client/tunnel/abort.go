@@ -0,0 +1,55 @@
+package tunnel
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+
+	"github.com/hashicorp/yamux"
+)
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeResponse forwards resp to the ingress and reports how many bytes were
+// sent and whether the public caller aborted before the response finished.
+//
+// The ingress sends nothing after the request and closes its side of the
+// stream only once it is done with the response, so EOF on reader while the
+// response is still being written means the caller went away. The stream is
+// then closed so that a write blocked on flow control fails immediately.
+func writeResponse(remote net.Conn, reader *bufio.Reader, resp *http.Response) (sent int64, aborted bool, err error) {
+	callerGone := make(chan struct{})
+	go func() {
+		if _, err := reader.Peek(1); err == io.EOF {
+			close(callerGone)
+			remote.Close()
+		}
+	}()
+
+	w := &countingWriter{w: remote}
+	err = resp.Write(w)
+	if err == nil {
+		return w.n, false, nil
+	}
+
+	select {
+	case <-callerGone:
+		aborted = true
+	default:
+		// The ingress resets streams it gave up on
+		aborted = errors.Is(err, yamux.ErrConnectionReset)
+	}
+	return w.n, aborted, err
+}
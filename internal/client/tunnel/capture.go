@@ -0,0 +1,79 @@
+package tunnel
+
+import (
+	"bytes"
+	"io"
+	"sync/atomic"
+)
+
+const (
+	// DefaultCaptureBudget bounds the bodies buffered for the inspector at
+	// once, across all streams and tunnels.
+	DefaultCaptureBudget = 64 * 1024 * 1024
+	// captureChunk is how much of the budget a body reserves at a time.
+	captureChunk = 64 * 1024
+)
+
+// captureBudget is shared by all tunnels of the process.
+var captureBudget = &bodyBudget{limit: DefaultCaptureBudget}
+
+// bodyBudget limits the bytes of in-flight bodies held in memory. Bodies
+// that do not fit are streamed without capture instead of buffered.
+type bodyBudget struct {
+	limit int64
+	used  atomic.Int64
+}
+
+func (b *bodyBudget) reserve(n int64) bool {
+	for {
+		used := b.used.Load()
+		if used+n > b.limit {
+			return false
+		}
+		if b.used.CompareAndSwap(used, used+n) {
+			return true
+		}
+	}
+}
+
+func (b *bodyBudget) release(n int64) {
+	b.used.Add(-n)
+}
+
+// capture reads body into memory for the inspector while the budget allows.
+// The returned reader replays the body to the local service or caller; it
+// must be used instead of body. If the budget runs out, captured is nil and
+// the part already read is replayed before the rest, which is streamed.
+// release returns the reservation and must be called once the exchange is
+// done with the body.
+func (b *bodyBudget) capture(body io.ReadCloser) (captured []byte, rest io.ReadCloser, release func(), err error) {
+	var buf bytes.Buffer
+	var reserved int64
+	release = func() { b.release(reserved) }
+
+	for {
+		if !b.reserve(captureChunk) {
+			rest = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(buf.Bytes()), body), body}
+			return nil, rest, release, nil
+		}
+		reserved += captureChunk
+
+		if _, err := io.CopyN(&buf, body, captureChunk); err == io.EOF {
+			break
+		} else if err != nil {
+			body.Close()
+			release()
+			reserved = 0
+			return []byte{}, io.NopCloser(bytes.NewReader(nil)), release, err
+		}
+	}
+	body.Close()
+
+	// Keep only what the body takes
+	b.release(reserved - int64(buf.Len()))
+	reserved = int64(buf.Len())
+	return buf.Bytes(), io.NopCloser(bytes.NewReader(buf.Bytes())), release, nil
+}
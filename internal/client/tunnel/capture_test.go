@@ -0,0 +1,56 @@
+package tunnel
+
+import (
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestBodyBudget_Capture(t *testing.T) {
+	b := &bodyBudget{limit: 4 * captureChunk}
+	body := bytes.Repeat([]byte("a"), captureChunk+10)
+
+	captured, rest, release, err := b.capture(io.NopCloser(bytes.NewReader(body)))
+	if err != nil {
+		t.Fatalf("capture() error = %v", err)
+	}
+	if !bytes.Equal(captured, body) {
+		t.Errorf("captured %d bytes, want %d", len(captured), len(body))
+	}
+	if replay, _ := io.ReadAll(rest); !bytes.Equal(replay, body) {
+		t.Errorf("replayed %d bytes, want %d", len(replay), len(body))
+	}
+	if got := b.used.Load(); got != int64(len(body)) {
+		t.Errorf("used = %d while in flight, want %d", got, len(body))
+	}
+	release()
+	if got := b.used.Load(); got != 0 {
+		t.Errorf("used = %d after release, want 0", got)
+	}
+}
+
+func TestBodyBudget_CaptureOverBudget(t *testing.T) {
+	b := &bodyBudget{limit: 2 * captureChunk}
+	held, _, releaseHeld, _ := b.capture(io.NopCloser(bytes.NewReader(make([]byte, captureChunk/2))))
+	if held == nil {
+		t.Fatal("small body was not captured")
+	}
+
+	body := bytes.Repeat([]byte("b"), 3*captureChunk)
+	captured, rest, release, err := b.capture(io.NopCloser(bytes.NewReader(body)))
+	if err != nil {
+		t.Fatalf("capture() error = %v", err)
+	}
+	if captured != nil {
+		t.Errorf("captured %d bytes over budget, want nil", len(captured))
+	}
+	// The body must still reach the other side in full
+	if streamed, _ := io.ReadAll(rest); !bytes.Equal(streamed, body) {
+		t.Errorf("streamed %d bytes, want %d", len(streamed), len(body))
+	}
+	release()
+	releaseHeld()
+	if got := b.used.Load(); got != 0 {
+		t.Errorf("used = %d after release, want 0", got)
+	}
+}
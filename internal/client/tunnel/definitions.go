@@ -0,0 +1,18 @@
+package tunnel
+
+import (
+	"context"
+
+	"gopublic/pkg/protocol"
+)
+
+// FetchDefinitions returns the tunnels the user defined in the dashboard,
+// see protocol.AuthRequest.Definitions. Callers pick those whose selector
+// matches their labels.
+func FetchDefinitions(ctx context.Context, serverAddr string, tlsCfg *TLSConfig, token string) ([]protocol.TunnelDefinition, error) {
+	resp, err := exchange(ctx, serverAddr, tlsCfg, protocol.AuthRequest{Token: token, Definitions: true})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Definitions, nil
+}
@@ -1,6 +1,19 @@
 package tunnel
 
-import "errors"
+import (
+	"errors"
+	"time"
+
+	"gopublic/pkg/protocol"
+)
+
+// ErrRemoteDisconnect is returned when the server relayed a disconnect
+// command from the dashboard. Reconnect loops stop instead of retrying.
+var ErrRemoteDisconnect = errors.New("disconnected from the dashboard")
+
+// ErrNotConnected is returned by Reconnect while there is no session to
+// drop, e.g. between reconnect attempts.
+var ErrNotConnected = errors.New("not connected")
 
 // AlreadyConnectedError indicates the user already has an active session on the server.
 type AlreadyConnectedError struct {
@@ -16,3 +29,49 @@ func IsAlreadyConnectedError(err error) bool {
 	var acErr *AlreadyConnectedError
 	return errors.As(err, &acErr)
 }
+
+// ServerError is a handshake refused by the server.
+type ServerError struct {
+	Code       protocol.ErrorCode
+	Message    string
+	RetryAfter time.Duration // Minimum wait before reconnecting, if the server sent one
+}
+
+func (e *ServerError) Error() string {
+	return "server error: " + e.Message
+}
+
+// ServerErrorCode returns the code of the ServerError in err's chain, or
+// ErrorCodeNone if there is none.
+func ServerErrorCode(err error) protocol.ErrorCode {
+	var sErr *ServerError
+	if errors.As(err, &sErr) {
+		return sErr.Code
+	}
+	return protocol.ErrorCodeNone
+}
+
+// retryAfter returns the wait requested by the ServerError in err's chain,
+// or 0.
+func retryAfter(err error) time.Duration {
+	var sErr *ServerError
+	if errors.As(err, &sErr) {
+		return sErr.RetryAfter
+	}
+	return 0
+}
+
+// isPermanent reports whether err is a refusal that reconnecting cannot
+// fix without user action, e.g. a new token or an untrusted certificate.
+func isPermanent(err error) bool {
+	if IsUntrustedCert(err) {
+		return true
+	}
+	switch ServerErrorCode(err) {
+	case protocol.ErrorCodeInvalidToken, protocol.ErrorCodeTokenExpired, protocol.ErrorCodeInvalidLabels,
+		protocol.ErrorCodeQuotaExceeded, protocol.ErrorCodeInvalidBasicAuth, protocol.ErrorCodeInvalidAgent,
+		protocol.ErrorCodeTooManyAgents, protocol.ErrorCodeInvalidRateLimit:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,48 @@
+package tunnel
+
+import (
+	"net"
+	"net/http"
+
+	"gopublic/internal/client/logger"
+	"gopublic/internal/filter"
+)
+
+// runRequestFilters applies request filters and writes any short-circuit
+// response to remote. Returns true if the filters handled the exchange.
+func runRequestFilters(chain filter.Chain, req *http.Request, remote net.Conn) bool {
+	if len(chain) == 0 {
+		return false
+	}
+
+	resp, err := chain.Request(req)
+	if err != nil {
+		logger.Warn("Request %s %s rejected by filter: %v", req.Method, req.URL.Path, err)
+		writeStatus(remote, http.StatusBadGateway, "Request rejected by traffic filter")
+		return true
+	}
+	if resp == nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if err := resp.Write(remote); err != nil {
+		logger.Error("Failed to write filter response to remote: %v", err)
+	}
+	return true
+}
+
+// runResponseFilters applies response filters. If a filter fails, a 502 is
+// written to remote and false is returned.
+func runResponseFilters(chain filter.Chain, req *http.Request, resp *http.Response, remote net.Conn) bool {
+	if len(chain) == 0 {
+		return true
+	}
+
+	if err := chain.Response(resp); err != nil {
+		logger.Warn("Response for %s %s rejected by filter: %v", req.Method, req.URL.Path, err)
+		writeStatus(remote, http.StatusBadGateway, "Response rejected by traffic filter")
+		return false
+	}
+	return true
+}
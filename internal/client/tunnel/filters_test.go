@@ -0,0 +1,94 @@
+package tunnel
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+
+	"gopublic/internal/filter"
+)
+
+// stubFilter answers requests to /blocked itself and fails on 500s.
+type stubFilter struct{}
+
+func (stubFilter) Request(req *http.Request) (*http.Response, error) {
+	if req.URL.Path == "/blocked" {
+		return filter.NewResponse(req, http.StatusForbidden, "blocked"), nil
+	}
+	if req.URL.Path == "/broken" {
+		return nil, errors.New("filter failed")
+	}
+	return nil, nil
+}
+
+func (stubFilter) Response(resp *http.Response) error {
+	if resp.StatusCode == http.StatusInternalServerError {
+		return errors.New("server error")
+	}
+	return nil
+}
+
+// filterStatus runs f against a pipe and returns the status written to
+// the remote side, or 0 if nothing was written.
+func filterStatus(t *testing.T, f func(remote net.Conn) bool) (bool, int) {
+	t.Helper()
+	remote, caller := net.Pipe()
+	defer caller.Close()
+
+	done := make(chan bool, 1)
+	go func() {
+		handled := f(remote)
+		remote.Close()
+		done <- handled
+	}()
+	status := 0
+	if resp, err := http.ReadResponse(bufio.NewReader(caller), nil); err == nil {
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		status = resp.StatusCode
+	}
+	return <-done, status
+}
+
+func TestRunRequestFilters(t *testing.T) {
+	chain := filter.Chain{stubFilter{}}
+	tests := []struct {
+		path        string
+		wantHandled bool
+		wantStatus  int
+	}{
+		{"/", false, 0},
+		{"/blocked", true, http.StatusForbidden},
+		{"/broken", true, http.StatusBadGateway},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com"+tt.path, nil)
+		handled, status := filterStatus(t, func(remote net.Conn) bool {
+			return runRequestFilters(chain, req, remote)
+		})
+		if handled != tt.wantHandled || status != tt.wantStatus {
+			t.Errorf("%s: handled, status = %v, %d, want %v, %d", tt.path, handled, status, tt.wantHandled, tt.wantStatus)
+		}
+	}
+}
+
+func TestRunResponseFilters(t *testing.T) {
+	chain := filter.Chain{stubFilter{}}
+	req, _ := http.NewRequest(http.MethodGet, "http://example.com/", nil)
+
+	ok, status := filterStatus(t, func(remote net.Conn) bool {
+		return runResponseFilters(chain, req, &http.Response{StatusCode: http.StatusOK}, remote)
+	})
+	if !ok || status != 0 {
+		t.Errorf("200: ok, status = %v, %d, want true, 0", ok, status)
+	}
+	ok, status = filterStatus(t, func(remote net.Conn) bool {
+		return runResponseFilters(chain, req, &http.Response{StatusCode: http.StatusInternalServerError}, remote)
+	})
+	if ok || status != http.StatusBadGateway {
+		t.Errorf("500: ok, status = %v, %d, want false, 502", ok, status)
+	}
+}
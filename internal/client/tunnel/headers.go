@@ -0,0 +1,44 @@
+package tunnel
+
+import "net/http"
+
+// HeaderRules rewrite the headers of a message passing through the tunnel:
+// Remove deletes headers, then Add sets headers, replacing any values.
+type HeaderRules struct {
+	Add    map[string]string
+	Remove []string
+}
+
+// apply rewrites h.
+func (r HeaderRules) apply(h http.Header) {
+	for _, name := range r.Remove {
+		h.Del(name)
+	}
+	for name, value := range r.Add {
+		h.Set(name, value)
+	}
+}
+
+// HeaderRewrite holds the header rules of a tunnel.
+type HeaderRewrite struct {
+	Request  HeaderRules // Applied before the request reaches the app
+	Response HeaderRules // Applied before the response leaves the client
+}
+
+// SetHeaderRewrite rewrites the headers of requests to subdomain that no
+// mount serves, and of their responses.
+func (st *SharedTunnel) SetHeaderRewrite(subdomain string, rw *HeaderRewrite) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.Rewrites == nil {
+		st.Rewrites = make(map[string]*HeaderRewrite)
+	}
+	st.Rewrites[subdomain] = rw
+}
+
+// rewriteFor returns the header rules of a subdomain, or nil.
+func (st *SharedTunnel) rewriteFor(subdomain string) *HeaderRewrite {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.Rewrites[subdomain]
+}
@@ -0,0 +1,209 @@
+package tunnel
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/client/crash"
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/logger"
+	"gopublic/pkg/protocol"
+)
+
+// ErrSessionLost is returned when an established session ends without
+// being shut down, e.g. because the connection went half-open. Reconnect
+// loops start over from the initial delay.
+var ErrSessionLost = errors.New("session lost")
+
+// KeepaliveConfig controls how fast a dead connection to the server is
+// noticed. Both yamux pings and the application heartbeat (see
+// protocol.Heartbeat) run every Interval; a connection that does not
+// answer one within Timeout is closed and reconnected. Every handshake and
+// control message must be sent and answered within HandshakeTimeout.
+type KeepaliveConfig struct {
+	Interval         time.Duration
+	Timeout          time.Duration
+	HandshakeTimeout time.Duration // 0 = the default
+}
+
+// defaultHandshakeTimeout bounds handshake and control messages unless
+// KeepaliveConfig.HandshakeTimeout is set.
+const defaultHandshakeTimeout = 10 * time.Second
+
+// DefaultKeepaliveConfig returns defaults that detect a dead connection
+// within about 15 seconds.
+func DefaultKeepaliveConfig() *KeepaliveConfig {
+	return &KeepaliveConfig{
+		Interval:         5 * time.Second,
+		Timeout:          10 * time.Second,
+		HandshakeTimeout: defaultHandshakeTimeout,
+	}
+}
+
+// Validate checks that the parameters are usable.
+func (c *KeepaliveConfig) Validate() error {
+	switch {
+	case c.Interval <= 0:
+		return errors.New("keepalive interval must be positive")
+	case c.Timeout <= 0:
+		return errors.New("keepalive timeout must be positive")
+	case c.HandshakeTimeout < 0:
+		return errors.New("handshake timeout must not be negative")
+	}
+	return nil
+}
+
+// handshakeTimeout returns the deadline of each handshake and control
+// message.
+func (c *KeepaliveConfig) handshakeTimeout() time.Duration {
+	if c.HandshakeTimeout > 0 {
+		return c.HandshakeTimeout
+	}
+	return defaultHandshakeTimeout
+}
+
+// keepaliveOrDefault returns cfg, or the defaults if it is nil.
+func keepaliveOrDefault(cfg *KeepaliveConfig) *KeepaliveConfig {
+	if cfg == nil {
+		return DefaultKeepaliveConfig()
+	}
+	return cfg
+}
+
+// heartbeat sends a protocol.Heartbeat every Interval on a new stream of
+// session and waits up to Timeout for each echo, passing the stats the
+// server attaches to some of them to onStats and remote commands other
+// than protocol.CommandDisconnect to onCommand. It returns why the
+// heartbeat failed, ErrRemoteDisconnect when told to disconnect, or nil
+// once the session is closed.
+func heartbeat(session *yamux.Session, cfg *KeepaliveConfig, onStats func(*protocol.StatsPush), onCommand func(string)) error {
+	stream, err := session.Open()
+	if err != nil {
+		if session.IsClosed() {
+			return nil
+		}
+		return fmt.Errorf("open heartbeat stream: %w", err)
+	}
+	defer stream.Close()
+
+	encoder := json.NewEncoder(stream)
+	decoder := json.NewDecoder(stream)
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for seq := uint64(1); ; seq++ {
+		stream.SetDeadline(time.Now().Add(cfg.Timeout))
+		if err := encoder.Encode(protocol.Heartbeat{Seq: seq}); err != nil {
+			return heartbeatError(session, err)
+		}
+		var echo protocol.Heartbeat
+		if err := decoder.Decode(&echo); err != nil {
+			return heartbeatError(session, err)
+		}
+		if echo.Seq != seq {
+			return fmt.Errorf("heartbeat %d answered with %d", seq, echo.Seq)
+		}
+		if echo.Stats != nil && onStats != nil {
+			onStats(echo.Stats)
+		}
+		switch {
+		case echo.Command == protocol.CommandDisconnect:
+			return ErrRemoteDisconnect
+		case echo.Command != "" && onCommand != nil:
+			onCommand(echo.Command)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-session.CloseChan():
+			return nil
+		}
+	}
+}
+
+// heartbeatError describes a failed heartbeat, or returns nil if it failed
+// because the session was closed meanwhile.
+func heartbeatError(session *yamux.Session, err error) error {
+	if session.IsClosed() {
+		return nil
+	}
+	return fmt.Errorf("no heartbeat answer from the server: %w", err)
+}
+
+// watchSession runs the heartbeat of session if the server answers them,
+// closing the session when one fails or the server says to disconnect.
+// The returned function reports that failure, or ErrRemoteDisconnect,
+// once the session has ended, or nil.
+func watchSession(session *yamux.Session, cfg *KeepaliveConfig, serverEchoes bool, onStats func(*protocol.StatsPush), onCommand func(string), onFail func(error)) func() error {
+	failed := make(chan error, 1)
+	if serverEchoes {
+		crash.Go(func() {
+			if err := heartbeat(session, cfg, onStats, onCommand); err != nil {
+				failed <- err
+				if errors.Is(err, ErrRemoteDisconnect) {
+					logger.Warn("Disconnected from the dashboard")
+				} else {
+					onFail(err)
+				}
+				session.Close()
+			}
+		})
+	}
+	return func() error {
+		select {
+		case err := <-failed:
+			return err
+		default:
+			return nil
+		}
+	}
+}
+
+// statsHandler returns the stats callback of a session: it publishes each
+// push as EventServerStats and warns once each time the server reports the
+// daily bandwidth limit used up or starts throttling a domain.
+func statsHandler(publish func(events.EventType, interface{})) func(*protocol.StatsPush) {
+	exceeded := false
+	throttled := make(map[string]int64) // Domain -> throttled requests at the last push
+	throttling := make(map[string]bool) // Domain -> throttled requests grew at the last push
+	return func(push *protocol.StatsPush) {
+		if push.QuotaExceeded && !exceeded {
+			logger.Warn("Daily bandwidth limit reached: visitors get 429 until it resets")
+		}
+		exceeded = push.QuotaExceeded
+		for _, d := range push.Domains {
+			grew := d.Throttled > throttled[d.Domain]
+			if grew && !throttling[d.Domain] {
+				logger.Warn("Requests to %s exceed the rate limit of %g/s: visitors get 429", d.Domain, d.RateLimit)
+			}
+			throttled[d.Domain], throttling[d.Domain] = d.Throttled, grew
+		}
+		publish(events.EventServerStats, serverStatsData(push))
+	}
+}
+
+// serverStatsData converts a stats push for EventServerStats.
+func serverStatsData(push *protocol.StatsPush) events.ServerStatsData {
+	data := events.ServerStatsData{
+		BandwidthToday: push.BandwidthToday,
+		BandwidthTotal: push.BandwidthTotal,
+		BandwidthLimit: push.BandwidthLimit,
+		StreamsActive:  push.StreamsActive,
+		StreamsLimit:   push.StreamsLimit,
+		QuotaExceeded:  push.QuotaExceeded,
+	}
+	for _, d := range push.Domains {
+		data.Domains = append(data.Domains, events.DomainStatsData{
+			Domain:    d.Domain,
+			Requests:  d.Requests,
+			Bytes:     d.Bytes,
+			Throttled: d.Throttled,
+			RateLimit: d.RateLimit,
+		})
+	}
+	return data
+}
@@ -0,0 +1,243 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/client/events"
+	"gopublic/pkg/protocol"
+)
+
+// yamuxPair returns a client session and the server end of it.
+func yamuxPair(t *testing.T) (*yamux.Session, *yamux.Session) {
+	t.Helper()
+	c, s := net.Pipe()
+	client, err := yamux.Client(c, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := yamux.Server(s, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return client, server
+}
+
+func TestHeartbeat_Echoed(t *testing.T) {
+	client, server := yamuxPair(t)
+	go func() {
+		stream, err := server.Accept()
+		if err != nil {
+			return
+		}
+		// Echo, like the server does
+		decoder, encoder := json.NewDecoder(stream), json.NewEncoder(stream)
+		for {
+			var hb protocol.Heartbeat
+			if decoder.Decode(&hb) != nil {
+				return
+			}
+			hb.Stats = &protocol.StatsPush{StreamsActive: int(hb.Seq)}
+			if encoder.Encode(hb) != nil {
+				return
+			}
+		}
+	}()
+
+	pushes := make(chan *protocol.StatsPush, 100)
+	done := make(chan error, 1)
+	go func() {
+		done <- heartbeat(client, &KeepaliveConfig{Interval: 5 * time.Millisecond, Timeout: time.Second}, func(push *protocol.StatsPush) {
+			pushes <- push
+		}, nil)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	client.Close()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("heartbeat() = %v, want nil once the session is closed", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("heartbeat did not return after the session closed")
+	}
+	if len(pushes) == 0 {
+		t.Fatal("no stats delivered")
+	}
+	if push := <-pushes; push.StreamsActive != 1 {
+		t.Errorf("first push = %+v, want the stats of heartbeat 1", push)
+	}
+}
+
+func TestHeartbeat_Unanswered(t *testing.T) {
+	client, server := yamuxPair(t)
+	go func() {
+		// Accept the stream but never answer, like a hung server
+		server.Accept()
+	}()
+
+	start := time.Now()
+	err := heartbeat(client, &KeepaliveConfig{Interval: 5 * time.Millisecond, Timeout: 50 * time.Millisecond}, nil, nil)
+	if err == nil {
+		t.Fatal("heartbeat() = nil, want an error without an answer")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("heartbeat failed after %v, want about the timeout", elapsed)
+	}
+}
+
+func TestSharedTunnel_ReconnectsAfterMissedHeartbeat(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// The server completes every handshake, then stops answering
+	var handshakes atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				session, err := yamux.Server(conn, nil)
+				if err != nil {
+					return
+				}
+				defer session.Close()
+				control, err := session.Accept()
+				if err != nil {
+					return
+				}
+				decoder := json.NewDecoder(control)
+				var auth protocol.AuthRequest
+				var req protocol.TunnelRequest
+				if decoder.Decode(&auth) != nil || decoder.Decode(&req) != nil {
+					return
+				}
+				handshakes.Add(1)
+				json.NewEncoder(control).Encode(protocol.InitResponse{Success: true, BoundDomains: []string{"misty-river.example.com"}, Heartbeat: true})
+				session.Accept() // Heartbeat stream, never answered
+				<-session.CloseChan()
+			}()
+		}
+	}()
+
+	bus := events.NewBus()
+	sub := bus.Subscribe()
+	st := NewSharedTunnel(ln.Addr().String(), "token", map[string]string{"misty-river": "3000"})
+	st.SetEventBus(bus)
+	st.SetKeepalive(&KeepaliveConfig{Interval: 10 * time.Millisecond, Timeout: 100 * time.Millisecond})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		done <- st.StartWithReconnect(ctx, &ReconnectConfig{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1})
+	}()
+
+	for {
+		select {
+		case ev := <-sub:
+			if ev.Type != events.EventReconnecting {
+				continue
+			}
+			data := ev.Data.(events.ReconnectingData)
+			if !errors.Is(data.Error, ErrSessionLost) {
+				t.Errorf("reconnecting after %v, want ErrSessionLost", data.Error)
+			}
+			cancel()
+			<-done
+			if n := handshakes.Load(); n < 1 {
+				t.Errorf("handshakes = %d", n)
+			}
+			return
+		case err := <-done:
+			t.Fatalf("StartWithReconnect() = %v before reconnecting", err)
+		case <-ctx.Done():
+			t.Fatal("no EventReconnecting after the heartbeat went unanswered")
+		}
+	}
+}
+
+func TestHeartbeat_Commands(t *testing.T) {
+	client, server := yamuxPair(t)
+	go func() {
+		stream, err := server.Accept()
+		if err != nil {
+			return
+		}
+		// Send a reload, then a disconnect
+		decoder, encoder := json.NewDecoder(stream), json.NewEncoder(stream)
+		for _, command := range []string{protocol.CommandReload, protocol.CommandDisconnect} {
+			var hb protocol.Heartbeat
+			if decoder.Decode(&hb) != nil {
+				return
+			}
+			hb.Command = command
+			if encoder.Encode(hb) != nil {
+				return
+			}
+		}
+	}()
+
+	var commands []string
+	err := heartbeat(client, &KeepaliveConfig{Interval: 5 * time.Millisecond, Timeout: time.Second}, nil, func(command string) {
+		commands = append(commands, command)
+	})
+	if !errors.Is(err, ErrRemoteDisconnect) {
+		t.Errorf("heartbeat() = %v, want ErrRemoteDisconnect", err)
+	}
+	if len(commands) != 1 || commands[0] != protocol.CommandReload {
+		t.Errorf("commands = %v, want only the reload", commands)
+	}
+}
+
+func TestStatsHandler_QuotaExceeded(t *testing.T) {
+	var published []events.ServerStatsData
+	onStats := statsHandler(func(eventType events.EventType, data interface{}) {
+		if eventType != events.EventServerStats {
+			t.Errorf("published %v, want EventServerStats", eventType)
+		}
+		published = append(published, data.(events.ServerStatsData))
+	})
+
+	onStats(&protocol.StatsPush{ServerStats: protocol.ServerStats{BandwidthToday: 900, BandwidthLimit: 1000}})
+	onStats(&protocol.StatsPush{ServerStats: protocol.ServerStats{BandwidthToday: 1000, BandwidthLimit: 1000}, QuotaExceeded: true})
+	if len(published) != 2 {
+		t.Fatalf("published %d events, want 2", len(published))
+	}
+	if published[0].QuotaExceeded || !published[1].QuotaExceeded {
+		t.Errorf("QuotaExceeded not passed on: %+v", published)
+	}
+}
+
+func TestStatsHandler_Throttled(t *testing.T) {
+	var published []events.ServerStatsData
+	onStats := statsHandler(func(eventType events.EventType, data interface{}) {
+		published = append(published, data.(events.ServerStatsData))
+	})
+
+	onStats(&protocol.StatsPush{Domains: []protocol.DomainStats{{Domain: "app.example.com", Requests: 10, Throttled: 3, RateLimit: 5}}})
+	if len(published) != 1 || len(published[0].Domains) != 1 {
+		t.Fatalf("published %+v, want one domain", published)
+	}
+	d := published[0].Domains[0]
+	if d.Throttled != 3 || d.RateLimit != 5 {
+		t.Errorf("domain stats = %+v, want 3 throttled at 5/s", d)
+	}
+}
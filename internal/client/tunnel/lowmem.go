@@ -0,0 +1,21 @@
+package tunnel
+
+import "github.com/hashicorp/yamux"
+
+// lowMemoryAcceptBacklog bounds queued inbound streams in low-memory mode.
+const lowMemoryAcceptBacklog = 16
+
+// yamuxConfig returns the session configuration, pinging the server as
+// set by keepalive. In low-memory mode the accept backlog is reduced;
+// stream windows stay at yamux's default, which is also the smallest it
+// allows.
+func yamuxConfig(lowMemory bool, keepalive *KeepaliveConfig) *yamux.Config {
+	keepalive = keepaliveOrDefault(keepalive)
+	cfg := yamux.DefaultConfig()
+	cfg.KeepAliveInterval = keepalive.Interval
+	cfg.ConnectionWriteTimeout = keepalive.Timeout
+	if lowMemory {
+		cfg.AcceptBacklog = lowMemoryAcceptBacklog
+	}
+	return cfg
+}
@@ -2,28 +2,58 @@ package tunnel
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"sync"
+	"time"
 
 	"gopublic/internal/client/events"
+	"gopublic/internal/client/inspector"
 	"gopublic/internal/client/logger"
 	"gopublic/internal/client/stats"
+	"gopublic/internal/filter"
+	"gopublic/pkg/protocol"
 )
 
 // TunnelManager coordinates multiple tunnel connections using a shared session.
 type TunnelManager struct {
 	ServerAddr string
 	Token      string
-	Force      bool // Force disconnect existing sessions
-	NoCache    bool // Add Cache-Control: no-store to responses
-	tunnels    []*ManagedTunnel
-	mu         sync.Mutex
-	eventBus   *events.Bus
-	stats      *stats.Stats
+	Force      bool              // Force disconnect existing sessions
+	NoCache    bool              // Add Cache-Control: no-store to responses
+	LowMemory  bool              // Low-memory profile for the shared tunnel
+	Labels     map[string]string // Labels sent to the server with the tunnel request
+	Agent      string            // Agent name for the dashboard fleet view, see SharedTunnel.Agent
+	Managed    bool              // Tunnels come from the dashboard, see SharedTunnel.Managed
+	// StartTimeout bounds how long each tunnel may take to be bound before
+	// WaitReady reports it as failed; 0 waits indefinitely.
+	StartTimeout time.Duration
+	tunnels      []*ManagedTunnel
+	mu           sync.Mutex
+	eventBus     *events.Bus
+	stats        *stats.Stats
+	inspector    *inspector.Server
+
+	reconnect *ReconnectConfig // nil = DefaultReconnectConfig
+	tlsConfig *TLSConfig       // nil = verify against the system roots
+	keepalive *KeepaliveConfig // nil = DefaultKeepaliveConfig
+	refresher TokenRefresher   // nil = stop when the token expires
 
 	// Shared tunnel instance (used when starting)
 	sharedTunnel *SharedTunnel
 	cancelFunc   context.CancelFunc
+
+	// Readiness of the current StartAll run, see WaitReady
+	readyMu    sync.Mutex
+	readyDone  chan struct{}     // Closed once every tunnel is bound or failed
+	subdomains map[string]string // Tunnel name -> subdomain
+	mountPaths map[string]string // Tunnel name -> mount path, if any
+	scheme     string
+	bound      map[string][]string // Tunnel name -> bound domains
+	startErrs  map[string]error    // Tunnel name -> why it did not start
+	sessionErr error               // Set if StartAll returned before all were bound
+	timers     []*time.Timer
 }
 
 // ManagedTunnel wraps a tunnel with its metadata
@@ -31,6 +61,17 @@ type ManagedTunnel struct {
 	Name      string
 	LocalPort string
 	Subdomain string
+	BasicAuth string // user:pass, empty for a public tunnel
+	Filters   filter.Chain
+
+	RateLimit protocol.RateLimit // Requests per second at the ingress, zero RPS = server limit
+
+	MountPath     string // Public path prefix, empty to serve the whole subdomain
+	KeepMountPath bool   // Forward MountPath to the app instead of stripping it
+
+	Headers *HeaderRewrite // Header rules, nil to forward headers as they are
+
+	StartTimeout time.Duration // Overrides TunnelManager.StartTimeout if set
 }
 
 // NewTunnelManager creates a new tunnel manager
@@ -57,11 +98,61 @@ func (tm *TunnelManager) SetStats(stats *stats.Stats) {
 	tm.stats = stats
 }
 
+// SetInspector sets the inspector that records the exchanges of all tunnels
+func (tm *TunnelManager) SetInspector(s *inspector.Server) {
+	tm.inspector = s
+}
+
 // SetNoCache enables Cache-Control: no-store header on all responses
 func (tm *TunnelManager) SetNoCache(noCache bool) {
 	tm.NoCache = noCache
 }
 
+// SetLowMemory enables the low-memory profile for all tunnels
+func (tm *TunnelManager) SetLowMemory(lowMemory bool) {
+	tm.LowMemory = lowMemory
+}
+
+// SetLabels sets the labels sent to the server with the tunnel request
+func (tm *TunnelManager) SetLabels(labels map[string]string) {
+	tm.Labels = labels
+}
+
+// SetAgent sets the agent name sent with the auth request.
+func (tm *TunnelManager) SetAgent(agent string) {
+	tm.Agent = agent
+}
+
+// SetManaged marks the tunnels as defined in the dashboard.
+func (tm *TunnelManager) SetManaged(managed bool) {
+	tm.Managed = managed
+}
+
+// SetStartTimeout sets the default per-tunnel start timeout
+func (tm *TunnelManager) SetStartTimeout(d time.Duration) {
+	tm.StartTimeout = d
+}
+
+// SetReconnectConfig sets the reconnect policy of the shared session
+func (tm *TunnelManager) SetReconnectConfig(cfg *ReconnectConfig) {
+	tm.reconnect = cfg
+}
+
+// SetTLSConfig sets how the shared session verifies the server
+func (tm *TunnelManager) SetTLSConfig(cfg *TLSConfig) {
+	tm.tlsConfig = cfg
+}
+
+// SetKeepalive sets how fast the shared session detects a dead connection
+func (tm *TunnelManager) SetKeepalive(cfg *KeepaliveConfig) {
+	tm.keepalive = cfg
+}
+
+// SetTokenRefresher sets how the shared session renews an expired token
+func (tm *TunnelManager) SetTokenRefresher(refresh TokenRefresher) {
+	tm.refresher = refresh
+}
+
 // AddTunnel adds a tunnel configuration to the manager
 func (tm *TunnelManager) AddTunnel(name, localPort, subdomain string) {
 	tm.mu.Lock()
@@ -75,6 +166,81 @@ func (tm *TunnelManager) AddTunnel(name, localPort, subdomain string) {
 	tm.tunnels = append(tm.tunnels, mt)
 }
 
+// SetTunnelBasicAuth protects a configured tunnel with "user:pass" credentials
+func (tm *TunnelManager) SetTunnelBasicAuth(name, creds string) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, mt := range tm.tunnels {
+		if mt.Name == name {
+			mt.BasicAuth = creds
+		}
+	}
+}
+
+// SetTunnelFilters sets the traffic filters for a configured tunnel
+func (tm *TunnelManager) SetTunnelFilters(name string, chain filter.Chain) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, mt := range tm.tunnels {
+		if mt.Name == name {
+			mt.Filters = chain
+		}
+	}
+}
+
+// SetTunnelRateLimit asks the ingress to throttle requests to a configured tunnel
+func (tm *TunnelManager) SetTunnelRateLimit(name string, limit protocol.RateLimit) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, mt := range tm.tunnels {
+		if mt.Name == name {
+			mt.RateLimit = limit
+		}
+	}
+}
+
+// SetTunnelStartTimeout overrides the start timeout of a configured tunnel
+func (tm *TunnelManager) SetTunnelStartTimeout(name string, d time.Duration) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, mt := range tm.tunnels {
+		if mt.Name == name {
+			mt.StartTimeout = d
+		}
+	}
+}
+
+// SetTunnelMount serves a configured tunnel under a path prefix of its
+// subdomain, stripping the prefix toward the app unless keep is set
+func (tm *TunnelManager) SetTunnelMount(name, path string, keep bool) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, mt := range tm.tunnels {
+		if mt.Name == name {
+			mt.MountPath = NormalizeMountPath(path)
+			mt.KeepMountPath = keep
+		}
+	}
+}
+
+// SetTunnelHeaders rewrites the request and response headers of a
+// configured tunnel
+func (tm *TunnelManager) SetTunnelHeaders(name string, rw *HeaderRewrite) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, mt := range tm.tunnels {
+		if mt.Name == name {
+			mt.Headers = rw
+		}
+	}
+}
+
 // StartAll starts all configured tunnels using a single shared connection.
 func (tm *TunnelManager) StartAll(ctx context.Context) error {
 	tm.mu.Lock()
@@ -86,6 +252,10 @@ func (tm *TunnelManager) StartAll(ctx context.Context) error {
 	// Build subdomain -> localPort mapping
 	tunnelMap := make(map[string]string)
 	for _, mt := range tm.tunnels {
+		if mt.MountPath != "" {
+			logger.Info("Configured tunnel '%s': localhost:%s -> %s%s", mt.Name, mt.LocalPort, mt.Subdomain, mt.MountPath)
+			continue
+		}
 		tunnelMap[mt.Subdomain] = mt.LocalPort
 		logger.Info("Configured tunnel '%s': localhost:%s -> %s", mt.Name, mt.LocalPort, mt.Subdomain)
 	}
@@ -94,8 +264,35 @@ func (tm *TunnelManager) StartAll(ctx context.Context) error {
 	st := NewSharedTunnel(tm.ServerAddr, tm.Token, tunnelMap)
 	st.SetEventBus(tm.eventBus)
 	st.SetStats(tm.stats)
+	st.SetInspector(tm.inspector)
 	st.SetForce(tm.Force)
 	st.SetNoCache(tm.NoCache)
+	st.SetLabels(tm.Labels)
+	st.SetAgent(tm.Agent)
+	st.SetManaged(tm.Managed)
+	st.SetLowMemory(tm.LowMemory)
+	st.SetTLSConfig(tm.tlsConfig)
+	st.SetKeepalive(tm.keepalive)
+	st.SetTokenRefresher(tm.refresher)
+	for _, mt := range tm.tunnels {
+		if mt.MountPath != "" {
+			st.SetMount(mt.Subdomain, Mount{Path: mt.MountPath, LocalPort: mt.LocalPort, KeepPath: mt.KeepMountPath, Headers: mt.Headers})
+		} else if mt.Headers != nil {
+			st.SetHeaderRewrite(mt.Subdomain, mt.Headers)
+		}
+		if mt.BasicAuth != "" {
+			st.SetBasicAuth(mt.Subdomain, mt.BasicAuth)
+		}
+		if mt.RateLimit.RPS > 0 {
+			st.SetRateLimit(mt.Subdomain, mt.RateLimit)
+		}
+		if len(mt.Filters) > 0 {
+			// Mounts sharing a subdomain run all their filters
+			st.SetFilters(mt.Subdomain, append(st.Filters[mt.Subdomain], mt.Filters...))
+		}
+	}
+	st.onReady = tm.markBound
+	tm.resetReady()
 
 	tm.sharedTunnel = st
 
@@ -105,7 +302,12 @@ func (tm *TunnelManager) StartAll(ctx context.Context) error {
 	tm.mu.Unlock()
 
 	// Start shared tunnel with reconnection
-	return st.StartWithReconnect(tunnelCtx, nil)
+	err := st.StartWithReconnect(tunnelCtx, tm.reconnect)
+	if err == nil {
+		err = errors.New("tunnel closed")
+	}
+	tm.markFailed(err)
+	return err
 }
 
 // StopAll stops all running tunnels
@@ -125,3 +327,207 @@ func (tm *TunnelManager) StopAll() {
 		tm.sharedTunnel = nil
 	}
 }
+
+// Reconnect drops the shared session, which then connects again with the
+// same tunnels.
+func (tm *TunnelManager) Reconnect() error {
+	tm.mu.Lock()
+	st := tm.sharedTunnel
+	tm.mu.Unlock()
+	if st == nil {
+		return ErrNotConnected
+	}
+	return st.Reconnect()
+}
+
+// WaitReady blocks until every configured tunnel is bound by the server or
+// has failed to start, e.g. because its subdomain was refused or its start
+// timeout expired. It returns nil if all tunnels are usable; their URLs are
+// then available from PublicURLs. WaitReady may be called before StartAll.
+func (tm *TunnelManager) WaitReady(ctx context.Context) error {
+	tm.readyMu.Lock()
+	if tm.readyDone == nil {
+		tm.readyDone = make(chan struct{})
+	}
+	done := tm.readyDone
+	tm.readyMu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-done:
+	}
+
+	tm.readyMu.Lock()
+	defer tm.readyMu.Unlock()
+
+	if tm.sessionErr != nil {
+		return tm.sessionErr
+	}
+	names := make([]string, 0, len(tm.startErrs))
+	for name := range tm.startErrs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	errs := make([]error, 0, len(names))
+	for _, name := range names {
+		errs = append(errs, tm.startErrs[name])
+	}
+	return errors.Join(errs...)
+}
+
+// PublicURLs returns the public URLs of the bound tunnels by tunnel name.
+func (tm *TunnelManager) PublicURLs() map[string][]string {
+	tm.readyMu.Lock()
+	defer tm.readyMu.Unlock()
+
+	urls := make(map[string][]string, len(tm.bound))
+	for name, domains := range tm.bound {
+		for _, domain := range domains {
+			urls[name] = append(urls[name], tm.scheme+"://"+domain+tm.mountPaths[name])
+		}
+	}
+	return urls
+}
+
+// Health returns the binding state of each configured tunnel, ordered by
+// name. Each refused tunnel is retried by its own supervisor, so one failing
+// binding never reconnects the others.
+func (tm *TunnelManager) Health() []TunnelHealth {
+	tm.mu.Lock()
+	tunnels := make([]ManagedTunnel, 0, len(tm.tunnels))
+	for _, mt := range tm.tunnels {
+		tunnels = append(tunnels, *mt)
+	}
+	st := tm.sharedTunnel
+	tm.mu.Unlock()
+
+	bySubdomain := make(map[string]TunnelHealth)
+	if st != nil {
+		for _, h := range st.Health() {
+			bySubdomain[h.Subdomain] = h
+		}
+	}
+	health := make([]TunnelHealth, 0, len(tunnels))
+	for _, mt := range tunnels {
+		h, ok := bySubdomain[mt.Subdomain]
+		if !ok {
+			h = TunnelHealth{Subdomain: mt.Subdomain, State: HealthConnecting}
+		}
+		h.Name = mt.Name
+		health = append(health, h)
+	}
+	sort.Slice(health, func(i, j int) bool { return health[i].Name < health[j].Name })
+	return health
+}
+
+// resetReady starts tracking the readiness of the configured tunnels and
+// arms their start timeouts. Must be called with tm.mu held.
+func (tm *TunnelManager) resetReady() {
+	tm.readyMu.Lock()
+	defer tm.readyMu.Unlock()
+
+	for _, t := range tm.timers {
+		t.Stop()
+	}
+	tm.timers = nil
+	if tm.readyDone == nil || tm.isReadyLocked() {
+		tm.readyDone = make(chan struct{})
+	}
+	tm.subdomains = make(map[string]string, len(tm.tunnels))
+	tm.mountPaths = make(map[string]string)
+	tm.bound = make(map[string][]string)
+	tm.startErrs = make(map[string]error)
+	tm.sessionErr = nil
+
+	for _, mt := range tm.tunnels {
+		tm.subdomains[mt.Name] = mt.Subdomain
+		if mt.MountPath != "" {
+			tm.mountPaths[mt.Name] = mt.MountPath
+		}
+		timeout := mt.StartTimeout
+		if timeout == 0 {
+			timeout = tm.StartTimeout
+		}
+		if timeout > 0 {
+			name := mt.Name
+			tm.timers = append(tm.timers, time.AfterFunc(timeout, func() {
+				tm.markTimedOut(name, timeout)
+			}))
+		}
+	}
+}
+
+// markBound records the result of a handshake. Tunnels whose subdomain was
+// not bound fail, unless they were already resolved earlier.
+func (tm *TunnelManager) markBound(scheme string, bound map[string][]string) {
+	tm.readyMu.Lock()
+	defer tm.readyMu.Unlock()
+
+	tm.scheme = scheme
+	for name, subdomain := range tm.subdomains {
+		if domains, ok := bound[subdomain]; ok {
+			tm.bound[name] = domains
+		} else if !tm.resolvedLocked(name) {
+			tm.startErrs[name] = fmt.Errorf("tunnel '%s': subdomain '%s' was not bound by the server", name, subdomain)
+		}
+	}
+	tm.checkReadyLocked()
+}
+
+// markTimedOut fails a tunnel that is still not bound after its timeout.
+func (tm *TunnelManager) markTimedOut(name string, timeout time.Duration) {
+	tm.readyMu.Lock()
+	defer tm.readyMu.Unlock()
+
+	if !tm.resolvedLocked(name) {
+		tm.startErrs[name] = fmt.Errorf("tunnel '%s' not ready after %v", name, timeout)
+		tm.checkReadyLocked()
+	}
+}
+
+// markFailed fails all tunnels still pending when StartAll returns.
+func (tm *TunnelManager) markFailed(err error) {
+	tm.readyMu.Lock()
+	defer tm.readyMu.Unlock()
+
+	if !tm.isReadyLocked() {
+		tm.sessionErr = err
+		tm.checkReadyLocked()
+	}
+}
+
+func (tm *TunnelManager) resolvedLocked(name string) bool {
+	if tm.sessionErr != nil {
+		return true
+	}
+	_, bound := tm.bound[name]
+	_, failed := tm.startErrs[name]
+	return bound || failed
+}
+
+func (tm *TunnelManager) isReadyLocked() bool {
+	select {
+	case <-tm.readyDone:
+		return true
+	default:
+		return false
+	}
+}
+
+// checkReadyLocked wakes up WaitReady once no tunnel is pending.
+func (tm *TunnelManager) checkReadyLocked() {
+	if tm.readyDone == nil || tm.isReadyLocked() {
+		return
+	}
+	for name := range tm.subdomains {
+		if !tm.resolvedLocked(name) {
+			return
+		}
+	}
+	for _, t := range tm.timers {
+		t.Stop()
+	}
+	tm.timers = nil
+	close(tm.readyDone)
+}
@@ -0,0 +1,140 @@
+package tunnel
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newReadyManager(startTimeout time.Duration) *TunnelManager {
+	tm := NewTunnelManager("localhost:4443", "token")
+	tm.SetStartTimeout(startTimeout)
+	tm.AddTunnel("frontend", "3000", "misty-river")
+	tm.AddTunnel("backend", "8080", "silent-star")
+	tm.mu.Lock()
+	tm.resetReady()
+	tm.mu.Unlock()
+	return tm
+}
+
+func TestTunnelManager_WaitReady(t *testing.T) {
+	tm := newReadyManager(0)
+
+	result := make(chan error, 1)
+	go func() { result <- tm.WaitReady(context.Background()) }()
+
+	tm.markBound("https", map[string][]string{
+		"misty-river": {"misty-river.example.com"},
+		"silent-star": {"silent-star.example.com"},
+	})
+
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Fatalf("WaitReady() = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("WaitReady() did not return")
+	}
+
+	urls := tm.PublicURLs()
+	if got := urls["frontend"]; len(got) != 1 || got[0] != "https://misty-river.example.com" {
+		t.Errorf("PublicURLs()[frontend] = %v", got)
+	}
+	if got := urls["backend"]; len(got) != 1 || got[0] != "https://silent-star.example.com" {
+		t.Errorf("PublicURLs()[backend] = %v", got)
+	}
+}
+
+func TestTunnelManager_WaitReady_NotBound(t *testing.T) {
+	tm := newReadyManager(0)
+	tm.markBound("https", map[string][]string{
+		"misty-river": {"misty-river.example.com"},
+	})
+
+	err := tm.WaitReady(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "silent-star") {
+		t.Fatalf("WaitReady() = %v, want error for silent-star", err)
+	}
+	if _, ok := tm.PublicURLs()["frontend"]; !ok {
+		t.Error("bound tunnel missing from PublicURLs()")
+	}
+}
+
+func TestTunnelManager_WaitReady_StartTimeout(t *testing.T) {
+	tm := NewTunnelManager("localhost:4443", "token")
+	tm.AddTunnel("frontend", "3000", "misty-river")
+	tm.AddTunnel("backend", "8080", "silent-star")
+	tm.SetTunnelStartTimeout("backend", 50*time.Millisecond)
+	tm.mu.Lock()
+	tm.resetReady()
+	tm.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	result := make(chan error, 1)
+	go func() { result <- tm.WaitReady(ctx) }()
+
+	// Only backend has a timeout, so frontend is still awaited after it
+	time.Sleep(100 * time.Millisecond)
+	tm.markBound("https", map[string][]string{
+		"misty-river": {"misty-river.example.com"},
+		"silent-star": {"silent-star.example.com"},
+	})
+
+	err := <-result
+	if err == nil || !strings.Contains(err.Error(), "'backend' not ready after") {
+		t.Fatalf("WaitReady() = %v, want backend timeout", err)
+	}
+	// A late binding is still reported
+	if _, ok := tm.PublicURLs()["backend"]; !ok {
+		t.Error("late bound tunnel missing from PublicURLs()")
+	}
+}
+
+func TestTunnelManager_WaitReady_SessionError(t *testing.T) {
+	tm := newReadyManager(0)
+	sessionErr := &AlreadyConnectedError{Message: "already connected"}
+	tm.markFailed(sessionErr)
+
+	if err := tm.WaitReady(context.Background()); !errors.Is(err, sessionErr) {
+		t.Fatalf("WaitReady() = %v, want %v", err, sessionErr)
+	}
+}
+
+func TestTunnelManager_WaitReady_Context(t *testing.T) {
+	tm := NewTunnelManager("localhost:4443", "token")
+	tm.AddTunnel("frontend", "3000", "misty-river")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := tm.WaitReady(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("WaitReady() = %v, want deadline exceeded", err)
+	}
+}
+
+func TestTunnelManager_Health(t *testing.T) {
+	tm := NewTunnelManager("localhost:4443", "token")
+	tm.AddTunnel("frontend", "3000", "misty-river")
+	tm.AddTunnel("backend", "8080", "silent-star")
+
+	// Not started yet
+	health := tm.Health()
+	if len(health) != 2 || health[0].Name != "backend" || health[0].State != HealthConnecting {
+		t.Fatalf("Health() = %+v", health)
+	}
+
+	st := NewSharedTunnel(tm.ServerAddr, tm.Token, map[string]string{"misty-river": "3000", "silent-star": "8080"})
+	st.publishBound("https", []string{"misty-river.example.com"}, st.subdomains())
+	tm.sharedTunnel = st
+
+	health = tm.Health()
+	if health[0].Name != "backend" || health[0].State != HealthRetrying || health[0].Attempts != 1 || health[0].LastError == "" {
+		t.Errorf("backend health = %+v", health[0])
+	}
+	if health[1].Name != "frontend" || health[1].State != HealthOnline || health[1].Subdomain != "misty-river" {
+		t.Errorf("frontend health = %+v", health[1])
+	}
+}
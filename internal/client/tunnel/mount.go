@@ -0,0 +1,89 @@
+package tunnel
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Mount serves a local app under a path prefix of a subdomain, so several
+// apps can share one public domain.
+type Mount struct {
+	Path      string // Public path prefix, e.g. "/app"
+	LocalPort string
+	KeepPath  bool           // Forward the prefix to the app instead of stripping it
+	Headers   *HeaderRewrite // Header rules of the app, if any
+}
+
+// matches reports whether path is the mount path or below it.
+func (m Mount) matches(path string) bool {
+	if !strings.HasPrefix(path, m.Path) {
+		return false
+	}
+	return len(path) == len(m.Path) || path[len(m.Path)] == '/'
+}
+
+// NormalizeMountPath returns path with a leading slash and without a
+// trailing one; "/" and "" mean no mount path and return "".
+func NormalizeMountPath(path string) string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return ""
+	}
+	return "/" + path
+}
+
+// SetMount serves the app on localPort under a path prefix of subdomain.
+// Requests outside every mount of a subdomain go to its port in Tunnels,
+// if any.
+func (st *SharedTunnel) SetMount(subdomain string, m Mount) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	m.Path = NormalizeMountPath(m.Path)
+	if st.Mounts == nil {
+		st.Mounts = make(map[string][]Mount)
+	}
+	mounts := append(st.Mounts[subdomain], m)
+	// Longest prefix first, so /app/api wins over /app
+	sort.SliceStable(mounts, func(i, j int) bool { return len(mounts[i].Path) > len(mounts[j].Path) })
+	st.Mounts[subdomain] = mounts
+}
+
+// mountFor returns the mount of subdomain serving path.
+func (st *SharedTunnel) mountFor(subdomain, path string) (Mount, bool) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	for _, m := range st.Mounts[subdomain] {
+		if m.matches(path) {
+			return m, true
+		}
+	}
+	return Mount{}, false
+}
+
+// localPortsFor describes the local ports serving subdomain for events,
+// e.g. "3000, /api: 8080".
+func (st *SharedTunnel) localPortsFor(subdomain string) string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var ports []string
+	if port, ok := st.Tunnels[subdomain]; ok {
+		ports = append(ports, port)
+	}
+	for _, m := range st.Mounts[subdomain] {
+		ports = append(ports, m.Path+": "+m.LocalPort)
+	}
+	return strings.Join(ports, ", ")
+}
+
+// stripMount removes the mount path from req's URL and records it in
+// X-Forwarded-Prefix, so the app can still build public links.
+func stripMount(req *http.Request, m Mount) {
+	req.Header.Set("X-Forwarded-Prefix", m.Path)
+	req.URL.Path = "/" + strings.TrimPrefix(strings.TrimPrefix(req.URL.Path, m.Path), "/")
+	if req.URL.RawPath != "" {
+		// The escaped form of the prefix may differ; let URL re-escape Path
+		req.URL.RawPath = ""
+	}
+	req.RequestURI = req.URL.RequestURI()
+}
@@ -0,0 +1,92 @@
+package tunnel
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSharedTunnel_RouteRequest(t *testing.T) {
+	st := NewSharedTunnel("localhost:4443", "token", map[string]string{"misty-river": "3000"})
+	st.SetMount("misty-river", Mount{Path: "/api/", LocalPort: "8080"})
+	st.SetMount("misty-river", Mount{Path: "/api/admin", LocalPort: "9000", KeepPath: true})
+	st.SetMount("silent-star", Mount{Path: "/docs", LocalPort: "4000"})
+
+	tests := []struct {
+		url      string
+		wantPort string
+		wantURI  string
+		prefix   string
+	}{
+		{"http://misty-river.example.com/", "3000", "/", ""},
+		{"http://misty-river.example.com/apis", "3000", "/apis", ""},
+		{"http://misty-river.example.com/api", "8080", "/", "/api"},
+		{"http://misty-river.example.com/api/users?page=2", "8080", "/users?page=2", "/api"},
+		{"http://misty-river.example.com/api/admin/stats", "9000", "/api/admin/stats", ""},
+		{"http://silent-star.example.com/docs/index.html", "4000", "/index.html", "/docs"},
+		{"http://silent-star.example.com/other", "", "/other", ""},
+		{"http://unknown.example.com/api", "", "/api", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if got, _ := st.routeRequest(req); got != tt.wantPort {
+				t.Errorf("routeRequest() = %q, want %q", got, tt.wantPort)
+			}
+			if got := req.URL.RequestURI(); got != tt.wantURI {
+				t.Errorf("RequestURI = %q, want %q", got, tt.wantURI)
+			}
+			if got := req.Header.Get("X-Forwarded-Prefix"); got != tt.prefix {
+				t.Errorf("X-Forwarded-Prefix = %q, want %q", got, tt.prefix)
+			}
+		})
+	}
+}
+
+func TestSharedTunnel_SubdomainsWithMounts(t *testing.T) {
+	st := NewSharedTunnel("localhost:4443", "token", map[string]string{"misty-river": "3000"})
+	st.SetMount("misty-river", Mount{Path: "/api", LocalPort: "8080"})
+	st.SetMount("silent-star", Mount{Path: "/docs", LocalPort: "4000"})
+
+	got := st.subdomains()
+	if len(got) != 2 || got[0] != "misty-river" || got[1] != "silent-star" {
+		t.Errorf("subdomains() = %v, want [misty-river silent-star]", got)
+	}
+	if ports := st.localPortsFor("misty-river"); ports != "3000, /api: 8080" {
+		t.Errorf("localPortsFor() = %q", ports)
+	}
+}
+
+func TestNormalizeMountPath(t *testing.T) {
+	for in, want := range map[string]string{"": "", "/": "", "app": "/app", "/app/": "/app", "/a/b": "/a/b"} {
+		if got := NormalizeMountPath(in); got != want {
+			t.Errorf("NormalizeMountPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSharedTunnel_HeaderRewrite(t *testing.T) {
+	st := NewSharedTunnel("localhost:4443", "token", map[string]string{"misty-river": "3000"})
+	st.SetHeaderRewrite("misty-river", &HeaderRewrite{
+		Request: HeaderRules{Add: map[string]string{"X-Forwarded-Proto": "https"}, Remove: []string{"Cookie"}},
+	})
+	st.SetMount("misty-river", Mount{Path: "/api", LocalPort: "8080", Headers: &HeaderRewrite{
+		Response: HeaderRules{Add: map[string]string{"Access-Control-Allow-Origin": "*"}},
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "http://misty-river.example.com/", nil)
+	req.Header.Set("Cookie", "session=1")
+	_, rw := st.routeRequest(req)
+	if rw == nil {
+		t.Fatal("routeRequest() returned no rules for the subdomain")
+	}
+	rw.Request.apply(req.Header)
+	if req.Header.Get("Cookie") != "" || req.Header.Get("X-Forwarded-Proto") != "https" {
+		t.Errorf("request headers = %v", req.Header)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "http://misty-river.example.com/api/users", nil)
+	if _, rw := st.routeRequest(req); rw == nil || rw.Response.Add["Access-Control-Allow-Origin"] != "*" {
+		t.Errorf("routeRequest() rules = %+v, want the mount's", rw)
+	}
+}
@@ -2,10 +2,14 @@ package tunnel
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand/v2"
 	"time"
 
+	"gopublic/internal/client/events"
 	"gopublic/internal/client/logger"
+	"gopublic/pkg/protocol"
 )
 
 // ReconnectConfig holds reconnection parameters
@@ -13,7 +17,12 @@ type ReconnectConfig struct {
 	InitialDelay time.Duration
 	MaxDelay     time.Duration
 	Multiplier   float64
-	MaxAttempts  int // 0 = infinite
+	MaxAttempts  int     // 0 = infinite
+	Jitter       float64 // 0-1: randomize each delay by up to this fraction, so clients don't retry in lockstep
+	// OfflineStart keeps retrying until the first connection succeeds, so
+	// the client can be started while the server is unreachable;
+	// MaxAttempts only applies once it has been connected.
+	OfflineStart bool
 }
 
 // DefaultReconnectConfig returns sensible defaults for reconnection
@@ -26,6 +35,31 @@ func DefaultReconnectConfig() *ReconnectConfig {
 	}
 }
 
+// Validate checks that the parameters describe a usable backoff.
+func (c *ReconnectConfig) Validate() error {
+	switch {
+	case c.InitialDelay <= 0:
+		return errors.New("initial delay must be positive")
+	case c.MaxDelay < c.InitialDelay:
+		return errors.New("max delay must not be below the initial delay")
+	case c.Multiplier < 1:
+		return errors.New("multiplier must be at least 1")
+	case c.MaxAttempts < 0:
+		return errors.New("max attempts must not be negative")
+	case c.Jitter < 0 || c.Jitter > 1:
+		return errors.New("jitter must be between 0 and 1")
+	}
+	return nil
+}
+
+// jittered randomizes a delay by up to ±Jitter of its value.
+func (c *ReconnectConfig) jittered(delay time.Duration) time.Duration {
+	if c.Jitter <= 0 {
+		return delay
+	}
+	return time.Duration(float64(delay) * (1 + c.Jitter*(2*rand.Float64()-1)))
+}
+
 // StartWithReconnect starts the tunnel with automatic reconnection on failure
 func (t *Tunnel) StartWithReconnect(ctx context.Context, cfg *ReconnectConfig) error {
 	if cfg == nil {
@@ -43,6 +77,8 @@ func (t *Tunnel) StartWithReconnect(ctx context.Context, cfg *ReconnectConfig) e
 
 	attempt := 0
 	delay := cfg.InitialDelay
+	var lastErr error
+	renewed := false // The token was renewed since the last session
 
 	for {
 		// Check if context is cancelled
@@ -56,18 +92,24 @@ func (t *Tunnel) StartWithReconnect(ctx context.Context, cfg *ReconnectConfig) e
 		attempt++
 
 		// Check max attempts
-		if cfg.MaxAttempts > 0 && attempt > cfg.MaxAttempts {
+		t.mu.Lock()
+		connectedOnce := t.connectedOnce
+		t.mu.Unlock()
+		if cfg.MaxAttempts > 0 && attempt > cfg.MaxAttempts && (!cfg.OfflineStart || connectedOnce) {
 			t.publishStatus("error", fmt.Sprintf("Max reconnection attempts (%d) exceeded", cfg.MaxAttempts))
-			return fmt.Errorf("max reconnection attempts (%d) exceeded", cfg.MaxAttempts)
+			return fmt.Errorf("max reconnection attempts (%d) exceeded: %w", cfg.MaxAttempts, lastErr)
 		}
 
 		// Wait before reconnecting (except first attempt)
 		if attempt > 1 {
-			logger.Info("Reconnecting in %v (attempt %d)...", delay, attempt)
-			t.publishStatus("reconnecting", fmt.Sprintf("Reconnecting in %v (attempt %d)...", delay, attempt))
+			// A busy server says how long to stay away
+			wait := max(cfg.jittered(delay), retryAfter(lastErr))
+			logger.Info("Reconnecting in %v (attempt %d)...", wait.Round(time.Millisecond), attempt)
+			t.publishStatus("reconnecting", fmt.Sprintf("Reconnecting in %v (attempt %d)...", wait.Round(time.Millisecond), attempt))
+			t.publishEvent(events.EventReconnecting, events.ReconnectingData{Attempt: attempt, Delay: wait, Error: lastErr})
 
 			select {
-			case <-time.After(delay):
+			case <-time.After(wait):
 			case <-ctx.Done():
 				logger.Info("Tunnel shutdown requested during reconnect wait")
 				return ctx.Err()
@@ -77,14 +119,45 @@ func (t *Tunnel) StartWithReconnect(ctx context.Context, cfg *ReconnectConfig) e
 		// Try to connect
 		logger.Info("Connecting to %s...", t.ServerAddr)
 		err := t.Start()
+		lastErr = err
+
+		if err != nil && !renewed {
+			if token, renewErr := renewToken(ctx, t.Refresher, err); renewErr == nil {
+				logger.Info("Token expired, renewed it")
+				t.Token = token
+				renewed = true
+				attempt = 0
+				continue
+			} else {
+				err, lastErr = renewErr, renewErr
+			}
+		}
 
 		if err != nil {
+			if errors.Is(err, ErrRemoteDisconnect) {
+				t.publishStatus("stopped", "Disconnected from the dashboard")
+				return err
+			}
 			// Don't retry on "already connected" error - this is not transient
 			if IsAlreadyConnectedError(err) {
 				logger.Error("Session conflict: %v", err)
 				t.publishStatus("error", fmt.Sprintf("Session conflict: %v", err))
 				return err
 			}
+			// A single tunnel has nothing left to serve when its domains are refused
+			if isPermanent(err) || ServerErrorCode(err) == protocol.ErrorCodeNoDomains {
+				logger.Error("Connection refused: %v", err)
+				return err
+			}
+
+			if errors.Is(err, ErrSessionLost) {
+				// The session was up: start the backoff over
+				logger.Warn("Connection lost: %v", err)
+				attempt = 1
+				delay = cfg.InitialDelay
+				renewed = false
+				continue
+			}
 
 			logger.Warn("Connection failed: %v", err)
 			t.publishStatus("connection_failed", fmt.Sprintf("Connection failed: %v (retry in %v)", err, delay))
@@ -105,5 +178,6 @@ func (t *Tunnel) StartWithReconnect(ctx context.Context, cfg *ReconnectConfig) e
 		// Reset backoff on successful connection
 		attempt = 0
 		delay = cfg.InitialDelay
+		renewed = false
 	}
 }
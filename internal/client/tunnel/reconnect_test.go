@@ -2,8 +2,16 @@ package tunnel
 
 import (
 	"context"
+	"encoding/json"
+	"net"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"gopublic/pkg/protocol"
+
+	"github.com/hashicorp/yamux"
 )
 
 func TestDefaultReconnectConfig(t *testing.T) {
@@ -66,6 +74,44 @@ func TestExponentialBackoff(t *testing.T) {
 	}
 }
 
+func TestReconnectConfig_Validate(t *testing.T) {
+	if err := DefaultReconnectConfig().Validate(); err != nil {
+		t.Errorf("default config: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(c *ReconnectConfig)
+	}{
+		{"zero initial delay", func(c *ReconnectConfig) { c.InitialDelay = 0 }},
+		{"max below initial", func(c *ReconnectConfig) { c.MaxDelay = c.InitialDelay / 2 }},
+		{"shrinking multiplier", func(c *ReconnectConfig) { c.Multiplier = 0.5 }},
+		{"negative attempts", func(c *ReconnectConfig) { c.MaxAttempts = -1 }},
+		{"jitter above 1", func(c *ReconnectConfig) { c.Jitter = 1.5 }},
+	}
+	for _, tt := range tests {
+		cfg := DefaultReconnectConfig()
+		tt.modify(cfg)
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: Validate() = nil, want error", tt.name)
+		}
+	}
+}
+
+func TestReconnectConfig_Jittered(t *testing.T) {
+	cfg := DefaultReconnectConfig()
+	if got := cfg.jittered(time.Second); got != time.Second {
+		t.Errorf("jittered() without jitter = %v, want 1s", got)
+	}
+
+	cfg.Jitter = 0.25
+	for i := 0; i < 100; i++ {
+		if got := cfg.jittered(time.Second); got < 750*time.Millisecond || got > 1250*time.Millisecond {
+			t.Fatalf("jittered() = %v, want within 25%% of 1s", got)
+		}
+	}
+}
+
 func TestStartWithReconnect_ContextCancellation(t *testing.T) {
 	tunnel := NewTunnel("invalid-server:9999", "test-token", "3000")
 
@@ -116,3 +162,209 @@ func TestStartWithReconnect_MaxAttempts(t *testing.T) {
 		t.Errorf("Took too long: %v", elapsed)
 	}
 }
+
+func TestStartWithReconnect_OfflineStart(t *testing.T) {
+	tunnel := NewTunnel("127.0.0.1:1", "test-token", "3000")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	cfg := &ReconnectConfig{
+		InitialDelay: 1 * time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   1.0,
+		MaxAttempts:  2,
+		OfflineStart: true,
+	}
+
+	// Never connected, so MaxAttempts does not end the retries
+	if err := tunnel.StartWithReconnect(ctx, cfg); err != context.DeadlineExceeded {
+		t.Errorf("StartWithReconnect() = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestStartWithReconnect_PermanentError(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// The server refuses every handshake with an invalid token
+	var handshakes atomic.Int32
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			handshakes.Add(1)
+			go func() {
+				session, err := yamux.Server(conn, nil)
+				if err != nil {
+					return
+				}
+				defer session.Close()
+				control, err := session.Accept()
+				if err != nil {
+					return
+				}
+				var auth protocol.AuthRequest
+				if json.NewDecoder(control).Decode(&auth) != nil {
+					return
+				}
+				json.NewEncoder(control).Encode(protocol.InitResponse{Error: "Invalid Token", ErrorCode: protocol.ErrorCodeInvalidToken})
+				<-session.CloseChan()
+			}()
+		}
+	}()
+
+	tunnel := NewTunnel(ln.Addr().String(), "bad-token", "3000")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err = tunnel.StartWithReconnect(ctx, &ReconnectConfig{
+		InitialDelay: 1 * time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   1.0,
+	})
+	if code := ServerErrorCode(err); code != protocol.ErrorCodeInvalidToken {
+		t.Fatalf("StartWithReconnect() = %v, want invalid token error", err)
+	}
+	if n := handshakes.Load(); n != 1 {
+		t.Errorf("handshakes = %d, want no retries", n)
+	}
+}
+
+func TestStartWithReconnect_ServerBusy(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// The server sheds every handshake and asks for a one second pause
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				session, err := yamux.Server(conn, nil)
+				if err != nil {
+					return
+				}
+				defer session.Close()
+				control, err := session.Accept()
+				if err != nil {
+					return
+				}
+				json.NewEncoder(control).Encode(protocol.InitResponse{Error: "The server is busy", ErrorCode: protocol.ErrorCodeServerBusy, RetryAfter: 1})
+				<-session.CloseChan()
+			}()
+		}
+	}()
+
+	tunnel := NewTunnel(ln.Addr().String(), "token", "3000")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	err = tunnel.StartWithReconnect(ctx, &ReconnectConfig{
+		InitialDelay: 1 * time.Millisecond,
+		MaxDelay:     5 * time.Millisecond,
+		Multiplier:   1.0,
+		MaxAttempts:  2,
+	})
+	if code := ServerErrorCode(err); code != protocol.ErrorCodeServerBusy {
+		t.Fatalf("StartWithReconnect() = %v, want server busy error", err)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("retried after %v, want the server's Retry-After of 1s", elapsed)
+	}
+}
+
+func TestStartWithReconnect_TokenRefresh(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	// The server renews refresh-1 and refuses every token: "old" as expired,
+	// the others as invalid
+	var mu sync.Mutex
+	var tokens []string
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				session, err := yamux.Server(conn, nil)
+				if err != nil {
+					return
+				}
+				defer session.Close()
+				control, err := session.Accept()
+				if err != nil {
+					return
+				}
+				var auth protocol.AuthRequest
+				if json.NewDecoder(control).Decode(&auth) != nil {
+					return
+				}
+				resp := protocol.InitResponse{Error: "Invalid Token", ErrorCode: protocol.ErrorCodeInvalidToken}
+				switch {
+				case auth.Refresh == "refresh-1":
+					resp = protocol.InitResponse{Success: true, Refreshed: &protocol.TokenRefresh{Token: "new", RefreshToken: "refresh-2"}}
+				case auth.Refresh != "":
+				case auth.Token == "old":
+					resp = protocol.InitResponse{Error: "Token expired", ErrorCode: protocol.ErrorCodeTokenExpired}
+				}
+				if auth.Refresh == "" {
+					mu.Lock()
+					tokens = append(tokens, auth.Token)
+					mu.Unlock()
+				}
+				json.NewEncoder(control).Encode(resp)
+				<-session.CloseChan()
+			}()
+		}
+	}()
+
+	cfg := &ReconnectConfig{InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond, Multiplier: 1.0}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	tunnel := NewTunnel(ln.Addr().String(), "old", "3000")
+	tunnel.SetTokenRefresher(func(ctx context.Context) (string, error) {
+		refreshed, err := RefreshToken(ctx, ln.Addr().String(), nil, "refresh-1")
+		if err != nil {
+			return "", err
+		}
+		return refreshed.Token, nil
+	})
+	err = tunnel.StartWithReconnect(ctx, cfg)
+	if code := ServerErrorCode(err); code != protocol.ErrorCodeInvalidToken {
+		t.Fatalf("StartWithReconnect() = %v, want invalid token error", err)
+	}
+	mu.Lock()
+	if len(tokens) != 2 || tokens[0] != "old" || tokens[1] != "new" {
+		t.Errorf("tokens = %v, want [old new]", tokens)
+	}
+	mu.Unlock()
+
+	// A failed refresh stops with the expiry
+	tunnel = NewTunnel(ln.Addr().String(), "old", "3000")
+	tunnel.SetTokenRefresher(func(ctx context.Context) (string, error) {
+		_, err := RefreshToken(ctx, ln.Addr().String(), nil, "revoked")
+		return "", err
+	})
+	err = tunnel.StartWithReconnect(ctx, cfg)
+	if code := ServerErrorCode(err); code != protocol.ErrorCodeTokenExpired {
+		t.Fatalf("StartWithReconnect() = %v, want token expired error", err)
+	}
+}
@@ -0,0 +1,104 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/pkg/protocol"
+)
+
+// TokenRefresher renews the token once the server reports it expired
+// (protocol.ErrorCodeTokenExpired) and returns the new one. Reconnect
+// loops call it once per expiry; if it fails, they stop with the expiry.
+type TokenRefresher func(ctx context.Context) (string, error)
+
+// RefreshToken exchanges refreshToken for a new token at the server, see
+// protocol.AuthRequest.Refresh. The old token and refresh token stop
+// working; the caller must store the new ones.
+func RefreshToken(ctx context.Context, serverAddr string, tlsCfg *TLSConfig, refreshToken string) (*protocol.TokenRefresh, error) {
+	resp, err := exchange(ctx, serverAddr, tlsCfg, protocol.AuthRequest{Refresh: refreshToken})
+	if err != nil {
+		return nil, err
+	}
+	if resp.Refreshed == nil {
+		return nil, &ServerError{Code: resp.ErrorCode, Message: resp.Error}
+	}
+	return resp.Refreshed, nil
+}
+
+// Join redeems a one-time join token created in the dashboard for a token
+// of the agent's own, see protocol.AuthRequest.Join. The caller must store
+// it; the join token stops working.
+func Join(ctx context.Context, serverAddr string, tlsCfg *TLSConfig, join, agent string) (string, error) {
+	resp, err := exchange(ctx, serverAddr, tlsCfg, protocol.AuthRequest{Join: join, Agent: agent})
+	if err != nil {
+		return "", err
+	}
+	if resp.Refreshed == nil {
+		return "", &ServerError{Code: resp.ErrorCode, Message: resp.Error}
+	}
+	return resp.Refreshed.Token, nil
+}
+
+// exchange sends a single AuthRequest that the server answers without
+// opening a session, e.g. a token refresh, and returns the successful
+// answer.
+func exchange(ctx context.Context, serverAddr string, tlsCfg *TLSConfig, req protocol.AuthRequest) (*protocol.InitResponse, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	host, _, _ := net.SplitHostPort(serverAddr)
+	var conn net.Conn
+	var err error
+	if host == "localhost" || host == "127.0.0.1" || host == "::1" {
+		conn, err = dialer.DialContext(ctx, "tcp", serverAddr)
+	} else {
+		cfg, _ := tlsCfg.ClientConfig()
+		conn, err = (&tls.Dialer{NetDialer: dialer, Config: cfg}).DialContext(ctx, "tcp", serverAddr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+	defer conn.Close()
+
+	session, err := yamux.Client(conn, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start yamux: %w", err)
+	}
+	defer session.Close()
+	stream, err := session.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open handshake stream: %w", err)
+	}
+	stream.SetDeadline(time.Now().Add(10 * time.Second))
+
+	if err := json.NewEncoder(stream).Encode(req); err != nil {
+		return nil, err
+	}
+	var resp protocol.InitResponse
+	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("read failed: %w", err)
+	}
+	if !resp.Success {
+		return nil, &ServerError{Code: resp.ErrorCode, Message: resp.Error}
+	}
+	return &resp, nil
+}
+
+// renewToken returns a new token from refresh if err reports an expired
+// token. Otherwise, or if the refresh fails, it returns the error to stop
+// with.
+func renewToken(ctx context.Context, refresh TokenRefresher, err error) (string, error) {
+	if refresh == nil || ServerErrorCode(err) != protocol.ErrorCodeTokenExpired {
+		return "", err
+	}
+	token, refreshErr := refresh(ctx)
+	if refreshErr != nil {
+		return "", fmt.Errorf("%w (refresh failed: %v)", err, refreshErr)
+	}
+	return token, nil
+}
@@ -0,0 +1,86 @@
+package tunnel
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"math"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Limits on the request head read from a stream. The ingress already
+// bounds what it forwards; these keep a hostile or buggy server from
+// making the client buffer without end.
+const (
+	maxRequestHeaderBytes = 64 << 10 // Request line and headers
+	maxRequestHeaders     = 100
+	maxRequestURILength   = 8 << 10
+)
+
+// Errors of readRequest for heads over the limits.
+var (
+	errHeaderTooLarge = errors.New("request header too large")
+	errURITooLong     = errors.New("request URI too long")
+)
+
+// readRequest reads a request from a stream with a bounded head. The
+// returned reader must be used for everything read after the request; the
+// body is not limited.
+func readRequest(remote net.Conn) (*bufio.Reader, *http.Request, error) {
+	limited := &io.LimitedReader{R: remote, N: maxRequestHeaderBytes}
+	reader := bufio.NewReader(limited)
+	req, err := http.ReadRequest(reader)
+	exhausted := limited.N <= 0
+	limited.N = math.MaxInt64
+	if err != nil {
+		if exhausted {
+			return reader, nil, errHeaderTooLarge
+		}
+		return reader, nil, err
+	}
+
+	if len(req.RequestURI) > maxRequestURILength {
+		return reader, nil, errURITooLong
+	}
+	count := 0
+	for _, values := range req.Header {
+		count += len(values)
+	}
+	if count > maxRequestHeaders {
+		return reader, nil, errHeaderTooLarge
+	}
+	return reader, req, nil
+}
+
+// rejectRequest answers a request head over the limits with 431 or 414.
+// It reports false for other errors, which it leaves to the caller.
+func rejectRequest(remote net.Conn, err error) bool {
+	switch {
+	case errors.Is(err, errHeaderTooLarge):
+		writeStatus(remote, http.StatusRequestHeaderFieldsTooLarge, err.Error())
+	case errors.Is(err, errURITooLong):
+		writeStatus(remote, http.StatusRequestURITooLong, err.Error())
+	default:
+		return false
+	}
+	return true
+}
+
+// writeStatus writes a plain text response with status code to the
+// ingress.
+func writeStatus(remote net.Conn, code int, message string) {
+	resp := &http.Response{
+		StatusCode:    code,
+		Status:        http.StatusText(code),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(message)),
+		ContentLength: int64(len(message)),
+	}
+	resp.Header.Set("Content-Type", "text/plain")
+	resp.Write(remote)
+}
@@ -2,7 +2,6 @@ package tunnel
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -11,14 +10,18 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"sort"
 	"strings"
 	"sync"
 	"time"
 
+	"gopublic/internal/client/crash"
 	"gopublic/internal/client/events"
 	"gopublic/internal/client/inspector"
 	"gopublic/internal/client/logger"
 	"gopublic/internal/client/stats"
+	"gopublic/internal/filter"
+	"gopublic/internal/version"
 	"gopublic/pkg/protocol"
 
 	"github.com/hashicorp/yamux"
@@ -30,15 +33,49 @@ type SharedTunnel struct {
 	ServerAddr string
 	Token      string
 	Force      bool
-	NoCache    bool              // Add Cache-Control: no-store to responses
-	Tunnels    map[string]string // subdomain -> localPort
+	NoCache    bool                      // Add Cache-Control: no-store to responses
+	LowMemory  bool                      // Stream bodies without capture, smaller yamux buffers
+	Tunnels    map[string]string         // subdomain -> localPort
+	Mounts     map[string][]Mount        // subdomain -> apps under path prefixes, see SetMount
+	Rewrites   map[string]*HeaderRewrite // subdomain -> header rules, see SetHeaderRewrite
+
+	// Labels sent to the server to identify this session (e.g. env=staging)
+	Labels map[string]string
+
+	// Agent names this client in the dashboard fleet view; sessions of
+	// different agents coexist. Empty for the regular session.
+	Agent string
+
+	// Managed marks tunnels defined in the dashboard: the server reloads
+	// the session when their definitions change.
+	Managed bool
+
+	// Basic auth "user:pass" per subdomain (optional)
+	BasicAuth map[string]string
+
+	// Request rate limit per subdomain (optional)
+	RateLimit map[string]protocol.RateLimit
+
+	// Traffic filters per subdomain (optional)
+	Filters map[string]filter.Chain
 
 	// TLS configuration
 	TLSConfig *TLSConfig
 
+	// Keepalive detects dead connections; nil = DefaultKeepaliveConfig
+	Keepalive *KeepaliveConfig
+
+	// Refresher renews an expired Token; nil = stop when it expires
+	Refresher TokenRefresher
+
 	// Dependencies
-	eventBus *events.Bus
-	stats    *stats.Stats
+	eventBus  *events.Bus
+	stats     *stats.Stats
+	inspector *inspector.Server // Records exchanges; nil records nothing
+
+	// onReady is called after each handshake and late bind with the domains
+	// bound per requested subdomain; subdomains not bound are absent.
+	onReady func(scheme string, bound map[string][]string)
 
 	// Internal state
 	mu          sync.Mutex
@@ -48,7 +85,9 @@ type SharedTunnel struct {
 	closed      bool
 
 	// Cached connection info
-	boundDomains []string
+	boundDomains  []string
+	connectedOnce bool                     // A handshake has succeeded, see ReconnectConfig.OfflineStart
+	health        map[string]*TunnelHealth // By subdomain, see Health
 }
 
 // NewSharedTunnel creates a new shared tunnel instance.
@@ -71,11 +110,26 @@ func (st *SharedTunnel) SetStats(s *stats.Stats) {
 	st.stats = s
 }
 
+// SetInspector sets the inspector that records the tunnels' exchanges.
+func (st *SharedTunnel) SetInspector(s *inspector.Server) {
+	st.inspector = s
+}
+
 // SetTLSConfig sets the TLS configuration.
 func (st *SharedTunnel) SetTLSConfig(cfg *TLSConfig) {
 	st.TLSConfig = cfg
 }
 
+// SetKeepalive sets how fast a dead connection is detected.
+func (st *SharedTunnel) SetKeepalive(cfg *KeepaliveConfig) {
+	st.Keepalive = cfg
+}
+
+// SetTokenRefresher sets how an expired token is renewed.
+func (st *SharedTunnel) SetTokenRefresher(refresh TokenRefresher) {
+	st.Refresher = refresh
+}
+
 // SetForce sets the force flag to disconnect existing session.
 func (st *SharedTunnel) SetForce(force bool) {
 	st.Force = force
@@ -86,6 +140,88 @@ func (st *SharedTunnel) SetNoCache(noCache bool) {
 	st.NoCache = noCache
 }
 
+// SetLowMemory enables the low-memory profile (see Tunnel.SetLowMemory).
+func (st *SharedTunnel) SetLowMemory(lowMemory bool) {
+	st.LowMemory = lowMemory
+}
+
+// SetAgent sets the agent name sent with the auth request.
+func (st *SharedTunnel) SetAgent(agent string) {
+	st.Agent = agent
+}
+
+// SetManaged marks the tunnels as defined in the dashboard.
+func (st *SharedTunnel) SetManaged(managed bool) {
+	st.Managed = managed
+}
+
+// SetLabels sets the labels sent to the server with the tunnel request.
+func (st *SharedTunnel) SetLabels(labels map[string]string) {
+	st.Labels = labels
+}
+
+// SetBasicAuth protects a subdomain with "user:pass" credentials.
+func (st *SharedTunnel) SetBasicAuth(subdomain, creds string) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.BasicAuth == nil {
+		st.BasicAuth = make(map[string]string)
+	}
+	st.BasicAuth[subdomain] = creds
+}
+
+// SetRateLimit asks the ingress to throttle requests to a subdomain.
+func (st *SharedTunnel) SetRateLimit(subdomain string, limit protocol.RateLimit) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.RateLimit == nil {
+		st.RateLimit = make(map[string]protocol.RateLimit)
+	}
+	st.RateLimit[subdomain] = limit
+}
+
+// SetFilters sets the traffic filters applied to requests for a subdomain.
+func (st *SharedTunnel) SetFilters(subdomain string, chain filter.Chain) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	if st.Filters == nil {
+		st.Filters = make(map[string]filter.Chain)
+	}
+	st.Filters[subdomain] = chain
+}
+
+// rateLimitFor returns the rate limits to send when binding subdomains.
+func (st *SharedTunnel) rateLimitFor(subdomains []string) map[string]protocol.RateLimit {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var limits map[string]protocol.RateLimit
+	for _, subdomain := range subdomains {
+		if l, ok := st.RateLimit[subdomain]; ok {
+			if limits == nil {
+				limits = make(map[string]protocol.RateLimit)
+			}
+			limits[subdomain] = l
+		}
+	}
+	return limits
+}
+
+// basicAuthFor returns the credentials to send when binding subdomains.
+func (st *SharedTunnel) basicAuthFor(subdomains []string) map[string]string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	var creds map[string]string
+	for _, subdomain := range subdomains {
+		if c, ok := st.BasicAuth[subdomain]; ok {
+			if creds == nil {
+				creds = make(map[string]string)
+			}
+			creds[subdomain] = c
+		}
+	}
+	return creds
+}
+
 // BoundDomains returns the domains bound to this tunnel.
 func (st *SharedTunnel) BoundDomains() []string {
 	st.mu.Lock()
@@ -100,6 +236,12 @@ func (st *SharedTunnel) publishEvent(eventType events.EventType, data interface{
 	}
 }
 
+// onCommand publishes a remote command received with a heartbeat.
+func (st *SharedTunnel) onCommand(command string) {
+	logger.Info("Remote command from the dashboard: %s", command)
+	st.publishEvent(events.EventRemoteCommand, events.RemoteCommandData{Command: command})
+}
+
 // publishStatus publishes a connection status event.
 func (st *SharedTunnel) publishStatus(stage, message string) {
 	st.publishEvent(events.EventConnectionStatus, events.ConnectionStatusData{
@@ -128,6 +270,9 @@ func (st *SharedTunnel) untrackConn(conn net.Conn) {
 // Start establishes a connection to the server and starts the shared tunnel.
 func (st *SharedTunnel) Start(ctx context.Context) error {
 	st.publishEvent(events.EventConnecting, nil)
+	for _, subdomain := range st.subdomains() {
+		st.setHealthState(subdomain, HealthConnecting, nil)
+	}
 
 	host, _, _ := net.SplitHostPort(st.ServerAddr)
 	if host == "" {
@@ -145,25 +290,21 @@ func (st *SharedTunnel) Start(ctx context.Context) error {
 		if err != nil {
 			st.publishStatus("error", fmt.Sprintf("Connection failed: %v", err))
 			st.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "connect"})
-			return fmt.Errorf("failed to connect to local server: %v", err)
+			return fmt.Errorf("failed to connect to local server: %w", err)
 		}
 		return st.handleSession(ctx, conn, connectStart)
 	}
 
-	// Build TLS config
-	tlsConfig := &tls.Config{}
-	if st.TLSConfig != nil {
-		tlsConfig.InsecureSkipVerify = st.TLSConfig.InsecureSkipVerify
-		if st.TLSConfig.ServerName != "" {
-			tlsConfig.ServerName = st.TLSConfig.ServerName
-		}
-	} else {
-		tlsConfig.InsecureSkipVerify = true
-	}
+	tlsConfig, allowPlain := st.TLSConfig.ClientConfig()
 
 	st.publishStatus("dialing", fmt.Sprintf("Connecting to %s (TLS)...", st.ServerAddr))
 	dialer := &net.Dialer{Timeout: dialTimeout}
 	conn, err := tls.DialWithDialer(dialer, "tcp", st.ServerAddr, tlsConfig)
+	if err != nil && !allowPlain {
+		st.publishStatus("error", fmt.Sprintf("TLS connection failed: %v", err))
+		st.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "connect"})
+		return fmt.Errorf("failed to connect: %w", err)
+	}
 	if err != nil {
 		st.publishStatus("tls_fallback", fmt.Sprintf("TLS failed: %v, trying plain TCP...", err))
 		logger.Warn("TLS connection failed, trying plain TCP: %v", err)
@@ -171,7 +312,7 @@ func (st *SharedTunnel) Start(ctx context.Context) error {
 		if errPlain != nil {
 			st.publishStatus("error", fmt.Sprintf("Connection failed: %v", errPlain))
 			st.publishEvent(events.EventError, events.ErrorData{Error: errPlain, Context: "connect"})
-			return fmt.Errorf("failed to connect: %v", errPlain)
+			return fmt.Errorf("failed to connect: %w", errPlain)
 		}
 		return st.handleSession(ctx, connPlain, connectStart)
 	}
@@ -191,7 +332,7 @@ func (st *SharedTunnel) handleSession(ctx context.Context, conn net.Conn, connec
 
 	// Start Yamux Client
 	st.publishStatus("yamux_init", "Initializing multiplexed connection...")
-	session, err := yamux.Client(conn, nil)
+	session, err := yamux.Client(conn, yamuxConfig(st.LowMemory, st.Keepalive))
 	if err != nil {
 		st.publishStatus("error", fmt.Sprintf("Failed to init yamux: %v", err))
 		return fmt.Errorf("failed to start yamux: %v", err)
@@ -222,26 +363,30 @@ func (st *SharedTunnel) handleSession(ctx context.Context, conn net.Conn, connec
 		return fmt.Errorf("failed to open handshake stream: %v", err)
 	}
 
-	handshakeTimeout := 5 * time.Second
+	handshakeTimeout := keepaliveOrDefault(st.Keepalive).handshakeTimeout()
 	stream.SetWriteDeadline(time.Now().Add(handshakeTimeout))
 
 	// Auth
 	st.publishStatus("authenticating", "Authenticating with server...")
-	authReq := protocol.AuthRequest{Token: st.Token, Force: st.Force}
+	authReq := protocol.AuthRequest{Token: st.Token, Force: st.Force, Device: deviceName(), Agent: st.Agent, Version: version.Version, Commands: true, Managed: st.Managed}
 	if err := json.NewEncoder(stream).Encode(authReq); err != nil {
-		st.publishStatus("error", fmt.Sprintf("Failed to send auth: %v", err))
+		err = handshakeError("sending the token", handshakeTimeout, err)
+		st.publishHandshakeError("Failed to send auth", err)
 		return err
 	}
 
 	// Request all subdomains
 	st.publishStatus("requesting_tunnel", "Requesting tunnels...")
-	var requestedDomains []string
-	for subdomain := range st.Tunnels {
-		requestedDomains = append(requestedDomains, subdomain)
+	requestedDomains := st.subdomains()
+	tunnelReq := protocol.TunnelRequest{
+		RequestedDomains: requestedDomains,
+		Labels:           st.Labels,
+		BasicAuth:        st.basicAuthFor(requestedDomains),
+		RateLimit:        st.rateLimitFor(requestedDomains),
 	}
-	tunnelReq := protocol.TunnelRequest{RequestedDomains: requestedDomains}
 	if err := json.NewEncoder(stream).Encode(tunnelReq); err != nil {
-		st.publishStatus("error", fmt.Sprintf("Failed to request tunnel: %v", err))
+		err = handshakeError("requesting the tunnels", handshakeTimeout, err)
+		st.publishHandshakeError("Failed to request tunnel", err)
 		return err
 	}
 	stream.SetWriteDeadline(time.Time{})
@@ -250,22 +395,39 @@ func (st *SharedTunnel) handleSession(ctx context.Context, conn net.Conn, connec
 	stream.SetReadDeadline(time.Now().Add(handshakeTimeout))
 	var resp protocol.InitResponse
 	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
-		st.publishStatus("error", fmt.Sprintf("Failed to read response: %v", err))
+		err = handshakeError("waiting for the tunnel response", handshakeTimeout, err)
+		st.publishHandshakeError("Failed to read response", err)
 		return err
 	}
 	stream.SetReadDeadline(time.Time{})
 
+	// Determine scheme (https for remote, http for local)
+	host, _, _ := net.SplitHostPort(st.ServerAddr)
+	if host == "" {
+		host = st.ServerAddr
+	}
+	isLocal := host == "localhost" || host == "127.0.0.1" || host == "::1"
+	scheme := "https"
+	if isLocal {
+		scheme = "http"
+	}
+
 	if !resp.Success {
 		st.publishStatus("error", resp.Error)
 		if resp.ErrorCode == protocol.ErrorCodeAlreadyConnected {
 			return &AlreadyConnectedError{Message: resp.Error}
 		}
-		return fmt.Errorf("server error: %s", resp.Error)
+		if resp.ErrorCode == protocol.ErrorCodeNoDomains {
+			// None of the subdomains was bound; the reconnect loop retries them all
+			st.publishBound(scheme, nil, st.subdomains())
+		}
+		return &ServerError{Code: resp.ErrorCode, Message: resp.Error, RetryAfter: time.Duration(resp.RetryAfter) * time.Second}
 	}
 
 	// Store bound domains
 	st.mu.Lock()
 	st.boundDomains = resp.BoundDomains
+	st.connectedOnce = true
 	st.mu.Unlock()
 
 	// Calculate latency
@@ -275,6 +437,7 @@ func (st *SharedTunnel) handleSession(ctx context.Context, conn net.Conn, connec
 	connectedData := events.ConnectedData{
 		BoundDomains: resp.BoundDomains,
 		Latency:      latency,
+		Labels:       st.Labels,
 	}
 	if resp.ServerStats != nil {
 		connectedData.BandwidthToday = resp.ServerStats.BandwidthToday
@@ -283,30 +446,70 @@ func (st *SharedTunnel) handleSession(ctx context.Context, conn net.Conn, connec
 	}
 	st.publishEvent(events.EventConnected, connectedData)
 
-	// Determine scheme (https for remote, http for local)
-	host, _, _ := net.SplitHostPort(st.ServerAddr)
-	if host == "" {
-		host = st.ServerAddr
+	// Publish TunnelReady for each subdomain -> localPort mapping
+	// This populates the Forwarding section in TUI
+	if missing := st.publishBound(scheme, resp.BoundDomains, st.subdomains()); len(missing) > 0 {
+		ctl := newControlStream(stream, handshakeTimeout)
+		for _, subdomain := range missing {
+			crash.Go(func() { st.superviseBinding(ctx, ctl, session.CloseChan(), scheme, subdomain) })
+		}
 	}
-	isLocal := host == "localhost" || host == "127.0.0.1" || host == "::1"
-	scheme := "https"
-	if isLocal {
-		scheme = "http"
+
+	heartbeatErr := watchSession(session, keepaliveOrDefault(st.Keepalive), resp.Heartbeat, statsHandler(st.publishEvent), st.onCommand, func(err error) {
+		logger.Warn("Heartbeat failed, reconnecting: %v", err)
+		st.publishStatus("heartbeat_failed", fmt.Sprintf("Connection lost: %v", err))
+	})
+
+	// Accept incoming streams
+	st.acceptStreams(session)
+
+	st.mu.Lock()
+	closed := st.closed
+	st.mu.Unlock()
+	if closed || ctx.Err() != nil {
+		return nil
 	}
+	st.publishEvent(events.EventDisconnected, nil)
+	if err := heartbeatErr(); errors.Is(err, ErrRemoteDisconnect) {
+		return err
+	} else if err != nil {
+		return fmt.Errorf("%w: %v", ErrSessionLost, err)
+	}
+	return ErrSessionLost
+}
 
-	// Publish TunnelReady for each subdomain -> localPort mapping
-	// This populates the Forwarding section in TUI
-	for subdomain, localPort := range st.Tunnels {
+// subdomains returns the configured subdomains, with or without mounts.
+func (st *SharedTunnel) subdomains() []string {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	subdomains := make([]string, 0, len(st.Tunnels)+len(st.Mounts))
+	for subdomain := range st.Tunnels {
+		subdomains = append(subdomains, subdomain)
+	}
+	for subdomain := range st.Mounts {
+		if _, ok := st.Tunnels[subdomain]; !ok {
+			subdomains = append(subdomains, subdomain)
+		}
+	}
+	sort.Strings(subdomains)
+	return subdomains
+}
+
+// boundBySubdomain maps each subdomain to the bound domains serving it.
+// Subdomains without a bound domain are absent.
+func boundBySubdomain(subdomains, boundDomains []string) map[string][]string {
+	bound := make(map[string][]string, len(subdomains))
+	for _, subdomain := range subdomains {
 		// Find matching bound domain for this subdomain
 		var boundDomainsForTunnel []string
-		for _, bd := range resp.BoundDomains {
+		for _, bd := range boundDomains {
 			if strings.HasPrefix(bd, subdomain+".") || bd == subdomain {
 				boundDomainsForTunnel = append(boundDomainsForTunnel, bd)
 			}
 		}
 		if len(boundDomainsForTunnel) == 0 {
 			// Fallback: use any bound domain that starts with subdomain
-			for _, bd := range resp.BoundDomains {
+			for _, bd := range boundDomains {
 				if strings.Contains(bd, subdomain) {
 					boundDomainsForTunnel = append(boundDomainsForTunnel, bd)
 					break
@@ -314,19 +517,41 @@ func (st *SharedTunnel) handleSession(ctx context.Context, conn net.Conn, connec
 			}
 		}
 		if len(boundDomainsForTunnel) > 0 {
+			bound[subdomain] = boundDomainsForTunnel
+		}
+	}
+	return bound
+}
+
+// publishBound publishes TunnelReady for the requested subdomains that were
+// bound and TunnelFailed for the others, which it returns.
+func (st *SharedTunnel) publishBound(scheme string, boundDomains, requested []string) (missing []string) {
+	bound := boundBySubdomain(requested, boundDomains)
+	for _, subdomain := range requested {
+		if domains, ok := bound[subdomain]; ok {
+			st.setHealthState(subdomain, HealthOnline, nil)
 			st.publishEvent(events.EventTunnelReady, events.TunnelReadyData{
 				Name:         subdomain,
-				LocalPort:    localPort,
-				BoundDomains: boundDomainsForTunnel,
+				LocalPort:    st.localPortsFor(subdomain),
+				BoundDomains: domains,
 				Scheme:       scheme,
 			})
+			continue
 		}
-	}
-
-	// Accept incoming streams
-	st.acceptStreams(session)
-
-	return nil
+		missing = append(missing, subdomain)
+		err := fmt.Errorf("subdomain '%s' was not bound by the server (not owned by this account?)", subdomain)
+		logger.Warn("Tunnel %s: %v", subdomain, err)
+		st.setHealthState(subdomain, HealthRetrying, err)
+		st.publishEvent(events.EventTunnelFailed, events.TunnelFailedData{
+			Name:      subdomain,
+			LocalPort: st.localPortsFor(subdomain),
+			Error:     err,
+		})
+	}
+	if st.onReady != nil {
+		st.onReady(scheme, bound)
+	}
+	return missing
 }
 
 // acceptStreams accepts incoming streams from the server and routes them.
@@ -344,6 +569,7 @@ func (st *SharedTunnel) acceptStreams(session *yamux.Session) {
 		st.wg.Add(1)
 		go func(s net.Conn) {
 			defer st.wg.Done()
+			defer crash.Recover()
 			st.proxyStream(s)
 		}(stream)
 	}
@@ -363,30 +589,36 @@ func (st *SharedTunnel) proxyStream(remote net.Conn) {
 	defer st.untrackConn(remote)
 
 	// Read HTTP request to determine the target port
-	reader := bufio.NewReader(remote)
-	req, err := http.ReadRequest(reader)
-	if err != nil {
+	reader, req, err := readRequest(remote)
+	if rejectRequest(remote, err) {
+		logger.Warn("Rejected request from the server: %v", err)
+		return
+	} else if err != nil {
 		// Not HTTP - can't route without Host header
 		logger.Warn("Failed to parse HTTP request for routing: %v", err)
 		return
 	}
 
-	// Extract subdomain from Host header
-	localPort := st.getLocalPortForHost(req.Host)
+	// Extract subdomain from Host header, then the mount from the path
+	localPort, rewrite := st.routeRequest(req)
 	if localPort == "" {
 		logger.Warn("No tunnel configured for host: %s", req.Host)
-		// Send 502 Bad Gateway response
-		resp := &http.Response{
-			StatusCode: http.StatusBadGateway,
-			Status:     "502 Bad Gateway",
-			Proto:      "HTTP/1.1",
-			ProtoMajor: 1,
-			ProtoMinor: 1,
-			Header:     make(http.Header),
-			Body:       io.NopCloser(strings.NewReader("No tunnel configured for this host")),
-		}
-		resp.Header.Set("Content-Type", "text/plain")
-		resp.Write(remote)
+		writeStatus(remote, http.StatusBadGateway, "No tunnel configured for this host")
+		return
+	}
+
+	if rewrite != nil {
+		rewrite.Request.apply(req.Header)
+	}
+	subdomain := st.subdomainForHost(req.Host)
+	req = inspector.WithOrigin(req, inspector.Origin{Tunnel: subdomain, LocalPort: localPort})
+
+	st.mu.Lock()
+	filters := st.Filters[subdomain]
+	st.mu.Unlock()
+
+	// Run traffic filters before the request reaches the local service
+	if runRequestFilters(filters, req, remote) {
 		return
 	}
 
@@ -408,15 +640,16 @@ func (st *SharedTunnel) proxyStream(remote net.Conn) {
 
 	// Buffer request body for inspector
 	var reqBody []byte
-	if req.Body != nil {
+	if req.Body != nil && !st.LowMemory {
+		var release func()
 		var readErr error
-		reqBody, readErr = io.ReadAll(req.Body)
+		reqBody, req.Body, release, readErr = captureBudget.capture(req.Body)
+		defer release()
 		if readErr != nil {
 			logger.Warn("Failed to read request body: %v", readErr)
-			reqBody = []byte{}
+		} else if reqBody == nil && st.stats != nil {
+			st.stats.RecordCaptureSkipped()
 		}
-		req.Body.Close()
-		req.Body = io.NopCloser(bytes.NewReader(reqBody))
 	}
 
 	// Forward request to local
@@ -431,27 +664,57 @@ func (st *SharedTunnel) proxyStream(remote net.Conn) {
 	resp, err := http.ReadResponse(respReader, req)
 	if err != nil {
 		logger.Error("Failed to read response from local: %v", err)
-		inspector.AddExchange(req, reqBody, nil, nil, time.Since(startTime))
+		st.inspector.AddExchange(req, reqBody, nil, nil, time.Since(startTime))
 		st.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "read_response"})
 		return
 	}
 	defer resp.Body.Close()
+	if rewrite != nil {
+		rewrite.Response.apply(resp.Header)
+	}
+	if !runResponseFilters(filters, req, resp, remote) {
+		return
+	}
+
+	// WebSocket and other upgrades stay open as a raw byte stream
+	if isUpgrade(resp) {
+		in, out, err := proxyUpgrade(st.inspector, local, remote, respReader, reader, req, resp)
+		if err != nil {
+			logger.Error("Failed to write response to remote: %v", err)
+			st.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "write_response"})
+			return
+		}
+		duration := time.Since(startTime)
+		if st.stats != nil {
+			st.stats.RecordRequest(duration, in+out)
+		}
+		st.publishEvent(events.EventRequestComplete, events.RequestData{
+			Method:   req.Method,
+			Path:     req.URL.Path,
+			Status:   resp.StatusCode,
+			Duration: duration,
+			Bytes:    in + out,
+		})
+		return
+	}
 
 	// Buffer response body for inspector
 	var respBody []byte
-	if resp.Body != nil {
+	if resp.Body != nil && !st.LowMemory {
+		var release func()
 		var readErr error
-		respBody, readErr = io.ReadAll(resp.Body)
+		respBody, resp.Body, release, readErr = captureBudget.capture(resp.Body)
+		defer release()
 		if readErr != nil {
 			logger.Warn("Failed to read response body: %v", readErr)
-			respBody = []byte{}
+		} else if respBody == nil && st.stats != nil {
+			st.stats.RecordCaptureSkipped()
 		}
-		resp.Body = io.NopCloser(bytes.NewReader(respBody))
 	}
 
 	// Record to inspector
 	duration := time.Since(startTime)
-	inspector.AddExchange(req, reqBody, resp, respBody, duration)
+	exchangeID := st.inspector.AddExchange(req, reqBody, resp, respBody, duration)
 
 	// Calculate total bytes
 	totalBytes := int64(len(reqBody) + len(respBody))
@@ -488,35 +751,63 @@ func (st *SharedTunnel) proxyStream(remote net.Conn) {
 	}
 
 	// Forward response back to remote
-	if err := resp.Write(remote); err != nil {
+	sent, aborted, err := writeResponse(remote, reader, resp)
+	if aborted {
+		msg := fmt.Sprintf("Client aborted %s %s after %d bytes", req.Method, req.URL.Path, sent)
+		logger.Warn("%s", msg)
+		st.inspector.MarkAborted(exchangeID, sent)
+		st.publishEvent(events.EventLog, events.LogData{Level: "warn", Message: msg})
+		return
+	}
+	if err != nil {
 		logger.Error("Failed to write response to remote: %v", err)
 		st.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "write_response"})
 		return
 	}
 }
 
-// getLocalPortForHost extracts subdomain from host and returns the local port.
-func (st *SharedTunnel) getLocalPortForHost(host string) string {
+// routeRequest returns the local port serving req, or "" if none does,
+// and the header rules of the tunnel serving it. If a mount serves it, its
+// path prefix is stripped from req unless the mount keeps it.
+func (st *SharedTunnel) routeRequest(req *http.Request) (string, *HeaderRewrite) {
+	subdomain := st.subdomainForHost(req.Host)
+	if subdomain == "" {
+		return "", nil
+	}
+	if m, ok := st.mountFor(subdomain, req.URL.Path); ok {
+		if !m.KeepPath {
+			stripMount(req, m)
+		}
+		return m.LocalPort, m.Headers
+	}
+	return st.Tunnels[subdomain], st.rewriteFor(subdomain)
+}
+
+// subdomainForHost returns the configured subdomain serving host, or "".
+func (st *SharedTunnel) subdomainForHost(host string) string {
 	// Remove port if present
 	if idx := strings.LastIndex(host, ":"); idx != -1 {
 		host = host[:idx]
 	}
 
+	subdomains := st.subdomains()
+
 	// Try exact match first (full hostname)
-	for subdomain, port := range st.Tunnels {
+	for _, subdomain := range subdomains {
 		if strings.HasPrefix(host, subdomain+".") || host == subdomain {
-			return port
+			return subdomain
 		}
 	}
 
 	// Extract subdomain (first part before first dot)
-	subdomain := host
+	first := host
 	if idx := strings.Index(host, "."); idx != -1 {
-		subdomain = host[:idx]
+		first = host[:idx]
 	}
-
-	if port, ok := st.Tunnels[subdomain]; ok {
-		return port
+	for _, subdomain := range subdomains {
+		if subdomain == first {
+			return subdomain
+		}
 	}
 
 	return ""
@@ -530,6 +821,7 @@ func (st *SharedTunnel) StartWithReconnect(ctx context.Context, config *Reconnec
 
 	attempt := 0
 	delay := config.InitialDelay
+	renewed := false // The token was renewed since the last session
 
 	for {
 		select {
@@ -553,24 +845,55 @@ func (st *SharedTunnel) StartWithReconnect(ctx context.Context, config *Reconnec
 			return ctx.Err()
 		}
 
+		if !renewed {
+			token, renewErr := renewToken(ctx, st.Refresher, err)
+			if renewErr == nil {
+				logger.Info("Token expired, renewed it")
+				st.Token = token
+				renewed = true
+				attempt = 0
+				continue
+			}
+			err = renewErr
+		}
+
+		if errors.Is(err, ErrRemoteDisconnect) {
+			st.publishStatus("stopped", "Disconnected from the dashboard")
+			return err
+		}
 		if IsAlreadyConnectedError(err) {
 			logger.Error("Session conflict: %v", err)
 			st.publishStatus("error", fmt.Sprintf("Session conflict: %v", err))
 			return err
 		}
+		if isPermanent(err) {
+			logger.Error("Connection refused: %v", err)
+			return err
+		}
 
+		if errors.Is(err, ErrSessionLost) {
+			// The session was up: start the backoff over
+			attempt = 0
+			delay = config.InitialDelay
+			renewed = false
+		}
+		wait := max(config.jittered(delay), retryAfter(err))
 		logger.Error("Connection failed: %v", err)
-		st.publishStatus("reconnecting", fmt.Sprintf("Connection failed, retrying in %v...", delay))
+		st.publishStatus("reconnecting", fmt.Sprintf("Connection failed, retrying in %v...", wait.Round(time.Millisecond)))
 
-		if config.MaxAttempts > 0 && attempt >= config.MaxAttempts {
-			return fmt.Errorf("max reconnection attempts (%d) reached: %v", config.MaxAttempts, err)
+		st.mu.Lock()
+		connectedOnce := st.connectedOnce
+		st.mu.Unlock()
+		if config.MaxAttempts > 0 && attempt >= config.MaxAttempts && (!config.OfflineStart || connectedOnce) {
+			return fmt.Errorf("max reconnection attempts (%d) reached: %w", config.MaxAttempts, err)
 		}
+		st.publishEvent(events.EventReconnecting, events.ReconnectingData{Attempt: attempt, Delay: wait, Error: err})
 
 		select {
 		case <-ctx.Done():
 			logger.Info("Tunnel shutdown requested during reconnect wait")
 			return ctx.Err()
-		case <-time.After(delay):
+		case <-time.After(wait):
 		}
 
 		delay = time.Duration(float64(delay) * config.Multiplier)
@@ -580,6 +903,19 @@ func (st *SharedTunnel) StartWithReconnect(ctx context.Context, config *Reconnec
 	}
 }
 
+// Reconnect drops the current session, so that StartWithReconnect
+// connects again as if the connection had been lost.
+func (st *SharedTunnel) Reconnect() error {
+	st.mu.Lock()
+	session := st.session
+	st.mu.Unlock()
+	if session == nil {
+		return ErrNotConnected
+	}
+	logger.Info("Reconnect requested, dropping the session")
+	return session.Close()
+}
+
 // Shutdown gracefully shuts down the tunnel.
 func (st *SharedTunnel) Shutdown(ctx context.Context) error {
 	st.mu.Lock()
@@ -0,0 +1,205 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"gopublic/internal/client/events"
+	"gopublic/pkg/protocol"
+)
+
+func TestBoundBySubdomain(t *testing.T) {
+	bound := boundBySubdomain(
+		[]string{"misty-river", "silent-star", "taken"},
+		[]string{"misty-river.example.com", "silent-star.example.com"},
+	)
+	want := map[string][]string{
+		"misty-river": {"misty-river.example.com"},
+		"silent-star": {"silent-star.example.com"},
+	}
+	if !reflect.DeepEqual(bound, want) {
+		t.Errorf("boundBySubdomain() = %v, want %v", bound, want)
+	}
+}
+
+func TestSharedTunnel_BasicAuthFor(t *testing.T) {
+	st := NewSharedTunnel("localhost:4443", "token", map[string]string{"misty-river": "3000", "silent-star": "8080"})
+	if got := st.basicAuthFor([]string{"misty-river"}); got != nil {
+		t.Errorf("basicAuthFor() without credentials = %v, want nil", got)
+	}
+
+	st.SetBasicAuth("misty-river", "demo:s3cret")
+	got := st.basicAuthFor([]string{"misty-river", "silent-star"})
+	want := map[string]string{"misty-river": "demo:s3cret"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("basicAuthFor() = %v, want %v", got, want)
+	}
+}
+
+func TestSharedTunnel_PublishBound(t *testing.T) {
+	bus := events.NewBus()
+	defer bus.Close()
+	ch := bus.Subscribe()
+
+	st := NewSharedTunnel("localhost:4443", "token", map[string]string{
+		"misty-river": "3000",
+		"taken":       "8080",
+	})
+	st.SetEventBus(bus)
+	var ready map[string][]string
+	st.onReady = func(scheme string, bound map[string][]string) { ready = bound }
+
+	missing := st.publishBound("http", []string{"misty-river.example.com"}, st.subdomains())
+	if !reflect.DeepEqual(missing, []string{"taken"}) {
+		t.Fatalf("missing = %v, want [taken]", missing)
+	}
+	if _, ok := ready["misty-river"]; !ok || len(ready) != 1 {
+		t.Errorf("onReady got %v", ready)
+	}
+
+	got := map[events.EventType]string{}
+	for i := 0; i < 2; i++ {
+		select {
+		case event := <-ch:
+			switch data := event.Data.(type) {
+			case events.TunnelReadyData:
+				got[event.Type] = data.LocalPort
+			case events.TunnelFailedData:
+				got[event.Type] = data.LocalPort
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timed out waiting for events")
+		}
+	}
+	if got[events.EventTunnelReady] != "3000" || got[events.EventTunnelFailed] != "8080" {
+		t.Errorf("events = %v", got)
+	}
+}
+
+func TestSharedTunnel_SuperviseBinding(t *testing.T) {
+	saved := rebindRetry
+	rebindRetry = ReconnectConfig{InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, Multiplier: 1}
+	defer func() { rebindRetry = saved }()
+
+	st := NewSharedTunnel("localhost:4443", "token", map[string]string{
+		"misty-river": "3000",
+		"taken":       "8080",
+		"other":       "9090",
+	})
+	readyCh := make(chan map[string][]string, 1)
+	st.onReady = func(scheme string, bound map[string][]string) { readyCh <- bound }
+
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	// The server binds "taken" on its third request and never binds "other"
+	go func() {
+		dec := json.NewDecoder(server)
+		enc := json.NewEncoder(server)
+		takenAttempts := 0
+		for {
+			var req protocol.TunnelRequest
+			if dec.Decode(&req) != nil {
+				return
+			}
+			if len(req.RequestedDomains) == 1 && req.RequestedDomains[0] == "taken" {
+				if takenAttempts++; takenAttempts == 3 {
+					enc.Encode(protocol.InitResponse{Success: true, BoundDomains: []string{"taken.example.com"}})
+					continue
+				}
+			}
+			enc.Encode(protocol.InitResponse{Success: false, Error: "No valid domains requested or authorized", ErrorCode: protocol.ErrorCodeNoDomains})
+		}
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	sessionDone := make(chan struct{})
+	defer close(sessionDone)
+	defer cancel()
+	ctl := newControlStream(client, time.Second)
+	takenDone := make(chan struct{})
+	go func() {
+		st.superviseBinding(ctx, ctl, sessionDone, "http", "taken")
+		close(takenDone)
+	}()
+	otherDone := make(chan struct{})
+	go func() {
+		st.superviseBinding(ctx, ctl, sessionDone, "http", "other")
+		close(otherDone)
+	}()
+
+	select {
+	case bound := <-readyCh:
+		if !reflect.DeepEqual(bound, map[string][]string{"taken": {"taken.example.com"}}) {
+			t.Errorf("onReady got %v", bound)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subdomain was not bound after retries")
+	}
+	select {
+	case <-takenDone:
+	case <-time.After(time.Second):
+		t.Fatal("supervisor did not return once its subdomain was bound")
+	}
+	if got := st.BoundDomains(); !reflect.DeepEqual(got, []string{"taken.example.com"}) {
+		t.Errorf("BoundDomains() = %v", got)
+	}
+
+	health := map[string]TunnelHealth{}
+	for _, h := range st.Health() {
+		health[h.Subdomain] = h
+	}
+	if h := health["taken"]; h.State != HealthOnline || h.Attempts != 0 {
+		t.Errorf("taken health = %+v", h)
+	}
+	if h := health["other"]; h.Attempts == 0 || h.LastError == "" {
+		t.Errorf("other health = %+v, want failed attempts", h)
+	}
+	if h := health["misty-river"]; h.State != HealthConnecting {
+		t.Errorf("misty-river health = %+v", h)
+	}
+
+	// Stop the other supervisor before rebindRetry is restored
+	cancel()
+	<-otherDone
+}
+
+func TestSharedTunnel_SuperviseBinding_SessionClosed(t *testing.T) {
+	st := NewSharedTunnel("localhost:4443", "token", map[string]string{"taken": "8080"})
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	sessionDone := make(chan struct{})
+	close(sessionDone)
+	done := make(chan struct{})
+	go func() {
+		st.superviseBinding(context.Background(), newControlStream(client, time.Second), sessionDone, "http", "taken")
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("supervisor did not stop with the session")
+	}
+}
+
+func TestControlStream_Unanswered(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	server.Close() // A server that never answers
+
+	ctl := newControlStream(client, time.Second)
+	if _, err := ctl.bind([]string{"taken"}, nil, nil); err == nil {
+		t.Fatal("bind() = nil, want error")
+	}
+	if _, err := ctl.bind([]string{"taken"}, nil, nil); !errors.Is(err, errControlStream) {
+		t.Errorf("bind() after failure = %v, want errControlStream", err)
+	}
+}
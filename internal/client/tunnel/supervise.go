@@ -0,0 +1,187 @@
+package tunnel
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"gopublic/internal/client/logger"
+	"gopublic/pkg/protocol"
+)
+
+// The server allows a single session per user and agent name, so all
+// tunnels share one session and its reconnect loop. Bindings are
+// supervised individually on top of it: a subdomain the server refuses is
+// retried on the control stream with its own backoff, without touching the
+// other tunnels.
+
+// Tunnel health states.
+const (
+	HealthConnecting = "connecting" // Waiting for the session handshake
+	HealthOnline     = "online"     // Bound and serving requests
+	HealthRetrying   = "retrying"   // Refused by the server, retried in the background
+)
+
+// TunnelHealth is the binding state of one configured tunnel.
+type TunnelHealth struct {
+	Name      string // Tunnel name from gopublic.yaml (the subdomain for SharedTunnel)
+	Subdomain string
+	State     string
+	Attempts  int       // Failed bind attempts since the tunnel was last online
+	LastError string    // Most recent bind error
+	NextRetry time.Time // Zero unless a retry is scheduled
+	Since     time.Time // When State last changed
+}
+
+// rebindRetry paces the background retries of subdomains the server refused.
+var rebindRetry = ReconnectConfig{
+	InitialDelay: 10 * time.Second,
+	MaxDelay:     5 * time.Minute,
+	Multiplier:   2.0,
+}
+
+var errControlStream = errors.New("control stream unusable until reconnect")
+
+// controlStream serializes the late bind requests of the binding
+// supervisors on the handshake stream.
+type controlStream struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	dec     *json.Decoder
+	timeout time.Duration // For each request and its answer
+	broken  bool
+}
+
+func newControlStream(conn net.Conn, timeout time.Duration) *controlStream {
+	return &controlStream{conn: conn, dec: json.NewDecoder(conn), timeout: timeout}
+}
+
+// bind asks the server to bind subdomains. A stream that failed once is not
+// reused: its message framing is lost, e.g. when a server without late
+// binding leaves the request unanswered.
+func (c *controlStream) bind(subdomains []string, basicAuth map[string]string, rateLimit map[string]protocol.RateLimit) (*protocol.InitResponse, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.broken {
+		return nil, errControlStream
+	}
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	defer c.conn.SetDeadline(time.Time{})
+
+	if err := json.NewEncoder(c.conn).Encode(protocol.TunnelRequest{RequestedDomains: subdomains, BasicAuth: basicAuth, RateLimit: rateLimit}); err != nil {
+		c.broken = true
+		return nil, handshakeError("requesting a late bind", c.timeout, err)
+	}
+	var resp protocol.InitResponse
+	if err := c.dec.Decode(&resp); err != nil {
+		c.broken = true
+		return nil, handshakeError("waiting for a late bind response", c.timeout, err)
+	}
+	return &resp, nil
+}
+
+// superviseBinding retries a refused subdomain with its own backoff until
+// it is bound or the session ends.
+func (st *SharedTunnel) superviseBinding(ctx context.Context, ctl *controlStream, sessionDone <-chan struct{}, scheme, subdomain string) {
+	delay := rebindRetry.InitialDelay
+	for attempt := 1; ; attempt++ {
+		st.updateHealth(subdomain, func(h *TunnelHealth) {
+			h.NextRetry = time.Now().Add(delay)
+		})
+		select {
+		case <-ctx.Done():
+			return
+		case <-sessionDone:
+			return
+		case <-time.After(delay):
+		}
+		delay = min(time.Duration(float64(delay)*rebindRetry.Multiplier), rebindRetry.MaxDelay)
+
+		resp, err := ctl.bind([]string{subdomain}, st.basicAuthFor([]string{subdomain}), st.rateLimitFor([]string{subdomain}))
+		if err != nil {
+			logger.Warn("Retrying tunnel %s stopped until reconnect: %v", subdomain, err)
+			st.updateHealth(subdomain, func(h *TunnelHealth) {
+				h.Attempts++
+				h.LastError = err.Error()
+				h.NextRetry = time.Time{}
+			})
+			return
+		}
+		if _, ok := boundBySubdomain([]string{subdomain}, resp.BoundDomains)[subdomain]; !resp.Success || !ok {
+			msg := resp.Error
+			if msg == "" {
+				msg = fmt.Sprintf("subdomain '%s' was not bound by the server", subdomain)
+			}
+			st.updateHealth(subdomain, func(h *TunnelHealth) {
+				h.Attempts++
+				h.LastError = msg
+			})
+			continue
+		}
+
+		st.mu.Lock()
+		st.boundDomains = append(st.boundDomains, resp.BoundDomains...)
+		st.mu.Unlock()
+		logger.Info("Tunnel %s bound after %d retries: %v", subdomain, attempt, resp.BoundDomains)
+		st.publishBound(scheme, resp.BoundDomains, []string{subdomain})
+		return
+	}
+}
+
+// setHealthState moves a tunnel to state. Going online clears its errors.
+func (st *SharedTunnel) setHealthState(subdomain, state string, err error) {
+	st.updateHealth(subdomain, func(h *TunnelHealth) {
+		if h.State != state {
+			h.State = state
+			h.Since = time.Now()
+		}
+		h.NextRetry = time.Time{}
+		switch {
+		case state == HealthOnline:
+			h.Attempts = 0
+			h.LastError = ""
+		case err != nil:
+			h.Attempts++
+			h.LastError = err.Error()
+		}
+	})
+}
+
+func (st *SharedTunnel) updateHealth(subdomain string, update func(h *TunnelHealth)) {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	if st.health == nil {
+		st.health = make(map[string]*TunnelHealth)
+	}
+	h, ok := st.health[subdomain]
+	if !ok {
+		h = &TunnelHealth{Name: subdomain, Subdomain: subdomain, State: HealthConnecting, Since: time.Now()}
+		st.health[subdomain] = h
+	}
+	update(h)
+}
+
+// Health returns the binding state of every configured subdomain, ordered
+// by subdomain.
+func (st *SharedTunnel) Health() []TunnelHealth {
+	subdomains := st.subdomains()
+
+	st.mu.Lock()
+	defer st.mu.Unlock()
+
+	health := make([]TunnelHealth, 0, len(subdomains))
+	for _, subdomain := range subdomains {
+		if h, ok := st.health[subdomain]; ok {
+			health = append(health, *h)
+		} else {
+			health = append(health, TunnelHealth{Name: subdomain, Subdomain: subdomain, State: HealthConnecting})
+		}
+	}
+	return health
+}
@@ -0,0 +1,61 @@
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"gopublic/internal/client/events"
+)
+
+// HandshakeTimeoutError is returned when the server does not take or
+// answer a handshake or control message within
+// KeepaliveConfig.HandshakeTimeout, e.g. because it stalled or a
+// middlebox swallows the connection. Reconnect loops retry it.
+type HandshakeTimeoutError struct {
+	Stage   string // What the client was doing, e.g. "waiting for the tunnel response"
+	Timeout time.Duration
+}
+
+func (e *HandshakeTimeoutError) Error() string {
+	return fmt.Sprintf("server did not respond within %s while %s", e.Timeout, e.Stage)
+}
+
+// IsHandshakeTimeout reports whether err is a HandshakeTimeoutError.
+func IsHandshakeTimeout(err error) bool {
+	var tErr *HandshakeTimeoutError
+	return errors.As(err, &tErr)
+}
+
+// handshakeError returns a HandshakeTimeoutError for stage if err is a
+// deadline expiry, and err otherwise.
+func handshakeError(stage string, timeout time.Duration, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return &HandshakeTimeoutError{Stage: stage, Timeout: timeout}
+	}
+	return err
+}
+
+// publishHandshakeError reports a failed handshake step; timeouts are also
+// published as EventError so that the TUI shows them.
+func (t *Tunnel) publishHandshakeError(what string, err error) {
+	if IsHandshakeTimeout(err) {
+		t.publishStatus("timeout", fmt.Sprintf("%s: %v", what, err))
+		t.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "handshake"})
+		return
+	}
+	t.publishStatus("error", fmt.Sprintf("%s: %v", what, err))
+}
+
+// publishHandshakeError reports a failed handshake step; timeouts are also
+// published as EventError so that the TUI shows them.
+func (st *SharedTunnel) publishHandshakeError(what string, err error) {
+	if IsHandshakeTimeout(err) {
+		st.publishStatus("timeout", fmt.Sprintf("%s: %v", what, err))
+		st.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "handshake"})
+		return
+	}
+	st.publishStatus("error", fmt.Sprintf("%s: %v", what, err))
+}
@@ -0,0 +1,85 @@
+package tunnel
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrPinMismatch is returned when the server presents a key other than
+// the one pinned with "gopublic trust".
+var ErrPinMismatch = errors.New("server certificate does not match the pinned key")
+
+// TLSConfig holds TLS configuration options. The zero value verifies the
+// server certificate against the system roots.
+type TLSConfig struct {
+	// InsecureSkipVerify accepts any certificate and falls back to plain
+	// TCP if the TLS handshake fails. For development only.
+	InsecureSkipVerify bool
+	ServerName         string
+
+	RootCAs *x509.CertPool // Trusted roots; nil = system roots
+	// Pin is the SHA-256 of the server's public key, see KeyPin. When set
+	// it replaces chain verification, so self-signed servers work.
+	Pin string
+}
+
+// KeyPin returns the pin of cert: the hex SHA-256 of its public key
+// (SubjectPublicKeyInfo), which survives renewals with the same key.
+func KeyPin(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return hex.EncodeToString(sum[:])
+}
+
+// LoadCAFile returns the system roots plus the PEM certificates in path.
+func LoadCAFile(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates in %s", path)
+	}
+	return pool, nil
+}
+
+// ClientConfig returns the tls.Config to dial the server with, and whether
+// a failed handshake may fall back to plain TCP. Only insecure configs
+// fall back: otherwise an attacker could strip TLS by failing it.
+func (c *TLSConfig) ClientConfig() (*tls.Config, bool) {
+	if c == nil {
+		c = &TLSConfig{}
+	}
+	// An empty ServerName is taken from the dialed address
+	cfg := &tls.Config{ServerName: c.ServerName, RootCAs: c.RootCAs}
+	if c.Pin != "" {
+		pin := strings.ToLower(c.Pin)
+		// The pin is checked instead of the chain
+		cfg.InsecureSkipVerify = true
+		cfg.VerifyConnection = func(state tls.ConnectionState) error {
+			if len(state.PeerCertificates) == 0 || KeyPin(state.PeerCertificates[0]) != pin {
+				return ErrPinMismatch
+			}
+			return nil
+		}
+		return cfg, false
+	}
+	cfg.InsecureSkipVerify = c.InsecureSkipVerify
+	return cfg, c.InsecureSkipVerify
+}
+
+// IsUntrustedCert reports whether err is a failed verification of the
+// server certificate, which reconnecting cannot fix; see "gopublic trust".
+func IsUntrustedCert(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	return errors.Is(err, ErrPinMismatch) || errors.As(err, &verifyErr)
+}
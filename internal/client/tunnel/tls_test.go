@@ -0,0 +1,69 @@
+package tunnel
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestTLSConfig_ClientConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(nil)
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+	pin := KeyPin(srv.Certificate())
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatal(err)
+	}
+	pool, err := LoadCAFile(caFile)
+	if err != nil {
+		t.Fatalf("LoadCAFile() error = %v", err)
+	}
+
+	tests := []struct {
+		name          string
+		cfg           *TLSConfig
+		wantErr       bool
+		wantUntrusted bool
+		wantPlain     bool
+	}{
+		{"default verifies", nil, true, true, false},
+		{"ca cert", &TLSConfig{RootCAs: pool, ServerName: "example.com"}, false, false, false},
+		{"pinned", &TLSConfig{Pin: pin}, false, false, false},
+		{"pin mismatch", &TLSConfig{Pin: "00" + pin[2:]}, true, true, false},
+		{"insecure", &TLSConfig{InsecureSkipVerify: true}, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, allowPlain := tt.cfg.ClientConfig()
+			if allowPlain != tt.wantPlain {
+				t.Errorf("allowPlain = %v, want %v", allowPlain, tt.wantPlain)
+			}
+			conn, err := tls.Dial("tcp", addr, cfg)
+			if err == nil {
+				conn.Close()
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Dial() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := IsUntrustedCert(err); got != tt.wantUntrusted {
+				t.Errorf("IsUntrustedCert(%v) = %v, want %v", err, got, tt.wantUntrusted)
+			}
+		})
+	}
+}
+
+func TestLoadCAFile_NoCertificates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCAFile(path); err == nil {
+		t.Error("LoadCAFile() accepted a file without certificates")
+	}
+}
@@ -2,7 +2,6 @@ package tunnel
 
 import (
 	"bufio"
-	"bytes"
 	"context"
 	"crypto/tls"
 	"encoding/json"
@@ -11,25 +10,25 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"os"
+	"runtime"
 	"strings"
 	"sync"
 	"time"
 
+	"gopublic/internal/bufpool"
+	"gopublic/internal/client/crash"
 	"gopublic/internal/client/events"
 	"gopublic/internal/client/inspector"
 	"gopublic/internal/client/logger"
 	"gopublic/internal/client/stats"
+	"gopublic/internal/filter"
+	"gopublic/internal/version"
 	"gopublic/pkg/protocol"
 
 	"github.com/hashicorp/yamux"
 )
 
-// TLSConfig holds TLS configuration options.
-type TLSConfig struct {
-	InsecureSkipVerify bool
-	ServerName         string
-}
-
 // Tunnel represents a connection to the gopublic server.
 type Tunnel struct {
 	ServerAddr string
@@ -38,13 +37,40 @@ type Tunnel struct {
 	Subdomain  string // Specific subdomain to bind (empty = bind all)
 	Force      bool   // Force disconnect existing session
 	NoCache    bool   // Add Cache-Control: no-store to responses
+	LowMemory  bool   // Stream bodies without capture, smaller yamux buffers
+	Proto      string // protocol.ProtoTCP forwards raw TCP on a server-assigned port; HTTP otherwise
+
+	// Labels sent to the server to identify this tunnel (e.g. env=staging)
+	Labels map[string]string
+
+	// Agent names this client in the dashboard fleet view; sessions of
+	// different agents coexist. Empty for the regular session.
+	Agent string
+
+	// BasicAuth is "user:pass" visitors must present; empty leaves the
+	// tunnel public
+	BasicAuth string
+
+	// RateLimit throttles requests to the tunnel at the ingress; zero RPS
+	// leaves it to the server limit
+	RateLimit protocol.RateLimit
+
+	// Traffic filters applied to every request (optional)
+	Filters filter.Chain
 
 	// TLS configuration
 	TLSConfig *TLSConfig
 
+	// Keepalive detects dead connections; nil = DefaultKeepaliveConfig
+	Keepalive *KeepaliveConfig
+
+	// Refresher renews an expired Token; nil = stop when it expires
+	Refresher TokenRefresher
+
 	// Dependencies (optional, for integration with TUI)
-	eventBus *events.Bus
-	stats    *stats.Stats
+	eventBus  *events.Bus
+	stats     *stats.Stats
+	inspector *inspector.Server // Records exchanges; nil records nothing
 
 	// Internal state for graceful shutdown
 	mu          sync.Mutex
@@ -54,7 +80,8 @@ type Tunnel struct {
 	closed      bool
 
 	// Cached connection info
-	boundDomains []string
+	boundDomains  []string
+	connectedOnce bool // A handshake has succeeded, see ReconnectConfig.OfflineStart
 }
 
 // NewTunnel creates a new tunnel instance.
@@ -77,11 +104,31 @@ func (t *Tunnel) SetStats(s *stats.Stats) {
 	t.stats = s
 }
 
+// SetInspector sets the inspector that records the tunnel's exchanges.
+func (t *Tunnel) SetInspector(s *inspector.Server) {
+	t.inspector = s
+}
+
 // SetTLSConfig sets the TLS configuration.
 func (t *Tunnel) SetTLSConfig(cfg *TLSConfig) {
 	t.TLSConfig = cfg
 }
 
+// SetKeepalive sets how fast a dead connection is detected.
+func (t *Tunnel) SetKeepalive(cfg *KeepaliveConfig) {
+	t.Keepalive = cfg
+}
+
+// SetTokenRefresher sets how an expired token is renewed.
+func (t *Tunnel) SetTokenRefresher(refresh TokenRefresher) {
+	t.Refresher = refresh
+}
+
+// SetAgent sets the agent name sent with the auth request.
+func (t *Tunnel) SetAgent(agent string) {
+	t.Agent = agent
+}
+
 // SetForce sets the force flag to disconnect existing session.
 func (t *Tunnel) SetForce(force bool) {
 	t.Force = force
@@ -92,6 +139,44 @@ func (t *Tunnel) SetNoCache(noCache bool) {
 	t.NoCache = noCache
 }
 
+// SetLowMemory enables the low-memory profile: request and response bodies
+// are streamed instead of buffered for the inspector, and yamux buffers
+// are reduced.
+func (t *Tunnel) SetLowMemory(lowMemory bool) {
+	t.LowMemory = lowMemory
+}
+
+// SetProto sets the tunnel protocol, protocol.ProtoHTTP or protocol.ProtoTCP.
+func (t *Tunnel) SetProto(proto string) {
+	t.Proto = proto
+}
+
+// SetSubdomain restricts the tunnel to one of the user's domains; empty
+// binds all of them.
+func (t *Tunnel) SetSubdomain(subdomain string) {
+	t.Subdomain = subdomain
+}
+
+// SetLabels sets the labels sent to the server with the tunnel request.
+func (t *Tunnel) SetLabels(labels map[string]string) {
+	t.Labels = labels
+}
+
+// SetBasicAuth protects the tunnel with "user:pass" credentials.
+func (t *Tunnel) SetBasicAuth(creds string) {
+	t.BasicAuth = creds
+}
+
+// SetRateLimit asks the ingress to throttle requests to the tunnel.
+func (t *Tunnel) SetRateLimit(limit protocol.RateLimit) {
+	t.RateLimit = limit
+}
+
+// SetFilters sets the traffic filters applied to every request.
+func (t *Tunnel) SetFilters(chain filter.Chain) {
+	t.Filters = chain
+}
+
 // BoundDomains returns the domains bound to this tunnel.
 func (t *Tunnel) BoundDomains() []string {
 	t.mu.Lock()
@@ -106,6 +191,12 @@ func (t *Tunnel) publishEvent(eventType events.EventType, data interface{}) {
 	}
 }
 
+// onCommand publishes a remote command received with a heartbeat.
+func (t *Tunnel) onCommand(command string) {
+	logger.Info("Remote command from the dashboard: %s", command)
+	t.publishEvent(events.EventRemoteCommand, events.RemoteCommandData{Command: command})
+}
+
 // publishStatus publishes a connection status event.
 func (t *Tunnel) publishStatus(stage, message string) {
 	t.publishEvent(events.EventConnectionStatus, events.ConnectionStatusData{
@@ -154,26 +245,21 @@ func (t *Tunnel) Start() error {
 		if err != nil {
 			t.publishStatus("error", fmt.Sprintf("Connection failed: %v", err))
 			t.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "connect"})
-			return fmt.Errorf("failed to connect to local server: %v", err)
+			return fmt.Errorf("failed to connect to local server: %w", err)
 		}
 		return t.handleSession(conn, connectStart)
 	}
 
-	// Build TLS config
-	tlsConfig := &tls.Config{}
-	if t.TLSConfig != nil {
-		tlsConfig.InsecureSkipVerify = t.TLSConfig.InsecureSkipVerify
-		if t.TLSConfig.ServerName != "" {
-			tlsConfig.ServerName = t.TLSConfig.ServerName
-		}
-	} else {
-		// Default: insecure for backward compatibility (TODO: make secure by default)
-		tlsConfig.InsecureSkipVerify = true
-	}
+	tlsConfig, allowPlain := t.TLSConfig.ClientConfig()
 
 	t.publishStatus("dialing", fmt.Sprintf("Connecting to %s (TLS)...", t.ServerAddr))
 	dialer := &net.Dialer{Timeout: dialTimeout}
 	conn, err := tls.DialWithDialer(dialer, "tcp", t.ServerAddr, tlsConfig)
+	if err != nil && !allowPlain {
+		t.publishStatus("error", fmt.Sprintf("TLS connection failed: %v", err))
+		t.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "connect"})
+		return fmt.Errorf("failed to connect: %w", err)
+	}
 	if err != nil {
 		t.publishStatus("tls_fallback", fmt.Sprintf("TLS failed: %v, trying plain TCP...", err))
 		logger.Warn("TLS connection failed, trying plain TCP: %v", err)
@@ -181,7 +267,7 @@ func (t *Tunnel) Start() error {
 		if errPlain != nil {
 			t.publishStatus("error", fmt.Sprintf("Connection failed: %v", errPlain))
 			t.publishEvent(events.EventError, events.ErrorData{Error: errPlain, Context: "connect"})
-			return fmt.Errorf("failed to connect: %v", errPlain)
+			return fmt.Errorf("failed to connect: %w", errPlain)
 		}
 		return t.handleSession(connPlain, connectStart)
 	}
@@ -202,7 +288,7 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 
 	// Start Yamux Client
 	t.publishStatus("yamux_init", "Initializing multiplexed connection...")
-	session, err := yamux.Client(conn, nil)
+	session, err := yamux.Client(conn, yamuxConfig(t.LowMemory, t.Keepalive))
 	if err != nil {
 		t.publishStatus("error", fmt.Sprintf("Failed to init yamux: %v", err))
 		return fmt.Errorf("failed to start yamux: %v", err)
@@ -229,14 +315,15 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 	}
 
 	// Set write deadline for handshake operations
-	handshakeTimeout := 5 * time.Second
+	handshakeTimeout := keepaliveOrDefault(t.Keepalive).handshakeTimeout()
 	stream.SetWriteDeadline(time.Now().Add(handshakeTimeout))
 
 	// Auth
 	t.publishStatus("authenticating", "Authenticating with server...")
-	authReq := protocol.AuthRequest{Token: t.Token, Force: t.Force}
+	authReq := protocol.AuthRequest{Token: t.Token, Force: t.Force, Device: deviceName(), Agent: t.Agent, Version: version.Version, Commands: true}
 	if err := json.NewEncoder(stream).Encode(authReq); err != nil {
-		t.publishStatus("error", fmt.Sprintf("Failed to send auth: %v", err))
+		err = handshakeError("sending the token", handshakeTimeout, err)
+		t.publishHandshakeError("Failed to send auth", err)
 		return err
 	}
 
@@ -246,9 +333,16 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 	if t.Subdomain != "" {
 		requestedDomains = []string{t.Subdomain}
 	}
-	tunnelReq := protocol.TunnelRequest{RequestedDomains: requestedDomains}
+	tunnelReq := protocol.TunnelRequest{RequestedDomains: requestedDomains, Labels: t.Labels, Proto: t.Proto}
+	if t.BasicAuth != "" {
+		tunnelReq.BasicAuth = map[string]string{protocol.AllDomains: t.BasicAuth}
+	}
+	if t.RateLimit.RPS > 0 {
+		tunnelReq.RateLimit = map[string]protocol.RateLimit{protocol.AllDomains: t.RateLimit}
+	}
 	if err := json.NewEncoder(stream).Encode(tunnelReq); err != nil {
-		t.publishStatus("error", fmt.Sprintf("Failed to request tunnel: %v", err))
+		err = handshakeError("requesting the tunnel", handshakeTimeout, err)
+		t.publishHandshakeError("Failed to request tunnel", err)
 		return err
 	}
 	// Clear write deadline
@@ -259,8 +353,9 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 	stream.SetReadDeadline(time.Now().Add(handshakeTimeout))
 	var resp protocol.InitResponse
 	if err := json.NewDecoder(stream).Decode(&resp); err != nil {
-		t.publishStatus("error", fmt.Sprintf("Failed to read response: %v", err))
-		return fmt.Errorf("handshake read failed: %v", err)
+		err = handshakeError("waiting for the tunnel response", handshakeTimeout, err)
+		t.publishHandshakeError("Failed to read response", err)
+		return fmt.Errorf("handshake read failed: %w", err)
 	}
 	// Clear deadline for normal operation
 	stream.SetReadDeadline(time.Time{})
@@ -272,7 +367,7 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 			return &AlreadyConnectedError{Message: resp.Error}
 		}
 		t.publishStatus("error", fmt.Sprintf("Server error: %s", resp.Error))
-		return fmt.Errorf("server error: %s", resp.Error)
+		return &ServerError{Code: resp.ErrorCode, Message: resp.Error, RetryAfter: time.Duration(resp.RetryAfter) * time.Second}
 	}
 
 	// Calculate latency and record stats
@@ -284,11 +379,14 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 	// Cache bound domains
 	t.mu.Lock()
 	t.boundDomains = resp.BoundDomains
+	t.connectedOnce = true
 	t.mu.Unlock()
 
 	// Determine scheme for display
 	scheme := "https"
-	if strings.Contains(t.ServerAddr, "localhost") || strings.Contains(t.ServerAddr, "127.0.0.1") {
+	if t.Proto == protocol.ProtoTCP {
+		scheme = "tcp"
+	} else if strings.Contains(t.ServerAddr, "localhost") || strings.Contains(t.ServerAddr, "127.0.0.1") {
 		scheme = "http"
 	}
 
@@ -297,6 +395,7 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 		ServerAddr:   t.ServerAddr,
 		BoundDomains: resp.BoundDomains,
 		Latency:      latency,
+		Labels:       t.Labels,
 	}
 	if resp.ServerStats != nil {
 		connData.BandwidthToday = resp.ServerStats.BandwidthToday
@@ -316,6 +415,11 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 
 	stream.Close() // Handshake done
 
+	heartbeatErr := watchSession(session, keepaliveOrDefault(t.Keepalive), resp.Heartbeat, statsHandler(t.publishEvent), t.onCommand, func(err error) {
+		logger.Warn("Heartbeat failed, reconnecting: %v", err)
+		t.publishStatus("heartbeat_failed", fmt.Sprintf("Connection lost: %v", err))
+	})
+
 	// Accept Streams with proper tracking
 	for {
 		stream, err := session.Accept()
@@ -330,13 +434,19 @@ func (t *Tunnel) handleSession(conn net.Conn, connectStart time.Time) error {
 				return nil
 			}
 			t.publishEvent(events.EventDisconnected, nil)
-			return fmt.Errorf("session ended: %v", err)
+			if hbErr := heartbeatErr(); errors.Is(hbErr, ErrRemoteDisconnect) {
+				return hbErr
+			} else if hbErr != nil {
+				err = hbErr
+			}
+			return fmt.Errorf("%w: %v", ErrSessionLost, err)
 		}
 
 		// Track goroutine to prevent leaks
 		t.wg.Add(1)
 		go func(s net.Conn) {
 			defer t.wg.Done()
+			defer crash.Recover()
 			t.proxyStream(s)
 		}(stream)
 	}
@@ -366,14 +476,28 @@ func (t *Tunnel) proxyStream(remote net.Conn) {
 	}
 	defer local.Close()
 
+	// Raw TCP services may speak first (SSH, MySQL), so don't wait for a request
+	if t.Proto == protocol.ProtoTCP {
+		t.copyBidirectional(local, remote)
+		return
+	}
+
 	// To support Inspector, we parse the HTTP request
-	reader := bufio.NewReader(remote)
-	req, err := http.ReadRequest(reader)
-	if err != nil {
+	reader, req, err := readRequest(remote)
+	if rejectRequest(remote, err) {
+		logger.Warn("Rejected request from the server: %v", err)
+		return
+	} else if err != nil {
 		// Not a valid HTTP request or error? Just copy TCP bidirectionally
 		t.copyBidirectional(local, remote)
 		return
 	}
+	req = inspector.WithOrigin(req, inspector.Origin{Tunnel: t.Subdomain, LocalPort: t.LocalPort})
+
+	// Run traffic filters before the request reaches the local service
+	if runRequestFilters(t.Filters, req, remote) {
+		return
+	}
 
 	// Publish request start event
 	t.publishEvent(events.EventRequestStart, events.RequestData{
@@ -383,16 +507,17 @@ func (t *Tunnel) proxyStream(remote net.Conn) {
 
 	// Buffer request body for inspector (with error handling)
 	var reqBody []byte
-	if req.Body != nil {
+	if req.Body != nil && !t.LowMemory {
+		var release func()
 		var readErr error
-		reqBody, readErr = io.ReadAll(req.Body)
+		reqBody, req.Body, release, readErr = captureBudget.capture(req.Body)
+		defer release()
 		if readErr != nil {
 			logger.Warn("Failed to read request body: %v", readErr)
 			// Continue with empty body rather than silent failure
-			reqBody = []byte{}
+		} else if reqBody == nil && t.stats != nil {
+			t.stats.RecordCaptureSkipped()
 		}
-		req.Body.Close()
-		req.Body = io.NopCloser(bytes.NewReader(reqBody))
 	}
 
 	// Forward Request to Local
@@ -408,30 +533,58 @@ func (t *Tunnel) proxyStream(remote net.Conn) {
 	if err != nil {
 		logger.Error("Failed to read response from local: %v", err)
 		// Record failed request to inspector
-		inspector.AddExchange(req, reqBody, nil, nil, time.Since(startTime))
+		t.inspector.AddExchange(req, reqBody, nil, nil, time.Since(startTime))
 		t.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "read_response"})
 		return
 	}
 	defer resp.Body.Close()
 
+	if !runResponseFilters(t.Filters, req, resp, remote) {
+		return
+	}
+
+	// WebSocket and other upgrades stay open as a raw byte stream
+	if isUpgrade(resp) {
+		in, out, err := proxyUpgrade(t.inspector, local, remote, respReader, reader, req, resp)
+		if err != nil {
+			logger.Error("Failed to write response to remote: %v", err)
+			t.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "write_response"})
+			return
+		}
+		duration := time.Since(startTime)
+		if t.stats != nil {
+			t.stats.RecordRequest(duration, in+out)
+		}
+		t.publishEvent(events.EventRequestComplete, events.RequestData{
+			Method:   req.Method,
+			Path:     req.URL.Path,
+			Status:   resp.StatusCode,
+			Duration: duration,
+			Bytes:    in + out,
+		})
+		return
+	}
+
 	// Buffer response body for inspector (with error handling)
 	var respBody []byte
-	if resp.Body != nil {
+	if resp.Body != nil && !t.LowMemory {
+		var release func()
 		var readErr error
-		respBody, readErr = io.ReadAll(resp.Body)
+		respBody, resp.Body, release, readErr = captureBudget.capture(resp.Body)
+		defer release()
 		if readErr != nil {
 			logger.Warn("Failed to read response body: %v", readErr)
 			// Continue with empty body rather than silent failure
-			respBody = []byte{}
+		} else if respBody == nil && t.stats != nil {
+			t.stats.RecordCaptureSkipped()
 		}
-		resp.Body = io.NopCloser(bytes.NewReader(respBody))
 	}
 
 	duration := time.Since(startTime)
 	totalBytes := int64(len(reqBody) + len(respBody))
 
 	// Record complete exchange to inspector
-	inspector.AddExchange(req, reqBody, resp, respBody, duration)
+	exchangeID := t.inspector.AddExchange(req, reqBody, resp, respBody, duration)
 
 	// Record stats
 	if t.stats != nil {
@@ -453,7 +606,15 @@ func (t *Tunnel) proxyStream(remote net.Conn) {
 	}
 
 	// Forward Response back to Remote
-	if err := resp.Write(remote); err != nil {
+	sent, aborted, err := writeResponse(remote, reader, resp)
+	if aborted {
+		msg := fmt.Sprintf("Client aborted %s %s after %d bytes", req.Method, req.URL.Path, sent)
+		logger.Warn("%s", msg)
+		t.inspector.MarkAborted(exchangeID, sent)
+		t.publishEvent(events.EventLog, events.LogData{Level: "warn", Message: msg})
+		return
+	}
+	if err != nil {
 		logger.Error("Failed to write response to remote: %v", err)
 		t.publishEvent(events.EventError, events.ErrorData{Error: err, Context: "write_response"})
 		return
@@ -469,7 +630,7 @@ func (t *Tunnel) copyBidirectional(local, remote net.Conn) {
 	// Remote -> Local
 	go func() {
 		defer wg.Done()
-		_, err := io.Copy(local, remote)
+		_, err := bufpool.Copy(local, remote)
 		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
 			logger.Warn("Error copying remote->local: %v", err)
 		}
@@ -482,7 +643,7 @@ func (t *Tunnel) copyBidirectional(local, remote net.Conn) {
 	// Local -> Remote
 	go func() {
 		defer wg.Done()
-		_, err := io.Copy(remote, local)
+		_, err := bufpool.Copy(remote, local)
 		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
 			logger.Warn("Error copying local->remote: %v", err)
 		}
@@ -495,6 +656,19 @@ func (t *Tunnel) copyBidirectional(local, remote net.Conn) {
 	wg.Wait()
 }
 
+// Reconnect drops the current session, so that StartWithReconnect
+// connects again as if the connection had been lost.
+func (t *Tunnel) Reconnect() error {
+	t.mu.Lock()
+	session := t.session
+	t.mu.Unlock()
+	if session == nil {
+		return ErrNotConnected
+	}
+	logger.Info("Reconnect requested, dropping the session")
+	return session.Close()
+}
+
 // Shutdown gracefully shuts down the tunnel, waiting for active connections.
 func (t *Tunnel) Shutdown(ctx context.Context) error {
 	t.mu.Lock()
@@ -554,3 +728,13 @@ func formatLocalDialError(port string, err error) string {
 	// Unknown error - show original for debugging
 	return fmt.Sprintf("Failed to connect to port %s: %v", port, err)
 }
+
+// deviceName describes this machine for the server's device list.
+func deviceName() string {
+	platform := runtime.GOOS + "/" + runtime.GOARCH
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		return platform
+	}
+	return fmt.Sprintf("%s (%s)", host, platform)
+}
@@ -1,14 +1,23 @@
 package tunnel
 
 import (
+	"bufio"
 	"context"
+	"io"
 	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
 	"gopublic/internal/client/events"
+	"gopublic/internal/client/inspector"
 	"gopublic/internal/client/stats"
+	"gopublic/pkg/protocol"
+
+	"github.com/hashicorp/yamux"
 )
 
 func TestNewTunnel(t *testing.T) {
@@ -371,3 +380,351 @@ func TestTLSConfig(t *testing.T) {
 		t.Errorf("expected secure.example.com, got %s", cfg.ServerName)
 	}
 }
+
+func TestYamuxConfig_LowMemory(t *testing.T) {
+	for _, lowMemory := range []bool{false, true} {
+		if err := yamux.VerifyConfig(yamuxConfig(lowMemory, nil)); err != nil {
+			t.Errorf("yamuxConfig(%v) invalid: %v", lowMemory, err)
+		}
+	}
+
+	def, low := yamuxConfig(false, nil), yamuxConfig(true, nil)
+	if low.AcceptBacklog >= def.AcceptBacklog {
+		t.Errorf("low-memory AcceptBacklog = %d, want < %d", low.AcceptBacklog, def.AcceptBacklog)
+	}
+}
+
+func TestTunnel_ProxyStream_LowMemory(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(w, r.Body)
+	}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	tun := NewTunnel("localhost:4443", "token", port)
+	tun.SetLowMemory(true)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go tun.proxyStream(server)
+
+	req, _ := http.NewRequest("POST", "http://example.com/echo", strings.NewReader("hello"))
+	go req.Write(client)
+
+	resp, err := http.ReadResponse(bufio.NewReader(client), req)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if string(body) != "hello" {
+		t.Errorf("body = %q, want hello", body)
+	}
+}
+
+func TestTunnel_ProxyStream_Events(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "ok")
+	}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	tun := NewTunnel("localhost:4443", "token", port)
+	bus := events.NewBus()
+	ch := bus.Subscribe()
+	s := stats.New()
+	tun.SetEventBus(bus)
+	tun.SetStats(s)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go tun.proxyStream(server)
+
+	req, _ := http.NewRequest("GET", "http://example.com/items", nil)
+	go req.Write(client)
+	resp, err := http.ReadResponse(bufio.NewReader(client), req)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	var complete *events.RequestData
+	timeout := time.After(5 * time.Second)
+	for complete == nil {
+		select {
+		case e := <-ch:
+			if e.Type == events.EventRequestComplete {
+				data := e.Data.(events.RequestData)
+				complete = &data
+			}
+		case <-timeout:
+			t.Fatal("no EventRequestComplete published")
+		}
+	}
+	if complete.Method != "GET" || complete.Path != "/items" || complete.Status != http.StatusCreated {
+		t.Errorf("request = %+v", complete)
+	}
+	if snap := s.Snapshot(); snap.TotalRequests != 1 || snap.TotalConnections != 1 {
+		t.Errorf("stats = %+v, want 1 request and connection", snap)
+	}
+}
+
+func TestTunnel_ProxyStream_HeadLimits(t *testing.T) {
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	manyHeaders := "GET / HTTP/1.1\r\nHost: a\r\n" + strings.Repeat("X-A: b\r\n", maxRequestHeaders+1) + "\r\n"
+	tests := []struct {
+		name string
+		head string
+		want int
+	}{
+		{"header bytes", "GET / HTTP/1.1\r\nHost: a\r\nCookie: " + strings.Repeat("x", maxRequestHeaderBytes) + "\r\n\r\n", http.StatusRequestHeaderFieldsTooLarge},
+		{"header count", manyHeaders, http.StatusRequestHeaderFieldsTooLarge},
+		{"URI length", "GET /" + strings.Repeat("a", maxRequestURILength) + " HTTP/1.1\r\nHost: a\r\n\r\n", http.StatusRequestURITooLong},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tun := NewTunnel("localhost:4443", "token", port)
+			client, server := net.Pipe()
+			defer client.Close()
+			go tun.proxyStream(server)
+
+			client.SetDeadline(time.Now().Add(5 * time.Second))
+			go io.WriteString(client, tt.head)
+			resp, err := http.ReadResponse(bufio.NewReader(client), nil)
+			if err != nil {
+				t.Fatalf("ReadResponse: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
+
+func TestTunnel_HandshakeTimeout(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+	go func() {
+		// A server that accepts the handshake stream but never answers
+		session, err := yamux.Server(serverConn, nil)
+		if err != nil {
+			return
+		}
+		defer session.Close()
+		if stream, err := session.Accept(); err == nil {
+			io.Copy(io.Discard, stream)
+		}
+	}()
+
+	tun := NewTunnel("localhost:4443", "token", "3000")
+	tun.SetKeepalive(&KeepaliveConfig{Interval: time.Minute, Timeout: time.Minute, HandshakeTimeout: 50 * time.Millisecond})
+	bus := events.NewBus()
+	ch := bus.Subscribe()
+	tun.SetEventBus(bus)
+
+	err := tun.handleSession(clientConn, time.Now())
+	if !IsHandshakeTimeout(err) {
+		t.Fatalf("handleSession() error = %v, want a handshake timeout", err)
+	}
+	for {
+		select {
+		case e := <-ch:
+			if data, ok := e.Data.(events.ErrorData); ok && e.Type == events.EventError && data.Context == "handshake" {
+				return
+			}
+		case <-time.After(time.Second):
+			t.Fatal("no handshake EventError published")
+		}
+	}
+}
+
+func TestTunnel_ProxyStream_TCP(t *testing.T) {
+	// A server-first service, like SSH: it sends a banner before reading
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "SSH-2.0-test\r\n")
+		io.Copy(conn, conn)
+	}()
+	_, port, _ := net.SplitHostPort(ln.Addr().String())
+
+	tun := NewTunnel("localhost:4443", "token", port)
+	tun.SetProto(protocol.ProtoTCP)
+
+	client, server := net.Pipe()
+	defer client.Close()
+	go tun.proxyStream(server)
+
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+	r := bufio.NewReader(client)
+	if banner, err := r.ReadString('\n'); err != nil || banner != "SSH-2.0-test\r\n" {
+		t.Fatalf("banner = %q, %v", banner, err)
+	}
+	io.WriteString(client, "ping\n")
+	if echo, err := r.ReadString('\n'); err != nil || echo != "ping\n" {
+		t.Errorf("echo = %q, %v", echo, err)
+	}
+}
+
+func TestTunnel_ProxyStream_ClientAborted(t *testing.T) {
+	payload := strings.Repeat("x", 1<<20) // Larger than the stream window
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, payload)
+	}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	tun := NewTunnel("localhost:4443", "token", port)
+	marker, _ := http.NewRequest("GET", "http://example.com/", nil)
+	insp := inspector.NewServer(nil)
+	tun.SetInspector(insp)
+	lastID := insp.AddExchange(marker, nil, nil, nil, 0)
+
+	// Connect a client and server session like the tunnel and ingress
+	clientConn, serverConn := net.Pipe()
+	clientSession, _ := yamux.Server(clientConn, nil)
+	serverSession, _ := yamux.Client(serverConn, nil)
+	defer clientSession.Close()
+	defer serverSession.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		remote, err := clientSession.Accept()
+		if err != nil {
+			return
+		}
+		tun.proxyStream(remote)
+	}()
+
+	stream, err := serverSession.Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	req, _ := http.NewRequest("GET", "http://example.com/download", nil)
+	req.Write(stream)
+
+	// Read only the headers, then drop the stream like the ingress does
+	// when the caller closes the tab
+	resp, err := http.ReadResponse(bufio.NewReader(stream), req)
+	if err != nil {
+		t.Fatalf("ReadResponse: %v", err)
+	}
+	stream.SetReadDeadline(time.Now())
+	stream.Close()
+	resp.Body.Close()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxyStream did not return after the caller aborted")
+	}
+
+	insp.Flush()
+	ex, ok := insp.Store().Get(lastID + 1)
+	if !ok {
+		t.Fatal("expected exchange in inspector")
+	}
+	if !ex.Aborted {
+		t.Error("exchange should be marked as aborted")
+	}
+	if ex.BytesSent <= 0 || ex.BytesSent >= int64(len(payload)) {
+		t.Errorf("BytesSent = %d, want partial response", ex.BytesSent)
+	}
+}
+
+func TestTunnel_ProxyStream_Upgrade(t *testing.T) {
+	// A WebSocket-like service: it greets right after the 101, then echoes
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") != "websocket" {
+			http.Error(w, "upgrade required", http.StatusUpgradeRequired)
+			return
+		}
+		conn, brw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		io.WriteString(conn, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\nhello\n")
+		io.Copy(conn, brw)
+	}))
+	defer local.Close()
+	_, port, _ := net.SplitHostPort(local.Listener.Addr().String())
+
+	tun := NewTunnel("localhost:4443", "token", port)
+	marker, _ := http.NewRequest("GET", "http://example.com/", nil)
+	insp := inspector.NewServer(nil)
+	tun.SetInspector(insp)
+	lastID := insp.AddExchange(marker, nil, nil, nil, 0)
+
+	clientConn, serverConn := net.Pipe()
+	clientSession, _ := yamux.Server(clientConn, nil)
+	serverSession, _ := yamux.Client(serverConn, nil)
+	defer clientSession.Close()
+	defer serverSession.Close()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		remote, err := clientSession.Accept()
+		if err != nil {
+			return
+		}
+		tun.proxyStream(remote)
+	}()
+
+	stream, err := serverSession.Open()
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	stream.SetDeadline(time.Now().Add(5 * time.Second))
+	req, _ := http.NewRequest("GET", "http://example.com/ws", nil)
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Write(stream)
+
+	r := bufio.NewReader(stream)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("ReadResponse = %v, %v", resp, err)
+	}
+	if greeting, err := r.ReadString('\n'); err != nil || greeting != "hello\n" {
+		t.Fatalf("greeting = %q, %v", greeting, err)
+	}
+	io.WriteString(stream, "ping\n")
+	if echo, err := r.ReadString('\n'); err != nil || echo != "ping\n" {
+		t.Fatalf("echo = %q, %v", echo, err)
+	}
+
+	insp.Flush()
+	ex, ok := insp.Store().Get(lastID + 1)
+	if !ok || !ex.WebSocket || !ex.WSOpen {
+		t.Fatalf("inspector entry = %+v, want an open WebSocket", ex)
+	}
+
+	stream.Close()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("proxyStream did not return after the caller closed")
+	}
+	insp.Flush()
+	ex, _ = insp.Store().Get(lastID + 1)
+	if ex.WSOpen || ex.WSIn != 5 || ex.WSOut != 11 {
+		t.Errorf("closed entry = open %v, in %d, out %d; want closed, 5 in, 11 out", ex.WSOpen, ex.WSIn, ex.WSOut)
+	}
+}
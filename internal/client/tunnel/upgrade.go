@@ -0,0 +1,53 @@
+package tunnel
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"gopublic/internal/bufpool"
+	"gopublic/internal/client/inspector"
+)
+
+// isUpgrade reports whether the local service switched the connection to
+// another protocol, e.g. WebSocket.
+func isUpgrade(resp *http.Response) bool {
+	return resp.StatusCode == http.StatusSwitchingProtocols
+}
+
+// proxyUpgrade completes an upgrade accepted by the local service: it
+// forwards the 101 response and then copies raw bytes both ways until
+// either side closes. Bytes the readers buffered past the HTTP headers
+// are forwarded first. The connection shows in the inspector as a
+// WebSocket entry of insp. Returns the bytes copied from remote and from local.
+func proxyUpgrade(insp *inspector.Server, local, remote net.Conn, localReader, remoteReader *bufio.Reader, req *http.Request, resp *http.Response) (int64, int64, error) {
+	start := time.Now()
+	resp.Body = nil
+	if err := resp.Write(remote); err != nil {
+		return 0, 0, err
+	}
+	id := insp.AddUpgrade(req, resp)
+
+	var in, out int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		in, _ = bufpool.Copy(local, remoteReader)
+		if tcpConn, ok := local.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		out, _ = bufpool.Copy(remote, localReader)
+		// Closing a stream only ends our side; the caller may still send
+		remote.Close()
+	}()
+	wg.Wait()
+
+	insp.CloseUpgrade(id, in, out, time.Since(start))
+	return in, out, nil
+}
@@ -2,8 +2,11 @@ package config
 
 import (
 	"encoding/hex"
+	"math"
 	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	apperrors "gopublic/internal/errors"
 )
@@ -17,6 +20,12 @@ type Config struct {
 	InsecureMode bool   // If true, use HTTP instead of HTTPS
 	DBPath       string // Path to SQLite database
 
+	// Database connection pool settings
+	DBMaxOpenConns    int           // Max open connections (0 = unlimited)
+	DBMaxIdleConns    int           // Max idle connections
+	DBConnMaxLifetime time.Duration // Max connection reuse time (0 = unlimited)
+	DBReplicaPath     string        // Optional read-only replica for reporting queries
+
 	// Control plane settings
 	ControlPlanePort string // Port for control plane (default ":4443")
 	MaxConnections   int    // Max concurrent tunnel connections
@@ -25,10 +34,31 @@ type Config struct {
 	TelegramBotToken string
 	TelegramBotName  string
 
+	// Tunnel management commands (/tunnels, /disconnect, /usage) for users in the bot
+	TelegramBotCommands bool
+
+	// Destructive dashboard actions need a TOTP code or a code sent by the bot
+	ConfirmActions bool
+
 	// Yandex OAuth
 	YandexClientID     string
 	YandexClientSecret string
 
+	// GitHub and Google OAuth
+	GitHubClientID     string
+	GitHubClientSecret string
+	GoogleClientID     string
+	GoogleClientSecret string
+
+	// Corporate SSO through an OpenID Connect identity provider (empty
+	// issuer = disabled)
+	OIDCIssuer       string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCName         string            // Label of the login button
+	OIDCGroupsClaim  string            // Claim listing the user's groups
+	OIDCGroupRoles   map[string]string // Group -> "admin", "member" or "viewer"; if set, other groups are refused
+
 	// Admin notifications
 	AdminTelegramID int64 // Telegram user ID for abuse reports
 
@@ -43,9 +73,113 @@ type Config struct {
 	// Number of domains to assign per new user (default: 2)
 	DomainsPerUser int
 
+	// Subdomains a user may reserve by name on top of the assigned ones
+	// (default: 1, 0 = reservations disabled), with per-plan overrides
+	ReservedDomainsPerUser int
+	PlanReservedDomains    map[string]int
+
+	// New signups wait for admin approval before getting a token and domains
+	RequireSignupApproval bool
+
+	// Signups require an invite code; invites created by users allow InviteMaxUses signups
+	InviteOnly    bool
+	InviteMaxUses int
+
 	// Daily bandwidth limit per user in bytes (0 = unlimited)
 	DailyBandwidthLimit int64
 
+	// Days of per-day bandwidth history kept before compaction (default: 90)
+	BandwidthRetentionDays int
+
+	// WASM traffic filter modules applied to all tunnel traffic at ingress
+	TrafficFilters []string
+
+	// Redis pub/sub for cross-instance events (empty = single instance)
+	RedisURL     string
+	RedisChannel string
+
+	// Address for the Prometheus metrics endpoint (empty = disabled)
+	MetricsAddr string
+
+	// Retry GET/HEAD requests once when the tunnel stream fails mid-flight
+	RetryIdempotentRequests bool
+
+	// Per-session concurrent stream limit (0 = unlimited) and queue wait
+	MaxStreamsPerSession int
+	StreamQueueTimeout   time.Duration
+
+	// Time a force-disconnected session may finish in-flight requests
+	DrainTimeout time.Duration
+
+	// Requests per second the ingress forwards to each tunnel domain and
+	// their burst (0 = unlimited); clients may ask for less
+	TunnelRateLimit float64
+	TunnelRateBurst int
+
+	// Requests per second each API token may send to the public API
+	// (/api/v1) and their burst (0 = unlimited)
+	APIRateLimit float64
+	APIRateBurst int
+
+	// Named agent sessions (gopublic start --agent) a user may hold besides
+	// the regular one (0 = unlimited)
+	MaxAgentsPerUser int
+
+	// Lifetime of tokens issued by the dashboard (0 = never expire); clients
+	// renew expired ones with the refresh token issued alongside
+	TokenTTL time.Duration
+
+	// Load shedding: new handshakes are refused with "server_busy" above any
+	// of these limits (0 = unlimited) and told to retry after ShedRetryAfter
+	MaxSessions    int
+	MaxMemoryMB    int
+	MaxGoroutines  int
+	ShedRetryAfter time.Duration
+
+	// Public port range for raw TCP tunnels (0 = TCP tunnels disabled)
+	TCPPortMin      int
+	TCPPortMax      int
+	TCPPortsPerUser int // Ports one user may hold at once (0 = unlimited)
+
+	// Plans whose tunnels show a phishing warning to first-time visitors
+	InterstitialPlans []string
+
+	// External content scanner for request bodies (empty = disabled)
+	ScanURL       string        // icap:// or http(s):// service URL
+	ScanThreshold int64         // Bodies larger than this are scanned
+	ScanMaxBody   int64         // Bodies larger than this cannot be scanned
+	ScanTimeout   time.Duration // Per-scan deadline
+	ScanFailOpen  bool          // Forward requests when the scanner fails
+
+	// MaxMind-compatible GeoIP databases (empty = disabled)
+	GeoIPDBPath    string // Country or city database
+	GeoIPASNDBPath string // ASN database
+
+	// Stripe billing (empty secret key = disabled)
+	StripeSecretKey     string
+	StripeWebhookSecret string
+	StripePrices        map[string]string // Plan -> Stripe price ID
+	PlanBandwidthLimits map[string]int64  // Plan -> daily bandwidth in bytes (0 = unlimited)
+
+	// Notify users at 80% and 100% of their daily bandwidth, over Telegram
+	// or, for users without Telegram, email through the SMTP relay
+	QuotaAlerts  bool
+	SMTPAddr     string // host:port (empty = no email)
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Let users sign in with a one-time link emailed through the SMTP relay
+	EmailLogin bool
+
+	// Let users register alerts for tunnels that stay offline, delivered
+	// over Telegram, email or a webhook
+	OfflineAlerts bool
+
+	// Let users publish a read-only status page of selected tunnels at
+	// status.<domain>/u/<name>
+	StatusPages bool
+
 	// Session keys (32 bytes each)
 	SessionHashKey  []byte
 	SessionBlockKey []byte
@@ -56,6 +190,8 @@ var (
 	ErrMissingDomain      = apperrors.New(apperrors.CodeConfigError, "DOMAIN_NAME is required in production mode")
 	ErrMissingSessionKeys = apperrors.New(apperrors.CodeConfigError, "SESSION_HASH_KEY and SESSION_BLOCK_KEY are required in production mode")
 	ErrInvalidSessionKey  = apperrors.New(apperrors.CodeConfigError, "session key must be 32 bytes hex-encoded")
+	ErrInvalidPoolSetting = apperrors.New(apperrors.CodeConfigError, "database pool settings must be non-negative")
+	ErrInvalidGroupRole   = apperrors.New(apperrors.CodeConfigError, "OIDC_GROUP_ROLES roles must be admin, member or viewer")
 )
 
 // LoadFromEnv loads configuration from environment variables
@@ -76,6 +212,22 @@ func LoadFromEnv() (*Config, error) {
 		}
 	}
 
+	// Parse reserved domains per user (default: 1)
+	reservedDomainsPerUser := 1
+	if val := os.Getenv("RESERVED_DOMAINS_PER_USER"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			reservedDomainsPerUser = n
+		}
+	}
+
+	// Parse bandwidth retention (default: 90 days)
+	bandwidthRetentionDays := 90
+	if val := os.Getenv("BANDWIDTH_RETENTION_DAYS"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			bandwidthRetentionDays = n
+		}
+	}
+
 	// Parse admin Telegram ID
 	var adminTelegramID int64
 	if val := os.Getenv("ADMIN_TELEGRAM_ID"); val != "" {
@@ -92,18 +244,197 @@ func LoadFromEnv() (*Config, error) {
 		}
 	}
 
+	// Parse database pool settings (defaults: 100 open, 10 idle, no lifetime limit)
+	dbMaxOpenConns := 100
+	if val := os.Getenv("DB_MAX_OPEN_CONNS"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return nil, apperrors.Wrapf(ErrInvalidPoolSetting, apperrors.CodeConfigError, "invalid DB_MAX_OPEN_CONNS %q", val)
+		}
+		dbMaxOpenConns = n
+	}
+	dbMaxIdleConns := 10
+	if val := os.Getenv("DB_MAX_IDLE_CONNS"); val != "" {
+		n, err := strconv.Atoi(val)
+		if err != nil || n < 0 {
+			return nil, apperrors.Wrapf(ErrInvalidPoolSetting, apperrors.CodeConfigError, "invalid DB_MAX_IDLE_CONNS %q", val)
+		}
+		dbMaxIdleConns = n
+	}
+	var dbConnMaxLifetime time.Duration
+	if val := os.Getenv("DB_CONN_MAX_LIFETIME"); val != "" {
+		d, err := time.ParseDuration(val)
+		if err != nil || d < 0 {
+			return nil, apperrors.Wrapf(ErrInvalidPoolSetting, apperrors.CodeConfigError, "invalid DB_CONN_MAX_LIFETIME %q", val)
+		}
+		dbConnMaxLifetime = d
+	}
+
+	// Parse per-session stream limits (defaults: 100 streams, 10s queue, 10s drain)
+	maxStreamsPerSession := 100
+	if val := os.Getenv("MAX_STREAMS_PER_SESSION"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			maxStreamsPerSession = n
+		}
+	}
+	streamQueueTimeout := 10 * time.Second
+	if val := os.Getenv("STREAM_QUEUE_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			streamQueueTimeout = d
+		}
+	}
+	drainTimeout := 10 * time.Second
+	if val := os.Getenv("DRAIN_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			drainTimeout = d
+		}
+	}
+
+	// Parse per-domain request rate limit (default: unlimited, burst = rate)
+	var tunnelRateLimit float64
+	if val := os.Getenv("TUNNEL_RATE_LIMIT"); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 && !math.IsInf(f, 0) {
+			tunnelRateLimit = f
+		}
+	}
+	tunnelRateBurst := parseNonNegative(os.Getenv("TUNNEL_RATE_BURST"))
+
+	// Public API rate limit per API token (default: 5/s, burst 20)
+	apiRateLimit := 5.0
+	if val := os.Getenv("API_RATE_LIMIT"); val != "" {
+		if f, err := strconv.ParseFloat(val, 64); err == nil && f >= 0 && !math.IsInf(f, 0) {
+			apiRateLimit = f
+		}
+	}
+	apiRateBurst := 20
+	if val := os.Getenv("API_RATE_BURST"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			apiRateBurst = n
+		}
+	}
+
+	// Named agent sessions per user besides the regular one (default: 10)
+	maxAgentsPerUser := 10
+	if val := os.Getenv("MAX_AGENTS_PER_USER"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			maxAgentsPerUser = n
+		}
+	}
+
+	var tokenTTL time.Duration
+	if val := os.Getenv("TOKEN_TTL"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			tokenTTL = d
+		}
+	}
+
+	// Parse load shedding limits (defaults: unlimited, retry after 30s)
+	maxSessions := parseNonNegative(os.Getenv("MAX_SESSIONS"))
+	maxMemoryMB := parseNonNegative(os.Getenv("MAX_MEMORY_MB"))
+	maxGoroutines := parseNonNegative(os.Getenv("MAX_GOROUTINES"))
+	shedRetryAfter := 30 * time.Second
+	if val := os.Getenv("SHED_RETRY_AFTER"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d > 0 {
+			shedRetryAfter = d
+		}
+	}
+
+	// Parse TCP tunnel port range ("20000-20099", empty = disabled)
+	tcpPortMin, tcpPortMax := parsePortRange(os.Getenv("TCP_PORTS"))
+	// A forced reconnect may get its new port before the old one is released
+	tcpPortsPerUser := 2
+	if val := os.Getenv("TCP_PORTS_PER_USER"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			tcpPortsPerUser = n
+		}
+	}
+
+	// Parse traffic filter module paths (comma-separated)
+	var trafficFilters []string
+	for _, path := range strings.Split(os.Getenv("TRAFFIC_FILTERS"), ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			trafficFilters = append(trafficFilters, path)
+		}
+	}
+
+	// Parse content scan limits (defaults: 64KB threshold, 32MB max, 30s timeout)
+	scanThreshold := int64(64 * 1024)
+	if val := os.Getenv("SCAN_THRESHOLD_KB"); val != "" {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && n >= 0 {
+			scanThreshold = n * 1024
+		}
+	}
+	scanMaxBody := int64(32 * 1024 * 1024)
+	if val := os.Getenv("SCAN_MAX_BODY_MB"); val != "" {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && n >= 0 {
+			scanMaxBody = n * 1024 * 1024
+		}
+	}
+	scanTimeout := 30 * time.Second
+	if val := os.Getenv("SCAN_TIMEOUT"); val != "" {
+		if d, err := time.ParseDuration(val); err == nil && d >= 0 {
+			scanTimeout = d
+		}
+	}
+
+	// Parse invite usage limit (default: 5 signups per user invite)
+	inviteMaxUses := 5
+	if val := os.Getenv("INVITE_MAX_USES"); val != "" {
+		if n, err := strconv.Atoi(val); err == nil && n > 0 {
+			inviteMaxUses = n
+		}
+	}
+
+	// Parse interstitial plans (comma-separated, empty = disabled)
+	var interstitialPlans []string
+	for _, plan := range strings.Split(os.Getenv("INTERSTITIAL_PLANS"), ",") {
+		if plan = strings.TrimSpace(plan); plan != "" {
+			interstitialPlans = append(interstitialPlans, plan)
+		}
+	}
+
+	// Parse per-plan daily bandwidth limits in MB ("pro=10240,team=0")
+	planBandwidthLimits := make(map[string]int64)
+	for plan, val := range parsePlanList(os.Getenv("PLAN_BANDWIDTH_LIMITS_MB")) {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil && n >= 0 {
+			planBandwidthLimits[plan] = n * 1024 * 1024
+		}
+	}
+
+	// Parse per-plan reserved domain limits ("free=0,pro=10")
+	planReservedDomains := make(map[string]int)
+	for plan, val := range parsePlanList(os.Getenv("PLAN_RESERVED_DOMAINS")) {
+		if n, err := strconv.Atoi(val); err == nil && n >= 0 {
+			planReservedDomains[plan] = n
+		}
+	}
+
 	cfg := &Config{
 		Domain:              os.Getenv("DOMAIN_NAME"),
 		ProjectName:         getEnvOrDefault("PROJECT_NAME", "Go Public"),
 		Email:               os.Getenv("EMAIL"),
 		InsecureMode:        os.Getenv("INSECURE_HTTP") == "true",
 		DBPath:              getEnvOrDefault("DB_PATH", "gopublic.db"),
+		DBMaxOpenConns:      dbMaxOpenConns,
+		DBMaxIdleConns:      dbMaxIdleConns,
+		DBConnMaxLifetime:   dbConnMaxLifetime,
+		DBReplicaPath:       os.Getenv("DB_REPLICA_PATH"),
 		ControlPlanePort:    getEnvOrDefault("CONTROL_PLANE_PORT", ":4443"),
 		MaxConnections:      1000,
 		TelegramBotToken:    os.Getenv("TELEGRAM_BOT_TOKEN"),
 		TelegramBotName:     os.Getenv("TELEGRAM_BOT_NAME"),
 		YandexClientID:      os.Getenv("YANDEX_CLIENT_ID"),
 		YandexClientSecret:  os.Getenv("YANDEX_CLIENT_SECRET"),
+		GitHubClientID:      os.Getenv("GITHUB_CLIENT_ID"),
+		GitHubClientSecret:  os.Getenv("GITHUB_CLIENT_SECRET"),
+		GoogleClientID:      os.Getenv("GOOGLE_CLIENT_ID"),
+		GoogleClientSecret:  os.Getenv("GOOGLE_CLIENT_SECRET"),
+		OIDCIssuer:          os.Getenv("OIDC_ISSUER"),
+		OIDCClientID:        os.Getenv("OIDC_CLIENT_ID"),
+		OIDCClientSecret:    os.Getenv("OIDC_CLIENT_SECRET"),
+		OIDCName:            getEnvOrDefault("OIDC_NAME", "SSO"),
+		OIDCGroupsClaim:     getEnvOrDefault("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCGroupRoles:      parsePlanList(os.Getenv("OIDC_GROUP_ROLES")),
 		AdminTelegramID:     adminTelegramID,
 		SentryDSN:           os.Getenv("SENTRY_DSN"),
 		SentryEnvironment:   getEnvOrDefault("SENTRY_ENVIRONMENT", "development"),
@@ -111,6 +442,66 @@ func LoadFromEnv() (*Config, error) {
 		GitHubRepo:          os.Getenv("GITHUB_REPO"),
 		DomainsPerUser:      domainsPerUser,
 		DailyBandwidthLimit: dailyBandwidthLimit,
+		TrafficFilters:      trafficFilters,
+
+		BandwidthRetentionDays: bandwidthRetentionDays,
+		ReservedDomainsPerUser: reservedDomainsPerUser,
+		PlanReservedDomains:    planReservedDomains,
+
+		RedisURL:     os.Getenv("REDIS_URL"),
+		RedisChannel: getEnvOrDefault("REDIS_CHANNEL", "gopublic:events"),
+
+		MetricsAddr:             os.Getenv("METRICS_ADDR"),
+		RetryIdempotentRequests: os.Getenv("RETRY_IDEMPOTENT_REQUESTS") == "true",
+
+		MaxStreamsPerSession: maxStreamsPerSession,
+		StreamQueueTimeout:   streamQueueTimeout,
+		DrainTimeout:         drainTimeout,
+		TunnelRateLimit:      tunnelRateLimit,
+		TunnelRateBurst:      tunnelRateBurst,
+		APIRateLimit:         apiRateLimit,
+		APIRateBurst:         apiRateBurst,
+		MaxAgentsPerUser:     maxAgentsPerUser,
+		TokenTTL:             tokenTTL,
+		MaxSessions:          maxSessions,
+		MaxMemoryMB:          maxMemoryMB,
+		MaxGoroutines:        maxGoroutines,
+		ShedRetryAfter:       shedRetryAfter,
+		TCPPortMin:           tcpPortMin,
+		TCPPortMax:           tcpPortMax,
+		TCPPortsPerUser:      tcpPortsPerUser,
+
+		InterstitialPlans:     interstitialPlans,
+		RequireSignupApproval: os.Getenv("SIGNUP_APPROVAL") == "true",
+		InviteOnly:            os.Getenv("INVITE_ONLY") == "true",
+		InviteMaxUses:         inviteMaxUses,
+
+		ScanURL:       os.Getenv("SCAN_URL"),
+		ScanThreshold: scanThreshold,
+		ScanMaxBody:   scanMaxBody,
+		ScanTimeout:   scanTimeout,
+		ScanFailOpen:  os.Getenv("SCAN_FAIL_OPEN") == "true",
+
+		GeoIPDBPath:    os.Getenv("GEOIP_DB"),
+		GeoIPASNDBPath: os.Getenv("GEOIP_ASN_DB"),
+
+		StripeSecretKey:     os.Getenv("STRIPE_SECRET_KEY"),
+		StripeWebhookSecret: os.Getenv("STRIPE_WEBHOOK_SECRET"),
+		StripePrices:        parsePlanList(os.Getenv("STRIPE_PRICES")),
+		PlanBandwidthLimits: planBandwidthLimits,
+
+		TelegramBotCommands: os.Getenv("TELEGRAM_BOT_COMMANDS") == "true",
+		ConfirmActions:      os.Getenv("CONFIRM_ACTIONS") == "true",
+
+		QuotaAlerts:  os.Getenv("QUOTA_ALERTS") == "true",
+		SMTPAddr:     os.Getenv("SMTP_ADDR"),
+		SMTPUsername: os.Getenv("SMTP_USERNAME"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+		SMTPFrom:     os.Getenv("SMTP_FROM"),
+
+		EmailLogin:    os.Getenv("EMAIL_LOGIN") == "true",
+		OfflineAlerts: os.Getenv("OFFLINE_ALERTS") == "true",
+		StatusPages:   os.Getenv("STATUS_PAGES") == "true",
 	}
 
 	// Parse session keys
@@ -148,6 +539,12 @@ func (c *Config) Validate() error {
 	// Telegram config is optional (dashboard won't work without it)
 	// but we don't fail startup
 
+	for _, role := range c.OIDCGroupRoles {
+		if role != "admin" && role != "member" && role != "viewer" {
+			return ErrInvalidGroupRole
+		}
+	}
+
 	return nil
 }
 
@@ -179,6 +576,21 @@ func (c *Config) HasYandexOAuth() bool {
 	return c.YandexClientID != "" && c.YandexClientSecret != ""
 }
 
+// HasGitHubOAuth returns true if GitHub OAuth is configured
+func (c *Config) HasGitHubOAuth() bool {
+	return c.GitHubClientID != "" && c.GitHubClientSecret != ""
+}
+
+// HasGoogleOAuth returns true if Google OAuth is configured
+func (c *Config) HasGoogleOAuth() bool {
+	return c.GoogleClientID != "" && c.GoogleClientSecret != ""
+}
+
+// HasOIDC returns true if corporate SSO is configured
+func (c *Config) HasOIDC() bool {
+	return c.OIDCIssuer != "" && c.OIDCClientID != "" && c.OIDCClientSecret != ""
+}
+
 // HasTelegramOAuth returns true if Telegram OAuth is configured
 func (c *Config) HasTelegramOAuth() bool {
 	return c.TelegramBotToken != "" && c.TelegramBotName != ""
@@ -194,9 +606,52 @@ func (c *Config) HasSentry() bool {
 	return c.SentryDSN != ""
 }
 
+// HasBilling returns true if Stripe billing is configured
+func (c *Config) HasBilling() bool {
+	return c.StripeSecretKey != "" && len(c.StripePrices) > 0
+}
+
 func getEnvOrDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
 	}
 	return defaultValue
 }
+
+// parsePortRange parses a "min-max" port range, returning zeros if it is
+// empty or invalid.
+func parsePortRange(s string) (int, int) {
+	lo, hi, ok := strings.Cut(s, "-")
+	if !ok {
+		return 0, 0
+	}
+	min, err1 := strconv.Atoi(strings.TrimSpace(lo))
+	max, err2 := strconv.Atoi(strings.TrimSpace(hi))
+	if err1 != nil || err2 != nil || min < 1 || max > 65535 || min > max {
+		return 0, 0
+	}
+	return min, max
+}
+
+// parseNonNegative parses a count limit, returning 0 (unlimited) if it is
+// empty or invalid.
+func parseNonNegative(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+// parsePlanList parses a comma-separated "plan=value" list.
+func parsePlanList(s string) map[string]string {
+	values := make(map[string]string)
+	for _, item := range strings.Split(s, ",") {
+		plan, value, ok := strings.Cut(item, "=")
+		plan, value = strings.TrimSpace(plan), strings.TrimSpace(value)
+		if ok && plan != "" && value != "" {
+			values[plan] = value
+		}
+	}
+	return values
+}
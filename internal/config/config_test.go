@@ -1,8 +1,10 @@
 package config
 
 import (
+	"errors"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoadFromEnv(t *testing.T) {
@@ -54,6 +56,56 @@ func TestLoadFromEnv(t *testing.T) {
 			t.Errorf("DBPath = %q, want %q", cfg.DBPath, "gopublic.db")
 		}
 	})
+
+	t.Run("db pool settings", func(t *testing.T) {
+		t.Setenv("DB_MAX_OPEN_CONNS", "25")
+		t.Setenv("DB_MAX_IDLE_CONNS", "")
+		t.Setenv("DB_CONN_MAX_LIFETIME", "30m")
+
+		cfg, err := LoadFromEnv()
+		if err != nil {
+			t.Fatalf("LoadFromEnv failed: %v", err)
+		}
+
+		if cfg.DBMaxOpenConns != 25 {
+			t.Errorf("DBMaxOpenConns = %d, want 25", cfg.DBMaxOpenConns)
+		}
+		if cfg.DBMaxIdleConns != 10 {
+			t.Errorf("DBMaxIdleConns = %d, want default 10", cfg.DBMaxIdleConns)
+		}
+		if cfg.DBConnMaxLifetime != 30*time.Minute {
+			t.Errorf("DBConnMaxLifetime = %v, want 30m", cfg.DBConnMaxLifetime)
+		}
+	})
+
+	t.Run("invalid db pool settings", func(t *testing.T) {
+		for _, env := range []string{"DB_MAX_OPEN_CONNS", "DB_MAX_IDLE_CONNS", "DB_CONN_MAX_LIFETIME"} {
+			t.Run(env, func(t *testing.T) {
+				t.Setenv(env, "-1")
+
+				if _, err := LoadFromEnv(); !errors.Is(err, ErrInvalidPoolSetting) {
+					t.Errorf("LoadFromEnv() error = %v, want ErrInvalidPoolSetting", err)
+				}
+			})
+		}
+	})
+
+	t.Run("reserved domain limits", func(t *testing.T) {
+		t.Setenv("RESERVED_DOMAINS_PER_USER", "")
+		t.Setenv("PLAN_RESERVED_DOMAINS", "free=0, pro=10, team=x")
+
+		cfg, err := LoadFromEnv()
+		if err != nil {
+			t.Fatalf("LoadFromEnv failed: %v", err)
+		}
+
+		if cfg.ReservedDomainsPerUser != 1 {
+			t.Errorf("ReservedDomainsPerUser = %d, want default 1", cfg.ReservedDomainsPerUser)
+		}
+		if len(cfg.PlanReservedDomains) != 2 || cfg.PlanReservedDomains["free"] != 0 || cfg.PlanReservedDomains["pro"] != 10 {
+			t.Errorf("PlanReservedDomains = %v, want free=0 pro=10", cfg.PlanReservedDomains)
+		}
+	})
 }
 
 func TestConfig_IsLocalDev(t *testing.T) {
@@ -162,4 +214,45 @@ func TestConfig_Validate(t *testing.T) {
 			t.Errorf("Unexpected error in insecure mode: %v", err)
 		}
 	})
+
+	t.Run("SSO group roles must be known", func(t *testing.T) {
+		cfg := &Config{
+			Domain:         "localhost",
+			OIDCGroupRoles: map[string]string{"gopublic-admins": "admin", "eng": "owner"},
+		}
+
+		if err := cfg.Validate(); err != ErrInvalidGroupRole {
+			t.Errorf("Validate() = %v, want ErrInvalidGroupRole", err)
+		}
+	})
+}
+
+func TestParsePlanList(t *testing.T) {
+	got := parsePlanList(" pro = price_123 ,team=price_456,broken,=x,empty=")
+	if len(got) != 2 || got["pro"] != "price_123" || got["team"] != "price_456" {
+		t.Errorf("parsePlanList() = %v, want pro and team only", got)
+	}
+	if got := parsePlanList(""); len(got) != 0 {
+		t.Errorf("parsePlanList(\"\") = %v, want empty", got)
+	}
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		in       string
+		min, max int
+	}{
+		{"20000-20099", 20000, 20099},
+		{" 3000 - 3000 ", 3000, 3000},
+		{"", 0, 0},
+		{"20000", 0, 0},
+		{"20099-20000", 0, 0},
+		{"0-100", 0, 0},
+		{"60000-70000", 0, 0},
+	}
+	for _, tt := range tests {
+		if min, max := parsePortRange(tt.in); min != tt.min || max != tt.max {
+			t.Errorf("parsePortRange(%q) = %d, %d, want %d, %d", tt.in, min, max, tt.min, tt.max)
+		}
+	}
 }
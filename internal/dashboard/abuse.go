@@ -0,0 +1,260 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+	"gopublic/internal/version"
+)
+
+// Abuse report review states.
+const (
+	AbuseStatusPending  = "pending"
+	AbuseStatusReviewed = "reviewed"
+	AbuseStatusResolved = "resolved"
+)
+
+// AbuseReportRow is a single entry of the admin abuse queue.
+type AbuseReportRow struct {
+	ID            uint      `json:"id"`
+	CreatedAt     time.Time `json:"created_at"`
+	TunnelURL     string    `json:"tunnel_url"`
+	Domain        string    `json:"domain"`
+	ReportType    string    `json:"report_type"`
+	Description   string    `json:"description"`
+	ReporterEmail string    `json:"reporter_email,omitempty"`
+	Status        string    `json:"status"`
+	Suspended     bool      `json:"suspended"`
+}
+
+// reportedHost extracts the lowercase hostname from a reported URL.
+// Scheme-less input such as "foo.example.com/path" is accepted.
+func reportedHost(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
+
+// domainName maps a tunnel hostname to the stored domain name.
+func (h *Handler) domainName(host string) (string, bool) {
+	if h.Domain == "" {
+		return host, host != ""
+	}
+	name, ok := strings.CutSuffix(host, "."+h.Domain)
+	return name, ok && name != ""
+}
+
+// requireAdminAPI returns the session user if it is the administrator,
+// otherwise it writes an error response.
+func (h *Handler) requireAdminAPI(c *gin.Context) bool {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return false
+	}
+	if !h.isAdmin(user) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return false
+	}
+	return true
+}
+
+// checkCSRF validates the double-submit CSRF token of a JSON API call.
+func checkCSRF(c *gin.Context) bool {
+	cookieToken, err := c.Cookie("csrf_token")
+	if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "CSRF token missing"})
+		return false
+	}
+	requestToken := c.GetHeader("X-CSRF-Token")
+	if requestToken == "" || requestToken != cookieToken {
+		c.JSON(http.StatusForbidden, gin.H{"error": "CSRF token invalid"})
+		return false
+	}
+	return true
+}
+
+// AbuseReports renders the admin abuse queue. The list is loaded from AbuseReportsAPI.
+func (h *Handler) AbuseReports(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	if !h.isAdmin(user) {
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+		return
+	}
+
+	c.HTML(http.StatusOK, "abuse_reports.html", gin.H{
+		"User":       user,
+		"IsAdmin":    true,
+		"GitHubRepo": h.GitHubRepo,
+		"Version":    version.Version,
+	})
+}
+
+// AbuseReportsAPI returns abuse reports, optionally filtered by ?status=.
+func (h *Handler) AbuseReportsAPI(c *gin.Context) {
+	if !h.requireAdminAPI(c) {
+		return
+	}
+
+	reports, err := storage.GetAbuseReports(c.Query("status"))
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to load abuse reports")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load reports"})
+		return
+	}
+	suspended, err := storage.GetSuspendedDomains()
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to load suspended domains")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load reports"})
+		return
+	}
+	suspendedNames := make(map[string]bool, len(suspended))
+	for _, d := range suspended {
+		suspendedNames[d.Name] = true
+	}
+
+	rows := make([]AbuseReportRow, 0, len(reports))
+	for _, r := range reports {
+		row := AbuseReportRow{
+			ID:            r.ID,
+			CreatedAt:     r.CreatedAt,
+			TunnelURL:     r.TunnelURL,
+			Domain:        r.Domain,
+			ReportType:    r.ReportType,
+			Description:   r.Description,
+			ReporterEmail: r.ReporterEmail,
+			Status:        r.Status,
+		}
+		if name, ok := h.domainName(r.Domain); ok {
+			row.Suspended = suspendedNames[name]
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": rows})
+}
+
+// AbuseStatusRequest changes the review state of a report.
+type AbuseStatusRequest struct {
+	ID     uint   `json:"id"`
+	Status string `json:"status"`
+}
+
+// UpdateAbuseReport moves a report through the review workflow.
+func (h *Handler) UpdateAbuseReport(c *gin.Context) {
+	if !checkCSRF(c) || !h.requireAdminAPI(c) {
+		return
+	}
+
+	var req AbuseStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	switch req.Status {
+	case AbuseStatusPending, AbuseStatusReviewed, AbuseStatusResolved:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid status"})
+		return
+	}
+
+	if err := storage.UpdateAbuseReportStatus(req.ID, req.Status); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+			return
+		}
+		sentry.CaptureErrorWithContextf(c, err, "Failed to update abuse report %d", req.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SuspendRequest takes a domain down or lifts a takedown.
+type SuspendRequest struct {
+	Domain   string `json:"domain"`              // Tunnel hostname
+	Reason   string `json:"reason"`              // Shown in logs and events
+	ReportID uint   `json:"report_id,omitempty"` // Report resolved by the suspension
+}
+
+// SuspendDomain takes a domain down: its tunnel is disconnected, it can no
+// longer be bound and the ingress serves a takedown page instead.
+func (h *Handler) SuspendDomain(c *gin.Context) {
+	h.setSuspended(c, true)
+}
+
+// UnsuspendDomain lifts a takedown.
+func (h *Handler) UnsuspendDomain(c *gin.Context) {
+	h.setSuspended(c, false)
+}
+
+func (h *Handler) setSuspended(c *gin.Context, suspend bool) {
+	if !checkCSRF(c) || !h.requireAdminAPI(c) {
+		return
+	}
+
+	var req SuspendRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	host := reportedHost(req.Domain)
+	name, ok := h.domainName(host)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown domain"})
+		return
+	}
+
+	var err error
+	eventType := pubsub.EventDomainSuspended
+	if suspend {
+		err = storage.SuspendDomain(name, req.Reason)
+	} else {
+		err = storage.UnsuspendDomain(name)
+		eventType = pubsub.EventDomainUnsuspended
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to change suspension of %s", host)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update domain"})
+		return
+	}
+	log.Printf("Domain %s suspended=%v by admin: %s", host, suspend, req.Reason)
+
+	if h.Events != nil {
+		event := pubsub.Event{Type: eventType, Domain: host, Reason: req.Reason}
+		if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+			// Stored state still applies on restart and on the next bind
+			log.Printf("Failed to publish %s for %s: %v", eventType, host, err)
+		}
+	}
+
+	if suspend && req.ReportID != 0 {
+		if err := storage.UpdateAbuseReportStatus(req.ReportID, AbuseStatusResolved); err != nil {
+			log.Printf("Failed to resolve abuse report %d: %v", req.ReportID, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,31 @@
+package dashboard
+
+import "testing"
+
+func TestReportedHost(t *testing.T) {
+	tests := map[string]string{
+		"https://MyApp.example.com/login?x=1": "myapp.example.com",
+		"http://myapp.example.com:8080":       "myapp.example.com",
+		"myapp.example.com/path":              "myapp.example.com",
+		"  myapp.example.com  ":               "myapp.example.com",
+		"":                                    "",
+	}
+	for in, want := range tests {
+		if got := reportedHost(in); got != want {
+			t.Errorf("reportedHost(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestDomainName(t *testing.T) {
+	h := &Handler{Domain: "example.com"}
+
+	if name, ok := h.domainName("myapp.example.com"); !ok || name != "myapp" {
+		t.Errorf("domainName(myapp.example.com) = %q, %v", name, ok)
+	}
+	for _, host := range []string{"example.com", "myapp.other.com", ""} {
+		if name, ok := h.domainName(host); ok {
+			t.Errorf("domainName(%q) = %q, want rejection", host, name)
+		}
+	}
+}
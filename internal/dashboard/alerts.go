@@ -0,0 +1,223 @@
+package dashboard
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/alerts"
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// AlertRow is a single entry of the user's alert rules.
+type AlertRow struct {
+	ID             uint       `json:"id"`
+	Domain         string     `json:"domain"`
+	OfflineMinutes int        `json:"offline_minutes"`
+	Channel        string     `json:"channel"`
+	WebhookURL     string     `json:"webhook_url,omitempty"`
+	LastOnlineAt   *time.Time `json:"last_online_at,omitempty"`
+	Firing         bool       `json:"firing"`
+}
+
+// AlertRequest creates an alert rule.
+type AlertRequest struct {
+	Domain         string `json:"domain"`
+	OfflineMinutes int    `json:"offline_minutes"`
+	Channel        string `json:"channel"`
+	WebhookURL     string `json:"webhook_url"`
+}
+
+// Errors returned by validateAlert, shown to the user.
+var (
+	errAlertDomain  = errors.New("unknown domain")
+	errAlertMinutes = errors.New("offline threshold must be between 1 and 1440 minutes")
+	errAlertChannel = errors.New("notification channel is not available")
+)
+
+// alertDomain maps a domain name or FQDN to the monitored FQDN.
+func (h *Handler) alertDomain(domain string) string {
+	domain = strings.ToLower(strings.TrimSpace(domain))
+	if h.Domain != "" && !strings.HasSuffix(domain, "."+h.Domain) {
+		domain += "." + h.Domain
+	}
+	return domain
+}
+
+// validateAlert checks a new rule against the user's domains and channels
+// and returns the rule to store.
+func (h *Handler) validateAlert(user *models.User, domains []models.Domain, req AlertRequest) (*models.AlertRule, error) {
+	fqdn := h.alertDomain(req.Domain)
+	owned := false
+	for _, d := range domains {
+		if h.alertDomain(d.Name) == fqdn {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		return nil, errAlertDomain
+	}
+	if req.OfflineMinutes < alerts.MinOfflineMinutes || req.OfflineMinutes > alerts.MaxOfflineMinutes {
+		return nil, errAlertMinutes
+	}
+
+	available := false
+	for _, channel := range h.Alerts.Channels(user) {
+		if channel == req.Channel {
+			available = true
+			break
+		}
+	}
+	if !available {
+		return nil, errAlertChannel
+	}
+
+	rule := &models.AlertRule{
+		UserID:         user.ID,
+		Domain:         fqdn,
+		OfflineMinutes: req.OfflineMinutes,
+		Channel:        req.Channel,
+	}
+	if req.Channel == models.AlertChannelWebhook {
+		webhookURL := strings.TrimSpace(req.WebhookURL)
+		if err := alerts.ValidateWebhookURL(webhookURL); err != nil {
+			return nil, err
+		}
+		rule.WebhookURL = webhookURL
+	}
+	return rule, nil
+}
+
+func alertRows(rules []models.AlertRule) []AlertRow {
+	rows := make([]AlertRow, 0, len(rules))
+	for _, r := range rules {
+		rows = append(rows, AlertRow{
+			ID:             r.ID,
+			Domain:         r.Domain,
+			OfflineMinutes: r.OfflineMinutes,
+			Channel:        r.Channel,
+			WebhookURL:     r.WebhookURL,
+			LastOnlineAt:   r.LastOnlineAt,
+			Firing:         r.FiredAt != nil,
+		})
+	}
+	return rows
+}
+
+// AlertsAPI returns the user's alert rules and the channels available for new ones.
+func (h *Handler) AlertsAPI(c *gin.Context) {
+	if h.Alerts == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alerts are not enabled"})
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	rules, err := storage.GetUserAlertRules(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list alert rules for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":   alertRows(rules),
+		"channels": h.Alerts.Channels(user),
+	})
+}
+
+// CreateAlert adds an alert rule for one of the user's domains.
+func (h *Handler) CreateAlert(c *gin.Context) {
+	if h.Alerts == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alerts are not enabled"})
+		return
+	}
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req AlertRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	existing, err := storage.GetUserAlertRules(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list alert rules for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+	if len(existing) >= alerts.MaxRulesPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "Alert limit reached"})
+		return
+	}
+
+	domains, err := storage.GetUserDomains(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch domains for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+	rule, err := h.validateAlert(user, domains, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := storage.CreateAlertRule(rule); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to create alert rule for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert"})
+		return
+	}
+	c.JSON(http.StatusOK, alertRows([]models.AlertRule{*rule})[0])
+}
+
+// DeleteAlert removes one of the user's alert rules.
+func (h *Handler) DeleteAlert(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	err = storage.DeleteAlertRule(user.ID, req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to delete alert rule %d for user %d", req.ID, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,45 @@
+package dashboard
+
+import (
+	"errors"
+	"testing"
+
+	"gopublic/internal/alerts"
+	"gopublic/internal/models"
+)
+
+func TestValidateAlert(t *testing.T) {
+	h := &Handler{Domain: "example.com", Alerts: alerts.NewSender(nil)}
+	user := &models.User{}
+	user.ID = 3
+	domains := []models.Domain{{Name: "happy-cat"}}
+
+	rule, err := h.validateAlert(user, domains, AlertRequest{
+		Domain:         "Happy-Cat",
+		OfflineMinutes: 5,
+		Channel:        models.AlertChannelWebhook,
+		WebhookURL:     " https://hooks.example.org/x ",
+	})
+	if err != nil {
+		t.Fatalf("validateAlert() = %v", err)
+	}
+	if rule.UserID != 3 || rule.Domain != "happy-cat.example.com" || rule.WebhookURL != "https://hooks.example.org/x" {
+		t.Errorf("unexpected rule %+v", rule)
+	}
+
+	tests := []struct {
+		name string
+		req  AlertRequest
+		want error
+	}{
+		{"foreign domain", AlertRequest{Domain: "sad-dog", OfflineMinutes: 5, Channel: models.AlertChannelWebhook, WebhookURL: "https://hooks.example.org/x"}, errAlertDomain},
+		{"zero minutes", AlertRequest{Domain: "happy-cat", Channel: models.AlertChannelWebhook, WebhookURL: "https://hooks.example.org/x"}, errAlertMinutes},
+		{"unavailable channel", AlertRequest{Domain: "happy-cat", OfflineMinutes: 5, Channel: models.AlertChannelTelegram}, errAlertChannel},
+		{"private webhook", AlertRequest{Domain: "happy-cat.example.com", OfflineMinutes: 5, Channel: models.AlertChannelWebhook, WebhookURL: "http://127.0.0.1/"}, alerts.ErrPrivateAddress},
+	}
+	for _, tt := range tests {
+		if _, err := h.validateAlert(user, domains, tt.req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: validateAlert() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// API token limits.
+const (
+	maxAPITokens       = 10 // API tokens per user
+	maxAPITokenNameLen = 64
+)
+
+// APITokenRow is a single API token of the user.
+type APITokenRow struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// APITokenRequest creates an API token.
+type APITokenRequest struct {
+	Name string `json:"name"`
+}
+
+// errAPITokenName is returned by apiTokenName, shown to the user.
+var errAPITokenName = errors.New("name must be 1 to 64 characters")
+
+// apiTokenName returns the name to store for a new API token.
+func apiTokenName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPITokenNameLen {
+		return "", errAPITokenName
+	}
+	return name, nil
+}
+
+func apiTokenRows(tokens []models.APIToken) []APITokenRow {
+	rows := make([]APITokenRow, 0, len(tokens))
+	for _, t := range tokens {
+		rows = append(rows, APITokenRow{
+			ID:         t.ID,
+			Name:       t.Name,
+			CreatedAt:  t.CreatedAt,
+			LastUsedAt: t.LastUsedAt,
+		})
+	}
+	return rows
+}
+
+// APITokensAPI returns the user's API tokens: GET /api/api-tokens
+func (h *Handler) APITokensAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	tokens, err := storage.GetUserAPITokens(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list API tokens for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API tokens"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"api_tokens": apiTokenRows(tokens)})
+}
+
+// CreateAPIToken issues a token for the public API (/api/v1). It is shown
+// only this once.
+func (h *Handler) CreateAPIToken(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req APITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	record, token, ok := h.createAPIToken(c, user, req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"token": token, "id": record.ID, "name": record.Name})
+}
+
+// createAPIToken creates an API token of the user, answering the request
+// itself if that fails. Returns the record and the token.
+func (h *Handler) createAPIToken(c *gin.Context, user *models.User, req APITokenRequest) (*models.APIToken, string, bool) {
+	name, err := apiTokenName(req.Name)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, "", false
+	}
+
+	existing, err := storage.GetUserAPITokens(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list API tokens for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+		return nil, "", false
+	}
+	if len(existing) >= maxAPITokens {
+		c.JSON(http.StatusConflict, gin.H{"error": "API token limit reached; revoke one first"})
+		return nil, "", false
+	}
+
+	token, record, err := storage.CreateAPIToken(user.ID, name)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to create API token for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API token"})
+		return nil, "", false
+	}
+	log.Printf("User %d created API token %d (%q)", user.ID, record.ID, record.Name)
+	return record, token, true
+}
+
+// DeleteAPIToken revokes one of the user's API tokens.
+func (h *Handler) DeleteAPIToken(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if !h.deleteAPIToken(c, user, req.ID) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// deleteAPIToken revokes an API token of the user, answering the request
+// itself if that fails.
+func (h *Handler) deleteAPIToken(c *gin.Context, user *models.User, id uint) bool {
+	err := storage.DeleteAPIToken(user.ID, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+		return false
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to delete API token %d for user %d", id, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API token"})
+		return false
+	}
+	log.Printf("User %d revoked API token %d", user.ID, id)
+	return true
+}
@@ -0,0 +1,28 @@
+package dashboard
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestAPITokenName(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{"terraform", "terraform", nil},
+		{"  ci deploy ", "ci deploy", nil},
+		{"", "", errAPITokenName},
+		{"   ", "", errAPITokenName},
+		{strings.Repeat("я", maxAPITokenNameLen), strings.Repeat("я", maxAPITokenNameLen), nil},
+		{strings.Repeat("a", maxAPITokenNameLen+1), "", errAPITokenName},
+	}
+	for _, tt := range tests {
+		got, err := apiTokenName(tt.name)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("apiTokenName(%q) = %q, %v, want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
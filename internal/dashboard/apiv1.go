@@ -0,0 +1,443 @@
+package dashboard
+
+import (
+	_ "embed"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/server"
+	"gopublic/internal/storage"
+)
+
+// openAPISpec describes the public API; keep it in sync with the handlers
+// below.
+//
+//go:embed openapi.yaml
+var openAPISpec []byte
+
+// apiUserKey is the gin context key of the user authenticated by
+// RequireAPIToken.
+const apiUserKey = "api_user"
+
+// APIDomain is a domain of the user in the public API.
+type APIDomain struct {
+	ID        uint      `json:"id"`
+	Name      string    `json:"name"`
+	URL       string    `json:"url"`
+	Reserved  bool      `json:"reserved"`  // Chosen by the user rather than assigned at signup
+	Suspended bool      `json:"suspended"` // Taken down by an administrator
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// APIUsage is the user's bandwidth usage in the public API.
+type APIUsage struct {
+	Plan           string `json:"plan"`
+	BandwidthToday int64  `json:"bandwidth_today"`
+	BandwidthTotal int64  `json:"bandwidth_total"`
+	DailyLimit     int64  `json:"daily_limit"` // 0 = unlimited
+}
+
+// APIResource is a collection of the public API. Collections are served
+// at /api/v1/<name>, their items at /api/v1/<name>/<id>; handlers left nil
+// answer 405.
+type APIResource struct {
+	List   gin.HandlerFunc // GET of the collection
+	Create gin.HandlerFunc // POST to the collection
+	Get    gin.HandlerFunc // GET of an item
+	Delete gin.HandlerFunc // DELETE of an item
+}
+
+// APIResources returns the collections of the public API by name.
+func (h *Handler) APIResources() map[string]APIResource {
+	return map[string]APIResource{
+		"domains":     {List: h.APIv1Domains, Create: h.APIv1CreateDomain, Get: h.APIv1Domain, Delete: h.APIv1DeleteDomain},
+		"tokens":      {List: h.APIv1Tokens, Create: h.APIv1CreateToken, Get: h.APIv1Token, Delete: h.APIv1DeleteToken},
+		"definitions": {List: h.APIv1Definitions, Create: h.APIv1CreateDefinition, Get: h.APIv1Definition, Delete: h.APIv1DeleteDefinition},
+		"sessions":    {List: h.APIv1Sessions},
+		"usage":       {List: h.APIv1Usage},
+	}
+}
+
+// apiResourceID returns the ID of the requested item, answering 404 for
+// one that cannot exist.
+func apiResourceID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 0)
+	if err != nil || id == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Not found"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// requireMember answers 403 for API users who may only look.
+func (h *Handler) requireMember(c *gin.Context, user *models.User) bool {
+	if !roleAtLeast(h.role(user), models.RoleMember) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return false
+	}
+	return true
+}
+
+// bearerToken returns the token of an "Authorization: Bearer" header.
+func bearerToken(header string) string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// apiUser returns the user authenticated by RequireAPIToken.
+func apiUser(c *gin.Context) *models.User {
+	user, _ := c.MustGet(apiUserKey).(*models.User)
+	return user
+}
+
+// RequireAPIToken wraps a public API handler so that only requests with a
+// valid API token of an active user reach it. Each token is rate limited
+// on its own; tokens are counted before they are checked so that guessing
+// is throttled too.
+func (h *Handler) RequireAPIToken(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c.GetHeader("Authorization"))
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer realm="gopublic"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "API token required"})
+			return
+		}
+		if h.APILimiter != nil && !h.APILimiter.Allow(auth.HashToken(token)) {
+			c.Header("Retry-After", "1")
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Rate limit exceeded"})
+			return
+		}
+
+		user, _, err := storage.ValidateAPIToken(token)
+		if errors.Is(err, storage.ErrNotFound) {
+			c.Header("WWW-Authenticate", `Bearer realm="gopublic", error="invalid_token"`)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API token"})
+			return
+		} else if err != nil {
+			sentry.CaptureErrorWithContext(c, err, "Failed to validate API token")
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate API token"})
+			return
+		}
+		if user.Status != "" && user.Status != models.UserStatusActive {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Account is not active"})
+			return
+		}
+
+		c.Set(apiUserKey, user)
+		c.Header("Cache-Control", "no-store")
+		next(c)
+	}
+}
+
+// apiDomains converts the user's domains for the public API.
+func (h *Handler) apiDomains(domains []models.Domain) []APIDomain {
+	rows := make([]APIDomain, 0, len(domains))
+	for _, d := range domains {
+		rows = append(rows, APIDomain{
+			ID:        d.ID,
+			Name:      d.Name,
+			URL:       "https://" + h.domainHost(d.Name),
+			Reserved:  d.Reserved,
+			Suspended: d.SuspendedAt != nil,
+			CreatedAt: d.CreatedAt,
+		})
+	}
+	return rows
+}
+
+// APIv1Spec serves the OpenAPI specification of the public API:
+// GET /api/v1/openapi.yaml
+func (h *Handler) APIv1Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/yaml", openAPISpec)
+}
+
+// APIv1Domains lists the user's domains: GET /api/v1/domains
+func (h *Handler) APIv1Domains(c *gin.Context) {
+	user := apiUser(c)
+	domains, err := storage.GetUserDomains(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch domains for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load domains"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"domains": h.apiDomains(domains)})
+}
+
+// APIv1CreateDomain reserves a subdomain of the user's choice, up to the
+// limit of their plan: POST /api/v1/domains
+func (h *Handler) APIv1CreateDomain(c *gin.Context) {
+	user := apiUser(c)
+	if !h.requireMember(c, user) {
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	domain, ok := h.reserveDomain(c, user, req.Name)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusCreated, h.apiDomains([]models.Domain{*domain})[0])
+}
+
+// apiDomain finds the requested domain of the user, answering the request
+// itself if that fails.
+func (h *Handler) apiDomain(c *gin.Context, user *models.User) (*models.Domain, bool) {
+	id, ok := apiResourceID(c)
+	if !ok {
+		return nil, false
+	}
+	domains, err := storage.GetUserDomains(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch domains for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load domains"})
+		return nil, false
+	}
+	for i := range domains {
+		if domains[i].ID == id {
+			return &domains[i], true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+	return nil, false
+}
+
+// APIv1Domain returns one of the user's domains: GET /api/v1/domains/{id}
+func (h *Handler) APIv1Domain(c *gin.Context) {
+	domain, ok := h.apiDomain(c, apiUser(c))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, h.apiDomains([]models.Domain{*domain})[0])
+}
+
+// APIv1DeleteDomain releases one of the user's domains, making the name
+// available to others: DELETE /api/v1/domains/{id}
+func (h *Handler) APIv1DeleteDomain(c *gin.Context) {
+	user := apiUser(c)
+	if !h.requireMember(c, user) {
+		return
+	}
+	domain, ok := h.apiDomain(c, user)
+	if !ok {
+		return
+	}
+	if domain.SuspendedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Suspended domains cannot be released"})
+		return
+	}
+
+	err := storage.ReleaseDomain(user.ID, domain.Name)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to release domain %s for user %d", domain.Name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release domain"})
+		return
+	}
+	log.Printf("User %d released domain %s", user.ID, domain.Name)
+	h.announceRelease(c.Request.Context(), domain.Name)
+
+	c.Status(http.StatusNoContent)
+}
+
+// APIv1Tokens lists the user's API tokens: GET /api/v1/tokens
+func (h *Handler) APIv1Tokens(c *gin.Context) {
+	user := apiUser(c)
+	tokens, err := storage.GetUserAPITokens(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list API tokens for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": apiTokenRows(tokens)})
+}
+
+// APIv1CreateToken creates an API token; the response is the only time
+// the token is shown: POST /api/v1/tokens
+func (h *Handler) APIv1CreateToken(c *gin.Context) {
+	user := apiUser(c)
+	if !h.requireMember(c, user) {
+		return
+	}
+
+	var req APITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	record, token, ok := h.createAPIToken(c, user, req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusCreated, gin.H{"token": token, "api_token": apiTokenRows([]models.APIToken{*record})[0]})
+}
+
+// APIv1Token returns one of the user's API tokens: GET /api/v1/tokens/{id}
+func (h *Handler) APIv1Token(c *gin.Context) {
+	user := apiUser(c)
+	id, ok := apiResourceID(c)
+	if !ok {
+		return
+	}
+	tokens, err := storage.GetUserAPITokens(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list API tokens for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load API tokens"})
+		return
+	}
+	for _, row := range apiTokenRows(tokens) {
+		if row.ID == id {
+			c.JSON(http.StatusOK, row)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "API token not found"})
+}
+
+// APIv1DeleteToken revokes one of the user's API tokens, the one sending
+// the request included: DELETE /api/v1/tokens/{id}
+func (h *Handler) APIv1DeleteToken(c *gin.Context) {
+	user := apiUser(c)
+	if !h.requireMember(c, user) {
+		return
+	}
+	id, ok := apiResourceID(c)
+	if !ok || !h.deleteAPIToken(c, user, id) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// apiDefinitions lists the user's tunnel definitions with the managed
+// clients that run them, answering the request itself if that fails.
+func (h *Handler) apiDefinitions(c *gin.Context, user *models.User) ([]DefinitionRow, bool) {
+	defs, err := storage.GetUserTunnelDefinitions(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list tunnel definitions for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tunnel definitions"})
+		return nil, false
+	}
+	var agents []server.AgentInfo
+	if h.Fleet != nil {
+		agents = h.Fleet.Agents(user.ID)
+	}
+	return definitionRows(defs, agents), true
+}
+
+// APIv1Definitions lists the user's tunnel definitions:
+// GET /api/v1/definitions
+func (h *Handler) APIv1Definitions(c *gin.Context) {
+	rows, ok := h.apiDefinitions(c, apiUser(c))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"definitions": rows})
+}
+
+// APIv1CreateDefinition defines a tunnel for the user's managed clients:
+// POST /api/v1/definitions
+func (h *Handler) APIv1CreateDefinition(c *gin.Context) {
+	user := apiUser(c)
+	if !h.requireMember(c, user) {
+		return
+	}
+
+	var req DefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	def, ok := h.defineTunnel(c, user, req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusCreated, definitionRows([]models.TunnelDefinition{*def}, nil)[0])
+}
+
+// APIv1Definition returns one of the user's tunnel definitions:
+// GET /api/v1/definitions/{id}
+func (h *Handler) APIv1Definition(c *gin.Context) {
+	user := apiUser(c)
+	id, ok := apiResourceID(c)
+	if !ok {
+		return
+	}
+	rows, ok := h.apiDefinitions(c, user)
+	if !ok {
+		return
+	}
+	for _, row := range rows {
+		if row.ID == id {
+			c.JSON(http.StatusOK, row)
+			return
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Tunnel definition not found"})
+}
+
+// APIv1DeleteDefinition removes one of the user's tunnel definitions:
+// DELETE /api/v1/definitions/{id}
+func (h *Handler) APIv1DeleteDefinition(c *gin.Context) {
+	user := apiUser(c)
+	if !h.requireMember(c, user) {
+		return
+	}
+	id, ok := apiResourceID(c)
+	if !ok || !h.deleteDefinition(c, user, id) {
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// APIv1Sessions lists the user's connected clients: GET /api/v1/sessions
+func (h *Handler) APIv1Sessions(c *gin.Context) {
+	user := apiUser(c)
+	var agents []server.AgentInfo
+	if h.Fleet != nil {
+		agents = h.Fleet.Agents(user.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": fleetRows(agents, time.Now())})
+}
+
+// APIv1Usage returns the user's bandwidth usage: GET /api/v1/usage
+func (h *Handler) APIv1Usage(c *gin.Context) {
+	user := apiUser(c)
+	today, err := storage.GetUserBandwidthToday(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch bandwidth for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+	total, err := storage.GetUserTotalBandwidth(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch bandwidth for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, APIUsage{
+		Plan:           user.Plan,
+		BandwidthToday: today,
+		BandwidthTotal: total,
+		DailyLimit:     h.PlanBandwidth.Bandwidth(user.Plan, h.DailyBandwidthLimit),
+	})
+}
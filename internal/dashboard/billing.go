@@ -0,0 +1,192 @@
+package dashboard
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/billing"
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// maxWebhookBody limits the size of a Stripe webhook payload.
+const maxWebhookBody = 1 << 20
+
+// appURL returns the absolute URL of a dashboard path.
+func (h *Handler) appURL(path string) string {
+	if h.Domain == "localhost" || h.Domain == "127.0.0.1" {
+		return fmt.Sprintf("http://%s%s", h.Domain, path)
+	}
+	return fmt.Sprintf("https://app.%s%s", h.Domain, path)
+}
+
+// purchasablePlans lists the plans offered on the dashboard, nil when
+// billing is disabled.
+func (h *Handler) purchasablePlans() []string {
+	if h.Billing == nil {
+		return nil
+	}
+	return h.Billing.Plans.Names()
+}
+
+// BillingCheckout starts a Stripe Checkout for the requested plan and
+// returns the payment page URL.
+func (h *Handler) BillingCheckout(c *gin.Context) {
+	if h.Billing == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Billing is not enabled"})
+		return
+	}
+	if !checkCSRF(c) {
+		return
+	}
+
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req struct {
+		Plan string `json:"plan"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Plan == user.Plan {
+		c.JSON(http.StatusConflict, gin.H{"error": "Plan is already active"})
+		return
+	}
+
+	checkoutURL, err := h.Billing.CreateCheckoutSession(c.Request.Context(), billing.CheckoutRequest{
+		Plan:       req.Plan,
+		UserID:     user.ID,
+		CustomerID: user.StripeCustomerID,
+		Email:      user.Email,
+		SuccessURL: h.appURL("/?billing=success"),
+		CancelURL:  h.appURL("/?billing=cancel"),
+	})
+	if errors.Is(err, billing.ErrUnknownPlan) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown plan"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to create checkout for user %d", user.ID)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Payment provider unavailable"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"url": checkoutURL})
+}
+
+// BillingWebhook receives Stripe events and applies subscription changes
+// to user plans. Cancelled or lapsed subscriptions downgrade to the free plan.
+func (h *Handler) BillingWebhook(c *gin.Context) {
+	if h.Billing == nil {
+		c.Status(http.StatusNotFound)
+		return
+	}
+
+	payload, err := io.ReadAll(io.LimitReader(c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.Status(http.StatusBadRequest)
+		return
+	}
+	event, err := h.Billing.ParseEvent(payload, c.GetHeader("Stripe-Signature"))
+	if err != nil {
+		log.Printf("Rejected Stripe webhook: %v", err)
+		c.Status(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.applyBillingEvent(c.Request.Context(), event); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to apply Stripe event %s (%s)", event.ID, event.Type)
+		c.Status(http.StatusInternalServerError) // Stripe retries failed deliveries
+		return
+	}
+	c.Status(http.StatusOK)
+}
+
+// applyBillingEvent updates the plan of the user a Stripe event refers to.
+// Events for unknown customers are acknowledged and ignored.
+func (h *Handler) applyBillingEvent(ctx context.Context, event *billing.Event) error {
+	switch event.Type {
+	case billing.EventCheckoutCompleted:
+		var session billing.CheckoutSession
+		if err := json.Unmarshal(event.Data.Object, &session); err != nil {
+			return err
+		}
+		userID, err := strconv.ParseUint(session.ClientReferenceID, 10, 64)
+		if err != nil {
+			log.Printf("Stripe checkout %s has no user reference", session.ID)
+			return nil
+		}
+		plan := session.Metadata["plan"]
+		if _, ok := h.Billing.Plans[plan]; !ok {
+			log.Printf("Stripe checkout %s is for unknown plan %q", session.ID, plan)
+			return nil
+		}
+		return h.setPlan(ctx, uint(userID), plan, session.Customer, session.Subscription)
+
+	case billing.EventSubscriptionUpdated, billing.EventSubscriptionDeleted:
+		var sub billing.Subscription
+		if err := json.Unmarshal(event.Data.Object, &sub); err != nil {
+			return err
+		}
+		user, err := storage.GetUserByStripeCustomer(sub.Customer)
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Stripe subscription %s belongs to unknown customer %s", sub.ID, sub.Customer)
+			return nil
+		} else if err != nil {
+			return err
+		}
+		// Ignore updates for a subscription the user has already replaced
+		if user.StripeSubscriptionID != "" && user.StripeSubscriptionID != sub.ID {
+			return nil
+		}
+
+		plan := h.Billing.Plans.SubscriptionPlan(&sub)
+		if event.Type == billing.EventSubscriptionDeleted {
+			plan = models.PlanFree
+		}
+		subscriptionID := sub.ID
+		if plan == models.PlanFree {
+			subscriptionID = ""
+		}
+		return h.setPlan(ctx, user.ID, plan, sub.Customer, subscriptionID)
+	}
+	return nil
+}
+
+// setPlan stores the user's plan and applies it to running tunnels.
+func (h *Handler) setPlan(ctx context.Context, userID uint, plan, customerID, subscriptionID string) error {
+	if err := storage.UpdateUserBilling(userID, plan, customerID, subscriptionID); err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			log.Printf("Stripe event refers to unknown user %d", userID)
+			return nil
+		}
+		return err
+	}
+	log.Printf("User %d is now on plan %q", userID, plan)
+
+	if h.Events != nil {
+		event := pubsub.Event{Type: pubsub.EventPlanChanged, UserID: userID, Plan: plan}
+		if err := h.Events.Publish(ctx, event); err != nil {
+			log.Printf("Failed to publish plan change for user %d: %v", userID, err)
+		}
+	}
+	return nil
+}
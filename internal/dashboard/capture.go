@@ -0,0 +1,125 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/capture"
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// CaptureProvider returns the exchanges the ingress recorded for a domain.
+// This interface is implemented by capture.Log.
+type CaptureProvider interface {
+	Recent(host string) []capture.Exchange
+}
+
+// CaptureRequest turns server-side capture of a domain on or off.
+type CaptureRequest struct {
+	Domain  string `json:"domain"`
+	Enabled bool   `json:"enabled"`
+}
+
+// SetCaptures sets the provider of server-side captures.
+func (h *Handler) SetCaptures(provider CaptureProvider) {
+	h.Captures = provider
+}
+
+// ownedDomain returns the user's domain called name.
+func ownedDomain(domains []models.Domain, name string) (models.Domain, bool) {
+	for _, d := range domains {
+		if d.Name == name {
+			return d, true
+		}
+	}
+	return models.Domain{}, false
+}
+
+// SetDomainCapture makes the ingress record recent exchanges of one of
+// the user's domains, for clients running without the local inspector.
+// Turning it off drops the records.
+func (h *Handler) SetDomainCapture(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+	if h.Captures == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture is not available"})
+		return
+	}
+
+	var req CaptureRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Domain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	name := h.subdomainName(req.Domain)
+
+	err = storage.SetDomainCapture(user.ID, name, req.Enabled)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set capture of %s for user %d", name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update domain"})
+		return
+	}
+	log.Printf("User %d set capture of %s to %v", user.ID, name, req.Enabled)
+
+	if h.Events != nil {
+		event := pubsub.Event{Type: pubsub.EventCaptureChanged, UserID: user.ID, Domain: h.domainHost(name)}
+		if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+			// Instances pick up the change on restart
+			log.Printf("Failed to publish capture of %s: %v", name, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// CapturesAPI returns the recorded exchanges of one of the user's domains:
+// GET /api/domains/captures?domain=...
+func (h *Handler) CapturesAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if h.Captures == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture is not available"})
+		return
+	}
+
+	domains, err := storage.GetUserDomains(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch domains for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch captures"})
+		return
+	}
+	domain, ok := ownedDomain(domains, h.subdomainName(c.Query("domain")))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	}
+
+	exchanges := []capture.Exchange{}
+	if domain.Capture {
+		exchanges = h.Captures.Recent(h.domainHost(domain.Name))
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"domain": domain.Name, "enabled": domain.Capture, "exchanges": exchanges})
+}
@@ -0,0 +1,436 @@
+package dashboard
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// Actions that require a second factor when ConfirmActions is enabled.
+// Turning the authenticator on or off is always confirmed.
+const (
+	ActionRegenerateToken = "regenerate_token"
+	ActionReleaseDomain   = "release_domain"
+	ActionTransferDomain  = "transfer_domain"
+	ActionDeleteAccount   = "delete_account"
+	ActionEnableTOTP      = "enable_totp"
+	ActionDisableTOTP     = "disable_totp"
+	ActionCreateJoinToken = "create_join_token"
+	ActionBanUser         = "ban_user"
+	ActionCreateAPIToken  = "create_api_token"
+)
+
+// actionLabels describe actions in the Telegram confirmation message.
+var actionLabels = map[string]string{
+	ActionRegenerateToken: "перевыпуск токена",
+	ActionReleaseDomain:   "освобождение домена",
+	ActionTransferDomain:  "передача домена",
+	ActionDeleteAccount:   "удаление аккаунта",
+	ActionEnableTOTP:      "включение двухфакторной аутентификации",
+	ActionDisableTOTP:     "отключение двухфакторной аутентификации",
+	ActionCreateJoinToken: "создание токена для подключения агента",
+	ActionBanUser:         "блокировка пользователя",
+	ActionCreateAPIToken:  "создание API-токена",
+}
+
+// ConfirmHeader carries the confirmation code of a retried request.
+const ConfirmHeader = "X-Confirm-Code"
+
+// Second factor methods.
+const (
+	confirmTOTP     = "totp"
+	confirmTelegram = "telegram"
+)
+
+// Limits for codes sent through Telegram. confirmMaxAttempts also caps
+// failed authenticator codes, after which they are refused for totpLockout.
+const (
+	confirmCodeTTL      = 5 * time.Minute
+	confirmMaxAttempts  = 5
+	confirmResendPeriod = 30 * time.Second
+	totpLockout         = 5 * time.Minute
+)
+
+// totpIssuer names the service in authenticator apps.
+const totpIssuer = "gopublic"
+
+// Errors returned by confirmStore.verify.
+var (
+	errConfirmCodeExpired = errors.New("confirmation code expired")
+	errConfirmCodeInvalid = errors.New("invalid confirmation code")
+	errConfirmLocked      = errors.New("too many invalid confirmation codes")
+)
+
+// pendingConfirm is a code sent to the user for one action.
+type pendingConfirm struct {
+	code     string
+	sentAt   time.Time
+	attempts int
+}
+
+// confirmStore keeps the codes sent through Telegram. The zero value is ready to use.
+type confirmStore struct {
+	mu      sync.Mutex
+	pending map[string]*pendingConfirm // "userID:action" -> code
+}
+
+func confirmKey(userID uint, action string) string {
+	return fmt.Sprintf("%d:%s", userID, action)
+}
+
+// issue creates a code for the action. It returns "" if a code was sent
+// within the resend period, in which case the old code stays valid.
+func (s *confirmStore) issue(userID uint, action string, now time.Time) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pending == nil {
+		s.pending = make(map[string]*pendingConfirm)
+	}
+	for k, p := range s.pending {
+		if now.Sub(p.sentAt) >= confirmCodeTTL {
+			delete(s.pending, k)
+		}
+	}
+
+	key := confirmKey(userID, action)
+	if p, ok := s.pending[key]; ok && now.Sub(p.sentAt) < confirmResendPeriod {
+		return "", nil
+	}
+	code, err := auth.GenerateConfirmCode()
+	if err != nil {
+		return "", err
+	}
+	s.pending[key] = &pendingConfirm{code: code, sentAt: now}
+	return code, nil
+}
+
+// verify checks a code. A code is used up by a successful check or by
+// too many failed ones.
+func (s *confirmStore) verify(userID uint, action, code string, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := confirmKey(userID, action)
+	p, ok := s.pending[key]
+	if !ok || now.Sub(p.sentAt) >= confirmCodeTTL {
+		delete(s.pending, key)
+		return errConfirmCodeExpired
+	}
+	if subtle.ConstantTimeCompare([]byte(p.code), []byte(code)) != 1 {
+		p.attempts++
+		if p.attempts >= confirmMaxAttempts {
+			delete(s.pending, key)
+		}
+		return errConfirmCodeInvalid
+	}
+	delete(s.pending, key)
+	return nil
+}
+
+// totpUsage is the authenticator state of one user.
+type totpUsage struct {
+	failures    int
+	lockedUntil time.Time
+	lastStep    int64 // Time step of the last accepted code
+}
+
+// totpGuard limits guessing of authenticator codes and refuses replayed
+// ones. The zero value is ready to use.
+type totpGuard struct {
+	mu    sync.Mutex
+	users map[uint]*totpUsage
+}
+
+// verify checks an authenticator code. After confirmMaxAttempts failures
+// all codes are refused for totpLockout. A code is accepted once: codes
+// from the last accepted time step or earlier are rejected.
+func (g *totpGuard) verify(userID uint, secret, code string, now time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.users == nil {
+		g.users = make(map[uint]*totpUsage)
+	}
+	u, ok := g.users[userID]
+	if !ok {
+		u = &totpUsage{}
+		g.users[userID] = u
+	}
+	if now.Before(u.lockedUntil) {
+		return errConfirmLocked
+	}
+
+	step, ok := auth.MatchTOTP(secret, code, now)
+	if !ok || step <= u.lastStep {
+		u.failures++
+		if u.failures >= confirmMaxAttempts {
+			u.failures = 0
+			u.lockedUntil = now.Add(totpLockout)
+		}
+		return errConfirmCodeInvalid
+	}
+	u.failures = 0
+	u.lastStep = step
+	return nil
+}
+
+// confirmMethod returns the second factor available to the user, or "" if
+// the user has none and actions go through unconfirmed.
+func (h *Handler) confirmMethod(user *models.User) string {
+	switch {
+	case user.TOTPEnabled && user.TOTPSecret != "":
+		return confirmTOTP
+	case user.TelegramID != nil && h.BotToken != "":
+		return confirmTelegram
+	}
+	return ""
+}
+
+// RequireConfirmation wraps a destructive API handler. The first request
+// is answered with 428 Precondition Required and, for Telegram users, a
+// code is sent through the bot; the client then repeats the request with
+// the code in the X-Confirm-Code header.
+func (h *Handler) RequireConfirmation(action string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !checkCSRF(c) {
+			return
+		}
+		if !h.ConfirmActions && action != ActionEnableTOTP && action != ActionDisableTOTP {
+			next(c)
+			return
+		}
+
+		user, err := h.getUserFromSession(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		method := h.confirmMethod(user)
+		if method == "" {
+			next(c)
+			return
+		}
+
+		code := strings.TrimSpace(c.GetHeader(ConfirmHeader))
+		if code == "" {
+			if method == confirmTelegram {
+				sent, err := h.confirms.issue(user.ID, action, time.Now())
+				if err != nil {
+					sentry.CaptureErrorWithContextf(c, err, "Failed to issue confirmation code for user %d", user.ID)
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send confirmation code"})
+					return
+				}
+				if sent != "" {
+					h.sendTelegramMessage(*user.TelegramID, fmt.Sprintf(
+						"🔐 Код подтверждения (%s): `%s`\n\nКод действует 5 минут. Если это были не вы, перевыпустите токен.",
+						actionLabels[action], sent))
+				}
+			}
+			c.JSON(http.StatusPreconditionRequired, gin.H{"error": "Confirmation required", "confirm": method})
+			return
+		}
+
+		if method == confirmTOTP {
+			err = h.totp.verify(user.ID, user.TOTPSecret, code, time.Now())
+		} else {
+			err = h.confirms.verify(user.ID, action, code, time.Now())
+		}
+		if errors.Is(err, errConfirmLocked) {
+			c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many invalid codes, try again later", "confirm": method})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Invalid confirmation code", "confirm": method})
+			return
+		}
+		log.Printf("User %d confirmed %s via %s", user.ID, action, method)
+		next(c)
+	}
+}
+
+// ReleaseDomain gives up one of the user's domains. Its tunnel is
+// disconnected and the name becomes available to other users.
+func (h *Handler) ReleaseDomain(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Domain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(req.Domain))
+	if h.Domain != "" {
+		name = strings.TrimSuffix(name, "."+h.Domain)
+	}
+
+	err = storage.ReleaseDomain(user.ID, name)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to release domain %s for user %d", name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release domain"})
+		return
+	}
+	log.Printf("User %d released domain %s", user.ID, name)
+	h.announceRelease(c.Request.Context(), name)
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// announceRelease disconnects the tunnel of a released domain on every
+// instance.
+func (h *Handler) announceRelease(ctx context.Context, name string) {
+	if h.Events == nil {
+		return
+	}
+	host := h.domainHost(name)
+	event := pubsub.Event{Type: pubsub.EventDomainRevoked, Domain: host, Reason: "domain released"}
+	if err := h.Events.Publish(ctx, event); err != nil {
+		log.Printf("Failed to publish release of %s: %v", host, err)
+	}
+}
+
+// DeleteAccount deletes the user's account and disconnects its tunnels.
+// The user's domains are recycled by the domain cleanup job.
+func (h *Handler) DeleteAccount(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	if err := storage.DeleteUser(user.ID); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to delete user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete account"})
+		return
+	}
+	log.Printf("User %d deleted their account", user.ID)
+
+	if h.Events != nil {
+		event := pubsub.Event{Type: pubsub.EventForceDisconnect, UserID: user.ID, Reason: "account deleted"}
+		if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+			log.Printf("Failed to disconnect deleted user %d: %v", user.ID, err)
+		}
+	}
+
+	h.Session.ClearSession(c.Writer)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// TOTPSetup generates a new authenticator secret. It takes effect once
+// TOTPEnable receives a valid code for it. Both are always wrapped in
+// RequireConfirmation, which asks for the factor the user had before
+// enrollment, so that a stolen session cannot bind its own authenticator.
+func (h *Handler) TOTPSetup(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to generate TOTP secret for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+		return
+	}
+	if err := storage.SetTOTP(user.ID, secret, false); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to store TOTP secret for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save secret"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"secret": secret,
+		"uri":    auth.TOTPURI(totpIssuer, totpAccount(user), secret),
+	})
+}
+
+// TOTPEnable turns on the authenticator set up by TOTPSetup.
+func (h *Handler) TOTPEnable(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not set up"})
+		return
+	}
+
+	var req struct {
+		Code string `json:"code"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	err = h.totp.verify(user.ID, user.TOTPSecret, strings.TrimSpace(req.Code), time.Now())
+	if errors.Is(err, errConfirmLocked) {
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many invalid codes, try again later"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid confirmation code"})
+		return
+	}
+
+	if err := storage.SetTOTP(user.ID, user.TOTPSecret, true); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to enable TOTP for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"totp_enabled": true})
+}
+
+// TOTPDisable removes the user's authenticator. It is always wrapped in
+// RequireConfirmation so that a stolen session cannot turn it off.
+func (h *Handler) TOTPDisable(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if err := storage.SetTOTP(user.ID, "", false); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to disable TOTP for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"totp_enabled": false})
+}
+
+// totpAccount names the user's entry in authenticator apps.
+func totpAccount(user *models.User) string {
+	switch {
+	case user.Username != "":
+		return user.Username
+	case user.Email != "":
+		return user.Email
+	}
+	return fmt.Sprintf("user-%d", user.ID)
+}
@@ -0,0 +1,136 @@
+package dashboard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+)
+
+func TestConfirmStore(t *testing.T) {
+	var s confirmStore
+	now := time.Now()
+
+	code, err := s.issue(1, ActionDeleteAccount, now)
+	if err != nil || len(code) != 6 {
+		t.Fatalf("issue() = %q, %v; want a 6-digit code", code, err)
+	}
+	if again, _ := s.issue(1, ActionDeleteAccount, now.Add(10*time.Second)); again != "" {
+		t.Errorf("issue() within resend period = %q, want empty", again)
+	}
+
+	if err := s.verify(1, ActionRegenerateToken, code, now); !errors.Is(err, errConfirmCodeExpired) {
+		t.Errorf("verify() for another action = %v, want errConfirmCodeExpired", err)
+	}
+	if err := s.verify(2, ActionDeleteAccount, code, now); !errors.Is(err, errConfirmCodeExpired) {
+		t.Errorf("verify() for another user = %v, want errConfirmCodeExpired", err)
+	}
+	if err := s.verify(1, ActionDeleteAccount, code, now); err != nil {
+		t.Fatalf("verify() = %v, want nil", err)
+	}
+	if err := s.verify(1, ActionDeleteAccount, code, now); !errors.Is(err, errConfirmCodeExpired) {
+		t.Errorf("verify() reusing code = %v, want errConfirmCodeExpired", err)
+	}
+}
+
+func TestConfirmStoreExpiry(t *testing.T) {
+	var s confirmStore
+	now := time.Now()
+
+	code, _ := s.issue(1, ActionReleaseDomain, now)
+	if err := s.verify(1, ActionReleaseDomain, code, now.Add(confirmCodeTTL)); !errors.Is(err, errConfirmCodeExpired) {
+		t.Errorf("verify() after TTL = %v, want errConfirmCodeExpired", err)
+	}
+}
+
+func TestConfirmStoreAttempts(t *testing.T) {
+	var s confirmStore
+	now := time.Now()
+
+	code, _ := s.issue(1, ActionReleaseDomain, now)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+	for i := 0; i < confirmMaxAttempts; i++ {
+		if err := s.verify(1, ActionReleaseDomain, wrong, now); !errors.Is(err, errConfirmCodeInvalid) {
+			t.Fatalf("attempt %d: verify() = %v, want errConfirmCodeInvalid", i+1, err)
+		}
+	}
+	if err := s.verify(1, ActionReleaseDomain, code, now); !errors.Is(err, errConfirmCodeExpired) {
+		t.Errorf("verify() after too many attempts = %v, want errConfirmCodeExpired", err)
+	}
+}
+
+func TestConfirmMethod(t *testing.T) {
+	tgID := int64(42)
+	tests := []struct {
+		name     string
+		botToken string
+		user     *models.User
+		want     string
+	}{
+		{"totp", "token", &models.User{TOTPEnabled: true, TOTPSecret: "JBSWY3DPEHPK3PXP", TelegramID: &tgID}, confirmTOTP},
+		{"totp not confirmed", "token", &models.User{TOTPSecret: "JBSWY3DPEHPK3PXP", TelegramID: &tgID}, confirmTelegram},
+		{"telegram", "token", &models.User{TelegramID: &tgID}, confirmTelegram},
+		{"telegram without bot", "", &models.User{TelegramID: &tgID}, ""},
+		{"no factor", "token", &models.User{Email: "user@example.com"}, ""},
+	}
+	for _, tt := range tests {
+		h := &Handler{BotToken: tt.botToken}
+		if got := h.confirmMethod(tt.user); got != tt.want {
+			t.Errorf("%s: confirmMethod() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTOTPGuard(t *testing.T) {
+	var g totpGuard
+	secret := "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000010, 0)
+	code, _ := auth.TOTPCode(secret, now)
+
+	if err := g.verify(1, secret, code, now); err != nil {
+		t.Fatalf("verify() = %v, want nil", err)
+	}
+	if err := g.verify(1, secret, code, now.Add(time.Second)); !errors.Is(err, errConfirmCodeInvalid) {
+		t.Errorf("verify() reusing code = %v, want errConfirmCodeInvalid", err)
+	}
+	if err := g.verify(2, secret, code, now); err != nil {
+		t.Errorf("verify() for another user = %v, want nil", err)
+	}
+
+	later := now.Add(time.Minute)
+	next, _ := auth.TOTPCode(secret, later)
+	if err := g.verify(1, secret, next, later); err != nil {
+		t.Errorf("verify() with a later code = %v, want nil", err)
+	}
+}
+
+func TestTOTPGuardLockout(t *testing.T) {
+	var g totpGuard
+	secret := "JBSWY3DPEHPK3PXP"
+	now := time.Unix(1700000010, 0)
+	code, _ := auth.TOTPCode(secret, now)
+	wrong := "000000"
+	if code == wrong {
+		wrong = "111111"
+	}
+
+	for i := 0; i < confirmMaxAttempts; i++ {
+		if err := g.verify(1, secret, wrong, now); !errors.Is(err, errConfirmCodeInvalid) {
+			t.Fatalf("attempt %d: verify() = %v, want errConfirmCodeInvalid", i+1, err)
+		}
+	}
+	if err := g.verify(1, secret, code, now); !errors.Is(err, errConfirmLocked) {
+		t.Errorf("verify() after too many attempts = %v, want errConfirmLocked", err)
+	}
+
+	later := now.Add(totpLockout)
+	code, _ = auth.TOTPCode(secret, later)
+	if err := g.verify(1, secret, code, later); err != nil {
+		t.Errorf("verify() after lockout = %v, want nil", err)
+	}
+}
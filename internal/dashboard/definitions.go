@@ -0,0 +1,257 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/server"
+	"gopublic/internal/storage"
+	"gopublic/pkg/protocol"
+)
+
+// maxDefinitionsPerUser caps the tunnels a user can define in the dashboard.
+const maxDefinitionsPerUser = 50
+
+// DefinitionRow is a single tunnel defined in the dashboard.
+type DefinitionRow struct {
+	ID        uint     `json:"id"`
+	Name      string   `json:"name"`
+	Subdomain string   `json:"subdomain"`
+	Port      int      `json:"port"`
+	Selector  string   `json:"selector"`
+	Agents    []string `json:"agents"` // Connected managed clients that run it
+}
+
+// DefinitionRequest defines a tunnel run by managed clients.
+type DefinitionRequest struct {
+	Name      string `json:"name"`
+	Subdomain string `json:"subdomain"`
+	Port      int    `json:"port"`
+	Selector  string `json:"selector"`
+}
+
+// Errors returned by validateDefinition, shown to the user.
+var (
+	errDefinitionName      = errors.New("name must be lowercase letters, digits, dots, dashes or underscores")
+	errDefinitionDuplicate = errors.New("a tunnel with this name or subdomain is already defined")
+	errDefinitionDomain    = errors.New("unknown domain")
+	errDefinitionPort      = errors.New("port must be between 1 and 65535")
+)
+
+// validateDefinition checks a new definition against the user's domains and
+// existing definitions and returns the definition to store.
+func (h *Handler) validateDefinition(userID uint, domains []models.Domain, existing []models.TunnelDefinition, req DefinitionRequest) (*models.TunnelDefinition, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || protocol.ValidateAgent(name) != nil {
+		return nil, errDefinitionName
+	}
+	subdomain := strings.ToLower(strings.TrimSpace(req.Subdomain))
+	if h.Domain != "" {
+		subdomain = strings.TrimSuffix(subdomain, "."+h.Domain)
+	}
+	owned := false
+	for _, d := range domains {
+		if d.Name == subdomain {
+			owned = true
+			break
+		}
+	}
+	if !owned {
+		return nil, errDefinitionDomain
+	}
+	for _, d := range existing {
+		if d.Name == name || d.Domain == subdomain {
+			return nil, errDefinitionDuplicate
+		}
+	}
+	if req.Port < 1 || req.Port > 65535 {
+		return nil, errDefinitionPort
+	}
+	selector := strings.TrimSpace(req.Selector)
+	if err := protocol.ValidateSelector(selector); err != nil {
+		return nil, err
+	}
+
+	return &models.TunnelDefinition{
+		UserID:   userID,
+		Name:     name,
+		Domain:   subdomain,
+		Port:     req.Port,
+		Selector: selector,
+	}, nil
+}
+
+// definitionRows converts the user's definitions, listing the connected
+// managed clients whose labels match each one.
+func definitionRows(defs []models.TunnelDefinition, agents []server.AgentInfo) []DefinitionRow {
+	rows := make([]DefinitionRow, 0, len(defs))
+	for _, d := range defs {
+		row := DefinitionRow{
+			ID:        d.ID,
+			Name:      d.Name,
+			Subdomain: d.Domain,
+			Port:      d.Port,
+			Selector:  d.Selector,
+			Agents:    []string{},
+		}
+		for _, a := range agents {
+			if a.Managed && protocol.MatchSelector(d.Selector, a.Labels) {
+				row.Agents = append(row.Agents, a.Agent)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// reloadManaged asks the user's managed clients that run definitions of
+// the selector to fetch them again.
+func (h *Handler) reloadManaged(ctx context.Context, userID uint, selector string) {
+	if h.Events == nil {
+		return
+	}
+	event := pubsub.Event{Type: pubsub.EventDefinitionsChanged, UserID: userID, Selector: selector}
+	if err := h.Events.Publish(ctx, event); err != nil {
+		log.Printf("Failed to announce tunnel definition change for user %d: %v", userID, err)
+	}
+}
+
+// DefinitionsAPI returns the user's tunnel definitions: GET /api/definitions
+func (h *Handler) DefinitionsAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	defs, err := storage.GetUserTunnelDefinitions(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list tunnel definitions for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tunnel definitions"})
+		return
+	}
+	var agents []server.AgentInfo
+	if h.Fleet != nil {
+		agents = h.Fleet.Agents(user.ID)
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"definitions": definitionRows(defs, agents)})
+}
+
+// CreateDefinition defines a tunnel for the user's managed clients and
+// reloads those it applies to.
+func (h *Handler) CreateDefinition(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req DefinitionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	def, ok := h.defineTunnel(c, user, req)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, definitionRows([]models.TunnelDefinition{*def}, nil)[0])
+}
+
+// defineTunnel stores a new tunnel definition of the user and reloads the
+// managed clients it applies to, answering the request itself if that
+// fails.
+func (h *Handler) defineTunnel(c *gin.Context, user *models.User, req DefinitionRequest) (*models.TunnelDefinition, bool) {
+	existing, err := storage.GetUserTunnelDefinitions(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list tunnel definitions for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to define tunnel"})
+		return nil, false
+	}
+	if len(existing) >= maxDefinitionsPerUser {
+		c.JSON(http.StatusConflict, gin.H{"error": "Tunnel definition limit reached"})
+		return nil, false
+	}
+
+	domains, err := storage.GetUserDomains(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch domains for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to define tunnel"})
+		return nil, false
+	}
+	def, err := h.validateDefinition(user.ID, domains, existing, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	if err := storage.CreateTunnelDefinition(def); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to create tunnel definition for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to define tunnel"})
+		return nil, false
+	}
+	log.Printf("User %d defined tunnel %q (%s -> port %d, selector %q)", user.ID, def.Name, def.Domain, def.Port, def.Selector)
+	h.reloadManaged(c.Request.Context(), user.ID, def.Selector)
+	return def, true
+}
+
+// DeleteDefinition removes one of the user's tunnel definitions and
+// reloads the managed clients that ran it.
+func (h *Handler) DeleteDefinition(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if !h.deleteDefinition(c, user, req.ID) {
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// deleteDefinition removes a tunnel definition of the user and reloads the
+// managed clients that ran it, answering the request itself if that fails.
+func (h *Handler) deleteDefinition(c *gin.Context, user *models.User, id uint) bool {
+	def, err := storage.DeleteTunnelDefinition(user.ID, id)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Tunnel definition not found"})
+		return false
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to delete tunnel definition %d for user %d", id, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete tunnel definition"})
+		return false
+	}
+	log.Printf("User %d deleted tunnel definition %q", user.ID, def.Name)
+	h.reloadManaged(c.Request.Context(), user.ID, def.Selector)
+	return true
+}
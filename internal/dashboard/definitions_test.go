@@ -0,0 +1,68 @@
+package dashboard
+
+import (
+	"errors"
+	"testing"
+
+	"gopublic/internal/models"
+	"gopublic/internal/server"
+)
+
+func TestValidateDefinition(t *testing.T) {
+	h := &Handler{Domain: "example.com"}
+	domains := []models.Domain{{Name: "happy-cat"}, {Name: "sad-dog"}}
+	existing := []models.TunnelDefinition{{Name: "api", Domain: "sad-dog", Port: 8080}}
+
+	def, err := h.validateDefinition(3, domains, existing, DefinitionRequest{
+		Name:      "web",
+		Subdomain: "Happy-Cat.example.com",
+		Port:      3000,
+		Selector:  " env=preview ",
+	})
+	if err != nil {
+		t.Fatalf("validateDefinition() = %v", err)
+	}
+	if def.UserID != 3 || def.Domain != "happy-cat" || def.Port != 3000 || def.Selector != "env=preview" {
+		t.Errorf("unexpected definition %+v", def)
+	}
+
+	tests := []struct {
+		name    string
+		req     DefinitionRequest
+		want    error
+		wantErr bool
+	}{
+		{"bad name", DefinitionRequest{Name: "My Web", Subdomain: "happy-cat", Port: 3000}, errDefinitionName, true},
+		{"duplicate name", DefinitionRequest{Name: "api", Subdomain: "happy-cat", Port: 3000}, errDefinitionDuplicate, true},
+		{"duplicate subdomain", DefinitionRequest{Name: "web", Subdomain: "sad-dog", Port: 3000}, errDefinitionDuplicate, true},
+		{"foreign domain", DefinitionRequest{Name: "web", Subdomain: "other", Port: 3000}, errDefinitionDomain, true},
+		{"zero port", DefinitionRequest{Name: "web", Subdomain: "happy-cat"}, errDefinitionPort, true},
+		{"bad selector", DefinitionRequest{Name: "web", Subdomain: "happy-cat", Port: 3000, Selector: "preview"}, nil, true},
+	}
+	for _, tt := range tests {
+		_, err := h.validateDefinition(3, domains, existing, tt.req)
+		if (err != nil) != tt.wantErr || (tt.want != nil && !errors.Is(err, tt.want)) {
+			t.Errorf("%s: validateDefinition() = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestDefinitionRows(t *testing.T) {
+	defs := []models.TunnelDefinition{
+		{Name: "web", Domain: "happy-cat", Port: 3000, Selector: "env=preview"},
+		{Name: "docs", Domain: "sad-dog", Port: 4000},
+	}
+	agents := []server.AgentInfo{
+		{Agent: "pr-1", Labels: map[string]string{"env": "preview"}, Managed: true},
+		{Agent: "ci", Labels: map[string]string{"env": "ci"}, Managed: true},
+		{Agent: "laptop", Labels: map[string]string{"env": "preview"}},
+	}
+
+	rows := definitionRows(defs, agents)
+	if len(rows[0].Agents) != 1 || rows[0].Agents[0] != "pr-1" {
+		t.Errorf("agents of web = %v, want [pr-1]", rows[0].Agents)
+	}
+	if len(rows[1].Agents) != 2 {
+		t.Errorf("agents of docs = %v, want every managed agent", rows[1].Agents)
+	}
+}
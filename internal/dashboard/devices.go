@@ -0,0 +1,141 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+	"gopublic/internal/version"
+)
+
+// devicePeriod is how far back the device list goes.
+const devicePeriod = 30 * 24 * time.Hour
+
+// DeviceRow is a single entry of the device list.
+type DeviceRow struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	IP          string     `json:"ip"`
+	FirstSeenAt time.Time  `json:"first_seen_at"`
+	LastSeenAt  time.Time  `json:"last_seen_at"`
+	Connections int64      `json:"connections"`
+	Connected   bool       `json:"connected"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+}
+
+// deviceRows converts device records, marking those holding an active
+// session (identified by their fingerprints).
+func deviceRows(devices []models.Device, active []string) []DeviceRow {
+	rows := make([]DeviceRow, 0, len(devices))
+	for _, d := range devices {
+		rows = append(rows, DeviceRow{
+			ID:          d.ID,
+			Name:        d.Name,
+			IP:          d.IP,
+			FirstSeenAt: d.CreatedAt,
+			LastSeenAt:  d.LastSeenAt,
+			Connections: d.Connections,
+			Connected:   d.Fingerprint != "" && slices.Contains(active, d.Fingerprint) && d.RevokedAt == nil,
+			RevokedAt:   d.RevokedAt,
+		})
+	}
+	return rows
+}
+
+// Devices renders the device list page. The list is loaded from DevicesAPI.
+func (h *Handler) Devices(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+
+	c.HTML(http.StatusOK, "devices.html", gin.H{
+		"User":       user,
+		"IsAdmin":    h.isAdmin(user),
+		"GitHubRepo": h.GitHubRepo,
+		"Version":    version.Version,
+	})
+}
+
+// DevicesAPI returns the devices that used the user's token recently.
+func (h *Handler) DevicesAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	devices, err := storage.GetUserDevices(user.ID, time.Now().Add(-devicePeriod))
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list devices for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load devices"})
+		return
+	}
+
+	var active []string
+	if h.UserSessions != nil {
+		active = h.UserSessions.GetDevices(user.ID)
+	}
+	c.JSON(http.StatusOK, gin.H{"devices": deviceRows(devices, active)})
+}
+
+// RevokeDevice closes a device's session and rotates the user's token, so
+// that the device cannot connect again. It returns the new token like
+// RegenerateToken and is wrapped in the same confirmation.
+func (h *Handler) RevokeDevice(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	device, err := storage.RevokeDevice(user.ID, req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Device not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to revoke device %d for user %d", req.ID, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke device"})
+		return
+	}
+	newToken, err := storage.RegenerateToken(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to rotate token of user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate token"})
+		return
+	}
+	log.Printf("User %d revoked device %d (%s, %s)", user.ID, device.ID, device.Name, device.IP)
+
+	if h.Events != nil {
+		event := pubsub.Event{Type: pubsub.EventForceDisconnect, UserID: user.ID, Device: device.Fingerprint, Reason: "device revoked"}
+		if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+			// The old token is invalid; only the running session survives
+			log.Printf("Failed to disconnect revoked device %d of user %d: %v", device.ID, user.ID, err)
+		}
+	}
+
+	resp, err := h.tokenResponse(user.ID, newToken)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set token expiry for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
@@ -0,0 +1,43 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+func TestDeviceRows(t *testing.T) {
+	revoked := time.Now()
+	devices := []models.Device{
+		{Fingerprint: "aaaa", Name: "laptop (darwin/arm64)", IP: "203.0.113.7", Connections: 3},
+		{Fingerprint: "bbbb", Name: "ci (linux/amd64)", IP: "198.51.100.2"},
+		{Fingerprint: "cccc", Name: "old (linux/amd64)", IP: "198.51.100.9", RevokedAt: &revoked},
+	}
+
+	rows := deviceRows(devices, []string{"aaaa"})
+	if len(rows) != 3 {
+		t.Fatalf("deviceRows() returned %d rows, want 3", len(rows))
+	}
+	if !rows[0].Connected || rows[1].Connected || rows[2].Connected {
+		t.Errorf("only the first device should be connected: %+v", rows)
+	}
+	if rows[0].Connections != 3 || rows[0].Name != "laptop (darwin/arm64)" {
+		t.Errorf("unexpected row: %+v", rows[0])
+	}
+	if rows[2].RevokedAt == nil {
+		t.Error("revoked device lost its revocation time")
+	}
+
+	// Several agents may be connected at once
+	rows = deviceRows(devices, []string{"aaaa", "bbbb"})
+	if !rows[0].Connected || !rows[1].Connected {
+		t.Errorf("both devices with a session should be connected: %+v", rows)
+	}
+
+	for _, row := range deviceRows(devices, nil) {
+		if row.Connected {
+			t.Errorf("device %q connected without an active session", row.Name)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// subdomainPattern matches the names users may reserve: 3-63 lowercase
+// letters, digits and inner hyphens.
+var subdomainPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,61}[a-z0-9]$`)
+
+// systemSubdomains are served by the server itself or kept for it.
+var systemSubdomains = map[string]bool{
+	"app": true, "status": true, "www": true, "api": true, "admin": true,
+	"dashboard": true, "mail": true, "smtp": true, "ns1": true, "ns2": true,
+}
+
+// Reasons a subdomain cannot be reserved.
+var (
+	errSubdomainInvalid  = errors.New("use 3-63 lowercase letters, digits and hyphens, not at the start or end")
+	errSubdomainReserved = errors.New("this name is reserved by the service")
+)
+
+// validateSubdomain checks that name can be reserved by a user.
+func validateSubdomain(name string) error {
+	if !subdomainPattern.MatchString(name) {
+		return errSubdomainInvalid
+	}
+	if systemSubdomains[name] {
+		return errSubdomainReserved
+	}
+	return nil
+}
+
+// subdomainName normalizes a requested domain to the stored name.
+func (h *Handler) subdomainName(domain string) string {
+	name := strings.ToLower(strings.TrimSpace(domain))
+	if h.Domain != "" {
+		name = strings.TrimSuffix(name, "."+h.Domain)
+	}
+	return name
+}
+
+// reservedDomainLimit returns how many domains the user may reserve.
+func (h *Handler) reservedDomainLimit(user *models.User) int {
+	return h.PlanDomains.Reserved(user.Plan, h.ReservedDomainsPerUser)
+}
+
+// CheckDomainAPI reports whether a subdomain can be reserved:
+// GET /api/domains/check?name=...
+func (h *Handler) CheckDomainAPI(c *gin.Context) {
+	if _, err := h.getUserFromSession(c); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	name := h.subdomainName(c.Query("name"))
+	if err := validateSubdomain(name); err != nil {
+		c.JSON(http.StatusOK, gin.H{"name": name, "available": false, "reason": err.Error()})
+		return
+	}
+	available, err := storage.IsDomainAvailable(name)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to check availability of %s", name)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check domain"})
+		return
+	}
+	resp := gin.H{"name": name, "available": available}
+	if !available {
+		resp["reason"] = storage.ErrDomainTaken.Error()
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// ReserveDomain gives the user a subdomain of their choice, up to the
+// limit of their plan. Reserved domains are released like assigned ones.
+func (h *Handler) ReserveDomain(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Domain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	domain, ok := h.reserveDomain(c, user, req.Domain)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "domain": domain.Name})
+}
+
+// reserveDomain reserves the requested subdomain for the user, answering
+// the request itself if that fails.
+func (h *Handler) reserveDomain(c *gin.Context, user *models.User, requested string) (*models.Domain, bool) {
+	name := h.subdomainName(requested)
+	if err := validateSubdomain(name); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+
+	domain, err := storage.ReserveDomain(user.ID, name, h.reservedDomainLimit(user))
+	switch {
+	case errors.Is(err, storage.ErrDomainTaken):
+		c.JSON(http.StatusConflict, gin.H{"error": "Domain is already taken"})
+		return nil, false
+	case errors.Is(err, storage.ErrReservationLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Reserved domain limit of your plan reached"})
+		return nil, false
+	case err != nil:
+		sentry.CaptureErrorWithContextf(c, err, "Failed to reserve domain %s for user %d", name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reserve domain"})
+		return nil, false
+	}
+	log.Printf("User %d reserved domain %s", user.ID, name)
+	return domain, true
+}
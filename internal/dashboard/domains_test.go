@@ -0,0 +1,56 @@
+package dashboard
+
+import (
+	"errors"
+	"testing"
+
+	"gopublic/internal/billing"
+	"gopublic/internal/models"
+)
+
+func TestValidateSubdomain(t *testing.T) {
+	tests := []struct {
+		name string
+		want error
+	}{
+		{"my-app", nil},
+		{"a1b", nil},
+		{"ab", errSubdomainInvalid},
+		{"-app", errSubdomainInvalid},
+		{"app-", errSubdomainInvalid},
+		{"My-App", errSubdomainInvalid},
+		{"my.app", errSubdomainInvalid},
+		{"my_app", errSubdomainInvalid},
+		{"status", errSubdomainReserved},
+		{"app", errSubdomainReserved},
+	}
+	for _, tt := range tests {
+		if err := validateSubdomain(tt.name); !errors.Is(err, tt.want) {
+			t.Errorf("validateSubdomain(%q) = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestSubdomainName(t *testing.T) {
+	h := &Handler{Domain: "example.com"}
+	if got := h.subdomainName(" My-App.Example.com "); got != "my-app" {
+		t.Errorf("subdomainName() = %q, want %q", got, "my-app")
+	}
+}
+
+func TestReservedDomainLimit(t *testing.T) {
+	h := &Handler{ReservedDomainsPerUser: 1, PlanDomains: billing.PlanDomains{"pro": 10, "free": 0}}
+	tests := []struct {
+		plan string
+		want int
+	}{
+		{"pro", 10},
+		{"free", 0},
+		{"team", 1},
+	}
+	for _, tt := range tests {
+		if got := h.reservedDomainLimit(&models.User{Plan: tt.plan}); got != tt.want {
+			t.Errorf("reservedDomainLimit(%q) = %d, want %d", tt.plan, got, tt.want)
+		}
+	}
+}
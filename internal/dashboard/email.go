@@ -0,0 +1,231 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/errorpage"
+	"gopublic/internal/middleware"
+	"gopublic/internal/models"
+	"gopublic/internal/notify"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// LoginMailer sends sign-in links by email; *notify.Notifier implements it.
+type LoginMailer interface {
+	SendEmail(ctx context.Context, to string, msg notify.Message) error
+}
+
+// loginLinkTTL is how long an emailed sign-in link can be used.
+const loginLinkTTL = 15 * time.Minute
+
+// Sign-in links per client IP and per address: a burst of
+// emailLoginBurst, then one every emailLoginInterval.
+const (
+	emailLoginBurst    = 5
+	emailLoginInterval = 10 * time.Minute
+)
+
+// newEmailLoginLimiter limits how many sign-in links are requested, keyed
+// by "ip:" and "email:" so one limiter covers both.
+func newEmailLoginLimiter() *middleware.IPRateLimiter {
+	return middleware.NewIPRateLimiter(middleware.RateLimiterConfig{
+		RequestsPerSecond: 1 / emailLoginInterval.Seconds(),
+		BurstSize:         emailLoginBurst,
+		CleanupInterval:   time.Minute,
+		MaxAge:            emailLoginBurst * emailLoginInterval, // Until the burst is refilled
+	})
+}
+
+// getEmailLoginURL returns the sign-in link for a login token based on domain
+func (h *Handler) getEmailLoginURL(token string) string {
+	if h.Domain == "localhost" || h.Domain == "127.0.0.1" {
+		return fmt.Sprintf("http://%s/auth/email/callback?token=%s", h.Domain, url.QueryEscape(token))
+	}
+	return fmt.Sprintf("https://app.%s/auth/email/callback?token=%s", h.Domain, url.QueryEscape(token))
+}
+
+// emailText holds the localized strings of the sign-in email and of the
+// page confirming it.
+type emailText struct {
+	Subject string
+	Body    string // Formatted with the link and loginLinkTTL in minutes
+	Title   string
+	Prompt  string
+	Confirm string
+}
+
+// emailTexts covers the languages supported by errorpage.
+var emailTexts = map[string]emailText{
+	"en": {
+		Subject: "Sign in to GoPublic",
+		Body: "To sign in to GoPublic, open this link:\n\n%s\n\n" +
+			"The link works once and expires in %d minutes. If you did not ask to sign in, ignore this email.",
+		Title:   "Sign in to GoPublic",
+		Prompt:  "Confirm that you want to sign in with the link from your email.",
+		Confirm: "Sign in",
+	},
+	"ru": {
+		Subject: "Вход в GoPublic",
+		Body: "Чтобы войти в GoPublic, откройте ссылку:\n\n%s\n\n" +
+			"Ссылка действует %d минут и только один раз. Если вы не запрашивали вход, просто проигнорируйте это письмо.",
+		Title:   "Вход в GoPublic",
+		Prompt:  "Подтвердите вход по ссылке из письма.",
+		Confirm: "Войти",
+	},
+}
+
+// emailLanguage picks the language of the sign-in email and page from
+// the request.
+func emailLanguage(c *gin.Context) (string, emailText) {
+	lang := errorpage.Language(c.GetHeader("Accept-Language"))
+	t, ok := emailTexts[lang]
+	if !ok {
+		lang = errorpage.DefaultLanguage
+		t = emailTexts[lang]
+	}
+	return lang, t
+}
+
+// loginLinkMessage is the email carrying a sign-in link.
+func loginLinkMessage(t emailText, link string) notify.Message {
+	return notify.Message{
+		Subject: t.Subject,
+		Text:    fmt.Sprintf(t.Body, link, int(loginLinkTTL.Minutes())),
+	}
+}
+
+// EmailLoginRequest emails a one-time sign-in link to an address:
+// POST /auth/email {"email": "..."}
+func (h *Handler) EmailLoginRequest(c *gin.Context) {
+	if h.EmailLogin == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Email login not configured"})
+		return
+	}
+	if !checkCSRF(c) {
+		return
+	}
+
+	var req struct {
+		Email string `json:"email"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	email, err := models.NormalizeEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid email address"})
+		return
+	}
+	if h.emailLimiter != nil && (!h.emailLimiter.Allow("ip:"+c.ClientIP()) || !h.emailLimiter.Allow("email:"+email)) {
+		c.Header("Retry-After", strconv.Itoa(int(emailLoginInterval.Seconds())))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many sign-in links requested, try again later"})
+		return
+	}
+
+	token, err := storage.CreateLoginToken(email, loginLinkTTL)
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to create login token")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sign-in link"})
+		return
+	}
+	// The mail is written in the language of the page that asked for it
+	_, text := emailLanguage(c)
+	if err := h.EmailLogin.SendEmail(c.Request.Context(), email, loginLinkMessage(text, h.getEmailLoginURL(token))); err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to send sign-in link")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send email"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"sent": true})
+}
+
+// EmailLoginCallback is the target of a link sent by EmailLoginRequest:
+// GET /auth/email/callback?token=...
+// It only asks to confirm the sign-in, so mail scanners and link previews
+// that fetch the link do not use it up; EmailLoginConfirm redeems it.
+func (h *Handler) EmailLoginCallback(c *gin.Context) {
+	if h.EmailLogin == nil {
+		c.String(http.StatusNotFound, "Email login not configured")
+		return
+	}
+	token := c.Query("token")
+	if token == "" {
+		c.String(http.StatusBadRequest, "This sign-in link is invalid, expired or already used")
+		return
+	}
+
+	lang, text := emailLanguage(c)
+	c.Header("Content-Language", lang)
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusOK, "email_confirm.html", gin.H{
+		"Lang":      lang,
+		"Text":      text,
+		"Token":     token,
+		"CSRFToken": middleware.GetCSRFToken(c),
+	})
+}
+
+// EmailLoginConfirm signs in with a link sent by EmailLoginRequest. An
+// address without an account registers a new user like any other first
+// login: POST /auth/email/callback (form: token, csrf_token)
+func (h *Handler) EmailLoginConfirm(c *gin.Context) {
+	if h.EmailLogin == nil {
+		c.String(http.StatusNotFound, "Email login not configured")
+		return
+	}
+	cookieToken, err := c.Cookie("csrf_token")
+	if err != nil {
+		c.String(http.StatusForbidden, "CSRF token missing")
+		return
+	}
+	requestToken := c.PostForm("csrf_token")
+	if requestToken == "" || requestToken != cookieToken {
+		c.String(http.StatusForbidden, "CSRF token invalid")
+		return
+	}
+
+	email, err := storage.RedeemLoginToken(c.PostForm("token"))
+	if errors.Is(err, storage.ErrNotFound) {
+		c.String(http.StatusBadRequest, "This sign-in link is invalid, expired or already used")
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to redeem login token")
+		c.String(http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	user, err := storage.GetUserByEmail(email)
+	if errors.Is(err, storage.ErrNotFound) {
+		user, err = h.registerUser(c, &models.User{Email: email})
+		if errors.Is(err, ErrInviteRequired) || errors.Is(err, storage.ErrInviteInvalid) {
+			c.String(http.StatusForbidden, inviteErrorMessage(err))
+			return
+		} else if err != nil {
+			sentry.CaptureErrorWithContext(c, err, "Failed to create user via email login")
+			c.String(http.StatusInternalServerError, "Failed to create user account")
+			return
+		}
+		log.Printf("User %d registered by email", user.ID)
+	} else if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Database error looking up email user")
+		c.String(http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	if err := h.Session.SetSession(c.Writer, user.ID); err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to set session after email login")
+		c.String(http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+	c.Redirect(http.StatusSeeOther, "/")
+}
@@ -0,0 +1,168 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/errorpage"
+	"gopublic/internal/models"
+	"gopublic/internal/notify"
+)
+
+type fakeMailer struct{ sent []string }
+
+func (m *fakeMailer) SendEmail(ctx context.Context, to string, msg notify.Message) error {
+	m.sent = append(m.sent, to)
+	return nil
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		email   string
+		want    string
+		wantErr error
+	}{
+		{"ivan@example.com", "ivan@example.com", nil},
+		{"  Ivan.Petrov+tunnels@Example.COM ", "ivan.petrov+tunnels@example.com", nil},
+		{"", "", models.ErrInvalidEmail},
+		{"ivan", "", models.ErrInvalidEmail},
+		{"ivan@localhost", "", models.ErrInvalidEmail},
+		{"Ivan <ivan@example.com>", "", models.ErrInvalidEmail},
+		{"ivan@example.com, eve@example.com", "", models.ErrInvalidEmail},
+		{strings.Repeat("a", 250) + "@example.com", "", models.ErrInvalidEmail},
+	}
+	for _, tt := range tests {
+		got, err := models.NormalizeEmail(tt.email)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("NormalizeEmail(%q) = %q, %v, want %q, %v", tt.email, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestGetEmailLoginURL(t *testing.T) {
+	h := &Handler{Domain: "example.com"}
+	if got := h.getEmailLoginURL("sk_live_a+b="); got != "https://app.example.com/auth/email/callback?token=sk_live_a%2Bb%3D" {
+		t.Errorf("getEmailLoginURL() = %q", got)
+	}
+}
+
+func TestLoginLinkMessage(t *testing.T) {
+	for lang, text := range emailTexts {
+		msg := loginLinkMessage(text, "https://app.example.com/x")
+		if !strings.Contains(msg.Text, "https://app.example.com/x") || !strings.Contains(msg.Text, "15") || msg.Subject == "" {
+			t.Errorf("loginLinkMessage(%s) = %+v", lang, msg)
+		}
+	}
+	if _, ok := emailTexts[errorpage.DefaultLanguage]; !ok {
+		t.Errorf("no sign-in email in %s", errorpage.DefaultLanguage)
+	}
+}
+
+// emailLoginCallback calls the sign-in link target with the method, form
+// and CSRF cookie given.
+func emailLoginCallback(t *testing.T, method, target string, form url.Values, cookie string) *httptest.ResponseRecorder {
+	t.Helper()
+	h := &Handler{EmailLogin: &fakeMailer{}}
+	w := httptest.NewRecorder()
+	c, r := gin.CreateTestContext(w)
+	if err := h.LoadTemplates(r); err != nil {
+		t.Fatal(err)
+	}
+	c.Request = httptest.NewRequest(method, target, strings.NewReader(form.Encode()))
+	c.Request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c.Request.Header.Set("Accept-Language", "ru-RU,ru;q=0.9")
+	if cookie != "" {
+		c.Request.AddCookie(&http.Cookie{Name: "csrf_token", Value: cookie})
+		c.Set("csrf_token", cookie)
+	}
+	if method == http.MethodGet {
+		h.EmailLoginCallback(c)
+	} else {
+		h.EmailLoginConfirm(c)
+	}
+	return w
+}
+
+func TestEmailLoginCallback(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Opening the link only renders the confirmation; without a database
+	// redeeming the token would fail
+	w := emailLoginCallback(t, http.MethodGet, "/auth/email/callback?token=lt_abc", nil, "t")
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{`method="POST"`, `name="token" value="lt_abc"`, `name="csrf_token" value="t"`, emailTexts["ru"].Confirm} {
+		if !strings.Contains(body, want) {
+			t.Errorf("confirmation page lacks %q", want)
+		}
+	}
+	if got := w.Header().Get("Content-Language"); got != "ru" {
+		t.Errorf("Content-Language = %q, want ru", got)
+	}
+	if w := emailLoginCallback(t, http.MethodGet, "/auth/email/callback", nil, "t"); w.Code != http.StatusBadRequest {
+		t.Errorf("GET status without a token = %d, want 400", w.Code)
+	}
+
+	form := url.Values{"token": {"lt_abc"}}
+	if w := emailLoginCallback(t, http.MethodPost, "/auth/email/callback", form, ""); w.Code != http.StatusForbidden {
+		t.Errorf("POST status without a CSRF cookie = %d, want 403", w.Code)
+	}
+	if w := emailLoginCallback(t, http.MethodPost, "/auth/email/callback", form, "t"); w.Code != http.StatusForbidden {
+		t.Errorf("POST status without a CSRF field = %d, want 403", w.Code)
+	}
+	form.Set("csrf_token", "other")
+	if w := emailLoginCallback(t, http.MethodPost, "/auth/email/callback", form, "t"); w.Code != http.StatusForbidden {
+		t.Errorf("POST status with a mismatched CSRF field = %d, want 403", w.Code)
+	}
+}
+
+// emailLoginRequest posts an email address to EmailLoginRequest.
+func emailLoginRequest(h *Handler, body string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/auth/email", strings.NewReader(body))
+	c.Request.Header.Set("Content-Type", "application/json")
+	c.Request.Header.Set("X-CSRF-Token", "t")
+	c.Request.AddCookie(&http.Cookie{Name: "csrf_token", Value: "t"})
+	c.Request.RemoteAddr = "203.0.113.7:50000"
+	h.EmailLoginRequest(c)
+	return w
+}
+
+func TestEmailLoginRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	if w := emailLoginRequest(&Handler{}, `{"email":"ivan@example.com"}`); w.Code != http.StatusNotFound {
+		t.Errorf("status without email login = %d, want 404", w.Code)
+	}
+
+	mailer := &fakeMailer{}
+	h := &Handler{EmailLogin: mailer, emailLimiter: newEmailLoginLimiter()}
+	t.Cleanup(h.emailLimiter.Stop)
+	if w := emailLoginRequest(h, `{"email":"not an address"}`); w.Code != http.StatusBadRequest {
+		t.Errorf("status for a malformed address = %d, want 400", w.Code)
+	}
+
+	// Without a database no link is sent, but the requests still count
+	for i := 0; i < emailLoginBurst; i++ {
+		if w := emailLoginRequest(h, `{"email":"ivan@example.com"}`); w.Code == http.StatusTooManyRequests {
+			t.Fatalf("request %d rate limited within the burst", i+1)
+		}
+	}
+	w := emailLoginRequest(h, `{"email":"ivan@example.com"}`)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") == "" {
+		t.Errorf("status after the burst = %d, want 429 with Retry-After", w.Code)
+	}
+	if len(mailer.sent) != 0 {
+		t.Errorf("sent %v without a login token", mailer.sent)
+	}
+}
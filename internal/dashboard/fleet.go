@@ -0,0 +1,141 @@
+package dashboard
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/server"
+	"gopublic/pkg/protocol"
+)
+
+// FleetProvider lists a user's connected clients.
+// This interface is implemented by server.UserSessionRegistry.
+type FleetProvider interface {
+	Agents(userID uint) []server.AgentInfo
+}
+
+// FleetRow is a single connected client of the fleet view.
+type FleetRow struct {
+	Agent       string            `json:"agent"` // Empty for the regular session
+	Device      string            `json:"device"`
+	Version     string            `json:"version,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	Domains     []string          `json:"domains"`
+	ConnectedAt time.Time         `json:"connected_at"`
+	Uptime      int64             `json:"uptime_seconds"`
+	Commands    bool              `json:"commands"` // Accepts disconnect and reload
+	Managed     bool              `json:"managed"`  // Runs tunnels defined in the dashboard
+}
+
+// FleetCommandRequest sends a remote command to one connected client.
+type FleetCommandRequest struct {
+	Agent   string `json:"agent"`
+	Command string `json:"command"`
+}
+
+// SetFleet sets the provider of connected clients.
+func (h *Handler) SetFleet(provider FleetProvider) {
+	h.Fleet = provider
+}
+
+// fleetRows converts the sessions of a user for the fleet view.
+func fleetRows(agents []server.AgentInfo, now time.Time) []FleetRow {
+	rows := make([]FleetRow, 0, len(agents))
+	for _, a := range agents {
+		domains := a.Domains
+		if domains == nil {
+			domains = []string{}
+		}
+		rows = append(rows, FleetRow{
+			Agent:       a.Agent,
+			Device:      a.Device,
+			Version:     a.Version,
+			Labels:      a.Labels,
+			Domains:     domains,
+			ConnectedAt: a.ConnectedAt,
+			Uptime:      int64(now.Sub(a.ConnectedAt).Seconds()),
+			Commands:    a.Commands,
+			Managed:     a.Managed,
+		})
+	}
+	return rows
+}
+
+// FleetAPI returns the user's connected clients: GET /api/fleet
+func (h *Handler) FleetAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var agents []server.AgentInfo
+	if h.Fleet != nil {
+		agents = h.Fleet.Agents(user.ID)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"clients": fleetRows(agents, time.Now())})
+}
+
+// FleetCommand asks one of the user's connected clients to disconnect for
+// good or to reload its gopublic.yaml. The command travels over the
+// control channel with the client's next heartbeat.
+func (h *Handler) FleetCommand(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+	if h.Fleet == nil || h.Events == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Remote commands are not available"})
+		return
+	}
+
+	var req FleetCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Command != protocol.CommandDisconnect && req.Command != protocol.CommandReload {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown command"})
+		return
+	}
+
+	var target *server.AgentInfo
+	for _, a := range h.Fleet.Agents(user.ID) {
+		if a.Agent == req.Agent {
+			target = &a
+			break
+		}
+	}
+	if target == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Client is not connected"})
+		return
+	}
+	if !target.Commands {
+		c.JSON(http.StatusConflict, gin.H{"error": "Client does not support remote commands; update it"})
+		return
+	}
+
+	event := pubsub.Event{Type: pubsub.EventAgentCommand, UserID: user.ID, Agent: req.Agent, Command: req.Command}
+	if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+		log.Printf("Failed to send %q to agent %q of user %d: %v", req.Command, req.Agent, user.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send command"})
+		return
+	}
+	log.Printf("User %d sent %q to agent %q", user.ID, req.Command, req.Agent)
+
+	c.JSON(http.StatusAccepted, gin.H{"success": true})
+}
@@ -0,0 +1,27 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	"gopublic/internal/server"
+)
+
+func TestFleetRows(t *testing.T) {
+	now := time.Now()
+	agents := []server.AgentInfo{
+		{Device: "laptop (darwin/arm64)", ConnectedAt: now.Add(-time.Hour)},
+		{Agent: "ci", Device: "runner (linux/amd64)", Version: "v1.2.0", Domains: []string{"ci.example.com"}, ConnectedAt: now.Add(-90 * time.Second), Commands: true},
+	}
+
+	rows := fleetRows(agents, now)
+	if len(rows) != 2 {
+		t.Fatalf("fleetRows() returned %d rows, want 2", len(rows))
+	}
+	if rows[0].Uptime != 3600 || rows[0].Domains == nil {
+		t.Errorf("unexpected regular session row: %+v", rows[0])
+	}
+	if rows[1].Agent != "ci" || rows[1].Uptime != 90 || !rows[1].Commands || rows[1].Version != "v1.2.0" {
+		t.Errorf("unexpected agent row: %+v", rows[1])
+	}
+}
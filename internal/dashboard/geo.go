@@ -0,0 +1,65 @@
+package dashboard
+
+import (
+	"time"
+
+	"gopublic/internal/storage"
+)
+
+const (
+	// geoUsagePeriod is the window of the visitor country breakdown.
+	geoUsagePeriod = 30 * 24 * time.Hour
+	// geoUsageTop is the number of countries listed before "other".
+	geoUsageTop = 8
+)
+
+// GeoRow is one bar of the visitor country breakdown.
+type GeoRow struct {
+	Country   string // ISO code, empty for unknown or aggregated countries
+	Label     string
+	Requests  int64
+	BytesUsed int64
+	Percent   int // Share of requests
+}
+
+// geoBreakdown turns per-country usage, sorted busiest first, into chart
+// rows, folding countries beyond the top few into a single row.
+func geoBreakdown(usage []storage.GeoUsage, top int) []GeoRow {
+	var total int64
+	for _, u := range usage {
+		total += u.Requests
+	}
+	if total == 0 {
+		return nil
+	}
+
+	var rows []GeoRow
+	var other GeoRow
+	for n, u := range usage {
+		if n >= top {
+			other.Requests += u.Requests
+			other.BytesUsed += u.BytesUsed
+			continue
+		}
+		row := GeoRow{Country: u.Country, Label: countryLabel(u.Country), Requests: u.Requests, BytesUsed: u.BytesUsed}
+		rows = append(rows, row)
+	}
+	if other.Requests > 0 {
+		other.Label = "Другие страны"
+		rows = append(rows, other)
+	}
+	for n := range rows {
+		rows[n].Percent = int(rows[n].Requests * 100 / total)
+	}
+	return rows
+}
+
+// countryLabel renders an ISO country code as its flag emoji and code.
+func countryLabel(code string) string {
+	if len(code) != 2 || code[0] < 'A' || code[0] > 'Z' || code[1] < 'A' || code[1] > 'Z' {
+		return "Неизвестно"
+	}
+	const regionalIndicatorA = 0x1F1E6
+	flag := string([]rune{regionalIndicatorA + rune(code[0]-'A'), regionalIndicatorA + rune(code[1]-'A')})
+	return flag + " " + code
+}
@@ -0,0 +1,35 @@
+package dashboard
+
+import (
+	"testing"
+
+	"gopublic/internal/storage"
+)
+
+func TestGeoBreakdown(t *testing.T) {
+	usage := []storage.GeoUsage{
+		{Country: "DE", Requests: 50, BytesUsed: 500},
+		{Country: "RU", Requests: 30, BytesUsed: 300},
+		{Country: "", Requests: 10, BytesUsed: 100},
+		{Country: "US", Requests: 6, BytesUsed: 60},
+		{Country: "FR", Requests: 4, BytesUsed: 40},
+	}
+
+	rows := geoBreakdown(usage, 3)
+	if len(rows) != 4 {
+		t.Fatalf("rows = %+v, want 3 countries and other", rows)
+	}
+	if rows[0].Label != "🇩🇪 DE" || rows[0].Percent != 50 {
+		t.Errorf("rows[0] = %+v", rows[0])
+	}
+	if rows[2].Label != "Неизвестно" {
+		t.Errorf("unknown country label = %q", rows[2].Label)
+	}
+	if other := rows[3]; other.Country != "" || other.Requests != 10 || other.BytesUsed != 100 || other.Percent != 10 {
+		t.Errorf("other = %+v", other)
+	}
+
+	if rows := geoBreakdown(nil, 3); rows != nil {
+		t.Errorf("empty usage = %+v, want nil", rows)
+	}
+}
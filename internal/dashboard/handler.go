@@ -1,7 +1,6 @@
 package dashboard
 
 import (
-	"bytes"
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
@@ -9,6 +8,7 @@ import (
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
 	"io"
@@ -22,9 +22,14 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"gopublic/internal/alerts"
 	"gopublic/internal/auth"
+	"gopublic/internal/billing"
 	"gopublic/internal/config"
+	"gopublic/internal/metrics"
+	"gopublic/internal/middleware"
 	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
 	"gopublic/internal/sentry"
 	"gopublic/internal/storage"
 	"gopublic/internal/version"
@@ -38,6 +43,14 @@ var templateFS embed.FS
 type UserSessionProvider interface {
 	IsConnected(userID uint) bool
 	GetActiveDomains(userID uint) []string
+	GetLabels(userID uint) map[string]string
+	GetDevices(userID uint) []string
+}
+
+// LatencyProvider provides per-domain response latency.
+// This interface is implemented by metrics.DomainLatency.
+type LatencyProvider interface {
+	Summary(domain string) (metrics.LatencySummary, bool)
 }
 
 type Handler struct {
@@ -50,8 +63,41 @@ type Handler struct {
 	AdminTelegramID     int64
 	YandexClientID      string
 	YandexClientSecret  string
+	GitHub              *auth.OAuthProvider // GitHub login (nil = disabled)
+	Google              *auth.OAuthProvider // Google login (nil = disabled)
+	SSO                 *auth.OIDCProvider  // Corporate SSO (nil = disabled)
+	EmailLogin          LoginMailer         // Sends sign-in links by email (nil = disabled)
+	SSOName             string              // Label of the SSO login button
+	SSOGroupRoles       map[string]string   // IdP group -> models.RoleAdmin, RoleMember or RoleViewer
 	Session             *auth.SessionManager
 	UserSessions        UserSessionProvider // Optional: provides active session info
+	Latency             LatencyProvider     // Optional: provides per-domain latency
+	Captures            CaptureProvider     // Optional: exchanges recorded by the ingress
+	Fleet               FleetProvider       // Optional: lists connected clients for the fleet view
+	Events              pubsub.Bus          // Optional: announces domain takedowns to all instances
+
+	RequireSignupApproval bool // New accounts wait for admin approval
+	InviteOnly            bool // Signups require an invite code
+	InviteMaxUses         int  // Signups allowed per user-created invite
+
+	Billing       *billing.Stripe    // Stripe subscriptions (nil = billing disabled)
+	PlanBandwidth billing.PlanLimits // Per-plan overrides of DailyBandwidthLimit
+	QuotaAlerts   bool               // Users are notified when nearing the bandwidth limit
+
+	ReservedDomainsPerUser int                 // Subdomains a user may reserve by name
+	PlanDomains            billing.PlanDomains // Per-plan overrides of ReservedDomainsPerUser
+
+	Alerts      *alerts.Sender // Offline tunnel alerts (nil = disabled)
+	StatusPages bool           // Users can publish a public status page
+
+	TokenTTL time.Duration // Lifetime of issued tokens (0 = never expire)
+
+	APILimiter   *middleware.IPRateLimiter // Requests per API token to the public API (nil = unlimited)
+	emailLimiter *middleware.IPRateLimiter // Sign-in links per client IP and per address (nil = unlimited)
+
+	ConfirmActions bool         // Destructive actions require a second factor
+	confirms       confirmStore // Pending confirmation codes
+	totp           totpGuard    // Failed and last accepted authenticator codes
 }
 
 // SetUserSessions sets the user session provider for displaying connection status.
@@ -59,6 +105,16 @@ func (h *Handler) SetUserSessions(provider UserSessionProvider) {
 	h.UserSessions = provider
 }
 
+// SetLatency sets the latency provider for displaying tunnel response times.
+func (h *Handler) SetLatency(provider LatencyProvider) {
+	h.Latency = provider
+}
+
+// SetEvents sets the event bus used to announce domain suspensions.
+func (h *Handler) SetEvents(bus pubsub.Bus) {
+	h.Events = bus
+}
+
 // NewHandlerWithConfig creates a new dashboard handler with the given configuration.
 func NewHandlerWithConfig(cfg *config.Config) (*Handler, error) {
 	sessionCfg := auth.SessionConfig{
@@ -71,6 +127,34 @@ func NewHandlerWithConfig(cfg *config.Config) (*Handler, error) {
 		return nil, err
 	}
 
+	var sso *auth.OIDCProvider
+	if cfg.HasOIDC() {
+		sso = auth.NewOIDCProvider(cfg.OIDCIssuer, cfg.OIDCClientID, cfg.OIDCClientSecret, cfg.OIDCGroupsClaim)
+	}
+
+	var github, google *auth.OAuthProvider
+	if cfg.HasGitHubOAuth() {
+		github = auth.NewGitHubProvider(cfg.GitHubClientID, cfg.GitHubClientSecret)
+	}
+	if cfg.HasGoogleOAuth() {
+		google = auth.NewGoogleProvider(cfg.GoogleClientID, cfg.GoogleClientSecret)
+	}
+
+	var apiLimiter *middleware.IPRateLimiter
+	if cfg.APIRateLimit > 0 {
+		apiLimiter = middleware.NewIPRateLimiter(middleware.RateLimiterConfig{
+			RequestsPerSecond: cfg.APIRateLimit,
+			BurstSize:         cfg.APIRateBurst,
+			CleanupInterval:   time.Minute,
+			MaxAge:            5 * time.Minute,
+		})
+	}
+
+	var emailLimiter *middleware.IPRateLimiter
+	if cfg.EmailLogin {
+		emailLimiter = newEmailLoginLimiter()
+	}
+
 	return &Handler{
 		BotToken:            cfg.TelegramBotToken,
 		BotName:             cfg.TelegramBotName,
@@ -81,7 +165,29 @@ func NewHandlerWithConfig(cfg *config.Config) (*Handler, error) {
 		AdminTelegramID:     cfg.AdminTelegramID,
 		YandexClientID:      cfg.YandexClientID,
 		YandexClientSecret:  cfg.YandexClientSecret,
+		GitHub:              github,
+		Google:              google,
+		SSO:                 sso,
+		SSOName:             cfg.OIDCName,
+		SSOGroupRoles:       cfg.OIDCGroupRoles,
 		Session:             sessionMgr,
+
+		RequireSignupApproval: cfg.RequireSignupApproval,
+		InviteOnly:            cfg.InviteOnly,
+		InviteMaxUses:         cfg.InviteMaxUses,
+
+		Billing:       billing.NewStripe(cfg.StripeSecretKey, cfg.StripeWebhookSecret, cfg.StripePrices),
+		PlanBandwidth: cfg.PlanBandwidthLimits,
+		QuotaAlerts:   cfg.QuotaAlerts,
+		StatusPages:   cfg.StatusPages,
+
+		ReservedDomainsPerUser: cfg.ReservedDomainsPerUser,
+		PlanDomains:            cfg.PlanReservedDomains,
+
+		TokenTTL:       cfg.TokenTTL,
+		APILimiter:     apiLimiter,
+		emailLimiter:   emailLimiter,
+		ConfirmActions: cfg.ConfirmActions,
 	}, nil
 }
 
@@ -125,6 +231,7 @@ func (h *Handler) LoadTemplates(r *gin.Engine) error {
 			}
 			return fmt.Sprintf("%.2f GB", float64(bytes)/(1024*1024*1024))
 		},
+		"deref": func(f *float64) float64 { return *f },
 		"bandwidthPercent": func(used, limit int64) int {
 			if limit == 0 {
 				return 0
@@ -173,12 +280,22 @@ func (h *Handler) Login(c *gin.Context) {
 		authURL = fmt.Sprintf("https://app.%s/auth/telegram", h.Domain)
 	}
 
+	// Remember the invite code until the OAuth round-trip creates the account
+	inviteCode := h.rememberInvite(c)
+
 	c.HTML(http.StatusOK, "login.html", gin.H{
 		"BotName":       h.BotName,
 		"AuthURL":       authURL,
 		"GitHubRepo":    h.GitHubRepo,
 		"Version":       version.Version,
 		"YandexEnabled": h.YandexClientID != "" && h.YandexClientSecret != "",
+		"GitHubEnabled": h.GitHub != nil,
+		"GoogleEnabled": h.Google != nil,
+		"SSOEnabled":    h.SSO != nil,
+		"SSOName":       h.SSOName,
+		"EmailEnabled":  h.EmailLogin != nil,
+		"InviteOnly":    h.InviteOnly,
+		"InviteCode":    inviteCode,
 	})
 }
 
@@ -189,6 +306,15 @@ func (h *Handler) Index(c *gin.Context) {
 		return
 	}
 
+	if user.Status == models.UserStatusPending {
+		c.HTML(http.StatusOK, "pending.html", gin.H{
+			"User":       user,
+			"GitHubRepo": h.GitHubRepo,
+			"Version":    version.Version,
+		})
+		return
+	}
+
 	// Fetch token
 	token, err := storage.GetUserToken(user.ID)
 	if err != nil {
@@ -205,21 +331,43 @@ func (h *Handler) Index(c *gin.Context) {
 		return
 	}
 
+	var reservedCount int
+	for _, d := range domains {
+		if d.Reserved {
+			reservedCount++
+		}
+	}
+
 	// Fetch bandwidth statistics
 	bandwidthToday, _ := storage.GetUserBandwidthToday(user.ID)
 	bandwidthTotal, _ := storage.GetUserTotalBandwidth(user.ID)
+	geoUsage, _ := storage.GetUserGeoUsage(user.ID, time.Now().Add(-geoUsagePeriod))
+	bandwidthLimit := h.PlanBandwidth.Bandwidth(user.Plan, h.DailyBandwidthLimit)
 
 	// Check connection status
 	var isConnected bool
 	var activeDomains []string
+	var labels map[string]string
 	if h.UserSessions != nil {
 		isConnected = h.UserSessions.IsConnected(user.ID)
 		activeDomains = h.UserSessions.GetActiveDomains(user.ID)
+		labels = h.UserSessions.GetLabels(user.ID)
+	}
+
+	incomingTransfers, outgoingTransfers, err := storage.GetDomainTransfers(user.ID)
+	if err != nil {
+		log.Printf("Failed to fetch domain transfers for user %d: %v", user.ID, err)
+	}
+
+	var statusURL string
+	if user.StatusSlug != nil {
+		statusURL = h.statusPageURL(*user.StatusSlug)
 	}
 
 	c.HTML(http.StatusOK, "index.html", gin.H{
 		"User":            user,
 		"Token":           token.TokenString,
+		"TokenExpiresAt":  token.ExpiresAt,
 		"Domains":         domains,
 		"RootDomain":      h.Domain,
 		"GitHubRepo":      h.GitHubRepo,
@@ -227,11 +375,27 @@ func (h *Handler) Index(c *gin.Context) {
 		"TermsAccepted":   user.TermsAcceptedAt != nil,
 		"TelegramEnabled": h.BotToken != "" && h.BotName != "",
 		"YandexEnabled":   h.YandexClientID != "" && h.YandexClientSecret != "",
+		"GitHubEnabled":   h.GitHub != nil,
+		"GoogleEnabled":   h.Google != nil,
 		"BandwidthToday":  bandwidthToday,
 		"BandwidthTotal":  bandwidthTotal,
-		"BandwidthLimit":  h.DailyBandwidthLimit,
+		"BandwidthLimit":  bandwidthLimit,
+		"QuotaExceeded":   bandwidthLimit > 0 && bandwidthToday >= bandwidthLimit,
+		"GeoUsage":        geoBreakdown(geoUsage, geoUsageTop),
 		"IsConnected":     isConnected,
 		"ActiveDomains":   activeDomains,
+		"Labels":          labels,
+		"Plans":           h.purchasablePlans(),
+		"QuotaAlerts":     h.QuotaAlerts,
+		"AlertsEnabled":   h.Alerts != nil,
+		"StatusPages":     h.StatusPages,
+		"StatusURL":       statusURL,
+		"CaptureEnabled":  h.Captures != nil,
+		"ReservedCount":   reservedCount,
+		"ReservedLimit":   h.reservedDomainLimit(user),
+
+		"IncomingTransfers": incomingTransfers,
+		"OutgoingTransfers": outgoingTransfers,
 	})
 }
 
@@ -258,6 +422,7 @@ func (h *Handler) TelegramCallback(c *gin.Context) {
 
 	if err == storage.ErrNotFound {
 		// Create new user with token and domains in a single transaction
+		// (or a pending account when signups need approval)
 		newUser := &models.User{
 			TelegramID: &tgID,
 			FirstName:  firstName,
@@ -266,22 +431,11 @@ func (h *Handler) TelegramCallback(c *gin.Context) {
 			PhotoURL:   photoURL,
 		}
 
-		// Generate domain names
-		prefixes := []string{"misty", "silent", "bold", "rapid", "cool"}
-		suffixes := []string{"river", "star", "eagle", "bear", "fox"}
-		var domains []string
-		for i := 0; i < h.DomainsPerUser; i++ {
-			name := fmt.Sprintf("%s-%s-%d", prefixes[i%len(prefixes)], suffixes[i%len(suffixes)], time.Now().Unix()%1000+int64(i))
-			domains = append(domains, name)
-		}
-
-		reg := storage.UserRegistration{
-			User:    newUser,
-			Domains: domains,
-		}
-
-		createdUser, _, err := storage.CreateUserWithTokenAndDomains(reg)
-		if err != nil {
+		createdUser, err := h.registerUser(c, newUser)
+		if errors.Is(err, ErrInviteRequired) || errors.Is(err, storage.ErrInviteInvalid) {
+			c.String(http.StatusForbidden, inviteErrorMessage(err))
+			return
+		} else if err != nil {
 			sentry.CaptureErrorWithContext(c, err, "Failed to create user")
 			c.String(http.StatusInternalServerError, "Failed to create user account")
 			return
@@ -341,6 +495,10 @@ func (h *Handler) RegenerateToken(c *gin.Context) {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
 
 	newToken, err := storage.RegenerateToken(user.ID)
 	if err != nil {
@@ -349,10 +507,63 @@ func (h *Handler) RegenerateToken(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"token":   newToken,
-		"command": fmt.Sprintf("gopublic auth %s", newToken),
-	})
+	resp, err := h.tokenResponse(user.ID, newToken)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set token expiry for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// tokenResponse describes a newly issued token. With a TokenTTL the token
+// expires, and the command also saves a refresh token the client renews it
+// with; it is shown only this once.
+func (h *Handler) tokenResponse(userID uint, token string) (gin.H, error) {
+	if h.TokenTTL <= 0 {
+		return gin.H{
+			"token":   token,
+			"command": fmt.Sprintf("gopublic auth %s", token),
+		}, nil
+	}
+	refresh, expiresAt, err := storage.SetTokenExpiry(userID, h.TokenTTL)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"token":      token,
+		"expires_at": expiresAt,
+		"command":    fmt.Sprintf("gopublic auth %s --refresh %s", token, refresh),
+	}, nil
+}
+
+// UpdateNotifications saves the user's notification preferences.
+func (h *Handler) UpdateNotifications(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		QuotaAlerts *bool `json:"quota_alerts"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.QuotaAlerts == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	if err := storage.SetQuotaAlerts(user.ID, *req.QuotaAlerts); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to update notifications for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"quota_alerts": *req.QuotaAlerts})
 }
 
 func (h *Handler) getUserFromSession(c *gin.Context) (*models.User, error) {
@@ -361,7 +572,14 @@ func (h *Handler) getUserFromSession(c *gin.Context) (*models.User, error) {
 		return nil, err
 	}
 
-	return storage.GetUserByID(session.UserID)
+	user, err := storage.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Status == models.UserStatusBanned {
+		return nil, errUserBanned
+	}
+	return user, nil
 }
 
 func (h *Handler) verifyTelegramHash(params map[string][]string) bool {
@@ -443,9 +661,11 @@ func (h *Handler) AcceptTerms(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
-// AbuseForm displays the abuse report form
+// AbuseForm displays the abuse report form.
+// The tunnel URL can be prefilled with ?url=, e.g. from a takedown notice.
 func (h *Handler) AbuseForm(c *gin.Context) {
 	c.HTML(http.StatusOK, "abuse.html", gin.H{
+		"TunnelURL":  c.Query("url"),
 		"GitHubRepo": h.GitHubRepo,
 		"Version":    version.Version,
 	})
@@ -489,6 +709,7 @@ func (h *Handler) SubmitAbuseReport(c *gin.Context) {
 	// Create abuse report
 	report := &models.AbuseReport{
 		TunnelURL:     req.TunnelURL,
+		Domain:        reportedHost(req.TunnelURL),
 		ReportType:    req.ReportType,
 		Description:   req.Description,
 		ReporterEmail: req.ReporterEmail,
@@ -509,10 +730,6 @@ func (h *Handler) SubmitAbuseReport(c *gin.Context) {
 
 // sendAbuseNotification sends a Telegram message to the admin about the abuse report
 func (h *Handler) sendAbuseNotification(report *models.AbuseReport) {
-	if h.AdminTelegramID == 0 || h.BotToken == "" {
-		return
-	}
-
 	reportTypes := map[string]string{
 		"phishing": "Фишинг",
 		"malware":  "Вредоносное ПО",
@@ -540,24 +757,7 @@ func (h *Handler) sendAbuseNotification(report *models.AbuseReport) {
 		message += fmt.Sprintf("\n*Email:* %s", report.ReporterEmail)
 	}
 
-	// Send via Telegram Bot API
-	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", h.BotToken)
-
-	payload := map[string]interface{}{
-		"chat_id":    h.AdminTelegramID,
-		"text":       message,
-		"parse_mode": "Markdown",
-	}
-
-	go func() {
-		jsonData, _ := json.Marshal(payload)
-		resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
-		if err != nil {
-			log.Printf("Failed to send Telegram notification: %v", err)
-			return
-		}
-		defer resp.Body.Close()
-	}()
+	h.notifyAdmin(message)
 }
 
 // YandexUserInfo represents user info from Yandex OAuth
@@ -747,22 +947,11 @@ func (h *Handler) YandexCallback(c *gin.Context) {
 			PhotoURL:  yandexUser.GetAvatarURL(),
 		}
 
-		// Generate domain names
-		prefixes := []string{"misty", "silent", "bold", "rapid", "cool"}
-		suffixes := []string{"river", "star", "eagle", "bear", "fox"}
-		var domains []string
-		for i := 0; i < h.DomainsPerUser; i++ {
-			name := fmt.Sprintf("%s-%s-%d", prefixes[i%len(prefixes)], suffixes[i%len(suffixes)], time.Now().Unix()%1000+int64(i))
-			domains = append(domains, name)
-		}
-
-		reg := storage.UserRegistration{
-			User:    newUser,
-			Domains: domains,
-		}
-
-		createdUser, _, err := storage.CreateUserWithTokenAndDomains(reg)
-		if err != nil {
+		createdUser, err := h.registerUser(c, newUser)
+		if errors.Is(err, ErrInviteRequired) || errors.Is(err, storage.ErrInviteInvalid) {
+			c.String(http.StatusForbidden, inviteErrorMessage(err))
+			return
+		} else if err != nil {
 			sentry.CaptureErrorWithContext(c, err, "Failed to create user via Yandex OAuth")
 			c.String(http.StatusInternalServerError, "Failed to create user account")
 			return
@@ -876,22 +1065,11 @@ func (h *Handler) YandexTokenAuth(c *gin.Context) {
 			PhotoURL:  yandexUser.GetAvatarURL(),
 		}
 
-		// Generate domain names
-		prefixes := []string{"misty", "silent", "bold", "rapid", "cool"}
-		suffixes := []string{"river", "star", "eagle", "bear", "fox"}
-		var domains []string
-		for i := 0; i < h.DomainsPerUser; i++ {
-			name := fmt.Sprintf("%s-%s-%d", prefixes[i%len(prefixes)], suffixes[i%len(suffixes)], time.Now().Unix()%1000+int64(i))
-			domains = append(domains, name)
-		}
-
-		reg := storage.UserRegistration{
-			User:    newUser,
-			Domains: domains,
-		}
-
-		createdUser, _, err := storage.CreateUserWithTokenAndDomains(reg)
-		if err != nil {
+		createdUser, err := h.registerUser(c, newUser)
+		if errors.Is(err, ErrInviteRequired) || errors.Is(err, storage.ErrInviteInvalid) {
+			c.JSON(http.StatusForbidden, gin.H{"error": inviteErrorMessage(err)})
+			return
+		} else if err != nil {
 			sentry.CaptureErrorWithContext(c, err, "Failed to create user via Yandex SDK")
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create user account"})
 			return
@@ -0,0 +1,71 @@
+package dashboard
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// maxConnectionEvents is how many of a domain's latest connection events
+// the dashboard shows.
+const maxConnectionEvents = 100
+
+// ConnectionEventRow is a single event of a domain's connection history.
+type ConnectionEventRow struct {
+	Time   time.Time `json:"time"`
+	Type   string    `json:"type"`             // models.ConnectionEvent*
+	Reason string    `json:"reason,omitempty"` // models.Disconnect*, for disconnects
+	Detail string    `json:"detail,omitempty"`
+	Agent  string    `json:"agent,omitempty"`
+}
+
+func connectionEventRows(events []models.ConnectionEvent) []ConnectionEventRow {
+	rows := make([]ConnectionEventRow, 0, len(events))
+	for _, e := range events {
+		rows = append(rows, ConnectionEventRow{
+			Time:   e.CreatedAt,
+			Type:   e.Type,
+			Reason: e.Reason,
+			Detail: e.Detail,
+			Agent:  e.Agent,
+		})
+	}
+	return rows
+}
+
+// ConnectionHistoryAPI returns the latest connects, late binds and
+// disconnects of one of the user's domains, newest first:
+// GET /api/domains/history?domain=...
+func (h *Handler) ConnectionHistoryAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	domains, err := storage.GetUserDomains(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch domains for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connection history"})
+		return
+	}
+	domain, ok := ownedDomain(domains, h.subdomainName(c.Query("domain")))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	}
+
+	events, err := storage.GetConnectionEvents(user.ID, h.domainHost(domain.Name), maxConnectionEvents)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch connection history of %s for user %d", domain.Name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch connection history"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"domain": domain.Name, "events": connectionEventRows(events)})
+}
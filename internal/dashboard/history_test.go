@@ -0,0 +1,28 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+func TestConnectionEventRows(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	rows := connectionEventRows([]models.ConnectionEvent{{
+		CreatedAt: at,
+		UserID:    1,
+		Domain:    "app.example.com",
+		Type:      models.ConnectionEventDisconnect,
+		Reason:    models.DisconnectForced,
+		Detail:    "banned by an administrator",
+		Agent:     "ci",
+	}})
+	want := ConnectionEventRow{Time: at, Type: "disconnect", Reason: "forced", Detail: "banned by an administrator", Agent: "ci"}
+	if len(rows) != 1 || rows[0] != want {
+		t.Errorf("connectionEventRows() = %+v, want [%+v]", rows, want)
+	}
+	if rows := connectionEventRows(nil); rows == nil || len(rows) != 0 {
+		t.Errorf("connectionEventRows(nil) = %#v, want an empty slice", rows)
+	}
+}
@@ -0,0 +1,115 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/errorpage"
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+	"gopublic/internal/version"
+)
+
+// HubRequest makes one of the user's domains their hub. An empty Domain
+// removes the hub.
+type HubRequest struct {
+	Domain string `json:"domain"`
+}
+
+// hubTunnels returns the online tunnels to list on a hub, without the hub
+// itself.
+func hubTunnels(active []string, hub string) []string {
+	tunnels := make([]string, 0, len(active))
+	for _, domain := range active {
+		if domain != hub {
+			tunnels = append(tunnels, domain)
+		}
+	}
+	sort.Strings(tunnels)
+	return tunnels
+}
+
+// HubPage renders the index of a user's online tunnels on their hub
+// domain. The ingress serves it while no tunnel is bound to the hub.
+func (h *Handler) HubPage(c *gin.Context, host string, userID uint) {
+	if c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead {
+		errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		return
+	}
+	if c.Request.URL.Path != "/" {
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
+		return
+	}
+
+	var active []string
+	if h.UserSessions != nil {
+		active = h.UserSessions.GetActiveDomains(userID)
+	}
+	c.Header("Cache-Control", "no-store")
+	c.HTML(http.StatusOK, "hub.html", gin.H{
+		"Host":       host,
+		"Tunnels":    hubTunnels(active, host),
+		"UpdatedAt":  time.Now().UTC().Format("2006-01-02 15:04 UTC"),
+		"GitHubRepo": h.GitHubRepo,
+		"Version":    version.Version,
+	})
+}
+
+// SetHubDomain chooses or removes the user's hub domain.
+func (h *Handler) SetHubDomain(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req HubRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(req.Domain))
+	if h.Domain != "" {
+		name = strings.TrimSuffix(name, "."+h.Domain)
+	}
+
+	err = storage.SetHubDomain(user.ID, name)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set hub %q for user %d", name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update domain"})
+		return
+	}
+	log.Printf("User %d set hub domain to %q", user.ID, name)
+
+	if h.Events != nil {
+		event := pubsub.Event{Type: pubsub.EventHubChanged, UserID: user.ID}
+		if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+			// Instances pick up the hub on restart
+			log.Printf("Failed to publish hub of user %d: %v", user.ID, err)
+		}
+	}
+
+	if name == "" {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "url": "https://" + h.domainHost(name)})
+}
@@ -0,0 +1,17 @@
+package dashboard
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHubTunnels(t *testing.T) {
+	active := []string{"web.example.com", "hub.example.com", "api.example.com"}
+	want := []string{"api.example.com", "web.example.com"}
+	if got := hubTunnels(active, "hub.example.com"); !reflect.DeepEqual(got, want) {
+		t.Errorf("hubTunnels() = %v, want %v", got, want)
+	}
+	if got := hubTunnels(nil, "hub.example.com"); len(got) != 0 {
+		t.Errorf("hubTunnels(nil) = %v, want none", got)
+	}
+}
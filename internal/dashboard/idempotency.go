@@ -0,0 +1,116 @@
+package dashboard
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// IdempotencyKeyTTL is how long the response to a public API request sent
+// with an Idempotency-Key header is replayed to retries.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// Idempotency limits.
+const (
+	maxIdempotencyKeyLen = 255
+	maxAPIRequestBody    = 64 << 10
+)
+
+// recordingWriter keeps a copy of the response body it writes.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// requestHash identifies a request for an idempotency key, so that a key
+// cannot be reused for a different request.
+func requestHash(method, path string, body []byte) string {
+	sum := sha256.New()
+	sum.Write([]byte(method + " " + path + "\n"))
+	sum.Write(body)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// Idempotent wraps a public API handler that changes state. A request with
+// an Idempotency-Key header runs once per user and key: retries within
+// IdempotencyKeyTTL get the stored response with Idempotent-Replayed set,
+// while the first request runs they get 409, and the same key with a
+// different request gets 422. Server errors are not stored, so the request
+// can be retried. Must run inside RequireAPIToken.
+func (h *Handler) Idempotent(next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			next(c)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLen {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Idempotency-Key is too long"})
+			return
+		}
+		body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxAPIRequestBody))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		user := apiUser(c)
+		hash := requestHash(c.Request.Method, c.Request.URL.Path, body)
+		record, claimed, err := storage.ClaimIdempotencyKey(user.ID, key, hash, time.Now().Add(-IdempotencyKeyTTL))
+		if err != nil {
+			sentry.CaptureErrorWithContextf(c, err, "Failed to claim idempotency key for user %d", user.ID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to process request"})
+			return
+		}
+		if !claimed {
+			switch {
+			case record.RequestHash != hash:
+				c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Idempotency-Key was already used for a different request"})
+			case record.Status == 0:
+				c.JSON(http.StatusConflict, gin.H{"error": "A request with this Idempotency-Key is in progress"})
+			default:
+				c.Header("Idempotent-Replayed", "true")
+				c.Data(record.Status, "application/json; charset=utf-8", record.Response)
+			}
+			return
+		}
+
+		w := &recordingWriter{ResponseWriter: c.Writer}
+		c.Writer = w
+		status := 0
+		defer func() {
+			// Also reached when next panics, with status 0
+			var err error
+			if status == 0 || status >= http.StatusInternalServerError {
+				err = storage.ReleaseIdempotencyKey(record.ID)
+			} else {
+				err = storage.CompleteIdempotencyKey(record.ID, status, w.body.Bytes())
+			}
+			if err != nil {
+				log.Printf("Failed to store idempotency key %d of user %d: %v", record.ID, user.ID, err)
+			}
+		}()
+		next(c)
+		status = w.Status()
+	}
+}
@@ -0,0 +1,64 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequestHash(t *testing.T) {
+	base := requestHash(http.MethodPost, "/api/v1/domains", []byte(`{"name":"a"}`))
+	if base != requestHash(http.MethodPost, "/api/v1/domains", []byte(`{"name":"a"}`)) {
+		t.Error("requestHash() differs for the same request")
+	}
+	for _, other := range []string{
+		requestHash(http.MethodPost, "/api/v1/domains", []byte(`{"name":"b"}`)),
+		requestHash(http.MethodPost, "/api/v1/tokens", []byte(`{"name":"a"}`)),
+		requestHash(http.MethodDelete, "/api/v1/domains", []byte(`{"name":"a"}`)),
+	} {
+		if other == base {
+			t.Error("requestHash() equal for different requests")
+		}
+	}
+}
+
+func TestRecordingWriter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	recorder := &recordingWriter{ResponseWriter: c.Writer}
+	c.Writer = recorder
+
+	c.JSON(http.StatusCreated, gin.H{"id": 1})
+	if recorder.Status() != http.StatusCreated || recorder.body.String() != w.Body.String() || w.Body.Len() == 0 {
+		t.Errorf("recorded %d %q, sent %d %q", recorder.Status(), recorder.body.String(), w.Code, w.Body.String())
+	}
+}
+
+func TestIdempotent_WithoutKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{}
+	calls := 0
+	next := func(c *gin.Context) { calls++ }
+
+	// Without a key the request runs as is, without the database
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/domains", nil)
+	h.Idempotent(next)(c)
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1", calls)
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/domains", nil)
+	c.Request.Header.Set("Idempotency-Key", strings.Repeat("k", maxIdempotencyKeyLen+1))
+	h.Idempotent(next)(c)
+	if w.Code != http.StatusBadRequest || calls != 1 {
+		t.Errorf("long key: status = %d, calls = %d, want 400 without a call", w.Code, calls)
+	}
+}
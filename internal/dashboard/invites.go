@@ -0,0 +1,194 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// inviteCookie carries the invite code from the login page through OAuth.
+const inviteCookie = "invite_code"
+
+// ErrInviteRequired is returned when an invite-only server gets a signup without a code.
+var ErrInviteRequired = errors.New("invite code required")
+
+// InviteRow is a single invite shown on the dashboard.
+type InviteRow struct {
+	Code      string     `json:"code"`
+	Link      string     `json:"link"`
+	Uses      int        `json:"uses"`
+	MaxUses   int        `json:"max_uses"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// normalizeInviteCode uppercases a user-entered code and trims whitespace.
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// inviteErrorMessage returns the text shown when a signup is refused.
+func inviteErrorMessage(err error) string {
+	if errors.Is(err, storage.ErrInviteInvalid) {
+		return "Код приглашения недействителен, истёк или уже использован"
+	}
+	return "Регистрация только по приглашениям"
+}
+
+// inviteLink builds the login URL that applies the invite code.
+func (h *Handler) inviteLink(code string) string {
+	if h.Domain == "localhost" || h.Domain == "127.0.0.1" {
+		return fmt.Sprintf("http://%s/login?invite=%s", h.Domain, code)
+	}
+	return fmt.Sprintf("https://app.%s/login?invite=%s", h.Domain, code)
+}
+
+// rememberInvite stores ?invite= in a cookie and returns the pending code, if any.
+func (h *Handler) rememberInvite(c *gin.Context) string {
+	code := normalizeInviteCode(c.Query("invite"))
+	if code == "" {
+		code, _ = c.Cookie(inviteCookie)
+		return code
+	}
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     inviteCookie,
+		Value:    code,
+		Path:     "/",
+		MaxAge:   3600, // 1 hour
+		HttpOnly: true,
+		Secure:   h.Domain != "localhost" && h.Domain != "127.0.0.1",
+		SameSite: http.SameSiteLaxMode,
+	})
+	return code
+}
+
+// takeInvite returns the invite code remembered at login and clears the cookie.
+func (h *Handler) takeInvite(c *gin.Context) string {
+	code, err := c.Cookie(inviteCookie)
+	if err != nil {
+		return ""
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:   inviteCookie,
+		Value:  "",
+		Path:   "/",
+		MaxAge: -1,
+	})
+	return normalizeInviteCode(code)
+}
+
+// InvitesAPI returns the session user's invites and referral count.
+// The administrator sees the codes created from the admin account.
+func (h *Handler) InvitesAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var owner uint
+	if !h.isAdmin(user) {
+		owner = user.ID
+	}
+	invites, err := storage.GetUserInvites(owner)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to load invites for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invites"})
+		return
+	}
+	referrals, err := storage.CountReferrals(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to count referrals for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load invites"})
+		return
+	}
+
+	rows := make([]InviteRow, 0, len(invites))
+	for _, inv := range invites {
+		rows = append(rows, InviteRow{
+			Code:      inv.Code,
+			Link:      h.inviteLink(inv.Code),
+			Uses:      inv.Uses,
+			MaxUses:   inv.MaxUses,
+			ExpiresAt: inv.ExpiresAt,
+			CreatedAt: inv.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"invites":   rows,
+		"referrals": referrals,
+	})
+}
+
+// CreateInvite issues a new invite code. User codes are limited to
+// InviteMaxUses signups; the administrator may set max_uses (0 = unlimited)
+// and expires_in_days.
+func (h *Handler) CreateInvite(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	code, err := auth.GenerateInviteCode()
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to generate invite code")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+	invite := &models.Invite{Code: code, MaxUses: h.InviteMaxUses}
+
+	if h.isAdmin(user) {
+		var req struct {
+			MaxUses       int `json:"max_uses"`
+			ExpiresInDays int `json:"expires_in_days"`
+		}
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil || req.MaxUses < 0 || req.ExpiresInDays < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+				return
+			}
+		}
+		invite.MaxUses = req.MaxUses
+		if req.ExpiresInDays > 0 {
+			expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+			invite.ExpiresAt = &expires
+		}
+	} else {
+		invite.CreatedBy = &user.ID
+	}
+
+	if err := storage.CreateInvite(invite); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to create invite for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create invite"})
+		return
+	}
+
+	c.JSON(http.StatusOK, InviteRow{
+		Code:      invite.Code,
+		Link:      h.inviteLink(invite.Code),
+		Uses:      invite.Uses,
+		MaxUses:   invite.MaxUses,
+		ExpiresAt: invite.ExpiresAt,
+		CreatedAt: invite.CreatedAt,
+	})
+}
@@ -0,0 +1,58 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestInviteLink(t *testing.T) {
+	tests := []struct {
+		domain string
+		want   string
+	}{
+		{"example.com", "https://app.example.com/login?invite=K7QX-M2PA"},
+		{"localhost", "http://localhost/login?invite=K7QX-M2PA"},
+	}
+	for _, tt := range tests {
+		h := &Handler{Domain: tt.domain}
+		if got := h.inviteLink("K7QX-M2PA"); got != tt.want {
+			t.Errorf("inviteLink() with domain %q = %q, want %q", tt.domain, got, tt.want)
+		}
+	}
+}
+
+func TestRememberAndTakeInvite(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{Domain: "example.com"}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/login?invite=+k7qx-m2pa+", nil)
+	if got := h.rememberInvite(c); got != "K7QX-M2PA" {
+		t.Fatalf("rememberInvite() = %q, want K7QX-M2PA", got)
+	}
+	cookies := w.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != inviteCookie {
+		t.Fatalf("expected %s cookie, got %v", inviteCookie, cookies)
+	}
+
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/telegram", nil)
+	c.Request.AddCookie(cookies[0])
+	if got := h.takeInvite(c); got != "K7QX-M2PA" {
+		t.Errorf("takeInvite() = %q, want K7QX-M2PA", got)
+	}
+	if cleared := w.Result().Cookies(); len(cleared) != 1 || cleared[0].MaxAge >= 0 {
+		t.Errorf("expected invite cookie to be cleared, got %v", cleared)
+	}
+
+	c, _ = gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest(http.MethodGet, "/auth/telegram", nil)
+	if got := h.takeInvite(c); got != "" {
+		t.Errorf("takeInvite() without cookie = %q, want empty", got)
+	}
+}
@@ -0,0 +1,179 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// Join token limits.
+const (
+	defaultJoinTTL   = 24 * time.Hour
+	maxJoinTTL       = 7 * 24 * time.Hour
+	maxJoinTokens    = 20                 // Unused, unexpired join tokens per user
+	joinTokensPeriod = 7 * 24 * time.Hour // How long join tokens stay listed
+)
+
+// JoinTokenRow is a single join token of the user.
+type JoinTokenRow struct {
+	ID        uint       `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	UsedBy    string     `json:"used_by,omitempty"`
+	Active    bool       `json:"active"` // Neither used nor expired
+}
+
+// JoinTokenRequest creates a join token.
+type JoinTokenRequest struct {
+	TTLHours int `json:"ttl_hours"` // 0 = defaultJoinTTL
+}
+
+// errJoinTTL is returned by joinTTL, shown to the user.
+var errJoinTTL = errors.New("lifetime must be between 1 and 168 hours")
+
+// joinTTL returns the lifetime of a new join token.
+func joinTTL(hours int) (time.Duration, error) {
+	if hours == 0 {
+		return defaultJoinTTL, nil
+	}
+	ttl := time.Duration(hours) * time.Hour
+	if hours < 0 || ttl > maxJoinTTL {
+		return 0, errJoinTTL
+	}
+	return ttl, nil
+}
+
+func joinTokenRows(tokens []models.JoinToken, now time.Time) []JoinTokenRow {
+	rows := make([]JoinTokenRow, 0, len(tokens))
+	for _, t := range tokens {
+		rows = append(rows, JoinTokenRow{
+			ID:        t.ID,
+			CreatedAt: t.CreatedAt,
+			ExpiresAt: t.ExpiresAt,
+			UsedAt:    t.UsedAt,
+			UsedBy:    t.UsedBy,
+			Active:    t.UsedAt == nil && now.Before(t.ExpiresAt),
+		})
+	}
+	return rows
+}
+
+// JoinTokensAPI returns the user's recent join tokens: GET /api/join-tokens
+func (h *Handler) JoinTokensAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	now := time.Now()
+	tokens, err := storage.GetUserJoinTokens(user.ID, now.Add(-joinTokensPeriod))
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list join tokens for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load join tokens"})
+		return
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"join_tokens": joinTokenRows(tokens, now)})
+}
+
+// CreateJoinToken issues a one-time token an agent exchanges for a token
+// of its own with "gopublic join". It is shown only this once.
+func (h *Handler) CreateJoinToken(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req JoinTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	ttl, err := joinTTL(req.TTLHours)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	now := time.Now()
+	existing, err := storage.GetUserJoinTokens(user.ID, now.Add(-maxJoinTTL))
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list join tokens for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create join token"})
+		return
+	}
+	active := 0
+	for _, row := range joinTokenRows(existing, now) {
+		if row.Active {
+			active++
+		}
+	}
+	if active >= maxJoinTokens {
+		c.JSON(http.StatusConflict, gin.H{"error": "Too many unused join tokens; revoke some first"})
+		return
+	}
+
+	join, record, err := storage.CreateJoinToken(user.ID, ttl)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to create join token for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create join token"})
+		return
+	}
+	log.Printf("User %d created join token %d valid until %s", user.ID, record.ID, record.ExpiresAt.Format(time.RFC3339))
+
+	c.JSON(http.StatusOK, gin.H{
+		"token":      join,
+		"expires_at": record.ExpiresAt,
+		"command":    fmt.Sprintf("gopublic join %s --agent AGENT-NAME", join),
+	})
+}
+
+// DeleteJoinToken revokes one of the user's join tokens. Tokens already
+// obtained with it keep working.
+func (h *Handler) DeleteJoinToken(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req struct {
+		ID uint `json:"id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	err = storage.DeleteJoinToken(user.ID, req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Join token not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to delete join token %d for user %d", req.ID, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke join token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
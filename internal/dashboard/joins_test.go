@@ -0,0 +1,47 @@
+package dashboard
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+func TestJoinTTL(t *testing.T) {
+	tests := []struct {
+		hours   int
+		want    time.Duration
+		wantErr error
+	}{
+		{0, defaultJoinTTL, nil},
+		{1, time.Hour, nil},
+		{168, maxJoinTTL, nil},
+		{169, 0, errJoinTTL},
+		{-1, 0, errJoinTTL},
+	}
+	for _, tt := range tests {
+		got, err := joinTTL(tt.hours)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("joinTTL(%d) = %v, %v, want %v, %v", tt.hours, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestJoinTokenRows(t *testing.T) {
+	now := time.Now()
+	used := now.Add(-time.Minute)
+	tokens := []models.JoinToken{
+		{ExpiresAt: now.Add(time.Hour)},
+		{ExpiresAt: now.Add(time.Hour), UsedAt: &used, UsedBy: "ci"},
+		{ExpiresAt: now.Add(-time.Hour)},
+	}
+
+	rows := joinTokenRows(tokens, now)
+	if !rows[0].Active || rows[1].Active || rows[2].Active {
+		t.Errorf("active = %v %v %v, want only the first", rows[0].Active, rows[1].Active, rows[2].Active)
+	}
+	if rows[1].UsedBy != "ci" {
+		t.Errorf("UsedBy = %q, want ci", rows[1].UsedBy)
+	}
+}
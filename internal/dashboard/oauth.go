@@ -0,0 +1,188 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// oauthStateCookie carries the OAuth state through the round-trip to
+// GitHub or Google.
+const oauthStateCookie = "oauth_state"
+
+// getOAuthRedirectURL returns the callback URL of a GitHub or Google login
+// based on domain
+func (h *Handler) getOAuthRedirectURL(provider *auth.OAuthProvider) string {
+	if h.Domain == "localhost" || h.Domain == "127.0.0.1" {
+		return fmt.Sprintf("http://%s/auth/%s/callback", h.Domain, provider.Name)
+	}
+	return fmt.Sprintf("https://app.%s/auth/%s/callback", h.Domain, provider.Name)
+}
+
+// oauthUser returns a new account for a first login with the provider.
+func oauthUser(provider string, identity *auth.OAuthIdentity) *models.User {
+	id := identity.ID
+	user := &models.User{
+		Email:     identity.Email,
+		FirstName: identity.GivenName,
+		LastName:  identity.FamilyName,
+		Username:  identity.Username,
+		PhotoURL:  identity.Picture,
+	}
+	switch provider {
+	case models.ProviderGitHub:
+		user.GitHubID = &id
+	case models.ProviderGoogle:
+		user.GoogleID = &id
+	}
+	return user
+}
+
+// updateFromOAuth refreshes the profile of an account from the provider,
+// keeping what the provider did not send.
+func updateFromOAuth(user *models.User, identity *auth.OAuthIdentity) {
+	if identity.GivenName != "" || identity.FamilyName != "" {
+		user.FirstName = identity.GivenName
+		user.LastName = identity.FamilyName
+	}
+	if identity.Username != "" {
+		user.Username = identity.Username
+	}
+	if identity.Email != "" {
+		user.Email = identity.Email
+	}
+	if identity.Picture != "" {
+		user.PhotoURL = identity.Picture
+	}
+}
+
+// GitHubAuth initiates the GitHub OAuth flow
+func (h *Handler) GitHubAuth(c *gin.Context) {
+	h.oauthAuth(c, h.GitHub)
+}
+
+// GitHubCallback handles the OAuth callback from GitHub
+func (h *Handler) GitHubCallback(c *gin.Context) {
+	h.oauthCallback(c, h.GitHub)
+}
+
+// GoogleAuth initiates the Google OAuth flow
+func (h *Handler) GoogleAuth(c *gin.Context) {
+	h.oauthAuth(c, h.Google)
+}
+
+// GoogleCallback handles the OAuth callback from Google
+func (h *Handler) GoogleCallback(c *gin.Context) {
+	h.oauthCallback(c, h.Google)
+}
+
+// oauthAuth redirects to the provider's login page.
+func (h *Handler) oauthAuth(c *gin.Context, provider *auth.OAuthProvider) {
+	if provider == nil {
+		c.String(http.StatusNotFound, "OAuth provider not configured")
+		return
+	}
+
+	state := generateState()
+	h.setSSOCookie(c, oauthStateCookie, state)
+	c.Redirect(http.StatusTemporaryRedirect, provider.AuthURL(h.getOAuthRedirectURL(provider), state))
+}
+
+// oauthCallback signs in with the account the provider vouched for. A
+// signed-in user links the account to theirs instead; an unknown account
+// registers a new user like any other first login.
+func (h *Handler) oauthCallback(c *gin.Context, provider *auth.OAuthProvider) {
+	if provider == nil {
+		c.String(http.StatusNotFound, "OAuth provider not configured")
+		return
+	}
+
+	stateCookie, err := c.Cookie(oauthStateCookie)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Missing state cookie")
+		return
+	}
+	state := c.Query("state")
+	if state == "" || state != stateCookie {
+		c.String(http.StatusBadRequest, "Invalid state parameter")
+		return
+	}
+	h.setSSOCookie(c, oauthStateCookie, "")
+
+	if errMsg := c.Query("error"); errMsg != "" {
+		log.Printf("%s OAuth error: %s - %s", provider.Label, errMsg, c.Query("error_description"))
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.String(http.StatusBadRequest, "Missing authorization code")
+		return
+	}
+
+	identity, err := provider.Exchange(c.Request.Context(), code, h.getOAuthRedirectURL(provider))
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "%s code exchange failed", provider.Label)
+		c.String(http.StatusInternalServerError, "Failed to authenticate with "+provider.Label)
+		return
+	}
+
+	user, err := storage.GetUserByOAuthID(provider.Name, identity.ID)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		sentry.CaptureErrorWithContextf(c, err, "Database error looking up %s user", provider.Label)
+		c.String(http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Check if user is already logged in (linking account)
+	if existingUser, err := h.getUserFromSession(c); err == nil {
+		if user != nil && user.ID != existingUser.ID {
+			c.String(http.StatusConflict, "This "+provider.Label+" account is already linked to another user")
+			return
+		}
+		if err := storage.LinkOAuthAccount(existingUser.ID, provider.Name, identity.ID); err != nil {
+			sentry.CaptureErrorWithContextf(c, err, "Failed to link %s account", provider.Label)
+			c.String(http.StatusInternalServerError, "Failed to link "+provider.Label+" account")
+			return
+		}
+		log.Printf("User %d linked %s account %s", existingUser.ID, provider.Label, identity.ID)
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+		return
+	}
+
+	if user == nil {
+		createdUser, err := h.registerUser(c, oauthUser(provider.Name, identity))
+		if errors.Is(err, ErrInviteRequired) || errors.Is(err, storage.ErrInviteInvalid) {
+			c.String(http.StatusForbidden, inviteErrorMessage(err))
+			return
+		} else if err != nil {
+			sentry.CaptureErrorWithContextf(c, err, "Failed to create user via %s OAuth", provider.Label)
+			c.String(http.StatusInternalServerError, "Failed to create user account")
+			return
+		}
+		user = createdUser
+	} else {
+		updateFromOAuth(user, identity)
+		if err := storage.UpdateUser(user); err != nil {
+			sentry.CaptureErrorWithContextf(c, err, "Failed to update %s user", provider.Label)
+			c.String(http.StatusInternalServerError, "Failed to update user")
+			return
+		}
+	}
+
+	if err := h.Session.SetSession(c.Writer, user.ID); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set session after %s login", provider.Label)
+		c.String(http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+
+	c.Redirect(http.StatusTemporaryRedirect, "/")
+}
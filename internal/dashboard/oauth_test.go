@@ -0,0 +1,43 @@
+package dashboard
+
+import (
+	"testing"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+)
+
+func TestGetOAuthRedirectURL(t *testing.T) {
+	github := auth.NewGitHubProvider("", "")
+	if got := (&Handler{Domain: "example.com"}).getOAuthRedirectURL(github); got != "https://app.example.com/auth/github/callback" {
+		t.Errorf("getOAuthRedirectURL() = %q", got)
+	}
+	if got := (&Handler{Domain: "localhost"}).getOAuthRedirectURL(auth.NewGoogleProvider("", "")); got != "http://localhost/auth/google/callback" {
+		t.Errorf("getOAuthRedirectURL() on localhost = %q", got)
+	}
+}
+
+func TestOAuthUser(t *testing.T) {
+	identity := &auth.OAuthIdentity{ID: "583231", Email: "octocat@example.com", GivenName: "Mona", Username: "octocat"}
+	user := oauthUser(models.ProviderGitHub, identity)
+	if user.GitHubID == nil || *user.GitHubID != "583231" || user.GoogleID != nil {
+		t.Errorf("oauthUser(github) IDs = %v, %v", user.GitHubID, user.GoogleID)
+	}
+	if user.Email != "octocat@example.com" || user.FirstName != "Mona" || user.Username != "octocat" {
+		t.Errorf("oauthUser(github) = %+v", user)
+	}
+
+	user = oauthUser(models.ProviderGoogle, &auth.OAuthIdentity{ID: "1098"})
+	if user.GoogleID == nil || *user.GoogleID != "1098" || user.GitHubID != nil {
+		t.Errorf("oauthUser(google) IDs = %v, %v", user.GitHubID, user.GoogleID)
+	}
+}
+
+func TestUpdateFromOAuth(t *testing.T) {
+	user := &models.User{FirstName: "Ivan", LastName: "Petrov", Username: "ivan", Email: "ivan@example.com", PhotoURL: "https://old"}
+	// Google sends no username and GitHub may send no verified email
+	updateFromOAuth(user, &auth.OAuthIdentity{GivenName: "Ivan", FamilyName: "Sidorov"})
+	if user.LastName != "Sidorov" || user.Username != "ivan" || user.Email != "ivan@example.com" || user.PhotoURL != "https://old" {
+		t.Errorf("updateFromOAuth() = %+v", user)
+	}
+}
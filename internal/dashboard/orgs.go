@@ -0,0 +1,278 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// maxOrgNameLength limits organization names, in characters.
+const maxOrgNameLength = 64
+
+// OrganizationRow is an organization the user belongs to.
+type OrganizationRow struct {
+	ID   uint   `json:"id"`
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+// OrganizationMemberRow is a member of an organization.
+type OrganizationMemberRow struct {
+	ID       uint   `json:"id"` // User ID
+	Username string `json:"username"`
+	Name     string `json:"name"`
+	Role     string `json:"role"`
+}
+
+// OrganizationMemberRequest adds a member or changes their role. New
+// members are found by Telegram username or email (To), existing ones by
+// user ID.
+type OrganizationMemberRequest struct {
+	ID   uint   `json:"id"`
+	To   string `json:"to"`
+	Role string `json:"role"`
+}
+
+// orgRoleAssignable reports whether an organization member with role
+// actor may give or take away role; only the owner handles admins, like
+// SetUserRole.
+func orgRoleAssignable(actor, role string) bool {
+	switch role {
+	case models.RoleMember, models.RoleViewer:
+		return roleAtLeast(actor, models.RoleAdmin)
+	case models.RoleAdmin:
+		return actor == models.RoleOwner
+	}
+	return false
+}
+
+// OrganizationsAPI returns the organizations of the user with their role
+// in each: GET /api/orgs
+func (h *Handler) OrganizationsAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	members, err := storage.GetUserOrganizations(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list organizations of user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organizations"})
+		return
+	}
+	rows := make([]OrganizationRow, 0, len(members))
+	for _, m := range members {
+		rows = append(rows, OrganizationRow{ID: m.OrganizationID, Name: m.Organization.Name, Role: h.effectiveOrgRole(user, m.Role)})
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"organizations": rows})
+}
+
+// CreateOrganization creates an organization owned by the user:
+// POST /api/orgs {"name": "..."}
+func (h *Handler) CreateOrganization(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req struct {
+		Name string `json:"name"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxOrgNameLength {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name must be 1 to 64 characters"})
+		return
+	}
+
+	org, err := storage.CreateOrganization(name, user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to create organization for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create organization"})
+		return
+	}
+	log.Printf("User %d created organization %d", user.ID, org.ID)
+	c.JSON(http.StatusOK, OrganizationRow{ID: org.ID, Name: org.Name, Role: models.RoleOwner})
+}
+
+// OrganizationMembersAPI lists the members of an organization:
+// GET /api/orgs/members?org=ID, behind RequireOrgRole.
+func (h *Handler) OrganizationMembersAPI(c *gin.Context) {
+	member := requestOrgMember(c)
+	members, err := storage.GetOrganizationMembers(member.OrgID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list members of organization %d", member.OrgID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load members"})
+		return
+	}
+	rows := make([]OrganizationMemberRow, 0, len(members))
+	for _, m := range members {
+		rows = append(rows, OrganizationMemberRow{
+			ID:       m.UserID,
+			Username: m.User.Username,
+			Name:     strings.TrimSpace(m.User.FirstName + " " + m.User.LastName),
+			Role:     m.Role,
+		})
+	}
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"members": rows})
+}
+
+// AddOrganizationMember adds a user to an organization:
+// POST /api/orgs/members?org=ID {"to": "...", "role": "..."}, behind
+// RequireOrgRole(models.RoleAdmin).
+func (h *Handler) AddOrganizationMember(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	member := requestOrgMember(c)
+
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Role == "" {
+		req.Role = models.RoleMember
+	}
+	if req.Role != models.RoleAdmin && req.Role != models.RoleMember && req.Role != models.RoleViewer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin, member or viewer"})
+		return
+	}
+	if !orgRoleAssignable(member.Role, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	target, err := storage.GetUserByHandle(req.To)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to look up new member of organization %d", member.OrgID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+	if target.Status == models.UserStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "User account is pending approval"})
+		return
+	}
+
+	err = storage.AddOrganizationMember(member.OrgID, target.ID, req.Role)
+	if errors.Is(err, storage.ErrAlreadyMember) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The user is already a member"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to add user %d to organization %d", target.ID, member.OrgID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add member"})
+		return
+	}
+	log.Printf("User %d added user %d to organization %d as %s", member.User.ID, target.ID, member.OrgID, req.Role)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// SetOrganizationRole changes the role of another member:
+// POST /api/orgs/members/role?org=ID {"id": ..., "role": "..."}, behind
+// RequireOrgRole(models.RoleAdmin). The owner's role cannot change.
+func (h *Handler) SetOrganizationRole(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	member := requestOrgMember(c)
+
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Role != models.RoleAdmin && req.Role != models.RoleMember && req.Role != models.RoleViewer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin, member or viewer"})
+		return
+	}
+	if req.ID == member.User.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	current, err := storage.GetOrganizationRole(member.OrgID, req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to load role of user %d in organization %d", req.ID, member.OrgID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	if !orgRoleAssignable(member.Role, current) || !orgRoleAssignable(member.Role, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := storage.SetOrganizationRole(member.OrgID, req.ID, req.Role); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set role of user %d in organization %d", req.ID, member.OrgID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	log.Printf("User %d set the role of user %d in organization %d to %s", member.User.ID, req.ID, member.OrgID, req.Role)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// RemoveOrganizationMember removes a member from an organization:
+// POST /api/orgs/members/remove?org=ID {"id": ...}, behind
+// RequireOrgRole(models.RoleViewer). Anyone but the owner may leave;
+// removing others takes the rights to give their role.
+func (h *Handler) RemoveOrganizationMember(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	member := requestOrgMember(c)
+
+	var req OrganizationMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	current, err := storage.GetOrganizationRole(member.OrgID, req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Member not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to load role of user %d in organization %d", req.ID, member.OrgID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	if current == models.RoleOwner || (req.ID != member.User.ID && !orgRoleAssignable(member.Role, current)) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := storage.RemoveOrganizationMember(member.OrgID, req.ID); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to remove user %d from organization %d", req.ID, member.OrgID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to remove member"})
+		return
+	}
+	log.Printf("User %d removed user %d from organization %d", member.User.ID, req.ID, member.OrgID)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
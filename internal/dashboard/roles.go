@@ -0,0 +1,203 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// roleRank orders the roles; unknown roles rank below viewers.
+func roleRank(role string) int {
+	switch role {
+	case models.RoleOwner:
+		return 4
+	case models.RoleAdmin:
+		return 3
+	case models.RoleMember, "":
+		return 2
+	case models.RoleViewer:
+		return 1
+	}
+	return 0
+}
+
+// roleAtLeast reports whether role grants at least the rights of min.
+func roleAtLeast(role, min string) bool {
+	return roleRank(role) >= roleRank(min)
+}
+
+// role returns the effective role of a user: the configured administrator
+// is the owner, users without a role are members.
+func (h *Handler) role(user *models.User) string {
+	if h.AdminTelegramID != 0 && user.TelegramID != nil && *user.TelegramID == h.AdminTelegramID {
+		return models.RoleOwner
+	}
+	if user.Role == "" {
+		return models.RoleMember
+	}
+	return user.Role
+}
+
+// RequireRole wraps a dashboard handler so that only users with at least
+// the instance-wide role min reach it. API paths are refused with a JSON
+// error, pages redirect to the login or home page. Routes of a single
+// organization use RequireOrgRole instead.
+func (h *Handler) RequireRole(min string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		api := strings.HasPrefix(c.Request.URL.Path, "/api/")
+		user, err := h.getUserFromSession(c)
+		if err != nil {
+			if api {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			} else {
+				c.Redirect(http.StatusTemporaryRedirect, "/login")
+			}
+			return
+		}
+		if !roleAtLeast(h.role(user), min) {
+			if api {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			} else {
+				c.Redirect(http.StatusTemporaryRedirect, "/")
+			}
+			return
+		}
+		next(c)
+	}
+}
+
+// orgMemberKey is the gin context key of the membership checked by
+// RequireOrgRole.
+const orgMemberKey = "org_member"
+
+// orgMember is the signed-in user's membership in the organization of a
+// request.
+type orgMember struct {
+	User  *models.User
+	OrgID uint
+	Role  string
+}
+
+// requestOrgMember returns the membership checked by RequireOrgRole.
+func requestOrgMember(c *gin.Context) *orgMember {
+	member, _ := c.MustGet(orgMemberKey).(*orgMember)
+	return member
+}
+
+// effectiveOrgRole returns the role a user has in an organization they
+// are a member of with role: instance viewers stay viewers whatever their
+// membership says.
+func (h *Handler) effectiveOrgRole(user *models.User, role string) string {
+	if h.role(user) == models.RoleViewer && roleAtLeast(role, models.RoleMember) {
+		return models.RoleViewer
+	}
+	return role
+}
+
+// orgRole returns the effective role of a user in an organization, or
+// storage.ErrNotFound if they are not a member.
+func (h *Handler) orgRole(user *models.User, orgID uint) (string, error) {
+	role, err := storage.GetOrganizationRole(orgID, user.ID)
+	if err != nil {
+		return "", err
+	}
+	return h.effectiveOrgRole(user, role), nil
+}
+
+// RequireOrgRole wraps an organization API handler so that only members
+// with at least role min in the organization of the "org" query parameter
+// reach it. Other users get 404, as if the organization did not exist.
+func (h *Handler) RequireOrgRole(min string, next gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, err := h.getUserFromSession(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		orgID, err := strconv.ParseUint(c.Query("org"), 10, 0)
+		if err != nil || orgID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization"})
+			return
+		}
+		role, err := h.orgRole(user, uint(orgID))
+		if errors.Is(err, storage.ErrNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Organization not found"})
+			return
+		} else if err != nil {
+			sentry.CaptureErrorWithContextf(c, err, "Failed to load role of user %d in organization %d", user.ID, orgID)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load organization"})
+			return
+		}
+		if !roleAtLeast(role, min) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+			return
+		}
+		c.Set(orgMemberKey, &orgMember{User: user, OrgID: uint(orgID), Role: role})
+		next(c)
+	}
+}
+
+// RoleRequest changes the role of a user.
+type RoleRequest struct {
+	ID   uint   `json:"id"`
+	Role string `json:"role"`
+}
+
+// SetUserRole changes the role of another user. Admins may make users
+// members or viewers; granting or taking away admin is up to the owner.
+func (h *Handler) SetUserRole(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	actor, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req RoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	if req.Role != models.RoleAdmin && req.Role != models.RoleMember && req.Role != models.RoleViewer {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin, member or viewer"})
+		return
+	}
+	if req.ID == actor.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "You cannot change your own role"})
+		return
+	}
+
+	target, err := storage.GetUserByID(req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to load user %d", req.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	current := h.role(target)
+	if current == models.RoleOwner ||
+		((req.Role == models.RoleAdmin || current == models.RoleAdmin) && h.role(actor) != models.RoleOwner) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return
+	}
+
+	if err := storage.SetUserRole(target.ID, req.Role); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set role of user %d", target.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role"})
+		return
+	}
+	log.Printf("User %d set the role of user %d to %s", actor.ID, target.ID, req.Role)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,128 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+)
+
+func TestRoleAtLeast(t *testing.T) {
+	tests := []struct {
+		role, min string
+		want      bool
+	}{
+		{models.RoleOwner, models.RoleAdmin, true},
+		{models.RoleAdmin, models.RoleAdmin, true},
+		{models.RoleMember, models.RoleAdmin, false},
+		{"", models.RoleMember, true},
+		{models.RoleViewer, models.RoleMember, false},
+		{models.RoleViewer, models.RoleViewer, true},
+		{"superuser", models.RoleViewer, false},
+	}
+	for _, tt := range tests {
+		if got := roleAtLeast(tt.role, tt.min); got != tt.want {
+			t.Errorf("roleAtLeast(%q, %q) = %v, want %v", tt.role, tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_Role(t *testing.T) {
+	adminID := int64(42)
+	h := &Handler{AdminTelegramID: adminID}
+	tests := []struct {
+		user models.User
+		want string
+	}{
+		{models.User{TelegramID: &adminID, Role: models.RoleViewer}, models.RoleOwner},
+		{models.User{}, models.RoleMember},
+		{models.User{Role: models.RoleViewer}, models.RoleViewer},
+		{models.User{Role: models.RoleAdmin}, models.RoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := h.role(&tt.user); got != tt.want {
+			t.Errorf("role(%+v) = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestRequireRole_NoSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	session, err := auth.NewSessionManager(auth.SessionConfig{AllowInsecureKeys: true})
+	if err != nil {
+		t.Fatalf("NewSessionManager: %v", err)
+	}
+	h := &Handler{Session: session}
+	reached := false
+	next := func(c *gin.Context) { reached = true }
+
+	for path, want := range map[string]int{"/api/signups": http.StatusUnauthorized, "/admin/signups": http.StatusTemporaryRedirect} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest(http.MethodGet, path, nil)
+		h.RequireRole(models.RoleAdmin, next)(c)
+		if w.Code != want || reached {
+			t.Errorf("%s: status = %d, reached = %v, want %d", path, w.Code, reached, want)
+		}
+	}
+}
+
+func TestRequireOrgRole_NoSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	session, err := auth.NewSessionManager(auth.SessionConfig{AllowInsecureKeys: true})
+	if err != nil {
+		t.Fatalf("NewSessionManager: %v", err)
+	}
+	h := &Handler{Session: session}
+	reached := false
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/orgs/members?org=1", nil)
+	h.RequireOrgRole(models.RoleViewer, func(c *gin.Context) { reached = true })(c)
+	if w.Code != http.StatusUnauthorized || reached {
+		t.Errorf("status = %d, reached = %v, want 401", w.Code, reached)
+	}
+}
+
+func TestEffectiveOrgRole(t *testing.T) {
+	h := &Handler{}
+	tests := []struct {
+		user models.User
+		role string
+		want string
+	}{
+		{models.User{}, models.RoleOwner, models.RoleOwner},
+		{models.User{Role: models.RoleViewer}, models.RoleAdmin, models.RoleViewer},
+		{models.User{Role: models.RoleViewer}, models.RoleViewer, models.RoleViewer},
+		{models.User{Role: models.RoleAdmin}, models.RoleMember, models.RoleMember},
+	}
+	for _, tt := range tests {
+		if got := h.effectiveOrgRole(&tt.user, tt.role); got != tt.want {
+			t.Errorf("effectiveOrgRole(%q, %q) = %q, want %q", tt.user.Role, tt.role, got, tt.want)
+		}
+	}
+}
+
+func TestOrgRoleAssignable(t *testing.T) {
+	tests := []struct {
+		actor, role string
+		want        bool
+	}{
+		{models.RoleOwner, models.RoleAdmin, true},
+		{models.RoleOwner, models.RoleViewer, true},
+		{models.RoleAdmin, models.RoleMember, true},
+		{models.RoleAdmin, models.RoleAdmin, false},
+		{models.RoleMember, models.RoleViewer, false},
+		{models.RoleOwner, models.RoleOwner, false},
+	}
+	for _, tt := range tests {
+		if got := orgRoleAssignable(tt.actor, tt.role); got != tt.want {
+			t.Errorf("orgRoleAssignable(%q, %q) = %v, want %v", tt.actor, tt.role, got, tt.want)
+		}
+	}
+}
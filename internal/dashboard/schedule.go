@@ -0,0 +1,95 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/schedule"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// ScheduleRequest sets the time windows of a domain. An empty Schedule
+// makes the domain reachable at all times.
+type ScheduleRequest struct {
+	Domain   string `json:"domain"`
+	Schedule string `json:"schedule"`
+	Timezone string `json:"timezone"`
+}
+
+// normalizeSchedule validates a schedule request and returns the values
+// to store.
+func normalizeSchedule(req ScheduleRequest) (spec, tz string, err error) {
+	spec = strings.TrimSpace(req.Schedule)
+	if spec == "" {
+		return "", "", nil
+	}
+	tz = strings.TrimSpace(req.Timezone)
+	if _, err := schedule.Parse(spec, tz); err != nil {
+		return "", "", err
+	}
+	return spec, tz, nil
+}
+
+// SetDomainSchedule restricts one of the user's domains to time windows.
+// Outside them the ingress serves the offline page.
+func (h *Handler) SetDomainSchedule(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req ScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Domain == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+	spec, tz, err := normalizeSchedule(req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	name := strings.ToLower(strings.TrimSpace(req.Domain))
+	if h.Domain != "" {
+		name = strings.TrimSuffix(name, "."+h.Domain)
+	}
+
+	err = storage.SetDomainSchedule(user.ID, name, spec, tz)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Domain not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to set schedule of %s for user %d", name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update domain"})
+		return
+	}
+	log.Printf("User %d set schedule of %s to %q (%s)", user.ID, name, spec, tz)
+
+	if h.Events != nil {
+		host := name
+		if h.Domain != "" {
+			host = name + "." + h.Domain
+		}
+		event := pubsub.Event{Type: pubsub.EventDomainScheduled, Domain: host}
+		if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+			// Instances pick up the schedule on restart
+			log.Printf("Failed to publish schedule of %s: %v", host, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,25 @@
+package dashboard
+
+import (
+	"errors"
+	"testing"
+
+	"gopublic/internal/schedule"
+)
+
+func TestNormalizeSchedule(t *testing.T) {
+	spec, tz, err := normalizeSchedule(ScheduleRequest{Schedule: " mon-fri 09:00-18:00 ", Timezone: " Europe/Moscow "})
+	if err != nil || spec != "mon-fri 09:00-18:00" || tz != "Europe/Moscow" {
+		t.Errorf("normalizeSchedule() = %q, %q, %v", spec, tz, err)
+	}
+
+	// Clearing the schedule drops the time zone too
+	spec, tz, err = normalizeSchedule(ScheduleRequest{Schedule: "  ", Timezone: "Europe/Moscow"})
+	if err != nil || spec != "" || tz != "" {
+		t.Errorf("normalizeSchedule(empty) = %q, %q, %v", spec, tz, err)
+	}
+
+	if _, _, err := normalizeSchedule(ScheduleRequest{Schedule: "weekdays"}); !errors.Is(err, schedule.ErrInvalid) {
+		t.Errorf("normalizeSchedule(invalid) = %v, want ErrInvalid", err)
+	}
+}
@@ -0,0 +1,224 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+	"gopublic/internal/version"
+)
+
+// generateDomainNames picks the subdomains assigned to a new account.
+func (h *Handler) generateDomainNames() []string {
+	prefixes := []string{"misty", "silent", "bold", "rapid", "cool"}
+	suffixes := []string{"river", "star", "eagle", "bear", "fox"}
+	var domains []string
+	for i := 0; i < h.DomainsPerUser; i++ {
+		name := fmt.Sprintf("%s-%s-%d", prefixes[i%len(prefixes)], suffixes[i%len(suffixes)], time.Now().Unix()%1000+int64(i))
+		domains = append(domains, name)
+	}
+	return domains
+}
+
+// registerUser creates the account for a first-time login. A valid invite
+// code from the login cookie skips approval; in invite-only mode signups
+// without one are refused. When signup approval is required the account is
+// created pending, without a token or domains, and the administrator is
+// notified.
+func (h *Handler) registerUser(c *gin.Context, user *models.User) (*models.User, error) {
+	if code := h.takeInvite(c); code != "" {
+		createdUser, _, err := storage.CreateUserWithTokenAndDomains(storage.UserRegistration{
+			User:       user,
+			Domains:    h.generateDomainNames(),
+			InviteCode: code,
+		})
+		return createdUser, err
+	}
+	if h.InviteOnly {
+		return nil, ErrInviteRequired
+	}
+
+	if h.RequireSignupApproval {
+		user.Status = models.UserStatusPending
+		if err := storage.CreateUser(user); err != nil {
+			return nil, err
+		}
+		h.notifyAdmin(fmt.Sprintf("🙋 *Новая заявка на регистрацию*\n\n*Пользователь:* %s", displayName(user)))
+		return user, nil
+	}
+
+	createdUser, _, err := storage.CreateUserWithTokenAndDomains(storage.UserRegistration{
+		User:    user,
+		Domains: h.generateDomainNames(),
+	})
+	return createdUser, err
+}
+
+// displayName returns a human-readable name for admin pages and messages.
+func displayName(user *models.User) string {
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if user.Username != "" {
+		if name == "" {
+			return "@" + user.Username
+		}
+		name += " (@" + user.Username + ")"
+	}
+	if name == "" {
+		name = user.Email
+	}
+	return name
+}
+
+// notifyAdmin sends a Markdown message to the admin Telegram chat.
+func (h *Handler) notifyAdmin(message string) {
+	h.sendTelegramMessage(h.AdminTelegramID, message)
+}
+
+// sendTelegramMessage sends a Markdown message through the bot in the background.
+func (h *Handler) sendTelegramMessage(chatID int64, message string) {
+	if chatID == 0 || h.BotToken == "" {
+		return
+	}
+
+	url := fmt.Sprintf("https://api.telegram.org/bot%s/sendMessage", h.BotToken)
+	payload := map[string]interface{}{
+		"chat_id":    chatID,
+		"text":       message,
+		"parse_mode": "Markdown",
+	}
+
+	go func() {
+		jsonData, _ := json.Marshal(payload)
+		resp, err := http.Post(url, "application/json", bytes.NewBuffer(jsonData))
+		if err != nil {
+			log.Printf("Failed to send Telegram notification: %v", err)
+			return
+		}
+		defer resp.Body.Close()
+	}()
+}
+
+// PendingSignupRow is a single entry of the admin signup queue.
+type PendingSignupRow struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Name      string    `json:"name"`
+	Email     string    `json:"email,omitempty"`
+	Provider  string    `json:"provider"` // telegram or yandex
+}
+
+// Signups renders the admin queue of accounts awaiting approval.
+func (h *Handler) Signups(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	if !h.isAdmin(user) {
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+		return
+	}
+
+	c.HTML(http.StatusOK, "signups.html", gin.H{
+		"User":       user,
+		"IsAdmin":    true,
+		"GitHubRepo": h.GitHubRepo,
+		"Version":    version.Version,
+	})
+}
+
+// SignupsAPI returns accounts awaiting approval.
+func (h *Handler) SignupsAPI(c *gin.Context) {
+	if !h.requireAdminAPI(c) {
+		return
+	}
+
+	users, err := storage.GetPendingUsers()
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to load pending signups")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load signups"})
+		return
+	}
+
+	rows := make([]PendingSignupRow, 0, len(users))
+	for i := range users {
+		u := &users[i]
+		provider := "telegram"
+		if u.TelegramID == nil {
+			provider = "yandex"
+		}
+		rows = append(rows, PendingSignupRow{
+			ID:        u.ID,
+			CreatedAt: u.CreatedAt,
+			Name:      displayName(u),
+			Email:     u.Email,
+			Provider:  provider,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"signups": rows})
+}
+
+// SignupRequest identifies a pending account.
+type SignupRequest struct {
+	ID uint `json:"id"`
+}
+
+// ApproveSignup activates a pending account and assigns its token and domains.
+func (h *Handler) ApproveSignup(c *gin.Context) {
+	h.decideSignup(c, true)
+}
+
+// RejectSignup deletes a pending account.
+func (h *Handler) RejectSignup(c *gin.Context) {
+	h.decideSignup(c, false)
+}
+
+func (h *Handler) decideSignup(c *gin.Context, approve bool) {
+	if !checkCSRF(c) || !h.requireAdminAPI(c) {
+		return
+	}
+
+	var req SignupRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	user, err := storage.GetUserByID(req.ID)
+	if err == nil && user.Status != models.UserStatusPending {
+		err = storage.ErrNotFound
+	}
+	if err == nil {
+		if approve {
+			_, err = storage.ApproveUser(user.ID, h.generateDomainNames())
+		} else {
+			err = storage.RejectUser(user.ID)
+		}
+	}
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Signup not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to decide signup %d", req.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update signup"})
+		return
+	}
+
+	log.Printf("Signup of user %d approved=%v", user.ID, approve)
+	if approve && user.TelegramID != nil {
+		h.sendTelegramMessage(*user.TelegramID, "✅ Ваша заявка на регистрацию одобрена. Войдите в панель управления, чтобы получить токен.")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
@@ -0,0 +1,39 @@
+package dashboard
+
+import (
+	"testing"
+
+	"gopublic/internal/models"
+)
+
+func TestDisplayName(t *testing.T) {
+	tests := []struct {
+		user models.User
+		want string
+	}{
+		{models.User{FirstName: "Ivan", LastName: "Petrov", Username: "ivan"}, "Ivan Petrov (@ivan)"},
+		{models.User{Username: "ivan"}, "@ivan"},
+		{models.User{FirstName: "Ivan"}, "Ivan"},
+		{models.User{Email: "ivan@example.com"}, "ivan@example.com"},
+	}
+	for _, tt := range tests {
+		if got := displayName(&tt.user); got != tt.want {
+			t.Errorf("displayName(%+v) = %q, want %q", tt.user, got, tt.want)
+		}
+	}
+}
+
+func TestGenerateDomainNames(t *testing.T) {
+	h := &Handler{DomainsPerUser: 3}
+	domains := h.generateDomainNames()
+	if len(domains) != 3 {
+		t.Fatalf("got %d domains, want 3", len(domains))
+	}
+	seen := map[string]bool{}
+	for _, d := range domains {
+		if seen[d] {
+			t.Errorf("duplicate domain %q", d)
+		}
+		seen[d] = true
+	}
+}
@@ -0,0 +1,209 @@
+package dashboard
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/auth"
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// SSO cookies carrying the OAuth state and the OIDC nonce through the
+// round-trip to the identity provider.
+const (
+	ssoStateCookie = "sso_state"
+	ssoNonceCookie = "sso_nonce"
+)
+
+// ssoRole maps the identity provider groups of a user to a dashboard role.
+// Without a mapping every user of the provider may sign in as a member;
+// with one, users get the highest role of their groups and users in no
+// mapped group are refused.
+func (h *Handler) ssoRole(groups []string) (string, bool) {
+	if len(h.SSOGroupRoles) == 0 {
+		return models.RoleMember, true
+	}
+	role := ""
+	for _, group := range groups {
+		if mapped, ok := h.SSOGroupRoles[group]; ok && (role == "" || roleRank(mapped) > roleRank(role)) {
+			role = mapped
+		}
+	}
+	return role, role != ""
+}
+
+// getSSORedirectURL returns the OIDC redirect URL based on domain
+func (h *Handler) getSSORedirectURL() string {
+	if h.Domain == "localhost" || h.Domain == "127.0.0.1" {
+		return fmt.Sprintf("http://%s/auth/sso/callback", h.Domain)
+	}
+	return fmt.Sprintf("https://app.%s/auth/sso/callback", h.Domain)
+}
+
+// setSSOCookie stores a value for the SSO callback, or clears it with an
+// empty value.
+func (h *Handler) setSSOCookie(c *gin.Context, name, value string) {
+	maxAge := 600 // 10 minutes
+	if value == "" {
+		maxAge = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   h.Domain != "localhost" && h.Domain != "127.0.0.1",
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// SSOAuth initiates the corporate SSO login
+func (h *Handler) SSOAuth(c *gin.Context) {
+	if h.SSO == nil {
+		c.String(http.StatusNotFound, "SSO not configured")
+		return
+	}
+
+	state, nonce := generateState(), generateState()
+	authURL, err := h.SSO.AuthURL(c.Request.Context(), h.getSSORedirectURL(), state, nonce)
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "SSO discovery failed")
+		c.String(http.StatusBadGateway, "Identity provider unavailable")
+		return
+	}
+	h.setSSOCookie(c, ssoStateCookie, state)
+	h.setSSOCookie(c, ssoNonceCookie, nonce)
+	c.Redirect(http.StatusTemporaryRedirect, authURL)
+}
+
+// SSOCallback handles the redirect back from the identity provider. Group
+// membership is the authorization: SSO signups skip invites and approval.
+func (h *Handler) SSOCallback(c *gin.Context) {
+	if h.SSO == nil {
+		c.String(http.StatusNotFound, "SSO not configured")
+		return
+	}
+
+	stateCookie, err := c.Cookie(ssoStateCookie)
+	if err != nil {
+		c.String(http.StatusBadRequest, "Missing state cookie")
+		return
+	}
+	nonce, _ := c.Cookie(ssoNonceCookie)
+	state := c.Query("state")
+	if state == "" || state != stateCookie {
+		c.String(http.StatusBadRequest, "Invalid state parameter")
+		return
+	}
+	h.setSSOCookie(c, ssoStateCookie, "")
+	h.setSSOCookie(c, ssoNonceCookie, "")
+
+	if errMsg := c.Query("error"); errMsg != "" {
+		log.Printf("SSO error: %s - %s", errMsg, c.Query("error_description"))
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	code := c.Query("code")
+	if code == "" {
+		c.String(http.StatusBadRequest, "Missing authorization code")
+		return
+	}
+
+	identity, err := h.SSO.Exchange(c.Request.Context(), code, h.getSSORedirectURL(), nonce)
+	if errors.Is(err, auth.ErrOIDCRejected) {
+		log.Printf("SSO login rejected: %v", err)
+		c.String(http.StatusForbidden, "Вход отклонён провайдером SSO")
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "SSO code exchange failed")
+		c.String(http.StatusInternalServerError, "Failed to authenticate with SSO")
+		return
+	}
+
+	role, ok := h.ssoRole(identity.Groups)
+	if !ok {
+		log.Printf("SSO login refused for %s: no mapped group in %v", identity.Subject, identity.Groups)
+		c.String(http.StatusForbidden, "Доступ запрещён: ваша группа не допущена к панели управления")
+		return
+	}
+
+	user, err := storage.GetUserByOIDCSubject(identity.Subject)
+	if err != nil && !errors.Is(err, storage.ErrNotFound) {
+		sentry.CaptureErrorWithContext(c, err, "Database error looking up SSO user")
+		c.String(http.StatusInternalServerError, "Database error")
+		return
+	}
+
+	// Check if user is already logged in (linking account). The role of
+	// the account stays as it is: linking must not grant the group's role.
+	if existingUser, err := h.getUserFromSession(c); err == nil {
+		if user != nil && user.ID != existingUser.ID {
+			c.String(http.StatusConflict, "This SSO account is already linked to another user")
+			return
+		}
+		subject := identity.Subject
+		existingUser.OIDCSubject = &subject
+		if err := storage.UpdateUser(existingUser); err != nil {
+			sentry.CaptureErrorWithContext(c, err, "Failed to link SSO account")
+			c.String(http.StatusInternalServerError, "Failed to link SSO account")
+			return
+		}
+		log.Printf("User %d linked SSO account %s", existingUser.ID, identity.Subject)
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+		return
+	}
+
+	if user == nil {
+		subject := identity.Subject
+		createdUser, _, err := storage.CreateUserWithTokenAndDomains(storage.UserRegistration{
+			User: &models.User{
+				OIDCSubject: &subject,
+				Email:       identity.Email,
+				FirstName:   identity.GivenName,
+				LastName:    identity.FamilyName,
+				Username:    identity.Username,
+				PhotoURL:    identity.Picture,
+				Role:        role,
+			},
+			Domains: h.generateDomainNames(),
+		})
+		if err != nil {
+			sentry.CaptureErrorWithContext(c, err, "Failed to create user via SSO")
+			c.String(http.StatusInternalServerError, "Failed to create user account")
+			return
+		}
+		user = createdUser
+	} else {
+		// The provider is the source of truth for the profile and the role
+		user.FirstName = identity.GivenName
+		user.LastName = identity.FamilyName
+		user.Username = identity.Username
+		user.Role = role
+		if identity.Email != "" {
+			user.Email = identity.Email
+		}
+		if identity.Picture != "" {
+			user.PhotoURL = identity.Picture
+		}
+		if err := storage.UpdateUser(user); err != nil {
+			sentry.CaptureErrorWithContext(c, err, "Failed to update SSO user")
+			c.String(http.StatusInternalServerError, "Failed to update user")
+			return
+		}
+	}
+
+	if err := h.Session.SetSession(c.Writer, user.ID); err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to set session after SSO login")
+		c.String(http.StatusInternalServerError, "Failed to create session")
+		return
+	}
+
+	c.Redirect(http.StatusTemporaryRedirect, "/")
+}
@@ -0,0 +1,43 @@
+package dashboard
+
+import (
+	"testing"
+
+	"gopublic/internal/models"
+)
+
+func TestSSORole(t *testing.T) {
+	open := &Handler{}
+	if role, ok := open.ssoRole(nil); !ok || role != models.RoleMember {
+		t.Errorf("without a mapping ssoRole() = %q, %v, want a member", role, ok)
+	}
+
+	h := &Handler{SSOGroupRoles: map[string]string{"gopublic-admins": models.RoleAdmin, "eng": models.RoleMember, "support": models.RoleViewer}}
+	tests := []struct {
+		groups []string
+		role   string
+		ok     bool
+	}{
+		{[]string{"eng"}, models.RoleMember, true},
+		{[]string{"support"}, models.RoleViewer, true},
+		{[]string{"support", "eng"}, models.RoleMember, true},
+		{[]string{"eng", "gopublic-admins"}, models.RoleAdmin, true},
+		{[]string{"sales"}, "", false},
+		{nil, "", false},
+	}
+	for _, tt := range tests {
+		if role, ok := h.ssoRole(tt.groups); role != tt.role || ok != tt.ok {
+			t.Errorf("ssoRole(%v) = %q, %v, want %q, %v", tt.groups, role, ok, tt.role, tt.ok)
+		}
+	}
+}
+
+func TestIsAdmin_SSORole(t *testing.T) {
+	h := &Handler{}
+	if !h.isAdmin(&models.User{Role: models.RoleAdmin}) {
+		t.Error("isAdmin() = false for an SSO admin")
+	}
+	if h.isAdmin(&models.User{Role: models.RoleMember}) {
+		t.Error("isAdmin() = true for an SSO member")
+	}
+}
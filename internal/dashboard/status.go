@@ -0,0 +1,211 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/errorpage"
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+	"gopublic/internal/version"
+)
+
+// statusHistoryDays is the number of days of uptime shown on status pages.
+const statusHistoryDays = 30
+
+// statusSlugPattern matches status page names: 3-32 lowercase letters,
+// digits and inner hyphens.
+var statusSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,30}[a-z0-9]$`)
+
+var errStatusSlug = errors.New("name must be 3-32 lowercase letters, digits or hyphens")
+
+// StatusDay is the uptime of a tunnel on one day.
+type StatusDay struct {
+	Date    string   `json:"date"`    // YYYY-MM-DD
+	Percent *float64 `json:"percent"` // nil if the tunnel was never seen that day
+}
+
+// StatusTunnel is a tunnel listed on a status page.
+type StatusTunnel struct {
+	Domain string      `json:"domain"`
+	Online bool        `json:"online"`
+	Uptime *float64    `json:"uptime"` // Over the days with data, nil if none
+	Days   []StatusDay `json:"days"`   // Oldest first, today last
+}
+
+// StatusPageRequest publishes the user's status page. An empty Slug
+// takes the page down.
+type StatusPageRequest struct {
+	Slug    string   `json:"slug"`
+	Domains []string `json:"domains"` // Domain names or FQDNs to list
+}
+
+// buildStatusTunnels combines the live state of the tunnels with their
+// uptime history. Today's uptime is relative to the time elapsed so far.
+func buildStatusTunnels(fqdns []string, online map[string]bool, uptime []models.DomainUptime, now time.Time) []StatusTunnel {
+	today := now.UTC().Truncate(24 * time.Hour)
+	elapsedToday := now.UTC().Sub(today).Seconds()
+
+	seconds := make(map[string]map[string]int64, len(fqdns))
+	for _, u := range uptime {
+		if seconds[u.Domain] == nil {
+			seconds[u.Domain] = make(map[string]int64)
+		}
+		seconds[u.Domain][u.Date.UTC().Format("2006-01-02")] += u.Seconds
+	}
+
+	tunnels := make([]StatusTunnel, 0, len(fqdns))
+	for _, fqdn := range fqdns {
+		t := StatusTunnel{Domain: fqdn, Online: online[fqdn]}
+		var total, span float64
+		for i := statusHistoryDays - 1; i >= 0; i-- {
+			day := today.AddDate(0, 0, -i)
+			key := day.Format("2006-01-02")
+			entry := StatusDay{Date: key}
+			if s, ok := seconds[fqdn][key]; ok {
+				length := 86400.0
+				if i == 0 {
+					length = max(elapsedToday, 1)
+				}
+				pct := min(float64(s)/length*100, 100)
+				entry.Percent = &pct
+				total += min(float64(s), length)
+				span += length
+			}
+			t.Days = append(t.Days, entry)
+		}
+		if span > 0 {
+			pct := total / span * 100
+			t.Uptime = &pct
+		}
+		tunnels = append(tunnels, t)
+	}
+	return tunnels
+}
+
+// StatusPage renders a user's public status page. It needs no session.
+func (h *Handler) StatusPage(c *gin.Context, slug string) {
+	if !h.StatusPages {
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
+		return
+	}
+	user, err := storage.GetUserByStatusSlug(strings.ToLower(slug))
+	if errors.Is(err, storage.ErrNotFound) || (err == nil && user.Status == models.UserStatusPending) {
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to load status page %q", slug)
+		errorpage.Render(c, http.StatusInternalServerError, errorpage.CodeInternal)
+		return
+	}
+
+	domains, err := storage.GetUserDomains(user.ID)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to fetch domains for user %d", user.ID)
+		errorpage.Render(c, http.StatusInternalServerError, errorpage.CodeInternal)
+		return
+	}
+	var fqdns []string
+	for _, d := range domains {
+		if d.OnStatusPage && d.SuspendedAt == nil {
+			fqdns = append(fqdns, h.alertDomain(d.Name))
+		}
+	}
+
+	online := make(map[string]bool)
+	if h.UserSessions != nil {
+		for _, name := range h.UserSessions.GetActiveDomains(user.ID) {
+			online[name] = true
+		}
+	}
+	now := time.Now()
+	uptime, err := storage.GetDomainUptime(fqdns, now.AddDate(0, 0, -(statusHistoryDays-1)))
+	if err != nil {
+		// The live state is still worth showing
+		log.Printf("Failed to load uptime for status page %q: %v", slug, err)
+	}
+
+	c.Header("Cache-Control", "public, max-age=60")
+	c.HTML(http.StatusOK, "status.html", gin.H{
+		"Slug":       *user.StatusSlug,
+		"Tunnels":    buildStatusTunnels(fqdns, online, uptime, now),
+		"Days":       statusHistoryDays,
+		"UpdatedAt":  now.UTC().Format("2006-01-02 15:04 UTC"),
+		"GitHubRepo": h.GitHubRepo,
+		"Version":    version.Version,
+	})
+}
+
+// statusPageURL returns the public address of a status page.
+func (h *Handler) statusPageURL(slug string) string {
+	if h.Domain == "" {
+		return ""
+	}
+	return "https://status." + h.Domain + "/u/" + slug
+}
+
+// UpdateStatusPage publishes, changes or takes down the user's status page.
+func (h *Handler) UpdateStatusPage(c *gin.Context) {
+	if !h.StatusPages {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Status pages are not enabled"})
+		return
+	}
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req StatusPageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	var slug *string
+	names := make([]string, 0, len(req.Domains))
+	if s := strings.ToLower(strings.TrimSpace(req.Slug)); s != "" {
+		if !statusSlugPattern.MatchString(s) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": errStatusSlug.Error()})
+			return
+		}
+		slug = &s
+		for _, d := range req.Domains {
+			name := strings.ToLower(strings.TrimSpace(d))
+			if h.Domain != "" {
+				name = strings.TrimSuffix(name, "."+h.Domain)
+			}
+			names = append(names, name)
+		}
+	}
+
+	err = storage.SetStatusPage(user.ID, slug, names)
+	if errors.Is(err, storage.ErrDuplicateKey) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This name is already taken"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to update status page for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update status page"})
+		return
+	}
+
+	if slug == nil {
+		c.JSON(http.StatusOK, gin.H{"success": true})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "url": h.statusPageURL(*slug)})
+}
@@ -0,0 +1,63 @@
+package dashboard
+
+import (
+	"testing"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+func TestStatusSlugPattern(t *testing.T) {
+	valid := []string{"acme", "my-company", "a1b", "0123456789abcdef0123456789abcdef"}
+	invalid := []string{"", "ab", "-acme", "acme-", "Acme", "my_company", "a.b.c", "0123456789abcdef0123456789abcdef0"}
+	for _, s := range valid {
+		if !statusSlugPattern.MatchString(s) {
+			t.Errorf("%q should be a valid status page name", s)
+		}
+	}
+	for _, s := range invalid {
+		if statusSlugPattern.MatchString(s) {
+			t.Errorf("%q should not be a valid status page name", s)
+		}
+	}
+}
+
+func TestBuildStatusTunnels(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	today := now.Truncate(24 * time.Hour)
+	uptime := []models.DomainUptime{
+		{Domain: "a.example.com", Date: today.AddDate(0, 0, -1), Seconds: 86400},
+		{Domain: "a.example.com", Date: today, Seconds: 6 * 3600},
+		{Domain: "gone.example.com", Date: today, Seconds: 100},
+	}
+
+	tunnels := buildStatusTunnels([]string{"a.example.com", "b.example.com"},
+		map[string]bool{"a.example.com": true}, uptime, now)
+	if len(tunnels) != 2 {
+		t.Fatalf("got %d tunnels, want 2", len(tunnels))
+	}
+
+	a := tunnels[0]
+	if !a.Online || len(a.Days) != statusHistoryDays {
+		t.Fatalf("unexpected tunnel %+v", a)
+	}
+	if a.Days[0].Percent != nil || a.Days[0].Date != "2026-09-17" {
+		t.Errorf("oldest day = %+v, want 2026-09-17 without data", a.Days[0])
+	}
+	if p := a.Days[statusHistoryDays-2].Percent; p == nil || *p != 100 {
+		t.Errorf("yesterday = %v, want 100", p)
+	}
+	// 6 of the 12 hours elapsed today
+	if p := a.Days[statusHistoryDays-1].Percent; p == nil || *p != 50 {
+		t.Errorf("today = %v, want 50", p)
+	}
+	// 30h online out of 36h with data
+	if a.Uptime == nil || *a.Uptime < 83.3 || *a.Uptime > 83.4 {
+		t.Errorf("uptime = %v, want ~83.33", a.Uptime)
+	}
+
+	b := tunnels[1]
+	if b.Online || b.Uptime != nil {
+		t.Errorf("tunnel without history = %+v", b)
+	}
+}
@@ -0,0 +1,173 @@
+package dashboard
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+)
+
+// domainHost returns the FQDN of a stored domain name.
+func (h *Handler) domainHost(name string) string {
+	if h.Domain == "" {
+		return name
+	}
+	return name + "." + h.Domain
+}
+
+// TransferDomain offers one of the user's reserved domains to another
+// user, found by Telegram username or email. The domain stays with its
+// owner until the recipient accepts.
+func (h *Handler) TransferDomain(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req struct {
+		Domain string `json:"domain"`
+		To     string `json:"to"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || req.Domain == "" || req.To == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	recipient, err := storage.GetUserByHandle(req.To)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to look up transfer recipient for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer domain"})
+		return
+	}
+	if recipient.ID == user.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The domain is already yours"})
+		return
+	}
+	if recipient.Status == models.UserStatusPending {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Recipient account is pending approval"})
+		return
+	}
+
+	name := h.subdomainName(req.Domain)
+	transfer, err := storage.CreateDomainTransfer(user.ID, name, recipient.ID)
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Only your reserved, active domains can be transferred"})
+		return
+	case errors.Is(err, storage.ErrTransferPending):
+		c.JSON(http.StatusConflict, gin.H{"error": "The domain already has a pending transfer"})
+		return
+	case err != nil:
+		sentry.CaptureErrorWithContextf(c, err, "Failed to transfer domain %s of user %d", name, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to transfer domain"})
+		return
+	}
+	log.Printf("User %d offered domain %s to user %d (transfer %d)", user.ID, name, recipient.ID, transfer.ID)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "id": transfer.ID})
+}
+
+// transferRequest is the body of the accept and decline endpoints.
+type transferRequest struct {
+	ID uint `json:"id"`
+}
+
+// AcceptDomainTransfer moves an offered domain to the user, within the
+// reserved domain limit of their plan. The previous owner's tunnel stops
+// serving it right away.
+func (h *Handler) AcceptDomainTransfer(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+	if user.Status == models.UserStatusPending {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	}
+
+	var req transferRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	transfer, err := storage.AcceptDomainTransfer(req.ID, user.ID, h.reservedDomainLimit(user))
+	switch {
+	case errors.Is(err, storage.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found or no longer valid"})
+		return
+	case errors.Is(err, storage.ErrReservationLimit):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Reserved domain limit of your plan reached"})
+		return
+	case errors.Is(err, storage.ErrUserPending):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Account is pending approval"})
+		return
+	case err != nil:
+		sentry.CaptureErrorWithContextf(c, err, "Failed to accept transfer %d for user %d", req.ID, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept transfer"})
+		return
+	}
+	log.Printf("User %d accepted domain %s from user %d (transfer %d)", user.ID, transfer.DomainName, transfer.FromUserID, transfer.ID)
+
+	// Unbind the domain from the previous owner's session; the new owner's
+	// client binds it on its next attempt
+	if h.Events != nil {
+		host := h.domainHost(transfer.DomainName)
+		event := pubsub.Event{Type: pubsub.EventDomainRevoked, Domain: host, Reason: "domain transferred"}
+		if err := h.Events.Publish(c.Request.Context(), event); err != nil {
+			log.Printf("Failed to publish transfer of %s: %v", host, err)
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "domain": transfer.DomainName})
+}
+
+// DeclineDomainTransfer ends a pending transfer: the recipient declines it
+// or the owner cancels it.
+func (h *Handler) DeclineDomainTransfer(c *gin.Context) {
+	if !checkCSRF(c) {
+		return
+	}
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	var req transferRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return
+	}
+
+	transfer, err := storage.ResolveDomainTransfer(req.ID, user.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Transfer not found"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to resolve transfer %d for user %d", req.ID, user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer"})
+		return
+	}
+	log.Printf("User %d %s transfer %d of domain %s", user.ID, transfer.Status, transfer.ID, transfer.DomainName)
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "status": transfer.Status})
+}
@@ -0,0 +1,18 @@
+package dashboard
+
+import "testing"
+
+func TestDomainHost(t *testing.T) {
+	if got := (&Handler{Domain: "example.com"}).domainHost("my-app"); got != "my-app.example.com" {
+		t.Errorf("domainHost() = %q, want %q", got, "my-app.example.com")
+	}
+	if got := (&Handler{}).domainHost("my-app"); got != "my-app" {
+		t.Errorf("domainHost() without root domain = %q, want %q", got, "my-app")
+	}
+}
+
+func TestActionLabels_Transfer(t *testing.T) {
+	if actionLabels[ActionTransferDomain] == "" {
+		t.Error("ActionTransferDomain has no confirmation label")
+	}
+}
@@ -0,0 +1,213 @@
+package dashboard
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/metrics"
+	"gopublic/internal/models"
+	"gopublic/internal/sentry"
+	"gopublic/internal/storage"
+	"gopublic/internal/version"
+)
+
+// Tunnel status values used by the tunnel list.
+const (
+	TunnelStatusOnline  = "online"
+	TunnelStatusOffline = "offline"
+)
+
+// TunnelRow is a single entry of the tunnel list.
+type TunnelRow struct {
+	Domain  string                  `json:"domain"`
+	URL     string                  `json:"url"`
+	OwnerID uint                    `json:"owner_id"`
+	Owner   string                  `json:"owner"`
+	Status  string                  `json:"status"`
+	Labels  map[string]string       `json:"labels,omitempty"`
+	Latency *metrics.LatencySummary `json:"latency,omitempty"`
+}
+
+// TunnelFilter narrows down the tunnel list. Empty fields match everything.
+type TunnelFilter struct {
+	Status string            // "online" or "offline"
+	Domain string            // Case-insensitive substring of the domain
+	Owner  string            // Case-insensitive substring of the owner name (admin only)
+	Labels map[string]string // Required labels; an empty value only requires the key
+}
+
+// parseTunnelFilter builds a filter from query parameters.
+// Labels are passed as repeated "label" parameters in "key=value" or "key" form.
+func parseTunnelFilter(query url.Values) TunnelFilter {
+	f := TunnelFilter{
+		Status: strings.ToLower(strings.TrimSpace(query.Get("status"))),
+		Domain: strings.TrimSpace(query.Get("domain")),
+		Owner:  strings.TrimSpace(query.Get("owner")),
+	}
+	for _, raw := range query["label"] {
+		for _, part := range strings.Split(raw, ",") {
+			part = strings.TrimSpace(part)
+			if part == "" {
+				continue
+			}
+			if f.Labels == nil {
+				f.Labels = make(map[string]string)
+			}
+			key, value, _ := strings.Cut(part, "=")
+			f.Labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+		}
+	}
+	return f
+}
+
+// Match reports whether the row satisfies the filter.
+func (f TunnelFilter) Match(row TunnelRow) bool {
+	if f.Status != "" && row.Status != f.Status {
+		return false
+	}
+	if f.Domain != "" && !strings.Contains(strings.ToLower(row.Domain), strings.ToLower(f.Domain)) {
+		return false
+	}
+	if f.Owner != "" && !strings.Contains(strings.ToLower(row.Owner), strings.ToLower(f.Owner)) {
+		return false
+	}
+	for key, want := range f.Labels {
+		got, ok := row.Labels[key]
+		if !ok || (want != "" && got != want) {
+			return false
+		}
+	}
+	return true
+}
+
+// filterTunnels returns the rows matching the filter, preserving order.
+func filterTunnels(rows []TunnelRow, f TunnelFilter) []TunnelRow {
+	result := make([]TunnelRow, 0, len(rows))
+	for _, row := range rows {
+		if f.Match(row) {
+			result = append(result, row)
+		}
+	}
+	return result
+}
+
+// isAdmin reports whether the user is the owner or an admin.
+func (h *Handler) isAdmin(user *models.User) bool {
+	return roleAtLeast(h.role(user), models.RoleAdmin)
+}
+
+// ownerName returns a human-readable name for the domain owner.
+func ownerName(user models.User) string {
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if name != "" {
+		return name
+	}
+	return user.Email
+}
+
+// listTunnels builds the tunnel list visible to the user.
+// Regular users see their own domains, the administrator sees every domain.
+func (h *Handler) listTunnels(user *models.User) ([]TunnelRow, error) {
+	var domains []models.Domain
+	var err error
+	if h.isAdmin(user) {
+		domains, err = storage.GetAllDomains()
+	} else {
+		domains, err = storage.GetUserDomains(user.ID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Session lookups are cached per owner: one user usually owns several domains.
+	active := make(map[uint]map[string]bool)
+	labels := make(map[uint]map[string]string)
+
+	rows := make([]TunnelRow, 0, len(domains))
+	for _, d := range domains {
+		fqdn := d.Name
+		if h.Domain != "" {
+			fqdn = d.Name + "." + h.Domain
+		}
+
+		if _, ok := active[d.UserID]; !ok {
+			set := make(map[string]bool)
+			if h.UserSessions != nil {
+				for _, name := range h.UserSessions.GetActiveDomains(d.UserID) {
+					set[name] = true
+				}
+				labels[d.UserID] = h.UserSessions.GetLabels(d.UserID)
+			}
+			active[d.UserID] = set
+		}
+
+		row := TunnelRow{
+			Domain:  fqdn,
+			URL:     "https://" + fqdn,
+			OwnerID: d.UserID,
+			Status:  TunnelStatusOffline,
+		}
+		if d.UserID == user.ID {
+			row.Owner = ownerName(*user)
+		} else {
+			row.Owner = ownerName(d.User)
+		}
+		if active[d.UserID][fqdn] {
+			row.Status = TunnelStatusOnline
+			row.Labels = labels[d.UserID]
+		}
+		if h.Latency != nil {
+			if summary, ok := h.Latency.Summary(fqdn); ok {
+				row.Latency = &summary
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// Tunnels renders the tunnel list page. The list itself is loaded from TunnelsAPI.
+func (h *Handler) Tunnels(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+
+	c.HTML(http.StatusOK, "tunnels.html", gin.H{
+		"User":       user,
+		"IsAdmin":    h.isAdmin(user),
+		"GitHubRepo": h.GitHubRepo,
+		"Version":    version.Version,
+	})
+}
+
+// TunnelsAPI returns the filtered tunnel list as JSON.
+// Supported query parameters: status, domain, owner (admin only) and label.
+func (h *Handler) TunnelsAPI(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return
+	}
+
+	rows, err := h.listTunnels(user)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to list tunnels for user %d", user.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load tunnels"})
+		return
+	}
+
+	f := parseTunnelFilter(c.Request.URL.Query())
+	if !h.isAdmin(user) {
+		f.Owner = ""
+	}
+
+	c.JSON(http.StatusOK, gin.H{"tunnels": filterTunnels(rows, f)})
+}
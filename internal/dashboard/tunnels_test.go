@@ -0,0 +1,63 @@
+package dashboard
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestParseTunnelFilter(t *testing.T) {
+	query, _ := url.ParseQuery("status=Online&domain=api&label=env=staging,team&label=region=eu")
+	f := parseTunnelFilter(query)
+
+	if f.Status != TunnelStatusOnline {
+		t.Errorf("Status = %q, want %q", f.Status, TunnelStatusOnline)
+	}
+	if f.Domain != "api" {
+		t.Errorf("Domain = %q, want %q", f.Domain, "api")
+	}
+	want := map[string]string{"env": "staging", "team": "", "region": "eu"}
+	if len(f.Labels) != len(want) {
+		t.Fatalf("Labels = %v, want %v", f.Labels, want)
+	}
+	for k, v := range want {
+		if got, ok := f.Labels[k]; !ok || got != v {
+			t.Errorf("Labels[%q] = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestFilterTunnels(t *testing.T) {
+	rows := []TunnelRow{
+		{Domain: "api.example.com", Owner: "@alice", Status: TunnelStatusOnline, Labels: map[string]string{"env": "staging"}},
+		{Domain: "web.example.com", Owner: "@alice", Status: TunnelStatusOnline, Labels: map[string]string{"env": "prod", "team": "web"}},
+		{Domain: "docs.example.com", Owner: "@bob", Status: TunnelStatusOffline},
+	}
+
+	tests := []struct {
+		name   string
+		filter TunnelFilter
+		want   []string
+	}{
+		{"empty filter", TunnelFilter{}, []string{"api.example.com", "web.example.com", "docs.example.com"}},
+		{"status", TunnelFilter{Status: TunnelStatusOffline}, []string{"docs.example.com"}},
+		{"domain substring", TunnelFilter{Domain: "API"}, []string{"api.example.com"}},
+		{"owner", TunnelFilter{Owner: "bob"}, []string{"docs.example.com"}},
+		{"label value", TunnelFilter{Labels: map[string]string{"env": "prod"}}, []string{"web.example.com"}},
+		{"label key only", TunnelFilter{Labels: map[string]string{"env": ""}}, []string{"api.example.com", "web.example.com"}},
+		{"combined", TunnelFilter{Status: TunnelStatusOnline, Labels: map[string]string{"team": ""}}, []string{"web.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := filterTunnels(rows, tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("got %d rows, want %d", len(got), len(tt.want))
+			}
+			for i, row := range got {
+				if row.Domain != tt.want[i] {
+					t.Errorf("row %d = %q, want %q", i, row.Domain, tt.want[i])
+				}
+			}
+		})
+	}
+}
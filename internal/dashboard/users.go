@@ -0,0 +1,243 @@
+package dashboard
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/sentry"
+	"gopublic/internal/server"
+	"gopublic/internal/storage"
+	"gopublic/internal/version"
+)
+
+// errUserBanned is returned by getUserFromSession for banned accounts.
+var errUserBanned = errors.New("account is banned")
+
+// UserRow is a single account of the admin user list.
+type UserRow struct {
+	ID             uint      `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	Name           string    `json:"name"`
+	Email          string    `json:"email,omitempty"`
+	Role           string    `json:"role"`   // Effective role, models.RoleOwner for the instance owner
+	Status         string    `json:"status"` // models.UserStatus*
+	Plan           string    `json:"plan"`
+	BandwidthToday int64     `json:"bandwidth_today"`
+	Clients        int       `json:"clients"` // Connected sessions
+	Tunnels        []string  `json:"tunnels"` // Domains bound right now
+}
+
+// userRows converts accounts for the admin user list. usage holds today's
+// bandwidth and online the connected clients, both by user ID.
+func (h *Handler) userRows(users []models.User, usage map[uint]int64, online map[uint][]server.AgentInfo) []UserRow {
+	rows := make([]UserRow, 0, len(users))
+	for i := range users {
+		u := &users[i]
+		row := UserRow{
+			ID:             u.ID,
+			CreatedAt:      u.CreatedAt,
+			Name:           displayName(u),
+			Email:          u.Email,
+			Role:           h.role(u),
+			Status:         u.Status,
+			Plan:           u.Plan,
+			BandwidthToday: usage[u.ID],
+			Clients:        len(online[u.ID]),
+			Tunnels:        []string{},
+		}
+		if row.Status == "" {
+			row.Status = models.UserStatusActive
+		}
+		for _, a := range online[u.ID] {
+			row.Tunnels = append(row.Tunnels, a.Domains...)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// canModerate reports whether actor may revoke the tokens of or ban target.
+// Nobody moderates themselves or the owner; only the owner moderates admins.
+func (h *Handler) canModerate(actor, target *models.User) bool {
+	if actor.ID == target.ID {
+		return false
+	}
+	switch h.role(target) {
+	case models.RoleOwner:
+		return false
+	case models.RoleAdmin:
+		return h.role(actor) == models.RoleOwner
+	}
+	return true
+}
+
+// disconnectUser closes the user's tunnel sessions on every instance.
+func (h *Handler) disconnectUser(ctx context.Context, userID uint, reason string) {
+	if h.Events == nil {
+		return
+	}
+	event := pubsub.Event{Type: pubsub.EventForceDisconnect, UserID: userID, Reason: reason}
+	if err := h.Events.Publish(ctx, event); err != nil {
+		log.Printf("Failed to disconnect user %d: %v", userID, err)
+	}
+}
+
+// Users renders the admin list of all accounts.
+func (h *Handler) Users(c *gin.Context) {
+	user, err := h.getUserFromSession(c)
+	if err != nil {
+		c.Redirect(http.StatusTemporaryRedirect, "/login")
+		return
+	}
+	if !h.isAdmin(user) {
+		c.Redirect(http.StatusTemporaryRedirect, "/")
+		return
+	}
+
+	c.HTML(http.StatusOK, "users.html", gin.H{
+		"User":       user,
+		"IsAdmin":    true,
+		"IsOwner":    h.role(user) == models.RoleOwner,
+		"GitHubRepo": h.GitHubRepo,
+		"Version":    version.Version,
+	})
+}
+
+// UsersAPI returns all accounts with their usage and connected tunnels.
+func (h *Handler) UsersAPI(c *gin.Context) {
+	if !h.requireAdminAPI(c) {
+		return
+	}
+
+	users, err := storage.GetUsers()
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to load users")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+	usage, err := storage.GetBandwidthToday()
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to load bandwidth usage")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load users"})
+		return
+	}
+	online := make(map[uint][]server.AgentInfo)
+	if h.Fleet != nil {
+		for _, u := range users {
+			if agents := h.Fleet.Agents(u.ID); len(agents) > 0 {
+				online[u.ID] = agents
+			}
+		}
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{"users": h.userRows(users, usage, online)})
+}
+
+// UserRequest identifies an account to moderate.
+type UserRequest struct {
+	ID uint `json:"id"`
+}
+
+// moderationTarget reads the request of a moderation action and loads the
+// account it targets, answering the request itself if that fails.
+func (h *Handler) moderationTarget(c *gin.Context) (actor, target *models.User, ok bool) {
+	if !checkCSRF(c) || !h.requireAdminAPI(c) {
+		return nil, nil, false
+	}
+	actor, err := h.getUserFromSession(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+		return nil, nil, false
+	}
+
+	var req UserRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.ID == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request"})
+		return nil, nil, false
+	}
+	target, err = storage.GetUserByID(req.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return nil, nil, false
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to load user %d", req.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load user"})
+		return nil, nil, false
+	}
+	if !h.canModerate(actor, target) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Forbidden"})
+		return nil, nil, false
+	}
+	return actor, target, true
+}
+
+// RevokeUserTokens deletes all tokens of an account and disconnects its
+// tunnels. The user gets a new token from the dashboard.
+func (h *Handler) RevokeUserTokens(c *gin.Context) {
+	actor, target, ok := h.moderationTarget(c)
+	if !ok {
+		return
+	}
+
+	if err := storage.RevokeUserTokens(target.ID); err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to revoke tokens of user %d", target.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke tokens"})
+		return
+	}
+	log.Printf("User %d revoked the tokens of user %d", actor.ID, target.ID)
+	h.disconnectUser(c.Request.Context(), target.ID, "tokens revoked by an administrator")
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// BanUser bans an active account: its tokens are revoked, its tunnels
+// disconnected and it can no longer sign in.
+func (h *Handler) BanUser(c *gin.Context) {
+	actor, target, ok := h.moderationTarget(c)
+	if !ok {
+		return
+	}
+
+	err := storage.BanUser(target.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only active users can be banned"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to ban user %d", target.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to ban user"})
+		return
+	}
+	log.Printf("User %d banned user %d", actor.ID, target.ID)
+	h.disconnectUser(c.Request.Context(), target.ID, "banned by an administrator")
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// UnbanUser lifts the ban of an account.
+func (h *Handler) UnbanUser(c *gin.Context) {
+	actor, target, ok := h.moderationTarget(c)
+	if !ok {
+		return
+	}
+
+	err := storage.UnbanUser(target.ID)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusConflict, gin.H{"error": "User is not banned"})
+		return
+	} else if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to unban user %d", target.ID)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unban user"})
+		return
+	}
+	log.Printf("User %d lifted the ban of user %d", actor.ID, target.ID)
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
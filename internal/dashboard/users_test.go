@@ -0,0 +1,64 @@
+package dashboard
+
+import (
+	"testing"
+
+	"gorm.io/gorm"
+
+	"gopublic/internal/models"
+	"gopublic/internal/server"
+)
+
+func TestHandler_CanModerate(t *testing.T) {
+	ownerTelegram := int64(42)
+	h := &Handler{AdminTelegramID: ownerTelegram}
+	owner := &models.User{Model: gorm.Model{ID: 1}, TelegramID: &ownerTelegram}
+	admin := &models.User{Model: gorm.Model{ID: 2}, Role: models.RoleAdmin}
+	otherAdmin := &models.User{Model: gorm.Model{ID: 3}, Role: models.RoleAdmin}
+	member := &models.User{Model: gorm.Model{ID: 4}}
+
+	tests := []struct {
+		name          string
+		actor, target *models.User
+		want          bool
+	}{
+		{"admin moderates member", admin, member, true},
+		{"owner moderates admin", owner, admin, true},
+		{"admin cannot moderate admin", admin, otherAdmin, false},
+		{"admin cannot moderate owner", admin, owner, false},
+		{"nobody moderates themselves", admin, admin, false},
+	}
+	for _, tt := range tests {
+		if got := h.canModerate(tt.actor, tt.target); got != tt.want {
+			t.Errorf("%s: canModerate = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestHandler_UserRows(t *testing.T) {
+	h := &Handler{}
+	users := []models.User{
+		{Model: gorm.Model{ID: 1}, Username: "alice", Plan: models.PlanFree},
+		{Model: gorm.Model{ID: 2}, Username: "bob", Status: models.UserStatusBanned},
+	}
+	usage := map[uint]int64{1: 2048}
+	online := map[uint][]server.AgentInfo{
+		1: {{Domains: []string{"a.example.com"}}, {Agent: "ci", Domains: []string{"b.example.com"}}},
+	}
+
+	rows := h.userRows(users, usage, online)
+	if len(rows) != 2 {
+		t.Fatalf("got %d rows, want 2", len(rows))
+	}
+	alice := rows[0]
+	if alice.Name != "@alice" || alice.Role != models.RoleMember || alice.Status != models.UserStatusActive {
+		t.Errorf("alice = %+v, want an active member", alice)
+	}
+	if alice.BandwidthToday != 2048 || alice.Clients != 2 || len(alice.Tunnels) != 2 {
+		t.Errorf("alice = %+v, want 2048 bytes and two clients with a tunnel each", alice)
+	}
+	bob := rows[1]
+	if bob.Status != models.UserStatusBanned || bob.Clients != 0 || bob.Tunnels == nil {
+		t.Errorf("bob = %+v, want banned, offline, empty tunnel list", bob)
+	}
+}
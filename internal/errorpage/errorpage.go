@@ -0,0 +1,163 @@
+// Package errorpage renders ingress error responses.
+//
+// Every error has a stable machine-readable Code. Clients that accept HTML
+// get a localized error page; all other clients get a JSON body:
+//
+//	{"error": "Tunnel is offline", "code": "TUNNEL_NOT_FOUND", "status": 404}
+package errorpage
+
+import (
+	"embed"
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code is a stable machine-readable error identifier.
+type Code string
+
+// Error codes returned by the ingress.
+const (
+	CodeInvalidHost       Code = "INVALID_HOST"
+	CodeBadRequest        Code = "BAD_REQUEST"
+	CodeHeadersTooLarge   Code = "HEADERS_TOO_LARGE"
+	CodeNotFound          Code = "NOT_FOUND"
+	CodeMethodNotAllowed  Code = "METHOD_NOT_ALLOWED"
+	CodeTunnelNotFound    Code = "TUNNEL_NOT_FOUND"
+	CodeTunnelUnavailable Code = "TUNNEL_UNAVAILABLE"
+	CodeTunnelBusy        Code = "TUNNEL_BUSY"
+	CodeTunnelRateLimited Code = "TUNNEL_RATE_LIMITED"
+	CodeTunnelSuspended   Code = "TUNNEL_SUSPENDED"
+	CodeQuotaExceeded     Code = "BANDWIDTH_LIMIT_EXCEEDED"
+	CodeRateLimited       Code = "RATE_LIMIT_EXCEEDED"
+	CodeIPBlocked         Code = "IP_BLOCKED"
+	CodeAuthRequired      Code = "AUTH_REQUIRED"
+	CodeFilterRejected    Code = "FILTER_REJECTED"
+	CodeContentBlocked    Code = "CONTENT_BLOCKED"
+	CodeScanUnavailable   Code = "SCAN_UNAVAILABLE"
+	CodeInternal          Code = "INTERNAL_ERROR"
+)
+
+// CodeHeader carries the error code on every error response, so that
+// clients can identify ingress errors regardless of the body format.
+const CodeHeader = "X-Gopublic-Error"
+
+//go:embed templates/error.html
+var templateFS embed.FS
+
+var pageTemplate = template.Must(template.ParseFS(templateFS, "templates/error.html"))
+
+// Response is the JSON error body.
+type Response struct {
+	Error  string `json:"error"`
+	Code   Code   `json:"code"`
+	Status int    `json:"status"`
+}
+
+// pageData is passed to the HTML template.
+type pageData struct {
+	Lang    string
+	Status  int
+	Code    Code
+	Host    string
+	Title   string
+	Message string
+	Labels  catalog
+}
+
+// Render writes an error response negotiated from the request's Accept and
+// Accept-Language headers. It does not abort the Gin context.
+func Render(c *gin.Context, status int, code Code) {
+	Write(c.Writer, c.Request, status, code)
+}
+
+// Abort renders the error and aborts the remaining handlers.
+func Abort(c *gin.Context, status int, code Code) {
+	Render(c, status, code)
+	c.Abort()
+}
+
+// Write renders an error response to a plain http.ResponseWriter.
+func Write(w http.ResponseWriter, r *http.Request, status int, code Code) {
+	lang := Language(r.Header.Get("Accept-Language"))
+	t := Message(lang, code)
+
+	h := w.Header()
+	h.Set(CodeHeader, string(code))
+	h.Set("Cache-Control", "no-store")
+
+	if !wantsHTML(r.Header.Get("Accept")) {
+		h.Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(Response{Error: t.Title, Code: code, Status: status})
+		return
+	}
+
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Language", lang)
+	w.WriteHeader(status)
+	pageTemplate.Execute(w, pageData{
+		Lang:    lang,
+		Status:  status,
+		Code:    code,
+		Host:    r.Host,
+		Title:   t.Title,
+		Message: t.Message,
+		Labels:  catalogs[lang],
+	})
+}
+
+// Message returns the localized title and explanation for a code.
+// Unknown codes fall back to the internal error text.
+func Message(lang string, code Code) (t Text) {
+	cat, ok := catalogs[lang]
+	if !ok {
+		cat = catalogs[DefaultLanguage]
+	}
+	if t, ok = cat.Errors[code]; ok {
+		return t
+	}
+	return cat.Errors[CodeInternal]
+}
+
+// Language picks the best supported language from an Accept-Language header.
+func Language(header string) string {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+	var candidates []candidate
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		primary, _, _ := strings.Cut(strings.ToLower(tag), "-")
+		candidates = append(candidates, candidate{lang: primary, q: q})
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+	for _, c := range candidates {
+		if _, ok := catalogs[c.lang]; ok && c.q > 0 {
+			return c.lang
+		}
+	}
+	return DefaultLanguage
+}
+
+// wantsHTML reports whether the client prefers an HTML page (browsers).
+func wantsHTML(accept string) bool {
+	return strings.Contains(accept, "text/html")
+}
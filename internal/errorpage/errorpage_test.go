@@ -0,0 +1,86 @@
+package errorpage
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestLanguage(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"ru-RU,ru;q=0.9,en;q=0.8", "ru"},
+		{"en-US,en;q=0.9,ru;q=0.8", "en"},
+		{"de-DE,ru;q=0.5", "ru"},
+		{"fr", "en"},
+		{"en;q=0.2, ru;q=0.7", "ru"},
+		{"ru;q=0", "en"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.header, func(t *testing.T) {
+			if got := Language(tt.header); got != tt.want {
+				t.Errorf("Language(%q) = %q, want %q", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrite_JSON(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "*/*")
+	w := httptest.NewRecorder()
+
+	Write(w, req, http.StatusNotFound, CodeTunnelNotFound)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", w.Code)
+	}
+	if got := w.Header().Get(CodeHeader); got != string(CodeTunnelNotFound) {
+		t.Errorf("%s = %q", CodeHeader, got)
+	}
+
+	var resp Response
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid JSON body: %v", err)
+	}
+	if resp.Code != CodeTunnelNotFound || resp.Status != http.StatusNotFound || resp.Error == "" {
+		t.Errorf("unexpected body: %+v", resp)
+	}
+}
+
+func TestWrite_LocalizedHTML(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Host = "myapp.example.com"
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	req.Header.Set("Accept-Language", "ru-RU,ru;q=0.9")
+	w := httptest.NewRecorder()
+
+	Write(w, req, http.StatusTooManyRequests, CodeQuotaExceeded)
+
+	body := w.Body.String()
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("Content-Type = %q", w.Header().Get("Content-Type"))
+	}
+	for _, want := range []string{`lang="ru"`, "Превышен лимит трафика", string(CodeQuotaExceeded), "myapp.example.com"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("body does not contain %q", want)
+		}
+	}
+}
+
+func TestCatalogsComplete(t *testing.T) {
+	codes := catalogs[DefaultLanguage].Errors
+	for lang, cat := range catalogs {
+		for code := range codes {
+			if _, ok := cat.Errors[code]; !ok {
+				t.Errorf("language %q has no text for %s", lang, code)
+			}
+		}
+	}
+}
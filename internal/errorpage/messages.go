@@ -0,0 +1,75 @@
+package errorpage
+
+// Text holds the localized title and explanation of an error.
+type Text struct {
+	Title   string
+	Message string
+}
+
+// catalog is the set of strings for a single language.
+type catalog struct {
+	Errors map[Code]Text
+
+	// Page chrome
+	ErrorLabel string // Label shown next to the status code
+	CodeLabel  string // Label for the machine-readable code
+	HostLabel  string // Label for the requested host
+}
+
+// DefaultLanguage is used when the client accepts none of the supported languages.
+const DefaultLanguage = "en"
+
+// catalogs maps language codes to their strings. To add a language, add a
+// catalog with a translation for every Code.
+var catalogs = map[string]catalog{
+	"en": {
+		ErrorLabel: "Error",
+		CodeLabel:  "Code",
+		HostLabel:  "Host",
+		Errors: map[Code]Text{
+			CodeInvalidHost:       {"Invalid host", "The Host header of this request is missing or malformed."},
+			CodeBadRequest:        {"Bad request", "The request is malformed and was not forwarded to the tunnel."},
+			CodeHeadersTooLarge:   {"Request headers too large", "The request headers exceed the size the tunnel accepts."},
+			CodeNotFound:          {"Page not found", "The requested page does not exist."},
+			CodeMethodNotAllowed:  {"Method not allowed", "This address does not accept the request method."},
+			CodeTunnelNotFound:    {"Tunnel is offline", "No client is currently connected for this address. If this is your tunnel, start the gopublic client and try again."},
+			CodeTunnelUnavailable: {"Tunnel unavailable", "The tunnel client did not respond. It may have disconnected or the local service may be down."},
+			CodeTunnelBusy:        {"Tunnel busy", "The tunnel is handling too many requests at once. Please try again shortly."},
+			CodeTunnelRateLimited: {"Too many requests", "This tunnel is receiving more requests per second than it accepts. Please try again shortly."},
+			CodeTunnelSuspended:   {"Tunnel suspended", "This tunnel has been taken down for violating the terms of service."},
+			CodeQuotaExceeded:     {"Bandwidth limit exceeded", "The owner of this tunnel has used up today's bandwidth limit. Please try again tomorrow."},
+			CodeRateLimited:       {"Too many requests", "You are sending requests too quickly. Please slow down and try again shortly."},
+			CodeIPBlocked:         {"Access denied", "Requests from your IP address are blocked."},
+			CodeAuthRequired:      {"Login required", "The owner of this tunnel protects it with a username and password."},
+			CodeFilterRejected:    {"Request rejected", "The request or response was rejected by a traffic filter."},
+			CodeContentBlocked:    {"Content blocked", "The uploaded content was rejected by the content scanner."},
+			CodeScanUnavailable:   {"Content scan unavailable", "The uploaded content could not be scanned. Please try again later."},
+			CodeInternal:          {"Internal error", "Something went wrong on our side. Please try again later."},
+		},
+	},
+	"ru": {
+		ErrorLabel: "Ошибка",
+		CodeLabel:  "Код",
+		HostLabel:  "Адрес",
+		Errors: map[Code]Text{
+			CodeInvalidHost:       {"Некорректный адрес", "Заголовок Host в запросе отсутствует или имеет неверный формат."},
+			CodeBadRequest:        {"Некорректный запрос", "Запрос имеет неверный формат и не был передан в туннель."},
+			CodeHeadersTooLarge:   {"Слишком большие заголовки", "Заголовки запроса превышают допустимый для туннеля размер."},
+			CodeNotFound:          {"Страница не найдена", "Запрошенная страница не существует."},
+			CodeMethodNotAllowed:  {"Метод не поддерживается", "Этот адрес не принимает запросы с таким методом."},
+			CodeTunnelNotFound:    {"Туннель не в сети", "Для этого адреса сейчас нет подключённого клиента. Если это ваш туннель, запустите клиент gopublic и повторите попытку."},
+			CodeTunnelUnavailable: {"Туннель недоступен", "Клиент туннеля не ответил. Возможно, он отключился или локальный сервис не запущен."},
+			CodeTunnelBusy:        {"Туннель перегружен", "Туннель обрабатывает слишком много запросов одновременно. Повторите попытку чуть позже."},
+			CodeTunnelRateLimited: {"Слишком много запросов", "Туннель получает больше запросов в секунду, чем принимает. Повторите попытку чуть позже."},
+			CodeTunnelSuspended:   {"Туннель заблокирован", "Этот туннель отключён за нарушение условий использования."},
+			CodeQuotaExceeded:     {"Превышен лимит трафика", "Владелец туннеля исчерпал дневной лимит трафика. Попробуйте завтра."},
+			CodeRateLimited:       {"Слишком много запросов", "Вы отправляете запросы слишком часто. Подождите немного и повторите попытку."},
+			CodeIPBlocked:         {"Доступ запрещён", "Запросы с вашего IP-адреса заблокированы."},
+			CodeAuthRequired:      {"Требуется вход", "Владелец туннеля защитил его логином и паролем."},
+			CodeFilterRejected:    {"Запрос отклонён", "Запрос или ответ отклонён фильтром трафика."},
+			CodeContentBlocked:    {"Содержимое заблокировано", "Загружаемые данные отклонены проверкой содержимого."},
+			CodeScanUnavailable:   {"Проверка содержимого недоступна", "Не удалось проверить загружаемые данные. Попробуйте позже."},
+			CodeInternal:          {"Внутренняя ошибка", "Что-то пошло не так на нашей стороне. Попробуйте позже."},
+		},
+	},
+}
@@ -0,0 +1,78 @@
+package filter
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Filter inspects or rewrites HTTP traffic flowing through a tunnel.
+// Filters are used on both the client (before the request reaches the local
+// service) and the server ingress (before the request enters the tunnel).
+type Filter interface {
+	// Request is called before the request is forwarded.
+	// Returning a non-nil response short-circuits the exchange: the request
+	// is not forwarded and the returned response is sent instead.
+	Request(req *http.Request) (*http.Response, error)
+
+	// Response is called before the response is returned to the caller.
+	Response(resp *http.Response) error
+}
+
+// Chain applies a list of filters in order.
+// A nil or empty chain is a no-op.
+type Chain []Filter
+
+// Request runs request filters in order, stopping at the first filter
+// that returns an error or a short-circuit response.
+func (c Chain) Request(req *http.Request) (*http.Response, error) {
+	for _, f := range c {
+		resp, err := f.Request(req)
+		if err != nil || resp != nil {
+			return resp, err
+		}
+	}
+	return nil, nil
+}
+
+// Response runs response filters in reverse order, so the first filter in
+// the chain sees the response last (like nested middleware).
+func (c Chain) Response(resp *http.Response) error {
+	for i := len(c) - 1; i >= 0; i-- {
+		if err := c[i].Response(resp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases resources held by filters in the chain.
+func (c Chain) Close() error {
+	var firstErr error
+	for _, f := range c {
+		if closer, ok := f.(io.Closer); ok {
+			if err := closer.Close(); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
+// NewResponse builds a plain-text response, for filters that short-circuit requests.
+func NewResponse(req *http.Request, status int, body string) *http.Response {
+	resp := &http.Response{
+		StatusCode:    status,
+		Status:        fmt.Sprintf("%d %s", status, http.StatusText(status)),
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        make(http.Header),
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+	resp.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	return resp
+}
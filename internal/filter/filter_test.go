@@ -0,0 +1,212 @@
+package filter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// headerFilter tags requests and responses with a header.
+type headerFilter struct {
+	name   string
+	order  *[]string
+	block  bool
+	closed bool
+}
+
+func (f *headerFilter) Request(req *http.Request) (*http.Response, error) {
+	*f.order = append(*f.order, "req:"+f.name)
+	if f.block {
+		return NewResponse(req, http.StatusForbidden, "blocked by "+f.name), nil
+	}
+	req.Header.Add("X-Filter", f.name)
+	return nil, nil
+}
+
+func (f *headerFilter) Response(resp *http.Response) error {
+	*f.order = append(*f.order, "resp:"+f.name)
+	return nil
+}
+
+func (f *headerFilter) Close() error {
+	f.closed = true
+	return nil
+}
+
+// fakeRuntime compiles module bytes into a headerFilter named after the contents.
+type fakeRuntime struct {
+	order    *[]string
+	compiled []*headerFilter
+}
+
+func (r *fakeRuntime) Compile(ctx context.Context, code []byte) (Filter, error) {
+	if string(code) == "bad" {
+		return nil, errors.New("invalid module")
+	}
+	f := &headerFilter{name: string(code), order: r.order}
+	r.compiled = append(r.compiled, f)
+	return f, nil
+}
+
+func TestChain_Order(t *testing.T) {
+	var order []string
+	chain := Chain{
+		&headerFilter{name: "a", order: &order},
+		&headerFilter{name: "b", order: &order},
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	resp, err := chain.Request(req)
+	if err != nil || resp != nil {
+		t.Fatalf("Request() = %v, %v; want nil, nil", resp, err)
+	}
+	if err := chain.Response(&http.Response{Header: make(http.Header)}); err != nil {
+		t.Fatalf("Response() error = %v", err)
+	}
+
+	want := []string{"req:a", "req:b", "resp:b", "resp:a"}
+	if len(order) != len(want) {
+		t.Fatalf("order = %v, want %v", order, want)
+	}
+	for i := range want {
+		if order[i] != want[i] {
+			t.Errorf("order[%d] = %s, want %s", i, order[i], want[i])
+		}
+	}
+	if got := req.Header.Values("X-Filter"); len(got) != 2 {
+		t.Errorf("X-Filter = %v, want 2 values", got)
+	}
+}
+
+func TestChain_ShortCircuit(t *testing.T) {
+	var order []string
+	chain := Chain{
+		&headerFilter{name: "a", order: &order, block: true},
+		&headerFilter{name: "b", order: &order},
+	}
+
+	resp, err := chain.Request(httptest.NewRequest("GET", "/", nil))
+	if err != nil {
+		t.Fatalf("Request() error = %v", err)
+	}
+	if resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Fatalf("expected 403 short-circuit response, got %v", resp)
+	}
+	if len(order) != 1 {
+		t.Errorf("filter b should not run, order = %v", order)
+	}
+}
+
+func TestLoadModule_WASM(t *testing.T) {
+	m, err := LoadModule(filepath.Join("testdata", "headers.wasm"))
+	if err != nil {
+		t.Fatalf("LoadModule() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req := httptest.NewRequest("GET", "/orders?page=2", nil)
+			if resp, err := m.Request(req); err != nil || resp != nil {
+				t.Errorf("Request(GET) = %v, %v; want nil, nil", resp, err)
+			}
+			if got := req.Header.Get("X-Filter"); got != "wasm" {
+				t.Errorf("X-Filter = %q, want wasm", got)
+			}
+		}()
+	}
+	wg.Wait()
+
+	resp, err := m.Request(httptest.NewRequest("DELETE", "/orders/1", nil))
+	if err != nil || resp == nil || resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("Request(DELETE) = %v, %v; want a 405 response", resp, err)
+	}
+
+	ok := &http.Response{StatusCode: http.StatusOK, ProtoMajor: 1, ProtoMinor: 1, Header: http.Header{"Server": {"nginx"}}}
+	if err := m.Response(ok); err != nil {
+		t.Fatalf("Response(200) error = %v", err)
+	}
+	if got := ok.Header.Get("Server"); got != "" {
+		t.Errorf("Server = %q, want it removed", got)
+	}
+	failed := &http.Response{StatusCode: http.StatusInternalServerError, ProtoMajor: 1, ProtoMinor: 1, Header: make(http.Header)}
+	if err := m.Response(failed); err == nil {
+		t.Error("Response(500) succeeded, want it rejected")
+	}
+}
+
+func TestLoadModule_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "filter.wasm")
+	if err := os.WriteFile(path, []byte("(module)"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadModule(path); err == nil {
+		t.Error("LoadModule() of a text module succeeded")
+	}
+}
+
+func TestModule_Reload(t *testing.T) {
+	var order []string
+	rt := &fakeRuntime{order: &order}
+	RegisterRuntime(rt)
+	defer RegisterRuntime(nil)
+
+	path := filepath.Join(t.TempDir(), "filter.wasm")
+	if err := os.WriteFile(path, []byte("v1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := LoadModule(path)
+	if err != nil {
+		t.Fatalf("LoadModule() error = %v", err)
+	}
+
+	// Unchanged file is not recompiled
+	if reloaded, err := m.Reload(); err != nil || reloaded {
+		t.Errorf("Reload() = %v, %v; want false, nil", reloaded, err)
+	}
+
+	// Changed file is recompiled and the old version closed
+	if err := os.WriteFile(path, []byte("v2"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Minute)
+	os.Chtimes(path, future, future)
+
+	if reloaded, err := m.Reload(); err != nil || !reloaded {
+		t.Fatalf("Reload() = %v, %v; want true, nil", reloaded, err)
+	}
+	if !rt.compiled[0].closed {
+		t.Error("previous module version should be closed")
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	m.Request(req)
+	if got := req.Header.Get("X-Filter"); got != "v2" {
+		t.Errorf("X-Filter = %q, want v2", got)
+	}
+
+	// Broken update keeps the previous version active
+	if err := os.WriteFile(path, []byte("bad"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := future.Add(time.Minute)
+	os.Chtimes(path, later, later)
+
+	if _, err := m.Reload(); err == nil {
+		t.Error("Reload() should fail for invalid module")
+	}
+	req = httptest.NewRequest("GET", "/", nil)
+	m.Request(req)
+	if got := req.Header.Get("X-Filter"); got != "v2" {
+		t.Errorf("X-Filter = %q, want v2 after failed reload", got)
+	}
+}
@@ -0,0 +1,168 @@
+package filter
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Runtime compiles WASM module bytes into a sandboxed Filter.
+// The compiled filter may implement io.Closer to release runtime resources.
+type Runtime interface {
+	Compile(ctx context.Context, code []byte) (Filter, error)
+}
+
+var (
+	runtimeMu sync.RWMutex
+	runtime   Runtime = Interpreter{}
+)
+
+// RegisterRuntime replaces the runtime used to compile filter modules, e.g.
+// with a JIT. nil restores the built-in Interpreter.
+func RegisterRuntime(r Runtime) {
+	runtimeMu.Lock()
+	defer runtimeMu.Unlock()
+	if r == nil {
+		r = Interpreter{}
+	}
+	runtime = r
+}
+
+func currentRuntime() Runtime {
+	runtimeMu.RLock()
+	defer runtimeMu.RUnlock()
+	return runtime
+}
+
+// Module is a Filter backed by a WASM file on disk.
+// It recompiles the file when its modification time changes, so traffic
+// logic can be updated without restarting the tunnel.
+type Module struct {
+	Path string
+
+	mu      sync.RWMutex
+	current Filter
+	modTime time.Time
+}
+
+// LoadModule reads and compiles the module at path.
+func LoadModule(path string) (*Module, error) {
+	m := &Module{Path: path}
+	if _, err := m.Reload(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LoadChain loads a module for each path, in order.
+func LoadChain(paths []string) (Chain, error) {
+	var chain Chain
+	for _, path := range paths {
+		m, err := LoadModule(path)
+		if err != nil {
+			chain.Close()
+			return nil, err
+		}
+		chain = append(chain, m)
+	}
+	return chain, nil
+}
+
+// Reload recompiles the module if the file changed since the last load.
+// Returns true if a new version was swapped in. On failure the previously
+// loaded version stays active.
+func (m *Module) Reload() (bool, error) {
+	info, err := os.Stat(m.Path)
+	if err != nil {
+		return false, fmt.Errorf("filter %s: %w", m.Path, err)
+	}
+
+	m.mu.RLock()
+	unchanged := m.current != nil && info.ModTime().Equal(m.modTime)
+	m.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	rt := currentRuntime()
+	code, err := os.ReadFile(m.Path)
+	if err != nil {
+		return false, fmt.Errorf("filter %s: %w", m.Path, err)
+	}
+
+	f, err := rt.Compile(context.Background(), code)
+	if err != nil {
+		return false, fmt.Errorf("filter %s: compile: %w", m.Path, err)
+	}
+
+	m.mu.Lock()
+	old := m.current
+	m.current = f
+	m.modTime = info.ModTime()
+	m.mu.Unlock()
+
+	if closer, ok := old.(io.Closer); ok {
+		closer.Close()
+	}
+	return true, nil
+}
+
+// Watch polls the module file and reloads it on change until ctx is cancelled.
+func (m *Module) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := m.Reload()
+			if err != nil {
+				log.Printf("Filter reload failed: %v", err)
+			} else if reloaded {
+				log.Printf("Filter %s reloaded", m.Path)
+			}
+		}
+	}
+}
+
+func (m *Module) filter() Filter {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.current
+}
+
+// Request runs the current version of the module on the request.
+func (m *Module) Request(req *http.Request) (*http.Response, error) {
+	return m.filter().Request(req)
+}
+
+// Response runs the current version of the module on the response.
+func (m *Module) Response(resp *http.Response) error {
+	return m.filter().Response(resp)
+}
+
+// Close releases the compiled module.
+func (m *Module) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if closer, ok := m.current.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// WatchChain starts Watch for every Module in the chain.
+func WatchChain(ctx context.Context, chain Chain, interval time.Duration) {
+	for _, f := range chain {
+		if m, ok := f.(*Module); ok {
+			go m.Watch(ctx, interval)
+		}
+	}
+}
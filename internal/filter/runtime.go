@@ -0,0 +1,263 @@
+package filter
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+
+	"gopublic/internal/filter/wasm"
+)
+
+// Limits applied to every filter instance by the built-in runtime. A call
+// that runs out of fuel fails like any other filter error.
+var DefaultLimits = wasm.Limits{
+	MaxPages: 64, // 4 MB
+	Fuel:     5_000_000,
+}
+
+// Interpreter is the built-in Runtime. It runs modules in the pure-Go
+// interpreter of package wasm, so filters work in every build.
+//
+// A filter module exports its memory, alloc(size i32) i32, and one or both
+// of on_request(ptr, len i32) i32 and on_response(ptr, len i32) i32. The
+// host allocates a buffer with alloc, writes the message head into it
+// ("GET /path HTTP/1.1\r\nHost: ...\r\n" or "HTTP/1.1 200 OK\r\n..."), calls
+// the handler, then free(ptr, len i32) if the module exports it.
+// on_request returns 0 to pass the request on or an HTTP status to answer
+// it with instead; on_response returns 0 to pass the response on, anything
+// else rejects it. Handlers may import from module "gopublic":
+//
+//	set_header(name_ptr, name_len, value_ptr, value_len i32)
+//	del_header(name_ptr, name_len i32)
+//	log(ptr, len i32)
+//
+// which act on the message being filtered.
+type Interpreter struct {
+	Limits wasm.Limits // Zero = DefaultLimits
+}
+
+// Compile checks the module's exports and imports and instantiates it once.
+func (r Interpreter) Compile(ctx context.Context, code []byte) (Filter, error) {
+	m, err := wasm.Compile(code)
+	if err != nil {
+		return nil, err
+	}
+
+	f := &wasmFilter{module: m, limits: r.Limits}
+	if f.limits == (wasm.Limits{}) {
+		f.limits = DefaultLimits
+	}
+	if t, ok := m.ExportedFunc("alloc"); !ok || !sameType(t, []byte{wasm.I32}, []byte{wasm.I32}) {
+		return nil, errors.New("module must export alloc(i32) i32")
+	}
+	handler := []byte{wasm.I32, wasm.I32}
+	for _, name := range []string{"on_request", "on_response"} {
+		t, ok := m.ExportedFunc(name)
+		if !ok {
+			continue
+		}
+		if !sameType(t, handler, []byte{wasm.I32}) {
+			return nil, fmt.Errorf("%s must take (i32, i32) and return i32", name)
+		}
+		if name == "on_request" {
+			f.onRequest = true
+		} else {
+			f.onResponse = true
+		}
+	}
+	if !f.onRequest && !f.onResponse {
+		return nil, errors.New("module exports neither on_request nor on_response")
+	}
+	if t, ok := m.ExportedFunc("free"); ok {
+		if !sameType(t, handler, nil) {
+			return nil, errors.New("free must take (i32, i32)")
+		}
+		f.free = true
+	}
+	for _, imp := range m.Imports() {
+		want, ok := hostTypes[imp.Name]
+		if imp.Module != "gopublic" || !ok {
+			return nil, fmt.Errorf("unknown import %s.%s", imp.Module, imp.Name)
+		}
+		if !sameType(imp.Type, want, nil) {
+			return nil, fmt.Errorf("import %s.%s has the wrong signature", imp.Module, imp.Name)
+		}
+	}
+
+	g, err := f.instantiate()
+	if err != nil {
+		return nil, err
+	}
+	f.pool.Put(g)
+	return f, nil
+}
+
+// hostTypes are the parameters of the functions filters may import.
+var hostTypes = map[string][]byte{
+	"set_header": {wasm.I32, wasm.I32, wasm.I32, wasm.I32},
+	"del_header": {wasm.I32, wasm.I32},
+	"log":        {wasm.I32, wasm.I32},
+}
+
+func sameType(t wasm.FuncType, params, results []byte) bool {
+	return bytes.Equal(t.Params, params) && bytes.Equal(t.Results, results)
+}
+
+// wasmFilter runs a module on each message. Instances are not safe for
+// concurrent use, so each exchange takes one from a pool.
+type wasmFilter struct {
+	module     *wasm.Module
+	limits     wasm.Limits
+	onRequest  bool
+	onResponse bool
+	free       bool
+	pool       sync.Pool // *guest
+}
+
+// guest is an instance and the headers its host calls act on.
+type guest struct {
+	inst   *wasm.Instance
+	header http.Header
+}
+
+func (f *wasmFilter) instantiate() (*guest, error) {
+	g := &guest{}
+	imports := map[string]wasm.HostFunc{
+		"gopublic.set_header": func(inst *wasm.Instance, args []uint64) ([]uint64, error) {
+			name, err := g.str(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			value, err := g.str(args[2], args[3])
+			if err != nil {
+				return nil, err
+			}
+			if !validHeaderName(name) || strings.ContainsAny(value, "\r\n\x00") {
+				return nil, fmt.Errorf("set_header: invalid header %q", name)
+			}
+			g.header.Set(name, value)
+			return nil, nil
+		},
+		"gopublic.del_header": func(inst *wasm.Instance, args []uint64) ([]uint64, error) {
+			name, err := g.str(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			g.header.Del(name)
+			return nil, nil
+		},
+		"gopublic.log": func(inst *wasm.Instance, args []uint64) ([]uint64, error) {
+			msg, err := g.str(args[0], args[1])
+			if err != nil {
+				return nil, err
+			}
+			log.Printf("Filter: %s", msg)
+			return nil, nil
+		},
+	}
+	inst, err := f.module.Instantiate(imports, f.limits)
+	if err != nil {
+		return nil, err
+	}
+	g.inst = inst
+	return g, nil
+}
+
+func (g *guest) str(ptr, n uint64) (string, error) {
+	b, ok := g.inst.Read(uint32(ptr), uint32(n))
+	if !ok {
+		return "", errors.New("string out of bounds")
+	}
+	return string(b), nil
+}
+
+// call passes head to a handler that acts on header and returns its result.
+// An instance that failed is dropped rather than reused.
+func (f *wasmFilter) call(handler string, header http.Header, head []byte) (uint32, error) {
+	g, _ := f.pool.Get().(*guest)
+	if g == nil {
+		var err error
+		if g, err = f.instantiate(); err != nil {
+			return 0, err
+		}
+	}
+
+	g.header = header
+	res, err := g.inst.Call("alloc", uint64(len(head)))
+	if err != nil {
+		return 0, fmt.Errorf("alloc: %w", err)
+	}
+	ptr := uint32(res[0])
+	if !g.inst.Write(ptr, head) {
+		return 0, errors.New("alloc returned a buffer out of bounds")
+	}
+	if res, err = g.inst.Call(handler, uint64(ptr), uint64(len(head))); err != nil {
+		return 0, fmt.Errorf("%s: %w", handler, err)
+	}
+	if f.free {
+		if _, err := g.inst.Call("free", uint64(ptr), uint64(len(head))); err != nil {
+			return 0, fmt.Errorf("free: %w", err)
+		}
+	}
+	g.header = nil
+	f.pool.Put(g)
+	return uint32(res[0]), nil
+}
+
+// Request runs on_request on the request head.
+func (f *wasmFilter) Request(req *http.Request) (*http.Response, error) {
+	if !f.onRequest {
+		return nil, nil
+	}
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "%s %s HTTP/%d.%d\r\nHost: %s\r\n", req.Method, req.URL.RequestURI(), req.ProtoMajor, req.ProtoMinor, req.Host)
+	req.Header.Write(&head)
+
+	status, err := f.call("on_request", req.Header, head.Bytes())
+	switch {
+	case err != nil:
+		return nil, err
+	case status == 0:
+		return nil, nil
+	case status >= 100 && status <= 599:
+		return NewResponse(req, int(status), http.StatusText(int(status))), nil
+	}
+	return nil, fmt.Errorf("on_request returned %d", status)
+}
+
+// Response runs on_response on the response head.
+func (f *wasmFilter) Response(resp *http.Response) error {
+	if !f.onResponse {
+		return nil
+	}
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "HTTP/%d.%d %03d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.StatusCode, http.StatusText(resp.StatusCode))
+	resp.Header.Write(&head)
+
+	verdict, err := f.call("on_response", resp.Header, head.Bytes())
+	if err != nil {
+		return err
+	}
+	if verdict != 0 {
+		return fmt.Errorf("on_response rejected the response (%d)", verdict)
+	}
+	return nil
+}
+
+// validHeaderName reports whether name is an HTTP token.
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range []byte(name) {
+		if c <= ' ' || c >= 0x7F || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,42 @@
+;; Filter module of the tests, assembled into headers.wasm: answers DELETE
+;; requests with 405, tags the others with "X-Filter: wasm", strips the
+;; Server header of responses and rejects 500 responses.
+(module
+  (import "gopublic" "set_header" (func $set_header (param i32 i32 i32 i32)))
+  (import "gopublic" "del_header" (func $del_header (param i32 i32)))
+  (memory (export "memory") 1)
+  (data (i32.const 0) "X-Filter")
+  (data (i32.const 8) "wasm")
+  (data (i32.const 16) "Server")
+  (data (i32.const 24) "DELETE ")
+  (data (i32.const 32) "HTTP/1.1 500")
+
+  ;; has_prefix reports whether the n bytes at lit start the len bytes at ptr.
+  (func $has_prefix (param $ptr i32) (param $len i32) (param $lit i32) (param $n i32) (result i32)
+    (local $i i32)
+    (if (i32.lt_u (local.get $len) (local.get $n))
+      (then (return (i32.const 0))))
+    (block $done
+      (loop $next
+        (br_if $done (i32.ge_u (local.get $i) (local.get $n)))
+        (if (i32.ne
+              (i32.load8_u (i32.add (local.get $ptr) (local.get $i)))
+              (i32.load8_u (i32.add (local.get $lit) (local.get $i))))
+          (then (return (i32.const 0))))
+        (local.set $i (i32.add (local.get $i) (i32.const 1)))
+        (br $next)))
+    (i32.const 1))
+
+  ;; Every message is written to the same buffer.
+  (func (export "alloc") (param $size i32) (result i32)
+    (i32.const 1024))
+
+  (func (export "on_request") (param $ptr i32) (param $len i32) (result i32)
+    (if (call $has_prefix (local.get $ptr) (local.get $len) (i32.const 24) (i32.const 7))
+      (then (return (i32.const 405))))
+    (call $set_header (i32.const 0) (i32.const 8) (i32.const 8) (i32.const 4))
+    (i32.const 0))
+
+  (func (export "on_response") (param $ptr i32) (param $len i32) (result i32)
+    (call $del_header (i32.const 16) (i32.const 6))
+    (call $has_prefix (local.get $ptr) (local.get $len) (i32.const 32) (i32.const 12))))
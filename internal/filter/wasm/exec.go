@@ -0,0 +1,917 @@
+package wasm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"runtime"
+)
+
+// ErrTrap wraps every run-time failure of a module: unreachable code,
+// out-of-bounds memory access, division by zero and exhausted limits.
+var ErrTrap = errors.New("wasm trap")
+
+// ErrOutOfFuel is the trap of a call that ran more instructions than
+// Limits.Fuel allows.
+var ErrOutOfFuel = fmt.Errorf("%w: out of fuel", ErrTrap)
+
+// Defaults for zero Limits fields.
+const (
+	DefaultMaxPages = 256 // 16 MB
+	DefaultFuel     = 10_000_000
+	DefaultMaxDepth = 512
+)
+
+// maxStack caps the operand stack of a single frame.
+const maxStack = 1 << 16
+
+// Limits bound what an instance may use.
+type Limits struct {
+	MaxPages uint32 // Memory cap in 64 KB pages
+	Fuel     int64  // Instructions per Call
+	MaxDepth int    // Nested calls
+}
+
+func (l Limits) withDefaults() Limits {
+	if l.MaxPages == 0 {
+		l.MaxPages = DefaultMaxPages
+	}
+	if l.Fuel == 0 {
+		l.Fuel = DefaultFuel
+	}
+	if l.MaxDepth == 0 {
+		l.MaxDepth = DefaultMaxDepth
+	}
+	return l
+}
+
+// HostFunc implements an imported function. Returning an error traps the
+// running call with it.
+type HostFunc func(inst *Instance, args []uint64) ([]uint64, error)
+
+// Instance is a module with its own memory, globals and table. It is not
+// safe for concurrent use.
+type Instance struct {
+	m        *Module
+	host     []HostFunc
+	mem      []byte
+	maxPages uint32
+	globals  []uint64
+	table    []int64 // function index, -1 when empty
+	dropped  []bool  // data segments no longer available to memory.init
+	limits   Limits
+	fuel     int64
+	depth    int
+}
+
+// trap carries a failure out of the interpreter loop.
+type trap struct{ err error }
+
+func trapf(format string, args ...any) trap {
+	return trap{fmt.Errorf("%w: %s", ErrTrap, fmt.Sprintf(format, args...))}
+}
+
+// Instantiate links the module with host functions, keyed "module.name",
+// initializes its memory and table and runs its start function.
+func (m *Module) Instantiate(imports map[string]HostFunc, limits Limits) (*Instance, error) {
+	inst := &Instance{m: m, limits: limits.withDefaults()}
+	for _, imp := range m.imports {
+		fn, ok := imports[imp.Module+"."+imp.Name]
+		if !ok {
+			return nil, fmt.Errorf("missing import %s.%s", imp.Module, imp.Name)
+		}
+		inst.host = append(inst.host, fn)
+	}
+
+	if m.memory != nil {
+		inst.maxPages = inst.limits.MaxPages
+		if m.memory.hasMax && m.memory.max < inst.maxPages {
+			inst.maxPages = m.memory.max
+		}
+		if m.memory.min > inst.maxPages {
+			return nil, fmt.Errorf("memory of %d pages exceeds the limit of %d", m.memory.min, inst.maxPages)
+		}
+		inst.mem = make([]byte, int(m.memory.min)*PageSize)
+	}
+
+	inst.globals = make([]uint64, len(m.globals))
+	for i, g := range m.globals {
+		inst.globals[i] = g.init
+	}
+
+	if m.table != nil {
+		if m.table.min > 1<<20 {
+			return nil, fmt.Errorf("table of %d elements is too large", m.table.min)
+		}
+		inst.table = make([]int64, m.table.min)
+		for i := range inst.table {
+			inst.table[i] = -1
+		}
+	}
+	for _, seg := range m.elems {
+		if uint64(seg.offset)+uint64(len(seg.funcs)) > uint64(len(inst.table)) {
+			return nil, errors.New("element segment out of bounds")
+		}
+		for i, f := range seg.funcs {
+			inst.table[int(seg.offset)+i] = int64(f)
+		}
+	}
+
+	inst.dropped = make([]bool, len(m.data))
+	for i, seg := range m.data {
+		if seg.passive {
+			continue
+		}
+		if uint64(seg.offset)+uint64(len(seg.data)) > uint64(len(inst.mem)) {
+			return nil, errors.New("data segment out of bounds")
+		}
+		copy(inst.mem[seg.offset:], seg.data)
+		inst.dropped[i] = true
+	}
+
+	if m.start != nil {
+		if err := inst.guard(func() { inst.invoke(*m.start, nil) }); err != nil {
+			return nil, fmt.Errorf("start function: %w", err)
+		}
+	}
+	return inst, nil
+}
+
+// Call runs an exported function with a fresh fuel budget.
+func (inst *Instance) Call(name string, args ...uint64) (results []uint64, err error) {
+	e, ok := inst.m.exports[name]
+	if !ok || e.kind != 0 {
+		return nil, fmt.Errorf("no exported function %q", name)
+	}
+	if n := len(inst.m.funcType(e.index).Params); n != len(args) {
+		return nil, fmt.Errorf("%s takes %d arguments, got %d", name, n, len(args))
+	}
+	err = inst.guard(func() { results = inst.invoke(e.index, args) })
+	return results, err
+}
+
+// guard runs fn with a fresh budget and turns traps into errors.
+func (inst *Instance) guard(fn func()) (err error) {
+	inst.fuel = inst.limits.Fuel
+	inst.depth = 0
+	defer func() {
+		switch r := recover().(type) {
+		case nil:
+		case trap:
+			err = r.err
+		case runtime.Error:
+			err = fmt.Errorf("%w: %v", ErrTrap, r)
+		default:
+			panic(r)
+		}
+	}()
+	fn()
+	return nil
+}
+
+// Memory returns the linear memory. The slice is replaced when the module
+// grows its memory, so do not keep it across calls.
+func (inst *Instance) Memory() []byte {
+	return inst.mem
+}
+
+// Read returns n bytes of memory at ptr.
+func (inst *Instance) Read(ptr, n uint32) ([]byte, bool) {
+	if uint64(ptr)+uint64(n) > uint64(len(inst.mem)) {
+		return nil, false
+	}
+	return inst.mem[ptr : ptr+n], true
+}
+
+// Write copies data into memory at ptr.
+func (inst *Instance) Write(ptr uint32, data []byte) bool {
+	if uint64(ptr)+uint64(len(data)) > uint64(len(inst.mem)) {
+		return false
+	}
+	copy(inst.mem[ptr:], data)
+	return true
+}
+
+func (inst *Instance) invoke(index uint32, args []uint64) []uint64 {
+	if int(index) < len(inst.host) {
+		results, err := inst.host[index](inst, args)
+		if err != nil {
+			panic(trap{err})
+		}
+		if len(results) != len(inst.m.imports[index].Type.Results) {
+			panic(trapf("%s.%s returned %d results", inst.m.imports[index].Module, inst.m.imports[index].Name, len(results)))
+		}
+		return results
+	}
+
+	inst.depth++
+	if inst.depth > inst.limits.MaxDepth {
+		panic(trapf("call stack exhausted"))
+	}
+	f := &inst.m.funcs[int(index)-len(inst.host)]
+	locals := make([]uint64, len(f.locals))
+	copy(locals, args)
+	results := inst.run(f, locals)
+	inst.depth--
+	return results
+}
+
+// effective returns the n bytes of memory an access at base+offset touches.
+func (inst *Instance) effective(base uint64, offset uint64, n uint64) []byte {
+	ea := uint64(uint32(base)) + offset
+	if ea+n > uint64(len(inst.mem)) {
+		panic(trapf("out of bounds memory access"))
+	}
+	return inst.mem[ea : ea+n]
+}
+
+func (inst *Instance) span(addr, n uint64) []byte {
+	addr, n = uint64(uint32(addr)), uint64(uint32(n))
+	if addr+n > uint64(len(inst.mem)) {
+		panic(trapf("out of bounds memory access"))
+	}
+	return inst.mem[addr : addr+n]
+}
+
+type label struct {
+	height int  // Operand stack height below the block's params
+	arity  int  // Values a branch to the label carries
+	cont   int  // Where a branch continues
+	loop   bool // Branches re-enter the block instead of leaving it
+}
+
+// stack is the operand stack of a frame. Values are kept as raw bits:
+// i32 and f32 in the low 32 bits.
+type stack []uint64
+
+func (s *stack) push(v uint64) { *s = append(*s, v) }
+func (s *stack) pushBool(b bool) {
+	if b {
+		s.push(1)
+	} else {
+		s.push(0)
+	}
+}
+func (s *stack) push32(v uint32)   { s.push(uint64(v)) }
+func (s *stack) pushF32(f float32) { s.push(uint64(math.Float32bits(f))) }
+func (s *stack) pushF64(f float64) { s.push(math.Float64bits(f)) }
+
+func (s *stack) pop() uint64 {
+	v := (*s)[len(*s)-1]
+	*s = (*s)[:len(*s)-1]
+	return v
+}
+func (s *stack) pop32() uint32   { return uint32(s.pop()) }
+func (s *stack) popF32() float32 { return math.Float32frombits(uint32(s.pop())) }
+func (s *stack) popF64() float64 { return math.Float64frombits(s.pop()) }
+
+func (inst *Instance) run(f *function, locals []uint64) []uint64 {
+	code := f.code
+	s := make(stack, 0, 16)
+	labels := make([]label, 1, 8)
+	labels[0] = label{arity: len(f.typ.Results), cont: len(code)}
+
+	branch := func(depth uint64, pc *int) {
+		l := labels[len(labels)-1-int(depth)]
+		copy(s[l.height:], s[len(s)-l.arity:])
+		s = s[:l.height+l.arity]
+		if l.loop {
+			labels = labels[:len(labels)-int(depth)]
+		} else {
+			labels = labels[:len(labels)-1-int(depth)]
+		}
+		*pc = l.cont
+	}
+
+	pc := 0
+	for pc < len(code) {
+		in := &code[pc]
+		pc++
+		if inst.fuel--; inst.fuel < 0 {
+			panic(trap{ErrOutOfFuel})
+		}
+		if len(s) > maxStack {
+			panic(trapf("operand stack exhausted"))
+		}
+
+		switch in.op {
+		case 0x00:
+			panic(trapf("unreachable"))
+		case 0x02:
+			labels = append(labels, label{height: len(s) - int(in.in), arity: int(in.out), cont: int(in.end) + 1})
+		case 0x03:
+			labels = append(labels, label{height: len(s) - int(in.in), arity: int(in.in), cont: pc, loop: true})
+		case 0x04:
+			if s.pop32() != 0 {
+				labels = append(labels, label{height: len(s) - int(in.in), arity: int(in.out), cont: int(in.end) + 1})
+			} else if in.els != in.end {
+				labels = append(labels, label{height: len(s) - int(in.in), arity: int(in.out), cont: int(in.end) + 1})
+				pc = int(in.els) + 1
+			} else {
+				pc = int(in.end) + 1
+			}
+		case 0x05: // end of the then branch
+			labels = labels[:len(labels)-1]
+			pc = int(in.end) + 1
+		case 0x0B:
+			labels = labels[:len(labels)-1]
+		case 0x0C:
+			branch(in.imm, &pc)
+		case 0x0D:
+			if s.pop32() != 0 {
+				branch(in.imm, &pc)
+			}
+		case 0x0E:
+			i := s.pop32()
+			depth := in.tbl[len(in.tbl)-1]
+			if uint64(i) < uint64(len(in.tbl)-1) {
+				depth = in.tbl[i]
+			}
+			branch(uint64(depth), &pc)
+		case 0x0F:
+			branch(uint64(len(labels)-1), &pc)
+		case 0x10:
+			inst.call(&s, uint32(in.imm))
+		case 0x11:
+			i := s.pop32()
+			if uint64(i) >= uint64(len(inst.table)) {
+				panic(trapf("undefined element %d", i))
+			}
+			target := inst.table[i]
+			if target < 0 {
+				panic(trapf("uninitialized element %d", i))
+			}
+			if !inst.m.funcType(uint32(target)).equal(inst.m.types[in.imm]) {
+				panic(trapf("indirect call type mismatch"))
+			}
+			inst.call(&s, uint32(target))
+
+		case 0x1A:
+			s.pop()
+		case 0x1B:
+			c, b, a := s.pop32(), s.pop(), s.pop()
+			if c != 0 {
+				s.push(a)
+			} else {
+				s.push(b)
+			}
+		case 0x20:
+			s.push(locals[in.imm])
+		case 0x21:
+			locals[in.imm] = s.pop()
+		case 0x22:
+			locals[in.imm] = s[len(s)-1]
+		case 0x23:
+			s.push(inst.globals[in.imm])
+		case 0x24:
+			inst.globals[in.imm] = s.pop()
+
+		// Memory
+		case 0x28, 0x2A:
+			s.push32(binary.LittleEndian.Uint32(inst.effective(s.pop(), in.imm, 4)))
+		case 0x29, 0x2B:
+			s.push(binary.LittleEndian.Uint64(inst.effective(s.pop(), in.imm, 8)))
+		case 0x2C:
+			s.push32(uint32(int32(int8(inst.effective(s.pop(), in.imm, 1)[0]))))
+		case 0x2D:
+			s.push32(uint32(inst.effective(s.pop(), in.imm, 1)[0]))
+		case 0x2E:
+			s.push32(uint32(int32(int16(binary.LittleEndian.Uint16(inst.effective(s.pop(), in.imm, 2))))))
+		case 0x2F:
+			s.push32(uint32(binary.LittleEndian.Uint16(inst.effective(s.pop(), in.imm, 2))))
+		case 0x30:
+			s.push(uint64(int64(int8(inst.effective(s.pop(), in.imm, 1)[0]))))
+		case 0x31:
+			s.push(uint64(inst.effective(s.pop(), in.imm, 1)[0]))
+		case 0x32:
+			s.push(uint64(int64(int16(binary.LittleEndian.Uint16(inst.effective(s.pop(), in.imm, 2))))))
+		case 0x33:
+			s.push(uint64(binary.LittleEndian.Uint16(inst.effective(s.pop(), in.imm, 2))))
+		case 0x34:
+			s.push(uint64(int64(int32(binary.LittleEndian.Uint32(inst.effective(s.pop(), in.imm, 4))))))
+		case 0x35:
+			s.push(uint64(binary.LittleEndian.Uint32(inst.effective(s.pop(), in.imm, 4))))
+		case 0x36, 0x38:
+			v := s.pop32()
+			binary.LittleEndian.PutUint32(inst.effective(s.pop(), in.imm, 4), v)
+		case 0x37, 0x39:
+			v := s.pop()
+			binary.LittleEndian.PutUint64(inst.effective(s.pop(), in.imm, 8), v)
+		case 0x3A, 0x3C:
+			v := s.pop()
+			inst.effective(s.pop(), in.imm, 1)[0] = byte(v)
+		case 0x3B, 0x3D:
+			v := s.pop()
+			binary.LittleEndian.PutUint16(inst.effective(s.pop(), in.imm, 2), uint16(v))
+		case 0x3E:
+			v := s.pop()
+			binary.LittleEndian.PutUint32(inst.effective(s.pop(), in.imm, 4), uint32(v))
+		case 0x3F:
+			s.push32(uint32(len(inst.mem) / PageSize))
+		case 0x40:
+			s.push32(inst.grow(s.pop32()))
+
+		// Constants
+		case 0x41, 0x42, 0x43, 0x44:
+			s.push(in.imm)
+
+		// i32 comparisons
+		case 0x45:
+			s.pushBool(s.pop32() == 0)
+		case 0x46, 0x47, 0x48, 0x49, 0x4A, 0x4B, 0x4C, 0x4D, 0x4E, 0x4F:
+			b, a := s.pop32(), s.pop32()
+			s.pushBool(compare32(in.op, a, b))
+
+		// i64 comparisons
+		case 0x50:
+			s.pushBool(s.pop() == 0)
+		case 0x51, 0x52, 0x53, 0x54, 0x55, 0x56, 0x57, 0x58, 0x59, 0x5A:
+			b, a := s.pop(), s.pop()
+			s.pushBool(compare64(in.op, a, b))
+
+		// Float comparisons
+		case 0x5B, 0x5C, 0x5D, 0x5E, 0x5F, 0x60:
+			b, a := s.popF32(), s.popF32()
+			s.pushBool(compareFloat(in.op-0x5B, float64(a), float64(b)))
+		case 0x61, 0x62, 0x63, 0x64, 0x65, 0x66:
+			b, a := s.popF64(), s.popF64()
+			s.pushBool(compareFloat(in.op-0x61, a, b))
+
+		// i32 arithmetic
+		case 0x67:
+			s.push32(uint32(bits.LeadingZeros32(s.pop32())))
+		case 0x68:
+			s.push32(uint32(bits.TrailingZeros32(s.pop32())))
+		case 0x69:
+			s.push32(uint32(bits.OnesCount32(s.pop32())))
+		case 0x6A, 0x6B, 0x6C, 0x6D, 0x6E, 0x6F, 0x70, 0x71, 0x72, 0x73, 0x74, 0x75, 0x76, 0x77, 0x78:
+			b, a := s.pop32(), s.pop32()
+			s.push32(arith32(in.op, a, b))
+
+		// i64 arithmetic
+		case 0x79:
+			s.push(uint64(bits.LeadingZeros64(s.pop())))
+		case 0x7A:
+			s.push(uint64(bits.TrailingZeros64(s.pop())))
+		case 0x7B:
+			s.push(uint64(bits.OnesCount64(s.pop())))
+		case 0x7C, 0x7D, 0x7E, 0x7F, 0x80, 0x81, 0x82, 0x83, 0x84, 0x85, 0x86, 0x87, 0x88, 0x89, 0x8A:
+			b, a := s.pop(), s.pop()
+			s.push(arith64(in.op, a, b))
+
+		// f32 arithmetic
+		case 0x8B:
+			s.push32(s.pop32() &^ (1 << 31))
+		case 0x8C:
+			s.push32(s.pop32() ^ (1 << 31))
+		case 0x8D, 0x8E, 0x8F, 0x90, 0x91:
+			s.pushF32(float32(unaryFloat(in.op-0x8D, float64(s.popF32()))))
+		case 0x92, 0x93, 0x94, 0x95, 0x96, 0x97:
+			b, a := s.popF32(), s.popF32()
+			s.pushF32(arithF32(in.op-0x92, a, b))
+		case 0x98:
+			b, a := s.pop32(), s.pop32()
+			s.push32(a&^(1<<31) | b&(1<<31))
+
+		// f64 arithmetic
+		case 0x99:
+			s.push(s.pop() &^ (1 << 63))
+		case 0x9A:
+			s.push(s.pop() ^ (1 << 63))
+		case 0x9B, 0x9C, 0x9D, 0x9E, 0x9F:
+			s.pushF64(unaryFloat(in.op-0x9B, s.popF64()))
+		case 0xA0, 0xA1, 0xA2, 0xA3, 0xA4, 0xA5:
+			b, a := s.popF64(), s.popF64()
+			s.pushF64(arithF64(in.op-0xA0, a, b))
+		case 0xA6:
+			b, a := s.pop(), s.pop()
+			s.push(a&^(1<<63) | b&(1<<63))
+
+		// Conversions
+		case 0xA7:
+			s.push32(s.pop32())
+		case 0xA8:
+			s.push32(uint32(int32(truncate(float64(s.popF32()), math.MinInt32, math.MaxInt32))))
+		case 0xA9:
+			s.push32(uint32(truncate(float64(s.popF32()), 0, math.MaxUint32)))
+		case 0xAA:
+			s.push32(uint32(int32(truncate(s.popF64(), math.MinInt32, math.MaxInt32))))
+		case 0xAB:
+			s.push32(uint32(truncate(s.popF64(), 0, math.MaxUint32)))
+		case 0xAC:
+			s.push(uint64(int64(int32(s.pop32()))))
+		case 0xAD:
+			s.push(uint64(s.pop32()))
+		case 0xAE:
+			s.push(uint64(truncateI64(float64(s.popF32()))))
+		case 0xAF:
+			s.push(truncateU64(float64(s.popF32())))
+		case 0xB0:
+			s.push(uint64(truncateI64(s.popF64())))
+		case 0xB1:
+			s.push(truncateU64(s.popF64()))
+		case 0xB2:
+			s.pushF32(float32(int32(s.pop32())))
+		case 0xB3:
+			s.pushF32(float32(s.pop32()))
+		case 0xB4:
+			s.pushF32(float32(int64(s.pop())))
+		case 0xB5:
+			s.pushF32(float32(s.pop()))
+		case 0xB6:
+			s.pushF32(float32(s.popF64()))
+		case 0xB7:
+			s.pushF64(float64(int32(s.pop32())))
+		case 0xB8:
+			s.pushF64(float64(s.pop32()))
+		case 0xB9:
+			s.pushF64(float64(int64(s.pop())))
+		case 0xBA:
+			s.pushF64(float64(s.pop()))
+		case 0xBB:
+			s.pushF64(float64(s.popF32()))
+		case 0xBC, 0xBD, 0xBE, 0xBF: // reinterpret: the bits stay as they are
+
+		// Sign extension
+		case 0xC0:
+			s.push32(uint32(int32(int8(s.pop32()))))
+		case 0xC1:
+			s.push32(uint32(int32(int16(s.pop32()))))
+		case 0xC2:
+			s.push(uint64(int64(int8(s.pop()))))
+		case 0xC3:
+			s.push(uint64(int64(int16(s.pop()))))
+		case 0xC4:
+			s.push(uint64(int64(int32(s.pop()))))
+
+		// Saturating truncation
+		case 0xFC00:
+			s.push32(uint32(int32(saturate(float64(s.popF32()), math.MinInt32, math.MaxInt32))))
+		case 0xFC01:
+			s.push32(uint32(saturate(float64(s.popF32()), 0, math.MaxUint32)))
+		case 0xFC02:
+			s.push32(uint32(int32(saturate(s.popF64(), math.MinInt32, math.MaxInt32))))
+		case 0xFC03:
+			s.push32(uint32(saturate(s.popF64(), 0, math.MaxUint32)))
+		case 0xFC04:
+			s.push(uint64(saturateI64(float64(s.popF32()))))
+		case 0xFC05:
+			s.push(saturateU64(float64(s.popF32())))
+		case 0xFC06:
+			s.push(uint64(saturateI64(s.popF64())))
+		case 0xFC07:
+			s.push(saturateU64(s.popF64()))
+
+		// Bulk memory
+		case 0xFC08:
+			n, src, dst := s.pop(), s.pop(), s.pop()
+			if int(in.imm) >= len(inst.m.data) {
+				panic(trapf("unknown data segment %d", in.imm))
+			}
+			var data []byte
+			if !inst.dropped[in.imm] {
+				data = inst.m.data[in.imm].data
+			}
+			if uint64(uint32(src))+uint64(uint32(n)) > uint64(len(data)) {
+				panic(trapf("out of bounds memory access"))
+			}
+			copy(inst.span(dst, n), data[uint32(src):])
+		case 0xFC09:
+			if int(in.imm) >= len(inst.dropped) {
+				panic(trapf("unknown data segment %d", in.imm))
+			}
+			inst.dropped[in.imm] = true
+		case 0xFC0A:
+			n, src, dst := s.pop(), s.pop(), s.pop()
+			from := inst.span(src, n)
+			copy(inst.span(dst, n), from)
+		case 0xFC0B:
+			n, v, dst := s.pop(), s.pop(), s.pop()
+			to := inst.span(dst, n)
+			for i := range to {
+				to[i] = byte(v)
+			}
+
+		default:
+			panic(trapf("unsupported instruction 0x%x", in.op))
+		}
+	}
+
+	n := len(f.typ.Results)
+	return append([]uint64(nil), s[len(s)-n:]...)
+}
+
+// call pops the arguments of a function, runs it and pushes its results.
+func (inst *Instance) call(s *stack, index uint32) {
+	n := len(inst.m.funcType(index).Params)
+	args := append([]uint64(nil), (*s)[len(*s)-n:]...)
+	*s = (*s)[:len(*s)-n]
+	*s = append(*s, inst.invoke(index, args)...)
+}
+
+// grow adds pages to memory and returns the old size, or -1 past the limit.
+func (inst *Instance) grow(pages uint32) uint32 {
+	old := uint32(len(inst.mem) / PageSize)
+	if inst.m.memory == nil || uint64(old)+uint64(pages) > uint64(inst.maxPages) {
+		return math.MaxUint32
+	}
+	inst.mem = append(inst.mem, make([]byte, int(pages)*PageSize)...)
+	return old
+}
+
+func compare32(op uint16, a, b uint32) bool {
+	switch op {
+	case 0x46:
+		return a == b
+	case 0x47:
+		return a != b
+	case 0x48:
+		return int32(a) < int32(b)
+	case 0x49:
+		return a < b
+	case 0x4A:
+		return int32(a) > int32(b)
+	case 0x4B:
+		return a > b
+	case 0x4C:
+		return int32(a) <= int32(b)
+	case 0x4D:
+		return a <= b
+	case 0x4E:
+		return int32(a) >= int32(b)
+	default:
+		return a >= b
+	}
+}
+
+func compare64(op uint16, a, b uint64) bool {
+	switch op {
+	case 0x51:
+		return a == b
+	case 0x52:
+		return a != b
+	case 0x53:
+		return int64(a) < int64(b)
+	case 0x54:
+		return a < b
+	case 0x55:
+		return int64(a) > int64(b)
+	case 0x56:
+		return a > b
+	case 0x57:
+		return int64(a) <= int64(b)
+	case 0x58:
+		return a <= b
+	case 0x59:
+		return int64(a) >= int64(b)
+	default:
+		return a >= b
+	}
+}
+
+// compareFloat runs eq, ne, lt, gt, le or ge (op 0-5).
+func compareFloat(op uint16, a, b float64) bool {
+	switch op {
+	case 0:
+		return a == b
+	case 1:
+		return a != b
+	case 2:
+		return a < b
+	case 3:
+		return a > b
+	case 4:
+		return a <= b
+	default:
+		return a >= b
+	}
+}
+
+func arith32(op uint16, a, b uint32) uint32 {
+	switch op {
+	case 0x6A:
+		return a + b
+	case 0x6B:
+		return a - b
+	case 0x6C:
+		return a * b
+	case 0x6D:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		if int32(a) == math.MinInt32 && int32(b) == -1 {
+			panic(trapf("integer overflow"))
+		}
+		return uint32(int32(a) / int32(b))
+	case 0x6E:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		return a / b
+	case 0x6F:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		if int32(b) == -1 {
+			return 0
+		}
+		return uint32(int32(a) % int32(b))
+	case 0x70:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		return a % b
+	case 0x71:
+		return a & b
+	case 0x72:
+		return a | b
+	case 0x73:
+		return a ^ b
+	case 0x74:
+		return a << (b & 31)
+	case 0x75:
+		return uint32(int32(a) >> (b & 31))
+	case 0x76:
+		return a >> (b & 31)
+	case 0x77:
+		return bits.RotateLeft32(a, int(b&31))
+	default:
+		return bits.RotateLeft32(a, -int(b&31))
+	}
+}
+
+func arith64(op uint16, a, b uint64) uint64 {
+	switch op {
+	case 0x7C:
+		return a + b
+	case 0x7D:
+		return a - b
+	case 0x7E:
+		return a * b
+	case 0x7F:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		if int64(a) == math.MinInt64 && int64(b) == -1 {
+			panic(trapf("integer overflow"))
+		}
+		return uint64(int64(a) / int64(b))
+	case 0x80:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		return a / b
+	case 0x81:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		if int64(b) == -1 {
+			return 0
+		}
+		return uint64(int64(a) % int64(b))
+	case 0x82:
+		if b == 0 {
+			panic(trapf("integer divide by zero"))
+		}
+		return a % b
+	case 0x83:
+		return a & b
+	case 0x84:
+		return a | b
+	case 0x85:
+		return a ^ b
+	case 0x86:
+		return a << (b & 63)
+	case 0x87:
+		return uint64(int64(a) >> (b & 63))
+	case 0x88:
+		return a >> (b & 63)
+	case 0x89:
+		return bits.RotateLeft64(a, int(b&63))
+	default:
+		return bits.RotateLeft64(a, -int(b&63))
+	}
+}
+
+// unaryFloat runs ceil, floor, trunc, nearest or sqrt (op 0-4).
+func unaryFloat(op uint16, f float64) float64 {
+	switch op {
+	case 0:
+		return math.Ceil(f)
+	case 1:
+		return math.Floor(f)
+	case 2:
+		return math.Trunc(f)
+	case 3:
+		return math.RoundToEven(f)
+	default:
+		return math.Sqrt(f)
+	}
+}
+
+// arithF32 runs add, sub, mul, div, min or max (op 0-5).
+func arithF32(op uint16, a, b float32) float32 {
+	switch op {
+	case 0:
+		return a + b
+	case 1:
+		return a - b
+	case 2:
+		return a * b
+	case 3:
+		return a / b
+	case 4:
+		return float32(math.Min(float64(a), float64(b)))
+	default:
+		return float32(math.Max(float64(a), float64(b)))
+	}
+}
+
+// arithF64 runs add, sub, mul, div, min or max (op 0-5).
+func arithF64(op uint16, a, b float64) float64 {
+	switch op {
+	case 0:
+		return a + b
+	case 1:
+		return a - b
+	case 2:
+		return a * b
+	case 3:
+		return a / b
+	case 4:
+		return math.Min(a, b)
+	default:
+		return math.Max(a, b)
+	}
+}
+
+// truncate converts f to an integer in [lo, hi], trapping outside it.
+func truncate(f, lo, hi float64) int64 {
+	if math.IsNaN(f) {
+		panic(trapf("invalid conversion to integer"))
+	}
+	t := math.Trunc(f)
+	if t < lo || t > hi {
+		panic(trapf("integer overflow"))
+	}
+	return int64(t)
+}
+
+func truncateI64(f float64) int64 {
+	if math.IsNaN(f) {
+		panic(trapf("invalid conversion to integer"))
+	}
+	t := math.Trunc(f)
+	if t < math.MinInt64 || t >= 1<<63 {
+		panic(trapf("integer overflow"))
+	}
+	return int64(t)
+}
+
+func truncateU64(f float64) uint64 {
+	if math.IsNaN(f) {
+		panic(trapf("invalid conversion to integer"))
+	}
+	t := math.Trunc(f)
+	if t <= -1 || t >= 1<<64 {
+		panic(trapf("integer overflow"))
+	}
+	return uint64(t)
+}
+
+// saturate converts f to an integer, clamping it to [lo, hi].
+func saturate(f, lo, hi float64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f <= lo:
+		return int64(lo)
+	case f >= hi:
+		return int64(hi)
+	}
+	return int64(f)
+}
+
+func saturateI64(f float64) int64 {
+	switch {
+	case math.IsNaN(f):
+		return 0
+	case f <= math.MinInt64:
+		return math.MinInt64
+	case f >= 1<<63:
+		return math.MaxInt64
+	}
+	return int64(f)
+}
+
+func saturateU64(f float64) uint64 {
+	switch {
+	case math.IsNaN(f) || f <= 0:
+		return 0
+	case f >= 1<<64:
+		return math.MaxUint64
+	}
+	return uint64(f)
+}
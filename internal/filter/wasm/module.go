@@ -0,0 +1,571 @@
+// Package wasm is a small WebAssembly interpreter for traffic filters. It
+// runs MVP modules (plus sign extension, saturating truncation and bulk
+// memory copy/fill) without cgo, with a fuel budget, a memory cap and a
+// call depth limit so a filter cannot hang or exhaust the process.
+package wasm
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Value types.
+const (
+	I32 byte = 0x7F
+	I64 byte = 0x7E
+	F32 byte = 0x7D
+	F64 byte = 0x7C
+
+	funcRef   byte = 0x70
+	externRef byte = 0x6F
+)
+
+// PageSize is the size of a WebAssembly memory page.
+const PageSize = 64 * 1024
+
+// FuncType is the signature of a function.
+type FuncType struct {
+	Params  []byte
+	Results []byte
+}
+
+func (t FuncType) equal(o FuncType) bool {
+	return bytes.Equal(t.Params, o.Params) && bytes.Equal(t.Results, o.Results)
+}
+
+func (t FuncType) String() string {
+	return fmt.Sprintf("%x -> %x", t.Params, t.Results)
+}
+
+// Import is a function the module expects from the host.
+type Import struct {
+	Module string
+	Name   string
+	Type   FuncType
+}
+
+type limits struct {
+	min, max uint32
+	hasMax   bool
+}
+
+type global struct {
+	typ     byte
+	mutable bool
+	init    uint64
+}
+
+type export struct {
+	kind  byte // 0 func, 1 table, 2 memory, 3 global
+	index uint32
+}
+
+type elemSegment struct {
+	offset uint32
+	funcs  []uint32
+}
+
+type dataSegment struct {
+	passive bool
+	offset  uint32
+	data    []byte
+}
+
+// instr is a decoded instruction. Block instructions carry the index of
+// their end (and else) so branches do not scan the code at run time.
+type instr struct {
+	op  uint16 // opcode; 0xFC-prefixed ops are 0xFC00|sub
+	imm uint64 // constant, index, depth or memory offset
+	end uint32 // block, loop, if, else: index of the matching end
+	els uint32 // if: index of the else, or the end without one
+	in  uint16 // block params
+	out uint16 // block results
+	tbl []uint32
+}
+
+type function struct {
+	typ    FuncType
+	locals []byte // params followed by declared locals
+	code   []instr
+}
+
+// Module is a decoded module, ready to be instantiated any number of times.
+type Module struct {
+	types   []FuncType
+	imports []Import
+	funcs   []function // defined functions, after the imported ones
+	table   *limits
+	memory  *limits
+	globals []global
+	exports map[string]export
+	start   *uint32
+	elems   []elemSegment
+	data    []dataSegment
+}
+
+// Imports lists the host functions the module needs.
+func (m *Module) Imports() []Import {
+	return m.imports
+}
+
+// ExportedFunc returns the signature of an exported function.
+func (m *Module) ExportedFunc(name string) (FuncType, bool) {
+	e, ok := m.exports[name]
+	if !ok || e.kind != 0 {
+		return FuncType{}, false
+	}
+	return m.funcType(e.index), true
+}
+
+func (m *Module) funcType(index uint32) FuncType {
+	if int(index) < len(m.imports) {
+		return m.imports[index].Type
+	}
+	return m.funcs[int(index)-len(m.imports)].typ
+}
+
+// ErrInvalid wraps every decoding failure.
+var ErrInvalid = errors.New("invalid module")
+
+type decoder struct {
+	b   []byte
+	pos int
+}
+
+func (d *decoder) fail(format string, args ...any) {
+	panic(fmt.Errorf("%w: %s at byte %d", ErrInvalid, fmt.Sprintf(format, args...), d.pos))
+}
+
+func (d *decoder) eof() bool { return d.pos >= len(d.b) }
+
+func (d *decoder) byte() byte {
+	if d.pos >= len(d.b) {
+		d.fail("unexpected end")
+	}
+	c := d.b[d.pos]
+	d.pos++
+	return c
+}
+
+func (d *decoder) bytes(n uint32) []byte {
+	if uint64(d.pos)+uint64(n) > uint64(len(d.b)) {
+		d.fail("unexpected end")
+	}
+	b := d.b[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b
+}
+
+func (d *decoder) u32() uint32 {
+	var v uint64
+	for shift := 0; ; shift += 7 {
+		if shift >= 35 {
+			d.fail("integer too long")
+		}
+		c := d.byte()
+		v |= uint64(c&0x7F) << shift
+		if c&0x80 == 0 {
+			break
+		}
+	}
+	if v > math.MaxUint32 {
+		d.fail("integer too large")
+	}
+	return uint32(v)
+}
+
+func (d *decoder) signed(bits int) int64 {
+	var v int64
+	shift := 0
+	for {
+		if shift >= bits {
+			d.fail("integer too long")
+		}
+		c := d.byte()
+		v |= int64(c&0x7F) << shift
+		shift += 7
+		if c&0x80 == 0 {
+			if shift < 64 && c&0x40 != 0 {
+				v |= -1 << shift
+			}
+			return v
+		}
+	}
+}
+
+func (d *decoder) name() string {
+	return string(d.bytes(d.u32()))
+}
+
+func (d *decoder) valType() byte {
+	t := d.byte()
+	switch t {
+	case I32, I64, F32, F64:
+		return t
+	}
+	d.fail("unsupported value type 0x%x", t)
+	return 0
+}
+
+func (d *decoder) valTypes() []byte {
+	n := d.u32()
+	types := make([]byte, 0, min(n, 64))
+	for i := uint32(0); i < n; i++ {
+		types = append(types, d.valType())
+	}
+	return types
+}
+
+func (d *decoder) limits() limits {
+	switch flag := d.byte(); flag {
+	case 0:
+		return limits{min: d.u32()}
+	case 1:
+		l := limits{min: d.u32(), max: d.u32(), hasMax: true}
+		if l.max < l.min {
+			d.fail("limits max below min")
+		}
+		return l
+	default:
+		d.fail("unsupported limits flag 0x%x", flag)
+	}
+	return limits{}
+}
+
+// constExpr evaluates an initializer: a constant or an earlier global.
+func (d *decoder) constExpr(m *Module) uint64 {
+	var v uint64
+	switch op := d.byte(); op {
+	case 0x41:
+		v = uint64(uint32(int32(d.signed(32))))
+	case 0x42:
+		v = uint64(d.signed(64))
+	case 0x43:
+		v = uint64(binary.LittleEndian.Uint32(d.bytes(4)))
+	case 0x44:
+		v = binary.LittleEndian.Uint64(d.bytes(8))
+	case 0x23:
+		i := d.u32()
+		if int(i) >= len(m.globals) {
+			d.fail("unknown global %d", i)
+		}
+		v = m.globals[i].init
+	default:
+		d.fail("unsupported initializer 0x%x", op)
+	}
+	if d.byte() != 0x0B {
+		d.fail("initializer not terminated")
+	}
+	return v
+}
+
+// Compile decodes a binary module.
+func Compile(code []byte) (m *Module, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(error)
+			if !ok || !errors.Is(e, ErrInvalid) {
+				e = fmt.Errorf("%w: %v", ErrInvalid, r)
+			}
+			m, err = nil, e
+		}
+	}()
+
+	d := &decoder{b: code}
+	if !bytes.HasPrefix(code, []byte("\x00asm")) {
+		d.fail("not a WebAssembly module")
+	}
+	d.pos = 4
+	if v := binary.LittleEndian.Uint32(d.bytes(4)); v != 1 {
+		d.fail("unsupported version %d", v)
+	}
+
+	m = &Module{exports: make(map[string]export)}
+	var funcTypes []uint32
+	for !d.eof() {
+		id := d.byte()
+		size := d.u32()
+		sec := &decoder{b: d.bytes(size)}
+		sec.pos = 0
+		switch id {
+		case 0: // custom
+		case 1:
+			for n := sec.u32(); n > 0; n-- {
+				if sec.byte() != 0x60 {
+					sec.fail("bad function type")
+				}
+				m.types = append(m.types, FuncType{Params: sec.valTypes(), Results: sec.valTypes()})
+			}
+		case 2:
+			for n := sec.u32(); n > 0; n-- {
+				imp := Import{Module: sec.name(), Name: sec.name()}
+				if kind := sec.byte(); kind != 0 {
+					sec.fail("import %s.%s: only functions can be imported", imp.Module, imp.Name)
+				}
+				imp.Type = m.typeAt(sec, sec.u32())
+				m.imports = append(m.imports, imp)
+			}
+		case 3:
+			for n := sec.u32(); n > 0; n-- {
+				idx := sec.u32()
+				m.typeAt(sec, idx)
+				funcTypes = append(funcTypes, idx)
+			}
+		case 4:
+			for n := sec.u32(); n > 0; n-- {
+				if m.table != nil {
+					sec.fail("more than one table")
+				}
+				if t := sec.byte(); t != funcRef {
+					sec.fail("unsupported table type 0x%x", t)
+				}
+				l := sec.limits()
+				m.table = &l
+			}
+		case 5:
+			for n := sec.u32(); n > 0; n-- {
+				if m.memory != nil {
+					sec.fail("more than one memory")
+				}
+				l := sec.limits()
+				if l.min > 65536 || (l.hasMax && l.max > 65536) {
+					sec.fail("memory too large")
+				}
+				m.memory = &l
+			}
+		case 6:
+			for n := sec.u32(); n > 0; n-- {
+				g := global{typ: sec.valType(), mutable: sec.byte() == 1}
+				g.init = sec.constExpr(m)
+				m.globals = append(m.globals, g)
+			}
+		case 7:
+			for n := sec.u32(); n > 0; n-- {
+				name := sec.name()
+				m.exports[name] = export{kind: sec.byte(), index: sec.u32()}
+			}
+		case 8:
+			idx := sec.u32()
+			m.start = &idx
+		case 9:
+			for n := sec.u32(); n > 0; n-- {
+				if flag := sec.u32(); flag != 0 {
+					sec.fail("unsupported element segment kind %d", flag)
+				}
+				seg := elemSegment{offset: uint32(sec.constExpr(m))}
+				for k := sec.u32(); k > 0; k-- {
+					seg.funcs = append(seg.funcs, sec.u32())
+				}
+				m.elems = append(m.elems, seg)
+			}
+		case 10:
+			count := sec.u32()
+			if int(count) != len(funcTypes) {
+				sec.fail("function and code section sizes differ")
+			}
+			for i := uint32(0); i < count; i++ {
+				body := &decoder{b: sec.bytes(sec.u32())}
+				m.funcs = append(m.funcs, m.function(body, m.types[funcTypes[i]]))
+			}
+		case 11:
+			for n := sec.u32(); n > 0; n-- {
+				var seg dataSegment
+				switch flag := sec.u32(); flag {
+				case 0:
+					seg.offset = uint32(sec.constExpr(m))
+				case 1:
+					seg.passive = true
+				case 2:
+					if sec.u32() != 0 {
+						sec.fail("unknown memory")
+					}
+					seg.offset = uint32(sec.constExpr(m))
+				default:
+					sec.fail("unsupported data segment kind %d", flag)
+				}
+				seg.data = sec.bytes(sec.u32())
+				m.data = append(m.data, seg)
+			}
+		case 12: // data count
+		default:
+			d.fail("unknown section %d", id)
+		}
+	}
+	if len(m.funcs) != len(funcTypes) {
+		d.fail("function section without code")
+	}
+
+	total := uint32(len(m.imports) + len(m.funcs))
+	for name, e := range m.exports {
+		if e.kind == 0 && e.index >= total {
+			d.fail("export %s: unknown function %d", name, e.index)
+		}
+	}
+	if m.start != nil && *m.start >= total {
+		d.fail("unknown start function %d", *m.start)
+	}
+	for _, seg := range m.elems {
+		for _, f := range seg.funcs {
+			if f >= total {
+				d.fail("element segment: unknown function %d", f)
+			}
+		}
+	}
+	for _, f := range m.funcs {
+		for _, in := range f.code {
+			if (in.op == 0x10 && in.imm >= uint64(total)) || (in.op == 0x23 || in.op == 0x24) && in.imm >= uint64(len(m.globals)) {
+				d.fail("reference to an unknown function or global")
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m *Module) typeAt(d *decoder, idx uint32) FuncType {
+	if int(idx) >= len(m.types) {
+		d.fail("unknown type %d", idx)
+	}
+	return m.types[idx]
+}
+
+// blockType decodes the signature of a block, loop or if.
+func (m *Module) blockType(d *decoder) (in, out uint16) {
+	switch d.byte() {
+	case 0x40:
+		return 0, 0
+	case I32, I64, F32, F64:
+		return 0, 1
+	}
+	d.pos--
+	idx := d.signed(33)
+	if idx < 0 {
+		d.fail("bad block type")
+	}
+	t := m.typeAt(d, uint32(idx))
+	return uint16(len(t.Params)), uint16(len(t.Results))
+}
+
+// function decodes a function body and links its blocks.
+func (m *Module) function(d *decoder, typ FuncType) function {
+	f := function{typ: typ, locals: append([]byte(nil), typ.Params...)}
+	for n := d.u32(); n > 0; n-- {
+		count := d.u32()
+		if uint64(len(f.locals))+uint64(count) > 50000 {
+			d.fail("too many locals")
+		}
+		t := d.valType()
+		for ; count > 0; count-- {
+			f.locals = append(f.locals, t)
+		}
+	}
+
+	var open []int // indexes of the enclosing block instructions
+	for !d.eof() {
+		in := instr{op: uint16(d.byte())}
+		switch in.op {
+		case 0x00, 0x01, 0x0F, 0x1A, 0x1B:
+		case 0x02, 0x03, 0x04:
+			in.in, in.out = m.blockType(d)
+			open = append(open, len(f.code))
+		case 0x05:
+			if len(open) == 0 || f.code[open[len(open)-1]].op != 0x04 {
+				d.fail("else outside if")
+			}
+			f.code[open[len(open)-1]].els = uint32(len(f.code))
+		case 0x0B:
+			if len(open) > 0 {
+				start := open[len(open)-1]
+				open = open[:len(open)-1]
+				end := uint32(len(f.code))
+				f.code[start].end = end
+				if f.code[start].op == 0x04 {
+					if f.code[start].els == 0 {
+						f.code[start].els = end
+					} else {
+						f.code[f.code[start].els].end = end
+					}
+				}
+			} else if !d.eof() {
+				d.fail("code after the end of the function")
+			}
+		case 0x0C, 0x0D, 0x10, 0x20, 0x21, 0x22, 0x23, 0x24:
+			in.imm = uint64(d.u32())
+		case 0x0E:
+			n := d.u32()
+			if n > 1<<16 {
+				d.fail("branch table too large")
+			}
+			in.tbl = make([]uint32, n+1)
+			for i := range in.tbl {
+				in.tbl[i] = d.u32()
+			}
+		case 0x11:
+			in.imm = uint64(d.u32())
+			m.typeAt(d, uint32(in.imm))
+			if d.u32() != 0 || m.table == nil {
+				d.fail("unknown table")
+			}
+		case 0x1C:
+			if n := d.u32(); n != 1 {
+				d.fail("bad select arity")
+			}
+			d.valType()
+			in.op = 0x1B
+		case 0x3F, 0x40:
+			if d.byte() != 0 {
+				d.fail("unknown memory")
+			}
+		case 0x41:
+			in.imm = uint64(uint32(int32(d.signed(32))))
+		case 0x42:
+			in.imm = uint64(d.signed(64))
+		case 0x43:
+			in.imm = uint64(binary.LittleEndian.Uint32(d.bytes(4)))
+		case 0x44:
+			in.imm = binary.LittleEndian.Uint64(d.bytes(8))
+		case 0xFC:
+			in.op = 0xFC00 | uint16(d.u32())
+			switch in.op & 0xFF {
+			case 0, 1, 2, 3, 4, 5, 6, 7:
+			case 8:
+				in.imm = uint64(d.u32())
+				d.byte()
+			case 9:
+				in.imm = uint64(d.u32())
+			case 10:
+				d.byte()
+				d.byte()
+			case 11:
+				d.byte()
+			default:
+				d.fail("unsupported instruction 0xfc %d", in.op&0xFF)
+			}
+		default:
+			switch {
+			case in.op >= 0x28 && in.op <= 0x3E:
+				d.u32() // alignment hint
+				in.imm = uint64(d.u32())
+			case in.op >= 0x45 && in.op <= 0xC4:
+			default:
+				d.fail("unsupported instruction 0x%x", in.op)
+			}
+		}
+		if in.op != 0x01 {
+			f.code = append(f.code, in)
+		}
+	}
+	if len(open) != 0 || len(f.code) == 0 || f.code[len(f.code)-1].op != 0x0B {
+		d.fail("function not terminated")
+	}
+	if m.memory == nil {
+		for _, in := range f.code {
+			if (in.op >= 0x28 && in.op <= 0x40) || in.op >= 0xFC08 {
+				d.fail("memory instruction without a memory")
+			}
+		}
+	}
+	return f
+}
@@ -0,0 +1,343 @@
+package wasm
+
+import (
+	"errors"
+	"testing"
+)
+
+// Test modules are assembled from sections; bodies are raw instructions.
+
+func leb(v uint32) []byte {
+	var b []byte
+	for {
+		c := byte(v & 0x7F)
+		v >>= 7
+		if v != 0 {
+			c |= 0x80
+		}
+		b = append(b, c)
+		if v == 0 {
+			return b
+		}
+	}
+}
+
+func vec(items ...[]byte) []byte {
+	b := leb(uint32(len(items)))
+	for _, item := range items {
+		b = append(b, item...)
+	}
+	return b
+}
+
+func str(s string) []byte {
+	return append(leb(uint32(len(s))), s...)
+}
+
+func section(id byte, content []byte) []byte {
+	return append(append([]byte{id}, leb(uint32(len(content)))...), content...)
+}
+
+func functype(params, results []byte) []byte {
+	return append(append([]byte{0x60}, vec(bytesOf(params)...)...), vec(bytesOf(results)...)...)
+}
+
+func bytesOf(b []byte) [][]byte {
+	items := make([][]byte, len(b))
+	for i, c := range b {
+		items[i] = []byte{c}
+	}
+	return items
+}
+
+// body prefixes instructions with local declarations (count, type pairs).
+func body(locals []byte, code ...byte) []byte {
+	var decls [][]byte
+	for i := 0; i < len(locals); i += 2 {
+		decls = append(decls, []byte{locals[i], locals[i+1]})
+	}
+	b := append(vec(decls...), code...)
+	return append(leb(uint32(len(b))), b...)
+}
+
+type testModule struct {
+	types   [][]byte
+	imports [][]byte
+	funcs   []uint32 // type of each defined function
+	bodies  [][]byte
+	memory  []byte
+	exports [][]byte
+	data    [][]byte
+	table   []byte
+	elems   [][]byte
+}
+
+func (tm testModule) bytes() []byte {
+	b := []byte("\x00asm\x01\x00\x00\x00")
+	b = append(b, section(1, vec(tm.types...))...)
+	if tm.imports != nil {
+		b = append(b, section(2, vec(tm.imports...))...)
+	}
+	var funcs [][]byte
+	for _, t := range tm.funcs {
+		funcs = append(funcs, leb(t))
+	}
+	b = append(b, section(3, vec(funcs...))...)
+	if tm.table != nil {
+		b = append(b, section(4, vec(tm.table))...)
+	}
+	if tm.memory != nil {
+		b = append(b, section(5, vec(tm.memory))...)
+	}
+	b = append(b, section(7, vec(tm.exports...))...)
+	if tm.elems != nil {
+		b = append(b, section(9, vec(tm.elems...))...)
+	}
+	b = append(b, section(10, vec(tm.bodies...))...)
+	if tm.data != nil {
+		b = append(b, section(11, vec(tm.data...))...)
+	}
+	return b
+}
+
+func exportFunc(name string, index uint32) []byte {
+	return append(append(str(name), 0x00), leb(index)...)
+}
+
+func instantiate(t *testing.T, tm testModule, imports map[string]HostFunc, limits Limits) *Instance {
+	t.Helper()
+	m, err := Compile(tm.bytes())
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	inst, err := m.Instantiate(imports, limits)
+	if err != nil {
+		t.Fatalf("Instantiate() error = %v", err)
+	}
+	return inst
+}
+
+func call(t *testing.T, inst *Instance, name string, args ...uint64) uint64 {
+	t.Helper()
+	res, err := inst.Call(name, args...)
+	if err != nil {
+		t.Fatalf("%s() error = %v", name, err)
+	}
+	if len(res) != 1 {
+		t.Fatalf("%s() = %v, want one result", name, res)
+	}
+	return res[0]
+}
+
+func TestArithmeticAndControl(t *testing.T) {
+	tm := testModule{
+		types: [][]byte{
+			functype([]byte{I32, I32}, []byte{I32}),
+			functype([]byte{I64}, []byte{I64}),
+			functype([]byte{I32}, []byte{I32}),
+		},
+		funcs: []uint32{0, 1, 2, 2, 0},
+		bodies: [][]byte{
+			// add: a + b
+			body(nil, 0x20, 0, 0x20, 1, 0x6A, 0x0B),
+			// fact: n == 0 ? 1 : n * fact(n-1)
+			body(nil,
+				0x20, 0, 0x50,
+				0x04, I64,
+				0x42, 1,
+				0x05,
+				0x20, 0, 0x20, 0, 0x42, 1, 0x7D, 0x10, 1, 0x7E,
+				0x0B,
+				0x0B),
+			// sum: 1 + 2 + ... + n with a loop
+			body([]byte{1, I32},
+				0x02, 0x40,
+				0x03, 0x40,
+				0x20, 0, 0x45, 0x0D, 1,
+				0x20, 1, 0x20, 0, 0x6A, 0x21, 1,
+				0x20, 0, 0x41, 1, 0x6B, 0x21, 0,
+				0x0C, 0,
+				0x0B,
+				0x0B,
+				0x20, 1, 0x0B),
+			// pick: br_table over 0, 1 and a default
+			body(nil,
+				0x02, 0x40, 0x02, 0x40, 0x02, 0x40,
+				0x20, 0, 0x0E, 2, 0, 1, 2,
+				0x0B,
+				0x41, 10, 0x0F,
+				0x0B,
+				0x41, 20, 0x0F,
+				0x0B,
+				0x41, 30, 0x0B),
+			// div: a / b (signed)
+			body(nil, 0x20, 0, 0x20, 1, 0x6D, 0x0B),
+		},
+		exports: [][]byte{exportFunc("add", 0), exportFunc("fact", 1), exportFunc("sum", 2), exportFunc("pick", 3), exportFunc("div", 4)},
+	}
+	inst := instantiate(t, tm, nil, Limits{})
+
+	if got := int32(call(t, inst, "add", 7, uint64(uint32(0xFFFFFFFD)))); got != 4 {
+		t.Errorf("add(7, -3) = %d, want 4", got)
+	}
+	if got := call(t, inst, "fact", 20); got != 2432902008176640000 {
+		t.Errorf("fact(20) = %d", got)
+	}
+	if got := call(t, inst, "sum", 100); got != 5050 {
+		t.Errorf("sum(100) = %d, want 5050", got)
+	}
+	for arg, want := range map[uint64]uint64{0: 10, 1: 20, 2: 30, 9: 30} {
+		if got := call(t, inst, "pick", arg); got != want {
+			t.Errorf("pick(%d) = %d, want %d", arg, got, want)
+		}
+	}
+	if got := int32(call(t, inst, "div", uint64(uint32(0xFFFFFFF6)), 3)); got != -3 {
+		t.Errorf("div(-10, 3) = %d, want -3", got)
+	}
+	if _, err := inst.Call("div", 1, 0); !errors.Is(err, ErrTrap) {
+		t.Errorf("div(1, 0) error = %v, want a trap", err)
+	}
+}
+
+func TestMemoryAndImports(t *testing.T) {
+	tm := testModule{
+		types: [][]byte{
+			functype([]byte{I32, I32}, nil),
+			functype([]byte{I32}, []byte{I32}),
+		},
+		imports: [][]byte{append(append(str("env"), str("log")...), 0x00, 0)},
+		funcs:   []uint32{1, 1},
+		bodies: [][]byte{
+			// greet: log the data segment, store the argument after it, load it back
+			body(nil,
+				0x41, 0, 0x41, 5, 0x10, 0,
+				0x41, 8, 0x20, 0, 0x36, 2, 0,
+				0x41, 0, 0x28, 2, 8,
+				0x0B),
+			// peek: i32.load8_u at the address
+			body(nil, 0x20, 0, 0x2D, 0, 0, 0x0B),
+		},
+		memory:  []byte{0x01, 1, 2},
+		exports: [][]byte{exportFunc("greet", 1), exportFunc("peek", 2)},
+		data:    [][]byte{append([]byte{0, 0x41, 0, 0x0B}, str("hello")...)},
+	}
+
+	var logged []byte
+	imports := map[string]HostFunc{
+		"env.log": func(inst *Instance, args []uint64) ([]uint64, error) {
+			b, ok := inst.Read(uint32(args[0]), uint32(args[1]))
+			if !ok {
+				return nil, errors.New("bad pointer")
+			}
+			logged = append(logged, b...)
+			return nil, nil
+		},
+	}
+	inst := instantiate(t, tm, imports, Limits{})
+
+	if got := call(t, inst, "greet", 42); got != 42 {
+		t.Errorf("greet(42) = %d, want 42", got)
+	}
+	if string(logged) != "hello" {
+		t.Errorf("logged %q, want hello", logged)
+	}
+	if got := call(t, inst, "peek", 1); got != 'e' {
+		t.Errorf("peek(1) = %q, want 'e'", rune(got))
+	}
+	if _, err := inst.Call("peek", PageSize); !errors.Is(err, ErrTrap) {
+		t.Errorf("peek past the memory error = %v, want a trap", err)
+	}
+
+	m, _ := Compile(tm.bytes())
+	if _, err := m.Instantiate(nil, Limits{}); err == nil {
+		t.Error("Instantiate() without the import succeeded")
+	}
+	if _, err := m.Instantiate(imports, Limits{MaxPages: 0xFFFF}); err != nil {
+		t.Errorf("Instantiate() error = %v", err)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tm := testModule{
+		types: [][]byte{functype(nil, nil), functype([]byte{I32}, []byte{I32})},
+		funcs: []uint32{0, 0, 1},
+		bodies: [][]byte{
+			// spin: loop forever
+			body(nil, 0x03, 0x40, 0x0C, 0, 0x0B, 0x0B),
+			// recurse: call itself forever
+			body(nil, 0x10, 1, 0x0B),
+			// grow: memory.grow by the argument
+			body(nil, 0x20, 0, 0x40, 0, 0x0B),
+		},
+		memory:  []byte{0x00, 1},
+		exports: [][]byte{exportFunc("spin", 0), exportFunc("recurse", 1), exportFunc("grow", 2)},
+	}
+	inst := instantiate(t, tm, nil, Limits{Fuel: 10000, MaxPages: 4})
+
+	if _, err := inst.Call("spin"); !errors.Is(err, ErrOutOfFuel) {
+		t.Errorf("spin() error = %v, want ErrOutOfFuel", err)
+	}
+	if _, err := inst.Call("recurse"); !errors.Is(err, ErrTrap) {
+		t.Errorf("recurse() error = %v, want a trap", err)
+	}
+	if got := call(t, inst, "grow", 3); got != 1 {
+		t.Errorf("grow(3) = %d, want the old size 1", got)
+	}
+	if got := int32(call(t, inst, "grow", 1)); got != -1 {
+		t.Errorf("grow(1) past the limit = %d, want -1", got)
+	}
+	if len(inst.Memory()) != 4*PageSize {
+		t.Errorf("memory = %d bytes, want 4 pages", len(inst.Memory()))
+	}
+}
+
+func TestCallIndirect(t *testing.T) {
+	tm := testModule{
+		types: [][]byte{functype(nil, []byte{I32}), functype([]byte{I32}, []byte{I32})},
+		funcs: []uint32{0, 0, 1},
+		bodies: [][]byte{
+			body(nil, 0x41, 1, 0x0B),
+			body(nil, 0x41, 2, 0x0B),
+			// dispatch: call_indirect through the table
+			body(nil, 0x20, 0, 0x11, 0, 0, 0x0B),
+		},
+		table:   []byte{0x70, 0x00, 3},
+		elems:   [][]byte{append([]byte{0, 0x41, 0, 0x0B}, vec([]byte{0}, []byte{1})...)},
+		exports: [][]byte{exportFunc("dispatch", 2)},
+	}
+	inst := instantiate(t, tm, nil, Limits{})
+
+	if got := call(t, inst, "dispatch", 1); got != 2 {
+		t.Errorf("dispatch(1) = %d, want 2", got)
+	}
+	if _, err := inst.Call("dispatch", 2); !errors.Is(err, ErrTrap) {
+		t.Errorf("dispatch(2) of an empty element error = %v, want a trap", err)
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	noop := testModule{
+		types:   [][]byte{functype(nil, nil)},
+		funcs:   []uint32{0},
+		bodies:  [][]byte{body(nil, 0x0B)},
+		exports: [][]byte{exportFunc("noop", 0)},
+	}
+	valid := noop.bytes()
+	simd := noop
+	simd.bodies = [][]byte{body(nil, 0xFD, 0x0C, 0x0B)}
+	if _, err := Compile(valid); err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+
+	for name, code := range map[string][]byte{
+		"empty":     nil,
+		"text":      []byte("(module)"),
+		"truncated": valid[:len(valid)-3],
+		"version":   append([]byte("\x00asm\x02\x00\x00\x00"), valid[8:]...),
+		"simd":      simd.bytes(),
+	} {
+		if _, err := Compile(code); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Compile(%s) error = %v, want ErrInvalid", name, err)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Package geoip resolves client addresses to a country and autonomous
+// system using MaxMind-compatible (MMDB) databases.
+package geoip
+
+import (
+	"net/netip"
+)
+
+// Info is the geo data known for an address. Fields are empty when the
+// address is not covered by the configured databases.
+type Info struct {
+	Country string // ISO 3166-1 alpha-2 code, e.g. "DE"
+	ASN     uint   // Autonomous system number
+	ASOrg   string // Autonomous system organization
+}
+
+// Resolver combines a country (or city) database with an optional ASN
+// database. Databases that contain both, such as DB-IP Lite or IPinfo,
+// can be used as the country database alone. A nil Resolver resolves nothing.
+type Resolver struct {
+	country *Reader
+	asn     *Reader
+}
+
+// NewResolver opens the databases at the given paths. Either may be empty.
+func NewResolver(countryPath, asnPath string) (*Resolver, error) {
+	r := &Resolver{}
+	var err error
+	if countryPath != "" {
+		if r.country, err = Open(countryPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if r.asn, err = Open(asnPath); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Lookup resolves an IP address given as a string. Invalid addresses and
+// lookup errors yield an empty Info.
+func (r *Resolver) Lookup(ip string) Info {
+	var info Info
+	if r == nil {
+		return info
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return info
+	}
+	for _, db := range []*Reader{r.country, r.asn} {
+		if db == nil {
+			continue
+		}
+		record, err := db.Lookup(addr)
+		if err != nil {
+			continue
+		}
+		info.fill(record)
+	}
+	return info
+}
+
+// fill copies the fields found in a database record, keeping values
+// already set by an earlier database.
+func (i *Info) fill(record any) {
+	m, ok := record.(map[string]any)
+	if !ok {
+		return
+	}
+	if i.Country == "" {
+		for _, key := range []string{"country", "registered_country"} {
+			if country, ok := m[key].(map[string]any); ok {
+				if code, ok := country["iso_code"].(string); ok && code != "" {
+					i.Country = code
+					break
+				}
+			}
+		}
+		// Flat layouts such as IPinfo use a top-level "country" code
+		if code, ok := m["country"].(string); ok && i.Country == "" {
+			i.Country = code
+		}
+	}
+	if i.ASN == 0 {
+		if n, ok := m["autonomous_system_number"].(uint64); ok {
+			i.ASN = uint(n)
+		}
+	}
+	if i.ASOrg == "" {
+		if org, ok := m["autonomous_system_organization"].(string); ok {
+			i.ASOrg = org
+		}
+	}
+}
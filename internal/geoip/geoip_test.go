@@ -0,0 +1,179 @@
+package geoip
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// encode serializes a value in the MMDB data section format.
+func encode(buf *bytes.Buffer, v any) {
+	header := func(typ, size int) {
+		var ext []byte
+		if size >= 29 {
+			ext = []byte{byte(size - 29)}
+			size = 29
+		}
+		buf.WriteByte(byte(typ<<5 | size))
+		buf.Write(ext)
+	}
+	switch v := v.(type) {
+	case string:
+		header(typeString, len(v))
+		buf.WriteString(v)
+	case uint32:
+		b := []byte{byte(v >> 24), byte(v >> 16), byte(v >> 8), byte(v)}
+		for len(b) > 0 && b[0] == 0 {
+			b = b[1:]
+		}
+		header(typeUint32, len(b))
+		buf.Write(b)
+	case map[string]any:
+		header(typeMap, len(v))
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			encode(buf, k)
+			encode(buf, v[k])
+		}
+	default:
+		panic("unsupported test value")
+	}
+}
+
+// buildDB writes a database with record size 24 mapping networks to records.
+func buildDB(t *testing.T, ipVersion uint32, networks map[string]map[string]any) []byte {
+	t.Helper()
+
+	type node struct{ child [2]int } // >0 node index, <0 -(data offset+1), 0 empty
+	nodes := []node{{}}
+	var data bytes.Buffer
+
+	for cidr, record := range networks {
+		prefix := netip.MustParsePrefix(cidr)
+		ip := prefix.Addr().AsSlice()
+		bits := prefix.Bits()
+		if ipVersion == 6 && prefix.Addr().Is4() {
+			ip = append(make([]byte, 12), ip...)
+			bits += 96
+		}
+		offset := data.Len()
+		encode(&data, record)
+
+		n := 0
+		for i := 0; i < bits; i++ {
+			bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+			if i == bits-1 {
+				nodes[n].child[bit] = -(offset + 1)
+				break
+			}
+			if nodes[n].child[bit] <= 0 {
+				nodes = append(nodes, node{})
+				nodes[n].child[bit] = len(nodes) - 1
+			}
+			n = nodes[n].child[bit]
+		}
+	}
+
+	var out bytes.Buffer
+	count := len(nodes)
+	for _, n := range nodes {
+		for _, c := range n.child {
+			v := count // Empty
+			if c > 0 {
+				v = c
+			} else if c < 0 {
+				v = count + dataSectionSeparator + (-c - 1)
+			}
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	out.Write(make([]byte, dataSectionSeparator))
+	out.Write(data.Bytes())
+	out.Write(metadataMarker)
+	encode(&out, map[string]any{
+		"node_count":    uint32(count),
+		"record_size":   uint32(24),
+		"ip_version":    ipVersion,
+		"database_type": "Test",
+	})
+	return out.Bytes()
+}
+
+var testNetworks = map[string]map[string]any{
+	"10.0.0.0/8": {
+		"country":                        map[string]any{"iso_code": "DE"},
+		"autonomous_system_number":       uint32(64500),
+		"autonomous_system_organization": "Example Net",
+	},
+	"192.168.1.0/24": {
+		"registered_country": map[string]any{"iso_code": "RU"},
+	},
+}
+
+func TestReader_Lookup(t *testing.T) {
+	for _, version := range []uint32{4, 6} {
+		r, err := NewReader(buildDB(t, version, testNetworks))
+		if err != nil {
+			t.Fatalf("v%d: NewReader: %v", version, err)
+		}
+		if r.Type != "Test" {
+			t.Errorf("v%d: Type = %q", version, r.Type)
+		}
+
+		record, err := r.Lookup(netip.MustParseAddr("10.1.2.3"))
+		if err != nil {
+			t.Fatalf("v%d: Lookup: %v", version, err)
+		}
+		m, _ := record.(map[string]any)
+		if m["autonomous_system_organization"] != "Example Net" {
+			t.Errorf("v%d: record = %v", version, record)
+		}
+
+		if record, err := r.Lookup(netip.MustParseAddr("172.16.0.1")); err != nil || record != nil {
+			t.Errorf("v%d: uncovered address = %v, %v", version, record, err)
+		}
+	}
+}
+
+func TestResolver_Lookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, buildDB(t, 6, testNetworks), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewResolver(path, "")
+	if err != nil {
+		t.Fatalf("NewResolver: %v", err)
+	}
+
+	tests := map[string]Info{
+		"10.1.2.3":        {Country: "DE", ASN: 64500, ASOrg: "Example Net"},
+		"::ffff:10.1.2.3": {Country: "DE", ASN: 64500, ASOrg: "Example Net"},
+		"192.168.1.7":     {Country: "RU"},
+		"192.168.2.7":     {},
+		"not an address":  {},
+		"2001:db8::1":     {},
+	}
+	for ip, want := range tests {
+		if got := r.Lookup(ip); got != want {
+			t.Errorf("Lookup(%q) = %+v, want %+v", ip, got, want)
+		}
+	}
+
+	var disabled *Resolver
+	if got := disabled.Lookup("10.1.2.3"); got != (Info{}) {
+		t.Errorf("nil resolver = %+v", got)
+	}
+}
+
+func TestNewReader_Invalid(t *testing.T) {
+	if _, err := NewReader([]byte("not a database")); err == nil {
+		t.Error("expected error for missing metadata")
+	}
+}
@@ -0,0 +1,309 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of an MMDB file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSectionSeparator is the gap of zero bytes between tree and data.
+const dataSectionSeparator = 16
+
+var errInvalidDatabase = errors.New("geoip: invalid database")
+
+// Reader looks up records in a MaxMind DB (MMDB) file, the format used by
+// GeoLite2, GeoIP2, DB-IP and IPinfo databases. The whole file is held in memory.
+type Reader struct {
+	buf        []byte
+	data       []byte // Data section
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint   // Node for ::/96, where IPv4 addresses live in an IPv6 tree
+	Type       string // database_type from the metadata, e.g. "GeoLite2-Country"
+}
+
+// Open reads an MMDB file.
+func Open(path string) (*Reader, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewReader(buf)
+}
+
+// NewReader parses an MMDB database held in memory.
+func NewReader(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: metadata not found", errInvalidDatabase)
+	}
+	meta, _, err := decoder{buf: buf[i+len(metadataMarker):]}.decode(0, 0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %v", errInvalidDatabase, err)
+	}
+	m, ok := meta.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", errInvalidDatabase)
+	}
+
+	r := &Reader{
+		buf:        buf,
+		nodeCount:  uint(toUint(m["node_count"])),
+		recordSize: uint(toUint(m["record_size"])),
+		ipVersion:  uint(toUint(m["ip_version"])),
+	}
+	r.Type, _ = m["database_type"].(string)
+
+	switch r.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", errInvalidDatabase, r.recordSize)
+	}
+	treeSize := r.nodeCount * r.recordSize / 4
+	if treeSize+dataSectionSeparator > uint(i) {
+		return nil, fmt.Errorf("%w: search tree exceeds file", errInvalidDatabase)
+	}
+	r.data = buf[treeSize+dataSectionSeparator : i]
+
+	if r.ipVersion == 6 {
+		node := uint(0)
+		for n := 0; n < 96 && node < r.nodeCount; n++ {
+			node = r.record(node, 0)
+		}
+		r.ipv4Start = node
+	}
+	return r, nil
+}
+
+// Lookup returns the record for an address, or nil if there is none.
+func (r *Reader) Lookup(addr netip.Addr) (any, error) {
+	addr = addr.Unmap()
+	node := uint(0)
+	bits := 128
+	if addr.Is4() {
+		bits = 32
+		if r.ipVersion == 6 {
+			node = r.ipv4Start
+		}
+	} else if r.ipVersion == 4 {
+		return nil, nil
+	}
+
+	ip := addr.AsSlice()
+	for i := 0; i < bits && node < r.nodeCount; i++ {
+		bit := (ip[i/8] >> (7 - uint(i%8))) & 1
+		node = r.record(node, uint(bit))
+	}
+
+	switch {
+	case node == r.nodeCount:
+		return nil, nil
+	case node < r.nodeCount:
+		return nil, fmt.Errorf("%w: address not resolved", errInvalidDatabase)
+	}
+	offset := node - r.nodeCount - dataSectionSeparator
+	if offset >= uint(len(r.data)) {
+		return nil, fmt.Errorf("%w: data pointer out of range", errInvalidDatabase)
+	}
+	value, _, err := decoder{buf: r.data}.decode(offset, 0)
+	return value, err
+}
+
+// record reads the left (0) or right (1) record of a search tree node.
+func (r *Reader) record(node, side uint) uint {
+	b := r.buf[node*r.recordSize/4:]
+	switch r.recordSize {
+	case 24:
+		b = b[side*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+	case 28:
+		if side == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6])
+	default:
+		return uint(binary.BigEndian.Uint32(b[side*4:]))
+	}
+}
+
+// Data section field types.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// maxDepth bounds nesting to reject malicious or corrupt databases.
+const maxDepth = 32
+
+// decoder decodes values of the MMDB data section.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset following it.
+func (d decoder) decode(offset uint, depth int) (any, uint, error) {
+	if depth > maxDepth {
+		return nil, 0, errors.New("data nested too deeply")
+	}
+	typ, size, offset, err := d.header(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if typ == typePointer {
+		value, _, err := d.decode(size, depth+1)
+		return value, offset, err
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]any, size)
+		for range size {
+			var key, value any
+			if key, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("map key is not a string")
+			}
+			m[k] = value
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]any, 0, min(size, 1024))
+		for range size {
+			var value any
+			if value, offset, err = d.decode(offset, depth+1); err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	}
+
+	end := offset + size
+	if end > uint(len(d.buf)) || end < offset {
+		return nil, 0, errors.New("value exceeds data section")
+	}
+	b := d.buf[offset:end]
+
+	switch typ {
+	case typeString:
+		return string(b), end, nil
+	case typeBytes:
+		return bytes.Clone(b), end, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("invalid double size")
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), end, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("invalid float size")
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), end, nil
+	case typeUint16, typeUint32, typeUint64:
+		if size > 8 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		var n uint64
+		for _, c := range b {
+			n = n<<8 | uint64(c)
+		}
+		return n, end, nil
+	case typeInt32:
+		if size > 4 {
+			return nil, 0, errors.New("invalid integer size")
+		}
+		var n uint32
+		for _, c := range b {
+			n = n<<8 | uint32(c)
+		}
+		return int64(int32(n)), end, nil
+	case typeUint128:
+		return bytes.Clone(b), end, nil
+	default:
+		return nil, 0, fmt.Errorf("unsupported data type %d", typ)
+	}
+}
+
+// header decodes a control byte sequence. For pointers, size is the
+// target offset.
+func (d decoder) header(offset uint) (typ, size, next uint, err error) {
+	next = offset
+	readByte := func() uint {
+		if next >= uint(len(d.buf)) {
+			err = errors.New("unexpected end of data")
+			return 0
+		}
+		b := d.buf[next]
+		next++
+		return uint(b)
+	}
+
+	ctrl := readByte()
+	typ = ctrl >> 5
+
+	if typ == typePointer {
+		ss, vvv := (ctrl>>3)&0x3, ctrl&0x7
+		switch ss {
+		case 0:
+			size = vvv<<8 | readByte()
+		case 1:
+			size = (vvv<<16 | readByte()<<8 | readByte()) + 2048
+		case 2:
+			size = (vvv<<24 | readByte()<<16 | readByte()<<8 | readByte()) + 526336
+		default:
+			size = readByte()<<24 | readByte()<<16 | readByte()<<8 | readByte()
+		}
+		return typ, size, next, err
+	}
+
+	if typ == typeExtended {
+		typ = 7 + readByte()
+	}
+	size = ctrl & 0x1f
+	switch size {
+	case 29:
+		size = 29 + readByte()
+	case 30:
+		size = 285 + (readByte()<<8 | readByte())
+	case 31:
+		size = 65821 + (readByte()<<16 | readByte()<<8 | readByte())
+	}
+	return typ, size, next, err
+}
+
+// toUint converts a decoded unsigned integer.
+func toUint(v any) uint64 {
+	n, _ := v.(uint64)
+	return n
+}
@@ -0,0 +1,17 @@
+package ingress
+
+import (
+	"crypto/subtle"
+	"net/http"
+)
+
+// basicAuthorized reports whether the request carries the tunnel's
+// "user:pass" credentials.
+func basicAuthorized(req *http.Request, creds string) bool {
+	user, pass, ok := req.BasicAuth()
+	if !ok {
+		return false
+	}
+	// Compare in constant time so the response time leaks nothing
+	return subtle.ConstantTimeCompare([]byte(user+":"+pass), []byte(creds)) == 1
+}
@@ -0,0 +1,85 @@
+package ingress
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"time"
+
+	"gopublic/internal/capture"
+	"gopublic/internal/storage"
+)
+
+// LoadCaptureDomains loads the domains whose exchanges the ingress records.
+func (i *Ingress) LoadCaptureDomains() error {
+	if i.Captures == nil {
+		return nil
+	}
+	domains, err := storage.GetCaptureDomains()
+	if err != nil {
+		return err
+	}
+	hosts := make([]string, 0, len(domains))
+	for _, d := range domains {
+		host := d.Name
+		if i.RootDomain != "" {
+			host = d.Name + "." + i.RootDomain
+		}
+		hosts = append(hosts, host)
+	}
+	i.Captures.SetDomains(hosts)
+	return nil
+}
+
+// capturedBody keeps the first max bytes written to it and counts the rest.
+type capturedBody struct {
+	max  int
+	buf  bytes.Buffer
+	size int64
+}
+
+func (b *capturedBody) Write(p []byte) (int, error) {
+	b.size += int64(len(p))
+	if room := b.max + 1 - b.buf.Len(); room > 0 {
+		b.buf.Write(p[:min(room, len(p))])
+	}
+	return len(p), nil
+}
+
+// requestBody reads up to max bytes of the body of a serialized request,
+// undoing chunked framing.
+func requestBody(raw []byte, max int) string {
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		return ""
+	}
+	body, _ := io.ReadAll(io.LimitReader(req.Body, int64(max)+1))
+	return string(body)
+}
+
+// responseBody returns the captured response body, or "" for compressed
+// bodies, which would only show as binary data.
+func responseBody(resp *http.Response, body *capturedBody) string {
+	if enc := resp.Header.Get("Content-Encoding"); enc != "" && enc != "identity" {
+		return ""
+	}
+	return body.buf.String()
+}
+
+// recordExchange adds a proxied exchange to the capture log of host.
+func (i *Ingress) recordExchange(host string, req *http.Request, raw []byte, resp *http.Response, respBody *capturedBody, start time.Time) {
+	i.Captures.Record(host, capture.Exchange{
+		Time:            start,
+		Method:          req.Method,
+		URL:             req.URL.RequestURI(),
+		Status:          resp.StatusCode,
+		DurationMS:      time.Since(start).Milliseconds(),
+		RequestHeaders:  req.Header,
+		RequestBody:     requestBody(raw, i.Captures.MaxBody()),
+		RequestSize:     max(req.ContentLength, 0),
+		ResponseHeaders: resp.Header,
+		ResponseBody:    responseBody(resp, respBody),
+		ResponseSize:    respBody.size,
+	})
+}
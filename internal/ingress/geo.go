@@ -0,0 +1,73 @@
+package ingress
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/geoip"
+	"gopublic/internal/storage"
+)
+
+// Geo headers forwarded to the tunnel client. Values sent by the caller
+// are always removed so that they cannot be spoofed.
+const (
+	CountryHeader = "X-Gopublic-Country"
+	ASNHeader     = "X-Gopublic-ASN"
+)
+
+// Context keys read by the access log.
+const (
+	geoCountryKey = "geo_country"
+	geoASNKey     = "geo_asn"
+)
+
+// annotateGeo resolves the caller's address and attaches the result to the
+// forwarded request and the access log.
+func (i *Ingress) annotateGeo(c *gin.Context) geoip.Info {
+	c.Request.Header.Del(CountryHeader)
+	c.Request.Header.Del(ASNHeader)
+
+	info := i.GeoIP.Lookup(c.ClientIP())
+	if info.Country != "" {
+		c.Request.Header.Set(CountryHeader, info.Country)
+		c.Set(geoCountryKey, info.Country)
+	}
+	if info.ASN != 0 {
+		c.Request.Header.Set(ASNHeader, strconv.FormatUint(uint64(info.ASN), 10))
+		c.Set(geoASNKey, info.ASN)
+	}
+	return info
+}
+
+// recordGeo counts the request towards the owner's per-country usage.
+func (i *Ingress) recordGeo(userID uint, country string, bytes int64) {
+	if err := storage.AddUserGeoUsage(userID, country, bytes); err != nil {
+		log.Printf("Failed to record geo usage for user %d: %v", userID, err)
+	}
+}
+
+// accessLogFormatter is gin's default access log line without colors,
+// extended with the caller's country and ASN when known.
+func accessLogFormatter(param gin.LogFormatterParams) string {
+	country, asn := "-", "-"
+	if v, ok := param.Keys[geoCountryKey].(string); ok {
+		country = v
+	}
+	if v, ok := param.Keys[geoASNKey].(uint); ok {
+		asn = "AS" + strconv.FormatUint(uint64(v), 10)
+	}
+	return fmt.Sprintf("[GIN] %v | %3d | %13v | %15s | %2s %-9s | %-7s %#v\n%s",
+		param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+		param.StatusCode,
+		param.Latency,
+		param.ClientIP,
+		country,
+		asn,
+		param.Method,
+		param.Path,
+		param.ErrorMessage,
+	)
+}
@@ -0,0 +1,52 @@
+package ingress
+
+import (
+	"sync"
+
+	"gopublic/internal/storage"
+)
+
+// hubSet maps hub hostnames to their owner. It is loaded from the database
+// at startup and reloaded whenever a user chooses or removes a hub.
+type hubSet struct {
+	mu    sync.RWMutex
+	hosts map[string]uint
+}
+
+func (s *hubSet) replace(hosts map[string]uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hosts = hosts
+}
+
+func (s *hubSet) remove(host string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.hosts, host)
+}
+
+// owner returns the user whose hub host is.
+func (s *hubSet) owner(host string) (uint, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	userID, ok := s.hosts[host]
+	return userID, ok
+}
+
+// LoadHubDomains loads the hub domains of all users.
+func (i *Ingress) LoadHubDomains() error {
+	domains, err := storage.GetHubDomains()
+	if err != nil {
+		return err
+	}
+	hosts := make(map[string]uint, len(domains))
+	for _, d := range domains {
+		host := d.Name
+		if i.RootDomain != "" {
+			host = d.Name + "." + i.RootDomain
+		}
+		hosts[host] = d.UserID
+	}
+	i.hubs.replace(hosts)
+	return nil
+}
@@ -1,21 +1,34 @@
 package ingress
 
 import (
-	"bufio"
 	"bytes"
+	"errors"
 	"io"
 	"log"
 	"net/http"
 	"os"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	sentrygin "github.com/getsentry/sentry-go/gin"
 
+	"gopublic/internal/billing"
+	"gopublic/internal/bufpool"
+	"gopublic/internal/capture"
 	"gopublic/internal/config"
 	"gopublic/internal/dashboard"
+	"gopublic/internal/errorpage"
+	"gopublic/internal/filter"
+	"gopublic/internal/geoip"
+	"gopublic/internal/interstitial"
+	"gopublic/internal/metrics"
 	"gopublic/internal/middleware"
+	"gopublic/internal/models"
+	"gopublic/internal/notify"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/scan"
 	"gopublic/internal/sentry"
 	"gopublic/internal/server"
 	"gopublic/internal/storage"
@@ -30,12 +43,27 @@ type Ingress struct {
 	Registry            *server.TunnelRegistry
 	DashHandler         *dashboard.Handler
 	Port                string
-	RootDomain          string // Root domain for routing
-	ProjectName         string // Project name for branding
-	IsSecure            bool   // Whether running in secure mode
-	GitHubRepo          string // GitHub repo for client downloads (e.g., "username/gopublic")
-	DailyBandwidthLimit int64  // Daily bandwidth limit per user in bytes (0 = unlimited)
-	SentryEnabled       bool   // Whether Sentry is configured
+	RootDomain          string                 // Root domain for routing
+	ProjectName         string                 // Project name for branding
+	IsSecure            bool                   // Whether running in secure mode
+	GitHubRepo          string                 // GitHub repo for client downloads (e.g., "username/gopublic")
+	DailyBandwidthLimit int64                  // Daily bandwidth limit per user in bytes (0 = unlimited)
+	PlanBandwidth       billing.PlanLimits     // Per-plan overrides of DailyBandwidthLimit (optional)
+	SentryEnabled       bool                   // Whether Sentry is configured
+	Filters             filter.Chain           // Traffic filters applied before proxying (optional)
+	Events              pubsub.Bus             // Cross-instance event bus (optional)
+	Latency             *metrics.DomainLatency // Per-domain response latency (optional)
+	RetryIdempotent     bool                   // Retry GET/HEAD once on a new stream if the first one fails
+	Interstitial        *interstitial.Policy   // Plans that show a phishing warning (nil = disabled)
+	Scan                *scan.Hook             // Scans large request bodies (optional)
+	GeoIP               *geoip.Resolver        // Resolves caller country and ASN (optional)
+	Notifier            *notify.Notifier       // Sends quota usage alerts to users (optional)
+	Captures            *capture.Log           // Recent exchanges of domains with capture on (optional)
+
+	overQuota quotaCache   // Users over today's bandwidth limit
+	suspended suspendedSet // Domains taken down by an administrator
+	schedules scheduleSet  // Domains restricted to time windows
+	hubs      hubSet       // Domains listing their owner's online tunnels
 }
 
 // NewIngressWithConfig creates a new ingress with the given configuration.
@@ -49,7 +77,10 @@ func NewIngressWithConfig(cfg *config.Config, registry *server.TunnelRegistry, d
 		IsSecure:            cfg.IsSecure(),
 		GitHubRepo:          cfg.GitHubRepo,
 		DailyBandwidthLimit: cfg.DailyBandwidthLimit,
+		PlanBandwidth:       cfg.PlanBandwidthLimits,
 		SentryEnabled:       cfg.HasSentry(),
+		RetryIdempotent:     cfg.RetryIdempotentRequests,
+		Interstitial:        interstitial.NewPolicy(cfg.InterstitialPlans),
 	}
 }
 
@@ -73,12 +104,13 @@ func (i *Ingress) Handler() http.Handler {
 	// Set Gin to release mode
 	gin.SetMode(gin.ReleaseMode)
 
-	r := gin.Default()
+	r := gin.New()
+	r.Use(gin.LoggerWithFormatter(accessLogFormatter), gin.Recovery())
 
 	// Add Sentry middleware if configured (must be before other middleware to capture panics)
 	if i.SentryEnabled {
 		r.Use(sentrygin.New(sentrygin.Options{
-			Repanic: true, // Let gin.Default's Recovery handle the response
+			Repanic: true, // Let gin's Recovery handle the response
 		}))
 	}
 
@@ -148,14 +180,15 @@ func (i *Ingress) Handler() http.Handler {
 
 func (i *Ingress) Start() error {
 	log.Printf("Public Ingress listening on %s (HTTP)", i.Port)
-	return http.ListenAndServe(i.Port, i.Handler())
+	srv := &http.Server{Addr: i.Port, Handler: i.Handler(), MaxHeaderBytes: MaxHeaderBytes}
+	return srv.ListenAndServe()
 }
 
 // handleRequest routes incoming requests to the appropriate handler.
 func (i *Ingress) handleRequest(c *gin.Context) {
 	host, valid := i.parseAndValidateHost(c.Request.Host)
 	if !valid {
-		c.String(http.StatusBadRequest, "Invalid host header")
+		errorpage.Render(c, http.StatusBadRequest, errorpage.CodeInvalidHost)
 		return
 	}
 
@@ -165,6 +198,8 @@ func (i *Ingress) handleRequest(c *gin.Context) {
 		i.serveLandingPage(c)
 	case i.isDashboardHost(host):
 		i.serveDashboard(c)
+	case i.isStatusHost(host):
+		i.serveStatusPage(c)
 	default:
 		i.proxyToTunnel(c, host)
 	}
@@ -225,6 +260,21 @@ func (i *Ingress) isDashboardHost(host string) bool {
 	return host == "app."+i.RootDomain
 }
 
+// isStatusHost returns true if the host serves public status pages.
+func (i *Ingress) isStatusHost(host string) bool {
+	return !i.isLocalDev() && host == "status."+i.RootDomain
+}
+
+// serveStatusPage renders a user's status page at /u/<name>.
+func (i *Ingress) serveStatusPage(c *gin.Context) {
+	slug, ok := strings.CutPrefix(c.Request.URL.Path, "/u/")
+	if !ok || slug == "" || strings.Contains(slug, "/") || c.Request.Method != http.MethodGet {
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
+		return
+	}
+	i.DashHandler.StatusPage(c, slug)
+}
+
 // serveLandingPage renders the public landing page or install scripts.
 func (i *Ingress) serveLandingPage(c *gin.Context) {
 	switch c.Request.URL.Path {
@@ -258,7 +308,7 @@ func (i *Ingress) serveLandingPage(c *gin.Context) {
 // serveInstallSh serves the bash install script for macOS/Linux.
 func (i *Ingress) serveInstallSh(c *gin.Context) {
 	if i.GitHubRepo == "" {
-		c.String(http.StatusNotFound, "Install script not available: GITHUB_REPO not configured")
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
 		return
 	}
 
@@ -342,7 +392,7 @@ esac
 // serveInstallPs1 serves the PowerShell install script for Windows.
 func (i *Ingress) serveInstallPs1(c *gin.Context) {
 	if i.GitHubRepo == "" {
-		c.String(http.StatusNotFound, "Install script not available: GITHUB_REPO not configured")
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
 		return
 	}
 
@@ -361,6 +411,11 @@ Write-Host "Done! Downloaded: .\gopublic.exe"
 
 // serveDashboard routes requests to dashboard handlers.
 func (i *Ingress) serveDashboard(c *gin.Context) {
+	if strings.HasPrefix(c.Request.URL.Path, "/api/v1/") {
+		i.serveAPIv1(c)
+		return
+	}
+
 	switch c.Request.URL.Path {
 	case "/":
 		i.DashHandler.Index(c)
@@ -378,18 +433,334 @@ func (i *Ingress) serveDashboard(c *gin.Context) {
 		} else {
 			i.DashHandler.AbuseForm(c)
 		}
+	case "/tunnels":
+		i.DashHandler.Tunnels(c)
+	case "/api/tunnels":
+		i.DashHandler.TunnelsAPI(c)
+	case "/devices":
+		i.DashHandler.Devices(c)
+	case "/api/devices":
+		i.DashHandler.DevicesAPI(c)
+	case "/api/fleet":
+		i.DashHandler.FleetAPI(c)
+	case "/api/fleet/command":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.FleetCommand)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/join-tokens":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.JoinTokensAPI(c)
+		case http.MethodPost:
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.RequireConfirmation(dashboard.ActionCreateJoinToken, i.DashHandler.CreateJoinToken))(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/join-tokens/delete":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.DeleteJoinToken)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/api-tokens":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.APITokensAPI(c)
+		case http.MethodPost:
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.RequireConfirmation(dashboard.ActionCreateAPIToken, i.DashHandler.CreateAPIToken))(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/api-tokens/delete":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.DeleteAPIToken)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/definitions":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.DefinitionsAPI(c)
+		case http.MethodPost:
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.CreateDefinition)(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/definitions/delete":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.DeleteDefinition)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/devices/revoke":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.RequireConfirmation(dashboard.ActionRegenerateToken, i.DashHandler.RevokeDevice))(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/admin/abuse":
+		i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.AbuseReports)(c)
+	case "/api/abuse-reports":
+		i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.AbuseReportsAPI)(c)
+	case "/api/abuse-reports/status":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.UpdateAbuseReport)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/suspend":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.SuspendDomain)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/unsuspend":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.UnsuspendDomain)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/admin/signups":
+		i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.Signups)(c)
+	case "/api/signups":
+		i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.SignupsAPI)(c)
+	case "/api/signups/approve":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.ApproveSignup)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/users/role":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.SetUserRole)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/admin/users":
+		i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.Users)(c)
+	case "/api/users":
+		i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.UsersAPI)(c)
+	case "/api/users/revoke-tokens":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.RevokeUserTokens)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/users/ban":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.RequireConfirmation(dashboard.ActionBanUser, i.DashHandler.BanUser))(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/users/unban":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.UnbanUser)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/signups/reject":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleAdmin, i.DashHandler.RejectSignup)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/invites":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.InvitesAPI(c)
+		case http.MethodPost:
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.CreateInvite)(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/orgs":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.OrganizationsAPI(c)
+		case http.MethodPost:
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.CreateOrganization)(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/orgs/members":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.RequireOrgRole(models.RoleViewer, i.DashHandler.OrganizationMembersAPI)(c)
+		case http.MethodPost:
+			i.DashHandler.RequireOrgRole(models.RoleAdmin, i.DashHandler.AddOrganizationMember)(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/orgs/members/role":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireOrgRole(models.RoleAdmin, i.DashHandler.SetOrganizationRole)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/orgs/members/remove":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireOrgRole(models.RoleViewer, i.DashHandler.RemoveOrganizationMember)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/billing/checkout":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.BillingCheckout)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/billing/webhook":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.BillingWebhook(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/notifications":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.UpdateNotifications(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/alerts":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.AlertsAPI(c)
+		case http.MethodPost:
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.CreateAlert)(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/alerts/delete":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.DeleteAlert)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
 	case "/api/regenerate-token":
 		if c.Request.Method == http.MethodPost {
-			i.DashHandler.RegenerateToken(c)
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.RequireConfirmation(dashboard.ActionRegenerateToken, i.DashHandler.RegenerateToken))(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/status-page":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.UpdateStatusPage)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/schedule":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.SetDomainSchedule)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/hub":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.SetHubDomain)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/capture":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.SetDomainCapture)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/captures":
+		i.DashHandler.CapturesAPI(c)
+	case "/api/domains/history":
+		i.DashHandler.ConnectionHistoryAPI(c)
+	case "/api/domains/check":
+		i.DashHandler.CheckDomainAPI(c)
+	case "/api/domains/reserve":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.ReserveDomain)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/release":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.RequireConfirmation(dashboard.ActionReleaseDomain, i.DashHandler.ReleaseDomain))(c)
 		} else {
-			c.String(http.StatusMethodNotAllowed, "Method Not Allowed")
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/transfer":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.RequireConfirmation(dashboard.ActionTransferDomain, i.DashHandler.TransferDomain))(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/transfer/accept":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.AcceptDomainTransfer)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/domains/transfer/decline":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireRole(models.RoleMember, i.DashHandler.DeclineDomainTransfer)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/account/delete":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireConfirmation(dashboard.ActionDeleteAccount, i.DashHandler.DeleteAccount)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/totp/setup":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireConfirmation(dashboard.ActionEnableTOTP, i.DashHandler.TOTPSetup)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/totp/enable":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireConfirmation(dashboard.ActionEnableTOTP, i.DashHandler.TOTPEnable)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/api/totp/disable":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.RequireConfirmation(dashboard.ActionDisableTOTP, i.DashHandler.TOTPDisable)(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
 		}
 	case "/api/accept-terms":
 		if c.Request.Method == http.MethodPost {
 			i.DashHandler.AcceptTerms(c)
 		} else {
-			c.String(http.StatusMethodNotAllowed, "Method Not Allowed")
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/auth/sso":
+		i.DashHandler.SSOAuth(c)
+	case "/auth/sso/callback":
+		i.DashHandler.SSOCallback(c)
+	case "/auth/email":
+		if c.Request.Method == http.MethodPost {
+			i.DashHandler.EmailLoginRequest(c)
+		} else {
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
 		}
+	case "/auth/email/callback":
+		switch c.Request.Method {
+		case http.MethodGet:
+			i.DashHandler.EmailLoginCallback(c)
+		case http.MethodPost:
+			i.DashHandler.EmailLoginConfirm(c)
+		default:
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		}
+	case "/auth/github":
+		i.DashHandler.GitHubAuth(c)
+	case "/auth/github/callback":
+		i.DashHandler.GitHubCallback(c)
+	case "/auth/google":
+		i.DashHandler.GoogleAuth(c)
+	case "/auth/google/callback":
+		i.DashHandler.GoogleCallback(c)
 	case "/auth/yandex":
 		i.DashHandler.YandexAuth(c)
 	case "/auth/yandex/callback":
@@ -400,73 +771,230 @@ func (i *Ingress) serveDashboard(c *gin.Context) {
 		if c.Request.Method == http.MethodPost {
 			i.DashHandler.YandexTokenAuth(c)
 		} else {
-			c.String(http.StatusMethodNotAllowed, "Method Not Allowed")
+			errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
 		}
 	case "/link/telegram":
 		i.DashHandler.LinkTelegram(c)
 	case "/auth/telegram/link":
 		i.DashHandler.TelegramLinkCallback(c)
 	default:
-		c.String(http.StatusNotFound, "Not Found")
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
+	}
+}
+
+// serveAPIv1 routes requests to the public API: GET and POST on
+// collections (/api/v1/domains), GET and DELETE on their items
+// (/api/v1/domains/42). Changes honour an Idempotency-Key header.
+func (i *Ingress) serveAPIv1(c *gin.Context) {
+	path := strings.TrimPrefix(c.Request.URL.Path, "/api/v1/")
+	if path == "openapi.yaml" {
+		i.DashHandler.APIv1Spec(c)
+		return
+	}
+
+	name, id, item := strings.Cut(path, "/")
+	resource, ok := i.DashHandler.APIResources()[name]
+	if !ok || (item && (id == "" || strings.Contains(id, "/") || resource.Get == nil)) {
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeNotFound)
+		return
+	}
+
+	var handler gin.HandlerFunc
+	switch {
+	case !item && c.Request.Method == http.MethodGet:
+		handler = resource.List
+	case !item && c.Request.Method == http.MethodPost && resource.Create != nil:
+		handler = i.DashHandler.Idempotent(resource.Create)
+	case item && c.Request.Method == http.MethodGet:
+		handler = resource.Get
+	case item && c.Request.Method == http.MethodDelete && resource.Delete != nil:
+		handler = i.DashHandler.Idempotent(resource.Delete)
+	}
+	if handler == nil {
+		errorpage.Render(c, http.StatusMethodNotAllowed, errorpage.CodeMethodNotAllowed)
+		return
+	}
+	if item {
+		c.Params = append(c.Params, gin.Param{Key: "id", Value: id})
 	}
+	i.DashHandler.RequireAPIToken(handler)(c)
 }
 
 // proxyToTunnel forwards the request to a tunnel client.
 func (i *Ingress) proxyToTunnel(c *gin.Context, host string) {
+	// Nothing the tunnel client could parse differently goes down the tunnel
+	if err := validateRequest(c.Request); errors.Is(err, errHeadersTooLarge) {
+		errorpage.Render(c, http.StatusRequestHeaderFieldsTooLarge, errorpage.CodeHeadersTooLarge)
+		return
+	} else if err != nil {
+		log.Printf("Rejected %s %s on %s: %v", c.Request.Method, c.Request.URL.Path, host, err)
+		errorpage.Render(c, http.StatusBadRequest, errorpage.CodeBadRequest)
+		return
+	}
+
+	if i.suspended.has(host) {
+		rejectSuspended(c)
+		return
+	}
+
+	// Outside its time windows a tunnel looks offline
+	if !i.schedules.allows(host, time.Now()) {
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeTunnelNotFound)
+		return
+	}
+
 	// Look up tunnel entry (includes user ID)
 	entry, ok := i.Registry.GetEntry(host)
 	if !ok {
-		c.String(http.StatusNotFound, "Tunnel not found for host: %s", host)
+		// A hub lists its owner's tunnels until a tunnel is bound to it
+		if userID, hub := i.hubs.owner(host); hub {
+			i.DashHandler.HubPage(c, host, userID)
+			return
+		}
+		errorpage.Render(c, http.StatusNotFound, errorpage.CodeTunnelNotFound)
+		return
+	}
+
+	// Requests over the domain's rate limit are refused before anything
+	// else, login attempts included
+	if entry.Limiter != nil && !entry.Limiter.Allow() {
+		entry.Traffic.Throttle()
+		c.Header("Retry-After", "1")
+		errorpage.Render(c, http.StatusTooManyRequests, errorpage.CodeTunnelRateLimited)
+		return
+	}
+
+	// Owners can require a login before anything reaches their tunnel
+	if entry.BasicAuth != "" {
+		if !basicAuthorized(c.Request, entry.BasicAuth) {
+			c.Header("WWW-Authenticate", `Basic realm="`+host+`", charset="UTF-8"`)
+			errorpage.Render(c, http.StatusUnauthorized, errorpage.CodeAuthRequired)
+			return
+		}
+		// The tunnel password is not for the local service
+		c.Request.Header.Del("Authorization")
+	}
+
+	geo := i.annotateGeo(c)
+
+	// Warn first-time browser visitors before showing untrusted content
+	if i.Interstitial.Applies(entry.Plan) && interstitial.Required(c.Request) {
+		interstitial.Write(c.Writer, c.Request, i.abuseReportURL(host))
 		return
 	}
 
 	// Check bandwidth limit before proxying
-	if i.DailyBandwidthLimit > 0 {
+	bandwidthLimit := i.PlanBandwidth.Bandwidth(entry.Plan, i.DailyBandwidthLimit)
+	if bandwidthLimit > 0 {
+		if i.overQuota.exceeded(entry.UserID) {
+			rejectOverQuota(c)
+			return
+		}
 		bytesUsed, err := storage.GetUserBandwidthToday(entry.UserID)
 		if err != nil {
 			log.Printf("Failed to check bandwidth for user %d: %v", entry.UserID, err)
 			// Continue anyway - don't block on DB errors
-		} else if bytesUsed >= i.DailyBandwidthLimit {
-			c.Header("Retry-After", "86400") // 24 hours
-			c.String(http.StatusTooManyRequests, "Daily bandwidth limit exceeded. Please try again tomorrow.")
+		} else if bytesUsed >= bandwidthLimit {
+			rejectOverQuota(c)
 			return
 		}
 	}
 
-	// Open stream to tunnel client
-	stream, err := entry.Session.Open()
-	if err != nil {
-		sentry.CaptureErrorWithContextf(c, err, "Failed to open stream for host %s", host)
-		c.String(http.StatusBadGateway, "Failed to connect to tunnel client")
+	// Run traffic filters before the request enters the tunnel
+	if filtered, err := i.Filters.Request(c.Request); err != nil {
+		log.Printf("Request to %s rejected by filter: %v", host, err)
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeFilterRejected)
+		return
+	} else if filtered != nil {
+		defer filtered.Body.Close()
+		for k, vv := range filtered.Header {
+			for _, v := range vv {
+				c.Writer.Header().Add(k, v)
+			}
+		}
+		c.Status(filtered.StatusCode)
+		io.Copy(c.Writer, filtered.Body)
 		return
 	}
-	defer stream.Close()
 
-	// Capture request size
+	// Submit large uploads to the content scanner
+	if verdict, err := i.Scan.Check(c.Request); err != nil {
+		log.Printf("Content scan of %s %s on %s failed: %v", c.Request.Method, c.Request.URL.Path, host, err)
+		if !i.Scan.FailOpen {
+			errorpage.Render(c, http.StatusServiceUnavailable, errorpage.CodeScanUnavailable)
+			return
+		}
+	} else if verdict.Blocked {
+		log.Printf("Content scan blocked %s %s on %s: %s", c.Request.Method, c.Request.URL.Path, host, verdict.Reason)
+		errorpage.Render(c, http.StatusForbidden, errorpage.CodeContentBlocked)
+		return
+	}
+
+	// Wait for a free stream slot so that one session cannot tie up
+	// an unbounded number of ingress connections
+	if entry.Streams != nil {
+		if err := entry.Streams.Acquire(c.Request.Context()); err != nil {
+			if errors.Is(err, server.ErrStreamLimit) {
+				errorpage.Render(c, http.StatusServiceUnavailable, errorpage.CodeTunnelBusy)
+			} else {
+				errorpage.Render(c, http.StatusServiceUnavailable, errorpage.CodeTunnelUnavailable)
+			}
+			return
+		}
+		defer entry.Streams.Release()
+	}
+
+	// Serialize the request up front so that it can be replayed on retry
 	var reqBuf bytes.Buffer
 	if err := c.Request.Write(&reqBuf); err != nil {
 		sentry.CaptureErrorWithContext(c, err, "Failed to serialize request")
-		c.Status(http.StatusBadGateway)
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeTunnelUnavailable)
 		return
 	}
 	requestBytes := int64(reqBuf.Len())
 
-	// Forward request to tunnel
-	if _, err := stream.Write(reqBuf.Bytes()); err != nil {
-		sentry.CaptureErrorWithContext(c, err, "Failed to write request to stream")
-		c.Status(http.StatusBadGateway)
+	// Upgraded connections (WebSocket) hold the stream until either side closes
+	if isUpgradeRequest(c.Request) {
+		totalBytes := requestBytes + i.proxyUpgrade(c, entry.Session, host, reqBuf.Bytes())
+		entry.Traffic.Record(totalBytes)
+		if bandwidthLimit > 0 {
+			go i.recordBandwidth(entry.UserID, bandwidthLimit, totalBytes)
+		}
+		if i.GeoIP != nil {
+			go i.recordGeo(entry.UserID, geo.Country, totalBytes)
+		}
 		return
 	}
 
-	// Read and forward response
-	resp, err := http.ReadResponse(bufio.NewReader(stream), c.Request)
+	// Forward request to tunnel and read the response headers
+	start := time.Now()
+	stream, resp, err := roundTrip(entry.Session, reqBuf.Bytes(), c.Request)
+	if err != nil && i.RetryIdempotent && isIdempotent(c.Request.Method) {
+		// Nothing has reached the caller yet. Look the tunnel up again in
+		// case the client has already reconnected with a new session.
+		if retry, ok := i.Registry.GetEntry(host); ok {
+			log.Printf("Retrying %s %s on %s after stream failure: %v", c.Request.Method, c.Request.URL.Path, host, err)
+			stream, resp, err = roundTrip(retry.Session, reqBuf.Bytes(), c.Request)
+		}
+	}
 	if err != nil {
-		sentry.CaptureErrorWithContext(c, err, "Failed to read response from stream")
-		c.Status(http.StatusBadGateway)
+		sentry.CaptureErrorWithContextf(c, err, "Failed to proxy request to host %s", host)
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeTunnelUnavailable)
 		return
 	}
+	defer stream.Close()
 	defer resp.Body.Close()
 
+	if i.Latency != nil {
+		i.Latency.Observe(host, time.Since(start))
+	}
+
+	if err := i.Filters.Response(resp); err != nil {
+		log.Printf("Response from %s rejected by filter: %v", host, err)
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeFilterRejected)
+		return
+	}
+
 	// Copy headers
 	for k, vv := range resp.Header {
 		for _, v := range vv {
@@ -474,17 +1002,34 @@ func (i *Ingress) proxyToTunnel(c *gin.Context, host string) {
 		}
 	}
 
+	// Domains with capture on keep the start of the response body
+	var body io.Reader = resp.Body
+	var captured *capturedBody
+	if i.Captures.Enabled(host) {
+		captured = &capturedBody{max: i.Captures.MaxBody()}
+		body = io.TeeReader(resp.Body, captured)
+	}
+
 	// Write status and body, counting response bytes
 	c.Status(resp.StatusCode)
-	responseBytes, _ := io.Copy(c.Writer, resp.Body)
+	responseBytes, err := bufpool.Copy(c.Writer, body)
+	if captured != nil {
+		i.recordExchange(host, c.Request, reqBuf.Bytes(), resp, captured, start)
+	}
+	if err != nil {
+		// Usually the caller went away. Close the stream right away instead
+		// of draining the rest of the response, so the client sees the abort.
+		stream.SetReadDeadline(time.Now())
+		stream.Close()
+	}
 
 	// Record bandwidth usage asynchronously
 	totalBytes := requestBytes + responseBytes
-	if i.DailyBandwidthLimit > 0 && totalBytes > 0 {
-		go func(userID uint, bytes int64) {
-			if err := storage.AddUserBandwidth(userID, bytes); err != nil {
-				log.Printf("Failed to record bandwidth for user %d: %v", userID, err)
-			}
-		}(entry.UserID, totalBytes)
+	entry.Traffic.Record(totalBytes)
+	if bandwidthLimit > 0 && totalBytes > 0 {
+		go i.recordBandwidth(entry.UserID, bandwidthLimit, totalBytes)
+	}
+	if i.GeoIP != nil {
+		go i.recordGeo(entry.UserID, geo.Country, totalBytes)
 	}
 }
@@ -1,12 +1,27 @@
 package ingress
 
 import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/yamux"
+	"golang.org/x/time/rate"
 
+	"gopublic/internal/capture"
+	"gopublic/internal/dashboard"
+	"gopublic/internal/errorpage"
+	"gopublic/internal/pubsub"
 	"gopublic/internal/server"
 )
 
@@ -125,6 +140,29 @@ func TestIsDashboardHost(t *testing.T) {
 	}
 }
 
+func TestIsStatusHost(t *testing.T) {
+	tests := []struct {
+		name       string
+		rootDomain string
+		host       string
+		expected   bool
+	}{
+		{"status subdomain", "example.com", "status.example.com", true},
+		{"app subdomain", "example.com", "app.example.com", false},
+		{"tunnel named status-page", "example.com", "status-page.example.com", false},
+		{"localhost", "localhost", "status.localhost", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ingress := &Ingress{RootDomain: tt.rootDomain}
+			if got := ingress.isStatusHost(tt.host); got != tt.expected {
+				t.Errorf("isStatusHost(%q) = %v, want %v", tt.host, got, tt.expected)
+			}
+		})
+	}
+}
+
 func TestHandleRequest_InvalidHost(t *testing.T) {
 	gin.SetMode(gin.TestMode)
 
@@ -170,3 +208,551 @@ func TestHandleRequest_TunnelNotFound(t *testing.T) {
 		t.Errorf("Expected status 404, got %d", w.Code)
 	}
 }
+
+func TestHandleRequest_SuspendedDomain(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := server.NewTunnelRegistry()
+	flakyTunnel(t, registry, "myapp.example.com")
+	ingress := &Ingress{
+		Registry:   registry,
+		RootDomain: "example.com",
+	}
+	bus := pubsub.NewLocalBus()
+	ingress.SetEvents(bus)
+
+	r := gin.New()
+	r.NoRoute(ingress.handleRequest)
+
+	get := func() *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Host = "myapp.example.com"
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	ctx := context.Background()
+	bus.Publish(ctx, pubsub.Event{Type: pubsub.EventDomainSuspended, Domain: "myapp.example.com"})
+	w := get()
+	if w.Code != http.StatusUnavailableForLegalReasons {
+		t.Fatalf("suspended: status = %d, want 451", w.Code)
+	}
+	if got := w.Header().Get(errorpage.CodeHeader); got != string(errorpage.CodeTunnelSuspended) {
+		t.Errorf("suspended: error code = %q", got)
+	}
+
+	bus.Publish(ctx, pubsub.Event{Type: pubsub.EventDomainUnsuspended, Domain: "myapp.example.com"})
+	if w := get(); w.Code == http.StatusUnavailableForLegalReasons {
+		t.Error("unsuspended domain still rejected")
+	}
+}
+
+func TestServeAPIv1_Routing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ingress := &Ingress{
+		Registry:    server.NewTunnelRegistry(),
+		DashHandler: &dashboard.Handler{},
+		RootDomain:  "example.com",
+	}
+	r := gin.New()
+	r.NoRoute(ingress.handleRequest)
+
+	tests := []struct {
+		method, path string
+		want         int
+	}{
+		{http.MethodGet, "/api/v1/openapi.yaml", http.StatusOK},
+		{http.MethodGet, "/api/v1/domains", http.StatusUnauthorized},
+		{http.MethodPost, "/api/v1/domains", http.StatusUnauthorized},
+		{http.MethodDelete, "/api/v1/definitions/7", http.StatusUnauthorized},
+		{http.MethodDelete, "/api/v1/domains", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/api/v1/usage", http.StatusMethodNotAllowed},
+		{http.MethodPut, "/api/v1/tokens/7", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/api/v1/usage/7", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/domains/7/x", http.StatusNotFound},
+		{http.MethodGet, "/api/v1/unknown", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(tt.method, tt.path, nil)
+		req.Host = "app.example.com"
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Errorf("%s %s: status = %d, want %d", tt.method, tt.path, w.Code, tt.want)
+		}
+	}
+}
+
+func TestCrossedAlertLevels(t *testing.T) {
+	tests := []struct {
+		name          string
+		before, after int64
+		want          []int64
+	}{
+		{"below", 10, 50, nil},
+		{"crosses 80%", 70, 85, []int64{80}},
+		{"crosses both", 50, 120, []int64{80, 100}},
+		{"already over 80%", 85, 95, nil},
+		{"exactly 100%", 95, 100, []int64{100}},
+		{"already over", 100, 150, nil},
+	}
+	for _, tt := range tests {
+		got := crossedAlertLevels(100, tt.before, tt.after)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: crossedAlertLevels() = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("%s: crossedAlertLevels() = %v, want %v", tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestQuotaAlert_KeyPerDay(t *testing.T) {
+	day := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	a := quotaAlert(80, 80<<20, 100<<20, day)
+	b := quotaAlert(80, 90<<20, 100<<20, day.Add(time.Hour))
+	c := quotaAlert(80, 80<<20, 100<<20, day.AddDate(0, 0, 1))
+	if a.Key != b.Key {
+		t.Errorf("same-day keys differ: %q vs %q", a.Key, b.Key)
+	}
+	if a.Key == c.Key {
+		t.Errorf("next-day key should differ, got %q", c.Key)
+	}
+	if full := quotaAlert(100, 100<<20, 100<<20, day); full.Key == a.Key || full.Subject == a.Subject {
+		t.Error("100% alert should differ from the 80% alert")
+	}
+}
+
+func TestAnnotateGeo_StripsSpoofedHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request, _ = http.NewRequest("GET", "/", nil)
+	c.Request.Header.Set(CountryHeader, "US")
+	c.Request.Header.Set(ASNHeader, "15169")
+
+	ingress := &Ingress{}
+	if info := ingress.annotateGeo(c); info.Country != "" {
+		t.Errorf("Country = %q without a database", info.Country)
+	}
+	if c.Request.Header.Get(CountryHeader) != "" || c.Request.Header.Get(ASNHeader) != "" {
+		t.Errorf("caller-supplied geo headers were forwarded: %v", c.Request.Header)
+	}
+}
+
+// flakyTunnel registers a tunnel client for host that drops the first
+// stream without answering and serves every following request with 200.
+func flakyTunnel(t *testing.T, registry *server.TunnelRegistry, host string) *atomic.Int32 {
+	t.Helper()
+	serverConn, clientConn := net.Pipe()
+	serverSession, _ := yamux.Client(serverConn, nil)
+	clientSession, _ := yamux.Server(clientConn, nil)
+	t.Cleanup(func() {
+		serverSession.Close()
+		clientSession.Close()
+	})
+	registry.Register(host, serverSession, 1)
+
+	var streams atomic.Int32
+	go func() {
+		for {
+			stream, err := clientSession.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(stream))
+			if err == nil && req.Body != nil {
+				io.Copy(io.Discard, req.Body)
+			}
+			if streams.Add(1) > 1 {
+				io.WriteString(stream, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+			}
+			stream.Close()
+		}
+	}()
+	return &streams
+}
+
+func TestProxyToTunnel_RetryIdempotent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name    string
+		method  string
+		retry   bool
+		want    int
+		streams int32
+	}{
+		{"GET retried", "GET", true, http.StatusOK, 2},
+		{"HEAD retried", "HEAD", true, http.StatusOK, 2},
+		{"POST not retried", "POST", true, http.StatusBadGateway, 1},
+		{"retry disabled", "GET", false, http.StatusBadGateway, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := server.NewTunnelRegistry()
+			streams := flakyTunnel(t, registry, "myapp.example.com")
+			ingress := &Ingress{
+				Registry:        registry,
+				RootDomain:      "example.com",
+				RetryIdempotent: tt.retry,
+			}
+
+			r := gin.New()
+			r.NoRoute(ingress.handleRequest)
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest(tt.method, "/", strings.NewReader(""))
+			req.Host = "myapp.example.com"
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+			if got := streams.Load(); got != tt.streams {
+				t.Errorf("streams = %d, want %d", got, tt.streams)
+			}
+		})
+	}
+}
+
+func TestIsUpgradeRequest(t *testing.T) {
+	tests := []struct {
+		connection, upgrade string
+		want                bool
+	}{
+		{"Upgrade", "websocket", true},
+		{"keep-alive, Upgrade", "websocket", true},
+		{"keep-alive", "websocket", false},
+		{"Upgrade", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Header.Set("Connection", tt.connection)
+		req.Header.Set("Upgrade", tt.upgrade)
+		if got := isUpgradeRequest(req); got != tt.want {
+			t.Errorf("isUpgradeRequest(Connection: %q, Upgrade: %q) = %v, want %v", tt.connection, tt.upgrade, got, tt.want)
+		}
+	}
+}
+
+func TestProxyToTunnel_Upgrade(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The tunnel client accepts the upgrade, greets and echoes
+	registry := server.NewTunnelRegistry()
+	serverConn, clientConn := net.Pipe()
+	serverSession, _ := yamux.Client(serverConn, nil)
+	clientSession, _ := yamux.Server(clientConn, nil)
+	defer serverSession.Close()
+	defer clientSession.Close()
+	registry.Register("myapp.example.com", serverSession, 1)
+	go func() {
+		stream, err := clientSession.Accept()
+		if err != nil {
+			return
+		}
+		defer stream.Close()
+		r := bufio.NewReader(stream)
+		if _, err := http.ReadRequest(r); err != nil {
+			return
+		}
+		io.WriteString(stream, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\n\r\nhello\n")
+		io.Copy(stream, r)
+	}()
+
+	ingress := &Ingress{Registry: registry, RootDomain: "example.com"}
+	r := gin.New()
+	r.NoRoute(ingress.handleRequest)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: myapp.example.com\r\nConnection: Upgrade\r\nUpgrade: websocket\r\n\r\n")
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("ReadResponse = %v, %v", resp, err)
+	}
+	if greeting, err := br.ReadString('\n'); err != nil || greeting != "hello\n" {
+		t.Fatalf("greeting = %q, %v", greeting, err)
+	}
+	io.WriteString(conn, "ping\n")
+	if echo, err := br.ReadString('\n'); err != nil || echo != "ping\n" {
+		t.Errorf("echo = %q, %v", echo, err)
+	}
+}
+
+func TestProxyToTunnel_BasicAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// The tunnel client reports whether the Authorization header got through
+	registry := server.NewTunnelRegistry()
+	serverConn, clientConn := net.Pipe()
+	serverSession, _ := yamux.Client(serverConn, nil)
+	clientSession, _ := yamux.Server(clientConn, nil)
+	defer serverSession.Close()
+	defer clientSession.Close()
+	registry.RegisterEntry("myapp.example.com", &server.TunnelEntry{Session: serverSession, UserID: 1, BasicAuth: "admin:secret"})
+	go func() {
+		for {
+			stream, err := clientSession.Accept()
+			if err != nil {
+				return
+			}
+			req, err := http.ReadRequest(bufio.NewReader(stream))
+			if err == nil {
+				body := "no-auth"
+				if req.Header.Get("Authorization") != "" {
+					body = "auth"
+				}
+				fmt.Fprintf(stream, "HTTP/1.1 200 OK\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+			}
+			stream.Close()
+		}
+	}()
+
+	ingress := &Ingress{Registry: registry, RootDomain: "example.com"}
+	r := gin.New()
+	r.NoRoute(ingress.handleRequest)
+
+	tests := []struct {
+		name       string
+		user, pass string
+		want       int
+	}{
+		{"no credentials", "", "", http.StatusUnauthorized},
+		{"wrong password", "admin", "wrong", http.StatusUnauthorized},
+		{"valid", "admin", "secret", http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/", nil)
+			req.Host = "myapp.example.com"
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.pass)
+			}
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status = %d, want %d", w.Code, tt.want)
+			}
+			if w.Code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without a WWW-Authenticate challenge")
+			}
+			if w.Code == http.StatusOK && w.Body.String() != "no-auth" {
+				t.Error("tunnel credentials were forwarded to the local service")
+			}
+		})
+	}
+}
+
+func TestProxyToTunnel_RateLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := server.NewTunnelRegistry()
+	serverConn, clientConn := net.Pipe()
+	serverSession, _ := yamux.Client(serverConn, nil)
+	clientSession, _ := yamux.Server(clientConn, nil)
+	defer serverSession.Close()
+	defer clientSession.Close()
+	traffic := &server.DomainTraffic{}
+	registry.RegisterEntry("myapp.example.com", &server.TunnelEntry{
+		Session: serverSession,
+		UserID:  1,
+		Limiter: rate.NewLimiter(rate.Every(time.Hour), 2),
+		Traffic: traffic,
+	})
+	go func() {
+		for {
+			stream, err := clientSession.Accept()
+			if err != nil {
+				return
+			}
+			if _, err := http.ReadRequest(bufio.NewReader(stream)); err == nil {
+				fmt.Fprint(stream, "HTTP/1.1 200 OK\r\nContent-Length: 2\r\n\r\nok")
+			}
+			stream.Close()
+		}
+	}()
+
+	ingress := &Ingress{Registry: registry, RootDomain: "example.com"}
+	r := gin.New()
+	r.NoRoute(ingress.handleRequest)
+
+	// The burst of two gets through, the third request is throttled
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		req.Host = "myapp.example.com"
+		r.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("request %d: status = %d, want %d", i+1, w.Code, want)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Host = "myapp.example.com"
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get(errorpage.CodeHeader); got != string(errorpage.CodeTunnelRateLimited) {
+		t.Errorf("%s = %q, want %s", errorpage.CodeHeader, got, errorpage.CodeTunnelRateLimited)
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("429 without Retry-After")
+	}
+	if got := traffic.Throttled.Load(); got != 2 {
+		t.Errorf("Throttled = %d, want 2", got)
+	}
+	if got := traffic.Requests.Load(); got != 2 {
+		t.Errorf("Requests = %d, want 2", got)
+	}
+}
+
+func TestProxyToTunnel_Capture(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	registry := server.NewTunnelRegistry()
+	serverConn, clientConn := net.Pipe()
+	serverSession, _ := yamux.Client(serverConn, nil)
+	clientSession, _ := yamux.Server(clientConn, nil)
+	defer serverSession.Close()
+	defer clientSession.Close()
+	registry.RegisterEntry("myapp.example.com", &server.TunnelEntry{Session: serverSession, UserID: 1})
+	go func() {
+		for {
+			stream, err := clientSession.Accept()
+			if err != nil {
+				return
+			}
+			if _, err := http.ReadRequest(bufio.NewReader(stream)); err == nil {
+				body := `{"token":"abc","ok":true}`
+				fmt.Fprintf(stream, "HTTP/1.1 201 Created\r\nContent-Type: application/json\r\nContent-Length: %d\r\n\r\n%s", len(body), body)
+			}
+			stream.Close()
+		}
+	}()
+
+	ingress := &Ingress{Registry: registry, RootDomain: "example.com", Captures: capture.NewLog(0, 0)}
+	ingress.Captures.SetDomains([]string{"myapp.example.com"})
+	r := gin.New()
+	r.NoRoute(ingress.handleRequest)
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/login?api_key=k1&page=2", strings.NewReader("user=bob&password=hunter2"))
+	req.Host = "myapp.example.com"
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Cookie", "session=s1")
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusCreated || w.Body.String() != `{"token":"abc","ok":true}` {
+		t.Fatalf("response = %d %q; capture must not change it", w.Code, w.Body.String())
+	}
+
+	recent := ingress.Captures.Recent("myapp.example.com")
+	if len(recent) != 1 {
+		t.Fatalf("recorded %d exchanges, want 1", len(recent))
+	}
+	ex := recent[0]
+	if ex.Method != "POST" || ex.Status != http.StatusCreated || ex.URL != "/login?api_key=%5Bredacted%5D&page=2" {
+		t.Errorf("exchange = %s %s %d", ex.Method, ex.URL, ex.Status)
+	}
+	if ex.RequestBody != "user=bob&password=%5Bredacted%5D" || ex.RequestHeaders["Cookie"][0] != capture.Redacted {
+		t.Errorf("request = %v %q, want credentials redacted", ex.RequestHeaders, ex.RequestBody)
+	}
+	if ex.ResponseBody != `{"token":"[redacted]","ok":true}` || ex.ResponseSize != int64(w.Body.Len()) {
+		t.Errorf("response body = %q (%d bytes)", ex.ResponseBody, ex.ResponseSize)
+	}
+
+	// Domains without capture are not recorded
+	ingress.Captures.SetDomains(nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+	if got := ingress.Captures.Recent("myapp.example.com"); len(got) != 0 {
+		t.Errorf("recorded %d exchanges with capture off", len(got))
+	}
+}
+
+func TestHubSet_Events(t *testing.T) {
+	ingress := &Ingress{Registry: server.NewTunnelRegistry(), RootDomain: "example.com"}
+	bus := pubsub.NewLocalBus()
+	ingress.SetEvents(bus)
+	ingress.hubs.replace(map[string]uint{"team.example.com": 7})
+
+	if userID, ok := ingress.hubs.owner("team.example.com"); !ok || userID != 7 {
+		t.Fatalf("owner() = %d, %v, want 7, true", userID, ok)
+	}
+	bus.Publish(context.Background(), pubsub.Event{Type: pubsub.EventDomainRevoked, Domain: "team.example.com"})
+	if _, ok := ingress.hubs.owner("team.example.com"); ok {
+		t.Error("revoked domain is still a hub")
+	}
+}
+
+func TestValidateRequest(t *testing.T) {
+	valid := func() *http.Request {
+		req := httptest.NewRequest("POST", "/upload?x=1", strings.NewReader("hello"))
+		req.Header.Set("Content-Type", "text/plain")
+		return req
+	}
+	if err := validateRequest(valid()); err != nil {
+		t.Fatalf("validateRequest() error = %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(*http.Request)
+		want   error
+	}{
+		{"content-length with chunked", func(r *http.Request) {
+			r.TransferEncoding = []string{"chunked"}
+			r.Header.Set("Content-Length", "5")
+		}, errAmbiguousLength},
+		{"unknown transfer coding", func(r *http.Request) { r.TransferEncoding = []string{"gzip", "chunked"} }, errAmbiguousLength},
+		{"duplicate content-length", func(r *http.Request) { r.Header["Content-Length"] = []string{"5", "6"} }, errDuplicateHeaders},
+		{"CRLF in value", func(r *http.Request) { r.Header.Set("X-Note", "a\r\nContent-Length: 0") }, errInvalidHeader},
+		{"space in name", func(r *http.Request) { r.Header["Content-Length "] = []string{"5"} }, errInvalidHeader},
+		{"control character in target", func(r *http.Request) { r.RequestURI = "/a\x00b" }, errInvalidTarget},
+		{"space in target", func(r *http.Request) { r.RequestURI = "/a HTTP/1.1\r\n" }, errInvalidTarget},
+		{"oversized headers", func(r *http.Request) { r.Header.Set("Cookie", strings.Repeat("x", MaxHeaderBytes)) }, errHeadersTooLarge},
+	}
+	for _, tt := range tests {
+		req := valid()
+		tt.modify(req)
+		if err := validateRequest(req); !errors.Is(err, tt.want) {
+			t.Errorf("%s: validateRequest() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestHandleRequest_OversizedHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	ingress := &Ingress{Registry: server.NewTunnelRegistry(), RootDomain: "example.com"}
+	r := gin.New()
+	r.NoRoute(ingress.handleRequest)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Host = "myapp.example.com"
+	req.Header.Set("Cookie", strings.Repeat("x", MaxHeaderBytes))
+	r.ServeHTTP(w, req)
+
+	if w.Code != http.StatusRequestHeaderFieldsTooLarge {
+		t.Errorf("status = %d, want 431", w.Code)
+	}
+	if code := w.Header().Get(errorpage.CodeHeader); code != string(errorpage.CodeHeadersTooLarge) {
+		t.Errorf("error code = %q", code)
+	}
+}
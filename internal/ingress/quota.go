@@ -0,0 +1,189 @@
+package ingress
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/errorpage"
+	"gopublic/internal/notify"
+	"gopublic/internal/pubsub"
+	"gopublic/internal/storage"
+)
+
+// quotaCache remembers users known to be over today's bandwidth limit, so
+// that every instance rejects their traffic without a database round trip.
+type quotaCache struct {
+	mu    sync.Mutex
+	users map[uint]time.Time // userID -> day the limit was exceeded
+}
+
+func (q *quotaCache) mark(userID uint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.users == nil {
+		q.users = make(map[uint]time.Time)
+	}
+	q.users[userID] = time.Now().Truncate(24 * time.Hour)
+}
+
+func (q *quotaCache) clear(userID uint) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	delete(q.users, userID)
+}
+
+func (q *quotaCache) exceeded(userID uint) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	day, ok := q.users[userID]
+	if !ok {
+		return false
+	}
+	if !day.Equal(time.Now().Truncate(24 * time.Hour)) {
+		delete(q.users, userID) // Limit reset at day rollover
+		return false
+	}
+	return true
+}
+
+// SetEvents connects the ingress to a cross-instance event bus.
+func (i *Ingress) SetEvents(bus pubsub.Bus) {
+	i.Events = bus
+	bus.Subscribe(func(event pubsub.Event) {
+		switch event.Type {
+		case pubsub.EventQuotaExceeded:
+			i.overQuota.mark(event.UserID)
+		case pubsub.EventDomainSuspended:
+			i.suspended.set(event.Domain, true)
+		case pubsub.EventDomainUnsuspended:
+			i.suspended.set(event.Domain, false)
+		case pubsub.EventDomainRevoked:
+			// Released and transferred domains stop being hubs and
+			// forget their captures
+			i.hubs.remove(event.Domain)
+			if i.Captures != nil {
+				i.Captures.Disable(event.Domain)
+			}
+		case pubsub.EventHubChanged:
+			if err := i.LoadHubDomains(); err != nil {
+				log.Printf("Failed to reload hub domains: %v", err)
+			}
+		case pubsub.EventCaptureChanged:
+			if err := i.LoadCaptureDomains(); err != nil {
+				log.Printf("Failed to reload capture domains: %v", err)
+			}
+		case pubsub.EventDomainScheduled:
+			if err := i.LoadDomainSchedules(); err != nil {
+				log.Printf("Failed to reload domain schedules: %v", err)
+			}
+		case pubsub.EventPlanChanged:
+			// The new plan may come with a different bandwidth limit
+			i.Registry.SetUserPlan(event.UserID, event.Plan)
+			i.overQuota.clear(event.UserID)
+		}
+	})
+}
+
+// rejectOverQuota responds that the daily bandwidth limit is exhausted.
+func rejectOverQuota(c *gin.Context) {
+	c.Header("Retry-After", "86400") // 24 hours
+	errorpage.Render(c, http.StatusTooManyRequests, errorpage.CodeQuotaExceeded)
+}
+
+// recordBandwidth adds usage for the user and announces the moment the
+// daily limit is crossed to all instances.
+func (i *Ingress) recordBandwidth(userID uint, limit, bytes int64) {
+	if err := storage.AddUserBandwidth(userID, bytes); err != nil {
+		log.Printf("Failed to record bandwidth for user %d: %v", userID, err)
+		return
+	}
+
+	used, err := storage.GetUserBandwidthToday(userID)
+	if err != nil {
+		return
+	}
+	for _, percent := range crossedAlertLevels(limit, used-bytes, used) {
+		i.sendQuotaAlert(userID, percent, used, limit)
+	}
+	if used < limit || used-bytes >= limit {
+		return
+	}
+
+	if i.Events == nil {
+		i.overQuota.mark(userID)
+		return
+	}
+	event := pubsub.Event{Type: pubsub.EventQuotaExceeded, UserID: userID, Reason: "daily bandwidth limit"}
+	if err := i.Events.Publish(context.Background(), event); err != nil {
+		log.Printf("Failed to publish quota event for user %d: %v", userID, err)
+		i.overQuota.mark(userID)
+	}
+}
+
+// quotaAlertLevels are the percentages of the daily limit at which users
+// are notified.
+var quotaAlertLevels = []int64{80, 100}
+
+// crossedAlertLevels returns the alert levels passed when usage grew from
+// before to after.
+func crossedAlertLevels(limit, before, after int64) []int64 {
+	var crossed []int64
+	for _, percent := range quotaAlertLevels {
+		threshold := limit * percent / 100
+		if before < threshold && after >= threshold {
+			crossed = append(crossed, percent)
+		}
+	}
+	return crossed
+}
+
+// quotaAlert builds the notification for a crossed alert level. The key
+// includes the day so that each level is sent at most once per day.
+func quotaAlert(percent, used, limit int64, day time.Time) notify.Message {
+	msg := notify.Message{Key: fmt.Sprintf("bandwidth:%d:%s", percent, day.Format("2006-01-02"))}
+	if percent >= 100 {
+		msg.Subject = "Дневной лимит трафика исчерпан"
+		msg.Text = fmt.Sprintf("⛔ Дневной лимит трафика исчерпан: %s из %s.\n\n"+
+			"До 00:00 UTC ваши туннели отвечают ошибкой 429.", formatMB(used), formatMB(limit))
+	} else {
+		msg.Subject = fmt.Sprintf("Использовано %d%% дневного лимита трафика", percent)
+		msg.Text = fmt.Sprintf("⚠️ Использовано %d%% дневного лимита трафика: %s из %s.\n\n"+
+			"Лимит обновляется в 00:00 UTC.", percent, formatMB(used), formatMB(limit))
+	}
+	msg.Text += "\nОтключить уведомления можно в панели управления."
+	return msg
+}
+
+// formatMB formats a byte count in megabytes.
+func formatMB(bytes int64) string {
+	return fmt.Sprintf("%.1f МБ", float64(bytes)/(1024*1024))
+}
+
+// sendQuotaAlert notifies the user unless they opted out.
+func (i *Ingress) sendQuotaAlert(userID uint, percent, used, limit int64) {
+	if i.Notifier == nil {
+		return
+	}
+	user, err := storage.GetUserByID(userID)
+	if err != nil {
+		log.Printf("Failed to load user %d for quota alert: %v", userID, err)
+		return
+	}
+	if user.QuotaAlertsDisabled {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	msg := quotaAlert(percent, used, limit, time.Now().UTC())
+	if _, err := i.Notifier.Send(ctx, user, msg); err != nil && !errors.Is(err, notify.ErrNoChannel) {
+		log.Printf("Failed to send quota alert to user %d: %v", userID, err)
+	}
+}
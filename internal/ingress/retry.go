@@ -0,0 +1,36 @@
+package ingress
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+
+	"github.com/hashicorp/yamux"
+)
+
+// isIdempotent reports whether a request can be safely sent twice.
+// Only GET and HEAD are retried: other idempotent methods such as PUT and
+// DELETE may still have side effects on a partially processed first attempt.
+func isIdempotent(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// roundTrip opens a stream on the session, writes the serialized request
+// and reads the response headers. On success the caller must close both the
+// stream and the response body.
+func roundTrip(session *yamux.Session, payload []byte, req *http.Request) (*yamux.Stream, *http.Response, error) {
+	stream, err := session.OpenStream()
+	if err != nil {
+		return nil, nil, fmt.Errorf("open stream: %w", err)
+	}
+	if _, err := stream.Write(payload); err != nil {
+		stream.Close()
+		return nil, nil, fmt.Errorf("write request: %w", err)
+	}
+	resp, err := http.ReadResponse(bufio.NewReader(stream), req)
+	if err != nil {
+		stream.Close()
+		return nil, nil, fmt.Errorf("read response: %w", err)
+	}
+	return stream, resp, nil
+}
@@ -0,0 +1,56 @@
+package ingress
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gopublic/internal/schedule"
+	"gopublic/internal/storage"
+)
+
+// scheduleSet holds the time windows of scheduled hostnames. It is loaded
+// from the database at startup and reloaded whenever a schedule changes.
+type scheduleSet struct {
+	mu    sync.RWMutex
+	hosts map[string]*schedule.Schedule
+}
+
+func (s *scheduleSet) replace(hosts map[string]*schedule.Schedule) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.hosts = hosts
+}
+
+// allows reports whether host may be served at t. Hosts without a
+// schedule are always allowed.
+func (s *scheduleSet) allows(host string, t time.Time) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	sched, ok := s.hosts[host]
+	return !ok || sched.Allows(t)
+}
+
+// LoadDomainSchedules loads the time windows of all scheduled domains.
+func (i *Ingress) LoadDomainSchedules() error {
+	domains, err := storage.GetScheduledDomains()
+	if err != nil {
+		return err
+	}
+	hosts := make(map[string]*schedule.Schedule, len(domains))
+	for _, d := range domains {
+		sched, err := schedule.Parse(d.Schedule, d.ScheduleTimezone)
+		if err != nil {
+			// Validated when saved; keep the domain reachable rather than guess
+			log.Printf("Ignoring invalid schedule of %s: %v", d.Name, err)
+			continue
+		}
+		host := d.Name
+		if i.RootDomain != "" {
+			host = d.Name + "." + i.RootDomain
+		}
+		hosts[host] = sched
+	}
+	i.schedules.replace(hosts)
+	return nil
+}
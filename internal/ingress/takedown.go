@@ -0,0 +1,71 @@
+package ingress
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+
+	"gopublic/internal/errorpage"
+	"gopublic/internal/storage"
+)
+
+// suspendedSet holds hostnames taken down by an administrator.
+// It is primed from the database and kept current through domain events.
+type suspendedSet struct {
+	mu    sync.RWMutex
+	hosts map[string]bool
+}
+
+func (s *suspendedSet) set(host string, suspended bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.hosts == nil {
+		s.hosts = make(map[string]bool)
+	}
+	if suspended {
+		s.hosts[host] = true
+	} else {
+		delete(s.hosts, host)
+	}
+}
+
+func (s *suspendedSet) has(host string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.hosts[host]
+}
+
+// LoadSuspendedDomains primes the takedown list from the database.
+func (i *Ingress) LoadSuspendedDomains() error {
+	domains, err := storage.GetSuspendedDomains()
+	if err != nil {
+		return err
+	}
+	for _, d := range domains {
+		host := d.Name
+		if i.RootDomain != "" {
+			host = d.Name + "." + i.RootDomain
+		}
+		i.suspended.set(host, true)
+	}
+	return nil
+}
+
+// rejectSuspended serves the takedown page for a suspended domain.
+func rejectSuspended(c *gin.Context) {
+	errorpage.Render(c, http.StatusUnavailableForLegalReasons, errorpage.CodeTunnelSuspended)
+}
+
+// abuseReportURL links to the abuse form prefilled with the tunnel address.
+func (i *Ingress) abuseReportURL(host string) string {
+	if i.RootDomain == "" {
+		return ""
+	}
+	scheme := "http"
+	if i.IsSecure {
+		scheme = "https"
+	}
+	return scheme + "://" + i.RootDomain + "/abuse?url=" + url.QueryEscape(scheme+"://"+host)
+}
@@ -0,0 +1,110 @@
+package ingress
+
+import (
+	"bufio"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/bufpool"
+	"gopublic/internal/errorpage"
+	"gopublic/internal/sentry"
+)
+
+// isUpgradeRequest reports whether the caller asks to switch the connection
+// to another protocol, e.g. WebSocket.
+func isUpgradeRequest(req *http.Request) bool {
+	if req.Header.Get("Upgrade") == "" {
+		return false
+	}
+	for _, token := range strings.Split(req.Header.Get("Connection"), ",") {
+		if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+			return true
+		}
+	}
+	return false
+}
+
+// closeWriter is a connection that can be half-closed, like *net.TCPConn
+// and *tls.Conn.
+type closeWriter interface {
+	CloseWrite() error
+}
+
+// proxyUpgrade forwards an upgrade request over a new stream. If the local
+// service switches protocols, the caller's connection is hijacked and bytes
+// are copied both ways until either side closes; otherwise the response is
+// passed on like any other. Returns the bytes passed through after the
+// request: the response and, once upgraded, the traffic in both directions.
+func (i *Ingress) proxyUpgrade(c *gin.Context, session *yamux.Session, host string, payload []byte) int64 {
+	stream, err := session.OpenStream()
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to open upgrade stream to host %s", host)
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeTunnelUnavailable)
+		return 0
+	}
+	defer stream.Close()
+	if _, err := stream.Write(payload); err != nil {
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeTunnelUnavailable)
+		return 0
+	}
+	reader := bufio.NewReader(stream)
+	resp, err := http.ReadResponse(reader, c.Request)
+	if err != nil {
+		sentry.CaptureErrorWithContextf(c, err, "Failed to read upgrade response from host %s", host)
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeTunnelUnavailable)
+		return 0
+	}
+	defer resp.Body.Close()
+
+	if err := i.Filters.Response(resp); err != nil {
+		errorpage.Render(c, http.StatusBadGateway, errorpage.CodeFilterRejected)
+		return 0
+	}
+
+	// The local service declined the upgrade
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		for k, vv := range resp.Header {
+			for _, v := range vv {
+				c.Writer.Header().Add(k, v)
+			}
+		}
+		c.Status(resp.StatusCode)
+		n, _ := bufpool.Copy(c.Writer, resp.Body)
+		return n
+	}
+
+	conn, brw, err := c.Writer.Hijack()
+	if err != nil {
+		sentry.CaptureErrorWithContext(c, err, "Failed to hijack upgraded connection")
+		errorpage.Render(c, http.StatusInternalServerError, errorpage.CodeTunnelUnavailable)
+		return 0
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Time{})
+
+	resp.Body = nil
+	if err := resp.Write(conn); err != nil {
+		return 0
+	}
+
+	done := make(chan int64, 2)
+	go func() {
+		n, _ := bufpool.Copy(stream, brw.Reader)
+		stream.Close() // Half-close: the local service may still answer
+		done <- n
+	}()
+	go func() {
+		n, _ := bufpool.Copy(conn, reader)
+		if cw, ok := conn.(closeWriter); ok {
+			cw.CloseWrite()
+		} else {
+			conn.Close()
+		}
+		done <- n
+	}()
+	return <-done + <-done
+}
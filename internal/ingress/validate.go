@@ -0,0 +1,81 @@
+package ingress
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// MaxHeaderBytes limits the request line and headers the ingress servers
+// read, well below net/http's default of 1 MB.
+const MaxHeaderBytes = 32 << 10
+
+// Reasons a request is not forwarded to a tunnel.
+var (
+	errHeadersTooLarge  = errors.New("request headers too large")
+	errAmbiguousLength  = errors.New("ambiguous request body length")
+	errInvalidHeader    = errors.New("invalid character in request header")
+	errInvalidTarget    = errors.New("invalid character in request target")
+	errDuplicateHeaders = errors.New("duplicate Host or Content-Length header")
+)
+
+// validateRequest rejects requests that tunnel clients could frame
+// differently than the ingress: conflicting Content-Length and
+// Transfer-Encoding, repeated framing headers and control characters that
+// would survive into the serialized request. net/http already refuses most
+// of these on the wire; the check keeps them out of the tunnel whatever
+// server or proxy the handler runs behind.
+func validateRequest(r *http.Request) error {
+	if len(r.Header["Content-Length"]) > 1 || len(r.Header["Host"]) > 1 {
+		return errDuplicateHeaders
+	}
+	if len(r.TransferEncoding) > 0 &&
+		(r.Header.Get("Content-Length") != "" || len(r.TransferEncoding) > 1 || r.TransferEncoding[0] != "chunked") {
+		return errAmbiguousLength
+	}
+	if !validFieldValue(r.RequestURI) || strings.ContainsRune(r.RequestURI, ' ') {
+		return errInvalidTarget
+	}
+
+	size := len(r.Method) + len(r.RequestURI) + len(r.Host)
+	for name, values := range r.Header {
+		if !validFieldName(name) {
+			return errInvalidHeader
+		}
+		for _, v := range values {
+			if !validFieldValue(v) {
+				return errInvalidHeader
+			}
+			size += len(name) + len(v) + 4 // ": " and CRLF
+		}
+	}
+	if size > MaxHeaderBytes {
+		return errHeadersTooLarge
+	}
+	return nil
+}
+
+// validFieldName reports whether name is an RFC 9110 token.
+func validFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// validFieldValue reports whether v is free of control characters other
+// than horizontal tab, in particular CR, LF and NUL.
+func validFieldValue(v string) bool {
+	for i := 0; i < len(v); i++ {
+		if c := v[i]; (c < ' ' && c != '\t') || c == 0x7f {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,98 @@
+// Package interstitial implements the warning page shown to first-time
+// visitors of tunnels on plans that are prone to phishing abuse.
+//
+// Browsers see the page once per tunnel host; confirming it sets a cookie.
+// API clients and tools that send BypassHeader are never interrupted.
+package interstitial
+
+import (
+	"embed"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"gopublic/internal/errorpage"
+)
+
+const (
+	// BypassHeader skips the warning when present with any value.
+	BypassHeader = "Gopublic-Skip-Browser-Warning"
+	// CookieName is set on the tunnel host once the visitor confirms the warning.
+	CookieName = "gopublic_skip_warning"
+)
+
+//go:embed templates/interstitial.html
+var templateFS embed.FS
+
+var pageTemplate = template.Must(template.ParseFS(templateFS, "templates/interstitial.html"))
+
+// Policy selects the plans whose tunnels show the warning.
+// A nil Policy disables the interstitial.
+type Policy struct {
+	plans map[string]bool
+}
+
+// NewPolicy creates a policy for the given plans. It returns nil when the
+// list is empty, so that callers can store the result unconditionally.
+func NewPolicy(plans []string) *Policy {
+	if len(plans) == 0 {
+		return nil
+	}
+	p := &Policy{plans: make(map[string]bool, len(plans))}
+	for _, plan := range plans {
+		p.plans[plan] = true
+	}
+	return p
+}
+
+// Applies reports whether tunnels of the plan show the warning.
+func (p *Policy) Applies(plan string) bool {
+	return p != nil && p.plans[plan]
+}
+
+// Required reports whether the request is a browser page load that has
+// not yet passed the warning.
+func Required(r *http.Request) bool {
+	if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return false
+	}
+	if r.Header.Get(BypassHeader) != "" {
+		return false
+	}
+	if _, err := r.Cookie(CookieName); err == nil {
+		return false
+	}
+	return true
+}
+
+// pageData is passed to the HTML template.
+type pageData struct {
+	Lang      string
+	Host      string
+	Text      text
+	ReportURL string
+	Cookie    string
+}
+
+// Write renders the warning page for the requested host. reportURL links
+// to the abuse report form and may be empty.
+func Write(w http.ResponseWriter, r *http.Request, reportURL string) {
+	lang := errorpage.Language(r.Header.Get("Accept-Language"))
+	t, ok := texts[lang]
+	if !ok {
+		t = texts[errorpage.DefaultLanguage]
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Language", lang)
+	h.Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	pageTemplate.Execute(w, pageData{
+		Lang:      lang,
+		Host:      r.Host,
+		Text:      t,
+		ReportURL: reportURL,
+		Cookie:    CookieName,
+	})
+}
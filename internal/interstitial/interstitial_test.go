@@ -0,0 +1,78 @@
+package interstitial
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPolicy(t *testing.T) {
+	if NewPolicy(nil) != nil {
+		t.Error("empty plan list should disable the policy")
+	}
+	var disabled *Policy
+	if disabled.Applies("free") {
+		t.Error("nil policy should not apply")
+	}
+
+	p := NewPolicy([]string{"free"})
+	if !p.Applies("free") || p.Applies("pro") {
+		t.Errorf("Applies: free=%v pro=%v, want true/false", p.Applies("free"), p.Applies("pro"))
+	}
+}
+
+func TestRequired(t *testing.T) {
+	browser := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("Accept", "text/html,application/xhtml+xml")
+		return r
+	}
+
+	if !Required(browser()) {
+		t.Error("first browser visit should see the warning")
+	}
+
+	r := browser()
+	r.Header.Set(BypassHeader, "1")
+	if Required(r) {
+		t.Error("bypass header should skip the warning")
+	}
+
+	r = browser()
+	r.AddCookie(&http.Cookie{Name: CookieName, Value: "1"})
+	if Required(r) {
+		t.Error("cookie should skip the warning")
+	}
+
+	r = httptest.NewRequest(http.MethodGet, "/api", nil)
+	r.Header.Set("Accept", "application/json")
+	if Required(r) {
+		t.Error("API clients should not see the warning")
+	}
+
+	r = browser()
+	r.Method = http.MethodPost
+	if Required(r) {
+		t.Error("non-GET requests should not see the warning")
+	}
+}
+
+func TestWrite(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Host = "myapp.example.com"
+	r.Header.Set("Accept-Language", "ru")
+	w := httptest.NewRecorder()
+
+	Write(w, r, "https://example.com/abuse?url=https%3A%2F%2Fmyapp.example.com")
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, want 200", w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{"myapp.example.com", texts["ru"].Continue, "/abuse?url=", `"` + CookieName + `"`} {
+		if !strings.Contains(body, want) {
+			t.Errorf("page does not contain %q", want)
+		}
+	}
+}
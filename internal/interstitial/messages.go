@@ -0,0 +1,31 @@
+package interstitial
+
+// text holds the localized strings of the warning page.
+type text struct {
+	Title    string
+	Message  string
+	Advice   string
+	Continue string
+	Report   string
+	Bypass   string // Explains BypassHeader to developers
+}
+
+// texts covers the languages supported by errorpage.
+var texts = map[string]text{
+	"en": {
+		Title:    "You are about to visit",
+		Message:  "This site is served through a free gopublic tunnel. Anyone can create one, and it may be used to impersonate other services.",
+		Advice:   "Do not enter passwords, card numbers or other personal data unless you trust the owner of this site.",
+		Continue: "Visit site",
+		Report:   "Report abuse",
+		Bypass:   "Developers: send the " + BypassHeader + " header with any value to skip this page.",
+	},
+	"ru": {
+		Title:    "Вы переходите на сайт",
+		Message:  "Этот сайт открыт через бесплатный туннель gopublic. Создать такой туннель может любой, и его могут использовать, чтобы выдать себя за другой сервис.",
+		Advice:   "Не вводите пароли, номера карт и другие личные данные, если не доверяете владельцу сайта.",
+		Continue: "Перейти на сайт",
+		Report:   "Сообщить о нарушении",
+		Bypass:   "Разработчикам: передайте заголовок " + BypassHeader + " с любым значением, чтобы пропустить эту страницу.",
+	},
+}
@@ -0,0 +1,85 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"gopublic/internal/models"
+	"gopublic/internal/storage"
+)
+
+// HostnameLister lists the tunnel hostnames served by this instance.
+// Implemented by server.TunnelRegistry.
+type HostnameLister interface {
+	Hostnames() []string
+}
+
+// AlertDeliverer sends tunnel alerts. Implemented by alerts.Sender.
+type AlertDeliverer interface {
+	Deliver(ctx context.Context, rule *models.AlertRule, online bool) error
+}
+
+// TunnelHeartbeat records which tunnels this instance serves, for offline
+// alerts and uptime history. The job name includes the instance ID so that
+// every instance runs it.
+func TunnelHeartbeat(interval time.Duration, instanceID string, tunnels HostnameLister) Job {
+	return Job{
+		Name:     "tunnel-heartbeat/" + instanceID,
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			hosts := tunnels.Hostnames()
+			if err := storage.MarkAlertDomainsOnline(hosts, time.Now()); err != nil {
+				return err
+			}
+			return storage.AddDomainUptime(hosts, int64(interval/time.Second))
+		},
+	}
+}
+
+// OfflineAlerts fires alert rules whose tunnel has been offline longer than
+// the rule's threshold and sends a recovery notice once it is back.
+// Failed deliveries are retried on the next run.
+func OfflineAlerts(interval time.Duration, sender AlertDeliverer) Job {
+	return Job{
+		Name:     "offline-alerts",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			now := time.Now()
+			offline, err := storage.GetOfflineAlertRules(now.Add(-time.Minute))
+			if err != nil {
+				return err
+			}
+			for i := range offline {
+				rule := &offline[i]
+				if now.Sub(*rule.LastOnlineAt) < time.Duration(rule.OfflineMinutes)*time.Minute {
+					continue
+				}
+				if err := sender.Deliver(ctx, rule, false); err != nil {
+					log.Printf("Failed to deliver offline alert %d for %s: %v", rule.ID, rule.Domain, err)
+					continue
+				}
+				if err := storage.SetAlertFired(rule.ID, &now); err != nil {
+					return err
+				}
+				log.Printf("Tunnel %s offline since %s, alerted user %d via %s", rule.Domain, rule.LastOnlineAt.Format(time.RFC3339), rule.UserID, rule.Channel)
+			}
+
+			recovered, err := storage.GetRecoveredAlertRules()
+			if err != nil {
+				return err
+			}
+			for i := range recovered {
+				rule := &recovered[i]
+				if err := sender.Deliver(ctx, rule, true); err != nil {
+					log.Printf("Failed to deliver recovery notice %d for %s: %v", rule.ID, rule.Domain, err)
+					continue
+				}
+				if err := storage.SetAlertFired(rule.ID, nil); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,242 @@
+// Package jobs runs recurring server maintenance tasks.
+package jobs
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"gopublic/internal/metrics"
+)
+
+// Job is a recurring maintenance task.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Timeout  time.Duration // Maximum run time (default: Interval)
+	Run      func(ctx context.Context) error
+}
+
+// Status is a snapshot of a job's most recent execution.
+type Status struct {
+	Name         string        `json:"name"`
+	LastRun      time.Time     `json:"last_run"`
+	LastDuration time.Duration `json:"last_duration"`
+	LastError    string        `json:"last_error,omitempty"`
+	Runs         int64         `json:"runs"`
+	Failures     int64         `json:"failures"`
+	Skipped      int64         `json:"skipped"`
+}
+
+// scheduledJob is a job with its metrics and last run status.
+type scheduledJob struct {
+	Job
+
+	runs        *metrics.Counter
+	failures    *metrics.Counter
+	skipped     *metrics.Counter
+	duration    *metrics.Histogram
+	lastSuccess *metrics.Gauge
+
+	mu     sync.Mutex
+	status Status
+}
+
+// Runner schedules jobs and guarantees that each job runs on at most one
+// instance at a time through its Locker.
+type Runner struct {
+	locker  Locker
+	metrics *metrics.Metrics
+
+	mu     sync.Mutex
+	jobs   map[string]*scheduledJob
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewRunner creates a runner. If locker is nil, an in-process LocalLocker is used.
+// If m is nil, metrics are recorded into a private registry.
+func NewRunner(locker Locker, m *metrics.Metrics) *Runner {
+	if locker == nil {
+		locker = NewLocalLocker()
+	}
+	if m == nil {
+		m = metrics.New()
+	}
+	return &Runner{
+		locker:  locker,
+		metrics: m,
+		jobs:    make(map[string]*scheduledJob),
+	}
+}
+
+// Add registers a job. Jobs must be added before Start.
+func (r *Runner) Add(job Job) {
+	if job.Timeout <= 0 {
+		job.Timeout = job.Interval
+	}
+	labels := map[string]string{"job": job.Name}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobs[job.Name] = &scheduledJob{
+		Job: job,
+		runs: r.metrics.NewCounter(
+			"gopublic_job_runs_total",
+			"Total number of background job runs",
+			labels,
+		),
+		failures: r.metrics.NewCounter(
+			"gopublic_job_failures_total",
+			"Total number of failed background job runs",
+			labels,
+		),
+		skipped: r.metrics.NewCounter(
+			"gopublic_job_skipped_total",
+			"Total number of runs skipped because another instance held the lock",
+			labels,
+		),
+		duration: r.metrics.NewHistogram(
+			"gopublic_job_duration_seconds",
+			"Background job run duration in seconds",
+			nil,
+			labels,
+		),
+		lastSuccess: r.metrics.NewGauge(
+			"gopublic_job_last_success_timestamp_seconds",
+			"Unix time of the last successful background job run",
+			labels,
+		),
+		status: Status{Name: job.Name},
+	}
+}
+
+// Start runs every job on its interval until Stop is called or ctx is done.
+func (r *Runner) Start(ctx context.Context) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ctx, r.cancel = context.WithCancel(ctx)
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, job)
+	}
+}
+
+// Stop cancels running jobs and waits for them to finish.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	cancel := r.cancel
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+	}
+	r.wg.Wait()
+}
+
+// RunNow runs the named job immediately, honoring the lock.
+func (r *Runner) RunNow(ctx context.Context, name string) error {
+	r.mu.Lock()
+	job, ok := r.jobs[name]
+	r.mu.Unlock()
+	if !ok {
+		return fmt.Errorf("unknown job %q", name)
+	}
+	return r.run(ctx, job)
+}
+
+// Status returns the last run status of all jobs, sorted by name.
+func (r *Runner) Status() []Status {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	result := make([]Status, 0, len(r.jobs))
+	for _, job := range r.jobs {
+		job.mu.Lock()
+		result = append(result, job.status)
+		job.mu.Unlock()
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// Metrics returns the registry holding per-job metrics.
+func (r *Runner) Metrics() *metrics.Metrics {
+	return r.metrics
+}
+
+func (r *Runner) loop(ctx context.Context, job *scheduledJob) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.run(ctx, job); err != nil {
+				log.Printf("Job %s failed: %v", job.Name, err)
+			}
+		}
+	}
+}
+
+// run executes a single job run under the job lock.
+func (r *Runner) run(ctx context.Context, job *scheduledJob) (err error) {
+	// Hold the lease slightly longer than the run may take so that a slow
+	// run is never picked up by another instance mid-way.
+	acquired, err := r.locker.TryLock(job.Name, job.Timeout+time.Minute)
+	if err != nil {
+		return fmt.Errorf("acquire lock: %w", err)
+	}
+	if !acquired {
+		job.skipped.Inc()
+		job.mu.Lock()
+		job.status.Skipped++
+		job.mu.Unlock()
+		return nil
+	}
+	defer func() {
+		if unlockErr := r.locker.Unlock(job.Name); unlockErr != nil {
+			log.Printf("Job %s: failed to release lock: %v", job.Name, unlockErr)
+		}
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	start := time.Now()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+
+		elapsed := time.Since(start)
+		job.runs.Inc()
+		job.duration.Observe(elapsed.Seconds())
+		if err != nil {
+			job.failures.Inc()
+		} else {
+			job.lastSuccess.Set(float64(start.Unix()))
+		}
+
+		job.mu.Lock()
+		job.status.LastRun = start
+		job.status.LastDuration = elapsed
+		job.status.LastError = ""
+		if err != nil {
+			job.status.LastError = err.Error()
+			job.status.Failures++
+		}
+		job.status.Runs++
+		job.mu.Unlock()
+	}()
+
+	return job.Run(ctx)
+}
@@ -0,0 +1,116 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunner_RunNowRecordsStatus(t *testing.T) {
+	r := NewRunner(nil, nil)
+	r.Add(Job{Name: "ok", Interval: time.Hour, Run: func(ctx context.Context) error { return nil }})
+	r.Add(Job{Name: "fail", Interval: time.Hour, Run: func(ctx context.Context) error { return errors.New("boom") }})
+
+	if err := r.RunNow(context.Background(), "ok"); err != nil {
+		t.Fatalf("RunNow(ok) = %v", err)
+	}
+	if err := r.RunNow(context.Background(), "fail"); err == nil {
+		t.Fatal("RunNow(fail) should return the job error")
+	}
+	if err := r.RunNow(context.Background(), "missing"); err == nil {
+		t.Fatal("RunNow(missing) should fail")
+	}
+
+	status := r.Status()
+	if len(status) != 2 || status[0].Name != "fail" || status[1].Name != "ok" {
+		t.Fatalf("unexpected status: %+v", status)
+	}
+	if status[0].Failures != 1 || status[0].LastError != "boom" {
+		t.Errorf("fail status = %+v", status[0])
+	}
+	if status[1].Runs != 1 || status[1].Failures != 0 || status[1].LastRun.IsZero() {
+		t.Errorf("ok status = %+v", status[1])
+	}
+}
+
+func TestRunner_RecoversPanic(t *testing.T) {
+	r := NewRunner(nil, nil)
+	r.Add(Job{Name: "panic", Interval: time.Hour, Run: func(ctx context.Context) error { panic("oops") }})
+
+	if err := r.RunNow(context.Background(), "panic"); err == nil {
+		t.Fatal("expected error from panicking job")
+	}
+
+	// The lock must be released after a panic
+	if err := r.RunNow(context.Background(), "panic"); err == nil {
+		t.Fatal("expected error from second run")
+	}
+	if got := r.Status()[0].Runs; got != 2 {
+		t.Errorf("Runs = %d, want 2", got)
+	}
+}
+
+func TestRunner_SkipsWhenLocked(t *testing.T) {
+	locker := NewLocalLocker()
+	var calls atomic.Int32
+	r := NewRunner(locker, nil)
+	r.Add(Job{Name: "job", Interval: time.Hour, Run: func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+
+	// Simulate another instance holding the lock
+	if ok, _ := locker.TryLock("job", time.Minute); !ok {
+		t.Fatal("expected to acquire lock")
+	}
+	if err := r.RunNow(context.Background(), "job"); err != nil {
+		t.Fatalf("RunNow = %v", err)
+	}
+	if calls.Load() != 0 {
+		t.Error("job should not run while locked")
+	}
+	if got := r.Status()[0].Skipped; got != 1 {
+		t.Errorf("Skipped = %d, want 1", got)
+	}
+
+	locker.Unlock("job")
+	if err := r.RunNow(context.Background(), "job"); err != nil {
+		t.Fatalf("RunNow = %v", err)
+	}
+	if calls.Load() != 1 {
+		t.Errorf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestRunner_StartStop(t *testing.T) {
+	var calls atomic.Int32
+	r := NewRunner(nil, nil)
+	r.Add(Job{Name: "tick", Interval: 10 * time.Millisecond, Run: func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	}})
+
+	r.Start(context.Background())
+	time.Sleep(55 * time.Millisecond)
+	r.Stop()
+
+	if calls.Load() == 0 {
+		t.Error("expected job to run at least once")
+	}
+}
+
+func TestLocalLocker_Expiry(t *testing.T) {
+	l := NewLocalLocker()
+	if ok, _ := l.TryLock("a", 10*time.Millisecond); !ok {
+		t.Fatal("first TryLock should succeed")
+	}
+	if ok, _ := l.TryLock("a", time.Minute); ok {
+		t.Fatal("second TryLock should fail while held")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := l.TryLock("a", time.Minute); !ok {
+		t.Fatal("TryLock should succeed after expiry")
+	}
+}
@@ -0,0 +1,70 @@
+package jobs
+
+import (
+	"sync"
+	"time"
+
+	"gopublic/internal/storage"
+)
+
+// Locker provides single-flight execution of jobs.
+type Locker interface {
+	// TryLock acquires the named lock for at most ttl.
+	// Returns false without error if the lock is held elsewhere.
+	TryLock(name string, ttl time.Duration) (bool, error)
+	// Unlock releases the named lock.
+	Unlock(name string) error
+}
+
+// LocalLocker is an in-process Locker for single-instance deployments.
+type LocalLocker struct {
+	mu   sync.Mutex
+	held map[string]time.Time // name -> lease expiry
+}
+
+// NewLocalLocker creates an in-process locker.
+func NewLocalLocker() *LocalLocker {
+	return &LocalLocker{held: make(map[string]time.Time)}
+}
+
+// TryLock implements Locker.
+func (l *LocalLocker) TryLock(name string, ttl time.Duration) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if expiry, ok := l.held[name]; ok && now.Before(expiry) {
+		return false, nil
+	}
+	l.held[name] = now.Add(ttl)
+	return true, nil
+}
+
+// Unlock implements Locker.
+func (l *LocalLocker) Unlock(name string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.held, name)
+	return nil
+}
+
+// StoreLocker is a Locker backed by the shared database, so that instances
+// using the same database never run the same job concurrently.
+type StoreLocker struct {
+	Owner string // Unique instance identifier (e.g. hostname and PID)
+}
+
+// NewStoreLocker creates a database-backed locker for the given instance.
+func NewStoreLocker(owner string) *StoreLocker {
+	return &StoreLocker{Owner: owner}
+}
+
+// TryLock implements Locker.
+func (l *StoreLocker) TryLock(name string, ttl time.Duration) (bool, error) {
+	return storage.AcquireJobLock(name, l.Owner, ttl)
+}
+
+// Unlock implements Locker.
+func (l *StoreLocker) Unlock(name string) error {
+	return storage.ReleaseJobLock(name, l.Owner)
+}
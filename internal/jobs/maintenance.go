@@ -0,0 +1,168 @@
+package jobs
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/crypto/acme/autocert"
+
+	"gopublic/internal/metrics"
+	"gopublic/internal/storage"
+)
+
+// Reaper removes stale entries and returns how many were removed.
+// Implemented by server.TunnelRegistry and server.UserSessionRegistry.
+type Reaper interface {
+	Reap() int
+}
+
+// SessionCounter reports the number of active sessions.
+// Implemented by server.UserSessionRegistry.
+type SessionCounter interface {
+	Count() int
+}
+
+// BandwidthRollover compacts daily bandwidth counters older than retention
+// into per-user archive rows and drops per-country counters, tunnel uptime
+// and connection events of the same age.
+func BandwidthRollover(interval, retention time.Duration) Job {
+	return Job{
+		Name:     "bandwidth-rollover",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			before := time.Now().Add(-retention).Truncate(24 * time.Hour)
+			n, err := storage.CompactBandwidth(before)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Compacted %d bandwidth record(s) older than %s", n, before.Format("2006-01-02"))
+			}
+			if n, err = storage.PruneGeoUsage(before); err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Pruned %d geo usage record(s) older than %s", n, before.Format("2006-01-02"))
+			}
+			if n, err = storage.PruneDomainUptime(before); err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Pruned %d uptime record(s) older than %s", n, before.Format("2006-01-02"))
+			}
+			if n, err = storage.PruneConnectionEvents(before); err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Pruned %d connection event(s) older than %s", n, before.Format("2006-01-02"))
+			}
+			return nil
+		},
+	}
+}
+
+// DomainRecycling releases domains whose owner has been deleted for longer
+// than grace, leaving time to restore the account.
+func DomainRecycling(interval, grace time.Duration) Job {
+	return Job{
+		Name:     "domain-recycling",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			n, err := storage.RecycleOrphanedDomains(grace)
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Recycled %d orphaned domain(s)", n)
+			}
+			return nil
+		},
+	}
+}
+
+// IdempotencyKeyPruning deletes the public API idempotency keys older
+// than ttl, once their responses are no longer replayed.
+func IdempotencyKeyPruning(interval, ttl time.Duration) Job {
+	return Job{
+		Name:     "idempotency-key-pruning",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			n, err := storage.PruneIdempotencyKeys(time.Now().Add(-ttl))
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				log.Printf("Pruned %d idempotency key(s)", n)
+			}
+			return nil
+		},
+	}
+}
+
+// SessionReaping removes closed sessions left behind in the registries.
+func SessionReaping(interval time.Duration, reapers ...Reaper) Job {
+	return Job{
+		Name:     "session-reaping",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			removed := 0
+			for _, r := range reapers {
+				removed += r.Reap()
+			}
+			if removed > 0 {
+				log.Printf("Reaped %d stale session(s)", removed)
+			}
+			return nil
+		},
+	}
+}
+
+// CertRenewal requests certificates for the given hosts so that autocert
+// obtains or renews them ahead of the first client handshake.
+func CertRenewal(interval time.Duration, manager *autocert.Manager, hosts ...string) Job {
+	return Job{
+		Name:     "acme-renewal",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			for _, host := range hosts {
+				hello := &tls.ClientHelloInfo{ServerName: host}
+				if _, err := manager.GetCertificate(hello); err != nil {
+					return fmt.Errorf("%s: %w", host, err)
+				}
+			}
+			return nil
+		},
+	}
+}
+
+// UsageAggregation periodically publishes service-wide usage gauges.
+func UsageAggregation(interval time.Duration, m *metrics.Metrics, sessions SessionCounter) Job {
+	users := m.NewGauge("gopublic_users_total", "Total number of registered users", nil)
+	active := m.NewGauge("gopublic_active_user_sessions", "Number of users with a connected client", nil)
+	bandwidth := m.NewGauge("gopublic_bandwidth_today_bytes", "Bandwidth used by all users today", nil)
+
+	return Job{
+		Name:     "usage-aggregation",
+		Interval: interval,
+		Run: func(ctx context.Context) error {
+			count, err := storage.GetTotalUserCount()
+			if err != nil {
+				return err
+			}
+			today, err := storage.GetTotalBandwidthToday()
+			if err != nil {
+				return err
+			}
+
+			users.Set(float64(count))
+			bandwidth.Set(float64(today))
+			if sessions != nil {
+				active.Set(float64(sessions.Count()))
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,157 @@
+package metrics
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// OtherDomain aggregates domains beyond the DomainLatency limit.
+const OtherDomain = "_other"
+
+// DefaultMaxLatencyDomains bounds the number of per-domain histograms.
+const DefaultMaxLatencyDomains = 1000
+
+// LatencyBuckets are HDR-style bounds for tunnel response latency:
+// 1ms to ~65s with four sub-buckets per power of two (at most 25% error).
+var LatencyBuckets = HDRBuckets(0.001, 60, 4)
+
+// HDRBuckets returns log-linear bucket bounds starting at min and covering
+// at least max. Each power of two is split into sub linear sub-buckets, so
+// the relative width of every bucket is at most 1/sub.
+func HDRBuckets(min, max float64, sub int) []float64 {
+	if sub < 1 {
+		sub = 1
+	}
+	var buckets []float64
+	for base := min; ; base *= 2 {
+		step := base / float64(sub)
+		for i := 0; i < sub; i++ {
+			b := base + float64(i)*step
+			// Round away float noise so bounds print cleanly
+			buckets = append(buckets, math.Round(b*1e9)/1e9)
+		}
+		if base >= max {
+			return buckets
+		}
+	}
+}
+
+// LatencySummary is a point-in-time view of a latency histogram.
+// Quantiles are bucket upper bounds in seconds.
+type LatencySummary struct {
+	Count uint64  `json:"count"`
+	Mean  float64 `json:"mean"`
+	P50   float64 `json:"p50"`
+	P90   float64 `json:"p90"`
+	P99   float64 `json:"p99"`
+}
+
+// DomainLatency records response latency histograms per domain.
+// Once maxDomains histograms exist, new domains share OtherDomain.
+type DomainLatency struct {
+	m          *Metrics
+	maxDomains int
+
+	mu         sync.RWMutex
+	histograms map[string]*Histogram
+}
+
+// NewDomainLatency creates a per-domain latency recorder registered in m.
+func NewDomainLatency(m *Metrics, maxDomains int) *DomainLatency {
+	if maxDomains <= 0 {
+		maxDomains = DefaultMaxLatencyDomains
+	}
+	return &DomainLatency{
+		m:          m,
+		maxDomains: maxDomains,
+		histograms: make(map[string]*Histogram),
+	}
+}
+
+// Observe records a response latency for the domain.
+func (d *DomainLatency) Observe(domain string, latency time.Duration) {
+	d.histogram(domain).Observe(latency.Seconds())
+}
+
+// Summary returns the latency summary for the domain, if it has any samples.
+func (d *DomainLatency) Summary(domain string) (LatencySummary, bool) {
+	d.mu.RLock()
+	h, ok := d.histograms[domain]
+	d.mu.RUnlock()
+	if !ok || h.Count() == 0 {
+		return LatencySummary{}, false
+	}
+	return h.Summary(), true
+}
+
+// histogram returns the domain's histogram, creating it if needed.
+func (d *DomainLatency) histogram(domain string) *Histogram {
+	d.mu.RLock()
+	h, ok := d.histograms[domain]
+	d.mu.RUnlock()
+	if ok {
+		return h
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if h, ok := d.histograms[domain]; ok {
+		return h
+	}
+	if len(d.histograms) >= d.maxDomains {
+		domain = OtherDomain
+		if h, ok := d.histograms[domain]; ok {
+			return h
+		}
+	}
+	h = d.m.NewHistogram(
+		"gopublic_tunnel_response_seconds",
+		"Time from opening the tunnel stream to receiving response headers",
+		LatencyBuckets,
+		map[string]string{"domain": domain},
+	)
+	d.histograms[domain] = h
+	return h
+}
+
+// Count returns the number of observations.
+func (h *Histogram) Count() uint64 {
+	return h.count.Load()
+}
+
+// Sum returns the sum of all observations.
+func (h *Histogram) Sum() float64 {
+	return float64FromBits(h.sum.Load())
+}
+
+// Quantile estimates the q-quantile (0 < q <= 1) as the upper bound of the
+// bucket containing it. Values above the last bucket report the last bound.
+func (h *Histogram) Quantile(q float64) float64 {
+	total := h.count.Load()
+	if total == 0 || len(h.buckets) == 0 {
+		return 0
+	}
+	rank := uint64(math.Ceil(q * float64(total)))
+	for i, bound := range h.buckets {
+		// Bucket counts are cumulative
+		if h.counts[i].Load() >= rank {
+			return bound
+		}
+	}
+	return h.buckets[len(h.buckets)-1]
+}
+
+// Summary returns count, mean and common quantiles.
+func (h *Histogram) Summary() LatencySummary {
+	s := LatencySummary{
+		Count: h.Count(),
+		P50:   h.Quantile(0.5),
+		P90:   h.Quantile(0.9),
+		P99:   h.Quantile(0.99),
+	}
+	if s.Count > 0 {
+		s.Mean = h.Sum() / float64(s.Count)
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHDRBuckets(t *testing.T) {
+	buckets := HDRBuckets(0.001, 0.004, 4)
+	want := []float64{0.001, 0.00125, 0.0015, 0.00175, 0.002, 0.0025, 0.003, 0.0035, 0.004, 0.005, 0.006, 0.007}
+	if len(buckets) != len(want) {
+		t.Fatalf("HDRBuckets = %v, want %v", buckets, want)
+	}
+	for i := range want {
+		if buckets[i] != want[i] {
+			t.Errorf("bucket %d = %v, want %v", i, buckets[i], want[i])
+		}
+	}
+
+	if last := LatencyBuckets[len(LatencyBuckets)-1]; last < 60 {
+		t.Errorf("LatencyBuckets ends at %v, want at least 60", last)
+	}
+}
+
+func TestHistogramQuantile(t *testing.T) {
+	m := New()
+	h := m.NewHistogram("test_latency", "A test histogram", []float64{0.1, 0.5, 1.0}, nil)
+
+	if got := h.Quantile(0.5); got != 0 {
+		t.Errorf("Quantile on empty histogram = %v, want 0", got)
+	}
+
+	for i := 0; i < 90; i++ {
+		h.Observe(0.05)
+	}
+	for i := 0; i < 9; i++ {
+		h.Observe(0.3)
+	}
+	h.Observe(5)
+
+	tests := []struct {
+		q    float64
+		want float64
+	}{
+		{0.5, 0.1},
+		{0.9, 0.1},
+		{0.95, 0.5},
+		{0.99, 0.5},
+		{1, 1.0}, // Above the last bucket reports the last bound
+	}
+	for _, tt := range tests {
+		if got := h.Quantile(tt.q); got != tt.want {
+			t.Errorf("Quantile(%v) = %v, want %v", tt.q, got, tt.want)
+		}
+	}
+
+	s := h.Summary()
+	if s.Count != 100 {
+		t.Errorf("Count = %d, want 100", s.Count)
+	}
+	if want := (90*0.05 + 9*0.3 + 5) / 100; s.Mean < want-1e-9 || s.Mean > want+1e-9 {
+		t.Errorf("Mean = %v, want %v", s.Mean, want)
+	}
+}
+
+func TestDomainLatency(t *testing.T) {
+	m := New()
+	d := NewDomainLatency(m, 2)
+
+	if _, ok := d.Summary("a.example.com"); ok {
+		t.Error("Summary should report no data for unknown domain")
+	}
+
+	d.Observe("a.example.com", 20*time.Millisecond)
+	d.Observe("a.example.com", 40*time.Millisecond)
+	d.Observe("b.example.com", time.Second)
+
+	s, ok := d.Summary("a.example.com")
+	if !ok || s.Count != 2 {
+		t.Fatalf("Summary(a) = %+v, %v", s, ok)
+	}
+	if s.P50 < 0.02 || s.P50 > 0.025 {
+		t.Errorf("P50 = %v, want within 25%% of 20ms", s.P50)
+	}
+
+	output := m.String()
+	if !strings.Contains(output, `gopublic_tunnel_response_seconds_count{domain="a.example.com"} 2`) {
+		t.Errorf("Expected per-domain histogram, got: %s", output)
+	}
+}
+
+func TestDomainLatency_CapsDomains(t *testing.T) {
+	m := New()
+	d := NewDomainLatency(m, 3)
+
+	for i := 0; i < 10; i++ {
+		d.Observe(fmt.Sprintf("d%d.example.com", i), time.Millisecond)
+	}
+
+	if _, ok := d.Summary("d9.example.com"); ok {
+		t.Error("domains over the limit should not get their own histogram")
+	}
+	s, ok := d.Summary(OtherDomain)
+	if !ok || s.Count != 7 {
+		t.Errorf("Summary(%s) = %+v, %v; want 7 samples", OtherDomain, s, ok)
+	}
+}
@@ -7,6 +7,8 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/time/rate"
+
+	"gopublic/internal/errorpage"
 )
 
 // RateLimiterConfig configures the rate limiter behavior.
@@ -109,10 +111,7 @@ func RateLimitMiddleware(limiter *IPRateLimiter) gin.HandlerFunc {
 
 		if !limiter.Allow(ip) {
 			c.Header("Retry-After", "1")
-			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
-				"error": "rate limit exceeded",
-				"code":  "RATE_LIMIT_EXCEEDED",
-			})
+			errorpage.Abort(c, http.StatusTooManyRequests, errorpage.CodeRateLimited)
 			return
 		}
 
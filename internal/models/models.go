@@ -1,6 +1,9 @@
 package models
 
 import (
+	"errors"
+	"net/mail"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -8,22 +11,109 @@ import (
 
 type User struct {
 	gorm.Model
-	Email           string
-	TelegramID      *int64  `gorm:"uniqueIndex"` // nil if not linked via Telegram
-	YandexID        *string `gorm:"uniqueIndex"` // nil if not linked via Yandex
+	Email           string  // Empty if unknown, see NormalizeEmail
+	TelegramID      *int64  `gorm:"uniqueIndex"`                  // nil if not linked via Telegram
+	YandexID        *string `gorm:"uniqueIndex"`                  // nil if not linked via Yandex
+	GitHubID        *string `gorm:"column:github_id;uniqueIndex"` // nil if not linked via GitHub
+	GoogleID        *string `gorm:"uniqueIndex"`                  // nil if not linked via Google
+	OIDCSubject     *string `gorm:"uniqueIndex"`                  // nil if not linked via corporate SSO
 	FirstName       string
 	LastName        string
 	Username        string
 	PhotoURL        string
 	TermsAcceptedAt *time.Time // nil if terms not yet accepted
+	Plan            string     `gorm:"default:free"`   // Service plan, e.g. PlanFree
+	Status          string     `gorm:"default:active"` // UserStatusActive, UserStatusPending or UserStatusBanned
+	InviteCode      string     // Invite used at signup, empty if none
+	InvitedBy       *uint      `gorm:"index"` // Owner of the invite, nil for admin invites or none
+	Role            string     // RoleAdmin, RoleMember or RoleViewer; empty = RoleMember
+
+	StripeCustomerID     string `gorm:"index"` // Set after the first checkout
+	StripeSubscriptionID string // Current subscription, empty on the free plan
+
+	QuotaAlertsDisabled bool // Opted out of quota usage notifications
+
+	TOTPSecret  string // Base32 authenticator secret, empty if not set up
+	TOTPEnabled bool   // Secret confirmed; destructive actions require a code
+
+	StatusSlug *string `gorm:"uniqueIndex"` // Name of the public status page, nil if not published
+}
+
+// ErrInvalidEmail is returned for a malformed email address.
+var ErrInvalidEmail = errors.New("invalid email address")
+
+// maxEmailLength is the longest address SMTP can deliver to (RFC 5321).
+const maxEmailLength = 254
+
+// NormalizeEmail returns a bare address like "ivan@example.com" trimmed
+// and lowercased, or ErrInvalidEmail if it is anything else, including
+// an address with a display name.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Name != "" || addr.Address != email || len(email) > maxEmailLength {
+		return "", ErrInvalidEmail
+	}
+	if _, domain, _ := strings.Cut(email, "@"); !strings.Contains(domain, ".") {
+		return "", ErrInvalidEmail
+	}
+	return strings.ToLower(email), nil
+}
+
+// BeforeSave refuses to store a malformed Email.
+func (u *User) BeforeSave(tx *gorm.DB) error {
+	if u.Email == "" {
+		return nil
+	}
+	_, err := NormalizeEmail(u.Email)
+	return err
 }
 
+// Social login providers linked to users with OAuth, see User.GitHubID
+// and User.GoogleID.
+const (
+	ProviderGitHub = "github"
+	ProviderGoogle = "google"
+)
+
+// Account states. Pending accounts have no token or domains until an
+// administrator approves them. Banned accounts lost their tokens and
+// cannot sign in until an administrator lifts the ban.
+const (
+	UserStatusActive  = "active"
+	UserStatusPending = "pending"
+	UserStatusBanned  = "banned"
+)
+
+// Dashboard roles, from most to least privileged. The owner runs the
+// instance (ADMIN_TELEGRAM_ID) and is never stored; admins moderate all
+// users and domains; members manage their own token and domains; viewers
+// can look but not change anything. Roles are set by admins or from
+// corporate SSO groups. The same roles rank the members of an
+// organization, see OrganizationMember.
+const (
+	RoleOwner  = "owner"
+	RoleAdmin  = "admin"
+	RoleMember = "member"
+	RoleViewer = "viewer"
+)
+
+// PlanFree is the plan assigned to newly registered users.
+const PlanFree = "free"
+
 type Token struct {
 	gorm.Model
 	TokenString string `gorm:"uniqueIndex"` // Deprecated: for backward compatibility
 	TokenHash   string `gorm:"uniqueIndex"` // SHA256 hash of the token
 	UserID      uint
 	User        User
+
+	ExpiresAt   *time.Time // nil = never
+	RefreshHash string     `gorm:"index"` // SHA256 hash of the refresh token, empty if none
+
+	// Agent that obtained the token with a join token; empty for the
+	// user's own token, the one shown in the dashboard
+	Agent string
 }
 
 type Domain struct {
@@ -31,12 +121,153 @@ type Domain struct {
 	Name   string `gorm:"uniqueIndex"`
 	UserID uint
 	User   User
+
+	SuspendedAt   *time.Time // nil unless taken down by an administrator
+	SuspendReason string
+
+	Schedule         string // Allowed time windows (see package schedule); empty = always
+	ScheduleTimezone string // IANA time zone of Schedule; empty = UTC
+
+	OnStatusPage bool // Listed on the owner's public status page
+	Hub          bool // Lists the owner's online tunnels while no tunnel is bound to it
+	Capture      bool // The ingress records recent exchanges for the dashboard
+
+	Reserved bool // Chosen by the user rather than assigned at signup
+}
+
+// DomainTransfer hands a reserved domain to another user once they accept.
+// Resolved transfers are kept as the audit trail of the domain's owners.
+type DomainTransfer struct {
+	gorm.Model
+	DomainName string `gorm:"index"` // Name at the time of the transfer
+	FromUserID uint   `gorm:"index"`
+	FromUser   User
+	ToUserID   uint `gorm:"index"`
+	ToUser     User
+	Status     string     `gorm:"default:pending"` // TransferPending, TransferAccepted, ...
+	ResolvedAt *time.Time // nil while pending
+}
+
+// Domain transfer states.
+const (
+	TransferPending   = "pending"
+	TransferAccepted  = "accepted"
+	TransferDeclined  = "declined"  // By the recipient
+	TransferCancelled = "cancelled" // By the owner
+)
+
+// Organization groups users under roles of their own, independent of
+// User.Role. The user who creates it is its owner.
+type Organization struct {
+	gorm.Model
+	Name string
+}
+
+// OrganizationMember gives a user a role in an organization. Each
+// organization has one RoleOwner member.
+type OrganizationMember struct {
+	gorm.Model
+	OrganizationID uint `gorm:"uniqueIndex:idx_organization_member"`
+	Organization   Organization
+	UserID         uint `gorm:"uniqueIndex:idx_organization_member;index"`
+	User           User
+	Role           string // RoleOwner, RoleAdmin, RoleMember or RoleViewer
+}
+
+// Invite is a signup code with a usage limit
+type Invite struct {
+	gorm.Model
+	Code      string `gorm:"uniqueIndex"`
+	CreatedBy *uint  `gorm:"index"` // Inviting user, nil for codes created by the admin
+	MaxUses   int    // 0 = unlimited
+	Uses      int
+	ExpiresAt *time.Time // nil = never
 }
 
+// JoinToken lets an agent obtain a token of its own once, so that machines
+// can be provisioned without the user's token
+type JoinToken struct {
+	gorm.Model
+	UserID    uint   `gorm:"index"`
+	TokenHash string `gorm:"uniqueIndex"` // SHA256 hash of the join token
+	ExpiresAt time.Time
+	UsedAt    *time.Time // nil until redeemed
+	UsedBy    string     // Agent that redeemed it
+}
+
+// APIToken authenticates scripts against the public API (/api/v1). It
+// cannot open tunnels, and tunnel tokens are not accepted by the API.
+type APIToken struct {
+	gorm.Model
+	UserID     uint       `gorm:"index"`
+	Name       string     // Chosen by the user, e.g. "terraform"
+	TokenHash  string     `gorm:"uniqueIndex"` // SHA256 hash of the API token
+	LastUsedAt *time.Time // nil until first used, updated at most once a minute
+}
+
+// IdempotencyKey remembers the response to a public API request sent with
+// an Idempotency-Key header, so that a retry is answered the same way
+// instead of acting twice. Keys are scoped to the user.
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primarykey"`
+	CreatedAt   time.Time `gorm:"index"`
+	UserID      uint      `gorm:"uniqueIndex:idx_idempotency_user_key"`
+	Key         string    `gorm:"uniqueIndex:idx_idempotency_user_key"`
+	RequestHash string    // SHA256 of the method, path and body
+	Status      int       // Response status, 0 while the first request runs
+	Response    []byte    // Response body
+}
+
+// Device is a client that has authenticated with a user's token. Devices
+// are told apart by a fingerprint of their address and reported name.
+// Revoking a device rotates the token; the fingerprint does not gate access.
+type Device struct {
+	gorm.Model
+	UserID      uint   `gorm:"uniqueIndex:idx_user_fingerprint"`
+	Fingerprint string `gorm:"uniqueIndex:idx_user_fingerprint"`
+	Name        string // Host name and platform reported by the client
+	IP          string
+	LastSeenAt  time.Time
+	Connections int64
+	RevokedAt   *time.Time // nil unless revoked and not reconnected with a new token
+}
+
+// AlertRule notifies a user when one of their tunnels stays offline
+type AlertRule struct {
+	gorm.Model
+	UserID         uint `gorm:"index"`
+	User           User
+	Domain         string `gorm:"index"` // FQDN of the monitored tunnel
+	OfflineMinutes int    // Alert once the tunnel has been offline this long
+	Channel        string // AlertChannelTelegram, AlertChannelEmail or AlertChannelWebhook
+	WebhookURL     string
+	LastOnlineAt   *time.Time // nil until the tunnel is first seen online
+	FiredAt        *time.Time // Set while an offline alert is outstanding
+}
+
+// TunnelDefinition is a tunnel defined in the dashboard and run by the
+// user's managed clients whose labels match Selector
+type TunnelDefinition struct {
+	gorm.Model
+	UserID   uint   `gorm:"index"`
+	Name     string // Unique per user
+	Domain   string // Domain name without the root domain
+	Port     int    // Local port on the agent's machine
+	Selector string // Label selector "key=value"; empty matches every managed client
+}
+
+// Alert delivery channels.
+const (
+	AlertChannelTelegram = "telegram"
+	AlertChannelEmail    = "email"
+	AlertChannelWebhook  = "webhook"
+)
+
 // AbuseReport stores user reports about malicious tunnels
 type AbuseReport struct {
 	gorm.Model
 	TunnelURL     string // URL of the reported tunnel
+	Domain        string `gorm:"index"` // Hostname parsed from TunnelURL
 	ReportType    string // phishing, malware, spam, other
 	Description   string // Description of the issue
 	ReporterEmail string // Optional email for contact
@@ -50,3 +281,67 @@ type UserBandwidth struct {
 	Date      time.Time `gorm:"uniqueIndex:idx_user_date;type:date"` // Date only (no time)
 	BytesUsed int64
 }
+
+// UserGeoUsage counts tunnel requests per user, day and visitor country
+type UserGeoUsage struct {
+	gorm.Model
+	UserID    uint      `gorm:"uniqueIndex:idx_user_date_country"`
+	Date      time.Time `gorm:"uniqueIndex:idx_user_date_country;type:date"`
+	Country   string    `gorm:"uniqueIndex:idx_user_date_country"` // ISO code, empty if unknown
+	Requests  int64
+	BytesUsed int64
+}
+
+// DomainUptime counts the seconds a tunnel was online per day
+type DomainUptime struct {
+	gorm.Model
+	Domain  string    `gorm:"uniqueIndex:idx_domain_date"` // FQDN of the tunnel
+	Date    time.Time `gorm:"uniqueIndex:idx_domain_date;type:date"`
+	Seconds int64
+}
+
+// ConnectionEvent is a tunnel of a domain connecting, binding after the
+// handshake or disconnecting, kept to diagnose flapping tunnels
+type ConnectionEvent struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	UserID    uint      `gorm:"index:idx_connection_event_user_domain"`
+	Domain    string    `gorm:"index:idx_connection_event_user_domain"` // FQDN of the tunnel
+	Type      string    // ConnectionEventConnect, ConnectionEventBind or ConnectionEventDisconnect
+	Reason    string    // Disconnect* for disconnects
+	Detail    string    // Error or message behind the reason, if any
+	Agent     string    // Agent name, "" for the regular session
+}
+
+// Connection event types.
+const (
+	ConnectionEventConnect    = "connect"
+	ConnectionEventBind       = "bind" // Bound to a running session
+	ConnectionEventDisconnect = "disconnect"
+)
+
+// Disconnect reasons of connection events.
+const (
+	DisconnectClientExit = "client_exit" // The client closed its connection
+	DisconnectForced     = "forced"      // The server closed the session or revoked the domain
+	DisconnectNetwork    = "network"     // The connection failed or timed out
+)
+
+// LoginToken is a one-time sign-in link sent by email. Only the hash of
+// the token in the link is stored.
+type LoginToken struct {
+	ID        uint      `gorm:"primarykey"`
+	CreatedAt time.Time `gorm:"index"`
+	Email     string    // Normalized address the link was sent to
+	TokenHash string    `gorm:"uniqueIndex"` // SHA256 hash of the token
+	ExpiresAt time.Time
+	UsedAt    *time.Time // nil until the link is opened
+}
+
+// JobLock is a lease that ensures a background job runs on only one
+// server instance at a time
+type JobLock struct {
+	Name      string `gorm:"primaryKey"`
+	Owner     string // Instance holding the lease
+	ExpiresAt time.Time
+}
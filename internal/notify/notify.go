@@ -0,0 +1,263 @@
+// Package notify delivers account notifications to users, over Telegram
+// for users who signed in with Telegram and over email otherwise.
+//
+// Delivery is rate limited in two ways: every message has a key that is
+// sent at most once per Cooldown to the same user, and all messages share
+// a global send rate so that bursts stay within the Telegram bot limits.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+// ErrNoChannel is returned when a user cannot be reached.
+var ErrNoChannel = errors.New("no notification channel for user")
+
+// Defaults for the delivery limits.
+const (
+	DefaultCooldown = 24 * time.Hour
+	DefaultInterval = 50 * time.Millisecond // 20 messages per second
+)
+
+// SMTPConfig describes the mail relay used for email notifications.
+type SMTPConfig struct {
+	Addr     string // host:port
+	Username string // Empty = no authentication
+	Password string
+	From     string
+}
+
+// Message is a notification for a single user.
+type Message struct {
+	Key     string // Deduplication key, e.g. "bandwidth:80"
+	Subject string // Email subject
+	Text    string // Plain text body
+}
+
+// Notifier sends messages to users. A nil Notifier sends nothing.
+type Notifier struct {
+	BotToken    string
+	SMTP        *SMTPConfig
+	Cooldown    time.Duration // Minimum time between messages with the same key
+	Interval    time.Duration // Minimum time between any two messages
+	TelegramURL string        // Defaults to https://api.telegram.org
+	Client      *http.Client  // Defaults to http.DefaultClient
+
+	sendMail func(addr string, a smtp.Auth, from string, to []string, msg []byte) error
+
+	mu   sync.Mutex
+	sent map[string]time.Time // "userID:key" -> last delivery
+	next time.Time            // Earliest time the next message may go out
+}
+
+// New creates a notifier. It returns nil if neither a bot token nor an
+// SMTP relay is configured.
+func New(botToken string, smtpConfig *SMTPConfig) *Notifier {
+	if botToken == "" && smtpConfig == nil {
+		return nil
+	}
+	return &Notifier{
+		BotToken: botToken,
+		SMTP:     smtpConfig,
+		Cooldown: DefaultCooldown,
+		Interval: DefaultInterval,
+		sendMail: smtp.SendMail,
+	}
+}
+
+// Send delivers the message unless one with the same key was sent to the
+// user within the cooldown. It returns false if the message was skipped.
+func (n *Notifier) Send(ctx context.Context, user *models.User, msg Message) (bool, error) {
+	if n == nil {
+		return false, nil
+	}
+	if !n.reserve(user.ID, msg.Key) {
+		return false, nil
+	}
+	if err := n.wait(ctx); err != nil {
+		n.release(user.ID, msg.Key)
+		return false, err
+	}
+
+	var err error
+	switch {
+	case user.TelegramID != nil && n.BotToken != "":
+		err = n.sendTelegram(ctx, *user.TelegramID, msg.Text)
+	case user.Email != "" && n.SMTP != nil:
+		err = n.sendEmail(user.Email, msg)
+	default:
+		err = ErrNoChannel
+	}
+	if err != nil {
+		n.release(user.ID, msg.Key) // Allow a retry on the next trigger
+		return false, err
+	}
+	return true, nil
+}
+
+// SendVia delivers the message over the given channel
+// (models.AlertChannelTelegram or models.AlertChannelEmail), ignoring the
+// cooldown. It is used for notifications the caller deduplicates itself.
+func (n *Notifier) SendVia(ctx context.Context, user *models.User, channel string, msg Message) error {
+	if n == nil || !n.CanReach(user, channel) {
+		return ErrNoChannel
+	}
+	if err := n.wait(ctx); err != nil {
+		return err
+	}
+	if channel == models.AlertChannelTelegram {
+		return n.sendTelegram(ctx, *user.TelegramID, msg.Text)
+	}
+	return n.sendEmail(user.Email, msg)
+}
+
+// SendEmail emails an address that need not belong to a user yet, such
+// as a sign-in link. Like SendVia it ignores the cooldown.
+func (n *Notifier) SendEmail(ctx context.Context, to string, msg Message) error {
+	if n == nil || n.SMTP == nil {
+		return ErrNoChannel
+	}
+	if err := n.wait(ctx); err != nil {
+		return err
+	}
+	return n.sendEmail(to, msg)
+}
+
+// CanReach reports whether the user can be notified over the channel.
+func (n *Notifier) CanReach(user *models.User, channel string) bool {
+	if n == nil {
+		return false
+	}
+	switch channel {
+	case models.AlertChannelTelegram:
+		return user.TelegramID != nil && n.BotToken != ""
+	case models.AlertChannelEmail:
+		return user.Email != "" && n.SMTP != nil
+	}
+	return false
+}
+
+// reserve records the delivery of key to the user, or reports false if
+// it is still within the cooldown.
+func (n *Notifier) reserve(userID uint, key string) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.sent == nil {
+		n.sent = make(map[string]time.Time)
+	}
+	id := fmt.Sprintf("%d:%s", userID, key)
+	now := time.Now()
+	if last, ok := n.sent[id]; ok && now.Sub(last) < n.Cooldown {
+		return false
+	}
+	n.sent[id] = now
+
+	// Drop expired entries so the map does not grow without bound
+	for k, last := range n.sent {
+		if now.Sub(last) >= n.Cooldown {
+			delete(n.sent, k)
+		}
+	}
+	return true
+}
+
+func (n *Notifier) release(userID uint, key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	delete(n.sent, fmt.Sprintf("%d:%s", userID, key))
+}
+
+// wait blocks until the global send rate allows another message.
+func (n *Notifier) wait(ctx context.Context) error {
+	n.mu.Lock()
+	now := time.Now()
+	slot := n.next
+	if slot.Before(now) {
+		slot = now
+	}
+	n.next = slot.Add(n.Interval)
+	n.mu.Unlock()
+
+	delay := time.Until(slot)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *Notifier) sendTelegram(ctx context.Context, chatID int64, text string) error {
+	baseURL := n.TelegramURL
+	if baseURL == "" {
+		baseURL = "https://api.telegram.org"
+	}
+	payload, err := json.Marshal(map[string]interface{}{
+		"chat_id": chatID,
+		"text":    text,
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/bot%s/sendMessage", baseURL, n.BotToken), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := n.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram request failed: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("telegram returned %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (n *Notifier) sendEmail(to string, msg Message) error {
+	var auth smtp.Auth
+	if n.SMTP.Username != "" {
+		host, _, _ := strings.Cut(n.SMTP.Addr, ":")
+		auth = smtp.PlainAuth("", n.SMTP.Username, n.SMTP.Password, host)
+	}
+	return n.sendMail(n.SMTP.Addr, auth, n.SMTP.From, []string{to}, buildEmail(n.SMTP.From, to, msg))
+}
+
+// buildEmail formats a plain text UTF-8 email.
+func buildEmail(from, to string, msg Message) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", to)
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.BEncoding.Encode("UTF-8", msg.Subject))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Text, "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return b.Bytes()
+}
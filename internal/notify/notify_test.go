@@ -0,0 +1,148 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+func TestNew_Disabled(t *testing.T) {
+	if New("", nil) != nil {
+		t.Error("expected nil notifier without channels")
+	}
+	var n *Notifier
+	if sent, err := n.Send(context.Background(), &models.User{}, Message{Key: "k"}); sent || err != nil {
+		t.Errorf("nil notifier Send() = %v, %v", sent, err)
+	}
+}
+
+func TestSend_TelegramWithCooldown(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		if r.URL.Path != "/botTOKEN/sendMessage" {
+			t.Errorf("path = %q", r.URL.Path)
+		}
+		var body struct {
+			ChatID int64  `json:"chat_id"`
+			Text   string `json:"text"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.ChatID != 42 || body.Text != "hello" {
+			t.Errorf("unexpected payload %+v (%v)", body, err)
+		}
+	}))
+	defer srv.Close()
+
+	n := New("TOKEN", nil)
+	n.TelegramURL = srv.URL
+	n.Interval = 0
+	tgID := int64(42)
+	user := &models.User{TelegramID: &tgID}
+	user.ID = 1
+
+	for i := 0; i < 3; i++ {
+		if _, err := n.Send(context.Background(), user, Message{Key: "bandwidth:80", Text: "hello"}); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("telegram called %d times, want 1 within the cooldown", got)
+	}
+
+	if sent, _ := n.Send(context.Background(), user, Message{Key: "bandwidth:100", Text: "hello"}); !sent {
+		t.Error("a different key should be delivered")
+	}
+}
+
+func TestSend_EmailFallback(t *testing.T) {
+	n := New("", &SMTPConfig{Addr: "mail.example.com:587", From: "noreply@example.com"})
+	n.Interval = 0
+	var gotTo []string
+	var gotMsg string
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotTo, gotMsg = to, string(msg)
+		return nil
+	}
+
+	user := &models.User{Email: "user@example.com"}
+	sent, err := n.Send(context.Background(), user, Message{Key: "k", Subject: "Лимит", Text: "line1\nline2"})
+	if err != nil || !sent {
+		t.Fatalf("Send() = %v, %v", sent, err)
+	}
+	if len(gotTo) != 1 || gotTo[0] != "user@example.com" {
+		t.Errorf("recipients = %v", gotTo)
+	}
+	if !strings.Contains(gotMsg, "Subject: =?UTF-8?b?") || !strings.Contains(gotMsg, "line1\r\nline2") {
+		t.Errorf("unexpected email:\n%s", gotMsg)
+	}
+}
+
+func TestSendEmail(t *testing.T) {
+	if err := New("bot-token", nil).SendEmail(context.Background(), "user@example.com", Message{}); err != ErrNoChannel {
+		t.Errorf("SendEmail() without SMTP error = %v, want ErrNoChannel", err)
+	}
+
+	n := New("", &SMTPConfig{Addr: "mail.example.com:587", From: "noreply@example.com"})
+	n.Interval = 0
+	var gotTo []string
+	n.sendMail = func(addr string, a smtp.Auth, from string, to []string, msg []byte) error {
+		gotTo = to
+		return nil
+	}
+	if err := n.SendEmail(context.Background(), "new@example.com", Message{Subject: "Вход", Text: "link"}); err != nil {
+		t.Fatalf("SendEmail() error = %v", err)
+	}
+	if len(gotTo) != 1 || gotTo[0] != "new@example.com" {
+		t.Errorf("recipients = %v", gotTo)
+	}
+}
+
+func TestSend_FailureAllowsRetry(t *testing.T) {
+	n := New("", &SMTPConfig{Addr: "mail.example.com:25"})
+	n.Interval = 0
+	fail := true
+	n.sendMail = func(string, smtp.Auth, string, []string, []byte) error {
+		if fail {
+			return errors.New("relay down")
+		}
+		return nil
+	}
+
+	user := &models.User{Email: "user@example.com"}
+	if _, err := n.Send(context.Background(), user, Message{Key: "k"}); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	fail = false
+	if sent, err := n.Send(context.Background(), user, Message{Key: "k"}); !sent || err != nil {
+		t.Errorf("retry Send() = %v, %v", sent, err)
+	}
+}
+
+func TestSend_NoChannel(t *testing.T) {
+	n := New("TOKEN", nil)
+	if _, err := n.Send(context.Background(), &models.User{Email: "user@example.com"}, Message{Key: "k"}); !errors.Is(err, ErrNoChannel) {
+		t.Errorf("Send() error = %v, want ErrNoChannel", err)
+	}
+}
+
+func TestWait_SpacesMessages(t *testing.T) {
+	n := &Notifier{Interval: 20 * time.Millisecond}
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := n.wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+		t.Errorf("3 messages took %v, want at least 40ms", elapsed)
+	}
+}
@@ -0,0 +1,110 @@
+// Package pubsub propagates control events between server instances.
+//
+// A single-instance deployment uses LocalBus, which delivers events
+// in-process. Multi-instance deployments use RedisBus so that an event
+// published on one node reaches the node that owns the affected session.
+package pubsub
+
+import (
+	"context"
+	"sync"
+)
+
+// EventType identifies the kind of cross-instance event.
+type EventType string
+
+const (
+	// EventForceDisconnect closes a user's active tunnel session.
+	EventForceDisconnect EventType = "force_disconnect"
+	// EventQuotaExceeded signals that a user has used up the daily bandwidth limit.
+	EventQuotaExceeded EventType = "quota_exceeded"
+	// EventDomainRevoked stops routing traffic for a domain.
+	EventDomainRevoked EventType = "domain_revoked"
+	// EventDomainSuspended takes a domain down and serves a takedown page instead.
+	EventDomainSuspended EventType = "domain_suspended"
+	// EventDomainUnsuspended lifts a takedown.
+	EventDomainUnsuspended EventType = "domain_unsuspended"
+	// EventDomainScheduled announces a change of a domain's time windows.
+	EventDomainScheduled EventType = "domain_scheduled"
+	// EventHubChanged announces that a user chose or removed a hub domain.
+	EventHubChanged EventType = "hub_changed"
+	// EventCaptureChanged announces that server-side capture of a domain was turned on or off.
+	EventCaptureChanged EventType = "capture_changed"
+	// EventAgentCommand delivers a remote command to one of a user's sessions.
+	EventAgentCommand EventType = "agent_command"
+	// EventPlanChanged applies a user's new service plan to running tunnels.
+	EventPlanChanged EventType = "plan_changed"
+	// EventDefinitionsChanged reloads a user's managed sessions after a
+	// change of their tunnel definitions.
+	EventDefinitionsChanged EventType = "definitions_changed"
+)
+
+// Event is a control event shared between server instances.
+type Event struct {
+	Type     EventType `json:"type"`
+	UserID   uint      `json:"user_id,omitempty"`
+	Domain   string    `json:"domain,omitempty"` // FQDN for domain events
+	Reason   string    `json:"reason,omitempty"`
+	Plan     string    `json:"plan,omitempty"`     // New plan for EventPlanChanged
+	Device   string    `json:"device,omitempty"`   // Device fingerprint for EventForceDisconnect; empty = any
+	Agent    string    `json:"agent,omitempty"`    // Agent name for EventAgentCommand; empty = the regular session
+	Command  string    `json:"command,omitempty"`  // protocol.Command* for EventAgentCommand
+	Selector string    `json:"selector,omitempty"` // Label selector of the changed definition for EventDefinitionsChanged
+	Origin   string    `json:"origin,omitempty"`   // Instance that published the event
+}
+
+// Handler processes an event received from the bus.
+type Handler func(Event)
+
+// Bus publishes events to all server instances, including the publisher.
+type Bus interface {
+	Publish(ctx context.Context, event Event) error
+	Subscribe(handler Handler)
+	Close() error
+}
+
+// handlers is a concurrency-safe list of subscribers shared by bus implementations.
+type handlers struct {
+	mu   sync.RWMutex
+	list []Handler
+}
+
+func (h *handlers) add(handler Handler) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.list = append(h.list, handler)
+}
+
+func (h *handlers) dispatch(event Event) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for _, handler := range h.list {
+		handler(event)
+	}
+}
+
+// LocalBus delivers events to subscribers in the same process.
+type LocalBus struct {
+	handlers handlers
+}
+
+// NewLocalBus creates an in-process bus.
+func NewLocalBus() *LocalBus {
+	return &LocalBus{}
+}
+
+// Publish delivers the event synchronously to all subscribers.
+func (b *LocalBus) Publish(ctx context.Context, event Event) error {
+	b.handlers.dispatch(event)
+	return nil
+}
+
+// Subscribe registers a handler for all events.
+func (b *LocalBus) Subscribe(handler Handler) {
+	b.handlers.add(handler)
+}
+
+// Close implements Bus.
+func (b *LocalBus) Close() error {
+	return nil
+}
@@ -0,0 +1,75 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestLocalBus_Delivery(t *testing.T) {
+	bus := NewLocalBus()
+
+	var got []Event
+	bus.Subscribe(func(e Event) { got = append(got, e) })
+	bus.Subscribe(func(e Event) { got = append(got, e) })
+
+	event := Event{Type: EventForceDisconnect, UserID: 42}
+	if err := bus.Publish(context.Background(), event); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+
+	if len(got) != 2 || got[0] != event || got[1] != event {
+		t.Errorf("got %+v, want event delivered to both handlers", got)
+	}
+}
+
+func TestRedisBus_Delivery(t *testing.T) {
+	srv := miniredis.RunT(t)
+
+	a, err := NewRedisBus("redis://"+srv.Addr(), "events", "node-a")
+	if err != nil {
+		t.Fatalf("NewRedisBus: %v", err)
+	}
+	defer a.Close()
+	b, err := NewRedisBus("redis://"+srv.Addr(), "events", "node-b")
+	if err != nil {
+		t.Fatalf("NewRedisBus: %v", err)
+	}
+	defer b.Close()
+
+	got := make(chan Event, 2)
+	a.Subscribe(func(e Event) { got <- e })
+	b.Subscribe(func(e Event) { got <- e })
+
+	// Wait until both instances are subscribed
+	deadline := time.Now().Add(5 * time.Second)
+	for srv.PubSubNumSub("events")["events"] < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("buses did not subscribe")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err := a.Publish(context.Background(), Event{Type: EventDomainRevoked, Domain: "misty-river.example.com"}); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	want := Event{Type: EventDomainRevoked, Domain: "misty-river.example.com", Origin: "node-a"}
+	for i := 0; i < 2; i++ {
+		select {
+		case e := <-got:
+			if e != want {
+				t.Errorf("event = %+v, want %+v", e, want)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("event not delivered to both instances")
+		}
+	}
+}
+
+func TestNewRedisBus_InvalidURL(t *testing.T) {
+	if _, err := NewRedisBus("http://localhost", "ch", "node"); err == nil {
+		t.Error("expected error for non-redis scheme")
+	}
+}
@@ -0,0 +1,94 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisBus propagates events through a Redis pub/sub channel.
+// Every instance subscribes to the same channel, so an event published on
+// one node is delivered to all of them, including the publisher.
+//
+// Delivery is at most once: Redis does not buffer pub/sub messages, so
+// events published while an instance is resubscribing after a connection
+// loss never reach it. The database stays the source of truth; a missed
+// force-disconnect or domain revocation takes effect when the affected
+// client next connects, as tokens and domains are checked on every
+// handshake.
+type RedisBus struct {
+	client  *redis.Client
+	sub     *redis.PubSub
+	channel string
+	origin  string
+
+	handlers handlers
+
+	done chan struct{}
+}
+
+// NewRedisBus creates a bus for the given Redis URL
+// (redis://[:password@]host[:port] or rediss:// for TLS) and starts
+// the subscriber. origin identifies this instance in published events.
+func NewRedisBus(rawURL, channel, origin string) (*RedisBus, error) {
+	opts, err := redis.ParseURL(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	b := &RedisBus{
+		client:  client,
+		sub:     client.Subscribe(context.Background(), channel),
+		channel: channel,
+		origin:  origin,
+		done:    make(chan struct{}),
+	}
+	go b.receive()
+	return b, nil
+}
+
+// Publish sends the event to all instances.
+func (b *RedisBus) Publish(ctx context.Context, event Event) error {
+	if event.Origin == "" {
+		event.Origin = b.origin
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, b.channel, payload).Err()
+}
+
+// Subscribe registers a handler for events from all instances.
+func (b *RedisBus) Subscribe(handler Handler) {
+	b.handlers.add(handler)
+}
+
+// Close stops the subscriber and closes connections.
+func (b *RedisBus) Close() error {
+	err := b.sub.Close()
+	<-b.done
+	if cerr := b.client.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// receive dispatches messages until the subscription is closed. The client
+// reconnects and resubscribes on its own after connection failures.
+func (b *RedisBus) receive() {
+	defer close(b.done)
+
+	for msg := range b.sub.Channel() {
+		var event Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			log.Printf("Ignoring malformed event on %s: %v", b.channel, err)
+			continue
+		}
+		b.handlers.dispatch(event)
+	}
+}
@@ -0,0 +1,67 @@
+package scan
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// HTTPScanner posts the body to a callback URL. Request metadata is sent in
+// X-Gopublic-* headers. The service answers 204 for a clean body, or 200
+// with {"verdict": "clean"|"block", "reason": "..."}.
+type HTTPScanner struct {
+	URL    string
+	Client *http.Client // Defaults to http.DefaultClient
+}
+
+// httpVerdict is the JSON reply of the callback.
+type httpVerdict struct {
+	Verdict string `json:"verdict"`
+	Reason  string `json:"reason"`
+}
+
+// Scan implements Scanner.
+func (s *HTTPScanner) Scan(ctx context.Context, req *http.Request, body []byte) (Verdict, error) {
+	scanReq, err := http.NewRequestWithContext(ctx, http.MethodPost, s.URL, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	scanReq.Header.Set("Content-Type", "application/octet-stream")
+	scanReq.Header.Set("X-Gopublic-Host", req.Host)
+	scanReq.Header.Set("X-Gopublic-Method", req.Method)
+	scanReq.Header.Set("X-Gopublic-Path", req.URL.RequestURI())
+	scanReq.Header.Set("X-Gopublic-Content-Type", req.Header.Get("Content-Type"))
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(scanReq)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNoContent:
+		return Verdict{}, nil
+	case http.StatusOK:
+		var v httpVerdict
+		if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&v); err != nil {
+			return Verdict{}, fmt.Errorf("invalid scan reply: %w", err)
+		}
+		switch v.Verdict {
+		case "clean":
+			return Verdict{}, nil
+		case "block":
+			return Verdict{Blocked: true, Reason: v.Reason}, nil
+		default:
+			return Verdict{}, fmt.Errorf("unknown scan verdict %q", v.Verdict)
+		}
+	default:
+		return Verdict{}, fmt.Errorf("scan service returned %s", resp.Status)
+	}
+}
@@ -0,0 +1,110 @@
+package scan
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// defaultICAPPort is used when the service URL has no port.
+const defaultICAPPort = "1344"
+
+// ICAPScanner submits bodies with an ICAP REQMOD request. A 204 reply means
+// the body is clean; a 200 reply means the service rewrote the request,
+// which antivirus gateways do to block it.
+type ICAPScanner struct {
+	addr    string
+	service string // Full icap:// URI sent in the request line
+}
+
+// NewICAPScanner creates a scanner for an icap://host[:port]/service URL.
+func NewICAPScanner(u *url.URL) *ICAPScanner {
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), defaultICAPPort)
+	}
+	service := *u
+	service.Host = addr
+	return &ICAPScanner{addr: addr, service: service.String()}
+}
+
+// Scan implements Scanner.
+func (s *ICAPScanner) Scan(ctx context.Context, req *http.Request, body []byte) (Verdict, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	if _, err := conn.Write(s.encode(req, body)); err != nil {
+		return Verdict{}, fmt.Errorf("icap write: %w", err)
+	}
+
+	tp := textproto.NewReader(bufio.NewReader(conn))
+	line, err := tp.ReadLine()
+	if err != nil {
+		return Verdict{}, fmt.Errorf("icap read: %w", err)
+	}
+	proto, status, _ := strings.Cut(line, " ")
+	if !strings.HasPrefix(proto, "ICAP/") {
+		return Verdict{}, fmt.Errorf("icap: malformed status line %q", line)
+	}
+	code, _, _ := strings.Cut(status, " ")
+	header, err := tp.ReadMIMEHeader()
+	if err != nil {
+		return Verdict{}, fmt.Errorf("icap read: %w", err)
+	}
+
+	switch code {
+	case "204":
+		return Verdict{}, nil
+	case "200":
+		reason := header.Get("X-Infection-Found")
+		if reason == "" {
+			reason = header.Get("X-Violations-Found")
+		}
+		if reason == "" {
+			reason = "rejected by ICAP service"
+		}
+		return Verdict{Blocked: true, Reason: reason}, nil
+	default:
+		return Verdict{}, fmt.Errorf("icap service returned %s", status)
+	}
+}
+
+// encode builds a REQMOD request carrying the HTTP request headers and the
+// body in a single chunk.
+func (s *ICAPScanner) encode(req *http.Request, body []byte) []byte {
+	var httpHdr bytes.Buffer
+	fmt.Fprintf(&httpHdr, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	fmt.Fprintf(&httpHdr, "Host: %s\r\n", req.Host)
+	req.Header.Write(&httpHdr)
+	httpHdr.WriteString("\r\n")
+
+	host, _, _ := net.SplitHostPort(s.addr)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "REQMOD %s ICAP/1.0\r\n", s.service)
+	fmt.Fprintf(&buf, "Host: %s\r\n", host)
+	buf.WriteString("Allow: 204\r\n")
+	fmt.Fprintf(&buf, "Encapsulated: req-hdr=0, req-body=%d\r\n\r\n", httpHdr.Len())
+	buf.Write(httpHdr.Bytes())
+	buf.WriteString(strconv.FormatInt(int64(len(body)), 16))
+	buf.WriteString("\r\n")
+	buf.Write(body)
+	buf.WriteString("\r\n0\r\n\r\n")
+	return buf.Bytes()
+}
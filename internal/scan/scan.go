@@ -0,0 +1,102 @@
+// Package scan submits large request bodies to an external scanning
+// service before they enter a tunnel.
+//
+// Two protocols are supported, selected by the service URL scheme:
+//
+//	icap://av.internal:1344/reqmod  ICAP REQMOD (RFC 3507)
+//	https://scanner.internal/check  HTTP callback with a JSON verdict
+package scan
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// ErrTooLarge is returned when a body exceeds the scan size limit and
+// therefore cannot be checked.
+var ErrTooLarge = errors.New("body too large to scan")
+
+// Verdict is the outcome of a scan.
+type Verdict struct {
+	Blocked bool
+	Reason  string // Why the body was blocked, as reported by the service
+}
+
+// Scanner checks a request body.
+type Scanner interface {
+	Scan(ctx context.Context, req *http.Request, body []byte) (Verdict, error)
+}
+
+// New creates a scanner for an icap://, http:// or https:// service URL.
+func New(rawURL string) (Scanner, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid scan URL: %w", err)
+	}
+	switch u.Scheme {
+	case "icap":
+		return NewICAPScanner(u), nil
+	case "http", "https":
+		return &HTTPScanner{URL: rawURL}, nil
+	default:
+		return nil, fmt.Errorf("invalid scan URL scheme %q", u.Scheme)
+	}
+}
+
+// Hook decides which requests are scanned and buffers their bodies.
+// A nil Hook scans nothing.
+type Hook struct {
+	Scanner   Scanner
+	Threshold int64         // Bodies up to this size are not scanned
+	MaxBody   int64         // Larger bodies fail with ErrTooLarge (0 = unlimited)
+	Timeout   time.Duration // Per-scan deadline (0 = none)
+	FailOpen  bool          // Let requests through when the scan fails
+}
+
+// Check scans the request body if it exceeds the threshold. The body is
+// restored so that the request can still be forwarded.
+func (h *Hook) Check(req *http.Request) (Verdict, error) {
+	if h == nil || req.Body == nil || req.Body == http.NoBody {
+		return Verdict{}, nil
+	}
+	if req.ContentLength >= 0 && req.ContentLength <= h.Threshold {
+		return Verdict{}, nil
+	}
+
+	// Chunked bodies have no declared length, so read them to find out
+	r := io.Reader(req.Body)
+	if h.MaxBody > 0 {
+		r = io.LimitReader(req.Body, h.MaxBody+1)
+	}
+	body, err := io.ReadAll(r)
+	req.Body = readCloser{io.MultiReader(bytes.NewReader(body), req.Body), req.Body}
+	if err != nil {
+		return Verdict{}, fmt.Errorf("read body: %w", err)
+	}
+	if h.MaxBody > 0 && int64(len(body)) > h.MaxBody {
+		return Verdict{}, ErrTooLarge
+	}
+	if int64(len(body)) <= h.Threshold {
+		return Verdict{}, nil
+	}
+
+	ctx := req.Context()
+	if h.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, h.Timeout)
+		defer cancel()
+	}
+	return h.Scanner.Scan(ctx, req, body)
+}
+
+// readCloser replays the buffered prefix and closes the original body.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
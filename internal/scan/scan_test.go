@@ -0,0 +1,176 @@
+package scan
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// stubScanner records scanned bodies and returns a fixed verdict.
+type stubScanner struct {
+	verdict Verdict
+	scanned []string
+}
+
+func (s *stubScanner) Scan(ctx context.Context, req *http.Request, body []byte) (Verdict, error) {
+	s.scanned = append(s.scanned, string(body))
+	return s.verdict, nil
+}
+
+func TestHook_Check(t *testing.T) {
+	stub := &stubScanner{verdict: Verdict{Blocked: true, Reason: "eicar"}}
+	hook := &Hook{Scanner: stub, Threshold: 4, MaxBody: 16}
+
+	// Small body with a declared length is not read
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("tiny"))
+	if v, err := hook.Check(req); err != nil || v.Blocked {
+		t.Fatalf("small body: %+v, %v", v, err)
+	}
+
+	// Chunked body over the threshold is scanned and restored
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader("malicious"))
+	req.ContentLength = -1
+	v, err := hook.Check(req)
+	if err != nil || !v.Blocked || v.Reason != "eicar" {
+		t.Fatalf("large body: %+v, %v", v, err)
+	}
+	if len(stub.scanned) != 1 || stub.scanned[0] != "malicious" {
+		t.Errorf("scanned = %q", stub.scanned)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != "malicious" {
+		t.Errorf("restored body = %q", body)
+	}
+
+	// Oversized body fails but stays intact for fail-open forwarding
+	payload := strings.Repeat("x", 20)
+	req = httptest.NewRequest(http.MethodPost, "/", strings.NewReader(payload))
+	if _, err := hook.Check(req); !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("oversized body: err = %v, want ErrTooLarge", err)
+	}
+	if body, _ := io.ReadAll(req.Body); string(body) != payload {
+		t.Errorf("restored body = %q", body)
+	}
+
+	var disabled *Hook
+	if v, err := disabled.Check(req); err != nil || v.Blocked {
+		t.Errorf("nil hook: %+v, %v", v, err)
+	}
+}
+
+func TestHTTPScanner(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get("X-Gopublic-Host") != "myapp.example.com" {
+			t.Errorf("X-Gopublic-Host = %q", r.Header.Get("X-Gopublic-Host"))
+		}
+		if strings.Contains(string(body), "virus") {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"verdict":"block","reason":"test signature"}`)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	s := &HTTPScanner{URL: srv.URL}
+	req := httptest.NewRequest(http.MethodPost, "http://myapp.example.com/upload", nil)
+
+	if v, err := s.Scan(context.Background(), req, []byte("hello")); err != nil || v.Blocked {
+		t.Errorf("clean body: %+v, %v", v, err)
+	}
+	v, err := s.Scan(context.Background(), req, []byte("a virus"))
+	if err != nil || !v.Blocked || v.Reason != "test signature" {
+		t.Errorf("infected body: %+v, %v", v, err)
+	}
+}
+
+// fakeICAP answers every REQMOD request with the given reply after
+// checking that the body was encapsulated.
+func fakeICAP(t *testing.T, reply string) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			r := bufio.NewReader(conn)
+			line, _ := r.ReadString('\n')
+			if !strings.HasPrefix(line, "REQMOD icap://") {
+				t.Errorf("request line = %q", line)
+			}
+			// Read until the terminating zero-length chunk
+			var seen strings.Builder
+			for !strings.HasSuffix(seen.String(), "\r\n0\r\n\r\n") {
+				b, err := r.ReadByte()
+				if err != nil {
+					break
+				}
+				seen.WriteByte(b)
+			}
+			if !strings.Contains(seen.String(), "payload") {
+				t.Errorf("body not encapsulated: %q", seen.String())
+			}
+			io.WriteString(conn, reply)
+			conn.Close()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestICAPScanner(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "http://myapp.example.com/upload", nil)
+
+	tests := []struct {
+		name    string
+		reply   string
+		blocked bool
+		reason  string
+	}{
+		{"clean", "ICAP/1.0 204 No Content\r\n\r\n", false, ""},
+		{"infected", "ICAP/1.0 200 OK\r\nX-Infection-Found: Type=0; Resolution=2; Threat=EICAR;\r\nEncapsulated: null-body=0\r\n\r\n", true, "Type=0; Resolution=2; Threat=EICAR;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u, _ := url.Parse("icap://" + fakeICAP(t, tt.reply) + "/reqmod")
+			v, err := NewICAPScanner(u).Scan(context.Background(), req, []byte("payload"))
+			if err != nil {
+				t.Fatalf("Scan: %v", err)
+			}
+			if v.Blocked != tt.blocked || v.Reason != tt.reason {
+				t.Errorf("verdict = %+v, want blocked=%v reason=%q", v, tt.blocked, tt.reason)
+			}
+		})
+	}
+
+	u, _ := url.Parse("icap://" + fakeICAP(t, "ICAP/1.0 500 Server Error\r\n\r\n") + "/reqmod")
+	if _, err := NewICAPScanner(u).Scan(context.Background(), req, []byte("payload")); err == nil {
+		t.Error("expected error for ICAP 500")
+	}
+}
+
+func TestNew(t *testing.T) {
+	if s, err := New("icap://av.internal/reqmod"); err != nil {
+		t.Errorf("icap: %v", err)
+	} else if icap := s.(*ICAPScanner); icap.addr != "av.internal:1344" {
+		t.Errorf("icap addr = %q", icap.addr)
+	}
+	if _, err := New("https://scanner.internal/check"); err != nil {
+		t.Errorf("https: %v", err)
+	}
+	if _, err := New("ftp://scanner.internal"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}
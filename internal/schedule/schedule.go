@@ -0,0 +1,157 @@
+// Package schedule restricts tunnels to weekly time windows.
+//
+// A schedule is a list of windows separated by semicolons, each made of
+// days and a time range:
+//
+//	mon-fri 09:00-18:00; sat 10:00-14:00
+//
+// Days are three-letter names, ranges (mon-fri), comma-separated lists
+// (mon,wed,fri) or "daily". A range whose end is before its start runs
+// past midnight and belongs to the day it starts on (fri 22:00-02:00).
+// Times are evaluated in the schedule's time zone.
+package schedule
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	_ "time/tzdata" // The runtime image has no zoneinfo
+)
+
+// MaxWindows limits the number of windows in a schedule.
+const MaxWindows = 14
+
+// ErrInvalid is returned for schedules that cannot be parsed.
+var ErrInvalid = errors.New("invalid schedule")
+
+var dayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a daily time range on a set of weekdays.
+type Window struct {
+	Days  [7]bool // Indexed by time.Weekday
+	Start int     // Minutes since midnight
+	End   int     // Minutes since midnight, 24*60 at most; End < Start runs past midnight
+}
+
+// Schedule is a set of windows in a time zone.
+type Schedule struct {
+	Windows  []Window
+	Location *time.Location
+}
+
+// Parse parses a schedule in the IANA time zone tz (empty = UTC).
+func Parse(spec, tz string) (*Schedule, error) {
+	loc, err := time.LoadLocation(strings.TrimSpace(tz))
+	if err != nil {
+		return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalid, tz)
+	}
+
+	s := &Schedule{Location: loc}
+	for _, part := range strings.Split(spec, ";") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		w, err := parseWindow(part)
+		if err != nil {
+			return nil, err
+		}
+		s.Windows = append(s.Windows, w)
+	}
+	if len(s.Windows) == 0 {
+		return nil, fmt.Errorf("%w: no windows", ErrInvalid)
+	}
+	if len(s.Windows) > MaxWindows {
+		return nil, fmt.Errorf("%w: at most %d windows", ErrInvalid, MaxWindows)
+	}
+	return s, nil
+}
+
+func parseWindow(s string) (Window, error) {
+	var w Window
+	fields := strings.Fields(strings.ToLower(s))
+	if len(fields) != 2 {
+		return w, fmt.Errorf("%w: %q is not \"days HH:MM-HH:MM\"", ErrInvalid, s)
+	}
+
+	if fields[0] == "daily" {
+		w.Days = [7]bool{true, true, true, true, true, true, true}
+	} else {
+		for _, item := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(item, "-")
+			first, ok := dayNames[from]
+			last, lastOK := first, true
+			if isRange {
+				last, lastOK = dayNames[to]
+			}
+			if !ok || !lastOK {
+				return w, fmt.Errorf("%w: unknown days %q", ErrInvalid, item)
+			}
+			for d := first; ; d = (d + 1) % 7 {
+				w.Days[d] = true
+				if d == last {
+					break
+				}
+			}
+		}
+	}
+
+	from, to, ok := strings.Cut(fields[1], "-")
+	if !ok {
+		return w, fmt.Errorf("%w: %q is not a time range", ErrInvalid, fields[1])
+	}
+	var err error
+	if w.Start, err = parseClock(from); err != nil {
+		return w, err
+	}
+	if w.End, err = parseClock(to); err != nil {
+		return w, err
+	}
+	if w.Start == w.End || w.Start == 24*60 {
+		return w, fmt.Errorf("%w: empty time range %q", ErrInvalid, fields[1])
+	}
+	return w, nil
+}
+
+// parseClock parses HH:MM into minutes since midnight. 24:00 is allowed.
+func parseClock(s string) (int, error) {
+	var h, m int
+	if len(s) != 5 || s[2] != ':' {
+		return 0, fmt.Errorf("%w: bad time %q", ErrInvalid, s)
+	}
+	if _, err := fmt.Sscanf(s, "%2d:%2d", &h, &m); err != nil || h > 24 || m > 59 || (h == 24 && m != 0) {
+		return 0, fmt.Errorf("%w: bad time %q", ErrInvalid, s)
+	}
+	return h*60 + m, nil
+}
+
+// Allows reports whether t falls into one of the windows.
+func (s *Schedule) Allows(t time.Time) bool {
+	t = t.In(s.Location)
+	day := t.Weekday()
+	prev := (day + 6) % 7
+	minute := t.Hour()*60 + t.Minute()
+
+	for _, w := range s.Windows {
+		if w.Start < w.End {
+			if w.Days[day] && minute >= w.Start && minute < w.End {
+				return true
+			}
+			continue
+		}
+		// Overnight window
+		if (w.Days[day] && minute >= w.Start) || (w.Days[prev] && minute < w.End) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,70 @@
+package schedule
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseInvalid(t *testing.T) {
+	for _, spec := range []string{
+		"",
+		"mon-fri",
+		"someday 09:00-18:00",
+		"mon-xyz 09:00-18:00",
+		"mon 9:00-18:00",
+		"mon 09:00-25:00",
+		"mon 09:00-09:00",
+		"mon 24:00-01:00",
+		"mon 09:00 18:00",
+	} {
+		if _, err := Parse(spec, ""); !errors.Is(err, ErrInvalid) {
+			t.Errorf("Parse(%q) = %v, want ErrInvalid", spec, err)
+		}
+	}
+	if _, err := Parse("daily 09:00-18:00", "Mars/Olympus"); !errors.Is(err, ErrInvalid) {
+		t.Errorf("Parse with unknown zone = %v, want ErrInvalid", err)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	s, err := Parse("mon-fri 09:00-18:00; sat,sun 22:00-02:00", "Europe/Moscow")
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	msk := s.Location
+
+	tests := []struct {
+		at   time.Time
+		want bool
+	}{
+		{time.Date(2026, 10, 12, 9, 0, 0, 0, msk), true},   // Monday opening
+		{time.Date(2026, 10, 12, 17, 59, 0, 0, msk), true}, // Monday before closing
+		{time.Date(2026, 10, 12, 18, 0, 0, 0, msk), false}, // Monday closing
+		{time.Date(2026, 10, 16, 8, 59, 0, 0, msk), false}, // Friday early
+		{time.Date(2026, 10, 17, 12, 0, 0, 0, msk), false}, // Saturday noon
+		{time.Date(2026, 10, 17, 23, 0, 0, 0, msk), true},  // Saturday night
+		{time.Date(2026, 10, 18, 1, 30, 0, 0, msk), true},  // Saturday window after midnight
+		{time.Date(2026, 10, 19, 1, 30, 0, 0, msk), true},  // Sunday window after midnight
+		{time.Date(2026, 10, 20, 1, 30, 0, 0, msk), false}, // Monday window does not exist
+		{time.Date(2026, 10, 12, 6, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		if got := s.Allows(tt.at); got != tt.want {
+			t.Errorf("Allows(%s) = %v, want %v", tt.at.In(msk).Format("Mon 15:04"), got, tt.want)
+		}
+	}
+}
+
+func TestAllowsWrappingDays(t *testing.T) {
+	s, err := Parse("fri-mon 00:00-24:00", "")
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	for day, want := range map[int]bool{12: true, 13: false, 14: false, 15: false, 16: true, 17: true, 18: true} {
+		at := time.Date(2026, 10, day, 12, 0, 0, 0, time.UTC)
+		if got := s.Allows(at); got != want {
+			t.Errorf("Allows(%s) = %v, want %v", at.Weekday(), got, want)
+		}
+	}
+}
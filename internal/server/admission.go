@@ -0,0 +1,70 @@
+package server
+
+import (
+	"fmt"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// memStatsMaxAge bounds how stale the heap size used by Admission may be.
+// runtime.ReadMemStats stops the world, so it is not read per handshake.
+const memStatsMaxAge = time.Second
+
+// Admission decides whether new sessions are accepted. Above any of its
+// limits the server refuses handshakes with "server_busy" instead of
+// slowing down every session during a traffic spike. Zero limits are
+// unlimited.
+type Admission struct {
+	MaxSessions   int
+	MaxMemoryMB   int
+	MaxGoroutines int
+	RetryAfter    time.Duration // Sent to refused clients
+
+	mu       sync.Mutex
+	heapMB   int
+	heapRead time.Time
+}
+
+// Check returns why a new session would overload the server, or "" if it
+// can be accepted. sessions is the number of active sessions.
+func (a *Admission) Check(sessions int) string {
+	if a == nil {
+		return ""
+	}
+	if a.MaxSessions > 0 && sessions >= a.MaxSessions {
+		return fmt.Sprintf("%d sessions", sessions)
+	}
+	if a.MaxGoroutines > 0 {
+		if n := runtime.NumGoroutine(); n >= a.MaxGoroutines {
+			return fmt.Sprintf("%d goroutines", n)
+		}
+	}
+	if a.MaxMemoryMB > 0 {
+		if mb := a.heapInUseMB(); mb >= a.MaxMemoryMB {
+			return fmt.Sprintf("%d MB heap", mb)
+		}
+	}
+	return ""
+}
+
+// heapInUseMB returns the heap in use, read at most once per memStatsMaxAge.
+func (a *Admission) heapInUseMB() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if time.Since(a.heapRead) >= memStatsMaxAge {
+		var m runtime.MemStats
+		runtime.ReadMemStats(&m)
+		a.heapMB = int(m.HeapInuse / (1024 * 1024))
+		a.heapRead = time.Now()
+	}
+	return a.heapMB
+}
+
+// retryAfterSeconds returns RetryAfter in whole seconds, at least 1.
+func (a *Admission) retryAfterSeconds() int {
+	if secs := int(a.RetryAfter / time.Second); secs > 0 {
+		return secs
+	}
+	return 1
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAdmission_Check(t *testing.T) {
+	var none *Admission
+	if reason := none.Check(1000); reason != "" {
+		t.Errorf("nil Admission refused: %s", reason)
+	}
+
+	a := &Admission{MaxSessions: 2}
+	if reason := a.Check(1); reason != "" {
+		t.Errorf("Check(1) refused below the session limit: %s", reason)
+	}
+	if reason := a.Check(2); reason == "" {
+		t.Error("Check(2) accepted at the session limit")
+	}
+
+	// The test binary runs more than one goroutine
+	a = &Admission{MaxGoroutines: 1}
+	if reason := a.Check(0); reason == "" {
+		t.Error("Check accepted over the goroutine limit")
+	}
+
+	a = &Admission{MaxMemoryMB: 1 << 20}
+	if reason := a.Check(0); reason != "" {
+		t.Errorf("Check refused below the memory limit: %s", reason)
+	}
+}
+
+func TestAdmission_RetryAfterSeconds(t *testing.T) {
+	tests := []struct {
+		in   time.Duration
+		want int
+	}{
+		{0, 1},
+		{500 * time.Millisecond, 1},
+		{30 * time.Second, 30},
+	}
+	for _, tt := range tests {
+		a := &Admission{RetryAfter: tt.in}
+		if got := a.retryAfterSeconds(); got != tt.want {
+			t.Errorf("retryAfterSeconds(%v) = %d, want %d", tt.in, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log"
+	"net"
+	"strings"
+
+	"gopublic/internal/storage"
+)
+
+// maxDeviceName limits the client-reported device description.
+const maxDeviceName = 100
+
+// deviceFingerprint identifies a client by its IP address and reported
+// name. The port is ignored so that reconnects map to the same device.
+// Both parts are easy to change, so the fingerprint only labels devices in
+// the list: access is controlled by the token, which revocation rotates.
+func deviceFingerprint(remoteAddr, name string) string {
+	sum := sha256.Sum256([]byte(remoteIP(remoteAddr) + "\x00" + name))
+	return hex.EncodeToString(sum[:8])
+}
+
+// remoteIP strips the port from a connection address.
+func remoteIP(remoteAddr string) string {
+	if host, _, err := net.SplitHostPort(remoteAddr); err == nil {
+		return host
+	}
+	return remoteAddr
+}
+
+// deviceName sanitizes the client-reported device description.
+func deviceName(name string) string {
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f {
+			return -1
+		}
+		return r
+	}, strings.TrimSpace(name))
+	if len(name) > maxDeviceName {
+		name = name[:maxDeviceName]
+	}
+	return name
+}
+
+// recordDevice records the connection in the user's device list and
+// returns the device fingerprint. Storage errors are logged and do not
+// block the connection.
+func (s *Server) recordDevice(userID uint, remoteAddr, name string) string {
+	name = deviceName(name)
+	fingerprint := deviceFingerprint(remoteAddr, name)
+
+	if _, err := storage.RecordDevice(userID, fingerprint, name, remoteIP(remoteAddr)); err != nil {
+		log.Printf("Failed to record device for user %d: %v", userID, err)
+	}
+	return fingerprint
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDeviceFingerprint(t *testing.T) {
+	a := deviceFingerprint("203.0.113.7:51234", "laptop (linux/amd64)")
+	if b := deviceFingerprint("203.0.113.7:40000", "laptop (linux/amd64)"); a != b {
+		t.Errorf("fingerprint changed with the source port: %s != %s", a, b)
+	}
+	if b := deviceFingerprint("203.0.113.8:51234", "laptop (linux/amd64)"); a == b {
+		t.Error("fingerprint did not change with the IP address")
+	}
+	if b := deviceFingerprint("203.0.113.7:51234", "desktop (linux/amd64)"); a == b {
+		t.Error("fingerprint did not change with the device name")
+	}
+	if len(a) != 16 {
+		t.Errorf("fingerprint length = %d, want 16", len(a))
+	}
+}
+
+func TestRemoteIP(t *testing.T) {
+	tests := map[string]string{
+		"203.0.113.7:51234": "203.0.113.7",
+		"[2001:db8::1]:443": "2001:db8::1",
+		"203.0.113.7":       "203.0.113.7",
+	}
+	for addr, want := range tests {
+		if got := remoteIP(addr); got != want {
+			t.Errorf("remoteIP(%q) = %q, want %q", addr, got, want)
+		}
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	if got := deviceName("  laptop\n(linux/amd64)\x00 "); got != "laptop(linux/amd64)" {
+		t.Errorf("deviceName() = %q, want control characters stripped", got)
+	}
+	if got := deviceName(strings.Repeat("x", 300)); len(got) != maxDeviceName {
+		t.Errorf("deviceName() length = %d, want %d", len(got), maxDeviceName)
+	}
+}
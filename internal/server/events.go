@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
+	"gopublic/pkg/protocol"
+)
+
+// SetEvents connects the server to a cross-instance event bus.
+// Events are acted upon only by the instance that owns the affected session.
+func (s *Server) SetEvents(bus pubsub.Bus) {
+	s.Events = bus
+	bus.Subscribe(s.handleEvent)
+}
+
+// ForceDisconnect closes the user's tunnel session on whichever instance holds it.
+func (s *Server) ForceDisconnect(ctx context.Context, userID uint, reason string) error {
+	return s.publish(ctx, pubsub.Event{Type: pubsub.EventForceDisconnect, UserID: userID, Reason: reason})
+}
+
+// RevokeDomain stops routing the domain (FQDN) on whichever instance serves it.
+func (s *Server) RevokeDomain(ctx context.Context, domain, reason string) error {
+	return s.publish(ctx, pubsub.Event{Type: pubsub.EventDomainRevoked, Domain: domain, Reason: reason})
+}
+
+// drainAndClose stops admitting new requests on the session and lets
+// in-flight ones finish for up to DrainTimeout before closing it.
+func (s *Server) drainAndClose(sess *UserSession) {
+	if sess.Streams != nil && s.DrainTimeout > 0 {
+		ctx, cancel := context.WithTimeout(s.ctx, s.DrainTimeout)
+		defer cancel()
+		if err := sess.Streams.Drain(ctx); err != nil {
+			log.Printf("Drain for user %d cut short with %d stream(s) in flight: %v", sess.UserID, sess.Streams.Active(), err)
+		}
+	}
+	sess.Session.Close()
+}
+
+// publish sends the event to all instances, or handles it locally if no bus is configured.
+func (s *Server) publish(ctx context.Context, event pubsub.Event) error {
+	if s.Events == nil {
+		s.handleEvent(event)
+		return nil
+	}
+	return s.Events.Publish(ctx, event)
+}
+
+// handleEvent applies an event to sessions owned by this instance.
+func (s *Server) handleEvent(event pubsub.Event) {
+	switch event.Type {
+	case pubsub.EventForceDisconnect:
+		for _, sess := range s.UserSessions.Sessions(event.UserID) {
+			if event.Device != "" && sess.Device != event.Device {
+				continue
+			}
+			log.Printf("Force disconnect for user %d (agent=%q, origin=%s): %s", event.UserID, sess.Agent, event.Origin, event.Reason)
+			sess.setCloseReason(event.Reason)
+			// monitorSession unregisters the domains once the session is closed
+			go s.drainAndClose(sess)
+		}
+
+	case pubsub.EventAgentCommand:
+		if s.UserSessions.Command(event.UserID, event.Agent, event.Command) {
+			log.Printf("Queued %q for user %d (agent=%q, origin=%s)", event.Command, event.UserID, event.Agent, event.Origin)
+		}
+
+	case pubsub.EventDefinitionsChanged:
+		for _, sess := range s.UserSessions.Sessions(event.UserID) {
+			if !sess.Managed || !protocol.MatchSelector(event.Selector, sess.Labels) {
+				continue
+			}
+			if s.UserSessions.Command(event.UserID, sess.Agent, protocol.CommandReload) {
+				log.Printf("Reloading tunnel definitions for user %d (agent=%q, origin=%s)", event.UserID, sess.Agent, event.Origin)
+			}
+		}
+
+	case pubsub.EventDomainRevoked, pubsub.EventDomainSuspended:
+		entry, ok := s.Registry.GetEntry(event.Domain)
+		if !ok {
+			return
+		}
+		log.Printf("Domain %s revoked for user %d (origin=%s): %s", event.Domain, entry.UserID, event.Origin, event.Reason)
+		s.Registry.Unregister(event.Domain)
+		s.UserSessions.RemoveDomain(entry.UserID, event.Domain)
+		sess := &UserSession{UserID: entry.UserID}
+		for _, us := range s.UserSessions.Sessions(entry.UserID) {
+			if us.Session == entry.Session {
+				sess = us
+			}
+		}
+		s.recordConnectionEvents(sess, models.ConnectionEventDisconnect, models.DisconnectForced, event.Reason, []string{event.Domain})
+	}
+}
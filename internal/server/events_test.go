@@ -0,0 +1,30 @@
+package server
+
+import (
+	"testing"
+
+	"gopublic/internal/pubsub"
+	"gopublic/pkg/protocol"
+)
+
+func TestHandleEvent_DefinitionsChanged(t *testing.T) {
+	s := &Server{UserSessions: NewUserSessionRegistry()}
+	preview := &UserSession{UserID: 1, Agent: "preview", Labels: map[string]string{"env": "preview"}, Commands: true, Managed: true}
+	staging := &UserSession{UserID: 1, Agent: "staging", Labels: map[string]string{"env": "staging"}, Commands: true, Managed: true}
+	manual := &UserSession{UserID: 1, Agent: "laptop", Labels: map[string]string{"env": "preview"}, Commands: true}
+	for _, sess := range []*UserSession{preview, staging, manual} {
+		s.UserSessions.Register(sess)
+	}
+
+	s.handleEvent(pubsub.Event{Type: pubsub.EventDefinitionsChanged, UserID: 1, Selector: "env=preview"})
+
+	if got := preview.nextCommand(); got != protocol.CommandReload {
+		t.Errorf("matching managed session got %q, want %q", got, protocol.CommandReload)
+	}
+	if got := staging.nextCommand(); got != "" {
+		t.Errorf("managed session of other labels got %q, want nothing", got)
+	}
+	if got := manual.nextCommand(); got != "" {
+		t.Errorf("session from gopublic.yaml got %q, want nothing", got)
+	}
+}
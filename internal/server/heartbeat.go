@@ -0,0 +1,98 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"sort"
+	"time"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/models"
+	"gopublic/internal/storage"
+	"gopublic/pkg/protocol"
+)
+
+// heartbeatIdleTimeout closes a heartbeat stream the client stopped using.
+// Clients send a heartbeat every few seconds, see protocol.Heartbeat.
+const heartbeatIdleTimeout = 2 * time.Minute
+
+// serveHeartbeats echoes heartbeats on the streams the client opens on a
+// tunnel session until it closes. Streams are served one at a time: a
+// client needs only one, and more cannot pile up goroutines.
+func serveHeartbeats(session *yamux.Session, statsEvery time.Duration, stats func() *protocol.StatsPush, command func() string) {
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			return
+		}
+		serveHeartbeat(stream, statsEvery, stats, command)
+	}
+}
+
+// serveHeartbeat writes back each heartbeat read from stream, attaching
+// the result of stats to the first echo after every statsEvery and the
+// next queued remote command, if command is set.
+func serveHeartbeat(stream net.Conn, statsEvery time.Duration, stats func() *protocol.StatsPush, command func() string) {
+	defer stream.Close()
+
+	decoder := json.NewDecoder(stream)
+	encoder := json.NewEncoder(stream)
+	lastStats := time.Now()
+	for {
+		stream.SetDeadline(time.Now().Add(heartbeatIdleTimeout))
+		var hb protocol.Heartbeat
+		if err := decoder.Decode(&hb); err != nil {
+			return
+		}
+		echo := protocol.Heartbeat{Seq: hb.Seq}
+		if stats != nil && time.Since(lastStats) >= statsEvery {
+			echo.Stats = stats()
+			lastStats = time.Now()
+		}
+		if command != nil {
+			echo.Command = command()
+		}
+		if err := encoder.Encode(echo); err != nil {
+			return
+		}
+	}
+}
+
+// statsPush collects the usage of a user's tunnel session.
+func (s *Server) statsPush(session *yamux.Session, user *models.User) *protocol.StatsPush {
+	bandwidthToday, _ := storage.GetUserBandwidthToday(user.ID)
+	bandwidthTotal, _ := storage.GetUserTotalBandwidth(user.ID)
+	push := &protocol.StatsPush{
+		ServerStats: protocol.ServerStats{
+			BandwidthToday: bandwidthToday,
+			BandwidthTotal: bandwidthTotal,
+			BandwidthLimit: s.PlanBandwidth.Bandwidth(user.Plan, s.DailyBandwidthLimit),
+		},
+	}
+	push.QuotaExceeded = push.BandwidthLimit > 0 && bandwidthToday >= push.BandwidthLimit
+
+	var streams *StreamLimiter
+	for hostname, entry := range s.Registry.SessionEntries(session) {
+		streams = entry.Streams
+		if entry.Traffic == nil {
+			continue
+		}
+		stats := protocol.DomainStats{
+			Domain:    hostname,
+			Requests:  entry.Traffic.Requests.Load(),
+			Bytes:     entry.Traffic.Bytes.Load(),
+			Throttled: entry.Traffic.Throttled.Load(),
+		}
+		if entry.Limiter != nil {
+			stats.RateLimit = float64(entry.Limiter.Limit())
+		}
+		push.Domains = append(push.Domains, stats)
+	}
+	sort.Slice(push.Domains, func(i, j int) bool { return push.Domains[i].Domain < push.Domains[j].Domain })
+	if streams != nil {
+		push.StreamsActive = streams.Active()
+		push.StreamsLimit = streams.Max()
+	}
+	return push
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"testing"
+
+	"gopublic/pkg/protocol"
+)
+
+func TestServeHeartbeat_Echoes(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go serveHeartbeat(server, 0, nil, nil)
+
+	encoder, decoder := json.NewEncoder(client), json.NewDecoder(client)
+	for seq := uint64(1); seq <= 3; seq++ {
+		go encoder.Encode(protocol.Heartbeat{Seq: seq})
+		var echo protocol.Heartbeat
+		if err := decoder.Decode(&echo); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if echo.Seq != seq {
+			t.Errorf("echo = %d, want %d", echo.Seq, seq)
+		}
+		if echo.Stats != nil {
+			t.Errorf("echo %d carries stats without a stats source", seq)
+		}
+	}
+}
+
+func TestServeHeartbeat_AttachesStats(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	stats := func() *protocol.StatsPush { return &protocol.StatsPush{StreamsActive: 2} }
+	go serveHeartbeat(server, 0, stats, nil)
+
+	go json.NewEncoder(client).Encode(protocol.Heartbeat{Seq: 1})
+	var echo protocol.Heartbeat
+	if err := json.NewDecoder(client).Decode(&echo); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if echo.Stats == nil || echo.Stats.StreamsActive != 2 {
+		t.Errorf("echo.Stats = %+v, want StreamsActive 2", echo.Stats)
+	}
+}
+
+func TestServeHeartbeat_DeliversCommand(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	registry := NewUserSessionRegistry()
+	sess := &UserSession{UserID: 1, Agent: "ci", Commands: true}
+	registry.Register(sess)
+	if !registry.Command(1, "ci", protocol.CommandReload) {
+		t.Fatal("Command not queued")
+	}
+	go serveHeartbeat(server, 0, nil, sess.nextCommand)
+
+	encoder, decoder := json.NewEncoder(client), json.NewDecoder(client)
+	for seq, want := range []string{protocol.CommandReload, ""} {
+		go encoder.Encode(protocol.Heartbeat{Seq: uint64(seq + 1)})
+		var echo protocol.Heartbeat
+		if err := decoder.Decode(&echo); err != nil {
+			t.Fatalf("Decode: %v", err)
+		}
+		if echo.Command != want {
+			t.Errorf("echo %d command = %q, want %q", seq+1, echo.Command, want)
+		}
+	}
+}
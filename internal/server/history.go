@@ -0,0 +1,61 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"log"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/models"
+	"gopublic/internal/storage"
+)
+
+// recordConnectionEvents stores an event of the given type for each of the
+// session's domains, for the connection history in the dashboard.
+func (s *Server) recordConnectionEvents(sess *UserSession, eventType, reason, detail string, domains []string) {
+	if len(domains) == 0 {
+		return
+	}
+	events := make([]models.ConnectionEvent, 0, len(domains))
+	for _, d := range domains {
+		events = append(events, models.ConnectionEvent{
+			UserID: sess.UserID,
+			Domain: d,
+			Type:   eventType,
+			Reason: reason,
+			Detail: detail,
+			Agent:  sess.Agent,
+		})
+	}
+	if err := storage.AddConnectionEvents(events); err != nil {
+		log.Printf("Failed to record %s of %v for user %d: %v", eventType, domains, sess.UserID, err)
+	}
+}
+
+// sessionError returns the error a closed session ended with: io.EOF if the
+// client closed the connection, yamux.ErrSessionShutdown if this server
+// closed the session, anything else if the connection failed.
+func sessionError(session *yamux.Session) error {
+	for {
+		// Streams still queued are returned before the error
+		stream, err := session.AcceptStream()
+		if err != nil {
+			return err
+		}
+		stream.Close()
+	}
+}
+
+// disconnectReason classifies the error a session ended with into one of
+// the models.Disconnect* reasons and the detail to record with it. forced
+// is why the server closed the session, if it did.
+func disconnectReason(err error, forced string) (reason, detail string) {
+	switch {
+	case errors.Is(err, io.EOF):
+		return models.DisconnectClientExit, ""
+	case errors.Is(err, yamux.ErrSessionShutdown):
+		return models.DisconnectForced, forced
+	}
+	return models.DisconnectNetwork, err.Error()
+}
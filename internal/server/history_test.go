@@ -0,0 +1,68 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"testing"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/models"
+)
+
+func TestSessionError(t *testing.T) {
+	conn, peer := net.Pipe()
+	session, err := yamux.Server(conn, nil)
+	if err != nil {
+		t.Fatalf("yamux.Server: %v", err)
+	}
+	// The client going away ends the session with EOF
+	peer.Close()
+	<-session.CloseChan()
+	if err := sessionError(session); !errors.Is(err, io.EOF) {
+		t.Errorf("sessionError() after the client closed = %v, want EOF", err)
+	}
+
+	conn, peer = net.Pipe()
+	defer peer.Close()
+	session, err = yamux.Server(conn, nil)
+	if err != nil {
+		t.Fatalf("yamux.Server: %v", err)
+	}
+	session.Close()
+	if err := sessionError(session); !errors.Is(err, yamux.ErrSessionShutdown) {
+		t.Errorf("sessionError() after closing = %v, want ErrSessionShutdown", err)
+	}
+}
+
+func TestDisconnectReason(t *testing.T) {
+	tests := []struct {
+		err    error
+		forced string
+		reason string
+		detail string
+	}{
+		{io.EOF, "", models.DisconnectClientExit, ""},
+		{yamux.ErrSessionShutdown, "banned", models.DisconnectForced, "banned"},
+		{yamux.ErrKeepAliveTimeout, "", models.DisconnectNetwork, yamux.ErrKeepAliveTimeout.Error()},
+		{errors.New("read: connection reset by peer"), "", models.DisconnectNetwork, "read: connection reset by peer"},
+	}
+	for _, tt := range tests {
+		reason, detail := disconnectReason(tt.err, tt.forced)
+		if reason != tt.reason || detail != tt.detail {
+			t.Errorf("disconnectReason(%v) = %q, %q, want %q, %q", tt.err, reason, detail, tt.reason, tt.detail)
+		}
+	}
+}
+
+func TestSetCloseReason(t *testing.T) {
+	sess := &UserSession{}
+	if got := sess.forcedReason(); got != "" {
+		t.Errorf("forcedReason() = %q, want empty", got)
+	}
+	sess.setCloseReason("tokens revoked")
+	if got := sess.forcedReason(); got != "tokens revoked" {
+		t.Errorf("forcedReason() = %q, want %q", got, "tokens revoked")
+	}
+}
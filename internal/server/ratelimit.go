@@ -0,0 +1,43 @@
+package server
+
+import (
+	"fmt"
+
+	"golang.org/x/time/rate"
+
+	"gopublic/pkg/protocol"
+)
+
+// domainRateLimit returns the rate limit of a bound domain: the one the
+// client asked for, capped at the server limit, or the server limit. A zero
+// RPS means unlimited.
+func domainRateLimit(limit protocol.RateLimit, requested map[string]protocol.RateLimit, name string) protocol.RateLimit {
+	req, ok := protocol.RateLimitFor(requested, name)
+	if !ok {
+		return limit
+	}
+	if limit.RPS > 0 {
+		req.RPS = min(req.RPS, limit.RPS)
+		req.Burst = min(req.BurstOrDefault(), limit.BurstOrDefault())
+	}
+	return req
+}
+
+// newRateLimiter returns the token bucket enforcing limit, or nil if the
+// domain is not limited.
+func newRateLimiter(limit protocol.RateLimit) *rate.Limiter {
+	if limit.RPS <= 0 {
+		return nil
+	}
+	return rate.NewLimiter(rate.Limit(limit.RPS), limit.BurstOrDefault())
+}
+
+// validateRateLimits checks every rate limit of a tunnel request.
+func validateRateLimits(limits map[string]protocol.RateLimit) error {
+	for domain, limit := range limits {
+		if err := protocol.ValidateRateLimit(limit); err != nil {
+			return fmt.Errorf("%s: %w", domain, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"testing"
+
+	"gopublic/pkg/protocol"
+)
+
+func TestDomainRateLimit(t *testing.T) {
+	requested := map[string]protocol.RateLimit{
+		"api":               {RPS: 5, Burst: 50},
+		protocol.AllDomains: {RPS: 100},
+	}
+	tests := []struct {
+		name      string
+		limit     protocol.RateLimit
+		requested map[string]protocol.RateLimit
+		domain    string
+		want      protocol.RateLimit
+	}{
+		{"unlimited server, nothing requested", protocol.RateLimit{}, nil, "web", protocol.RateLimit{}},
+		{"server limit applies", protocol.RateLimit{RPS: 10}, nil, "web", protocol.RateLimit{RPS: 10}},
+		{"requested without server limit", protocol.RateLimit{}, requested, "api", protocol.RateLimit{RPS: 5, Burst: 50}},
+		{"burst capped", protocol.RateLimit{RPS: 10, Burst: 20}, requested, "api", protocol.RateLimit{RPS: 5, Burst: 20}},
+		{"rate capped", protocol.RateLimit{RPS: 10}, requested, "web", protocol.RateLimit{RPS: 10, Burst: 10}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := domainRateLimit(tt.limit, tt.requested, tt.domain); got != tt.want {
+				t.Errorf("domainRateLimit = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if newRateLimiter(protocol.RateLimit{}) != nil {
+		t.Error("newRateLimiter returned a limiter for an unlimited domain")
+	}
+}
@@ -2,14 +2,48 @@ package server
 
 import (
 	"sync"
+	"sync/atomic"
 
 	"github.com/hashicorp/yamux"
+	"golang.org/x/time/rate"
 )
 
 // TunnelEntry contains session and user info for a registered tunnel
 type TunnelEntry struct {
 	Session *yamux.Session
 	UserID  uint
+	Plan    string         // Owner's service plan
+	Streams *StreamLimiter // Shared by all domains of the session (nil = unlimited)
+
+	BasicAuth string // "user:pass" visitors must send; empty = public
+
+	Limiter *rate.Limiter  // Requests per second the ingress forwards (nil = unlimited)
+	Traffic *DomainTraffic // Counted by ingress, pushed to the client (nil = not counted)
+}
+
+// DomainTraffic counts the requests and bytes proxied to a bound domain.
+type DomainTraffic struct {
+	Requests  atomic.Int64
+	Bytes     atomic.Int64
+	Throttled atomic.Int64 // Requests refused over the rate limit
+}
+
+// Record counts one request of n bytes. It is a no-op on a nil receiver.
+func (t *DomainTraffic) Record(n int64) {
+	if t == nil {
+		return
+	}
+	t.Requests.Add(1)
+	t.Bytes.Add(n)
+}
+
+// Throttle counts one request refused over the rate limit. It is a no-op
+// on a nil receiver.
+func (t *DomainTraffic) Throttle() {
+	if t == nil {
+		return
+	}
+	t.Throttled.Add(1)
 }
 
 // TunnelRegistry manages the mapping between hostnames and active Yamux sessions.
@@ -26,12 +60,17 @@ func NewTunnelRegistry() *TunnelRegistry {
 
 // Register maps a hostname to a session with user ID.
 func (r *TunnelRegistry) Register(hostname string, session *yamux.Session, userID uint) {
-	r.mu.Lock()
-	defer r.mu.Unlock()
-	r.sessions[hostname] = &TunnelEntry{
+	r.RegisterEntry(hostname, &TunnelEntry{
 		Session: session,
 		UserID:  userID,
-	}
+	})
+}
+
+// RegisterEntry maps a hostname to a tunnel entry.
+func (r *TunnelRegistry) RegisterEntry(hostname string, entry *TunnelEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.sessions[hostname] = entry
 }
 
 // Unregister removes a mapping.
@@ -59,3 +98,56 @@ func (r *TunnelRegistry) GetEntry(hostname string) (*TunnelEntry, bool) {
 	entry, ok := r.sessions[hostname]
 	return entry, ok
 }
+
+// Hostnames returns the hostnames currently registered.
+func (r *TunnelRegistry) Hostnames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	hostnames := make([]string, 0, len(r.sessions))
+	for hostname := range r.sessions {
+		hostnames = append(hostnames, hostname)
+	}
+	return hostnames
+}
+
+// SessionEntries returns the entries bound to session, by hostname.
+func (r *TunnelRegistry) SessionEntries(session *yamux.Session) map[string]*TunnelEntry {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	entries := make(map[string]*TunnelEntry)
+	for hostname, entry := range r.sessions {
+		if entry.Session == session {
+			entries[hostname] = entry
+		}
+	}
+	return entries
+}
+
+// SetUserPlan updates the plan of all entries owned by the user.
+// Entries are replaced rather than modified, as readers hold no lock.
+func (r *TunnelRegistry) SetUserPlan(userID uint, plan string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for hostname, entry := range r.sessions {
+		if entry.UserID == userID {
+			updated := *entry
+			updated.Plan = plan
+			r.sessions[hostname] = &updated
+		}
+	}
+}
+
+// Reap removes entries whose session has already been closed.
+// Returns the number of removed entries.
+func (r *TunnelRegistry) Reap() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := 0
+	for hostname, entry := range r.sessions {
+		if entry.Session != nil && entry.Session.IsClosed() {
+			delete(r.sessions, hostname)
+			removed++
+		}
+	}
+	return removed
+}
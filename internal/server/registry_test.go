@@ -116,3 +116,45 @@ func TestTunnelRegistry_MultipleHosts(t *testing.T) {
 		t.Error("Expected a.example.com to still be registered")
 	}
 }
+
+func TestTunnelRegistry_SetUserPlan(t *testing.T) {
+	registry := NewTunnelRegistry()
+	registry.RegisterEntry("a.example.com", &TunnelEntry{UserID: 1, Plan: "free"})
+	registry.RegisterEntry("b.example.com", &TunnelEntry{UserID: 1, Plan: "free"})
+	registry.RegisterEntry("c.example.com", &TunnelEntry{UserID: 2, Plan: "free"})
+
+	before, _ := registry.GetEntry("a.example.com")
+	registry.SetUserPlan(1, "pro")
+
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		if entry, _ := registry.GetEntry(host); entry.Plan != "pro" {
+			t.Errorf("%s plan = %q, want pro", host, entry.Plan)
+		}
+	}
+	if entry, _ := registry.GetEntry("c.example.com"); entry.Plan != "free" {
+		t.Errorf("other user's plan changed to %q", entry.Plan)
+	}
+	if before.Plan != "free" {
+		t.Error("entry held by a reader was modified in place")
+	}
+}
+
+func TestTunnelRegistry_SessionEntries(t *testing.T) {
+	a, b := &yamux.Session{}, &yamux.Session{}
+	registry := NewTunnelRegistry()
+	registry.RegisterEntry("a1.example.com", &TunnelEntry{Session: a, Traffic: &DomainTraffic{}})
+	registry.RegisterEntry("a2.example.com", &TunnelEntry{Session: a})
+	registry.RegisterEntry("b.example.com", &TunnelEntry{Session: b})
+
+	entries := registry.SessionEntries(a)
+	if len(entries) != 2 || entries["b.example.com"] != nil {
+		t.Fatalf("SessionEntries(a) = %v, want a1 and a2", entries)
+	}
+
+	entries["a1.example.com"].Traffic.Record(100)
+	entries["a2.example.com"].Traffic.Record(100) // Not counted: nil Traffic
+	traffic := entries["a1.example.com"].Traffic
+	if traffic.Requests.Load() != 1 || traffic.Bytes.Load() != 100 {
+		t.Errorf("Traffic = %d requests, %d bytes, want 1, 100", traffic.Requests.Load(), traffic.Bytes.Load())
+	}
+}
@@ -5,16 +5,20 @@ import (
 	"crypto/tls"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"log"
 	"net"
 	"os"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/hashicorp/yamux"
 
+	"gopublic/internal/billing"
 	"gopublic/internal/config"
 	"gopublic/internal/models"
+	"gopublic/internal/pubsub"
 	"gopublic/internal/sentry"
 	"gopublic/internal/storage"
 	"gopublic/pkg/protocol"
@@ -40,11 +44,56 @@ type Server struct {
 
 	// DailyBandwidthLimit is the daily bandwidth limit per user in bytes
 	DailyBandwidthLimit int64
+
+	// PlanBandwidth overrides DailyBandwidthLimit per service plan (optional)
+	PlanBandwidth billing.PlanLimits
+
+	// Events propagates control events between instances (optional)
+	Events pubsub.Bus
+
+	// MaxStreamsPerSession limits concurrent ingress streams per session (0 = unlimited).
+	// Requests over the limit queue for up to StreamQueueTimeout.
+	MaxStreamsPerSession int
+	StreamQueueTimeout   time.Duration
+
+	// DrainTimeout is how long a force-disconnected session may finish
+	// in-flight requests before it is closed
+	DrainTimeout time.Duration
+
+	// RateLimit caps the requests per second the ingress forwards to each
+	// bound domain (zero RPS = unlimited); clients may ask for less
+	RateLimit protocol.RateLimit
+
+	// TokenTTL is the lifetime of tokens renewed with a refresh token
+	TokenTTL time.Duration
+
+	// MaxAgentsPerUser limits the named agent sessions a user may hold
+	// besides the regular one (0 = unlimited)
+	MaxAgentsPerUser int
+
+	// TCPPorts assigns public ports to raw TCP tunnels (nil = disabled)
+	TCPPorts *TCPPortPool
+
+	// Admission sheds load by refusing new sessions (nil = no limits)
+	Admission *Admission
 }
 
 // NewServerWithConfig creates a new server with the given configuration.
 func NewServerWithConfig(cfg *config.Config, registry *TunnelRegistry, tlsConfig *tls.Config) *Server {
 	ctx, cancel := context.WithCancel(context.Background())
+	var tcpPorts *TCPPortPool
+	if cfg.TCPPortMin > 0 {
+		tcpPorts = NewTCPPortPool(cfg.TCPPortMin, cfg.TCPPortMax, cfg.TCPPortsPerUser)
+	}
+	var admission *Admission
+	if cfg.MaxSessions > 0 || cfg.MaxMemoryMB > 0 || cfg.MaxGoroutines > 0 {
+		admission = &Admission{
+			MaxSessions:   cfg.MaxSessions,
+			MaxMemoryMB:   cfg.MaxMemoryMB,
+			MaxGoroutines: cfg.MaxGoroutines,
+			RetryAfter:    cfg.ShedRetryAfter,
+		}
+	}
 	return &Server{
 		Registry:            registry,
 		UserSessions:        NewUserSessionRegistry(),
@@ -55,6 +104,16 @@ func NewServerWithConfig(cfg *config.Config, registry *TunnelRegistry, tlsConfig
 		cancel:              cancel,
 		MaxConnections:      cfg.MaxConnections,
 		DailyBandwidthLimit: cfg.DailyBandwidthLimit,
+		PlanBandwidth:       cfg.PlanBandwidthLimits,
+
+		MaxStreamsPerSession: cfg.MaxStreamsPerSession,
+		StreamQueueTimeout:   cfg.StreamQueueTimeout,
+		DrainTimeout:         cfg.DrainTimeout,
+		RateLimit:            protocol.RateLimit{RPS: cfg.TunnelRateLimit, Burst: cfg.TunnelRateBurst},
+		TokenTTL:             cfg.TokenTTL,
+		MaxAgentsPerUser:     cfg.MaxAgentsPerUser,
+		TCPPorts:             tcpPorts,
+		Admission:            admission,
 	}
 }
 
@@ -196,39 +255,96 @@ func (s *Server) handleConnection(conn net.Conn) {
 		return
 	}
 
+	// Refuse before authenticating, which costs a database lookup
+	if reason := s.Admission.Check(s.UserSessions.Count()); reason != "" {
+		log.Printf("Shedding load: refused %s at %s", conn.RemoteAddr(), reason)
+		json.NewEncoder(stream).Encode(protocol.InitResponse{
+			Success:    false,
+			Error:      "The server is busy. Try again later.",
+			ErrorCode:  protocol.ErrorCodeServerBusy,
+			RetryAfter: s.Admission.retryAfterSeconds(),
+		})
+		session.Close()
+		return
+	}
+
 	// Create a single decoder for the entire handshake to avoid buffering issues
 	decoder := json.NewDecoder(stream)
 
-	// 2. Authenticate client
-	user, force, err := s.authenticate(decoder, stream, conn.RemoteAddr().String())
+	// 2. Authenticate client, or renew its token
+	user, authReq, err := s.authenticate(decoder, stream, conn.RemoteAddr().String())
+	if errors.Is(err, errTokenRefreshed) || errors.Is(err, storage.ErrTokenExpired) {
+		session.Close()
+		return
+	}
 	if err != nil {
 		sentry.CaptureErrorf(err, "Authentication failed for %s", conn.RemoteAddr())
 		session.Close()
 		return
 	}
 
-	// 3. Check for existing session
-	if existingSession, exists := s.UserSessions.GetSession(user.ID); exists {
-		if !force {
+	// Managed clients fetch their tunnel definitions before opening a session
+	if authReq.Definitions {
+		if err := s.sendDefinitions(stream, user.ID); err != nil {
+			log.Printf("Failed to send tunnel definitions to user %d: %v", user.ID, err)
+		}
+		session.Close()
+		return
+	}
+
+	// Probe sessions (speedtest) bind no domains and leave any active session alone
+	if authReq.Probe {
+		if err := s.acceptProbe(decoder, stream); err != nil {
+			session.Close()
+			return
+		}
+		s.serveProbe(session, user.ID)
+		return
+	}
+
+	device := s.recordDevice(user.ID, conn.RemoteAddr().String(), authReq.Device)
+
+	if s.overQuota(user) {
+		log.Printf("Rejected user %d from %s: daily bandwidth used up", user.ID, conn.RemoteAddr())
+		s.sendErrorWithCode(stream, "Daily bandwidth limit reached. Tunnels can be started again tomorrow or after upgrading your plan.", protocol.ErrorCodeQuotaExceeded)
+		session.Close()
+		return
+	}
+
+	// 3. Check for an existing session of the same agent name
+	if err := protocol.ValidateAgent(authReq.Agent); err != nil {
+		s.sendErrorWithCode(stream, err.Error(), protocol.ErrorCodeInvalidAgent)
+		session.Close()
+		return
+	}
+	if existingSession, exists := s.UserSessions.GetSession(user.ID, authReq.Agent); exists {
+		if !authReq.Force {
 			// Reject connection - user already has active session
-			log.Printf("User %d already connected, rejecting new connection (use force=true to override)", user.ID)
+			log.Printf("User %d already connected (agent=%q), rejecting new connection (use force=true to override)", user.ID, authReq.Agent)
 			s.sendErrorWithCode(stream, "You already have an active tunnel session. Use --force to disconnect the existing session.", protocol.ErrorCodeAlreadyConnected)
 			session.Close()
 			return
 		}
 
 		// Force mode: disconnect old session
-		log.Printf("Force disconnect: closing existing session for user %d", user.ID)
+		log.Printf("Force disconnect: closing existing session for user %d (agent=%q)", user.ID, authReq.Agent)
 		// Unregister old domains first
 		for _, domain := range existingSession.Domains {
 			s.Registry.Unregister(domain)
 		}
+		existingSession.setCloseReason("replaced by a new connection of the same client")
 		existingSession.Session.Close()
-		s.UserSessions.Unregister(user.ID)
+		s.UserSessions.Unregister(user.ID, existingSession.Session)
+	} else if authReq.Agent != "" && s.MaxAgentsPerUser > 0 && s.UserSessions.AgentCount(user.ID) >= s.MaxAgentsPerUser {
+		log.Printf("User %d has %d agents connected, rejecting agent %q", user.ID, s.MaxAgentsPerUser, authReq.Agent)
+		s.sendErrorWithCode(stream, fmt.Sprintf("Too many agents connected (max %d). Disconnect one from the dashboard first.", s.MaxAgentsPerUser), protocol.ErrorCodeTooManyAgents)
+		session.Close()
+		return
 	}
 
 	// 4. Process tunnel request and bind domains
-	boundDomains, err := s.processTunnelRequest(decoder, stream, session, user, conn.RemoteAddr().String())
+	streams := NewStreamLimiter(s.MaxStreamsPerSession, s.StreamQueueTimeout)
+	boundDomains, labels, err := s.processTunnelRequest(decoder, stream, session, streams, user, conn.RemoteAddr().String())
 	if err != nil {
 		sentry.CaptureErrorf(err, "Tunnel request failed for %s", conn.RemoteAddr())
 		session.Close()
@@ -236,16 +352,37 @@ func (s *Server) handleConnection(conn net.Conn) {
 	}
 
 	// 5. Register user session
-	s.UserSessions.Register(user.ID, session, boundDomains)
+	userSession := &UserSession{
+		UserID:   user.ID,
+		Session:  session,
+		Domains:  boundDomains,
+		Labels:   labels,
+		Streams:  streams,
+		Device:   device,
+		Agent:    authReq.Agent,
+		Version:  authReq.Version,
+		Commands: authReq.Commands,
+		Managed:  authReq.Managed,
+	}
+	s.UserSessions.Register(userSession)
+	s.recordConnectionEvents(userSession, models.ConnectionEventConnect, "", "", boundDomains)
 
 	// 6. Send success response
-	if err := s.sendSuccessResponse(stream, boundDomains, user.ID); err != nil {
+	if err := s.sendSuccessResponse(stream, boundDomains, user); err != nil {
 		sentry.CaptureErrorf(err, "Failed to send success response to %s", conn.RemoteAddr())
 	}
 	log.Printf("Handshake complete for %s. Bound domains: %v", conn.RemoteAddr(), boundDomains)
 
 	// 7. Monitor session for cleanup
-	s.monitorSession(session, user.ID, boundDomains)
+	s.monitorSession(userSession, boundDomains)
+
+	// 8. Serve later bind requests for domains that were refused
+	go s.serveBindRequests(decoder, stream, userSession, user)
+
+	// 9. Echo the client's heartbeats with periodic usage, see protocol.Heartbeat
+	go serveHeartbeats(session, protocol.StatsPushInterval, func() *protocol.StatsPush {
+		return s.statsPush(session, user)
+	}, userSession.nextCommand)
 }
 
 // Handshake timeout for server-side operations
@@ -276,49 +413,175 @@ func (s *Server) setupYamuxSession(conn net.Conn) (*yamux.Session, net.Conn, err
 	return session, stream, nil
 }
 
-// authenticate validates the client's token and returns the user and force flag.
-func (s *Server) authenticate(decoder *json.Decoder, stream net.Conn, remoteAddr string) (*models.User, bool, error) {
+// authenticate validates the client's token and returns the user and the auth request.
+func (s *Server) authenticate(decoder *json.Decoder, stream net.Conn, remoteAddr string) (*models.User, protocol.AuthRequest, error) {
 	// Set read deadline for auth request
 	stream.SetReadDeadline(time.Now().Add(handshakeTimeout))
 	defer stream.SetReadDeadline(time.Time{}) // Clear deadline after auth
 
 	var authReq protocol.AuthRequest
 	if err := decoder.Decode(&authReq); err != nil {
-		return nil, false, err
+		return nil, authReq, err
+	}
+	log.Printf("Auth request received from %s (force=%v, probe=%v)", remoteAddr, authReq.Force, authReq.Probe)
+
+	if authReq.Refresh != "" {
+		return nil, authReq, s.refreshToken(stream, authReq.Refresh, remoteAddr)
+	}
+	if authReq.Join != "" {
+		return nil, authReq, s.joinAgent(stream, authReq.Join, authReq.Agent, remoteAddr)
 	}
-	log.Printf("Auth request received from %s (force=%v)", remoteAddr, authReq.Force)
 
 	user, err := storage.ValidateToken(authReq.Token)
+	if errors.Is(err, storage.ErrTokenExpired) {
+		s.sendErrorWithCode(stream, "Token expired", protocol.ErrorCodeTokenExpired)
+		return nil, authReq, err
+	}
 	if err != nil {
 		s.sendErrorWithCode(stream, "Invalid Token", protocol.ErrorCodeInvalidToken)
-		return nil, false, err
+		return nil, authReq, err
 	}
 	log.Printf("User %s authenticated (ID: %d)", user.Username, user.ID)
 
-	return user, authReq.Force, nil
+	return user, authReq, nil
+}
+
+// errTokenRefreshed ends a session opened only to renew or obtain a token.
+var errTokenRefreshed = errors.New("token refreshed")
+
+// refreshToken answers an AuthRequest with Refresh set with a new token.
+// It returns errTokenRefreshed once the client has been answered.
+func (s *Server) refreshToken(stream net.Conn, refresh, remoteAddr string) error {
+	token, newRefresh, expiresAt, err := storage.RefreshToken(refresh, s.TokenTTL)
+	if err != nil {
+		log.Printf("Token refresh from %s refused: %v", remoteAddr, err)
+		s.sendErrorWithCode(stream, "Invalid refresh token", protocol.ErrorCodeInvalidToken)
+		if errors.Is(err, storage.ErrNotFound) {
+			return errTokenRefreshed
+		}
+		return err
+	}
+	log.Printf("Token refreshed for %s", remoteAddr)
+	resp := protocol.InitResponse{
+		Success:   true,
+		Refreshed: &protocol.TokenRefresh{Token: token, RefreshToken: newRefresh, ExpiresAt: expiresAt},
+	}
+	if err := json.NewEncoder(stream).Encode(resp); err != nil {
+		return err
+	}
+	return errTokenRefreshed
+}
+
+// sendDefinitions answers an AuthRequest with Definitions set with the
+// user's tunnel definitions, see protocol.TunnelDefinition.
+func (s *Server) sendDefinitions(stream net.Conn, userID uint) error {
+	defs, err := storage.GetUserTunnelDefinitions(userID)
+	if err != nil {
+		s.sendError(stream, "Failed to retrieve tunnel definitions")
+		return err
+	}
+	resp := protocol.InitResponse{Success: true, Definitions: make([]protocol.TunnelDefinition, 0, len(defs))}
+	for _, d := range defs {
+		resp.Definitions = append(resp.Definitions, protocol.TunnelDefinition{
+			Name:      d.Name,
+			Subdomain: d.Domain,
+			Port:      d.Port,
+			Selector:  d.Selector,
+		})
+	}
+	return json.NewEncoder(stream).Encode(resp)
+}
+
+// joinAgent answers an AuthRequest with Join set with a token of the
+// agent's own. It returns errTokenRefreshed once the client has been
+// answered.
+func (s *Server) joinAgent(stream net.Conn, join, agent, remoteAddr string) error {
+	if agent == "" || protocol.ValidateAgent(agent) != nil {
+		s.sendErrorWithCode(stream, "Joining requires a valid agent name", protocol.ErrorCodeInvalidAgent)
+		return errTokenRefreshed
+	}
+	token, err := storage.RedeemJoinToken(join, agent)
+	if err != nil {
+		log.Printf("Join of agent %q from %s refused: %v", agent, remoteAddr, err)
+		s.sendErrorWithCode(stream, "Invalid, used or expired join token", protocol.ErrorCodeInvalidToken)
+		if errors.Is(err, storage.ErrNotFound) {
+			return errTokenRefreshed
+		}
+		return err
+	}
+	log.Printf("Agent %q joined from %s", agent, remoteAddr)
+	resp := protocol.InitResponse{
+		Success:   true,
+		Refreshed: &protocol.TokenRefresh{Token: token},
+	}
+	if err := json.NewEncoder(stream).Encode(resp); err != nil {
+		return err
+	}
+	return errTokenRefreshed
+}
+
+// acceptProbe completes the handshake for a probe session: the tunnel
+// request is read to keep the message sequence, then acknowledged.
+func (s *Server) acceptProbe(decoder *json.Decoder, stream net.Conn) error {
+	stream.SetReadDeadline(time.Now().Add(handshakeTimeout))
+	var tunnelReq protocol.TunnelRequest
+	if err := decoder.Decode(&tunnelReq); err != nil {
+		return err
+	}
+	stream.SetReadDeadline(time.Time{})
+
+	return json.NewEncoder(stream).Encode(protocol.InitResponse{Success: true})
 }
 
 // processTunnelRequest handles the tunnel request and binds domains.
-func (s *Server) processTunnelRequest(decoder *json.Decoder, stream net.Conn, session *yamux.Session, user *models.User, remoteAddr string) ([]string, error) {
+// Returns the bound domains and the labels supplied by the client.
+func (s *Server) processTunnelRequest(decoder *json.Decoder, stream net.Conn, session *yamux.Session, streams *StreamLimiter, user *models.User, remoteAddr string) ([]string, map[string]string, error) {
 	// Set read deadline for tunnel request
 	stream.SetReadDeadline(time.Now().Add(handshakeTimeout))
 
 	var tunnelReq protocol.TunnelRequest
 	if err := decoder.Decode(&tunnelReq); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	log.Printf("Tunnel request received from %s for %d domains", remoteAddr, len(tunnelReq.RequestedDomains))
 
 	// Clear read deadline before database operations
 	stream.SetReadDeadline(time.Time{})
 
+	if err := protocol.ValidateLabels(tunnelReq.Labels); err != nil {
+		s.sendErrorWithCode(stream, "Invalid labels: "+err.Error(), protocol.ErrorCodeInvalidLabels)
+		return nil, nil, err
+	}
+	if err := validateBasicAuth(tunnelReq.BasicAuth); err != nil {
+		s.sendErrorWithCode(stream, "Invalid basic auth: "+err.Error(), protocol.ErrorCodeInvalidBasicAuth)
+		return nil, nil, err
+	}
+	if err := validateRateLimits(tunnelReq.RateLimit); err != nil {
+		s.sendErrorWithCode(stream, "Invalid rate limit: "+err.Error(), protocol.ErrorCodeInvalidRateLimit)
+		return nil, nil, err
+	}
+
+	switch tunnelReq.Proto {
+	case "", protocol.ProtoHTTP:
+	case protocol.ProtoTCP:
+		addr, err := s.bindTCP(session, streams, user)
+		if err != nil {
+			s.sendErrorWithCode(stream, "TCP tunnel unavailable: "+err.Error(), protocol.ErrorCodeTCPUnavailable)
+			return nil, nil, err
+		}
+		return []string{addr}, tunnelReq.Labels, nil
+	default:
+		s.sendError(stream, "Unsupported tunnel protocol: "+tunnelReq.Proto)
+		return nil, nil, errors.New("unsupported tunnel protocol")
+	}
+
 	// If no domains requested, get all user domains
 	requestedDomains := tunnelReq.RequestedDomains
 	if len(requestedDomains) == 0 {
 		userDomains, err := storage.GetUserDomains(user.ID)
 		if err != nil {
 			s.sendError(stream, "Failed to retrieve user domains")
-			return nil, err
+			return nil, nil, err
 		}
 		log.Printf("Client requested all domains. Found %d domains in DB for user %d", len(userDomains), user.ID)
 		for _, d := range userDomains {
@@ -327,21 +590,26 @@ func (s *Server) processTunnelRequest(decoder *json.Decoder, stream net.Conn, se
 	}
 
 	// Bind domains
-	boundDomains := s.bindDomains(session, user.ID, requestedDomains)
+	boundDomains := s.bindDomains(session, streams, user, requestedDomains, tunnelReq.BasicAuth, tunnelReq.RateLimit)
 
 	if len(boundDomains) == 0 {
-		s.sendError(stream, "No valid domains requested or authorized")
-		return nil, errors.New("no domains bound")
+		s.sendErrorWithCode(stream, "No valid domains requested or authorized", protocol.ErrorCodeNoDomains)
+		return nil, nil, errors.New("no domains bound")
 	}
 
-	return boundDomains, nil
+	return boundDomains, tunnelReq.Labels, nil
 }
 
 // bindDomains validates ownership and registers domains with the session.
-func (s *Server) bindDomains(session *yamux.Session, userID uint, requestedDomains []string) []string {
+func (s *Server) bindDomains(session *yamux.Session, streams *StreamLimiter, user *models.User, requestedDomains []string, basicAuth map[string]string, rateLimits map[string]protocol.RateLimit) []string {
 	var boundDomains []string
+	userID := user.ID
 
 	for _, name := range requestedDomains {
+		// Clients may request the full hostname, e.g. gopublic start --domain
+		if s.RootDomain != "" {
+			name = strings.TrimSuffix(name, "."+s.RootDomain)
+		}
 		log.Printf("Processing domain bind: %s (User: %d)", name, userID)
 
 		isOwner, err := storage.ValidateDomainOwnership(name, userID)
@@ -361,7 +629,15 @@ func (s *Server) bindDomains(session *yamux.Session, userID uint, requestedDomai
 			regName = name + "." + s.RootDomain
 		}
 
-		s.Registry.Register(regName, session, userID)
+		s.Registry.RegisterEntry(regName, &TunnelEntry{
+			Session:   session,
+			UserID:    userID,
+			Plan:      user.Plan,
+			Streams:   streams,
+			BasicAuth: protocol.BasicAuthFor(basicAuth, name),
+			Limiter:   newRateLimiter(domainRateLimit(s.RateLimit, rateLimits, name)),
+			Traffic:   &DomainTraffic{},
+		})
 		boundDomains = append(boundDomains, regName)
 		log.Printf("Successfully bound domain %s for user %d", regName, userID)
 	}
@@ -369,36 +645,147 @@ func (s *Server) bindDomains(session *yamux.Session, userID uint, requestedDomai
 	return boundDomains
 }
 
+// validateBasicAuth checks every credential of a tunnel request.
+func validateBasicAuth(basicAuth map[string]string) error {
+	for domain, creds := range basicAuth {
+		if err := protocol.ValidateBasicAuth(creds); err != nil {
+			return fmt.Errorf("%s: %w", domain, err)
+		}
+	}
+	return nil
+}
+
+// overQuota reports whether the user has used up today's bandwidth. Ingress
+// refuses all their traffic then, so there is no point in binding domains.
+func (s *Server) overQuota(user *models.User) bool {
+	limit := s.PlanBandwidth.Bandwidth(user.Plan, s.DailyBandwidthLimit)
+	if limit <= 0 {
+		return false
+	}
+	used, err := storage.GetUserBandwidthToday(user.ID)
+	if err != nil {
+		log.Printf("Failed to check bandwidth for user %d: %v", user.ID, err)
+		return false
+	}
+	return used >= limit
+}
+
+// recordBandwidth adds bytes forwarded outside the ingress (raw TCP
+// tunnels) to the user's usage and announces the moment the daily limit is
+// crossed, so that all instances refuse the user's traffic from then on.
+func (s *Server) recordBandwidth(user *models.User, bytes int64) {
+	if err := storage.AddUserBandwidth(user.ID, bytes); err != nil {
+		log.Printf("Failed to record TCP bandwidth for user %d: %v", user.ID, err)
+		return
+	}
+	limit := s.PlanBandwidth.Bandwidth(user.Plan, s.DailyBandwidthLimit)
+	used, err := storage.GetUserBandwidthToday(user.ID)
+	if err != nil || !quotaCrossed(limit, used, bytes) {
+		return
+	}
+	event := pubsub.Event{Type: pubsub.EventQuotaExceeded, UserID: user.ID, Reason: "daily bandwidth limit"}
+	if err := s.publish(s.ctx, event); err != nil {
+		log.Printf("Failed to publish quota event for user %d: %v", user.ID, err)
+	}
+}
+
+// quotaCrossed reports whether adding the last bytes took usage to the
+// limit (0 = unlimited) or beyond.
+func quotaCrossed(limit, used, bytes int64) bool {
+	return limit > 0 && used >= limit && used-bytes < limit
+}
+
 // sendSuccessResponse sends the handshake success response to the client.
-func (s *Server) sendSuccessResponse(stream net.Conn, boundDomains []string, userID uint) error {
+func (s *Server) sendSuccessResponse(stream net.Conn, boundDomains []string, user *models.User) error {
 	// Fetch bandwidth statistics for the user
-	bandwidthToday, _ := storage.GetUserBandwidthToday(userID)
-	bandwidthTotal, _ := storage.GetUserTotalBandwidth(userID)
+	bandwidthToday, _ := storage.GetUserBandwidthToday(user.ID)
+	bandwidthTotal, _ := storage.GetUserTotalBandwidth(user.ID)
 
 	resp := protocol.InitResponse{
 		Success:      true,
 		BoundDomains: boundDomains,
+		Heartbeat:    true,
 		ServerStats: &protocol.ServerStats{
 			BandwidthToday: bandwidthToday,
 			BandwidthTotal: bandwidthTotal,
-			BandwidthLimit: s.DailyBandwidthLimit,
+			BandwidthLimit: s.PlanBandwidth.Bandwidth(user.Plan, s.DailyBandwidthLimit),
 		},
 	}
 	return json.NewEncoder(stream).Encode(resp)
 }
 
-// monitorSession watches for session close and cleans up domain registrations.
-func (s *Server) monitorSession(session *yamux.Session, userID uint, boundDomains []string) {
+// monitorSession watches for session close, records why it closed and
+// cleans up domain registrations.
+func (s *Server) monitorSession(sess *UserSession, boundDomains []string) {
 	go func() {
-		<-session.CloseChan()
-		log.Printf("Session closed for user %d. Cleaning up domains.", userID)
+		<-sess.Session.CloseChan()
+		reason, detail := disconnectReason(sessionError(sess.Session), sess.forcedReason())
+		log.Printf("Session closed for user %d (%s). Cleaning up domains.", sess.UserID, reason)
+		s.recordConnectionEvents(sess, models.ConnectionEventDisconnect, reason, detail, s.UserSessions.SessionDomains(sess))
 		for _, d := range boundDomains {
 			s.Registry.Unregister(d)
 		}
-		s.UserSessions.Unregister(userID)
+		s.UserSessions.Unregister(sess.UserID, sess.Session)
 	}()
 }
 
+// maxBindRequestDomains caps the domains in a single post-handshake bind request.
+const maxBindRequestDomains = 100
+
+// serveBindRequests reads further TunnelRequests from the control stream
+// after the handshake and binds their domains to the session, so that a
+// client can retry tunnels refused at first without dropping the others.
+// Each request is answered with an InitResponse listing only the newly
+// bound domains. Late bindings are unregistered when the session closes.
+func (s *Server) serveBindRequests(decoder *json.Decoder, stream net.Conn, sess *UserSession, user *models.User) {
+	session := sess.Session
+	var bound []string
+	defer func() {
+		for _, d := range bound {
+			s.Registry.Unregister(d)
+		}
+	}()
+
+	for {
+		var req protocol.TunnelRequest
+		if err := decoder.Decode(&req); err != nil {
+			return
+		}
+		if len(req.RequestedDomains) == 0 || len(req.RequestedDomains) > maxBindRequestDomains {
+			s.sendErrorWithCode(stream, "No valid domains requested or authorized", protocol.ErrorCodeNoDomains)
+			continue
+		}
+
+		if err := validateBasicAuth(req.BasicAuth); err != nil {
+			s.sendErrorWithCode(stream, "Invalid basic auth: "+err.Error(), protocol.ErrorCodeInvalidBasicAuth)
+			continue
+		}
+		if err := validateRateLimits(req.RateLimit); err != nil {
+			s.sendErrorWithCode(stream, "Invalid rate limit: "+err.Error(), protocol.ErrorCodeInvalidRateLimit)
+			continue
+		}
+
+		domains := s.bindDomains(session, sess.Streams, user, req.RequestedDomains, req.BasicAuth, req.RateLimit)
+		if session.IsClosed() {
+			// Raced with the session cleanup; undo via the deferred unregister
+			bound = append(bound, domains...)
+			return
+		}
+		if len(domains) == 0 {
+			s.sendErrorWithCode(stream, "No valid domains requested or authorized", protocol.ErrorCodeNoDomains)
+			continue
+		}
+		bound = append(bound, domains...)
+		s.UserSessions.AddDomains(user.ID, session, domains)
+		s.recordConnectionEvents(sess, models.ConnectionEventBind, "", "", domains)
+		log.Printf("Late bind for user %d: %v", user.ID, domains)
+
+		if err := json.NewEncoder(stream).Encode(protocol.InitResponse{Success: true, BoundDomains: domains}); err != nil {
+			return
+		}
+	}
+}
+
 func (s *Server) sendError(stream net.Conn, msg string) {
 	resp := protocol.InitResponse{
 		Success: false,
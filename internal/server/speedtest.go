@@ -0,0 +1,93 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"time"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/pkg/protocol"
+)
+
+// probeSessionTimeout bounds the lifetime of a probe (speedtest) session.
+const probeSessionTimeout = 2 * time.Minute
+
+// serveProbe answers speedtest streams on a probe session until the client
+// closes it, the timeout expires or the server shuts down.
+func (s *Server) serveProbe(session *yamux.Session, userID uint) {
+	log.Printf("Probe session started for user %d", userID)
+	defer log.Printf("Probe session ended for user %d", userID)
+
+	timer := time.AfterFunc(probeSessionTimeout, func() { session.Close() })
+	defer timer.Stop()
+	defer session.Close()
+
+	if s.ctx != nil {
+		go func() {
+			select {
+			case <-s.ctx.Done():
+				session.Close()
+			case <-session.CloseChan():
+			}
+		}()
+	}
+
+	for {
+		stream, err := session.Accept()
+		if err != nil {
+			return
+		}
+		go serveSpeedtest(stream)
+	}
+}
+
+// serveSpeedtest performs a single measurement requested by the client.
+func serveSpeedtest(stream net.Conn) {
+	defer stream.Close()
+	stream.SetDeadline(time.Now().Add(probeSessionTimeout))
+
+	decoder := json.NewDecoder(stream)
+	encoder := json.NewEncoder(stream)
+
+	var req protocol.SpeedtestRequest
+	if err := decoder.Decode(&req); err != nil {
+		return
+	}
+	if req.Bytes < 0 || req.Bytes > protocol.MaxSpeedtestBytes {
+		encoder.Encode(protocol.SpeedtestResult{Error: "invalid payload size"})
+		return
+	}
+
+	start := time.Now()
+	switch req.Mode {
+	case protocol.SpeedtestPing:
+		encoder.Encode(protocol.SpeedtestResult{})
+
+	case protocol.SpeedtestDownload:
+		io.CopyN(stream, zeroReader{}, req.Bytes)
+
+	case protocol.SpeedtestUpload:
+		// The decoder may have buffered the start of the payload
+		body := io.MultiReader(decoder.Buffered(), stream)
+		n, err := io.CopyN(io.Discard, body, req.Bytes)
+		result := protocol.SpeedtestResult{Bytes: n, DurationNs: time.Since(start).Nanoseconds()}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		encoder.Encode(result)
+
+	default:
+		encoder.Encode(protocol.SpeedtestResult{Error: "unknown mode"})
+	}
+}
+
+// zeroReader is an endless source of zero bytes.
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
@@ -0,0 +1,75 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"testing"
+
+	"gopublic/pkg/protocol"
+)
+
+func speedtestPipe(t *testing.T, req protocol.SpeedtestRequest) net.Conn {
+	t.Helper()
+	client, server := net.Pipe()
+	t.Cleanup(func() { client.Close() })
+	go serveSpeedtest(server)
+	go json.NewEncoder(client).Encode(req)
+	return client
+}
+
+func TestServeSpeedtest_Ping(t *testing.T) {
+	conn := speedtestPipe(t, protocol.SpeedtestRequest{Mode: protocol.SpeedtestPing})
+
+	var result protocol.SpeedtestResult
+	if err := json.NewDecoder(conn).Decode(&result); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if result.Error != "" {
+		t.Errorf("Error = %q", result.Error)
+	}
+}
+
+func TestServeSpeedtest_Download(t *testing.T) {
+	conn := speedtestPipe(t, protocol.SpeedtestRequest{Mode: protocol.SpeedtestDownload, Bytes: 64 << 10})
+
+	n, err := io.Copy(io.Discard, conn)
+	if err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if n != 64<<10 {
+		t.Errorf("received %d bytes, want %d", n, 64<<10)
+	}
+}
+
+func TestServeSpeedtest_Upload(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	go serveSpeedtest(server)
+
+	const size = 64 << 10
+	go func() {
+		json.NewEncoder(client).Encode(protocol.SpeedtestRequest{Mode: protocol.SpeedtestUpload, Bytes: size})
+		client.Write(make([]byte, size))
+	}()
+
+	var result protocol.SpeedtestResult
+	if err := json.NewDecoder(client).Decode(&result); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if result.Bytes != size || result.Error != "" {
+		t.Errorf("result = %+v, want %d bytes", result, size)
+	}
+}
+
+func TestServeSpeedtest_RejectsOversize(t *testing.T) {
+	conn := speedtestPipe(t, protocol.SpeedtestRequest{Mode: protocol.SpeedtestDownload, Bytes: protocol.MaxSpeedtestBytes + 1})
+
+	var result protocol.SpeedtestResult
+	if err := json.NewDecoder(conn).Decode(&result); err != nil {
+		t.Fatalf("Decode: %v", err)
+	}
+	if result.Error == "" {
+		t.Error("expected error for oversized payload")
+	}
+}
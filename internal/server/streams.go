@@ -0,0 +1,137 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Stream limiter errors
+var (
+	ErrStreamLimit     = errors.New("too many concurrent streams for session")
+	ErrSessionDraining = errors.New("session is draining")
+)
+
+// StreamLimiter bounds the number of concurrent ingress streams on a tunnel
+// session. Requests over the limit wait in a bounded queue for a free slot.
+// Once draining, no new streams are admitted while in-flight ones finish.
+type StreamLimiter struct {
+	max          int           // Concurrent streams (0 = unlimited)
+	queueTimeout time.Duration // How long a request may wait for a slot
+
+	mu       sync.Mutex
+	active   int
+	queued   int
+	draining bool
+	released chan struct{} // Closed and replaced whenever a slot is freed
+}
+
+// NewStreamLimiter creates a limiter allowing max concurrent streams.
+// Up to max further requests may queue for at most queueTimeout.
+func NewStreamLimiter(max int, queueTimeout time.Duration) *StreamLimiter {
+	return &StreamLimiter{
+		max:          max,
+		queueTimeout: queueTimeout,
+		released:     make(chan struct{}),
+	}
+}
+
+// Acquire reserves a stream slot, waiting for one if the session is at its
+// limit. Every successful Acquire must be paired with Release.
+func (l *StreamLimiter) Acquire(ctx context.Context) error {
+	l.mu.Lock()
+	if l.draining {
+		l.mu.Unlock()
+		return ErrSessionDraining
+	}
+	if l.max <= 0 || l.active < l.max {
+		l.active++
+		l.mu.Unlock()
+		return nil
+	}
+	if l.queued >= l.max {
+		l.mu.Unlock()
+		return ErrStreamLimit
+	}
+	l.queued++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		l.queued--
+		l.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	for {
+		l.mu.Lock()
+		if l.draining {
+			l.mu.Unlock()
+			return ErrSessionDraining
+		}
+		if l.active < l.max {
+			l.active++
+			l.mu.Unlock()
+			return nil
+		}
+		released := l.released
+		l.mu.Unlock()
+
+		select {
+		case <-released:
+		case <-timer.C:
+			return ErrStreamLimit
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// Release frees a slot reserved by Acquire.
+func (l *StreamLimiter) Release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.active--
+	l.notify()
+}
+
+// Drain stops admitting new streams and waits until in-flight streams finish
+// or ctx is done. Queued requests are rejected with ErrSessionDraining.
+func (l *StreamLimiter) Drain(ctx context.Context) error {
+	l.mu.Lock()
+	l.draining = true
+	l.notify()
+	for l.active > 0 {
+		released := l.released
+		l.mu.Unlock()
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		l.mu.Lock()
+	}
+	l.mu.Unlock()
+	return nil
+}
+
+// Active returns the number of streams in flight.
+func (l *StreamLimiter) Active() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.active
+}
+
+// Max returns the limit of concurrent streams (0 = unlimited).
+func (l *StreamLimiter) Max() int {
+	return l.max
+}
+
+// notify wakes up everyone waiting for a slot. Caller must hold l.mu.
+func (l *StreamLimiter) notify() {
+	close(l.released)
+	l.released = make(chan struct{})
+}
@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStreamLimiter_QueuesOverLimit(t *testing.T) {
+	l := NewStreamLimiter(1, time.Second)
+	ctx := context.Background()
+
+	if err := l.Acquire(ctx); err != nil {
+		t.Fatalf("first Acquire = %v", err)
+	}
+
+	acquired := make(chan error, 1)
+	go func() { acquired <- l.Acquire(ctx) }()
+
+	select {
+	case err := <-acquired:
+		t.Fatalf("second Acquire returned %v before a slot was free", err)
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.Release()
+	select {
+	case err := <-acquired:
+		if err != nil {
+			t.Fatalf("queued Acquire = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("queued Acquire did not get the released slot")
+	}
+	if got := l.Active(); got != 1 {
+		t.Errorf("Active = %d, want 1", got)
+	}
+}
+
+func TestStreamLimiter_QueueTimeoutAndFull(t *testing.T) {
+	l := NewStreamLimiter(1, 20*time.Millisecond)
+	ctx := context.Background()
+	l.Acquire(ctx)
+
+	// One request may queue; it gives up after the queue timeout
+	queued := make(chan error, 1)
+	go func() { queued <- l.Acquire(ctx) }()
+	time.Sleep(5 * time.Millisecond)
+
+	// The queue holds at most max requests
+	if err := l.Acquire(ctx); !errors.Is(err, ErrStreamLimit) {
+		t.Errorf("Acquire with full queue = %v, want ErrStreamLimit", err)
+	}
+	if err := <-queued; !errors.Is(err, ErrStreamLimit) {
+		t.Errorf("queued Acquire = %v, want ErrStreamLimit", err)
+	}
+}
+
+func TestStreamLimiter_Unlimited(t *testing.T) {
+	l := NewStreamLimiter(0, time.Second)
+	for i := 0; i < 1000; i++ {
+		if err := l.Acquire(context.Background()); err != nil {
+			t.Fatalf("Acquire #%d = %v", i, err)
+		}
+	}
+}
+
+func TestStreamLimiter_Drain(t *testing.T) {
+	l := NewStreamLimiter(1, time.Second)
+	ctx := context.Background()
+	l.Acquire(ctx)
+
+	queued := make(chan error, 1)
+	go func() { queued <- l.Acquire(ctx) }()
+	time.Sleep(5 * time.Millisecond)
+
+	drained := make(chan error, 1)
+	go func() { drained <- l.Drain(ctx) }()
+
+	// Queued and new requests are turned away once draining
+	if err := <-queued; !errors.Is(err, ErrSessionDraining) {
+		t.Errorf("queued Acquire = %v, want ErrSessionDraining", err)
+	}
+	if err := l.Acquire(ctx); !errors.Is(err, ErrSessionDraining) {
+		t.Errorf("Acquire while draining = %v, want ErrSessionDraining", err)
+	}
+
+	select {
+	case <-drained:
+		t.Fatal("Drain returned with a stream in flight")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	l.Release()
+	select {
+	case err := <-drained:
+		if err != nil {
+			t.Errorf("Drain = %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Drain did not finish after the last stream was released")
+	}
+}
+
+func TestStreamLimiter_DrainTimeout(t *testing.T) {
+	l := NewStreamLimiter(1, time.Second)
+	l.Acquire(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Drain(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Drain = %v, want DeadlineExceeded", err)
+	}
+}
@@ -0,0 +1,161 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/bufpool"
+	"gopublic/internal/models"
+)
+
+// Errors returned by TCPPortPool.Listen.
+var (
+	ErrNoTCPPorts   = errors.New("no free TCP tunnel port")
+	ErrTCPPortLimit = errors.New("TCP tunnel port limit reached")
+)
+
+// TCPPortPool hands out public ports for raw TCP tunnels from a fixed range.
+type TCPPortPool struct {
+	mu      sync.Mutex
+	min     int
+	max     int
+	perUser int          // Ports one user may hold at once (0 = unlimited)
+	used    map[int]uint // Port -> user holding it
+	next    int          // Where the search for a free port starts, so ports are not reused right away
+}
+
+// NewTCPPortPool creates a pool of the ports min to max, inclusive, of
+// which each user may hold perUser at once (0 = unlimited).
+func NewTCPPortPool(min, max, perUser int) *TCPPortPool {
+	return &TCPPortPool{min: min, max: max, perUser: perUser, used: make(map[int]uint), next: min}
+}
+
+// Listen listens on a free port of the pool for the user. Ports taken by
+// other processes are skipped. The port must be returned with Release once
+// the listener is closed.
+func (p *TCPPortPool) Listen(userID uint) (net.Listener, int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.perUser > 0 {
+		held := 0
+		for _, owner := range p.used {
+			if owner == userID {
+				held++
+			}
+		}
+		if held >= p.perUser {
+			return nil, 0, ErrTCPPortLimit
+		}
+	}
+
+	size := p.max - p.min + 1
+	for i := 0; i < size; i++ {
+		port := p.min + (p.next-p.min+i)%size
+		if _, ok := p.used[port]; ok {
+			continue
+		}
+		ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+		if err != nil {
+			continue
+		}
+		p.used[port] = userID
+		p.next = port + 1
+		return ln, port, nil
+	}
+	return nil, 0, ErrNoTCPPorts
+}
+
+// Release returns a port to the pool.
+func (p *TCPPortPool) Release(port int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.used, port)
+}
+
+// bindTCP assigns a public port to a TCP tunnel session and bridges every
+// connection to it over a new stream until the session closes. It returns
+// the public "host:port".
+func (s *Server) bindTCP(session *yamux.Session, streams *StreamLimiter, user *models.User) (string, error) {
+	if s.TCPPorts == nil {
+		return "", errors.New("TCP tunnels are disabled")
+	}
+	ln, port, err := s.TCPPorts.Listen(user.ID)
+	if err != nil {
+		return "", err
+	}
+
+	go func() {
+		<-session.CloseChan()
+		ln.Close()
+	}()
+	go func() {
+		defer s.TCPPorts.Release(port)
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.proxyTCP(conn, session, streams, user)
+		}
+	}()
+
+	host := s.RootDomain
+	if host == "" {
+		host = "localhost"
+	}
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	log.Printf("Bound TCP tunnel %s for user %d", addr, user.ID)
+	return addr, nil
+}
+
+// proxyTCP copies a public TCP connection to a new stream of the session
+// and back, and counts the bytes against the user's bandwidth.
+func (s *Server) proxyTCP(conn net.Conn, session *yamux.Session, streams *StreamLimiter, user *models.User) {
+	defer conn.Close()
+
+	if s.overQuota(user) {
+		return
+	}
+	if streams != nil {
+		if err := streams.Acquire(s.ctx); err != nil {
+			return
+		}
+		defer streams.Release()
+	}
+
+	stream, err := session.Open()
+	if err != nil {
+		return
+	}
+	defer stream.Close()
+
+	var total atomic.Int64
+	done := make(chan struct{}, 2)
+	go func() {
+		n, _ := bufpool.Copy(stream, conn)
+		total.Add(n)
+		stream.Close() // Half-close: the client still reads the reply
+		done <- struct{}{}
+	}()
+	go func() {
+		n, _ := bufpool.Copy(conn, stream)
+		total.Add(n)
+		if tcpConn, ok := conn.(*net.TCPConn); ok {
+			tcpConn.CloseWrite()
+		}
+		done <- struct{}{}
+	}()
+	<-done
+	<-done
+
+	if n := total.Load(); n > 0 {
+		s.recordBandwidth(user, n)
+	}
+}
@@ -0,0 +1,236 @@
+package server
+
+import (
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/internal/models"
+)
+
+// freePort returns a port that is free at the time of the call.
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestTCPPortPool(t *testing.T) {
+	port := freePort(t)
+	pool := NewTCPPortPool(port, port, 0)
+
+	ln, got, err := pool.Listen(1)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	if got != port {
+		t.Errorf("Listen() port = %d, want %d", got, port)
+	}
+	if _, _, err := pool.Listen(1); !errors.Is(err, ErrNoTCPPorts) {
+		t.Errorf("Listen() on a full pool = %v, want ErrNoTCPPorts", err)
+	}
+
+	ln.Close()
+	pool.Release(port)
+	ln, _, err = pool.Listen(1)
+	if err != nil {
+		t.Fatalf("Listen() after Release error = %v", err)
+	}
+	ln.Close()
+}
+
+func TestTCPPortPool_PerUser(t *testing.T) {
+	first, second := freePort(t), freePort(t)
+	if second < first {
+		first, second = second, first
+	}
+	pool := NewTCPPortPool(first, second, 1)
+
+	ln, _, err := pool.Listen(1)
+	if err != nil {
+		t.Fatalf("Listen() error = %v", err)
+	}
+	defer ln.Close()
+	if _, _, err := pool.Listen(1); !errors.Is(err, ErrTCPPortLimit) {
+		t.Errorf("Listen() over the user's limit = %v, want ErrTCPPortLimit", err)
+	}
+	other, _, err := pool.Listen(2)
+	if err != nil {
+		t.Fatalf("Listen() for another user error = %v", err)
+	}
+	other.Close()
+}
+
+func TestServer_BindTCPLimit(t *testing.T) {
+	port := freePort(t)
+	s := &Server{TCPPorts: NewTCPPortPool(port, port+1, 1)}
+
+	serverConn, _ := net.Pipe()
+	serverSession, err := yamux.Server(serverConn, nil)
+	if err != nil {
+		t.Fatalf("yamux.Server: %v", err)
+	}
+	defer serverSession.Close()
+
+	user := &models.User{}
+	user.ID = 1
+	if _, err := s.bindTCP(serverSession, nil, user); err != nil {
+		t.Fatalf("bindTCP() error = %v", err)
+	}
+	if _, err := s.bindTCP(serverSession, nil, user); !errors.Is(err, ErrTCPPortLimit) {
+		t.Errorf("second bindTCP() = %v, want ErrTCPPortLimit", err)
+	}
+}
+
+func TestServer_BindTCP(t *testing.T) {
+	port := freePort(t)
+	s := &Server{TCPPorts: NewTCPPortPool(port, port, 0)}
+
+	serverConn, clientConn := net.Pipe()
+	serverSession, err := yamux.Server(serverConn, nil)
+	if err != nil {
+		t.Fatalf("yamux.Server: %v", err)
+	}
+	defer serverSession.Close()
+	clientSession, err := yamux.Client(clientConn, nil)
+	if err != nil {
+		t.Fatalf("yamux.Client: %v", err)
+	}
+	defer clientSession.Close()
+
+	user := &models.User{}
+	user.ID = 1
+	addr, err := s.bindTCP(serverSession, nil, user)
+	if err != nil {
+		t.Fatalf("bindTCP() error = %v", err)
+	}
+	if want := "localhost:" + strconv.Itoa(port); addr != want {
+		t.Errorf("bindTCP() = %q, want %q", addr, want)
+	}
+
+	// The client echoes every stream back, like a local TCP service would
+	go func() {
+		for {
+			stream, err := clientSession.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(stream, stream)
+				stream.Close()
+			}()
+		}
+	}()
+
+	conn, err := net.DialTimeout("tcp", addr, time.Second)
+	if err != nil {
+		t.Fatalf("dial public port: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Write([]byte("PING\r\n")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	buf := make([]byte, 6)
+	if _, err := io.ReadFull(conn, buf); err != nil || string(buf) != "PING\r\n" {
+		t.Fatalf("read = %q, %v", buf, err)
+	}
+
+	// Closing the session frees the port
+	serverSession.Close()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		ln, _, err := s.TCPPorts.Listen(1)
+		if err == nil {
+			ln.Close()
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("port not released after the session closed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// BenchmarkServer_ProxyTCP measures raw TCP tunnel throughput: each
+// operation sends 1MB through the public port to a client that echoes it.
+func BenchmarkServer_ProxyTCP(b *testing.B) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		b.Fatalf("listen: %v", err)
+	}
+	port := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+	s := &Server{TCPPorts: NewTCPPortPool(port, port, 0)}
+
+	serverConn, clientConn := net.Pipe()
+	serverSession, _ := yamux.Server(serverConn, nil)
+	clientSession, _ := yamux.Client(clientConn, nil)
+	defer serverSession.Close()
+	defer clientSession.Close()
+
+	user := &models.User{}
+	user.ID = 1
+	addr, err := s.bindTCP(serverSession, nil, user)
+	if err != nil {
+		b.Fatalf("bindTCP() error = %v", err)
+	}
+	go func() {
+		for {
+			stream, err := clientSession.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(stream, stream)
+				stream.Close()
+			}()
+		}
+	}()
+
+	payload := make([]byte, 1<<20)
+	reply := make([]byte, len(payload))
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		b.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		go conn.Write(payload)
+		if _, err := io.ReadFull(conn, reply); err != nil {
+			b.Fatalf("read: %v", err)
+		}
+	}
+}
+
+func TestQuotaCrossed(t *testing.T) {
+	tests := []struct {
+		name               string
+		limit, used, bytes int64
+		want               bool
+	}{
+		{"unlimited", 0, 500, 500, false},
+		{"below", 1000, 900, 100, false},
+		{"reaches", 1000, 1000, 100, true},
+		{"crosses", 1000, 1200, 300, true},
+		{"already over", 1000, 1500, 100, false},
+	}
+	for _, tt := range tests {
+		if got := quotaCrossed(tt.limit, tt.used, tt.bytes); got != tt.want {
+			t.Errorf("%s: quotaCrossed(%d, %d, %d) = %v, want %v", tt.name, tt.limit, tt.used, tt.bytes, got, tt.want)
+		}
+	}
+}
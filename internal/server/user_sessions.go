@@ -1,7 +1,10 @@
 package server
 
 import (
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/hashicorp/yamux"
 )
@@ -11,67 +14,305 @@ type UserSession struct {
 	UserID  uint
 	Session *yamux.Session
 	Domains []string
+	Labels  map[string]string // Client-provided metadata (e.g. env=staging)
+	Streams *StreamLimiter    // Concurrent ingress streams (nil = unlimited)
+	Device  string            // Fingerprint of the connected device
+
+	Agent       string    // Agent name, "" for the regular session
+	Version     string    // Client version, if it sent one
+	Commands    bool      // The client acts on remote commands
+	Managed     bool      // The client runs tunnels defined in the dashboard
+	ConnectedAt time.Time // Set by Register
+
+	commands    chan string            // Remote commands waiting for the next heartbeat
+	closeReason atomic.Pointer[string] // Why the server closed the session, see setCloseReason
 }
 
-// UserSessionRegistry tracks active sessions per user.
-// Only one active session per user is allowed.
+// AgentInfo describes a connected session for the dashboard fleet view.
+type AgentInfo struct {
+	Agent       string
+	Device      string
+	Version     string
+	Labels      map[string]string
+	Domains     []string
+	ConnectedAt time.Time
+	Commands    bool
+	Managed     bool
+}
+
+// pendingCommands is how many remote commands a session queues until its
+// next heartbeat; more are dropped.
+const pendingCommands = 4
+
+// UserSessionRegistry tracks active sessions per user. A user has at most
+// one regular session and one session per agent name.
 type UserSessionRegistry struct {
 	mu       sync.RWMutex
-	sessions map[uint]*UserSession // userID -> session
+	sessions map[uint][]*UserSession // userID -> sessions, oldest first
 }
 
 // NewUserSessionRegistry creates a new registry.
 func NewUserSessionRegistry() *UserSessionRegistry {
 	return &UserSessionRegistry{
-		sessions: make(map[uint]*UserSession),
+		sessions: make(map[uint][]*UserSession),
+	}
+}
+
+// GetSession returns the user's active session for the agent name ("" for
+// the regular session), if any.
+func (r *UserSessionRegistry) GetSession(userID uint, agent string) (*UserSession, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, sess := range r.sessions[userID] {
+		if sess.Agent == agent {
+			return sess, true
+		}
 	}
+	return nil, false
 }
 
-// GetSession returns the active session for a user, if any.
-func (r *UserSessionRegistry) GetSession(userID uint) (*UserSession, bool) {
+// Sessions returns all active sessions of a user, oldest first.
+func (r *UserSessionRegistry) Sessions(userID uint) []*UserSession {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	sess, ok := r.sessions[userID]
-	return sess, ok
+	return slices.Clone(r.sessions[userID])
 }
 
 // IsConnected checks if a user has an active session.
 func (r *UserSessionRegistry) IsConnected(userID uint) bool {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	_, ok := r.sessions[userID]
-	return ok
+	return len(r.sessions[userID]) > 0
 }
 
-// GetActiveDomains returns the list of active domains for a user.
+// GetActiveDomains returns the active domains of all of a user's sessions.
 // Returns nil if the user has no active session.
 func (r *UserSessionRegistry) GetActiveDomains(userID uint) []string {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	if sess, ok := r.sessions[userID]; ok {
-		return sess.Domains
+	sessions := r.sessions[userID]
+	if len(sessions) == 1 {
+		return sessions[0].Domains
+	}
+	var domains []string
+	for _, sess := range sessions {
+		domains = append(domains, sess.Domains...)
+	}
+	return domains
+}
+
+// GetLabels returns the labels of a user's oldest active session.
+// Returns nil if the user has no active session.
+func (r *UserSessionRegistry) GetLabels(userID uint) map[string]string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if sessions := r.sessions[userID]; len(sessions) > 0 {
+		return sessions[0].Labels
 	}
 	return nil
 }
 
-// Register registers a new session for a user.
-// Returns the old session if one existed (caller should close it).
-func (r *UserSessionRegistry) Register(userID uint, session *yamux.Session, domains []string) *UserSession {
+// GetDevices returns the device fingerprints of a user's active sessions.
+func (r *UserSessionRegistry) GetDevices(userID uint) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var devices []string
+	for _, sess := range r.sessions[userID] {
+		devices = append(devices, sess.Device)
+	}
+	return devices
+}
+
+// Agents describes a user's active sessions, oldest first.
+func (r *UserSessionRegistry) Agents(userID uint) []AgentInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]AgentInfo, 0, len(r.sessions[userID]))
+	for _, sess := range r.sessions[userID] {
+		infos = append(infos, AgentInfo{
+			Agent:       sess.Agent,
+			Device:      sess.Device,
+			Version:     sess.Version,
+			Labels:      sess.Labels,
+			Domains:     sess.Domains,
+			ConnectedAt: sess.ConnectedAt,
+			Commands:    sess.Commands,
+			Managed:     sess.Managed,
+		})
+	}
+	return infos
+}
+
+// AgentCount returns the number of a user's active agent sessions, not
+// counting the regular one.
+func (r *UserSessionRegistry) AgentCount(userID uint) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, sess := range r.sessions[userID] {
+		if sess.Agent != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// SessionDomains returns the domains currently bound to the session.
+func (r *UserSessionRegistry) SessionDomains(sess *UserSession) []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sess.Domains
+}
+
+// Register registers a new session for its user and agent name.
+// Returns the session it replaces if one existed (caller should close it).
+func (r *UserSessionRegistry) Register(sess *UserSession) *UserSession {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	old := r.sessions[userID]
-	r.sessions[userID] = &UserSession{
-		UserID:  userID,
-		Session: session,
-		Domains: domains,
+	if sess.ConnectedAt.IsZero() {
+		sess.ConnectedAt = time.Now()
+	}
+	sess.commands = make(chan string, pendingCommands)
+
+	var old *UserSession
+	sessions := make([]*UserSession, 0, len(r.sessions[sess.UserID])+1)
+	for _, s := range r.sessions[sess.UserID] {
+		if s.Agent == sess.Agent {
+			old = s
+			continue
+		}
+		sessions = append(sessions, s)
 	}
+	r.sessions[sess.UserID] = append(sessions, sess)
 	return old
 }
 
-// Unregister removes a user's session.
-func (r *UserSessionRegistry) Unregister(userID uint) {
+// Unregister removes a user's session, if it is still registered.
+func (r *UserSessionRegistry) Unregister(userID uint, session *yamux.Session) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
-	delete(r.sessions, userID)
+	r.removeLocked(userID, func(s *UserSession) bool { return s.Session == session })
+}
+
+// removeLocked removes the user's sessions matching drop and returns how
+// many were removed.
+func (r *UserSessionRegistry) removeLocked(userID uint, drop func(*UserSession) bool) int {
+	sessions := r.sessions[userID]
+	kept := make([]*UserSession, 0, len(sessions))
+	for _, s := range sessions {
+		if !drop(s) {
+			kept = append(kept, s)
+		}
+	}
+	if len(kept) == 0 {
+		delete(r.sessions, userID)
+	} else {
+		r.sessions[userID] = kept
+	}
+	return len(sessions) - len(kept)
+}
+
+// Count returns the number of active sessions.
+func (r *UserSessionRegistry) Count() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	n := 0
+	for _, sessions := range r.sessions {
+		n += len(sessions)
+	}
+	return n
+}
+
+// Reap removes sessions that have already been closed.
+// Returns the number of removed sessions.
+func (r *UserSessionRegistry) Reap() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	removed := 0
+	for userID := range r.sessions {
+		removed += r.removeLocked(userID, func(s *UserSession) bool {
+			return s.Session != nil && s.Session.IsClosed()
+		})
+	}
+	return removed
+}
+
+// AddDomains adds domains bound after the handshake to the user's given
+// session, if it is still registered.
+func (r *UserSessionRegistry) AddDomains(userID uint, session *yamux.Session, domains []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sess := range r.sessions[userID] {
+		if sess.Session != session {
+			continue
+		}
+		// Copy, as readers hold the slice without the lock
+		merged := make([]string, 0, len(sess.Domains)+len(domains))
+		merged = append(merged, sess.Domains...)
+		for _, d := range domains {
+			if !slices.Contains(merged, d) {
+				merged = append(merged, d)
+			}
+		}
+		sess.Domains = merged
+	}
+}
+
+// RemoveDomain removes a domain from the user's active sessions, if any.
+func (r *UserSessionRegistry) RemoveDomain(userID uint, domain string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, sess := range r.sessions[userID] {
+		domains := make([]string, 0, len(sess.Domains))
+		for _, d := range sess.Domains {
+			if d != domain {
+				domains = append(domains, d)
+			}
+		}
+		sess.Domains = domains
+	}
+}
+
+// Command queues a remote command for the user's session of the agent
+// name, delivered with the next heartbeat echo. It reports whether such a
+// session that acts on commands exists here and had room for it.
+func (r *UserSessionRegistry) Command(userID uint, agent, command string) bool {
+	sess, ok := r.GetSession(userID, agent)
+	if !ok || !sess.Commands {
+		return false
+	}
+	select {
+	case sess.commands <- command:
+		return true
+	default:
+		return false
+	}
+}
+
+// nextCommand returns the session's next queued remote command, or "".
+func (sess *UserSession) nextCommand() string {
+	if sess == nil || sess.commands == nil {
+		return ""
+	}
+	select {
+	case command := <-sess.commands:
+		return command
+	default:
+		return ""
+	}
+}
+
+// setCloseReason records why the server is about to close the session,
+// for its disconnect events.
+func (sess *UserSession) setCloseReason(reason string) {
+	sess.closeReason.Store(&reason)
+}
+
+// forcedReason returns the reason recorded by setCloseReason, or "".
+func (sess *UserSession) forcedReason() string {
+	if reason := sess.closeReason.Load(); reason != nil {
+		return *reason
+	}
+	return ""
 }
@@ -0,0 +1,95 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/pkg/protocol"
+)
+
+func TestUserSessionRegistry_Agents(t *testing.T) {
+	r := NewUserSessionRegistry()
+	r.Register(&UserSession{UserID: 1, Domains: []string{"a"}})
+	r.Register(&UserSession{UserID: 1, Agent: "ci", Domains: []string{"b"}, Commands: true})
+	r.Register(&UserSession{UserID: 1, Agent: "nas", Domains: []string{"c"}})
+
+	if got := r.Count(); got != 3 {
+		t.Errorf("Count = %d, want 3", got)
+	}
+	if got := r.AgentCount(1); got != 2 {
+		t.Errorf("AgentCount = %d, want 2", got)
+	}
+	if got := r.GetActiveDomains(1); len(got) != 3 {
+		t.Errorf("GetActiveDomains = %v, want the domains of all sessions", got)
+	}
+	agents := r.Agents(1)
+	if len(agents) != 3 || agents[1].Agent != "ci" || agents[1].ConnectedAt.IsZero() {
+		t.Errorf("Agents = %+v", agents)
+	}
+
+	// A session of the same agent name replaces the old one
+	old := r.Register(&UserSession{UserID: 1, Agent: "ci"})
+	if old == nil || old.Domains[0] != "b" {
+		t.Errorf("Register returned %+v, want the replaced session", old)
+	}
+	if got := r.Count(); got != 3 {
+		t.Errorf("Count after replacing = %d, want 3", got)
+	}
+}
+
+func TestUserSessionRegistry_Command(t *testing.T) {
+	r := NewUserSessionRegistry()
+	ci := &UserSession{UserID: 1, Agent: "ci", Commands: true}
+	r.Register(ci)
+	r.Register(&UserSession{UserID: 1, Agent: "old"})
+
+	if r.Command(1, "old", protocol.CommandReload) {
+		t.Error("Command queued for a client that does not act on commands")
+	}
+	if r.Command(1, "missing", protocol.CommandReload) {
+		t.Error("Command queued for an agent that is not connected")
+	}
+	if !r.Command(1, "ci", protocol.CommandDisconnect) {
+		t.Fatal("Command not queued")
+	}
+	if got := ci.nextCommand(); got != protocol.CommandDisconnect {
+		t.Errorf("nextCommand = %q, want %q", got, protocol.CommandDisconnect)
+	}
+	if got := ci.nextCommand(); got != "" {
+		t.Errorf("nextCommand after delivery = %q, want empty", got)
+	}
+}
+
+func TestUserSessionRegistry_UnregisterKeepsOthers(t *testing.T) {
+	regular, agent := testSession(t), testSession(t)
+	r := NewUserSessionRegistry()
+	r.Register(&UserSession{UserID: 1, Session: regular})
+	r.Register(&UserSession{UserID: 1, Session: agent, Agent: "ci"})
+
+	r.Unregister(1, regular)
+	if _, ok := r.GetSession(1, "ci"); !ok {
+		t.Error("Unregister removed the agent session too")
+	}
+	if _, ok := r.GetSession(1, ""); ok {
+		t.Error("regular session still registered")
+	}
+	r.Unregister(1, agent)
+	if r.IsConnected(1) {
+		t.Error("IsConnected after unregistering all sessions")
+	}
+}
+
+// testSession returns a yamux session over an in-memory pipe.
+func testSession(t *testing.T) *yamux.Session {
+	t.Helper()
+	conn, peer := net.Pipe()
+	t.Cleanup(func() { peer.Close() })
+	session, err := yamux.Client(conn, nil)
+	if err != nil {
+		t.Fatalf("yamux.Client: %v", err)
+	}
+	t.Cleanup(func() { session.Close() })
+	return session
+}
@@ -1,12 +1,16 @@
 package storage
 
 import (
+	"database/sql"
 	"errors"
+	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
 
 	"gopublic/internal/auth"
 	apperrors "gopublic/internal/errors"
@@ -19,41 +23,102 @@ var (
 	ErrNotFound     = apperrors.ErrNotFound
 	ErrDBError      = apperrors.ErrInternal
 	ErrDuplicateKey = apperrors.ErrDuplicateKey
+
+	ErrInviteInvalid = apperrors.New(apperrors.CodeInvalidInput, "invite code is invalid, expired or used up")
+
+	ErrDomainTaken      = apperrors.New(apperrors.CodeDuplicateKey, "domain is already taken")
+	ErrReservationLimit = apperrors.New(apperrors.CodeForbidden, "reserved domain limit reached")
+	ErrTransferPending  = apperrors.New(apperrors.CodeDuplicateKey, "domain already has a pending transfer")
+	ErrAlreadyMember    = apperrors.New(apperrors.CodeDuplicateKey, "user is already a member of the organization")
+	ErrUserPending      = apperrors.New(apperrors.CodeForbidden, "account is pending approval")
+	ErrTokenExpired     = apperrors.New(apperrors.CodeSessionExpired, "token has expired")
 )
 
 // DB is the global database instance.
 // Deprecated: Use SQLiteStore via dependency injection instead.
 var DB *gorm.DB
 
+// reportingResolver names the dbresolver that serves reporting queries from
+// the read-only replica, if one is configured.
+const reportingResolver = "reporting"
+
 // SQLiteStore implements the Store interface using SQLite/GORM
 type SQLiteStore struct {
-	db *gorm.DB
+	db      *gorm.DB
+	replica *sql.DB // Optional read-only replica, closed with the store
+}
+
+// PoolConfig holds database connection pool settings.
+type PoolConfig struct {
+	MaxOpenConns    int           // Maximum open connections (0 = unlimited)
+	MaxIdleConns    int           // Maximum idle connections
+	ConnMaxLifetime time.Duration // Maximum connection reuse time (0 = unlimited)
+	ReplicaPath     string        // Optional read-only replica database path
+}
+
+// DefaultPoolConfig returns the default pool settings.
+func DefaultPoolConfig() PoolConfig {
+	return PoolConfig{
+		MaxOpenConns: 100,
+		MaxIdleConns: 10,
+	}
 }
 
-// NewSQLiteStore creates a new SQLite store
+// NewSQLiteStore creates a new SQLite store with the default pool settings
 func NewSQLiteStore(path string) (*SQLiteStore, error) {
+	return NewSQLiteStoreWithConfig(path, DefaultPoolConfig())
+}
+
+// NewSQLiteStoreWithConfig creates a new SQLite store with the given pool settings.
+// If pool.ReplicaPath is set, read-only reporting queries (usage statistics,
+// dashboard lists) are served from the replica.
+func NewSQLiteStoreWithConfig(path string, pool PoolConfig) (*SQLiteStore, error) {
 	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
+	store := &SQLiteStore{db: db}
+	if err := store.init(pool); err != nil {
+		store.Close()
+		return nil, err
+	}
+	return store, nil
+}
+
+// init configures the connection pool, migrates the schema and attaches the
+// replica of a newly opened store.
+func (s *SQLiteStore) init(pool PoolConfig) error {
+	db := s.db
 
 	// Configure connection pool
-	sqlDB, err := db.DB()
-	if err != nil {
-		return nil, err
+	if err := configurePool(db, pool); err != nil {
+		return err
 	}
-	sqlDB.SetMaxIdleConns(10)
-	sqlDB.SetMaxOpenConns(100)
 
 	// Auto Migrate
 	if err := db.AutoMigrate(
 		&models.User{},
 		&models.Token{},
 		&models.Domain{},
+		&models.DomainTransfer{},
+		&models.Organization{},
+		&models.OrganizationMember{},
 		&models.AbuseReport{},
+		&models.Invite{},
+		&models.Device{},
+		&models.JoinToken{},
+		&models.LoginToken{},
+		&models.APIToken{},
+		&models.IdempotencyKey{},
+		&models.AlertRule{},
+		&models.TunnelDefinition{},
+		&models.DomainUptime{},
+		&models.ConnectionEvent{},
 		&models.UserBandwidth{},
+		&models.UserGeoUsage{},
+		&models.JobLock{},
 	); err != nil {
-		return nil, err
+		return err
 	}
 
 	// Data migration: convert zero values to NULL for optional OAuth IDs
@@ -61,11 +126,51 @@ func NewSQLiteStore(path string) (*SQLiteStore, error) {
 	db.Exec("UPDATE users SET telegram_id = NULL WHERE telegram_id = 0")
 	db.Exec("UPDATE users SET yandex_id = NULL WHERE yandex_id = ''")
 
-	return &SQLiteStore{db: db}, nil
+	if pool.ReplicaPath == "" {
+		return nil
+	}
+
+	// Route reporting reads to the replica. The resolver lives on the
+	// *gorm.DB, so stores sharing it (including the global DB) use it too.
+	replica, err := sql.Open(sqlite.DriverName, "file:"+pool.ReplicaPath+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	s.replica = replica
+	configureSQLPool(replica, pool)
+	return db.Use(dbresolver.Register(dbresolver.Config{
+		Replicas: []gorm.Dialector{sqlite.New(sqlite.Config{Conn: replica})},
+	}, reportingResolver))
+}
+
+// configurePool applies pool settings to the underlying sql.DB.
+func configurePool(db *gorm.DB, pool PoolConfig) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	configureSQLPool(sqlDB, pool)
+	return nil
+}
+
+// configureSQLPool applies pool settings to sqlDB.
+func configureSQLPool(sqlDB *sql.DB, pool PoolConfig) {
+	sqlDB.SetMaxIdleConns(pool.MaxIdleConns)
+	sqlDB.SetMaxOpenConns(pool.MaxOpenConns)
+	sqlDB.SetConnMaxLifetime(pool.ConnMaxLifetime)
+}
+
+// reader returns the database used for read-only reporting queries: the
+// replica if one is configured, the primary otherwise.
+func (s *SQLiteStore) reader() *gorm.DB {
+	return s.db.Clauses(dbresolver.Use(reportingResolver))
 }
 
 // Close closes the database connection
 func (s *SQLiteStore) Close() error {
+	if s.replica != nil {
+		s.replica.Close()
+	}
 	sqlDB, err := s.db.DB()
 	if err != nil {
 		return err
@@ -112,6 +217,47 @@ func (s *SQLiteStore) UpdateUser(user *models.User) error {
 	return s.db.Save(user).Error
 }
 
+// GetUserByStripeCustomer finds the user that owns a Stripe customer.
+func (s *SQLiteStore) GetUserByStripeCustomer(customerID string) (*models.User, error) {
+	var user models.User
+	result := s.db.Where("stripe_customer_id = ?", customerID).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// SetUserRole sets the dashboard role of a user.
+func (s *SQLiteStore) SetUserRole(userID uint, role string) error {
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UpdateUserBilling sets the user's plan and Stripe identifiers.
+func (s *SQLiteStore) UpdateUserBilling(userID uint, plan, customerID, subscriptionID string) error {
+	result := s.db.Model(&models.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+		"plan":                   plan,
+		"stripe_customer_id":     customerID,
+		"stripe_subscription_id": subscriptionID,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
 func (s *SQLiteStore) GetUserByYandexID(yandexID string) (*models.User, error) {
 	var user models.User
 	result := s.db.Where("yandex_id = ?", yandexID).First(&user)
@@ -129,10 +275,245 @@ func (s *SQLiteStore) AcceptTerms(userID uint) error {
 	return s.db.Model(&models.User{}).Where("id = ?", userID).Update("terms_accepted_at", now).Error
 }
 
+// SetQuotaAlerts turns the user's quota usage notifications on or off.
+func (s *SQLiteStore) SetQuotaAlerts(userID uint, enabled bool) error {
+	return s.db.Model(&models.User{}).Where("id = ?", userID).Update("quota_alerts_disabled", !enabled).Error
+}
+
+// SetTOTP stores the user's authenticator secret.
+func (s *SQLiteStore) SetTOTP(userID uint, secret string, enabled bool) error {
+	return s.db.Model(&models.User{}).Where("id = ?", userID).
+		Updates(map[string]interface{}{"totp_secret": secret, "totp_enabled": enabled}).Error
+}
+
+// DeleteUser deletes the user's account and tokens, API tokens included.
+// The user's domains are
+// freed by RecycleOrphanedDomains.
+func (s *SQLiteStore) DeleteUser(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userID).Delete(&models.Token{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.APIToken{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.OrganizationMember{}).Error; err != nil {
+			return err
+		}
+		result := tx.Delete(&models.User{}, userID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// RecordDevice records a connection from the device and returns its record,
+// creating it on the first connection. Revoking a device rotates the
+// user's token, so a connection with the current token clears the mark.
+func (s *SQLiteStore) RecordDevice(userID uint, fingerprint, name, ip string) (*models.Device, error) {
+	now := time.Now()
+	result := s.db.Exec(`
+		INSERT INTO devices (user_id, fingerprint, name, ip, last_seen_at, connections, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 1, ?, ?)
+		ON CONFLICT(user_id, fingerprint) DO UPDATE SET
+			last_seen_at = excluded.last_seen_at,
+			connections = connections + 1,
+			revoked_at = NULL,
+			updated_at = excluded.updated_at
+	`, userID, fingerprint, name, ip, now, now, now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	var device models.Device
+	if err := s.db.Where("user_id = ? AND fingerprint = ?", userID, fingerprint).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// GetUserDevices returns the user's devices seen since the given time,
+// most recent first. Revoked devices are always included.
+func (s *SQLiteStore) GetUserDevices(userID uint, since time.Time) ([]models.Device, error) {
+	var devices []models.Device
+	result := s.reader().
+		Where("user_id = ? AND (last_seen_at >= ? OR revoked_at IS NOT NULL)", userID, since).
+		Order("last_seen_at DESC").
+		Find(&devices)
+	return devices, result.Error
+}
+
+// RevokeDevice marks one of the user's devices as revoked and returns its record.
+func (s *SQLiteStore) RevokeDevice(userID, deviceID uint) (*models.Device, error) {
+	now := time.Now()
+	result := s.db.Model(&models.Device{}).
+		Where("id = ? AND user_id = ?", deviceID, userID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+
+	var device models.Device
+	if err := s.db.First(&device, deviceID).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+// CreateAlertRule stores a new alert rule.
+func (s *SQLiteStore) CreateAlertRule(rule *models.AlertRule) error {
+	return s.db.Create(rule).Error
+}
+
+// GetUserAlertRules returns the user's alert rules, oldest first.
+func (s *SQLiteStore) GetUserAlertRules(userID uint) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	result := s.db.Where("user_id = ?", userID).Order("id").Find(&rules)
+	return rules, result.Error
+}
+
+// CreateTunnelDefinition stores a new tunnel definition.
+func (s *SQLiteStore) CreateTunnelDefinition(def *models.TunnelDefinition) error {
+	return s.db.Create(def).Error
+}
+
+// GetUserTunnelDefinitions returns the user's tunnel definitions, oldest first.
+func (s *SQLiteStore) GetUserTunnelDefinitions(userID uint) ([]models.TunnelDefinition, error) {
+	var defs []models.TunnelDefinition
+	result := s.db.Where("user_id = ?", userID).Order("id").Find(&defs)
+	return defs, result.Error
+}
+
+// DeleteTunnelDefinition deletes one of the user's tunnel definitions and
+// returns it.
+func (s *SQLiteStore) DeleteTunnelDefinition(userID, defID uint) (*models.TunnelDefinition, error) {
+	var def models.TunnelDefinition
+	if err := s.db.Where("id = ? AND user_id = ?", defID, userID).First(&def).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+	if err := s.db.Unscoped().Delete(&def).Error; err != nil {
+		return nil, err
+	}
+	return &def, nil
+}
+
+// DeleteAlertRule deletes one of the user's alert rules.
+func (s *SQLiteStore) DeleteAlertRule(userID, ruleID uint) error {
+	result := s.db.Unscoped().Where("id = ? AND user_id = ?", ruleID, userID).Delete(&models.AlertRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// MarkAlertDomainsOnline records that the given tunnels were online at the given time.
+func (s *SQLiteStore) MarkAlertDomainsOnline(domains []string, at time.Time) error {
+	if len(domains) == 0 {
+		return nil
+	}
+	return s.db.Model(&models.AlertRule{}).Where("domain IN ?", domains).Update("last_online_at", at).Error
+}
+
+// GetOfflineAlertRules returns armed rules whose tunnel was last seen
+// online before seenBefore. Callers apply each rule's own threshold.
+func (s *SQLiteStore) GetOfflineAlertRules(seenBefore time.Time) ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	result := s.db.Preload("User").
+		Where("fired_at IS NULL AND last_online_at IS NOT NULL AND last_online_at < ?", seenBefore).
+		Find(&rules)
+	return rules, result.Error
+}
+
+// GetRecoveredAlertRules returns fired rules whose tunnel has come back online.
+func (s *SQLiteStore) GetRecoveredAlertRules() ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	result := s.db.Preload("User").
+		Where("fired_at IS NOT NULL AND last_online_at > fired_at").
+		Find(&rules)
+	return rules, result.Error
+}
+
+// SetAlertFired marks an alert rule as fired, or re-arms it if firedAt is nil.
+func (s *SQLiteStore) SetAlertFired(ruleID uint, firedAt *time.Time) error {
+	return s.db.Model(&models.AlertRule{}).Where("id = ?", ruleID).Update("fired_at", firedAt).Error
+}
+
 func (s *SQLiteStore) LinkYandexAccount(userID uint, yandexID string) error {
 	return s.db.Model(&models.User{}).Where("id = ?", userID).Update("yandex_id", yandexID).Error
 }
 
+// oauthColumns maps social login providers to the users column of their
+// user IDs.
+var oauthColumns = map[string]string{
+	models.ProviderGitHub: "github_id",
+	models.ProviderGoogle: "google_id",
+}
+
+// GetUserByOAuthID gets the user linked to an account of a social login
+// provider (models.Provider*).
+func (s *SQLiteStore) GetUserByOAuthID(provider, id string) (*models.User, error) {
+	column, ok := oauthColumns[provider]
+	if !ok {
+		return nil, fmt.Errorf("unknown OAuth provider %q", provider)
+	}
+	var user models.User
+	result := s.db.Where(map[string]any{column: id}).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// LinkOAuthAccount links an account of a social login provider to a user.
+func (s *SQLiteStore) LinkOAuthAccount(userID uint, provider, id string) error {
+	column, ok := oauthColumns[provider]
+	if !ok {
+		return fmt.Errorf("unknown OAuth provider %q", provider)
+	}
+	return s.db.Model(&models.User{}).Where("id = ?", userID).Update(column, id).Error
+}
+
+// GetUserByEmail gets the oldest user with the email address, ignoring case.
+func (s *SQLiteStore) GetUserByEmail(email string) (*models.User, error) {
+	var user models.User
+	result := s.db.Where("LOWER(email) = ?", strings.ToLower(email)).Order("id").First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+func (s *SQLiteStore) GetUserByOIDCSubject(subject string) (*models.User, error) {
+	var user models.User
+	result := s.db.Where("oidc_subject = ?", subject).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
 func (s *SQLiteStore) LinkTelegramAccount(userID uint, telegramID int64) error {
 	return s.db.Model(&models.User{}).Where("id = ?", userID).Update("telegram_id", telegramID).Error
 }
@@ -145,24 +526,26 @@ func (s *SQLiteStore) ValidateToken(tokenStr string) (*models.User, error) {
 	// First try new hash-based lookup
 	tokenHash := auth.HashToken(tokenStr)
 	result := s.db.Preload("User").Where("token_hash = ?", tokenHash).First(&token)
-	if result.Error == nil {
-		return &token.User, nil
+	if result.Error != nil {
+		// Fallback to legacy plaintext lookup for backward compatibility
+		result = s.db.Preload("User").Where("token_string = ?", tokenStr).First(&token)
 	}
-
-	// Fallback to legacy plaintext lookup for backward compatibility
-	result = s.db.Preload("User").Where("token_string = ?", tokenStr).First(&token)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
 		}
 		return nil, result.Error
 	}
+	if token.ExpiresAt != nil && time.Now().After(*token.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
 	return &token.User, nil
 }
 
+// GetUserToken returns the user's own token, not those of agents.
 func (s *SQLiteStore) GetUserToken(userID uint) (*models.Token, error) {
 	var token models.Token
-	result := s.db.Where("user_id = ?", userID).First(&token)
+	result := s.db.Where("user_id = ? AND agent = ''", userID).First(&token)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, ErrNotFound
@@ -176,8 +559,9 @@ func (s *SQLiteStore) CreateToken(token *models.Token) error {
 	return s.db.Create(token).Error
 }
 
-// RegenerateToken creates a new token for the user, replacing the old one.
-// Returns the new token string (shown only once to user).
+// RegenerateToken creates a new token for the user, replacing the old one
+// and the tokens of the user's agents. Returns the new token string (shown
+// only once to user).
 func (s *SQLiteStore) RegenerateToken(userID uint) (string, error) {
 	var tokenString string
 
@@ -210,331 +594,1965 @@ func (s *SQLiteStore) RegenerateToken(userID uint) (string, error) {
 	return tokenString, nil
 }
 
-// --- Domain Operations ---
-
-func (s *SQLiteStore) GetUserDomains(userID uint) ([]models.Domain, error) {
-	var domains []models.Domain
-	if err := s.db.Where("user_id = ?", userID).Find(&domains).Error; err != nil {
-		return nil, err
+// SetTokenExpiry makes the user's token expire after ttl and issues a
+// refresh token for it, replacing any previous one. Returns the refresh
+// token (shown only once to the user) and the expiry.
+func (s *SQLiteStore) SetTokenExpiry(userID uint, ttl time.Duration) (string, time.Time, error) {
+	refresh, err := auth.GenerateSecureToken()
+	if err != nil {
+		return "", time.Time{}, err
 	}
-	return domains, nil
+	expiresAt := time.Now().Add(ttl)
+	result := s.db.Model(&models.Token{}).Where("user_id = ? AND agent = ''", userID).Updates(map[string]interface{}{
+		"expires_at":   expiresAt,
+		"refresh_hash": auth.HashToken(refresh),
+	})
+	if result.Error != nil {
+		return "", time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", time.Time{}, ErrNotFound
+	}
+	return refresh, expiresAt, nil
 }
 
-func (s *SQLiteStore) ValidateDomainOwnership(domainName string, userID uint) (bool, error) {
-	var domain models.Domain
-	result := s.db.Where("name = ? AND user_id = ?", domainName, userID).First(&domain)
-	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return false, nil
+// RefreshToken exchanges a refresh token for a new token expiring after
+// ttl and a new refresh token; both old ones stop working. With ttl 0 the
+// new token never expires and needs no refresh token. Returns the new
+// token, refresh token and expiry, or ErrNotFound for an unknown refresh
+// token.
+func (s *SQLiteStore) RefreshToken(refresh string, ttl time.Duration) (string, string, time.Time, error) {
+	if refresh == "" {
+		return "", "", time.Time{}, ErrNotFound
+	}
+	tokenString, err := auth.GenerateSecureToken()
+	if err != nil {
+		return "", "", time.Time{}, err
+	}
+	updates := map[string]interface{}{
+		"token_string": tokenString,
+		"token_hash":   auth.HashToken(tokenString),
+		"expires_at":   nil,
+		"refresh_hash": "",
+	}
+	var newRefresh string
+	var expiresAt time.Time
+	if ttl > 0 {
+		if newRefresh, err = auth.GenerateSecureToken(); err != nil {
+			return "", "", time.Time{}, err
 		}
-		return false, result.Error
+		expiresAt = time.Now().Add(ttl)
+		updates["expires_at"] = expiresAt
+		updates["refresh_hash"] = auth.HashToken(newRefresh)
 	}
-	return true, nil
-}
 
-func (s *SQLiteStore) CreateDomain(domain *models.Domain) error {
-	return s.db.Create(domain).Error
+	result := s.db.Model(&models.Token{}).Where("refresh_hash = ?", auth.HashToken(refresh)).Updates(updates)
+	if result.Error != nil {
+		return "", "", time.Time{}, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return "", "", time.Time{}, ErrNotFound
+	}
+	return tokenString, newRefresh, expiresAt, nil
 }
 
-// --- Abuse Report Operations ---
-
-func (s *SQLiteStore) CreateAbuseReport(report *models.AbuseReport) error {
-	return s.db.Create(report).Error
+// CreateJoinToken creates a join token of the user that expires after ttl.
+// Returns the join token (shown only once to the user) and its record.
+func (s *SQLiteStore) CreateJoinToken(userID uint, ttl time.Duration) (string, *models.JoinToken, error) {
+	join, err := auth.GenerateSecureToken()
+	if err != nil {
+		return "", nil, err
+	}
+	record := &models.JoinToken{
+		UserID:    userID,
+		TokenHash: auth.HashToken(join),
+		ExpiresAt: time.Now().Add(ttl),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return "", nil, err
+	}
+	return join, record, nil
 }
 
-func (s *SQLiteStore) GetAbuseReports(status string) ([]models.AbuseReport, error) {
-	var reports []models.AbuseReport
-	query := s.db
-	if status != "" {
-		query = query.Where("status = ?", status)
+// CreateLoginToken issues a sign-in link token for an email address valid
+// for ttl. Only its hash is stored; expired tokens are deleted on the way.
+func (s *SQLiteStore) CreateLoginToken(email string, ttl time.Duration) (string, error) {
+	token, err := auth.GenerateSecureToken()
+	if err != nil {
+		return "", err
 	}
-	if err := query.Order("created_at DESC").Find(&reports).Error; err != nil {
-		return nil, err
+	now := time.Now()
+	if err := s.db.Where("expires_at <= ?", now).Delete(&models.LoginToken{}).Error; err != nil {
+		return "", err
 	}
-	return reports, nil
+	record := &models.LoginToken{
+		Email:     email,
+		TokenHash: auth.HashToken(token),
+		ExpiresAt: now.Add(ttl),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return "", err
+	}
+	return token, nil
 }
 
-// --- Bandwidth Operations ---
-
-func (s *SQLiteStore) GetUserBandwidthToday(userID uint) (int64, error) {
-	today := time.Now().Truncate(24 * time.Hour)
-	var bandwidth models.UserBandwidth
-	result := s.db.Where("user_id = ? AND date = ?", userID, today).First(&bandwidth)
+// RedeemLoginToken uses up a sign-in link token and returns the email
+// address it was sent to. Unknown, expired and used tokens are ErrNotFound.
+func (s *SQLiteStore) RedeemLoginToken(token string) (string, error) {
+	if token == "" {
+		return "", ErrNotFound
+	}
+	var record models.LoginToken
+	now := time.Now()
+	result := s.db.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", auth.HashToken(token), now).First(&record)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return "", ErrNotFound
+	} else if result.Error != nil {
+		return "", result.Error
+	}
+	// Conditional, so that a link opened twice at once signs in once
+	result = s.db.Model(&models.LoginToken{}).Where("id = ? AND used_at IS NULL", record.ID).Update("used_at", now)
 	if result.Error != nil {
-		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return 0, nil // No usage today
-		}
-		return 0, result.Error
+		return "", result.Error
 	}
-	return bandwidth.BytesUsed, nil
+	if result.RowsAffected == 0 {
+		return "", ErrNotFound
+	}
+	return record.Email, nil
 }
 
-func (s *SQLiteStore) AddUserBandwidth(userID uint, bytes int64) error {
-	today := time.Now().Truncate(24 * time.Hour)
-
-	// Use upsert: insert or update if exists
-	result := s.db.Exec(`
-		INSERT INTO user_bandwidths (user_id, date, bytes_used, created_at, updated_at)
-		VALUES (?, ?, ?, datetime('now'), datetime('now'))
-		ON CONFLICT(user_id, date) DO UPDATE SET
-			bytes_used = bytes_used + excluded.bytes_used,
-			updated_at = datetime('now')
-	`, userID, today, bytes)
+// GetUserJoinTokens returns the user's join tokens created since, newest first.
+func (s *SQLiteStore) GetUserJoinTokens(userID uint, since time.Time) ([]models.JoinToken, error) {
+	var tokens []models.JoinToken
+	result := s.db.Where("user_id = ? AND created_at > ?", userID, since).Order("id DESC").Find(&tokens)
+	return tokens, result.Error
+}
 
-	return result.Error
+// DeleteJoinToken revokes one of the user's join tokens.
+func (s *SQLiteStore) DeleteJoinToken(userID, joinID uint) error {
+	result := s.db.Unscoped().Where("id = ? AND user_id = ?", joinID, userID).Delete(&models.JoinToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-// GetUserTotalBandwidth returns total bandwidth used by user across all days
-func (s *SQLiteStore) GetUserTotalBandwidth(userID uint) (int64, error) {
-	var total int64
-	result := s.db.Model(&models.UserBandwidth{}).
-		Where("user_id = ?", userID).
-		Select("COALESCE(SUM(bytes_used), 0)").
-		Scan(&total)
-	return total, result.Error
+// CreateAPIToken creates an API token of the user for the public API.
+// Returns the token (shown only once to the user) and its record.
+func (s *SQLiteStore) CreateAPIToken(userID uint, name string) (string, *models.APIToken, error) {
+	tokenString, err := auth.GenerateSecureToken()
+	if err != nil {
+		return "", nil, err
+	}
+	record := &models.APIToken{
+		UserID:    userID,
+		Name:      name,
+		TokenHash: auth.HashToken(tokenString),
+	}
+	if err := s.db.Create(record).Error; err != nil {
+		return "", nil, err
+	}
+	return tokenString, record, nil
 }
 
-// --- Statistics Operations ---
+// GetUserAPITokens returns the user's API tokens, newest first.
+func (s *SQLiteStore) GetUserAPITokens(userID uint) ([]models.APIToken, error) {
+	var tokens []models.APIToken
+	result := s.db.Where("user_id = ?", userID).Order("id DESC").Find(&tokens)
+	return tokens, result.Error
+}
 
-// UserStats holds user information with bandwidth statistics
-type UserStats struct {
-	UserID       uint
-	TelegramID   *int64
-	YandexID     *string
-	Email        string
-	Username     string
-	FirstName    string
-	LastName     string
-	BytesUsed    int64
+// DeleteAPIToken revokes one of the user's API tokens.
+func (s *SQLiteStore) DeleteAPIToken(userID, tokenID uint) error {
+	result := s.db.Unscoped().Where("id = ? AND user_id = ?", tokenID, userID).Delete(&models.APIToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
 }
 
-// GetTotalUserCount returns the total number of registered users
-func (s *SQLiteStore) GetTotalUserCount() (int64, error) {
-	var count int64
-	result := s.db.Model(&models.User{}).Count(&count)
-	return count, result.Error
+// ValidateAPIToken returns the API token and its user, or ErrNotFound for
+// an unknown token. The token's last use is recorded at most once a minute.
+func (s *SQLiteStore) ValidateAPIToken(tokenStr string) (*models.User, *models.APIToken, error) {
+	if tokenStr == "" {
+		return nil, nil, ErrNotFound
+	}
+	var token models.APIToken
+	result := s.db.Where("token_hash = ?", auth.HashToken(tokenStr)).First(&token)
+	if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+		return nil, nil, ErrNotFound
+	} else if result.Error != nil {
+		return nil, nil, result.Error
+	}
+	user, err := s.GetUserByID(token.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	s.db.Model(&models.APIToken{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ?)", token.ID, now.Add(-time.Minute)).
+		Update("last_used_at", now)
+	return user, &token, nil
 }
 
-// GetTopUsersByBandwidthToday returns top N users by bandwidth usage today
-func (s *SQLiteStore) GetTopUsersByBandwidthToday(limit int) ([]UserStats, error) {
-	today := time.Now().Truncate(24 * time.Hour)
+// ClaimIdempotencyKey claims a key of the user for a request. If the user
+// already used the key since, the earlier record is returned instead and
+// claimed is false; older records are replaced.
+func (s *SQLiteStore) ClaimIdempotencyKey(userID uint, key, requestHash string, since time.Time) (*models.IdempotencyKey, bool, error) {
+	record := &models.IdempotencyKey{UserID: userID, Key: key, RequestHash: requestHash}
+	claimed := false
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Map conditions quote the column names; "key" is an SQL keyword
+		match := map[string]interface{}{"user_id": userID, "key": key}
+		if err := tx.Where(match).Where("created_at < ?", since).Delete(&models.IdempotencyKey{}).Error; err != nil {
+			return err
+		}
+		var existing models.IdempotencyKey
+		result := tx.Where(match).First(&existing)
+		if result.Error == nil {
+			record = &existing
+			return nil
+		} else if !errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return result.Error
+		}
+		claimed = true
+		return tx.Create(record).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	return record, claimed, nil
+}
 
-	var stats []UserStats
-	result := s.db.Table("user_bandwidths").
-		Select("user_bandwidths.user_id, users.telegram_id, users.yandex_id, users.email, users.username, users.first_name, users.last_name, user_bandwidths.bytes_used").
-		Joins("JOIN users ON users.id = user_bandwidths.user_id").
-		Where("user_bandwidths.date = ?", today).
-		Order("user_bandwidths.bytes_used DESC").
-		Limit(limit).
-		Scan(&stats)
+// CompleteIdempotencyKey stores the response to the request that claimed
+// the key, to be replayed on retries.
+func (s *SQLiteStore) CompleteIdempotencyKey(id uint, status int, response []byte) error {
+	return s.db.Model(&models.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":   status,
+		"response": response,
+	}).Error
+}
 
-	return stats, result.Error
+// ReleaseIdempotencyKey forgets a claimed key so that the request can be
+// retried, after it failed without effect.
+func (s *SQLiteStore) ReleaseIdempotencyKey(id uint) error {
+	return s.db.Delete(&models.IdempotencyKey{}, id).Error
 }
 
-// GetTopUsersByBandwidthAllTime returns top N users by total bandwidth usage
-func (s *SQLiteStore) GetTopUsersByBandwidthAllTime(limit int) ([]UserStats, error) {
-	var stats []UserStats
-	result := s.db.Table("user_bandwidths").
-		Select("user_bandwidths.user_id, users.telegram_id, users.yandex_id, users.email, users.username, users.first_name, users.last_name, SUM(user_bandwidths.bytes_used) as bytes_used").
-		Joins("JOIN users ON users.id = user_bandwidths.user_id").
-		Group("user_bandwidths.user_id").
-		Order("bytes_used DESC").
-		Limit(limit).
-		Scan(&stats)
+// PruneIdempotencyKeys deletes keys claimed before the given time.
+func (s *SQLiteStore) PruneIdempotencyKeys(before time.Time) (int64, error) {
+	result := s.db.Where("created_at < ?", before).Delete(&models.IdempotencyKey{})
+	return result.RowsAffected, result.Error
+}
 
-	return stats, result.Error
+// RedeemJoinToken exchanges an unused, unexpired join token for a new
+// token of its user that never expires, named after the agent. The join
+// token stops working. Returns ErrNotFound for an unknown, used or expired
+// join token.
+func (s *SQLiteStore) RedeemJoinToken(join, agent string) (string, error) {
+	if join == "" {
+		return "", ErrNotFound
+	}
+	tokenString, err := auth.GenerateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.db.Transaction(func(tx *gorm.DB) error {
+		var record models.JoinToken
+		now := time.Now()
+		result := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", auth.HashToken(join), now).First(&record)
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return ErrNotFound
+		} else if result.Error != nil {
+			return result.Error
+		}
+		// Conditional, so that concurrent redemptions create one token
+		result = tx.Model(&models.JoinToken{}).Where("id = ? AND used_at IS NULL", record.ID).Updates(map[string]interface{}{
+			"used_at": now,
+			"used_by": agent,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return tx.Create(&models.Token{
+			TokenString: tokenString,
+			TokenHash:   auth.HashToken(tokenString),
+			UserID:      record.UserID,
+			Agent:       agent,
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return tokenString, nil
 }
 
-// --- Transaction Operations ---
+// --- Domain Operations ---
 
-// UserRegistration holds data for creating a new user with token and domains
-type UserRegistration struct {
-	User    *models.User
-	Domains []string
+func (s *SQLiteStore) GetUserDomains(userID uint) ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.db.Where("user_id = ?", userID).Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
 }
 
-// CreateUserWithTokenAndDomains creates a user, token, and domains in a single transaction.
-// Returns the created user and token string (shown only once to user).
-func (s *SQLiteStore) CreateUserWithTokenAndDomains(reg UserRegistration) (*models.User, string, error) {
-	var tokenString string
+func (s *SQLiteStore) ValidateDomainOwnership(domainName string, userID uint) (bool, error) {
+	var domain models.Domain
+	result := s.db.Where("name = ? AND user_id = ? AND suspended_at IS NULL", domainName, userID).First(&domain)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, result.Error
+	}
+	return true, nil
+}
+
+func (s *SQLiteStore) CreateDomain(domain *models.Domain) error {
+	return s.db.Create(domain).Error
+}
+
+// GetAllDomains returns all domains with their owners, ordered by name.
+func (s *SQLiteStore) GetAllDomains() ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.reader().Preload("User").Order("name").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// RecycleOrphanedDomains permanently deletes domains whose owner was
+// removed from the database or deleted more than grace ago, making the
+// names available for new users. Domains without an owner (user_id 0) are
+// kept. Returns the number of recycled domains.
+func (s *SQLiteStore) RecycleOrphanedDomains(grace time.Duration) (int64, error) {
+	allUsers := s.db.Unscoped().Model(&models.User{}).Select("id")
+	expiredUsers := s.db.Unscoped().Model(&models.User{}).Select("id").
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-grace))
+	result := s.db.Unscoped().
+		Where("user_id <> 0 AND (user_id NOT IN (?) OR user_id IN (?))", allUsers, expiredUsers).
+		Delete(&models.Domain{})
+	return result.RowsAffected, result.Error
+}
+
+// ReleaseDomain permanently deletes one of the user's domains, making the
+// name available again. Suspended domains cannot be released.
+func (s *SQLiteStore) ReleaseDomain(userID uint, domainName string) error {
+	result := s.db.Unscoped().
+		Where("name = ? AND user_id = ? AND suspended_at IS NULL", domainName, userID).
+		Delete(&models.Domain{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// IsDomainAvailable reports whether no user holds the domain name.
+func (s *SQLiteStore) IsDomainAvailable(domainName string) (bool, error) {
+	var count int64
+	if err := s.db.Unscoped().Model(&models.Domain{}).Where("name = ?", domainName).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
 
+// ReserveDomain gives the user a domain name of their choice, as long as
+// the name is free and the user holds fewer than limit reserved domains.
+func (s *SQLiteStore) ReserveDomain(userID uint, domainName string, limit int) (*models.Domain, error) {
+	domain := &models.Domain{Name: domainName, UserID: userID, Reserved: true}
 	err := s.db.Transaction(func(tx *gorm.DB) error {
-		// 1. Create user
-		if err := tx.Create(reg.User).Error; err != nil {
+		var reserved int64
+		if err := tx.Model(&models.Domain{}).Where("user_id = ? AND reserved = ?", userID, true).Count(&reserved).Error; err != nil {
 			return err
 		}
+		if reserved >= int64(limit) {
+			return ErrReservationLimit
+		}
 
-		// 2. Generate and create token
-		var err error
-		tokenString, err = auth.GenerateSecureToken()
-		if err != nil {
+		// Soft-deleted names still hold the unique index until recycled
+		var taken int64
+		if err := tx.Unscoped().Model(&models.Domain{}).Where("name = ?", domainName).Count(&taken).Error; err != nil {
 			return err
 		}
+		if taken > 0 {
+			return ErrDomainTaken
+		}
+		return tx.Create(domain).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
 
-		token := models.Token{
-			TokenString: tokenString,
-			TokenHash:   auth.HashToken(tokenString),
-			UserID:      reg.User.ID,
+// GetUserByHandle finds a user by Telegram username (with or without "@")
+// or email, ignoring case.
+func (s *SQLiteStore) GetUserByHandle(handle string) (*models.User, error) {
+	handle = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(handle), "@"))
+	if handle == "" {
+		return nil, ErrNotFound
+	}
+	var user models.User
+	result := s.db.Where("LOWER(username) = ? OR LOWER(email) = ?", handle, handle).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
 		}
-		if err := tx.Create(&token).Error; err != nil {
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// CreateDomainTransfer offers a reserved, unsuspended domain of fromUserID
+// to toUserID. A domain has at most one pending transfer.
+func (s *SQLiteStore) CreateDomainTransfer(fromUserID uint, domainName string, toUserID uint) (*models.DomainTransfer, error) {
+	transfer := &models.DomainTransfer{
+		DomainName: domainName,
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Status:     models.TransferPending,
+	}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var owned int64
+		if err := tx.Model(&models.Domain{}).
+			Where("name = ? AND user_id = ? AND reserved = ? AND suspended_at IS NULL", domainName, fromUserID, true).
+			Count(&owned).Error; err != nil {
 			return err
 		}
-
-		// 3. Create domains
-		for _, name := range reg.Domains {
-			domain := models.Domain{Name: name, UserID: reg.User.ID}
-			if err := tx.Create(&domain).Error; err != nil {
-				return err
-			}
+		if owned == 0 {
+			return ErrNotFound
 		}
 
-		return nil
+		var pending int64
+		if err := tx.Model(&models.DomainTransfer{}).
+			Where("domain_name = ? AND status = ?", domainName, models.TransferPending).
+			Count(&pending).Error; err != nil {
+			return err
+		}
+		if pending > 0 {
+			return ErrTransferPending
+		}
+		return tx.Create(transfer).Error
 	})
-
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
+	return transfer, nil
+}
 
-	return reg.User, tokenString, nil
+// GetDomainTransfers returns the pending transfers offered to and by the
+// user, with both users loaded.
+func (s *SQLiteStore) GetDomainTransfers(userID uint) (incoming, outgoing []models.DomainTransfer, err error) {
+	var transfers []models.DomainTransfer
+	if err := s.db.Preload("FromUser").Preload("ToUser").
+		Where("status = ? AND (to_user_id = ? OR from_user_id = ?)", models.TransferPending, userID, userID).
+		Order("created_at").Find(&transfers).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, t := range transfers {
+		if t.ToUserID == userID {
+			incoming = append(incoming, t)
+		} else {
+			outgoing = append(outgoing, t)
+		}
+	}
+	return incoming, outgoing, nil
 }
 
-// --- Seeding ---
+// AcceptDomainTransfer moves the domain of a pending transfer to its
+// recipient, who may hold at most limit reserved domains afterwards and
+// must not be pending approval. A transfer whose domain the owner no
+// longer holds is cancelled.
+func (s *SQLiteStore) AcceptDomainTransfer(transferID, userID uint, limit int) (*models.DomainTransfer, error) {
+	var transfer models.DomainTransfer
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id = ? AND to_user_id = ? AND status = ?", transferID, userID, models.TransferPending).
+			First(&transfer).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrNotFound
+			}
+			return err
+		}
 
-// SeedData seeds test data for development
-func (s *SQLiteStore) SeedData() {
-	var count int64
-	s.db.Model(&models.User{}).Count(&count)
-	if count == 0 {
-		log.Println("Seeding test data...")
-		user := models.User{Email: "test@example.com"}
-		s.db.Create(&user)
+		var recipient models.User
+		if err := tx.First(&recipient, userID).Error; err != nil {
+			return err
+		}
+		if recipient.Status == models.UserStatusPending {
+			return ErrUserPending
+		}
 
-		token := models.Token{TokenString: "sk_live_12345", UserID: user.ID}
-		s.db.Create(&token)
+		var reserved int64
+		if err := tx.Model(&models.Domain{}).Where("user_id = ? AND reserved = ?", userID, true).Count(&reserved).Error; err != nil {
+			return err
+		}
+		if reserved >= int64(limit) {
+			return ErrReservationLimit
+		}
 
-		// Assign some default domains
-		domains := []string{"misty-river", "silent-star", "bold-eagle"}
-		for _, d := range domains {
-			s.db.Create(&models.Domain{Name: d, UserID: user.ID})
+		now := time.Now()
+		result := tx.Model(&models.Domain{}).
+			Where("name = ? AND user_id = ? AND reserved = ? AND suspended_at IS NULL", transfer.DomainName, transfer.FromUserID, true).
+			Updates(map[string]interface{}{"user_id": userID, "hub": false, "capture": false})
+		if result.Error != nil {
+			return result.Error
 		}
-		log.Println("Seeding complete. Use token: sk_live_12345")
+		transfer.Status = models.TransferAccepted
+		if result.RowsAffected == 0 {
+			transfer.Status = models.TransferCancelled
+		}
+		transfer.ResolvedAt = &now
+		return tx.Model(&transfer).Updates(map[string]interface{}{"status": transfer.Status, "resolved_at": &now}).Error
+	})
+	if err != nil {
+		return nil, err
 	}
+	if transfer.Status != models.TransferAccepted {
+		return nil, ErrNotFound
+	}
+	return &transfer, nil
 }
 
-// =============================================================================
-// Backward Compatibility Layer
-// These package-level functions use the global DB variable.
-// Deprecated: Migrate to using SQLiteStore directly.
-// =============================================================================
+// ResolveDomainTransfer ends a pending transfer without moving the domain:
+// the recipient declines it or the owner cancels it.
+func (s *SQLiteStore) ResolveDomainTransfer(transferID, userID uint) (*models.DomainTransfer, error) {
+	var transfer models.DomainTransfer
+	if err := s.db.Where("id = ? AND status = ? AND (to_user_id = ? OR from_user_id = ?)", transferID, models.TransferPending, userID, userID).
+		First(&transfer).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
 
-// InitDB initializes the global database connection.
-// Deprecated: Use NewSQLiteStore instead.
-func InitDB(path string) error {
-	store, err := NewSQLiteStore(path)
+	status := models.TransferCancelled
+	if transfer.ToUserID == userID {
+		status = models.TransferDeclined
+	}
+	now := time.Now()
+	result := s.db.Model(&models.DomainTransfer{}).Where("id = ? AND status = ?", transferID, models.TransferPending).
+		Updates(map[string]interface{}{"status": status, "resolved_at": &now})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotFound
+	}
+	transfer.Status = status
+	transfer.ResolvedAt = &now
+	return &transfer, nil
+}
+
+// CreateOrganization creates an organization owned by ownerID.
+func (s *SQLiteStore) CreateOrganization(name string, ownerID uint) (*models.Organization, error) {
+	org := &models.Organization{Name: name}
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(org).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.OrganizationMember{OrganizationID: org.ID, UserID: ownerID, Role: models.RoleOwner}).Error
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	DB = store.db
-	return nil
+	return org, nil
 }
 
-// SeedData seeds test data using the global DB.
-// Deprecated: Use SQLiteStore.SeedData instead.
-func SeedData() {
-	if DB == nil {
-		return
+// GetUserOrganizations returns the memberships of a user with their
+// organizations loaded.
+func (s *SQLiteStore) GetUserOrganizations(userID uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := s.db.Preload("Organization").Where("user_id = ?", userID).Order("organization_id").Find(&members).Error
+	return members, err
+}
+
+// GetOrganizationMembers returns the members of an organization with
+// their users loaded.
+func (s *SQLiteStore) GetOrganizationMembers(orgID uint) ([]models.OrganizationMember, error) {
+	var members []models.OrganizationMember
+	err := s.db.Preload("User").Where("organization_id = ?", orgID).Order("id").Find(&members).Error
+	return members, err
+}
+
+// GetOrganizationRole returns the role of a user in an organization, or
+// ErrNotFound if they are not a member.
+func (s *SQLiteStore) GetOrganizationRole(orgID, userID uint) (string, error) {
+	var member models.OrganizationMember
+	if err := s.db.Where("organization_id = ? AND user_id = ?", orgID, userID).First(&member).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", ErrNotFound
+		}
+		return "", err
 	}
-	(&SQLiteStore{db: DB}).SeedData()
+	return member.Role, nil
 }
 
-// ValidateToken validates a token using the global DB.
-// Deprecated: Use SQLiteStore.ValidateToken instead.
-func ValidateToken(tokenStr string) (*models.User, error) {
+// AddOrganizationMember adds a user to an organization with a role.
+func (s *SQLiteStore) AddOrganizationMember(orgID, userID uint, role string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		var existing int64
+		if err := tx.Model(&models.OrganizationMember{}).
+			Where("organization_id = ? AND user_id = ?", orgID, userID).
+			Count(&existing).Error; err != nil {
+			return err
+		}
+		if existing > 0 {
+			return ErrAlreadyMember
+		}
+		return tx.Create(&models.OrganizationMember{OrganizationID: orgID, UserID: userID, Role: role}).Error
+	})
+}
+
+// SetOrganizationRole changes the role of a member of an organization.
+func (s *SQLiteStore) SetOrganizationRole(orgID, userID uint, role string) error {
+	result := s.db.Model(&models.OrganizationMember{}).
+		Where("organization_id = ? AND user_id = ?", orgID, userID).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RemoveOrganizationMember removes a user from an organization.
+func (s *SQLiteStore) RemoveOrganizationMember(orgID, userID uint) error {
+	result := s.db.Unscoped().Where("organization_id = ? AND user_id = ?", orgID, userID).Delete(&models.OrganizationMember{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// SuspendDomain takes a domain down. Suspended domains can no longer be
+// bound by their owner.
+func (s *SQLiteStore) SuspendDomain(domainName, reason string) error {
+	now := time.Now()
+	result := s.db.Model(&models.Domain{}).Where("name = ?", domainName).
+		Updates(map[string]interface{}{"suspended_at": &now, "suspend_reason": reason})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// UnsuspendDomain lifts a suspension.
+func (s *SQLiteStore) UnsuspendDomain(domainName string) error {
+	result := s.db.Model(&models.Domain{}).Where("name = ?", domainName).
+		Updates(map[string]interface{}{"suspended_at": nil, "suspend_reason": ""})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetSuspendedDomains returns all suspended domains.
+func (s *SQLiteStore) GetSuspendedDomains() ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.reader().Where("suspended_at IS NOT NULL").Order("name").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// SetDomainSchedule sets the time windows of one of the user's domains.
+// An empty schedule makes the domain reachable at all times.
+func (s *SQLiteStore) SetDomainSchedule(userID uint, domainName, schedule, timezone string) error {
+	result := s.db.Model(&models.Domain{}).Where("name = ? AND user_id = ?", domainName, userID).
+		Updates(map[string]interface{}{"schedule": schedule, "schedule_timezone": timezone})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetScheduledDomains returns all domains restricted to time windows.
+func (s *SQLiteStore) GetScheduledDomains() ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.reader().Where("schedule <> ''").Order("name").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// SetHubDomain makes one of the user's domains their hub, which lists
+// their online tunnels. An empty domainName removes the hub.
+func (s *SQLiteStore) SetHubDomain(userID uint, domainName string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.Domain{}).Where("user_id = ? AND hub = ?", userID, true).Update("hub", false).Error; err != nil {
+			return err
+		}
+		if domainName == "" {
+			return nil
+		}
+		result := tx.Model(&models.Domain{}).Where("name = ? AND user_id = ?", domainName, userID).Update("hub", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return nil
+	})
+}
+
+// GetHubDomains returns all hub domains.
+func (s *SQLiteStore) GetHubDomains() ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.reader().Where("hub = ?", true).Order("name").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// SetDomainCapture turns server-side capture of one of the user's domains
+// on or off.
+func (s *SQLiteStore) SetDomainCapture(userID uint, domainName string, enabled bool) error {
+	result := s.db.Model(&models.Domain{}).Where("name = ? AND user_id = ?", domainName, userID).Update("capture", enabled)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetCaptureDomains returns all domains with server-side capture on.
+func (s *SQLiteStore) GetCaptureDomains() ([]models.Domain, error) {
+	var domains []models.Domain
+	if err := s.reader().Where("capture = ?", true).Order("name").Find(&domains).Error; err != nil {
+		return nil, err
+	}
+	return domains, nil
+}
+
+// --- Abuse Report Operations ---
+
+func (s *SQLiteStore) CreateAbuseReport(report *models.AbuseReport) error {
+	return s.db.Create(report).Error
+}
+
+func (s *SQLiteStore) GetAbuseReports(status string) ([]models.AbuseReport, error) {
+	var reports []models.AbuseReport
+	query := s.reader()
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Order("created_at DESC").Find(&reports).Error; err != nil {
+		return nil, err
+	}
+	return reports, nil
+}
+
+// UpdateAbuseReportStatus moves a report through the review workflow.
+func (s *SQLiteStore) UpdateAbuseReportStatus(id uint, status string) error {
+	result := s.db.Model(&models.AbuseReport{}).Where("id = ?", id).Update("status", status)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// --- Bandwidth Operations ---
+
+func (s *SQLiteStore) GetUserBandwidthToday(userID uint) (int64, error) {
+	today := time.Now().Truncate(24 * time.Hour)
+	var bandwidth models.UserBandwidth
+	result := s.db.Where("user_id = ? AND date = ?", userID, today).First(&bandwidth)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return 0, nil // No usage today
+		}
+		return 0, result.Error
+	}
+	return bandwidth.BytesUsed, nil
+}
+
+func (s *SQLiteStore) AddUserBandwidth(userID uint, bytes int64) error {
+	today := time.Now().Truncate(24 * time.Hour)
+
+	// Use upsert: insert or update if exists
+	result := s.db.Exec(`
+		INSERT INTO user_bandwidths (user_id, date, bytes_used, created_at, updated_at)
+		VALUES (?, ?, ?, datetime('now'), datetime('now'))
+		ON CONFLICT(user_id, date) DO UPDATE SET
+			bytes_used = bytes_used + excluded.bytes_used,
+			updated_at = datetime('now')
+	`, userID, today, bytes)
+
+	return result.Error
+}
+
+// GetUserTotalBandwidth returns total bandwidth used by user across all days
+func (s *SQLiteStore) GetUserTotalBandwidth(userID uint) (int64, error) {
+	var total int64
+	result := s.reader().Model(&models.UserBandwidth{}).
+		Where("user_id = ?", userID).
+		Select("COALESCE(SUM(bytes_used), 0)").
+		Scan(&total)
+	return total, result.Error
+}
+
+// GetTotalBandwidthToday returns bandwidth used by all users today
+func (s *SQLiteStore) GetTotalBandwidthToday() (int64, error) {
+	today := time.Now().Truncate(24 * time.Hour)
+	var total int64
+	result := s.reader().Model(&models.UserBandwidth{}).
+		Where("date = ?", today).
+		Select("COALESCE(SUM(bytes_used), 0)").
+		Scan(&total)
+	return total, result.Error
+}
+
+// bandwidthArchiveDate is the date of the per-user row that accumulates
+// compacted bandwidth history.
+var bandwidthArchiveDate = time.Unix(0, 0).Truncate(24 * time.Hour)
+
+// CompactBandwidth folds daily bandwidth rows older than before into a single
+// archive row per user, keeping totals intact while bounding table growth.
+// Returns the number of compacted daily rows.
+func (s *SQLiteStore) CompactBandwidth(before time.Time) (int64, error) {
+	var compacted int64
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		var sums []struct {
+			UserID    uint
+			BytesUsed int64
+		}
+		old := tx.Model(&models.UserBandwidth{}).Where("date < ? AND date <> ?", before, bandwidthArchiveDate)
+		if err := old.Select("user_id, SUM(bytes_used) AS bytes_used").Group("user_id").Scan(&sums).Error; err != nil {
+			return err
+		}
+
+		for _, sum := range sums {
+			if err := tx.Exec(`
+				INSERT INTO user_bandwidths (user_id, date, bytes_used, created_at, updated_at)
+				VALUES (?, ?, ?, datetime('now'), datetime('now'))
+				ON CONFLICT(user_id, date) DO UPDATE SET
+					bytes_used = bytes_used + excluded.bytes_used,
+					updated_at = datetime('now')
+			`, sum.UserID, bandwidthArchiveDate, sum.BytesUsed).Error; err != nil {
+				return err
+			}
+		}
+
+		result := tx.Unscoped().Where("date < ? AND date <> ?", before, bandwidthArchiveDate).Delete(&models.UserBandwidth{})
+		compacted = result.RowsAffected
+		return result.Error
+	})
+
+	return compacted, err
+}
+
+// --- Geo Usage Operations ---
+
+// AddUserGeoUsage counts one request and its bytes for the visitor country.
+func (s *SQLiteStore) AddUserGeoUsage(userID uint, country string, bytes int64) error {
+	today := time.Now().Truncate(24 * time.Hour)
+
+	result := s.db.Exec(`
+		INSERT INTO user_geo_usages (user_id, date, country, requests, bytes_used, created_at, updated_at)
+		VALUES (?, ?, ?, 1, ?, datetime('now'), datetime('now'))
+		ON CONFLICT(user_id, date, country) DO UPDATE SET
+			requests = requests + 1,
+			bytes_used = bytes_used + excluded.bytes_used,
+			updated_at = datetime('now')
+	`, userID, today, country, bytes)
+
+	return result.Error
+}
+
+// GetUserGeoUsage returns the user's traffic per country since the given
+// day, busiest countries first.
+func (s *SQLiteStore) GetUserGeoUsage(userID uint, since time.Time) ([]GeoUsage, error) {
+	var usage []GeoUsage
+	result := s.reader().Model(&models.UserGeoUsage{}).
+		Select("country, SUM(requests) AS requests, SUM(bytes_used) AS bytes_used").
+		Where("user_id = ? AND date >= ?", userID, since.Truncate(24*time.Hour)).
+		Group("country").
+		Order("requests DESC").
+		Scan(&usage)
+	return usage, result.Error
+}
+
+// PruneGeoUsage deletes per-country counters for days before the given date.
+func (s *SQLiteStore) PruneGeoUsage(before time.Time) (int64, error) {
+	result := s.db.Unscoped().Where("date < ?", before).Delete(&models.UserGeoUsage{})
+	return result.RowsAffected, result.Error
+}
+
+// --- Status Page Operations ---
+
+// GetUserByStatusSlug returns the user who published the status page.
+func (s *SQLiteStore) GetUserByStatusSlug(slug string) (*models.User, error) {
+	var user models.User
+	result := s.reader().Where("status_slug = ?", slug).First(&user)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, ErrNotFound
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// SetStatusPage publishes the user's status page under slug with the
+// given domains, or takes it down if slug is nil. Returns ErrDuplicateKey
+// if another user has taken the slug.
+func (s *SQLiteStore) SetStatusPage(userID uint, slug *string, domains []string) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		if slug != nil {
+			var taken int64
+			if err := tx.Model(&models.User{}).Where("status_slug = ? AND id <> ?", *slug, userID).Count(&taken).Error; err != nil {
+				return err
+			}
+			if taken > 0 {
+				return ErrDuplicateKey
+			}
+		}
+		if err := tx.Model(&models.User{}).Where("id = ?", userID).Update("status_slug", slug).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Domain{}).Where("user_id = ?", userID).Update("on_status_page", false).Error; err != nil {
+			return err
+		}
+		if slug == nil || len(domains) == 0 {
+			return nil
+		}
+		return tx.Model(&models.Domain{}).Where("user_id = ? AND name IN ?", userID, domains).
+			Update("on_status_page", true).Error
+	})
+}
+
+// AddDomainUptime adds seconds of today's uptime to each of the tunnels.
+func (s *SQLiteStore) AddDomainUptime(domains []string, seconds int64) error {
+	if len(domains) == 0 {
+		return nil
+	}
+	today := time.Now().Truncate(24 * time.Hour)
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		for _, domain := range domains {
+			err := tx.Exec(`
+				INSERT INTO domain_uptimes (domain, date, seconds, created_at, updated_at)
+				VALUES (?, ?, ?, datetime('now'), datetime('now'))
+				ON CONFLICT(domain, date) DO UPDATE SET
+					seconds = MIN(seconds + excluded.seconds, 86400),
+					updated_at = datetime('now')
+			`, domain, today, seconds).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// GetDomainUptime returns the daily uptime of the tunnels since the given day.
+func (s *SQLiteStore) GetDomainUptime(domains []string, since time.Time) ([]models.DomainUptime, error) {
+	var uptime []models.DomainUptime
+	if len(domains) == 0 {
+		return uptime, nil
+	}
+	result := s.reader().Where("domain IN ? AND date >= ?", domains, since.Truncate(24*time.Hour)).
+		Order("date").Find(&uptime)
+	return uptime, result.Error
+}
+
+// PruneDomainUptime deletes uptime records for days before the given date.
+func (s *SQLiteStore) PruneDomainUptime(before time.Time) (int64, error) {
+	result := s.db.Unscoped().Where("date < ?", before).Delete(&models.DomainUptime{})
+	return result.RowsAffected, result.Error
+}
+
+// AddConnectionEvents stores connection events of tunnels.
+func (s *SQLiteStore) AddConnectionEvents(events []models.ConnectionEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return s.db.Create(&events).Error
+}
+
+// GetConnectionEvents returns the user's latest connection events of the
+// tunnel (FQDN), newest first.
+func (s *SQLiteStore) GetConnectionEvents(userID uint, domain string, limit int) ([]models.ConnectionEvent, error) {
+	var events []models.ConnectionEvent
+	result := s.reader().Where("user_id = ? AND domain = ?", userID, domain).
+		Order("created_at DESC, id DESC").Limit(limit).Find(&events)
+	return events, result.Error
+}
+
+// PruneConnectionEvents deletes connection events older than the given time.
+func (s *SQLiteStore) PruneConnectionEvents(before time.Time) (int64, error) {
+	result := s.db.Where("created_at < ?", before).Delete(&models.ConnectionEvent{})
+	return result.RowsAffected, result.Error
+}
+
+// --- Job Lock Operations ---
+
+// AcquireJobLock takes or extends the named lease for owner.
+// Returns false if another owner holds an unexpired lease.
+func (s *SQLiteStore) AcquireJobLock(name, owner string, ttl time.Duration) (bool, error) {
+	now := time.Now()
+	result := s.db.Exec(`
+		INSERT INTO job_locks (name, owner, expires_at)
+		VALUES (?, ?, ?)
+		ON CONFLICT(name) DO UPDATE SET
+			owner = excluded.owner,
+			expires_at = excluded.expires_at
+		WHERE job_locks.owner = excluded.owner OR job_locks.expires_at < ?
+	`, name, owner, now.Add(ttl), now)
+	if result.Error != nil {
+		return false, result.Error
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// ReleaseJobLock releases the named lease if it is held by owner.
+func (s *SQLiteStore) ReleaseJobLock(name, owner string) error {
+	return s.db.Where("name = ? AND owner = ?", name, owner).Delete(&models.JobLock{}).Error
+}
+
+// --- Statistics Operations ---
+
+// UserStats holds user information with bandwidth statistics
+type UserStats struct {
+	UserID       uint
+	TelegramID   *int64
+	YandexID     *string
+	Email        string
+	Username     string
+	FirstName    string
+	LastName     string
+	BytesUsed    int64
+}
+
+// GetTotalUserCount returns the total number of registered users
+func (s *SQLiteStore) GetTotalUserCount() (int64, error) {
+	var count int64
+	result := s.reader().Model(&models.User{}).Count(&count)
+	return count, result.Error
+}
+
+// GetTopUsersByBandwidthToday returns top N users by bandwidth usage today
+func (s *SQLiteStore) GetTopUsersByBandwidthToday(limit int) ([]UserStats, error) {
+	today := time.Now().Truncate(24 * time.Hour)
+
+	var stats []UserStats
+	result := s.reader().Table("user_bandwidths").
+		Select("user_bandwidths.user_id, users.telegram_id, users.yandex_id, users.email, users.username, users.first_name, users.last_name, user_bandwidths.bytes_used").
+		Joins("JOIN users ON users.id = user_bandwidths.user_id").
+		Where("user_bandwidths.date = ?", today).
+		Order("user_bandwidths.bytes_used DESC").
+		Limit(limit).
+		Scan(&stats)
+
+	return stats, result.Error
+}
+
+// GetTopUsersByBandwidthAllTime returns top N users by total bandwidth usage
+func (s *SQLiteStore) GetTopUsersByBandwidthAllTime(limit int) ([]UserStats, error) {
+	var stats []UserStats
+	result := s.reader().Table("user_bandwidths").
+		Select("user_bandwidths.user_id, users.telegram_id, users.yandex_id, users.email, users.username, users.first_name, users.last_name, SUM(user_bandwidths.bytes_used) as bytes_used").
+		Joins("JOIN users ON users.id = user_bandwidths.user_id").
+		Group("user_bandwidths.user_id").
+		Order("bytes_used DESC").
+		Limit(limit).
+		Scan(&stats)
+
+	return stats, result.Error
+}
+
+// --- Transaction Operations ---
+
+// UserRegistration holds data for creating a new user with token and domains
+type UserRegistration struct {
+	User       *models.User
+	Domains    []string
+	InviteCode string // Redeemed in the same transaction (optional)
+}
+
+// CreateUserWithTokenAndDomains creates a user, token, and domains in a single transaction.
+// Returns the created user and token string (shown only once to user).
+func (s *SQLiteStore) CreateUserWithTokenAndDomains(reg UserRegistration) (*models.User, string, error) {
+	var tokenString string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// 1. Redeem invite and create user
+		if reg.InviteCode != "" {
+			invite, err := redeemInvite(tx, reg.InviteCode)
+			if err != nil {
+				return err
+			}
+			reg.User.InviteCode = invite.Code
+			reg.User.InvitedBy = invite.CreatedBy
+		}
+		if err := tx.Create(reg.User).Error; err != nil {
+			return err
+		}
+
+		// 2. Generate and create token
+		var err error
+		tokenString, err = auth.GenerateSecureToken()
+		if err != nil {
+			return err
+		}
+
+		token := models.Token{
+			TokenString: tokenString,
+			TokenHash:   auth.HashToken(tokenString),
+			UserID:      reg.User.ID,
+		}
+		if err := tx.Create(&token).Error; err != nil {
+			return err
+		}
+
+		// 3. Create domains
+		for _, name := range reg.Domains {
+			domain := models.Domain{Name: name, UserID: reg.User.ID}
+			if err := tx.Create(&domain).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	return reg.User, tokenString, nil
+}
+
+// GetPendingUsers returns accounts awaiting approval, oldest first.
+func (s *SQLiteStore) GetPendingUsers() ([]models.User, error) {
+	var users []models.User
+	result := s.db.Where("status = ?", models.UserStatusPending).Order("created_at ASC").Find(&users)
+	return users, result.Error
+}
+
+// ApproveUser activates a pending account and gives it a token and domains.
+// Returns the new token string.
+func (s *SQLiteStore) ApproveUser(userID uint, domains []string) (string, error) {
+	var tokenString string
+
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND status = ?", userID, models.UserStatusPending).
+			Update("status", models.UserStatusActive)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+
+		var err error
+		tokenString, err = auth.GenerateSecureToken()
+		if err != nil {
+			return err
+		}
+		token := models.Token{
+			TokenString: tokenString,
+			TokenHash:   auth.HashToken(tokenString),
+			UserID:      userID,
+		}
+		if err := tx.Create(&token).Error; err != nil {
+			return err
+		}
+
+		for _, name := range domains {
+			domain := models.Domain{Name: name, UserID: userID}
+			if err := tx.Create(&domain).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	if err != nil {
+		return "", err
+	}
+	return tokenString, nil
+}
+
+// RejectUser deletes a pending account. The row is removed permanently so
+// that the person can sign up again later.
+func (s *SQLiteStore) RejectUser(userID uint) error {
+	result := s.db.Unscoped().Where("id = ? AND status = ?", userID, models.UserStatusPending).Delete(&models.User{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetUsers returns all accounts, newest first.
+func (s *SQLiteStore) GetUsers() ([]models.User, error) {
+	var users []models.User
+	result := s.reader().Order("created_at DESC").Find(&users)
+	return users, result.Error
+}
+
+// GetBandwidthToday returns today's usage of every user with traffic today.
+func (s *SQLiteStore) GetBandwidthToday() (map[uint]int64, error) {
+	today := time.Now().Truncate(24 * time.Hour)
+
+	var rows []models.UserBandwidth
+	if err := s.reader().Where("date = ?", today).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	usage := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		usage[row.UserID] += row.BytesUsed
+	}
+	return usage, nil
+}
+
+// RevokeUserTokens deletes every token of the user, those of its agents
+// included, its API tokens and its unused join tokens. The user gets a new token from the
+// dashboard.
+func (s *SQLiteStore) RevokeUserTokens(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		return revokeUserTokens(tx, userID)
+	})
+}
+
+func revokeUserTokens(tx *gorm.DB, userID uint) error {
+	if err := tx.Where("user_id = ?", userID).Delete(&models.Token{}).Error; err != nil {
+		return err
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.APIToken{}).Error; err != nil {
+		return err
+	}
+	return tx.Unscoped().Where("user_id = ? AND used_at IS NULL", userID).Delete(&models.JoinToken{}).Error
+}
+
+// BanUser bans an active account and revokes its tokens. Banned users
+// cannot sign in or connect until UnbanUser.
+func (s *SQLiteStore) BanUser(userID uint) error {
+	return s.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.User{}).
+			Where("id = ? AND status = ?", userID, models.UserStatusActive).
+			Update("status", models.UserStatusBanned)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrNotFound
+		}
+		return revokeUserTokens(tx, userID)
+	})
+}
+
+// UnbanUser lifts a ban. The account has no token until the user creates
+// one in the dashboard.
+func (s *SQLiteStore) UnbanUser(userID uint) error {
+	result := s.db.Model(&models.User{}).
+		Where("id = ? AND status = ?", userID, models.UserStatusBanned).
+		Update("status", models.UserStatusActive)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// --- Invite Operations ---
+
+// CreateInvite stores a new invite code.
+func (s *SQLiteStore) CreateInvite(invite *models.Invite) error {
+	return s.db.Create(invite).Error
+}
+
+// GetUserInvites returns invites created by the user, newest first.
+// A zero userID returns invites created by the admin.
+func (s *SQLiteStore) GetUserInvites(userID uint) ([]models.Invite, error) {
+	var invites []models.Invite
+	query := s.db.Order("created_at DESC")
+	if userID == 0 {
+		query = query.Where("created_by IS NULL")
+	} else {
+		query = query.Where("created_by = ?", userID)
+	}
+	result := query.Find(&invites)
+	return invites, result.Error
+}
+
+// CountReferrals returns how many users signed up with the user's invites.
+func (s *SQLiteStore) CountReferrals(userID uint) (int64, error) {
+	var count int64
+	result := s.reader().Model(&models.User{}).Where("invited_by = ?", userID).Count(&count)
+	return count, result.Error
+}
+
+// redeemInvite counts one use of a valid invite code.
+func redeemInvite(tx *gorm.DB, code string) (*models.Invite, error) {
+	result := tx.Model(&models.Invite{}).
+		Where("code = ? AND (max_uses = 0 OR uses < max_uses) AND (expires_at IS NULL OR expires_at > ?)", code, time.Now()).
+		Update("uses", gorm.Expr("uses + 1"))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInviteInvalid
+	}
+
+	var invite models.Invite
+	if err := tx.Where("code = ?", code).First(&invite).Error; err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+// --- Seeding ---
+
+// SeedData seeds test data for development
+func (s *SQLiteStore) SeedData() {
+	var count int64
+	s.db.Model(&models.User{}).Count(&count)
+	if count == 0 {
+		log.Println("Seeding test data...")
+		user := models.User{Email: "test@example.com"}
+		s.db.Create(&user)
+
+		token := models.Token{TokenString: "sk_live_12345", UserID: user.ID}
+		s.db.Create(&token)
+
+		// Assign some default domains
+		domains := []string{"misty-river", "silent-star", "bold-eagle"}
+		for _, d := range domains {
+			s.db.Create(&models.Domain{Name: d, UserID: user.ID})
+		}
+		log.Println("Seeding complete. Use token: sk_live_12345")
+	}
+}
+
+// =============================================================================
+// Backward Compatibility Layer
+// These package-level functions use the global DB variable.
+// Deprecated: Migrate to using SQLiteStore directly.
+// =============================================================================
+
+// InitDB initializes the global database connection.
+// Deprecated: Use NewSQLiteStore instead.
+func InitDB(path string) error {
+	return InitDBWithConfig(path, DefaultPoolConfig())
+}
+
+// InitDBWithConfig initializes the global database connection with pool settings.
+// Deprecated: Use NewSQLiteStoreWithConfig instead.
+func InitDBWithConfig(path string, pool PoolConfig) error {
+	store, err := NewSQLiteStoreWithConfig(path, pool)
+	if err != nil {
+		return err
+	}
+	DB = store.db
+	return nil
+}
+
+// SeedData seeds test data using the global DB.
+// Deprecated: Use SQLiteStore.SeedData instead.
+func SeedData() {
+	if DB == nil {
+		return
+	}
+	(&SQLiteStore{db: DB}).SeedData()
+}
+
+// ValidateToken validates a token using the global DB.
+// Deprecated: Use SQLiteStore.ValidateToken instead.
+func ValidateToken(tokenStr string) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ValidateToken(tokenStr)
+}
+
+// ValidateDomainOwnership checks domain ownership using the global DB.
+// Deprecated: Use SQLiteStore.ValidateDomainOwnership instead.
+func ValidateDomainOwnership(domainName string, userID uint) (bool, error) {
+	if DB == nil {
+		return false, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ValidateDomainOwnership(domainName, userID)
+}
+
+// GetUserDomains gets user domains using the global DB.
+// Deprecated: Use SQLiteStore.GetUserDomains instead.
+func GetUserDomains(userID uint) ([]models.Domain, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserDomains(userID)
+}
+
+// GetAllDomains gets all domains with their owners using the global DB.
+// Deprecated: Use SQLiteStore.GetAllDomains instead.
+func GetAllDomains() ([]models.Domain, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetAllDomains()
+}
+
+// CreateUserWithTokenAndDomains creates user with token and domains using the global DB.
+// Deprecated: Use SQLiteStore.CreateUserWithTokenAndDomains instead.
+func CreateUserWithTokenAndDomains(reg UserRegistration) (*models.User, string, error) {
+	if DB == nil {
+		return nil, "", ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateUserWithTokenAndDomains(reg)
+}
+
+// GetUserByTelegramID gets user by Telegram ID using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByTelegramID instead.
+func GetUserByTelegramID(telegramID int64) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByTelegramID(telegramID)
+}
+
+// CreateUser creates a user using the global DB.
+// Deprecated: Use SQLiteStore.CreateUser instead.
+func CreateUser(user *models.User) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateUser(user)
+}
+
+// UpdateUser updates a user using the global DB.
+// Deprecated: Use SQLiteStore.UpdateUser instead.
+func UpdateUser(user *models.User) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).UpdateUser(user)
+}
+
+// GetUserByStripeCustomer gets a user by Stripe customer ID using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByStripeCustomer instead.
+func GetUserByStripeCustomer(customerID string) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByStripeCustomer(customerID)
+}
+
+// UpdateUserBilling updates a user's plan using the global DB.
+// Deprecated: Use SQLiteStore.UpdateUserBilling instead.
+func UpdateUserBilling(userID uint, plan, customerID, subscriptionID string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).UpdateUserBilling(userID, plan, customerID, subscriptionID)
+}
+
+// SetUserRole sets the dashboard role of a user using the global DB.
+// Deprecated: Use SQLiteStore.SetUserRole instead.
+func SetUserRole(userID uint, role string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetUserRole(userID, role)
+}
+
+// GetUserToken gets user token using the global DB.
+// Deprecated: Use SQLiteStore.GetUserToken instead.
+func GetUserToken(userID uint) (*models.Token, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserToken(userID)
+}
+
+// GetUserByID gets user by ID using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByID instead.
+func GetUserByID(id uint) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByID(id)
+}
+
+// RegenerateToken regenerates a user's token using the global DB.
+// Deprecated: Use SQLiteStore.RegenerateToken instead.
+func RegenerateToken(userID uint) (string, error) {
+	if DB == nil {
+		return "", ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RegenerateToken(userID)
+}
+
+// SetTokenExpiry sets a user's token expiry using the global DB.
+// Deprecated: Use SQLiteStore.SetTokenExpiry instead.
+func SetTokenExpiry(userID uint, ttl time.Duration) (string, time.Time, error) {
+	if DB == nil {
+		return "", time.Time{}, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetTokenExpiry(userID, ttl)
+}
+
+// RefreshToken exchanges a refresh token using the global DB.
+// Deprecated: Use SQLiteStore.RefreshToken instead.
+func RefreshToken(refresh string, ttl time.Duration) (string, string, time.Time, error) {
+	if DB == nil {
+		return "", "", time.Time{}, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RefreshToken(refresh, ttl)
+}
+
+// CreateJoinToken creates a join token using the global DB.
+// Deprecated: Use SQLiteStore.CreateJoinToken instead.
+func CreateJoinToken(userID uint, ttl time.Duration) (string, *models.JoinToken, error) {
+	if DB == nil {
+		return "", nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateJoinToken(userID, ttl)
+}
+
+// GetUserJoinTokens returns a user's recent join tokens using the global DB.
+// Deprecated: Use SQLiteStore.GetUserJoinTokens instead.
+func GetUserJoinTokens(userID uint, since time.Time) ([]models.JoinToken, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserJoinTokens(userID, since)
+}
+
+// DeleteJoinToken revokes a user's join token using the global DB.
+// Deprecated: Use SQLiteStore.DeleteJoinToken instead.
+func DeleteJoinToken(userID, joinID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).DeleteJoinToken(userID, joinID)
+}
+
+// CreateLoginToken issues a sign-in link token using the global DB.
+// Deprecated: Use SQLiteStore.CreateLoginToken instead.
+func CreateLoginToken(email string, ttl time.Duration) (string, error) {
+	if DB == nil {
+		return "", ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateLoginToken(email, ttl)
+}
+
+// RedeemLoginToken uses up a sign-in link token using the global DB.
+// Deprecated: Use SQLiteStore.RedeemLoginToken instead.
+func RedeemLoginToken(token string) (string, error) {
+	if DB == nil {
+		return "", ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RedeemLoginToken(token)
+}
+
+// RedeemJoinToken exchanges a join token using the global DB.
+// Deprecated: Use SQLiteStore.RedeemJoinToken instead.
+func RedeemJoinToken(join, agent string) (string, error) {
+	if DB == nil {
+		return "", ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RedeemJoinToken(join, agent)
+}
+
+// ClaimIdempotencyKey claims an idempotency key using the global DB.
+// Deprecated: Use SQLiteStore.ClaimIdempotencyKey instead.
+func ClaimIdempotencyKey(userID uint, key, requestHash string, since time.Time) (*models.IdempotencyKey, bool, error) {
+	if DB == nil {
+		return nil, false, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ClaimIdempotencyKey(userID, key, requestHash, since)
+}
+
+// CompleteIdempotencyKey stores a response using the global DB.
+// Deprecated: Use SQLiteStore.CompleteIdempotencyKey instead.
+func CompleteIdempotencyKey(id uint, status int, response []byte) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CompleteIdempotencyKey(id, status, response)
+}
+
+// ReleaseIdempotencyKey forgets a claimed key using the global DB.
+// Deprecated: Use SQLiteStore.ReleaseIdempotencyKey instead.
+func ReleaseIdempotencyKey(id uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ReleaseIdempotencyKey(id)
+}
+
+// PruneIdempotencyKeys deletes old idempotency keys using the global DB.
+// Deprecated: Use SQLiteStore.PruneIdempotencyKeys instead.
+func PruneIdempotencyKeys(before time.Time) (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).PruneIdempotencyKeys(before)
+}
+
+// CreateAPIToken creates an API token using the global DB.
+// Deprecated: Use SQLiteStore.CreateAPIToken instead.
+func CreateAPIToken(userID uint, name string) (string, *models.APIToken, error) {
+	if DB == nil {
+		return "", nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateAPIToken(userID, name)
+}
+
+// GetUserAPITokens lists a user's API tokens using the global DB.
+// Deprecated: Use SQLiteStore.GetUserAPITokens instead.
+func GetUserAPITokens(userID uint) ([]models.APIToken, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserAPITokens(userID)
+}
+
+// DeleteAPIToken revokes an API token using the global DB.
+// Deprecated: Use SQLiteStore.DeleteAPIToken instead.
+func DeleteAPIToken(userID, tokenID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).DeleteAPIToken(userID, tokenID)
+}
+
+// ValidateAPIToken checks an API token using the global DB.
+// Deprecated: Use SQLiteStore.ValidateAPIToken instead.
+func ValidateAPIToken(tokenStr string) (*models.User, *models.APIToken, error) {
+	if DB == nil {
+		return nil, nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ValidateAPIToken(tokenStr)
+}
+
+// AcceptTerms accepts terms for a user using the global DB.
+// Deprecated: Use SQLiteStore.AcceptTerms instead.
+func AcceptTerms(userID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).AcceptTerms(userID)
+}
+
+// SetTOTP stores a user's authenticator secret using the global DB.
+// Deprecated: Use SQLiteStore.SetTOTP instead.
+func SetTOTP(userID uint, secret string, enabled bool) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetTOTP(userID, secret, enabled)
+}
+
+// DeleteUser deletes a user's account using the global DB.
+// Deprecated: Use SQLiteStore.DeleteUser instead.
+func DeleteUser(userID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).DeleteUser(userID)
+}
+
+// ReleaseDomain releases a user's domain using the global DB.
+// Deprecated: Use SQLiteStore.ReleaseDomain instead.
+func ReleaseDomain(userID uint, domainName string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ReleaseDomain(userID, domainName)
+}
+
+// IsDomainAvailable checks a domain name using the global DB.
+// Deprecated: Use SQLiteStore.IsDomainAvailable instead.
+func IsDomainAvailable(domainName string) (bool, error) {
+	if DB == nil {
+		return false, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).IsDomainAvailable(domainName)
+}
+
+// ReserveDomain reserves a domain name for a user using the global DB.
+// Deprecated: Use SQLiteStore.ReserveDomain instead.
+func ReserveDomain(userID uint, domainName string, limit int) (*models.Domain, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ReserveDomain(userID, domainName, limit)
+}
+
+// GetUserByHandle finds a user by username or email using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByHandle instead.
+func GetUserByHandle(handle string) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByHandle(handle)
+}
+
+// CreateDomainTransfer offers a domain to another user using the global DB.
+// Deprecated: Use SQLiteStore.CreateDomainTransfer instead.
+func CreateDomainTransfer(fromUserID uint, domainName string, toUserID uint) (*models.DomainTransfer, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateDomainTransfer(fromUserID, domainName, toUserID)
+}
+
+// GetDomainTransfers returns a user's pending transfers using the global DB.
+// Deprecated: Use SQLiteStore.GetDomainTransfers instead.
+func GetDomainTransfers(userID uint) (incoming, outgoing []models.DomainTransfer, err error) {
+	if DB == nil {
+		return nil, nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetDomainTransfers(userID)
+}
+
+// AcceptDomainTransfer accepts a transfer using the global DB.
+// Deprecated: Use SQLiteStore.AcceptDomainTransfer instead.
+func AcceptDomainTransfer(transferID, userID uint, limit int) (*models.DomainTransfer, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).AcceptDomainTransfer(transferID, userID, limit)
+}
+
+// ResolveDomainTransfer declines or cancels a transfer using the global DB.
+// Deprecated: Use SQLiteStore.ResolveDomainTransfer instead.
+func ResolveDomainTransfer(transferID, userID uint) (*models.DomainTransfer, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ResolveDomainTransfer(transferID, userID)
+}
+
+// CreateOrganization creates an organization using the global DB.
+// Deprecated: Use SQLiteStore.CreateOrganization instead.
+func CreateOrganization(name string, ownerID uint) (*models.Organization, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateOrganization(name, ownerID)
+}
+
+// GetUserOrganizations returns a user's memberships using the global DB.
+// Deprecated: Use SQLiteStore.GetUserOrganizations instead.
+func GetUserOrganizations(userID uint) ([]models.OrganizationMember, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserOrganizations(userID)
+}
+
+// GetOrganizationMembers returns an organization's members using the global DB.
+// Deprecated: Use SQLiteStore.GetOrganizationMembers instead.
+func GetOrganizationMembers(orgID uint) ([]models.OrganizationMember, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetOrganizationMembers(orgID)
+}
+
+// GetOrganizationRole returns a member's role using the global DB.
+// Deprecated: Use SQLiteStore.GetOrganizationRole instead.
+func GetOrganizationRole(orgID, userID uint) (string, error) {
+	if DB == nil {
+		return "", ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetOrganizationRole(orgID, userID)
+}
+
+// AddOrganizationMember adds a member using the global DB.
+// Deprecated: Use SQLiteStore.AddOrganizationMember instead.
+func AddOrganizationMember(orgID, userID uint, role string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).AddOrganizationMember(orgID, userID, role)
+}
+
+// SetOrganizationRole changes a member's role using the global DB.
+// Deprecated: Use SQLiteStore.SetOrganizationRole instead.
+func SetOrganizationRole(orgID, userID uint, role string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetOrganizationRole(orgID, userID, role)
+}
+
+// RemoveOrganizationMember removes a member using the global DB.
+// Deprecated: Use SQLiteStore.RemoveOrganizationMember instead.
+func RemoveOrganizationMember(orgID, userID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RemoveOrganizationMember(orgID, userID)
+}
+
+// RecordDevice records a device connection using the global DB.
+// Deprecated: Use SQLiteStore.RecordDevice instead.
+func RecordDevice(userID uint, fingerprint, name, ip string) (*models.Device, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RecordDevice(userID, fingerprint, name, ip)
+}
+
+// GetUserDevices returns a user's recent devices using the global DB.
+// Deprecated: Use SQLiteStore.GetUserDevices instead.
+func GetUserDevices(userID uint, since time.Time) ([]models.Device, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserDevices(userID, since)
+}
+
+// RevokeDevice marks a user's device as revoked using the global DB.
+// Deprecated: Use SQLiteStore.RevokeDevice instead.
+func RevokeDevice(userID, deviceID uint) (*models.Device, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RevokeDevice(userID, deviceID)
+}
+
+// CreateAlertRule stores an alert rule using the global DB.
+// Deprecated: Use SQLiteStore.CreateAlertRule instead.
+func CreateAlertRule(rule *models.AlertRule) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateAlertRule(rule)
+}
+
+// GetUserAlertRules returns a user's alert rules using the global DB.
+// Deprecated: Use SQLiteStore.GetUserAlertRules instead.
+func GetUserAlertRules(userID uint) ([]models.AlertRule, error) {
 	if DB == nil {
 		return nil, ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).ValidateToken(tokenStr)
+	return (&SQLiteStore{db: DB}).GetUserAlertRules(userID)
 }
 
-// ValidateDomainOwnership checks domain ownership using the global DB.
-// Deprecated: Use SQLiteStore.ValidateDomainOwnership instead.
-func ValidateDomainOwnership(domainName string, userID uint) (bool, error) {
+// DeleteAlertRule deletes a user's alert rule using the global DB.
+// Deprecated: Use SQLiteStore.DeleteAlertRule instead.
+func DeleteAlertRule(userID, ruleID uint) error {
 	if DB == nil {
-		return false, ErrDBError
+		return ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).ValidateDomainOwnership(domainName, userID)
+	return (&SQLiteStore{db: DB}).DeleteAlertRule(userID, ruleID)
 }
 
-// GetUserDomains gets user domains using the global DB.
-// Deprecated: Use SQLiteStore.GetUserDomains instead.
-func GetUserDomains(userID uint) ([]models.Domain, error) {
+// CreateTunnelDefinition stores a tunnel definition using the global DB.
+// Deprecated: Use SQLiteStore.CreateTunnelDefinition instead.
+func CreateTunnelDefinition(def *models.TunnelDefinition) error {
 	if DB == nil {
-		return nil, ErrDBError
+		return ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).GetUserDomains(userID)
+	return (&SQLiteStore{db: DB}).CreateTunnelDefinition(def)
 }
 
-// CreateUserWithTokenAndDomains creates user with token and domains using the global DB.
-// Deprecated: Use SQLiteStore.CreateUserWithTokenAndDomains instead.
-func CreateUserWithTokenAndDomains(reg UserRegistration) (*models.User, string, error) {
+// GetUserTunnelDefinitions returns a user's tunnel definitions using the global DB.
+// Deprecated: Use SQLiteStore.GetUserTunnelDefinitions instead.
+func GetUserTunnelDefinitions(userID uint) ([]models.TunnelDefinition, error) {
 	if DB == nil {
-		return nil, "", ErrDBError
+		return nil, ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).CreateUserWithTokenAndDomains(reg)
+	return (&SQLiteStore{db: DB}).GetUserTunnelDefinitions(userID)
 }
 
-// GetUserByTelegramID gets user by Telegram ID using the global DB.
-// Deprecated: Use SQLiteStore.GetUserByTelegramID instead.
-func GetUserByTelegramID(telegramID int64) (*models.User, error) {
+// DeleteTunnelDefinition deletes a user's tunnel definition using the global DB.
+// Deprecated: Use SQLiteStore.DeleteTunnelDefinition instead.
+func DeleteTunnelDefinition(userID, defID uint) (*models.TunnelDefinition, error) {
 	if DB == nil {
 		return nil, ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).GetUserByTelegramID(telegramID)
+	return (&SQLiteStore{db: DB}).DeleteTunnelDefinition(userID, defID)
 }
 
-// UpdateUser updates a user using the global DB.
-// Deprecated: Use SQLiteStore.UpdateUser instead.
-func UpdateUser(user *models.User) error {
+// MarkAlertDomainsOnline records online tunnels using the global DB.
+// Deprecated: Use SQLiteStore.MarkAlertDomainsOnline instead.
+func MarkAlertDomainsOnline(domains []string, at time.Time) error {
 	if DB == nil {
 		return ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).UpdateUser(user)
+	return (&SQLiteStore{db: DB}).MarkAlertDomainsOnline(domains, at)
 }
 
-// GetUserToken gets user token using the global DB.
-// Deprecated: Use SQLiteStore.GetUserToken instead.
-func GetUserToken(userID uint) (*models.Token, error) {
+// GetOfflineAlertRules returns rules of offline tunnels using the global DB.
+// Deprecated: Use SQLiteStore.GetOfflineAlertRules instead.
+func GetOfflineAlertRules(seenBefore time.Time) ([]models.AlertRule, error) {
 	if DB == nil {
 		return nil, ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).GetUserToken(userID)
+	return (&SQLiteStore{db: DB}).GetOfflineAlertRules(seenBefore)
 }
 
-// GetUserByID gets user by ID using the global DB.
-// Deprecated: Use SQLiteStore.GetUserByID instead.
-func GetUserByID(id uint) (*models.User, error) {
+// GetRecoveredAlertRules returns rules of recovered tunnels using the global DB.
+// Deprecated: Use SQLiteStore.GetRecoveredAlertRules instead.
+func GetRecoveredAlertRules() ([]models.AlertRule, error) {
 	if DB == nil {
 		return nil, ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).GetUserByID(id)
+	return (&SQLiteStore{db: DB}).GetRecoveredAlertRules()
 }
 
-// RegenerateToken regenerates a user's token using the global DB.
-// Deprecated: Use SQLiteStore.RegenerateToken instead.
-func RegenerateToken(userID uint) (string, error) {
+// SetAlertFired updates an alert rule's state using the global DB.
+// Deprecated: Use SQLiteStore.SetAlertFired instead.
+func SetAlertFired(ruleID uint, firedAt *time.Time) error {
 	if DB == nil {
-		return "", ErrDBError
+		return ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).RegenerateToken(userID)
+	return (&SQLiteStore{db: DB}).SetAlertFired(ruleID, firedAt)
 }
 
-// AcceptTerms accepts terms for a user using the global DB.
-// Deprecated: Use SQLiteStore.AcceptTerms instead.
-func AcceptTerms(userID uint) error {
+// SetQuotaAlerts sets a user's quota notification preference using the global DB.
+// Deprecated: Use SQLiteStore.SetQuotaAlerts instead.
+func SetQuotaAlerts(userID uint, enabled bool) error {
 	if DB == nil {
 		return ErrDBError
 	}
-	return (&SQLiteStore{db: DB}).AcceptTerms(userID)
+	return (&SQLiteStore{db: DB}).SetQuotaAlerts(userID, enabled)
 }
 
 // CreateAbuseReport creates an abuse report using the global DB.
@@ -546,6 +2564,24 @@ func CreateAbuseReport(report *models.AbuseReport) error {
 	return (&SQLiteStore{db: DB}).CreateAbuseReport(report)
 }
 
+// GetAbuseReports lists abuse reports using the global DB.
+// Deprecated: Use SQLiteStore.GetAbuseReports instead.
+func GetAbuseReports(status string) ([]models.AbuseReport, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetAbuseReports(status)
+}
+
+// UpdateAbuseReportStatus updates a report status using the global DB.
+// Deprecated: Use SQLiteStore.UpdateAbuseReportStatus instead.
+func UpdateAbuseReportStatus(id uint, status string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).UpdateAbuseReportStatus(id, status)
+}
+
 // GetUserByYandexID gets user by Yandex ID using the global DB.
 // Deprecated: Use SQLiteStore.GetUserByYandexID instead.
 func GetUserByYandexID(yandexID string) (*models.User, error) {
@@ -555,6 +2591,42 @@ func GetUserByYandexID(yandexID string) (*models.User, error) {
 	return (&SQLiteStore{db: DB}).GetUserByYandexID(yandexID)
 }
 
+// GetUserByOIDCSubject gets user by SSO subject using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByOIDCSubject instead.
+func GetUserByOIDCSubject(subject string) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByOIDCSubject(subject)
+}
+
+// GetUserByEmail gets the oldest user with an email address using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByEmail instead.
+func GetUserByEmail(email string) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByEmail(email)
+}
+
+// GetUserByOAuthID gets the user of a social login account using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByOAuthID instead.
+func GetUserByOAuthID(provider, id string) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByOAuthID(provider, id)
+}
+
+// LinkOAuthAccount links a social login account to a user using the global DB.
+// Deprecated: Use SQLiteStore.LinkOAuthAccount instead.
+func LinkOAuthAccount(userID uint, provider, id string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).LinkOAuthAccount(userID, provider, id)
+}
+
 // LinkYandexAccount links a Yandex account to a user using the global DB.
 // Deprecated: Use SQLiteStore.LinkYandexAccount instead.
 func LinkYandexAccount(userID uint, yandexID string) error {
@@ -600,6 +2672,132 @@ func AddUserBandwidth(userID uint, bytes int64) error {
 	return (&SQLiteStore{db: DB}).AddUserBandwidth(userID, bytes)
 }
 
+// AddUserGeoUsage records geo usage using the global DB.
+// Deprecated: Use SQLiteStore.AddUserGeoUsage instead.
+func AddUserGeoUsage(userID uint, country string, bytes int64) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).AddUserGeoUsage(userID, country, bytes)
+}
+
+// GetUserGeoUsage gets per-country usage using the global DB.
+// Deprecated: Use SQLiteStore.GetUserGeoUsage instead.
+func GetUserGeoUsage(userID uint, since time.Time) ([]GeoUsage, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserGeoUsage(userID, since)
+}
+
+// PruneGeoUsage deletes old geo usage using the global DB.
+// Deprecated: Use SQLiteStore.PruneGeoUsage instead.
+func PruneGeoUsage(before time.Time) (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).PruneGeoUsage(before)
+}
+
+// GetPendingUsers gets accounts awaiting approval using the global DB.
+// Deprecated: Use SQLiteStore.GetPendingUsers instead.
+func GetPendingUsers() ([]models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetPendingUsers()
+}
+
+// ApproveUser activates a pending account using the global DB.
+// Deprecated: Use SQLiteStore.ApproveUser instead.
+func ApproveUser(userID uint, domains []string) (string, error) {
+	if DB == nil {
+		return "", ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ApproveUser(userID, domains)
+}
+
+// RejectUser deletes a pending account using the global DB.
+// Deprecated: Use SQLiteStore.RejectUser instead.
+func RejectUser(userID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RejectUser(userID)
+}
+
+// GetUsers returns all accounts using the global DB.
+// Deprecated: Use SQLiteStore.GetUsers instead.
+func GetUsers() ([]models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUsers()
+}
+
+// GetBandwidthToday returns today's usage of all users using the global DB.
+// Deprecated: Use SQLiteStore.GetBandwidthToday instead.
+func GetBandwidthToday() (map[uint]int64, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetBandwidthToday()
+}
+
+// RevokeUserTokens deletes every token of the user using the global DB.
+// Deprecated: Use SQLiteStore.RevokeUserTokens instead.
+func RevokeUserTokens(userID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RevokeUserTokens(userID)
+}
+
+// BanUser bans an account using the global DB.
+// Deprecated: Use SQLiteStore.BanUser instead.
+func BanUser(userID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).BanUser(userID)
+}
+
+// UnbanUser lifts a ban using the global DB.
+// Deprecated: Use SQLiteStore.UnbanUser instead.
+func UnbanUser(userID uint) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).UnbanUser(userID)
+}
+
+// CreateInvite stores an invite using the global DB.
+// Deprecated: Use SQLiteStore.CreateInvite instead.
+func CreateInvite(invite *models.Invite) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CreateInvite(invite)
+}
+
+// GetUserInvites gets a user's invites using the global DB.
+// Deprecated: Use SQLiteStore.GetUserInvites instead.
+func GetUserInvites(userID uint) ([]models.Invite, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserInvites(userID)
+}
+
+// CountReferrals counts a user's referrals using the global DB.
+// Deprecated: Use SQLiteStore.CountReferrals instead.
+func CountReferrals(userID uint) (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CountReferrals(userID)
+}
+
 // GetTotalUserCount gets total user count using the global DB.
 // Deprecated: Use SQLiteStore.GetTotalUserCount instead.
 func GetTotalUserCount() (int64, error) {
@@ -626,3 +2824,201 @@ func GetTopUsersByBandwidthAllTime(limit int) ([]UserStats, error) {
 	}
 	return (&SQLiteStore{db: DB}).GetTopUsersByBandwidthAllTime(limit)
 }
+
+// GetTotalBandwidthToday gets today's bandwidth across all users using the global DB.
+// Deprecated: Use SQLiteStore.GetTotalBandwidthToday instead.
+func GetTotalBandwidthToday() (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetTotalBandwidthToday()
+}
+
+// CompactBandwidth compacts old bandwidth rows using the global DB.
+// Deprecated: Use SQLiteStore.CompactBandwidth instead.
+func CompactBandwidth(before time.Time) (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).CompactBandwidth(before)
+}
+
+// RecycleOrphanedDomains recycles domains of deleted users using the global DB.
+// Deprecated: Use SQLiteStore.RecycleOrphanedDomains instead.
+func RecycleOrphanedDomains(grace time.Duration) (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).RecycleOrphanedDomains(grace)
+}
+
+// AcquireJobLock acquires a job lease using the global DB.
+// Deprecated: Use SQLiteStore.AcquireJobLock instead.
+func AcquireJobLock(name, owner string, ttl time.Duration) (bool, error) {
+	if DB == nil {
+		return false, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).AcquireJobLock(name, owner, ttl)
+}
+
+// ReleaseJobLock releases a job lease using the global DB.
+// Deprecated: Use SQLiteStore.ReleaseJobLock instead.
+func ReleaseJobLock(name, owner string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).ReleaseJobLock(name, owner)
+}
+
+// SuspendDomain suspends a domain using the global DB.
+// Deprecated: Use SQLiteStore.SuspendDomain instead.
+func SuspendDomain(domainName, reason string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SuspendDomain(domainName, reason)
+}
+
+// UnsuspendDomain lifts a domain suspension using the global DB.
+// Deprecated: Use SQLiteStore.UnsuspendDomain instead.
+func UnsuspendDomain(domainName string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).UnsuspendDomain(domainName)
+}
+
+// GetSuspendedDomains lists suspended domains using the global DB.
+// Deprecated: Use SQLiteStore.GetSuspendedDomains instead.
+func GetSuspendedDomains() ([]models.Domain, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetSuspendedDomains()
+}
+
+// SetDomainSchedule sets a domain's time windows using the global DB.
+// Deprecated: Use SQLiteStore.SetDomainSchedule instead.
+func SetDomainSchedule(userID uint, domainName, schedule, timezone string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetDomainSchedule(userID, domainName, schedule, timezone)
+}
+
+// GetScheduledDomains lists scheduled domains using the global DB.
+// Deprecated: Use SQLiteStore.GetScheduledDomains instead.
+func GetScheduledDomains() ([]models.Domain, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetScheduledDomains()
+}
+
+// SetHubDomain sets or removes a user's hub domain using the global DB.
+// Deprecated: Use SQLiteStore.SetHubDomain instead.
+func SetHubDomain(userID uint, domainName string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetHubDomain(userID, domainName)
+}
+
+// GetHubDomains lists hub domains using the global DB.
+// Deprecated: Use SQLiteStore.GetHubDomains instead.
+func GetHubDomains() ([]models.Domain, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetHubDomains()
+}
+
+// SetDomainCapture turns capture of a domain on or off using the global DB.
+// Deprecated: Use SQLiteStore.SetDomainCapture instead.
+func SetDomainCapture(userID uint, domainName string, enabled bool) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetDomainCapture(userID, domainName, enabled)
+}
+
+// GetCaptureDomains lists domains with capture on using the global DB.
+// Deprecated: Use SQLiteStore.GetCaptureDomains instead.
+func GetCaptureDomains() ([]models.Domain, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetCaptureDomains()
+}
+
+// GetUserByStatusSlug gets the owner of a status page using the global DB.
+// Deprecated: Use SQLiteStore.GetUserByStatusSlug instead.
+func GetUserByStatusSlug(slug string) (*models.User, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetUserByStatusSlug(slug)
+}
+
+// SetStatusPage publishes or takes down a status page using the global DB.
+// Deprecated: Use SQLiteStore.SetStatusPage instead.
+func SetStatusPage(userID uint, slug *string, domains []string) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).SetStatusPage(userID, slug, domains)
+}
+
+// AddDomainUptime records tunnel uptime using the global DB.
+// Deprecated: Use SQLiteStore.AddDomainUptime instead.
+func AddDomainUptime(domains []string, seconds int64) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).AddDomainUptime(domains, seconds)
+}
+
+// GetDomainUptime returns tunnel uptime using the global DB.
+// Deprecated: Use SQLiteStore.GetDomainUptime instead.
+func GetDomainUptime(domains []string, since time.Time) ([]models.DomainUptime, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetDomainUptime(domains, since)
+}
+
+// PruneDomainUptime deletes old uptime records using the global DB.
+// Deprecated: Use SQLiteStore.PruneDomainUptime instead.
+func PruneDomainUptime(before time.Time) (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).PruneDomainUptime(before)
+}
+
+// AddConnectionEvents stores connection events using the global DB.
+// Deprecated: Use SQLiteStore.AddConnectionEvents instead.
+func AddConnectionEvents(events []models.ConnectionEvent) error {
+	if DB == nil {
+		return ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).AddConnectionEvents(events)
+}
+
+// GetConnectionEvents returns connection events using the global DB.
+// Deprecated: Use SQLiteStore.GetConnectionEvents instead.
+func GetConnectionEvents(userID uint, domain string, limit int) ([]models.ConnectionEvent, error) {
+	if DB == nil {
+		return nil, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).GetConnectionEvents(userID, domain, limit)
+}
+
+// PruneConnectionEvents deletes old connection events using the global DB.
+// Deprecated: Use SQLiteStore.PruneConnectionEvents instead.
+func PruneConnectionEvents(before time.Time) (int64, error) {
+	if DB == nil {
+		return 0, ErrDBError
+	}
+	return (&SQLiteStore{db: DB}).PruneConnectionEvents(before)
+}
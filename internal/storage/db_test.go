@@ -0,0 +1,822 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gopublic/internal/models"
+)
+
+// newTestStore opens a fresh store in a temporary directory.
+func newTestStore(t *testing.T) *SQLiteStore {
+	t.Helper()
+	store, err := NewSQLiteStore(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("NewSQLiteStore: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// createUser stores a user with the given username.
+func createUser(t *testing.T, store *SQLiteStore, username string) *models.User {
+	t.Helper()
+	user := &models.User{Username: username}
+	if err := store.CreateUser(user); err != nil {
+		t.Fatalf("CreateUser(%s): %v", username, err)
+	}
+	return user
+}
+
+func TestNewSQLiteStoreWithConfig_Replica(t *testing.T) {
+	dir := t.TempDir()
+	primaryPath := filepath.Join(dir, "primary.db")
+	replicaPath := filepath.Join(dir, "replica.db")
+
+	// The replica lags behind: it has one user, the primary two
+	replica, err := NewSQLiteStore(replicaPath)
+	if err != nil {
+		t.Fatalf("NewSQLiteStore(replica): %v", err)
+	}
+	createUser(t, replica, "alice")
+	replica.Close()
+
+	pool := DefaultPoolConfig()
+	pool.ReplicaPath = replicaPath
+	store, err := NewSQLiteStoreWithConfig(primaryPath, pool)
+	if err != nil {
+		t.Fatalf("NewSQLiteStoreWithConfig: %v", err)
+	}
+	defer store.Close()
+	createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	// Reporting queries read the replica
+	if count, err := store.GetTotalUserCount(); err != nil || count != 1 {
+		t.Errorf("GetTotalUserCount() = %d, %v; want 1 from the replica", count, err)
+	}
+	// Everything else reads the primary
+	if _, err := store.GetUserByID(bob.ID); err != nil {
+		t.Errorf("GetUserByID(bob) = %v; want the primary's user", err)
+	}
+}
+
+func TestNewSQLiteStoreWithConfig_MissingReplica(t *testing.T) {
+	dir := t.TempDir()
+	pool := DefaultPoolConfig()
+	pool.ReplicaPath = filepath.Join(dir, "missing", "replica.db")
+
+	if store, err := NewSQLiteStoreWithConfig(filepath.Join(dir, "primary.db"), pool); err == nil {
+		store.Close()
+		t.Fatal("NewSQLiteStoreWithConfig() with a missing replica succeeded")
+	}
+}
+
+func TestNewSQLiteStoreWithConfig_NoReplica(t *testing.T) {
+	store := newTestStore(t)
+	createUser(t, store, "alice")
+
+	if count, err := store.GetTotalUserCount(); err != nil || count != 1 {
+		t.Errorf("GetTotalUserCount() = %d, %v; want 1 from the primary", count, err)
+	}
+}
+
+func TestRecycleOrphanedDomains(t *testing.T) {
+	store := newTestStore(t)
+	active := createUser(t, store, "active")
+	recent := createUser(t, store, "recent")
+	expired := createUser(t, store, "expired")
+	gone := createUser(t, store, "gone")
+
+	for name, userID := range map[string]uint{
+		"active":  active.ID,
+		"recent":  recent.ID,
+		"expired": expired.ID,
+		"gone":    gone.ID,
+		"unowned": 0,
+	} {
+		if err := store.CreateDomain(&models.Domain{Name: name, UserID: userID}); err != nil {
+			t.Fatalf("CreateDomain(%s): %v", name, err)
+		}
+	}
+
+	// recent was deleted an hour ago, expired two months ago, gone is erased
+	if err := store.DeleteUser(recent.ID); err != nil {
+		t.Fatalf("DeleteUser(recent): %v", err)
+	}
+	if err := store.DeleteUser(expired.ID); err != nil {
+		t.Fatalf("DeleteUser(expired): %v", err)
+	}
+	store.db.Unscoped().Model(&models.User{}).Where("id = ?", recent.ID).Update("deleted_at", time.Now().Add(-time.Hour))
+	store.db.Unscoped().Model(&models.User{}).Where("id = ?", expired.ID).Update("deleted_at", time.Now().Add(-60*24*time.Hour))
+	store.db.Unscoped().Delete(&models.User{}, gone.ID)
+
+	n, err := store.RecycleOrphanedDomains(30 * 24 * time.Hour)
+	if err != nil {
+		t.Fatalf("RecycleOrphanedDomains: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("RecycleOrphanedDomains() = %d, want 2", n)
+	}
+
+	for name, wantAvailable := range map[string]bool{
+		"active":  false,
+		"recent":  false,
+		"expired": true,
+		"gone":    true,
+		"unowned": false,
+	} {
+		available, err := store.IsDomainAvailable(name)
+		if err != nil {
+			t.Fatalf("IsDomainAvailable(%s): %v", name, err)
+		}
+		if available != wantAvailable {
+			t.Errorf("IsDomainAvailable(%s) = %v, want %v", name, available, wantAvailable)
+		}
+	}
+}
+
+func TestRecordDevice_AfterRevoke(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "alice")
+
+	device, err := store.RecordDevice(user.ID, "aaaa", "laptop", "203.0.113.7")
+	if err != nil {
+		t.Fatalf("RecordDevice: %v", err)
+	}
+	if _, err := store.RevokeDevice(user.ID, device.ID); err != nil {
+		t.Fatalf("RevokeDevice: %v", err)
+	}
+	if _, err := store.RevokeDevice(user.ID+1, device.ID); err != ErrNotFound {
+		t.Errorf("RevokeDevice() for another user = %v, want ErrNotFound", err)
+	}
+
+	// Connecting again required the rotated token
+	device, err = store.RecordDevice(user.ID, "aaaa", "laptop", "203.0.113.7")
+	if err != nil {
+		t.Fatalf("RecordDevice: %v", err)
+	}
+	if device.RevokedAt != nil || device.Connections != 2 {
+		t.Errorf("RecordDevice() = revoked %v, %d connections; want not revoked, 2", device.RevokedAt, device.Connections)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "alice")
+	oldToken, err := store.RegenerateToken(user.ID)
+	if err != nil {
+		t.Fatalf("RegenerateToken: %v", err)
+	}
+
+	refresh, _, err := store.SetTokenExpiry(user.ID, -time.Minute)
+	if err != nil {
+		t.Fatalf("SetTokenExpiry: %v", err)
+	}
+	if _, err := store.ValidateToken(oldToken); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("ValidateToken() of an expired token = %v, want ErrTokenExpired", err)
+	}
+
+	token, newRefresh, expiresAt, err := store.RefreshToken(refresh, time.Hour)
+	if err != nil {
+		t.Fatalf("RefreshToken: %v", err)
+	}
+	if time.Until(expiresAt) < 59*time.Minute {
+		t.Errorf("expiresAt = %v, want in an hour", expiresAt)
+	}
+	if got, err := store.ValidateToken(token); err != nil || got.ID != user.ID {
+		t.Errorf("ValidateToken() of the new token = %v, %v", got, err)
+	}
+	if _, err := store.ValidateToken(oldToken); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ValidateToken() of the old token = %v, want ErrNotFound", err)
+	}
+
+	// Refresh tokens are single-use
+	if _, _, _, err := store.RefreshToken(refresh, time.Hour); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RefreshToken() reused = %v, want ErrNotFound", err)
+	}
+
+	// Without a lifetime the token no longer expires
+	token, newRefresh, _, err = store.RefreshToken(newRefresh, 0)
+	if err != nil || newRefresh != "" {
+		t.Fatalf("RefreshToken(0) = refresh %q, %v; want none", newRefresh, err)
+	}
+	if _, err := store.ValidateToken(token); err != nil {
+		t.Errorf("ValidateToken() of a token without expiry: %v", err)
+	}
+}
+
+func TestRedeemJoinToken(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "alice")
+	own, err := store.RegenerateToken(user.ID)
+	if err != nil {
+		t.Fatalf("RegenerateToken: %v", err)
+	}
+
+	join, _, err := store.CreateJoinToken(user.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateJoinToken: %v", err)
+	}
+	token, err := store.RedeemJoinToken(join, "ci-runner-1")
+	if err != nil {
+		t.Fatalf("RedeemJoinToken: %v", err)
+	}
+	if got, err := store.ValidateToken(token); err != nil || got.ID != user.ID {
+		t.Errorf("ValidateToken() of the agent token = %v, %v", got, err)
+	}
+	if shown, err := store.GetUserToken(user.ID); err != nil || shown.TokenString != own {
+		t.Errorf("GetUserToken() = %v, %v, want the user's own token", shown, err)
+	}
+
+	// Join tokens are single-use
+	if _, err := store.RedeemJoinToken(join, "ci-runner-2"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemJoinToken() reused = %v, want ErrNotFound", err)
+	}
+	tokens, _ := store.GetUserJoinTokens(user.ID, time.Now().Add(-time.Hour))
+	if len(tokens) != 1 || tokens[0].UsedAt == nil || tokens[0].UsedBy != "ci-runner-1" {
+		t.Errorf("GetUserJoinTokens() = %+v, want the redeemed token", tokens)
+	}
+
+	expired, _, _ := store.CreateJoinToken(user.ID, -time.Minute)
+	if _, err := store.RedeemJoinToken(expired, "ci-runner-3"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemJoinToken() expired = %v, want ErrNotFound", err)
+	}
+
+	// Regenerating the user's token revokes the agents' tokens too
+	if _, err := store.RegenerateToken(user.ID); err != nil {
+		t.Fatalf("RegenerateToken: %v", err)
+	}
+	if _, err := store.ValidateToken(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ValidateToken() after regenerate = %v, want ErrNotFound", err)
+	}
+}
+
+func TestAPITokens(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "alice")
+	other := createUser(t, store, "bob")
+
+	token, record, err := store.CreateAPIToken(user.ID, "terraform")
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+	got, apiToken, err := store.ValidateAPIToken(token)
+	if err != nil || got.ID != user.ID || apiToken.ID != record.ID {
+		t.Fatalf("ValidateAPIToken() = %v, %v, %v", got, apiToken, err)
+	}
+	if tokens, _ := store.GetUserAPITokens(user.ID); len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Errorf("GetUserAPITokens() = %+v, want one used token", tokens)
+	}
+
+	// API tokens do not open tunnels
+	if _, err := store.ValidateToken(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ValidateToken() of an API token = %v, want ErrNotFound", err)
+	}
+
+	if err := store.DeleteAPIToken(other.ID, record.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteAPIToken() by another user = %v, want ErrNotFound", err)
+	}
+	if err := store.DeleteAPIToken(user.ID, record.ID); err != nil {
+		t.Fatalf("DeleteAPIToken: %v", err)
+	}
+	if _, _, err := store.ValidateAPIToken(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ValidateAPIToken() after delete = %v, want ErrNotFound", err)
+	}
+}
+
+func TestClaimIdempotencyKey(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "alice")
+	other := createUser(t, store, "bob")
+	since := time.Now().Add(-time.Hour)
+
+	record, claimed, err := store.ClaimIdempotencyKey(user.ID, "k1", "hash", since)
+	if err != nil || !claimed {
+		t.Fatalf("ClaimIdempotencyKey() = %v, %v, want claimed", claimed, err)
+	}
+	if _, claimed, _ := store.ClaimIdempotencyKey(user.ID, "k1", "hash", since); claimed {
+		t.Error("ClaimIdempotencyKey() claimed a key in use")
+	}
+	// Keys are per user
+	if _, claimed, _ := store.ClaimIdempotencyKey(other.ID, "k1", "hash", since); !claimed {
+		t.Error("ClaimIdempotencyKey() of another user not claimed")
+	}
+
+	if err := store.CompleteIdempotencyKey(record.ID, 201, []byte(`{"id":1}`)); err != nil {
+		t.Fatalf("CompleteIdempotencyKey: %v", err)
+	}
+	got, claimed, err := store.ClaimIdempotencyKey(user.ID, "k1", "hash", since)
+	if err != nil || claimed || got.Status != 201 || string(got.Response) != `{"id":1}` || got.RequestHash != "hash" {
+		t.Errorf("ClaimIdempotencyKey() after completion = %+v, %v, %v", got, claimed, err)
+	}
+
+	// Expired keys are replaced
+	if _, claimed, _ := store.ClaimIdempotencyKey(user.ID, "k1", "other", time.Now().Add(time.Second)); !claimed {
+		t.Error("ClaimIdempotencyKey() did not replace an expired key")
+	}
+
+	record, _, _ = store.ClaimIdempotencyKey(user.ID, "k2", "hash", since)
+	if err := store.ReleaseIdempotencyKey(record.ID); err != nil {
+		t.Fatalf("ReleaseIdempotencyKey: %v", err)
+	}
+	if _, claimed, _ := store.ClaimIdempotencyKey(user.ID, "k2", "hash", since); !claimed {
+		t.Error("ClaimIdempotencyKey() after release not claimed")
+	}
+
+	if n, err := store.PruneIdempotencyKeys(time.Now().Add(time.Second)); err != nil || n != 3 {
+		t.Errorf("PruneIdempotencyKeys() = %d, %v, want 3", n, err)
+	}
+}
+
+func TestBanUser(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "mallory")
+	token, err := store.RegenerateToken(user.ID)
+	if err != nil {
+		t.Fatalf("RegenerateToken: %v", err)
+	}
+	join, _, err := store.CreateJoinToken(user.ID, time.Hour)
+	if err != nil {
+		t.Fatalf("CreateJoinToken: %v", err)
+	}
+	apiToken, _, err := store.CreateAPIToken(user.ID, "script")
+	if err != nil {
+		t.Fatalf("CreateAPIToken: %v", err)
+	}
+
+	if err := store.BanUser(user.ID); err != nil {
+		t.Fatalf("BanUser: %v", err)
+	}
+	if got, _ := store.GetUserByID(user.ID); got.Status != models.UserStatusBanned {
+		t.Errorf("Status = %q, want banned", got.Status)
+	}
+	if _, err := store.ValidateToken(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ValidateToken() after ban = %v, want ErrNotFound", err)
+	}
+	if _, err := store.RedeemJoinToken(join, "ci-runner-1"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemJoinToken() after ban = %v, want ErrNotFound", err)
+	}
+	if _, _, err := store.ValidateAPIToken(apiToken); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ValidateAPIToken() after ban = %v, want ErrNotFound", err)
+	}
+	if err := store.BanUser(user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("BanUser() twice = %v, want ErrNotFound", err)
+	}
+
+	if err := store.UnbanUser(user.ID); err != nil {
+		t.Fatalf("UnbanUser: %v", err)
+	}
+	if got, _ := store.GetUserByID(user.ID); got.Status != models.UserStatusActive {
+		t.Errorf("Status = %q, want active", got.Status)
+	}
+	if err := store.UnbanUser(user.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("UnbanUser() of an active user = %v, want ErrNotFound", err)
+	}
+}
+
+// createDomain stores a domain of the user.
+func createDomain(t *testing.T, store *SQLiteStore, name string, userID uint, reserved bool) {
+	t.Helper()
+	if err := store.CreateDomain(&models.Domain{Name: name, UserID: userID, Reserved: reserved}); err != nil {
+		t.Fatalf("CreateDomain(%s): %v", name, err)
+	}
+}
+
+func TestCreateDomainTransfer(t *testing.T) {
+	store := newTestStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	createDomain(t, store, "reserved", alice.ID, true)
+	createDomain(t, store, "assigned", alice.ID, false)
+	createDomain(t, store, "suspended", alice.ID, true)
+	createDomain(t, store, "offered", alice.ID, true)
+	createDomain(t, store, "bobs", bob.ID, true)
+	if err := store.SuspendDomain("suspended", "abuse"); err != nil {
+		t.Fatalf("SuspendDomain: %v", err)
+	}
+	if _, err := store.CreateDomainTransfer(alice.ID, "offered", bob.ID); err != nil {
+		t.Fatalf("CreateDomainTransfer(offered): %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		domain  string
+		wantErr error
+	}{
+		{"reserved domain", "reserved", nil},
+		{"assigned at signup", "assigned", ErrNotFound},
+		{"suspended", "suspended", ErrNotFound},
+		{"owned by someone else", "bobs", ErrNotFound},
+		{"missing", "missing", ErrNotFound},
+		{"already offered", "offered", ErrTransferPending},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transfer, err := store.CreateDomainTransfer(alice.ID, tt.domain, bob.ID)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateDomainTransfer(%s) = %v, want %v", tt.domain, err, tt.wantErr)
+			}
+			if err == nil && transfer.Status != models.TransferPending {
+				t.Errorf("transfer status = %q, want %q", transfer.Status, models.TransferPending)
+			}
+		})
+	}
+}
+
+func TestAcceptDomainTransfer(t *testing.T) {
+	tests := []struct {
+		name       string
+		limit      int
+		pending    bool // The recipient waits for approval
+		released   bool // The owner gave the domain up after offering it
+		wantErr    error
+		wantStatus string
+		wantOwner  string
+	}{
+		{"accepted", 1, false, false, nil, models.TransferAccepted, "bob"},
+		{"over the limit", 0, false, false, ErrReservationLimit, models.TransferPending, "alice"},
+		{"recipient pending approval", 1, true, false, ErrUserPending, models.TransferPending, "alice"},
+		{"owner no longer holds it", 1, false, true, ErrNotFound, models.TransferCancelled, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			alice := createUser(t, store, "alice")
+			bob := createUser(t, store, "bob")
+			createDomain(t, store, "demo", alice.ID, true)
+			transfer, err := store.CreateDomainTransfer(alice.ID, "demo", bob.ID)
+			if err != nil {
+				t.Fatalf("CreateDomainTransfer: %v", err)
+			}
+			if tt.pending {
+				store.db.Model(&models.User{}).Where("id = ?", bob.ID).Update("status", models.UserStatusPending)
+			}
+			if tt.released {
+				if err := store.ReleaseDomain(alice.ID, "demo"); err != nil {
+					t.Fatalf("ReleaseDomain: %v", err)
+				}
+			}
+
+			if _, err := store.AcceptDomainTransfer(transfer.ID, alice.ID, tt.limit); !errors.Is(err, ErrNotFound) {
+				t.Errorf("AcceptDomainTransfer() by the owner = %v, want ErrNotFound", err)
+			}
+			if _, err := store.AcceptDomainTransfer(transfer.ID, bob.ID, tt.limit); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("AcceptDomainTransfer() = %v, want %v", err, tt.wantErr)
+			}
+
+			var stored models.DomainTransfer
+			store.db.First(&stored, transfer.ID)
+			if stored.Status != tt.wantStatus {
+				t.Errorf("transfer status = %q, want %q", stored.Status, tt.wantStatus)
+			}
+			owner := ""
+			var domain models.Domain
+			if err := store.db.Preload("User").Where("name = ?", "demo").First(&domain).Error; err == nil {
+				owner = domain.User.Username
+			}
+			if owner != tt.wantOwner {
+				t.Errorf("domain owner = %q, want %q", owner, tt.wantOwner)
+			}
+		})
+	}
+}
+
+func TestResolveDomainTransfer(t *testing.T) {
+	tests := []struct {
+		name       string
+		by         string
+		wantStatus string
+	}{
+		{"declined by the recipient", "bob", models.TransferDeclined},
+		{"cancelled by the owner", "alice", models.TransferCancelled},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newTestStore(t)
+			users := map[string]*models.User{
+				"alice": createUser(t, store, "alice"),
+				"bob":   createUser(t, store, "bob"),
+				"carol": createUser(t, store, "carol"),
+			}
+			createDomain(t, store, "demo", users["alice"].ID, true)
+			transfer, err := store.CreateDomainTransfer(users["alice"].ID, "demo", users["bob"].ID)
+			if err != nil {
+				t.Fatalf("CreateDomainTransfer: %v", err)
+			}
+
+			if _, err := store.ResolveDomainTransfer(transfer.ID, users["carol"].ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("ResolveDomainTransfer() by a third user = %v, want ErrNotFound", err)
+			}
+			resolved, err := store.ResolveDomainTransfer(transfer.ID, users[tt.by].ID)
+			if err != nil {
+				t.Fatalf("ResolveDomainTransfer: %v", err)
+			}
+			if resolved.Status != tt.wantStatus || resolved.ResolvedAt == nil {
+				t.Errorf("ResolveDomainTransfer() = %q at %v, want %q", resolved.Status, resolved.ResolvedAt, tt.wantStatus)
+			}
+			if _, err := store.ResolveDomainTransfer(transfer.ID, users[tt.by].ID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("ResolveDomainTransfer() twice = %v, want ErrNotFound", err)
+			}
+			if _, err := store.AcceptDomainTransfer(transfer.ID, users["bob"].ID, 1); !errors.Is(err, ErrNotFound) {
+				t.Errorf("AcceptDomainTransfer() after resolving = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestSetDomainCapture(t *testing.T) {
+	store := newTestStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	createDomain(t, store, "demo", alice.ID, true)
+	createDomain(t, store, "other", bob.ID, true)
+
+	if err := store.SetDomainCapture(alice.ID, "demo", true); err != nil {
+		t.Fatalf("SetDomainCapture: %v", err)
+	}
+	if err := store.SetDomainCapture(alice.ID, "other", true); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetDomainCapture(not owned) = %v, want ErrNotFound", err)
+	}
+	domains, err := store.GetCaptureDomains()
+	if err != nil || len(domains) != 1 || domains[0].Name != "demo" {
+		t.Fatalf("GetCaptureDomains() = %v, %v, want [demo]", domains, err)
+	}
+
+	// The new owner of a transferred domain starts without capture
+	transfer, err := store.CreateDomainTransfer(alice.ID, "demo", bob.ID)
+	if err != nil {
+		t.Fatalf("CreateDomainTransfer: %v", err)
+	}
+	if _, err := store.AcceptDomainTransfer(transfer.ID, bob.ID, 5); err != nil {
+		t.Fatalf("AcceptDomainTransfer: %v", err)
+	}
+	if domains, _ := store.GetCaptureDomains(); len(domains) != 0 {
+		t.Errorf("capture domains after transfer = %v, want none", domains)
+	}
+}
+
+func TestSetHubDomain(t *testing.T) {
+	store := newTestStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+	createDomain(t, store, "demo", alice.ID, true)
+	createDomain(t, store, "team", alice.ID, true)
+	createDomain(t, store, "other", bob.ID, true)
+
+	hubs := func() []string {
+		domains, err := store.GetHubDomains()
+		if err != nil {
+			t.Fatalf("GetHubDomains: %v", err)
+		}
+		var names []string
+		for _, d := range domains {
+			names = append(names, d.Name)
+		}
+		return names
+	}
+
+	if err := store.SetHubDomain(alice.ID, "demo"); err != nil {
+		t.Fatalf("SetHubDomain: %v", err)
+	}
+	// A user has one hub at most
+	if err := store.SetHubDomain(alice.ID, "team"); err != nil {
+		t.Fatalf("SetHubDomain: %v", err)
+	}
+	if got := hubs(); len(got) != 1 || got[0] != "team" {
+		t.Errorf("hubs = %v, want [team]", got)
+	}
+	if err := store.SetHubDomain(alice.ID, "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetHubDomain(not owned) = %v, want ErrNotFound", err)
+	}
+	if got := hubs(); len(got) != 1 || got[0] != "team" {
+		t.Errorf("hubs after failed change = %v, want [team]", got)
+	}
+
+	// A transferred domain stops being a hub
+	transfer, err := store.CreateDomainTransfer(alice.ID, "team", bob.ID)
+	if err != nil {
+		t.Fatalf("CreateDomainTransfer: %v", err)
+	}
+	if _, err := store.AcceptDomainTransfer(transfer.ID, bob.ID, 5); err != nil {
+		t.Fatalf("AcceptDomainTransfer: %v", err)
+	}
+	if got := hubs(); len(got) != 0 {
+		t.Errorf("hubs after transfer = %v, want none", got)
+	}
+
+	if err := store.SetHubDomain(alice.ID, "demo"); err != nil {
+		t.Fatalf("SetHubDomain: %v", err)
+	}
+	if err := store.SetHubDomain(alice.ID, ""); err != nil {
+		t.Fatalf("SetHubDomain(remove): %v", err)
+	}
+	if got := hubs(); len(got) != 0 {
+		t.Errorf("hubs after removal = %v, want none", got)
+	}
+}
+
+func TestDeleteTunnelDefinition(t *testing.T) {
+	store := newTestStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	def := &models.TunnelDefinition{UserID: alice.ID, Name: "web", Domain: "demo", Port: 3000, Selector: "env=preview"}
+	if err := store.CreateTunnelDefinition(def); err != nil {
+		t.Fatalf("CreateTunnelDefinition: %v", err)
+	}
+	if _, err := store.DeleteTunnelDefinition(bob.ID, def.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("DeleteTunnelDefinition(not owned) = %v, want ErrNotFound", err)
+	}
+
+	deleted, err := store.DeleteTunnelDefinition(alice.ID, def.ID)
+	if err != nil || deleted.Selector != "env=preview" {
+		t.Fatalf("DeleteTunnelDefinition() = %+v, %v, want the deleted definition", deleted, err)
+	}
+	if defs, _ := store.GetUserTunnelDefinitions(alice.ID); len(defs) != 0 {
+		t.Errorf("definitions after delete = %v, want none", defs)
+	}
+}
+
+func TestConnectionEvents(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "alice")
+	other := createUser(t, store, "bob")
+
+	events := []models.ConnectionEvent{
+		{UserID: user.ID, Domain: "app.example.com", Type: models.ConnectionEventConnect},
+		{UserID: user.ID, Domain: "api.example.com", Type: models.ConnectionEventConnect},
+		{UserID: user.ID, Domain: "app.example.com", Type: models.ConnectionEventDisconnect, Reason: models.DisconnectNetwork, Detail: "keepalive timeout"},
+		{UserID: other.ID, Domain: "app.example.com", Type: models.ConnectionEventConnect},
+	}
+	if err := store.AddConnectionEvents(events); err != nil {
+		t.Fatalf("AddConnectionEvents: %v", err)
+	}
+	if err := store.AddConnectionEvents(nil); err != nil {
+		t.Fatalf("AddConnectionEvents(nil): %v", err)
+	}
+
+	got, err := store.GetConnectionEvents(user.ID, "app.example.com", 10)
+	if err != nil {
+		t.Fatalf("GetConnectionEvents: %v", err)
+	}
+	if len(got) != 2 || got[0].Type != models.ConnectionEventDisconnect || got[0].Reason != models.DisconnectNetwork || got[1].Type != models.ConnectionEventConnect {
+		t.Errorf("GetConnectionEvents() = %+v, want the disconnect and then the connect", got)
+	}
+	if got, _ := store.GetConnectionEvents(user.ID, "app.example.com", 1); len(got) != 1 {
+		t.Errorf("GetConnectionEvents() with limit 1 returned %d events", len(got))
+	}
+
+	if n, err := store.PruneConnectionEvents(time.Now().Add(time.Second)); err != nil || n != 4 {
+		t.Errorf("PruneConnectionEvents() = %d, %v, want 4", n, err)
+	}
+}
+
+func TestOAuthAccounts(t *testing.T) {
+	store := newTestStore(t)
+	user := createUser(t, store, "alice")
+
+	if _, err := store.GetUserByOAuthID(models.ProviderGitHub, "583231"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetUserByOAuthID() before linking error = %v, want ErrNotFound", err)
+	}
+	if err := store.LinkOAuthAccount(user.ID, models.ProviderGitHub, "583231"); err != nil {
+		t.Fatalf("LinkOAuthAccount(github): %v", err)
+	}
+	if err := store.LinkOAuthAccount(user.ID, models.ProviderGoogle, "1098"); err != nil {
+		t.Fatalf("LinkOAuthAccount(google): %v", err)
+	}
+
+	for provider, id := range map[string]string{models.ProviderGitHub: "583231", models.ProviderGoogle: "1098"} {
+		got, err := store.GetUserByOAuthID(provider, id)
+		if err != nil || got.ID != user.ID {
+			t.Errorf("GetUserByOAuthID(%s) = %+v, %v, want user %d", provider, got, err, user.ID)
+		}
+	}
+	// IDs are per provider
+	if _, err := store.GetUserByOAuthID(models.ProviderGoogle, "583231"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByOAuthID(google) with a GitHub ID error = %v, want ErrNotFound", err)
+	}
+
+	other := createUser(t, store, "bob")
+	if err := store.LinkOAuthAccount(other.ID, models.ProviderGitHub, "583231"); err == nil {
+		t.Error("LinkOAuthAccount() linked an account already linked to another user")
+	}
+	if _, err := store.GetUserByOAuthID("facebook", "1"); err == nil {
+		t.Error("GetUserByOAuthID() accepted an unknown provider")
+	}
+}
+
+func TestUserEmail(t *testing.T) {
+	store := newTestStore(t)
+	alice := &models.User{Email: "Alice@Example.com"}
+	if err := store.CreateUser(alice); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := store.CreateUser(&models.User{Email: "Alice <alice@example.com>"}); !errors.Is(err, models.ErrInvalidEmail) {
+		t.Errorf("CreateUser() with a malformed email error = %v, want ErrInvalidEmail", err)
+	}
+
+	got, err := store.GetUserByEmail("alice@example.com")
+	if err != nil || got.ID != alice.ID {
+		t.Errorf("GetUserByEmail() = %+v, %v, want user %d", got, err, alice.ID)
+	}
+	if _, err := store.GetUserByEmail("bob@example.com"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetUserByEmail(unknown) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestLoginTokens(t *testing.T) {
+	store := newTestStore(t)
+
+	token, err := store.CreateLoginToken("alice@example.com", time.Hour)
+	if err != nil {
+		t.Fatalf("CreateLoginToken: %v", err)
+	}
+	email, err := store.RedeemLoginToken(token)
+	if err != nil || email != "alice@example.com" {
+		t.Fatalf("RedeemLoginToken() = %q, %v", email, err)
+	}
+	if _, err := store.RedeemLoginToken(token); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemLoginToken() twice error = %v, want ErrNotFound", err)
+	}
+
+	expired, err := store.CreateLoginToken("alice@example.com", -time.Minute)
+	if err != nil {
+		t.Fatalf("CreateLoginToken: %v", err)
+	}
+	if _, err := store.RedeemLoginToken(expired); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemLoginToken(expired) error = %v, want ErrNotFound", err)
+	}
+	if _, err := store.RedeemLoginToken(""); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RedeemLoginToken(\"\") error = %v, want ErrNotFound", err)
+	}
+
+	// Issuing a token deletes the expired ones
+	if _, err := store.CreateLoginToken("bob@example.com", time.Hour); err != nil {
+		t.Fatalf("CreateLoginToken: %v", err)
+	}
+	var count int64
+	store.db.Model(&models.LoginToken{}).Where("expires_at <= ?", time.Now()).Count(&count)
+	if count != 0 {
+		t.Errorf("%d expired login tokens left", count)
+	}
+}
+
+func TestOrganizations(t *testing.T) {
+	store := newTestStore(t)
+	alice := createUser(t, store, "alice")
+	bob := createUser(t, store, "bob")
+
+	org, err := store.CreateOrganization("Acme", alice.ID)
+	if err != nil {
+		t.Fatalf("CreateOrganization: %v", err)
+	}
+	if role, err := store.GetOrganizationRole(org.ID, alice.ID); err != nil || role != models.RoleOwner {
+		t.Errorf("GetOrganizationRole(creator) = %q, %v, want owner", role, err)
+	}
+	if _, err := store.GetOrganizationRole(org.ID, bob.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("GetOrganizationRole(outsider) error = %v, want ErrNotFound", err)
+	}
+
+	if err := store.AddOrganizationMember(org.ID, bob.ID, models.RoleViewer); err != nil {
+		t.Fatalf("AddOrganizationMember: %v", err)
+	}
+	if err := store.AddOrganizationMember(org.ID, bob.ID, models.RoleMember); !errors.Is(err, ErrAlreadyMember) {
+		t.Errorf("AddOrganizationMember() twice error = %v, want ErrAlreadyMember", err)
+	}
+	if err := store.SetOrganizationRole(org.ID, bob.ID, models.RoleAdmin); err != nil {
+		t.Fatalf("SetOrganizationRole: %v", err)
+	}
+	members, err := store.GetOrganizationMembers(org.ID)
+	if err != nil || len(members) != 2 || members[1].User.Username != "bob" || members[1].Role != models.RoleAdmin {
+		t.Errorf("GetOrganizationMembers() = %+v, %v", members, err)
+	}
+	orgs, err := store.GetUserOrganizations(bob.ID)
+	if err != nil || len(orgs) != 1 || orgs[0].Organization.Name != "Acme" {
+		t.Errorf("GetUserOrganizations() = %+v, %v", orgs, err)
+	}
+
+	// A removed member can be added again
+	if err := store.RemoveOrganizationMember(org.ID, bob.ID); err != nil {
+		t.Fatalf("RemoveOrganizationMember: %v", err)
+	}
+	if err := store.RemoveOrganizationMember(org.ID, bob.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("RemoveOrganizationMember() twice error = %v, want ErrNotFound", err)
+	}
+	if err := store.SetOrganizationRole(org.ID, bob.ID, models.RoleMember); !errors.Is(err, ErrNotFound) {
+		t.Errorf("SetOrganizationRole(removed) error = %v, want ErrNotFound", err)
+	}
+	if err := store.AddOrganizationMember(org.ID, bob.ID, models.RoleMember); err != nil {
+		t.Errorf("AddOrganizationMember() after removal: %v", err)
+	}
+
+	// Deleting a user ends their memberships
+	if err := store.DeleteUser(bob.ID); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if orgs, err := store.GetUserOrganizations(bob.ID); err != nil || len(orgs) != 0 {
+		t.Errorf("GetUserOrganizations(deleted) = %+v, %v, want none", orgs, err)
+	}
+}
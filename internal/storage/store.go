@@ -14,31 +14,139 @@ type Store interface {
 	GetUserByID(id uint) (*models.User, error)
 	GetUserByTelegramID(telegramID int64) (*models.User, error)
 	GetUserByYandexID(yandexID string) (*models.User, error)
+	GetUserByOIDCSubject(subject string) (*models.User, error)
+	GetUserByOAuthID(provider, id string) (*models.User, error)
+	GetUserByEmail(email string) (*models.User, error)
 	CreateUser(user *models.User) error
 	UpdateUser(user *models.User) error
+	GetUserByStripeCustomer(customerID string) (*models.User, error)
+	UpdateUserBilling(userID uint, plan, customerID, subscriptionID string) error
+	SetUserRole(userID uint, role string) error
 	AcceptTerms(userID uint) error
+	SetQuotaAlerts(userID uint, enabled bool) error
+	SetTOTP(userID uint, secret string, enabled bool) error
+	RecordDevice(userID uint, fingerprint, name, ip string) (*models.Device, error)
+	GetUserDevices(userID uint, since time.Time) ([]models.Device, error)
+	RevokeDevice(userID, deviceID uint) (*models.Device, error)
+
+	// Alert rule operations
+	CreateAlertRule(rule *models.AlertRule) error
+	GetUserAlertRules(userID uint) ([]models.AlertRule, error)
+	DeleteAlertRule(userID, ruleID uint) error
+	MarkAlertDomainsOnline(domains []string, at time.Time) error
+	GetOfflineAlertRules(seenBefore time.Time) ([]models.AlertRule, error)
+	GetRecoveredAlertRules() ([]models.AlertRule, error)
+	SetAlertFired(ruleID uint, firedAt *time.Time) error
+
+	// Tunnel definition operations
+	CreateTunnelDefinition(def *models.TunnelDefinition) error
+	GetUserTunnelDefinitions(userID uint) ([]models.TunnelDefinition, error)
+	DeleteTunnelDefinition(userID, defID uint) (*models.TunnelDefinition, error)
+	DeleteUser(userID uint) error
 	LinkYandexAccount(userID uint, yandexID string) error
 	LinkTelegramAccount(userID uint, telegramID int64) error
+	LinkOAuthAccount(userID uint, provider, id string) error
+	GetPendingUsers() ([]models.User, error)
+	GetUsers() ([]models.User, error)
+	GetBandwidthToday() (map[uint]int64, error)
+	RevokeUserTokens(userID uint) error
+	BanUser(userID uint) error
+	UnbanUser(userID uint) error
+	ApproveUser(userID uint, domains []string) (string, error)
+	RejectUser(userID uint) error
 
 	// Token operations
 	ValidateToken(tokenStr string) (*models.User, error)
 	GetUserToken(userID uint) (*models.Token, error)
 	CreateToken(token *models.Token) error
 	RegenerateToken(userID uint) (string, error)
+	SetTokenExpiry(userID uint, ttl time.Duration) (string, time.Time, error)
+	RefreshToken(refresh string, ttl time.Duration) (string, string, time.Time, error)
+	CreateJoinToken(userID uint, ttl time.Duration) (string, *models.JoinToken, error)
+	GetUserJoinTokens(userID uint, since time.Time) ([]models.JoinToken, error)
+	DeleteJoinToken(userID, joinID uint) error
+	RedeemJoinToken(join, agent string) (string, error)
+	CreateLoginToken(email string, ttl time.Duration) (string, error)
+	RedeemLoginToken(token string) (string, error)
+	CreateAPIToken(userID uint, name string) (string, *models.APIToken, error)
+	GetUserAPITokens(userID uint) ([]models.APIToken, error)
+	DeleteAPIToken(userID, tokenID uint) error
+	ValidateAPIToken(tokenStr string) (*models.User, *models.APIToken, error)
+	ClaimIdempotencyKey(userID uint, key, requestHash string, since time.Time) (*models.IdempotencyKey, bool, error)
+	CompleteIdempotencyKey(id uint, status int, response []byte) error
+	ReleaseIdempotencyKey(id uint) error
+	PruneIdempotencyKeys(before time.Time) (int64, error)
 
 	// Domain operations
 	GetUserDomains(userID uint) ([]models.Domain, error)
 	ValidateDomainOwnership(domainName string, userID uint) (bool, error)
 	CreateDomain(domain *models.Domain) error
+	GetAllDomains() ([]models.Domain, error)
+	RecycleOrphanedDomains(grace time.Duration) (int64, error)
+	ReleaseDomain(userID uint, domainName string) error
+	IsDomainAvailable(domainName string) (bool, error)
+	ReserveDomain(userID uint, domainName string, limit int) (*models.Domain, error)
+	GetUserByHandle(handle string) (*models.User, error)
+	CreateDomainTransfer(fromUserID uint, domainName string, toUserID uint) (*models.DomainTransfer, error)
+	GetDomainTransfers(userID uint) (incoming, outgoing []models.DomainTransfer, err error)
+	AcceptDomainTransfer(transferID, userID uint, limit int) (*models.DomainTransfer, error)
+	ResolveDomainTransfer(transferID, userID uint) (*models.DomainTransfer, error)
+	SuspendDomain(domainName, reason string) error
+	UnsuspendDomain(domainName string) error
+	GetSuspendedDomains() ([]models.Domain, error)
+	SetDomainSchedule(userID uint, domainName, schedule, timezone string) error
+	GetScheduledDomains() ([]models.Domain, error)
+	SetHubDomain(userID uint, domainName string) error
+	GetHubDomains() ([]models.Domain, error)
+	SetDomainCapture(userID uint, domainName string, enabled bool) error
+	GetCaptureDomains() ([]models.Domain, error)
+
+	// Organization operations
+	CreateOrganization(name string, ownerID uint) (*models.Organization, error)
+	GetUserOrganizations(userID uint) ([]models.OrganizationMember, error)
+	GetOrganizationMembers(orgID uint) ([]models.OrganizationMember, error)
+	GetOrganizationRole(orgID, userID uint) (string, error)
+	AddOrganizationMember(orgID, userID uint, role string) error
+	SetOrganizationRole(orgID, userID uint, role string) error
+	RemoveOrganizationMember(orgID, userID uint) error
+
+	// Invite operations
+	CreateInvite(invite *models.Invite) error
+	GetUserInvites(userID uint) ([]models.Invite, error)
+	CountReferrals(userID uint) (int64, error)
 
 	// Abuse report operations
 	CreateAbuseReport(report *models.AbuseReport) error
 	GetAbuseReports(status string) ([]models.AbuseReport, error)
+	UpdateAbuseReportStatus(id uint, status string) error
 
 	// Bandwidth operations
 	GetUserBandwidthToday(userID uint) (int64, error)
 	GetUserTotalBandwidth(userID uint) (int64, error)
 	AddUserBandwidth(userID uint, bytes int64) error
+	GetTotalBandwidthToday() (int64, error)
+	CompactBandwidth(before time.Time) (int64, error)
+
+	// Geo usage operations
+	AddUserGeoUsage(userID uint, country string, bytes int64) error
+	GetUserGeoUsage(userID uint, since time.Time) ([]GeoUsage, error)
+	PruneGeoUsage(before time.Time) (int64, error)
+
+	// Status page operations
+	GetUserByStatusSlug(slug string) (*models.User, error)
+	SetStatusPage(userID uint, slug *string, domains []string) error
+	AddDomainUptime(domains []string, seconds int64) error
+	GetDomainUptime(domains []string, since time.Time) ([]models.DomainUptime, error)
+	PruneDomainUptime(before time.Time) (int64, error)
+
+	// Connection history
+	AddConnectionEvents(events []models.ConnectionEvent) error
+	GetConnectionEvents(userID uint, domain string, limit int) ([]models.ConnectionEvent, error)
+	PruneConnectionEvents(before time.Time) (int64, error)
+
+	// Background job locking
+	AcquireJobLock(name, owner string, ttl time.Duration) (bool, error)
+	ReleaseJobLock(name, owner string) error
 
 	// Transaction support
 	CreateUserWithTokenAndDomains(reg UserRegistration) (*models.User, string, error)
@@ -53,5 +161,12 @@ type BandwidthResult struct {
 	Date      time.Time
 }
 
+// GeoUsage is the traffic of one visitor country
+type GeoUsage struct {
+	Country   string
+	Requests  int64
+	BytesUsed int64
+}
+
 // Ensure SQLiteStore implements Store interface
 var _ Store = (*SQLiteStore)(nil)
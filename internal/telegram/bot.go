@@ -13,12 +13,15 @@ import (
 	"gopublic/internal/storage"
 )
 
-// Bot handles Telegram bot interactions for admin statistics
+// Bot handles Telegram bot interactions: admin statistics and, once
+// enabled, tunnel management commands for users
 type Bot struct {
 	token         string
 	adminID       int64
 	stopCh        chan struct{}
 	lastUpdateID  int64
+
+	user *UserCommands // nil = admin commands only
 }
 
 // NewBot creates a new Telegram bot instance
@@ -32,12 +35,12 @@ func NewBot(token string, adminID int64) *Bot {
 
 // Start begins the long polling loop for receiving updates
 func (b *Bot) Start() {
-	if b.token == "" || b.adminID == 0 {
-		log.Println("Telegram bot not configured (missing token or admin ID)")
+	if b.token == "" {
+		log.Println("Telegram bot not configured (missing token)")
 		return
 	}
 
-	log.Println("Starting Telegram admin bot...")
+	log.Println("Starting Telegram bot...")
 
 	go b.pollUpdates()
 }
@@ -141,25 +144,31 @@ func (b *Bot) handleUpdate(update Update) {
 
 	msg := update.Message
 
-	// Only respond to admin (check both user ID and chat ID for security)
-	if msg.From == nil || msg.From.ID != b.adminID {
-		// Silently ignore messages from non-admins
+	// Only respond in private chats, where the chat ID equals the sender ID
+	if msg.From == nil || msg.Chat == nil || msg.Chat.ID != msg.From.ID {
 		return
 	}
 
-	// Also verify the chat is a private chat with admin (not a group)
-	if msg.Chat.ID != b.adminID {
-		return
-	}
+	command, arg := parseCommand(msg.Text)
 
-	// Handle commands
-	text := strings.TrimSpace(msg.Text)
-	switch {
-	case text == "/stats" || text == "/start":
-		b.sendStats(msg.Chat.ID)
-	case text == "/help":
-		b.sendHelp(msg.Chat.ID)
+	// Admin commands (the admin can use user commands as well)
+	if b.adminID != 0 && msg.From.ID == b.adminID {
+		switch command {
+		case "/stats":
+			b.sendStats(msg.Chat.ID)
+			return
+		case "/start":
+			if b.user == nil {
+				b.sendStats(msg.Chat.ID)
+				return
+			}
+		case "/help":
+			b.sendHelp(msg.Chat.ID)
+			return
+		}
 	}
+
+	b.handleUserCommand(msg.From.ID, command, arg)
 }
 
 func (b *Bot) sendStats(chatID int64) {
@@ -220,6 +229,9 @@ func (b *Bot) sendHelp(chatID int64) {
 /help — Показать справку
 
 Бот показывает статистику только администратору.`
+	if b.user != nil {
+		help += "\n\n" + userHelp
+	}
 
 	b.sendMessage(chatID, help)
 }
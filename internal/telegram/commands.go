@@ -0,0 +1,163 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"gopublic/internal/billing"
+	"gopublic/internal/models"
+	"gopublic/internal/storage"
+)
+
+// SessionProvider reports users' live tunnels.
+// This interface is implemented by server.UserSessionRegistry.
+type SessionProvider interface {
+	IsConnected(userID uint) bool
+	GetActiveDomains(userID uint) []string
+}
+
+// DomainRevoker stops routing a domain on whichever instance serves it.
+// This interface is implemented by server.Server.
+type DomainRevoker interface {
+	RevokeDomain(ctx context.Context, domain, reason string) error
+}
+
+// UserCommands connects the bot to live tunnels so that users can list and
+// disconnect them and check their bandwidth from Telegram.
+type UserCommands struct {
+	Sessions       SessionProvider
+	Revoker        DomainRevoker
+	RootDomain     string             // Appended to bare domain names in /disconnect
+	BandwidthLimit int64              // Default daily limit in bytes (0 = unlimited)
+	PlanBandwidth  billing.PlanLimits // Per-plan overrides of BandwidthLimit
+}
+
+// EnableUserCommands turns on /tunnels, /disconnect and /usage for all
+// users with a linked Telegram account. Must be called before Start.
+func (b *Bot) EnableUserCommands(cmds UserCommands) {
+	b.user = &cmds
+}
+
+// parseCommand splits "/cmd@BotName arg" into "/cmd" and "arg".
+func parseCommand(text string) (string, string) {
+	command, arg, _ := strings.Cut(strings.TrimSpace(text), " ")
+	command, _, _ = strings.Cut(command, "@")
+	return strings.ToLower(command), strings.TrimSpace(arg)
+}
+
+// handleUserCommand answers a command from a regular user in a private chat.
+func (b *Bot) handleUserCommand(telegramID int64, command, arg string) {
+	if b.user == nil {
+		return
+	}
+
+	switch command {
+	case "/start", "/help", "/tunnels", "/disconnect", "/usage":
+	default:
+		return
+	}
+
+	user, err := storage.GetUserByTelegramID(telegramID)
+	if errors.Is(err, storage.ErrNotFound) {
+		b.sendMessage(telegramID, "Аккаунт не найден. Войдите в панель управления через Telegram, чтобы создать его.")
+		return
+	} else if err != nil {
+		log.Printf("Error looking up Telegram user %d: %v", telegramID, err)
+		b.sendMessage(telegramID, "❌ Ошибка базы данных, попробуйте позже.")
+		return
+	}
+
+	switch command {
+	case "/start", "/help":
+		b.sendMessage(telegramID, userHelp)
+	case "/tunnels":
+		b.sendMessage(telegramID, b.tunnelsMessage(user))
+	case "/disconnect":
+		b.sendMessage(telegramID, b.disconnect(user, arg))
+	case "/usage":
+		b.sendMessage(telegramID, b.usageMessage(user))
+	}
+}
+
+const userHelp = `🤖 *Команды бота:*
+
+/tunnels — Активные туннели
+/disconnect <домен> — Отключить туннель
+/usage — Расход трафика
+/help — Показать справку`
+
+func (b *Bot) tunnelsMessage(user *models.User) string {
+	domains := b.user.Sessions.GetActiveDomains(user.ID)
+	if !b.user.Sessions.IsConnected(user.ID) || len(domains) == 0 {
+		return "Нет активных туннелей."
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔌 *Активные туннели:*\n\n")
+	for _, domain := range domains {
+		sb.WriteString(fmt.Sprintf("• `%s`\n", domain))
+	}
+	sb.WriteString("\nОтключить: /disconnect <домен>")
+	return sb.String()
+}
+
+// disconnect stops routing one of the user's active domains.
+func (b *Bot) disconnect(user *models.User, arg string) string {
+	if arg == "" {
+		return "Укажите домен: /disconnect <домен>"
+	}
+	domain := strings.ToLower(arg)
+	if b.user.RootDomain != "" && !strings.HasSuffix(domain, "."+b.user.RootDomain) {
+		domain += "." + b.user.RootDomain
+	}
+
+	active := false
+	for _, d := range b.user.Sessions.GetActiveDomains(user.ID) {
+		if d == domain {
+			active = true
+			break
+		}
+	}
+	if !active {
+		return fmt.Sprintf("Туннель `%s` не найден среди ваших активных туннелей.", domain)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := b.user.Revoker.RevokeDomain(ctx, domain, "disconnected via Telegram"); err != nil {
+		log.Printf("Error disconnecting %s for user %d: %v", domain, user.ID, err)
+		return "❌ Не удалось отключить туннель, попробуйте позже."
+	}
+	return fmt.Sprintf("✅ Туннель `%s` отключён. Перезапустите клиент, чтобы подключить его снова.", domain)
+}
+
+func (b *Bot) usageMessage(user *models.User) string {
+	today, err := storage.GetUserBandwidthToday(user.ID)
+	if err != nil {
+		log.Printf("Error getting bandwidth for user %d: %v", user.ID, err)
+		return "❌ Ошибка получения статистики, попробуйте позже."
+	}
+	total, err := storage.GetUserTotalBandwidth(user.ID)
+	if err != nil {
+		log.Printf("Error getting total bandwidth for user %d: %v", user.ID, err)
+		return "❌ Ошибка получения статистики, попробуйте позже."
+	}
+	return formatUsage(today, total, b.user.PlanBandwidth.Bandwidth(user.Plan, b.user.BandwidthLimit))
+}
+
+// formatUsage renders the /usage reply.
+func formatUsage(today, total, limit int64) string {
+	var sb strings.Builder
+	sb.WriteString("📊 *Расход трафика*\n\n")
+	if limit > 0 {
+		sb.WriteString(fmt.Sprintf("*Сегодня:* %s из %s (%d%%)\n", formatBytes(today), formatBytes(limit), today*100/limit))
+	} else {
+		sb.WriteString(fmt.Sprintf("*Сегодня:* %s (без ограничений)\n", formatBytes(today)))
+	}
+	sb.WriteString(fmt.Sprintf("*Всего:* %s\n", formatBytes(total)))
+	return sb.String()
+}
@@ -0,0 +1,86 @@
+package telegram
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"gopublic/internal/models"
+)
+
+type fakeSessions map[uint][]string
+
+func (f fakeSessions) IsConnected(userID uint) bool          { _, ok := f[userID]; return ok }
+func (f fakeSessions) GetActiveDomains(userID uint) []string { return f[userID] }
+
+type fakeRevoker struct{ revoked []string }
+
+func (f *fakeRevoker) RevokeDomain(_ context.Context, domain, _ string) error {
+	f.revoked = append(f.revoked, domain)
+	return nil
+}
+
+func TestParseCommand(t *testing.T) {
+	tests := []struct {
+		text, command, arg string
+	}{
+		{"/tunnels", "/tunnels", ""},
+		{"  /disconnect   misty-river-1  ", "/disconnect", "misty-river-1"},
+		{"/usage@GoPublicBot", "/usage", ""},
+		{"/Disconnect@GoPublicBot a.example.com", "/disconnect", "a.example.com"},
+	}
+	for _, tt := range tests {
+		command, arg := parseCommand(tt.text)
+		if command != tt.command || arg != tt.arg {
+			t.Errorf("parseCommand(%q) = %q, %q; want %q, %q", tt.text, command, arg, tt.command, tt.arg)
+		}
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	revoker := &fakeRevoker{}
+	b := NewBot("token", 0)
+	b.EnableUserCommands(UserCommands{
+		Sessions:   fakeSessions{1: {"misty-river-1.example.com"}, 2: {"bold-fox-2.example.com"}},
+		Revoker:    revoker,
+		RootDomain: "example.com",
+	})
+	user := &models.User{}
+	user.ID = 1
+
+	// Bare names are expanded to the FQDN
+	if reply := b.disconnect(user, "misty-river-1"); !strings.HasPrefix(reply, "✅") {
+		t.Errorf("disconnect own domain: %q", reply)
+	}
+	// Another user's domain is not touched
+	if reply := b.disconnect(user, "bold-fox-2.example.com"); strings.HasPrefix(reply, "✅") {
+		t.Errorf("disconnect foreign domain: %q", reply)
+	}
+	if len(revoker.revoked) != 1 || revoker.revoked[0] != "misty-river-1.example.com" {
+		t.Errorf("revoked = %v", revoker.revoked)
+	}
+}
+
+func TestTunnelsMessage(t *testing.T) {
+	b := NewBot("token", 0)
+	b.EnableUserCommands(UserCommands{Sessions: fakeSessions{1: {"a.example.com", "b.example.com"}}})
+
+	user := &models.User{}
+	user.ID = 1
+	if msg := b.tunnelsMessage(user); !strings.Contains(msg, "a.example.com") || !strings.Contains(msg, "b.example.com") {
+		t.Errorf("tunnelsMessage() = %q", msg)
+	}
+	user.ID = 2
+	if msg := b.tunnelsMessage(user); msg != "Нет активных туннелей." {
+		t.Errorf("tunnelsMessage() for offline user = %q", msg)
+	}
+}
+
+func TestFormatUsage(t *testing.T) {
+	if got := formatUsage(50<<20, 1<<30, 100<<20); !strings.Contains(got, "50.0 MB из 100.0 MB (50%)") {
+		t.Errorf("formatUsage() with limit = %q", got)
+	}
+	if got := formatUsage(50<<20, 1<<30, 0); !strings.Contains(got, "без ограничений") {
+		t.Errorf("formatUsage() unlimited = %q", got)
+	}
+}
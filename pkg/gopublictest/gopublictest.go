@@ -0,0 +1,171 @@
+// Package gopublictest exposes test servers through a gopublic tunnel, so
+// that integration tests can hand a public callback URL to third-party
+// webhook providers:
+//
+//	func TestStripeWebhook(t *testing.T) {
+//		srv := httptest.NewServer(handler)
+//		defer srv.Close()
+//		url := gopublictest.Expose(t, srv)
+//		// Register url + "/webhook" with the provider ...
+//	}
+//
+// The token is taken from GOPUBLIC_TOKEN or the token saved by
+// "gopublic auth"; the server from GOPUBLIC_SERVER. Tests are skipped when
+// no token is configured, so that they still pass on machines without
+// credentials.
+package gopublictest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"gopublic/internal/client/config"
+	"gopublic/internal/client/events"
+	"gopublic/internal/client/tunnel"
+)
+
+// Environment variables read by Expose.
+const (
+	EnvToken  = "GOPUBLIC_TOKEN"
+	EnvServer = "GOPUBLIC_SERVER"
+)
+
+// DefaultServer is used when neither WithServer nor GOPUBLIC_SERVER is set.
+const DefaultServer = "localhost:4443"
+
+// DefaultTimeout bounds the wait for the tunnel to come up.
+const DefaultTimeout = 30 * time.Second
+
+// Option configures Expose.
+type Option func(*options)
+
+type options struct {
+	token     string
+	server    string
+	subdomain string
+	force     bool
+	timeout   time.Duration
+	pin       string // Server key pinned by "gopublic trust", if any
+}
+
+// WithToken sets the auth token instead of GOPUBLIC_TOKEN.
+func WithToken(token string) Option {
+	return func(o *options) { o.token = token }
+}
+
+// WithServer sets the control plane address (host:port) instead of
+// GOPUBLIC_SERVER.
+func WithServer(addr string) Option {
+	return func(o *options) { o.server = addr }
+}
+
+// WithSubdomain binds only one of the user's domains. By default all of
+// them are bound and the first one is returned.
+func WithSubdomain(name string) Option {
+	return func(o *options) { o.subdomain = name }
+}
+
+// WithForce disconnects another session of the same user, such as a
+// client left running on the developer's machine.
+func WithForce() Option {
+	return func(o *options) { o.force = true }
+}
+
+// WithTimeout changes how long Expose waits for the tunnel.
+func WithTimeout(d time.Duration) Option {
+	return func(o *options) { o.timeout = d }
+}
+
+// resolve applies opts on top of the environment and the saved client config.
+func resolve(opts []Option) options {
+	o := options{
+		token:   os.Getenv(EnvToken),
+		server:  os.Getenv(EnvServer),
+		timeout: DefaultTimeout,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.server == "" {
+		o.server = DefaultServer
+	}
+	if cfg, err := config.LoadConfig(); err == nil {
+		if o.token == "" {
+			o.token = cfg.Token
+		}
+		o.pin = cfg.TLSPins[o.server]
+	}
+	return o
+}
+
+// Expose starts a tunnel to srv and returns its public URL, e.g.
+// "https://misty-river.example.com". The tunnel is closed when the test
+// and its subtests finish. Expose skips the test if no token is configured
+// and fails it if the tunnel does not come up.
+func Expose(tb testing.TB, srv *httptest.Server, opts ...Option) string {
+	tb.Helper()
+
+	o := resolve(opts)
+	if o.token == "" {
+		tb.Skipf("gopublictest: no token; set %s or run \"gopublic auth\"", EnvToken)
+	}
+	_, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		tb.Fatalf("gopublictest: test server address: %v", err)
+	}
+
+	bus := events.NewBus()
+	ready := bus.Subscribe()
+
+	t := tunnel.NewTunnel(o.server, o.token, port)
+	t.Subdomain = o.subdomain
+	t.SetForce(o.force)
+	t.SetEventBus(bus)
+	t.SetTLSConfig(&tunnel.TLSConfig{Pin: o.pin})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- t.StartWithReconnect(ctx, &tunnel.ReconnectConfig{
+			InitialDelay: 500 * time.Millisecond,
+			MaxDelay:     5 * time.Second,
+			Multiplier:   2,
+			MaxAttempts:  3,
+		})
+	}()
+
+	stop := func() {
+		cancel()
+		<-done
+		bus.Close()
+	}
+
+	timeout := time.NewTimer(o.timeout)
+	defer timeout.Stop()
+	for {
+		select {
+		case event := <-ready:
+			data, ok := event.Data.(events.TunnelReadyData)
+			if event.Type != events.EventTunnelReady || !ok || len(data.BoundDomains) == 0 {
+				continue
+			}
+			tb.Cleanup(stop)
+			return data.Scheme + "://" + data.BoundDomains[0]
+		case err := <-done:
+			cancel()
+			bus.Close()
+			if err == nil {
+				err = errors.New("tunnel closed")
+			}
+			tb.Fatalf("gopublictest: tunnel to %s failed: %v", o.server, err)
+		case <-timeout.C:
+			stop()
+			tb.Fatalf("gopublictest: tunnel to %s not ready after %v", o.server, o.timeout)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package gopublictest
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/yamux"
+
+	"gopublic/pkg/protocol"
+)
+
+// fakeControlPlane accepts one client session, binds domain and returns
+// the server side of the session.
+func fakeControlPlane(t *testing.T, domain string) (addr string, sessions <-chan *yamux.Session) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan *yamux.Session, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		session, err := yamux.Server(conn, nil)
+		if err != nil {
+			return
+		}
+		control, err := session.Accept()
+		if err != nil {
+			return
+		}
+		dec := json.NewDecoder(control)
+		var auth protocol.AuthRequest
+		var req protocol.TunnelRequest
+		if dec.Decode(&auth) != nil || dec.Decode(&req) != nil {
+			return
+		}
+		resp := protocol.InitResponse{Success: auth.Token == "test-token", BoundDomains: []string{domain}}
+		if !resp.Success {
+			resp.Error = "invalid token"
+			resp.ErrorCode = protocol.ErrorCodeInvalidToken
+		}
+		json.NewEncoder(control).Encode(resp)
+		ch <- session
+	}()
+	return ln.Addr().String(), ch
+}
+
+func TestExpose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "pong from "+r.Host)
+	}))
+	defer srv.Close()
+
+	addr, sessions := fakeControlPlane(t, "misty-river.example.com")
+	url := Expose(t, srv, WithToken("test-token"), WithServer(addr), WithTimeout(5*time.Second))
+	if url != "http://misty-river.example.com" {
+		t.Fatalf("Expose() = %q", url)
+	}
+
+	// Requests from the ingress reach the test server
+	session := <-sessions
+	stream, err := session.Open()
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stream.Close()
+	req, _ := http.NewRequest(http.MethodGet, url+"/ping", nil)
+	req.Write(stream)
+	resp, err := http.ReadResponse(bufio.NewReader(stream), req)
+	if err != nil {
+		t.Fatalf("read response: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "pong from misty-river.example.com" {
+		t.Errorf("body = %q", body)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	t.Setenv(EnvToken, "env-token")
+	t.Setenv(EnvServer, "")
+
+	o := resolve(nil)
+	if o.token != "env-token" || o.server != DefaultServer || o.timeout != DefaultTimeout {
+		t.Errorf("resolve() = %+v", o)
+	}
+
+	o = resolve([]Option{WithToken("opt-token"), WithServer("tunnel.example.com:4443"), WithSubdomain("misty-river"), WithForce()})
+	if o.token != "opt-token" || o.server != "tunnel.example.com:4443" || o.subdomain != "misty-river" || !o.force {
+		t.Errorf("resolve(opts) = %+v", o)
+	}
+}
@@ -0,0 +1,29 @@
+package protocol
+
+import "fmt"
+
+// MaxAgentLength is the longest agent name the server accepts.
+const MaxAgentLength = 63
+
+// Remote commands the server sends in Heartbeat.Command to clients that
+// announced AuthRequest.Commands.
+const (
+	// CommandDisconnect stops the client for good instead of reconnecting.
+	CommandDisconnect = "disconnect"
+	// CommandReload restarts the tunnels with the project config, or the
+	// tunnel definitions of a managed client, re-read.
+	CommandReload = "reload"
+)
+
+// ValidateAgent checks an agent name: lowercase alphanumerics, dots,
+// dashes and underscores, like a label key. The empty name is valid and
+// stands for the user's regular session.
+func ValidateAgent(agent string) error {
+	if agent == "" {
+		return nil
+	}
+	if len(agent) > MaxAgentLength || !labelKeyPattern.MatchString(agent) {
+		return fmt.Errorf("invalid agent name %q", agent)
+	}
+	return nil
+}
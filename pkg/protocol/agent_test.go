@@ -0,0 +1,29 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateAgent(t *testing.T) {
+	tests := []struct {
+		name    string
+		agent   string
+		wantErr bool
+	}{
+		{"empty", "", false},
+		{"simple", "ci-runner-1", false},
+		{"dotted", "homelab.nas", false},
+		{"uppercase", "CI", true},
+		{"space", "my agent", true},
+		{"trailing dash", "ci-", true},
+		{"too long", strings.Repeat("a", MaxAgentLength+1), true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateAgent(tt.agent); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateAgent(%q) error = %v, wantErr %v", tt.agent, err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,39 @@
+package protocol
+
+import (
+	"errors"
+	"strings"
+)
+
+// AllDomains keys TunnelRequest.BasicAuth credentials that protect every
+// domain bound by the request.
+const AllDomains = "*"
+
+// MaxBasicAuthLength limits "user:pass" credentials.
+const MaxBasicAuthLength = 255
+
+// ValidateBasicAuth checks "user:pass" credentials of a tunnel.
+func ValidateBasicAuth(creds string) error {
+	user, pass, ok := strings.Cut(creds, ":")
+	if !ok || user == "" || pass == "" {
+		return errors.New(`basic auth must be "user:pass"`)
+	}
+	if len(creds) > MaxBasicAuthLength {
+		return errors.New("basic auth too long")
+	}
+	for _, r := range creds {
+		if r < 0x20 || r == 0x7f {
+			return errors.New("basic auth contains control characters")
+		}
+	}
+	return nil
+}
+
+// BasicAuthFor returns the credentials protecting domain, or "" if the
+// domain is public.
+func BasicAuthFor(basicAuth map[string]string, domain string) string {
+	if creds, ok := basicAuth[domain]; ok {
+		return creds
+	}
+	return basicAuth[AllDomains]
+}
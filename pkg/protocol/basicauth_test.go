@@ -0,0 +1,35 @@
+package protocol
+
+import "testing"
+
+func TestValidateBasicAuth(t *testing.T) {
+	tests := []struct {
+		creds string
+		ok    bool
+	}{
+		{"admin:secret", true},
+		{"admin:pass:with:colons", true},
+		{"admin", false},
+		{":secret", false},
+		{"admin:", false},
+		{"admin:se\ncret", false},
+	}
+	for _, tt := range tests {
+		if err := ValidateBasicAuth(tt.creds); (err == nil) != tt.ok {
+			t.Errorf("ValidateBasicAuth(%q) = %v, want ok=%v", tt.creds, err, tt.ok)
+		}
+	}
+}
+
+func TestBasicAuthFor(t *testing.T) {
+	auth := map[string]string{"api": "a:1", AllDomains: "all:2"}
+	if got := BasicAuthFor(auth, "api"); got != "a:1" {
+		t.Errorf("BasicAuthFor(api) = %q, want a:1", got)
+	}
+	if got := BasicAuthFor(auth, "web"); got != "all:2" {
+		t.Errorf("BasicAuthFor(web) = %q, want all:2", got)
+	}
+	if got := BasicAuthFor(nil, "web"); got != "" {
+		t.Errorf("BasicAuthFor(nil) = %q, want public", got)
+	}
+}
@@ -0,0 +1,46 @@
+package protocol
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TunnelDefinition is a tunnel defined in the dashboard rather than in
+// gopublic.yaml. Clients started with --managed fetch the definitions of
+// their user (AuthRequest.Definitions) and run those whose Selector
+// matches their labels.
+type TunnelDefinition struct {
+	Name      string `json:"name"`
+	Subdomain string `json:"subdomain"`
+	Port      int    `json:"port"` // Local port on the agent's machine
+	// Selector picks the agents that run the tunnel, "key=value" against
+	// their labels; empty for every managed agent of the user.
+	Selector string `json:"selector,omitempty"`
+}
+
+// ValidateSelector checks a "key=value" label selector. The empty selector
+// is valid and matches every agent.
+func ValidateSelector(selector string) error {
+	if selector == "" {
+		return nil
+	}
+	key, value, ok := strings.Cut(selector, "=")
+	if !ok {
+		return fmt.Errorf("invalid selector %q: expected key=value", selector)
+	}
+	return ValidateLabels(map[string]string{key: value})
+}
+
+// MatchSelector reports whether labels satisfy the selector, see
+// ValidateSelector.
+func MatchSelector(selector string, labels map[string]string) bool {
+	if selector == "" {
+		return true
+	}
+	key, value, ok := strings.Cut(selector, "=")
+	if !ok {
+		return false
+	}
+	got, ok := labels[key]
+	return ok && got == value
+}
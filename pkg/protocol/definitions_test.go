@@ -0,0 +1,44 @@
+package protocol
+
+import "testing"
+
+func TestValidateSelector(t *testing.T) {
+	tests := []struct {
+		selector string
+		wantErr  bool
+	}{
+		{"", false},
+		{"env=preview", false},
+		{"pr=", false},
+		{"env", true},
+		{"Env=preview", true},
+		{"=preview", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateSelector(tt.selector); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSelector(%q) error = %v, wantErr %v", tt.selector, err, tt.wantErr)
+		}
+	}
+}
+
+func TestMatchSelector(t *testing.T) {
+	labels := map[string]string{"env": "preview", "pr": ""}
+	tests := []struct {
+		selector string
+		labels   map[string]string
+		want     bool
+	}{
+		{"", nil, true},
+		{"env=preview", labels, true},
+		{"env=staging", labels, false},
+		{"pr=", labels, true},
+		{"team=", labels, false},
+		{"env=preview", nil, false},
+		{"env", labels, false},
+	}
+	for _, tt := range tests {
+		if got := MatchSelector(tt.selector, tt.labels); got != tt.want {
+			t.Errorf("MatchSelector(%q, %v) = %v, want %v", tt.selector, tt.labels, got, tt.want)
+		}
+	}
+}
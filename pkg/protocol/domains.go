@@ -0,0 +1,37 @@
+package protocol
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxDomainLength is the longest hostname DNS allows.
+const MaxDomainLength = 253
+
+// domainLabelPattern matches one DNS label, e.g. "misty-river".
+var domainLabelPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// ValidateSubdomain checks a subdomain requested by a client, a single DNS
+// label such as "misty-river".
+func ValidateSubdomain(name string) error {
+	if !domainLabelPattern.MatchString(name) {
+		return fmt.Errorf("invalid subdomain %q: use lowercase letters, digits and dashes", name)
+	}
+	return nil
+}
+
+// ValidateDomain checks a full hostname requested by a client, such as
+// "misty-river.tunnel.example.com".
+func ValidateDomain(host string) error {
+	labels := strings.Split(host, ".")
+	if len(host) > MaxDomainLength || len(labels) < 2 {
+		return fmt.Errorf("invalid domain %q: want a full hostname", host)
+	}
+	for _, label := range labels {
+		if !domainLabelPattern.MatchString(label) {
+			return fmt.Errorf("invalid domain %q: use lowercase letters, digits and dashes", host)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateSubdomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		wantErr bool
+	}{
+		{"misty-river", false},
+		{"a", false},
+		{"app2", false},
+		{"", true},
+		{"Misty", true},
+		{"-misty", true},
+		{"misty-", true},
+		{"misty.river", true},
+		{strings.Repeat("a", 64), true},
+	}
+	for _, tt := range tests {
+		if err := ValidateSubdomain(tt.name); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateSubdomain(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidateDomain(t *testing.T) {
+	tests := []struct {
+		host    string
+		wantErr bool
+	}{
+		{"misty-river.tunnel.example.com", false},
+		{"example.com", false},
+		{"misty-river", true},
+		{"misty..example.com", true},
+		{"example.com.", true},
+		{"my_app.example.com", true},
+		{strings.Repeat("a.", 127) + "com", true},
+	}
+	for _, tt := range tests {
+		if err := ValidateDomain(tt.host); (err != nil) != tt.wantErr {
+			t.Errorf("ValidateDomain(%q) error = %v, wantErr %v", tt.host, err, tt.wantErr)
+		}
+	}
+}
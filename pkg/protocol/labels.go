@@ -0,0 +1,49 @@
+package protocol
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Label limits enforced by the server.
+const (
+	MaxLabels           = 16
+	MaxLabelKeyLength   = 63
+	MaxLabelValueLength = 255
+)
+
+// labelKeyPattern allows lowercase alphanumerics, dots, dashes and underscores.
+var labelKeyPattern = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+
+// ValidateLabels checks tunnel labels against the protocol limits.
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > MaxLabels {
+		return fmt.Errorf("too many labels: %d (max %d)", len(labels), MaxLabels)
+	}
+	for k, v := range labels {
+		if len(k) > MaxLabelKeyLength || !labelKeyPattern.MatchString(k) {
+			return fmt.Errorf("invalid label key %q", k)
+		}
+		if len(v) > MaxLabelValueLength {
+			return fmt.Errorf("label %q value too long (max %d)", k, MaxLabelValueLength)
+		}
+	}
+	return nil
+}
+
+// FormatLabels renders labels as "k1=v1, k2=v2" sorted by key.
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return strings.Join(parts, ", ")
+}
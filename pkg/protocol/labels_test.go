@@ -0,0 +1,49 @@
+package protocol
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateLabels(t *testing.T) {
+	tooMany := make(map[string]string)
+	for i := 0; i <= MaxLabels; i++ {
+		tooMany[string(rune('a'+i))] = "x"
+	}
+
+	tests := []struct {
+		name    string
+		labels  map[string]string
+		wantErr bool
+	}{
+		{"nil", nil, false},
+		{"simple", map[string]string{"env": "staging", "pr": "123"}, false},
+		{"dotted key", map[string]string{"app.kubernetes.io-name": "web"}, false},
+		{"empty value", map[string]string{"env": ""}, false},
+		{"empty key", map[string]string{"": "x"}, true},
+		{"uppercase key", map[string]string{"Env": "x"}, true},
+		{"space in key", map[string]string{"my env": "x"}, true},
+		{"long key", map[string]string{strings.Repeat("k", MaxLabelKeyLength+1): "x"}, true},
+		{"long value", map[string]string{"env": strings.Repeat("v", MaxLabelValueLength+1)}, true},
+		{"too many", tooMany, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLabels(tt.labels)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateLabels() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	got := FormatLabels(map[string]string{"pr": "123", "env": "staging"})
+	if got != "env=staging, pr=123" {
+		t.Errorf("FormatLabels() = %q, want %q", got, "env=staging, pr=123")
+	}
+	if got := FormatLabels(nil); got != "" {
+		t.Errorf("FormatLabels(nil) = %q, want empty", got)
+	}
+}
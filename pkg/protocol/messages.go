@@ -1,5 +1,7 @@
 package protocol
 
+import "time"
+
 // ErrorCode represents structured error codes for protocol responses.
 type ErrorCode string
 
@@ -8,19 +10,90 @@ const (
 	ErrorCodeInvalidToken     ErrorCode = "invalid_token"
 	ErrorCodeAlreadyConnected ErrorCode = "already_connected"
 	ErrorCodeNoDomains        ErrorCode = "no_domains"
+	ErrorCodeInvalidLabels    ErrorCode = "invalid_labels"
+	ErrorCodeQuotaExceeded    ErrorCode = "quota_exceeded"
+	ErrorCodeTCPUnavailable   ErrorCode = "tcp_unavailable"
+	ErrorCodeServerBusy       ErrorCode = "server_busy"
+	ErrorCodeInvalidBasicAuth ErrorCode = "invalid_basic_auth"
+	ErrorCodeTokenExpired     ErrorCode = "token_expired"
+	ErrorCodeInvalidAgent     ErrorCode = "invalid_agent"
+	ErrorCodeTooManyAgents    ErrorCode = "too_many_agents"
+	ErrorCodeInvalidRateLimit ErrorCode = "invalid_rate_limit"
 )
 
 // AuthRequest is the first message sent by the client to authenticate using a token.
 type AuthRequest struct {
 	Token string `json:"token"`
 	Force bool   `json:"force,omitempty"` // Force disconnect existing session
+	// Probe opens a temporary measurement session (see SpeedtestRequest):
+	// no domains are bound and an existing session is left untouched.
+	Probe bool `json:"probe,omitempty"`
+	// Device describes the client machine, e.g. "laptop (darwin/arm64)".
+	// It is shown in the dashboard device list.
+	Device string `json:"device,omitempty"`
+	// Refresh exchanges a refresh token for a new token instead of opening
+	// a session; Token is ignored. The server answers with an InitResponse
+	// carrying Refreshed and closes the session.
+	Refresh string `json:"refresh,omitempty"`
+	// Join redeems a one-time join token created in the dashboard for a
+	// token of the agent's own; Token is ignored and Agent is required.
+	// The server answers with an InitResponse carrying Refreshed and
+	// closes the session.
+	Join string `json:"join,omitempty"`
+	// Agent names a long-running client, e.g. "ci-runner-1". Sessions
+	// with different agent names coexist instead of replacing each other,
+	// and are listed in the dashboard fleet view. Empty for a regular
+	// session, of which a user has at most one.
+	Agent string `json:"agent,omitempty"`
+	// Version is the client version, shown in the fleet view.
+	Version string `json:"version,omitempty"`
+	// Commands tells that the client acts on Heartbeat.Command.
+	Commands bool `json:"commands,omitempty"`
+	// Managed tells that the session runs tunnels defined in the
+	// dashboard; it is sent CommandReload when they change.
+	Managed bool `json:"managed,omitempty"`
+	// Definitions asks for the user's TunnelDefinitions instead of opening
+	// a session. The server answers with an InitResponse carrying
+	// Definitions and closes the session.
+	Definitions bool `json:"definitions,omitempty"`
+}
+
+// TokenRefresh is a new token issued for a refresh token, which stops
+// working along with the expired token.
+type TokenRefresh struct {
+	Token        string    `json:"token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
 }
 
 // TunnelRequest follows authentication to request binding of specific domains.
+// After a successful handshake the client may send further TunnelRequests on
+// the control stream to bind domains that were refused; each is answered
+// with an InitResponse listing only the newly bound domains.
 type TunnelRequest struct {
-	RequestedDomains []string `json:"requested_domains"`
+	RequestedDomains []string          `json:"requested_domains"`
+	Labels           map[string]string `json:"labels,omitempty"` // Arbitrary metadata, e.g. env=staging
+	// Proto selects the tunnel type, ProtoHTTP if empty. A ProtoTCP session
+	// binds no domains: the server assigns a public port and bridges each
+	// connection to it over a new stream, without parsing the traffic.
+	// InitResponse.BoundDomains then holds the public "host:port".
+	Proto string `json:"proto,omitempty"`
+	// BasicAuth holds "user:pass" credentials, keyed by requested domain or
+	// AllDomains. The ingress asks visitors of those domains to log in
+	// before any request reaches the tunnel.
+	BasicAuth map[string]string `json:"basic_auth,omitempty"`
+	// RateLimit throttles requests to the domains, keyed like BasicAuth.
+	// The server caps it at its own limit, which also applies to domains
+	// without one.
+	RateLimit map[string]RateLimit `json:"rate_limit,omitempty"`
 }
 
+// Tunnel protocols, see TunnelRequest.Proto.
+const (
+	ProtoHTTP = "http"
+	ProtoTCP  = "tcp"
+)
+
 // ServerStats contains user bandwidth statistics from the server.
 type ServerStats struct {
 	BandwidthToday int64 `json:"bandwidth_today"` // Bytes used today
@@ -37,4 +110,59 @@ type InitResponse struct {
 	// but for now it confirms what was bound.
 	BoundDomains []string     `json:"bound_domains,omitempty"`
 	ServerStats  *ServerStats `json:"server_stats,omitempty"` // User bandwidth statistics
+	// RetryAfter is how many seconds the client should wait before
+	// reconnecting, set with ErrorCodeServerBusy.
+	RetryAfter int `json:"retry_after,omitempty"`
+	// Heartbeat tells that the server echoes Heartbeat messages on streams
+	// the client opens, see Heartbeat. Older servers leave it unset.
+	Heartbeat bool `json:"heartbeat,omitempty"`
+	// Refreshed answers an AuthRequest with Refresh or Join set.
+	Refreshed *TokenRefresh `json:"refreshed,omitempty"`
+	// Definitions answers an AuthRequest with Definitions set.
+	Definitions []TunnelDefinition `json:"definitions,omitempty"`
+}
+
+// Heartbeat is sent by the client on a stream it opens after a successful
+// handshake, every few seconds; the server writes each one back. A missing
+// echo tells the client the connection is dead even though TCP has not
+// noticed yet.
+type Heartbeat struct {
+	Seq uint64 `json:"seq"`
+	// Stats is set by the server on an echo every StatsPushInterval, so
+	// that the client can show usage live rather than only at handshake.
+	Stats *StatsPush `json:"stats,omitempty"`
+	// Command is set by the server on an echo to deliver a remote action
+	// requested from the dashboard, e.g. CommandReload. Only sent to
+	// clients that announced AuthRequest.Commands.
+	Command string `json:"command,omitempty"`
+}
+
+// StatsPushInterval is how often the server attaches a StatsPush to a
+// heartbeat echo.
+const StatsPushInterval = 10 * time.Second
+
+// StatsPush reports the usage of a session as the server sees it.
+type StatsPush struct {
+	ServerStats
+	Domains []DomainStats `json:"domains,omitempty"`
+	// Concurrent ingress streams of the session and their limit
+	// (0 = unlimited); requests beyond it queue, then fail.
+	StreamsActive int `json:"streams_active"`
+	StreamsLimit  int `json:"streams_limit"`
+	// QuotaExceeded tells a connected client that the daily bandwidth
+	// limit is used up: the session stays open, but the ingress answers
+	// visitors with 429 until the limit resets. New sessions are refused
+	// with ErrorCodeQuotaExceeded meanwhile.
+	QuotaExceeded bool `json:"quota_exceeded,omitempty"`
+}
+
+// DomainStats counts the traffic of a bound domain since it was bound.
+type DomainStats struct {
+	Domain   string `json:"domain"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`
+	// Requests the ingress answered with 429 because they exceeded the
+	// rate limit of the domain (RateLimit requests per second, 0 = none)
+	Throttled int64   `json:"throttled,omitempty"`
+	RateLimit float64 `json:"rate_limit,omitempty"`
 }
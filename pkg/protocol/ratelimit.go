@@ -0,0 +1,45 @@
+package protocol
+
+import (
+	"errors"
+	"math"
+)
+
+// MaxRateBurst limits RateLimit.Burst.
+const MaxRateBurst = 10000
+
+// RateLimit throttles the requests the ingress forwards to a domain. The
+// ingress answers requests over the limit with 429 itself.
+type RateLimit struct {
+	RPS   float64 `json:"rps"`             // Sustained requests per second
+	Burst int     `json:"burst,omitempty"` // Requests allowed at once; 0 = RPS rounded up
+}
+
+// BurstOrDefault returns Burst, or RPS rounded up if Burst is not set.
+func (l RateLimit) BurstOrDefault() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return max(1, int(math.Ceil(l.RPS)))
+}
+
+// ValidateRateLimit checks a rate limit requested for a tunnel.
+func ValidateRateLimit(l RateLimit) error {
+	if l.RPS <= 0 || math.IsNaN(l.RPS) || math.IsInf(l.RPS, 0) {
+		return errors.New("rate limit must be a positive number of requests per second")
+	}
+	if l.Burst < 0 || l.Burst > MaxRateBurst {
+		return errors.New("rate burst must be between 0 and 10000")
+	}
+	return nil
+}
+
+// RateLimitFor returns the rate limit requested for domain, keyed like
+// TunnelRequest.BasicAuth, and whether there is one.
+func RateLimitFor(limits map[string]RateLimit, domain string) (RateLimit, bool) {
+	if l, ok := limits[domain]; ok {
+		return l, true
+	}
+	l, ok := limits[AllDomains]
+	return l, ok
+}
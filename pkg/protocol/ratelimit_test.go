@@ -0,0 +1,55 @@
+package protocol
+
+import (
+	"math"
+	"testing"
+)
+
+func TestValidateRateLimit(t *testing.T) {
+	tests := []struct {
+		limit RateLimit
+		ok    bool
+	}{
+		{RateLimit{RPS: 10}, true},
+		{RateLimit{RPS: 0.5, Burst: 5}, true},
+		{RateLimit{RPS: 0}, false},
+		{RateLimit{RPS: -1}, false},
+		{RateLimit{RPS: math.Inf(1)}, false},
+		{RateLimit{RPS: 10, Burst: -1}, false},
+		{RateLimit{RPS: 10, Burst: MaxRateBurst + 1}, false},
+	}
+	for _, tt := range tests {
+		if err := ValidateRateLimit(tt.limit); (err == nil) != tt.ok {
+			t.Errorf("ValidateRateLimit(%+v) = %v, want ok=%v", tt.limit, err, tt.ok)
+		}
+	}
+}
+
+func TestRateLimitBurstOrDefault(t *testing.T) {
+	tests := []struct {
+		limit RateLimit
+		want  int
+	}{
+		{RateLimit{RPS: 10, Burst: 20}, 20},
+		{RateLimit{RPS: 2.5}, 3},
+		{RateLimit{RPS: 0.2}, 1},
+	}
+	for _, tt := range tests {
+		if got := tt.limit.BurstOrDefault(); got != tt.want {
+			t.Errorf("%+v.BurstOrDefault() = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimitFor(t *testing.T) {
+	limits := map[string]RateLimit{"api": {RPS: 1}, AllDomains: {RPS: 5}}
+	if got, ok := RateLimitFor(limits, "api"); !ok || got.RPS != 1 {
+		t.Errorf("RateLimitFor(api) = %+v, %v, want 1 rps", got, ok)
+	}
+	if got, ok := RateLimitFor(limits, "web"); !ok || got.RPS != 5 {
+		t.Errorf("RateLimitFor(web) = %+v, %v, want 5 rps", got, ok)
+	}
+	if _, ok := RateLimitFor(nil, "web"); ok {
+		t.Error("RateLimitFor(nil) reported a limit")
+	}
+}
@@ -0,0 +1,30 @@
+package protocol
+
+// SpeedtestMode selects the measurement performed on a probe stream.
+type SpeedtestMode string
+
+const (
+	// SpeedtestPing is answered immediately with an empty SpeedtestResult.
+	SpeedtestPing SpeedtestMode = "ping"
+	// SpeedtestDownload makes the server write Bytes bytes, then close the stream.
+	SpeedtestDownload SpeedtestMode = "download"
+	// SpeedtestUpload makes the server read Bytes bytes and reply with a SpeedtestResult.
+	SpeedtestUpload SpeedtestMode = "upload"
+)
+
+// MaxSpeedtestBytes caps the payload of a single download or upload.
+const MaxSpeedtestBytes = 100 << 20
+
+// SpeedtestRequest is the first message on a stream opened by the client
+// on a probe session.
+type SpeedtestRequest struct {
+	Mode  SpeedtestMode `json:"mode"`
+	Bytes int64         `json:"bytes,omitempty"`
+}
+
+// SpeedtestResult reports what the server measured.
+type SpeedtestResult struct {
+	Bytes      int64  `json:"bytes"`
+	DurationNs int64  `json:"duration_ns"` // Server-side transfer time
+	Error      string `json:"error,omitempty"`
+}